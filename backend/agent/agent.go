@@ -22,10 +22,13 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/security/advancedtls"
+	"google.golang.org/grpc/status"
 
 	"isc.org/stork"
 	agentapi "isc.org/stork/api"
+	keactrl "isc.org/stork/appctrl/kea"
 )
 
 // Global Stork Agent state.
@@ -36,6 +39,8 @@ type StorkAgent struct {
 	server         *grpc.Server
 	logTailer      *logTailer
 	keaInterceptor *keaInterceptor
+	commandPolicy  *CommandPolicy
+	staticApps     []App
 	shutdownOnce   sync.Once
 	hookManager    *HookManager
 
@@ -52,9 +57,12 @@ func NewStorkAgent(settings *cli.Context, appMonitor AppMonitor, hookManager *Ho
 		HTTPClient:     NewHTTPClient(settings.Bool("skip-tls-cert-verification")),
 		logTailer:      logTailer,
 		keaInterceptor: newKeaInterceptor(),
+		commandPolicy:  loadCommandPolicy(),
 		hookManager:    hookManager,
 	}
 
+	sa.staticApps = loadStaticApps(sa.HTTPClient)
+
 	registerKeaInterceptFns(sa)
 
 	return sa
@@ -177,6 +185,7 @@ func (sa *StorkAgent) GetState(ctx context.Context, in *agentapi.GetStateReq) (*
 		apps = append(apps, &agentapi.App{
 			Type:         app.GetBaseApp().Type,
 			AccessPoints: accessPoints,
+			ConfigPath:   app.GetBaseApp().ConfigPath,
 		})
 	}
 
@@ -218,7 +227,7 @@ func (sa *StorkAgent) ForwardRndcCommand(ctx context.Context, in *agentapi.Forwa
 		RndcResponse: rndcRsp,
 	}
 
-	app := sa.AppMonitor.GetApp(AppTypeBind9, AccessPointControl, in.Address, in.Port)
+	app := sa.AppMonitor.GetApp(AppTypeBind9, AccessPointControl, in.Address, in.Port, in.ConfigPath)
 	if app == nil {
 		rndcRsp.Status.Code = agentapi.Status_ERROR
 		rndcRsp.Status.Message = "Cannot find BIND 9 app"
@@ -234,9 +243,19 @@ func (sa *StorkAgent) ForwardRndcCommand(ctx context.Context, in *agentapi.Forwa
 	}
 
 	request := in.GetRndcRequest()
+	command := strings.Fields(request.Request)
+
+	// Make sure the command is accepted by the command policy.
+	if err := sa.commandPolicy.CheckRndcCommand(command); err != nil {
+		log.WithFields(log.Fields{
+			"Address": in.Address,
+			"Port":    in.Port,
+		}).Warnf("Rejected rndc command: %s", err)
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	}
 
 	// Try to forward the command to rndc.
-	output, err := bind9App.sendCommand(strings.Fields(request.Request))
+	output, err := bind9App.sendCommand(command)
 	if err != nil {
 		log.WithFields(log.Fields{
 			"Address": in.Address,
@@ -325,6 +344,24 @@ func (sa *StorkAgent) ForwardToKeaOverHTTP(ctx context.Context, in *agentapi.For
 
 	requests := in.GetKeaRequests()
 
+	// Make sure all commands are accepted by the command policy before
+	// forwarding any of them.
+	for _, req := range requests {
+		command, err := keactrl.NewCommandFromJSON(req.Request)
+		if err != nil {
+			log.WithFields(log.Fields{
+				"URL": reqURL,
+			}).Warnf("Rejected malformed Kea command: %s", err)
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		if err = sa.commandPolicy.CheckKeaCommand(command); err != nil {
+			log.WithFields(log.Fields{
+				"URL": reqURL,
+			}).Warnf("Rejected Kea command: %s", err)
+			return nil, status.Error(codes.PermissionDenied, err.Error())
+		}
+	}
+
 	// forward requests to kea one by one
 	for _, req := range requests {
 		rsp := &agentapi.KeaResponse{
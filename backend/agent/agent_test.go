@@ -17,7 +17,9 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"github.com/urfave/cli/v2"
+	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/security/advancedtls"
+	"google.golang.org/grpc/status"
 	"gopkg.in/h2non/gock.v1"
 
 	"isc.org/stork"
@@ -75,6 +77,7 @@ func setupAgentTestWithHooks(calloutCarriers []hooks.CalloutCarrier) (*StorkAgen
 		HTTPClient:     httpClient,
 		logTailer:      newLogTailer(),
 		keaInterceptor: newKeaInterceptor(),
+		commandPolicy:  NewCommandPolicy(),
 		hookManager:    NewHookManager(),
 	}
 
@@ -89,11 +92,14 @@ func (fam *FakeAppMonitor) GetApps() []App {
 }
 
 // Stub function for AppMonitor. It behaves in the same way as original one.
-func (fam *FakeAppMonitor) GetApp(appType, apType, address string, port int64) App {
+func (fam *FakeAppMonitor) GetApp(appType, apType, address string, port int64, configPath string) App {
 	for _, app := range fam.Apps {
 		if app.GetBaseApp().Type != appType {
 			continue
 		}
+		if configPath != "" && app.GetBaseApp().ConfigPath != configPath {
+			continue
+		}
 		for _, ap := range app.GetBaseApp().AccessPoints {
 			if ap.Type == apType && ap.Address == address && ap.Port == port {
 				return app
@@ -154,6 +160,7 @@ func TestGetState(t *testing.T) {
 		BaseApp: BaseApp{
 			Type:         AppTypeKea,
 			AccessPoints: makeAccessPoint(AccessPointControl, "1.2.3.1", "", 1234, false),
+			ConfigPath:   "/etc/kea/kea-ctrl-agent.conf",
 		},
 		HTTPClient: nil,
 	})
@@ -184,6 +191,7 @@ func TestGetState(t *testing.T) {
 	require.Len(t, rsp.Apps, 2)
 
 	keaApp := rsp.Apps[0]
+	require.Equal(t, "/etc/kea/kea-ctrl-agent.conf", keaApp.ConfigPath)
 	require.Len(t, keaApp.AccessPoints, 1)
 	point := keaApp.AccessPoints[0]
 	require.Equal(t, AccessPointControl, point.Type)
@@ -355,6 +363,43 @@ func TestForwardToKeaOverHTTPNoKea(t *testing.T) {
 	require.Len(t, rsp.KeaResponses[0].Response, 0)
 }
 
+// Test that the Kea commands rejected by the command policy are not
+// forwarded to Kea.
+func TestForwardToKeaOverHTTPRejectedByPolicy(t *testing.T) {
+	sa, ctx := setupAgentTest()
+
+	req := &agentapi.ForwardToKeaOverHTTPReq{
+		Url: "http://localhost:45634/",
+		KeaRequests: []*agentapi.KeaRequest{
+			{Request: "{ \"command\": \"list-commands\"}"},
+			{Request: "{ \"command\": \"lease4-wipe\", \"service\": [ \"dhcp4\" ] }"},
+		},
+	}
+
+	// The destructive command is rejected by the default policy. None of
+	// the commands should be forwarded.
+	rsp, err := sa.ForwardToKeaOverHTTP(ctx, req)
+	require.Nil(t, rsp)
+	require.Error(t, err)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+	require.Contains(t, err.Error(), "lease4-wipe")
+}
+
+// Test that the malformed Kea commands are not forwarded to Kea.
+func TestForwardToKeaOverHTTPMalformedCommand(t *testing.T) {
+	sa, ctx := setupAgentTest()
+
+	req := &agentapi.ForwardToKeaOverHTTPReq{
+		Url:         "http://localhost:45634/",
+		KeaRequests: []*agentapi.KeaRequest{{Request: "{ \"command\": "}},
+	}
+
+	rsp, err := sa.ForwardToKeaOverHTTP(ctx, req)
+	require.Nil(t, rsp)
+	require.Error(t, err)
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
 // Test successful forwarding stats request to named.
 func TestForwardToNamedStatsSuccess(t *testing.T) {
 	sa, ctx := setupAgentTest()
@@ -596,6 +641,44 @@ func TestForwardRndcCommandEmpty(t *testing.T) {
 	require.Empty(t, rsp.Status.Message)
 }
 
+// Test that the rndc command rejected by the command policy is not forwarded
+// to named.
+func TestForwardRndcCommandRejectedByPolicy(t *testing.T) {
+	sa, ctx := setupAgentTest()
+	err := sa.commandPolicy.SetRules(AppTypeBind9, "named", []string{"status"}, nil)
+	require.NoError(t, err)
+
+	accessPoints := makeAccessPoint(AccessPointControl, "127.0.0.1", "_", 1234, false)
+	var apps []App
+	apps = append(apps, &Bind9App{
+		BaseApp: BaseApp{
+			Type:         AppTypeBind9,
+			AccessPoints: accessPoints,
+		},
+		RndcClient: NewRndcClient(mockRndc),
+	})
+	fam, _ := sa.AppMonitor.(*FakeAppMonitor)
+	fam.Apps = apps
+
+	req := &agentapi.ForwardRndcCommandReq{
+		Address:     "127.0.0.1",
+		Port:        1234,
+		RndcRequest: &agentapi.RndcRequest{Request: "reload example.com"},
+	}
+
+	// The command is not on the allowed list.
+	rsp, err := sa.ForwardRndcCommand(ctx, req)
+	require.Nil(t, rsp)
+	require.Error(t, err)
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	// The allowed command is forwarded.
+	req.RndcRequest.Request = "status"
+	rsp, err = sa.ForwardRndcCommand(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, agentapi.Status_OK, rsp.Status.Code)
+}
+
 // Test that the tail of the text file can be fetched.
 func TestTailTextFile(t *testing.T) {
 	sa, ctx := setupAgentTest()
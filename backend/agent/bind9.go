@@ -592,6 +592,7 @@ func detectBind9App(match []string, cwd string, executor storkutil.CommandExecut
 		BaseApp: BaseApp{
 			Type:         AppTypeBind9,
 			AccessPoints: accessPoints,
+			ConfigPath:   bind9ConfPath,
 		},
 		RndcClient: rndcClient,
 	}
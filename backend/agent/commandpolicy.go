@@ -0,0 +1,304 @@
+package agent
+
+// Policy controlling which commands the agent forwards to the monitored
+// daemons. The policy is read from a dedicated JSON file.
+//
+// The file contains the rules per app type and daemon name, for example:
+//
+//	{
+//	    "kea": {
+//	        "*": { "deny": [ "config-set" ] },
+//	        "dhcp4": { "allow": [ "lease4-wipe", "lease4-*", "config-get" ] }
+//	    },
+//	    "bind9": {
+//	        "named": { "allow": [ "status" ] }
+//	    }
+//	}
+//
+// The "*" key defines the rules for the daemons that have no dedicated
+// entry. A pattern ending with an asterisk matches all commands starting
+// with the pattern prefix.
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	keactrl "isc.org/stork/appctrl/kea"
+)
+
+// CommandPolicyFile path to a file holding the command policy of the agent.
+// It is being modified by tests so needs to be writable.
+var CommandPolicyFile = "/etc/stork/agent-command-policy.json" //nolint:gochecknoglobals
+
+// Name of the rules entry applied to the daemons without dedicated rules.
+const commandPolicyAnyDaemon = "*"
+
+// Name of the daemon receiving the Kea commands without the service list.
+const commandPolicyKeaCADaemon = "ca"
+
+// Name of the BIND 9 daemon receiving the rndc commands.
+const commandPolicyBind9Daemon = "named"
+
+// List of the commands considered destructive. They are rejected unless
+// they are explicitly listed (by exact name) in the allowed commands of
+// a daemon.
+//
+//nolint:gochecknoglobals
+var destructiveCommands = map[string][]string{
+	AppTypeKea: {
+		"cache-clear",
+		"config-set",
+		"lease4-wipe",
+		"lease6-wipe",
+		"shutdown",
+	},
+	AppTypeBind9: {
+		"delzone",
+		"flush",
+		"flushname",
+		"flushtree",
+		"halt",
+		"stop",
+	},
+}
+
+// Error returned when the command is rejected by the policy.
+type CommandRejectedError struct {
+	AppType string
+	Daemon  string
+	Command string
+	Reason  string
+}
+
+// Returns the error message describing the rejected command.
+func (e *CommandRejectedError) Error() string {
+	return fmt.Sprintf("%s command '%s' for the '%s' daemon rejected by the agent command policy: %s",
+		e.AppType, e.Command, e.Daemon, e.Reason)
+}
+
+// Allowed and denied command patterns of a single daemon.
+type commandRules struct {
+	allow []string
+	deny  []string
+}
+
+// Command policy with an API to check the commands against the configured
+// rules.
+type CommandPolicy struct {
+	// Rules indexed by the app type and the daemon name.
+	rules map[string]map[string]*commandRules
+	// Indicates that all commands are rejected because the policy file
+	// could not be loaded.
+	denyAll bool
+}
+
+// Structure of the command policy JSON file.
+type CommandPolicyContent struct {
+	Kea   map[string]CommandPolicyContentRules `json:"kea"`
+	Bind9 map[string]CommandPolicyContentRules `json:"bind9"`
+}
+
+// Rules of a single daemon in the command policy JSON file.
+type CommandPolicyContentRules struct {
+	Allow []string `json:"allow"`
+	Deny  []string `json:"deny"`
+}
+
+// Constructor of the command policy. The policy without the rules accepts
+// all commands except the destructive ones.
+func NewCommandPolicy() *CommandPolicy {
+	return &CommandPolicy{
+		rules: map[string]map[string]*commandRules{
+			AppTypeKea:   {},
+			AppTypeBind9: {},
+		},
+	}
+}
+
+// Constructor of the command policy rejecting all commands. It is used
+// when the command policy file exists but cannot be loaded.
+func newDenyAllCommandPolicy() *CommandPolicy {
+	policy := NewCommandPolicy()
+	policy.denyAll = true
+	return policy
+}
+
+// Read the command policy content from reader.
+func (cp *CommandPolicy) Read(reader io.Reader) error {
+	rawContent, err := io.ReadAll(reader)
+	if err != nil {
+		return errors.Wrap(err, "cannot read the command policy")
+	}
+	var content CommandPolicyContent
+	err = json.Unmarshal(rawContent, &content)
+	if err != nil {
+		return errors.Wrap(err, "cannot parse the command policy")
+	}
+	return cp.loadContent(&content)
+}
+
+// Sets the rules for the given app type and daemon. The daemon may be
+// specified as "*" to set the rules for all daemons lacking the dedicated
+// rules.
+func (cp *CommandPolicy) SetRules(appType, daemon string, allow, deny []string) error {
+	appRules, ok := cp.rules[appType]
+	if !ok {
+		return errors.Errorf("unsupported app type: %s", appType)
+	}
+	if len(daemon) == 0 {
+		return errors.Errorf("missing daemon name for the %s rules", appType)
+	}
+	for _, patterns := range [][]string{allow, deny} {
+		for _, pattern := range patterns {
+			if len(pattern) == 0 {
+				return errors.Errorf("empty command pattern for the %s daemon", daemon)
+			}
+		}
+	}
+	appRules[daemon] = &commandRules{
+		allow: allow,
+		deny:  deny,
+	}
+	return nil
+}
+
+// Checks if the Kea command is accepted by the policy. The command is
+// checked for each daemon it is addressed to. If the service list is empty,
+// the command is addressed to the Kea Control Agent. It returns
+// CommandRejectedError if the command is not accepted.
+func (cp *CommandPolicy) CheckKeaCommand(command *keactrl.Command) error {
+	daemons := command.GetDaemonsList()
+	if len(daemons) == 0 {
+		daemons = []string{commandPolicyKeaCADaemon}
+	}
+	for _, daemon := range daemons {
+		if err := cp.check(AppTypeKea, daemon, command.GetCommand()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Checks if the rndc command is accepted by the policy. The first item of
+// the command is its name; the remaining items are the arguments. It returns
+// CommandRejectedError if the command is not accepted.
+func (cp *CommandPolicy) CheckRndcCommand(command []string) error {
+	if len(command) == 0 {
+		return nil
+	}
+	return cp.check(AppTypeBind9, commandPolicyBind9Daemon, command[0])
+}
+
+// Checks the command against the rules of a given daemon. The denied
+// commands are always rejected. The destructive commands are accepted only
+// if they are explicitly allowed. Other commands are accepted if the allowed
+// list is empty or any allowed pattern matches them.
+func (cp *CommandPolicy) check(appType, daemon, command string) error {
+	rules, ok := cp.rules[appType][daemon]
+	if !ok {
+		rules, ok = cp.rules[appType][commandPolicyAnyDaemon]
+		if !ok {
+			rules = &commandRules{}
+		}
+	}
+
+	rejected := func(reason string) error {
+		return &CommandRejectedError{
+			AppType: appType,
+			Daemon:  daemon,
+			Command: command,
+			Reason:  reason,
+		}
+	}
+
+	if cp.denyAll {
+		return rejected("the command policy file cannot be loaded")
+	}
+
+	if matchCommandPattern(rules.deny, command) {
+		return rejected("the command is denied")
+	}
+
+	for _, destructive := range destructiveCommands[appType] {
+		if command != destructive {
+			continue
+		}
+		for _, allowed := range rules.allow {
+			if allowed == command {
+				return nil
+			}
+		}
+		return rejected("the destructive command is not explicitly allowed")
+	}
+
+	if len(rules.allow) > 0 && !matchCommandPattern(rules.allow, command) {
+		return rejected("the command is not allowed")
+	}
+	return nil
+}
+
+// Load the content from JSON file to the command policy.
+func (cp *CommandPolicy) loadContent(content *CommandPolicyContent) error {
+	for appType, daemons := range map[string]map[string]CommandPolicyContentRules{
+		AppTypeKea:   content.Kea,
+		AppTypeBind9: content.Bind9,
+	} {
+		for daemon, entry := range daemons {
+			err := cp.SetRules(appType, daemon, entry.Allow, entry.Deny)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Checks if any of the patterns matches the command. The pattern matches
+// if it is equal to the command or ends with an asterisk and the command
+// starts with the pattern prefix.
+func matchCommandPattern(patterns []string, command string) bool {
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, "*") {
+			if strings.HasPrefix(command, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+			continue
+		}
+		if pattern == command {
+			return true
+		}
+	}
+	return false
+}
+
+// Creates the command policy from the command policy file. If the file is
+// missing, the default policy rejecting the destructive commands is
+// returned. If the file exists but is unreadable or invalid, the policy
+// rejecting all commands is returned.
+func loadCommandPolicy() *CommandPolicy {
+	policy := NewCommandPolicy()
+	_, err := os.Stat(CommandPolicyFile)
+	if errors.Is(err, os.ErrNotExist) {
+		log.Infof("The command policy file (%s) is missing - only the destructive commands are rejected", CommandPolicyFile)
+		return policy
+	}
+	if err == nil {
+		var file *os.File
+		file, err = os.Open(CommandPolicyFile)
+		if err == nil {
+			defer file.Close()
+			err = policy.Read(file)
+		}
+	}
+	if err != nil {
+		log.Errorf("Cannot read the command policy from file (%s) - all commands are rejected, %+v", CommandPolicyFile, err)
+		return newDenyAllCommandPolicy()
+	}
+	log.Infof("Configured to use the command policy from file (%s)", CommandPolicyFile)
+	return policy
+}
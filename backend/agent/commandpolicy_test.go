@@ -0,0 +1,192 @@
+package agent
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	keactrl "isc.org/stork/appctrl/kea"
+)
+
+// Test that the command policy is constructed correctly.
+func TestNewCommandPolicy(t *testing.T) {
+	policy := NewCommandPolicy()
+	require.NotNil(t, policy)
+	require.Len(t, policy.rules, 2)
+	require.Empty(t, policy.rules[AppTypeKea])
+	require.Empty(t, policy.rules[AppTypeBind9])
+}
+
+// Test that the default policy accepts the non-destructive commands and
+// rejects the destructive ones.
+func TestCommandPolicyDefault(t *testing.T) {
+	policy := NewCommandPolicy()
+
+	require.NoError(t, policy.CheckKeaCommand(keactrl.NewCommand("config-get", []string{"dhcp4"}, nil)))
+	require.NoError(t, policy.CheckKeaCommand(keactrl.NewCommand("list-commands", nil, nil)))
+	require.NoError(t, policy.CheckRndcCommand([]string{"status"}))
+	require.NoError(t, policy.CheckRndcCommand([]string{}))
+
+	err := policy.CheckKeaCommand(keactrl.NewCommand("lease4-wipe", []string{"dhcp4"}, nil))
+	require.ErrorContains(t, err, "destructive")
+	var rejected *CommandRejectedError
+	require.ErrorAs(t, err, &rejected)
+	require.Equal(t, AppTypeKea, rejected.AppType)
+	require.Equal(t, "dhcp4", rejected.Daemon)
+	require.Equal(t, "lease4-wipe", rejected.Command)
+
+	err = policy.CheckKeaCommand(keactrl.NewCommand("shutdown", nil, nil))
+	require.ErrorAs(t, err, &rejected)
+	require.Equal(t, "ca", rejected.Daemon)
+
+	err = policy.CheckRndcCommand([]string{"stop", "-p"})
+	require.ErrorAs(t, err, &rejected)
+	require.Equal(t, AppTypeBind9, rejected.AppType)
+	require.Equal(t, "named", rejected.Daemon)
+	require.Equal(t, "stop", rejected.Command)
+}
+
+// Test that the denied commands are rejected and the allowed list limits
+// the accepted commands.
+func TestCommandPolicyAllowAndDeny(t *testing.T) {
+	policy := NewCommandPolicy()
+	err := policy.SetRules(AppTypeKea, "dhcp4", []string{"lease4-*", "config-get"}, []string{"lease4-del"})
+	require.NoError(t, err)
+
+	require.NoError(t, policy.CheckKeaCommand(keactrl.NewCommand("config-get", []string{"dhcp4"}, nil)))
+	require.NoError(t, policy.CheckKeaCommand(keactrl.NewCommand("lease4-get", []string{"dhcp4"}, nil)))
+	require.Error(t, policy.CheckKeaCommand(keactrl.NewCommand("lease4-del", []string{"dhcp4"}, nil)))
+	require.Error(t, policy.CheckKeaCommand(keactrl.NewCommand("status-get", []string{"dhcp4"}, nil)))
+	// The wildcard doesn't allow the destructive commands.
+	require.Error(t, policy.CheckKeaCommand(keactrl.NewCommand("lease4-wipe", []string{"dhcp4"}, nil)))
+	// The rules of other daemons are not affected.
+	require.NoError(t, policy.CheckKeaCommand(keactrl.NewCommand("status-get", []string{"dhcp6"}, nil)))
+	// All daemons must accept the command.
+	require.Error(t, policy.CheckKeaCommand(keactrl.NewCommand("status-get", []string{"dhcp4", "dhcp6"}, nil)))
+}
+
+// Test that the destructive command is accepted when explicitly allowed.
+func TestCommandPolicyAllowDestructive(t *testing.T) {
+	policy := NewCommandPolicy()
+	err := policy.SetRules(AppTypeKea, "dhcp6", []string{"*", "lease6-wipe"}, nil)
+	require.NoError(t, err)
+	err = policy.SetRules(AppTypeBind9, "named", []string{"status", "flush"}, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, policy.CheckKeaCommand(keactrl.NewCommand("lease6-wipe", []string{"dhcp6"}, nil)))
+	require.NoError(t, policy.CheckKeaCommand(keactrl.NewCommand("config-get", []string{"dhcp6"}, nil)))
+	require.Error(t, policy.CheckKeaCommand(keactrl.NewCommand("shutdown", []string{"dhcp6"}, nil)))
+	require.NoError(t, policy.CheckRndcCommand([]string{"flush"}))
+	require.Error(t, policy.CheckRndcCommand([]string{"reload"}))
+}
+
+// Test that the wildcard daemon rules apply to the daemons without the
+// dedicated rules.
+func TestCommandPolicyAnyDaemon(t *testing.T) {
+	policy := NewCommandPolicy()
+	err := policy.SetRules(AppTypeKea, "*", nil, []string{"config-*"})
+	require.NoError(t, err)
+	err = policy.SetRules(AppTypeKea, "dhcp4", nil, nil)
+	require.NoError(t, err)
+
+	require.Error(t, policy.CheckKeaCommand(keactrl.NewCommand("config-get", []string{"dhcp6"}, nil)))
+	require.Error(t, policy.CheckKeaCommand(keactrl.NewCommand("config-get", nil, nil)))
+	require.NoError(t, policy.CheckKeaCommand(keactrl.NewCommand("config-get", []string{"dhcp4"}, nil)))
+}
+
+// Test that the invalid rules are rejected.
+func TestCommandPolicySetInvalidRules(t *testing.T) {
+	policy := NewCommandPolicy()
+	require.Error(t, policy.SetRules("foo", "dhcp4", nil, nil))
+	require.Error(t, policy.SetRules(AppTypeKea, "", nil, nil))
+	require.Error(t, policy.SetRules(AppTypeKea, "dhcp4", []string{""}, nil))
+	require.Error(t, policy.SetRules(AppTypeKea, "dhcp4", nil, []string{"foo", ""}))
+}
+
+// Test that the command policy is read from the JSON content.
+func TestCommandPolicyRead(t *testing.T) {
+	content := `{
+		"kea": {
+			"*": { "deny": [ "config-set" ] },
+			"dhcp4": { "allow": [ "lease4-wipe", "config-get" ] }
+		},
+		"bind9": {
+			"named": { "allow": [ "status" ] }
+		}
+	}`
+	policy := NewCommandPolicy()
+	err := policy.Read(strings.NewReader(content))
+	require.NoError(t, err)
+
+	require.Len(t, policy.rules[AppTypeKea], 2)
+	require.Len(t, policy.rules[AppTypeBind9], 1)
+	require.NoError(t, policy.CheckKeaCommand(keactrl.NewCommand("lease4-wipe", []string{"dhcp4"}, nil)))
+	require.Error(t, policy.CheckKeaCommand(keactrl.NewCommand("status-get", []string{"dhcp4"}, nil)))
+	require.NoError(t, policy.CheckRndcCommand([]string{"status"}))
+	require.Error(t, policy.CheckRndcCommand([]string{"reload"}))
+}
+
+// Test that reading the invalid command policy content fails.
+func TestCommandPolicyReadInvalid(t *testing.T) {
+	policy := NewCommandPolicy()
+	require.Error(t, policy.Read(strings.NewReader("{")))
+	require.Error(t, policy.Read(strings.NewReader(`{ "kea": { "": { } } }`)))
+	require.Error(t, policy.Read(strings.NewReader(`{ "bind9": { "named": { "deny": [ "" ] } } }`)))
+}
+
+// Test that the command policy is loaded from the file and the default
+// policy is used when the file is missing. All commands are rejected when
+// the file is invalid.
+func TestLoadCommandPolicy(t *testing.T) {
+	restore := RememberPaths()
+	defer restore()
+
+	tmpDir := t.TempDir()
+	CommandPolicyFile = path.Join(tmpDir, "agent-command-policy.json")
+
+	// Missing file.
+	policy := loadCommandPolicy()
+	require.NotNil(t, policy)
+	require.Empty(t, policy.rules[AppTypeKea])
+
+	// Valid file.
+	err := os.WriteFile(CommandPolicyFile, []byte(`{ "kea": { "dhcp4": { "deny": [ "config-get" ] } } }`), 0o600)
+	require.NoError(t, err)
+	policy = loadCommandPolicy()
+	require.Len(t, policy.rules[AppTypeKea], 1)
+
+	// Invalid file.
+	err = os.WriteFile(CommandPolicyFile, []byte(`{ "kea": { "dhcp4": { "deny": [ "" ] } } }`), 0o600)
+	require.NoError(t, err)
+	policy = loadCommandPolicy()
+	require.Empty(t, policy.rules[AppTypeKea])
+	require.Error(t, policy.CheckKeaCommand(keactrl.NewCommand("shutdown", nil, nil)))
+	require.ErrorContains(t, policy.CheckKeaCommand(keactrl.NewCommand("config-get", []string{"dhcp4"}, nil)), "cannot be loaded")
+	require.Error(t, policy.CheckRndcCommand([]string{"status"}))
+
+	// Malformed JSON.
+	err = os.WriteFile(CommandPolicyFile, []byte(`{ "kea": `), 0o600)
+	require.NoError(t, err)
+	policy = loadCommandPolicy()
+	require.Error(t, policy.CheckKeaCommand(keactrl.NewCommand("list-commands", nil, nil)))
+	require.Error(t, policy.CheckRndcCommand([]string{"status"}))
+}
+
+// Test that all commands are rejected when the command policy file exists
+// but cannot be read.
+func TestLoadCommandPolicyUnreadable(t *testing.T) {
+	restore := RememberPaths()
+	defer restore()
+
+	// A directory exists under the path but cannot be read as a file.
+	CommandPolicyFile = t.TempDir()
+
+	policy := loadCommandPolicy()
+	require.NotNil(t, policy)
+	err := policy.CheckKeaCommand(keactrl.NewCommand("config-get", []string{"dhcp4"}, nil))
+	var rejected *CommandRejectedError
+	require.ErrorAs(t, err, &rejected)
+	require.Error(t, policy.CheckRndcCommand([]string{"status"}))
+}
@@ -209,6 +209,9 @@ func detectKeaApp(match []string, cwd string, httpClient *HTTPClient) App {
 	if !strings.HasPrefix(keaConfPath, "/") {
 		keaConfPath = path.Join(cwd, keaConfPath)
 	}
+	// The config path identifies the Kea instance, so it must be in the
+	// canonical form.
+	keaConfPath = path.Clean(keaConfPath)
 
 	config, err := readKeaConfig(keaConfPath)
 	if err != nil {
@@ -238,6 +241,7 @@ func detectKeaApp(match []string, cwd string, httpClient *HTTPClient) App {
 		BaseApp: BaseApp{
 			Type:         AppTypeKea,
 			AccessPoints: accessPoints,
+			ConfigPath:   keaConfPath,
 		},
 		HTTPClient:        httpClient,
 		ConfiguredDaemons: config.GetControlSockets().GetConfiguredDaemonNames(),
@@ -3,6 +3,7 @@ package agent
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -32,10 +33,18 @@ const (
 
 // Base application information. This structure is embedded
 // in other app specific structures like KeaApp and Bind9App.
+// The configuration path distinguishes the instances of the same
+// app type running on the same machine (e.g., the Kea Control Agent
+// configuration file path). The static apps are defined in the agent
+// configuration instead of being detected; their log files allowed for
+// viewing are configured explicitly.
 type BaseApp struct {
 	Pid          int32
 	Type         string
 	AccessPoints []AccessPoint
+	ConfigPath   string
+	Static       bool
+	AllowedLogs  []string
 }
 
 // Specific App like KeaApp or Bind9App have to implement
@@ -58,7 +67,7 @@ const (
 // They are available through assessors.
 type AppMonitor interface {
 	GetApps() []App
-	GetApp(appType, apType, address string, port int64) App
+	GetApp(appType, apType, address string, port int64, configPath string) App
 	Start(agent *StorkAgent)
 	Shutdown()
 }
@@ -133,39 +142,34 @@ func (sm *appMonitor) run(storkAgent *StorkAgent) {
 	}
 }
 
+// Checks if two detected apps are the same instance with the same access
+// points. The apps are the same instance if they have the same type and
+// configuration path.
+func isSameApp(appNew, appOld *BaseApp) bool {
+	if appOld.Type != appNew.Type || appOld.ConfigPath != appNew.ConfigPath {
+		return false
+	}
+	if len(appNew.AccessPoints) != len(appOld.AccessPoints) {
+		return false
+	}
+	for idx, acPtNew := range appNew.AccessPoints {
+		if acPtNew != appOld.AccessPoints[idx] {
+			return false
+		}
+	}
+	return true
+}
+
 func printNewOrUpdatedApps(newApps []App, oldApps []App) {
 	// look for new or updated apps
 	var newUpdatedApps []App
 	for _, an := range newApps {
-		appNew := an.GetBaseApp()
 		found := false
 		for _, ao := range oldApps {
-			appOld := ao.GetBaseApp()
-			if appOld.Type != appNew.Type {
-				continue
-			}
-			if len(appNew.AccessPoints) != len(appOld.AccessPoints) {
-				continue
+			if isSameApp(an.GetBaseApp(), ao.GetBaseApp()) {
+				found = true
+				break
 			}
-			for idx, acPtNew := range appNew.AccessPoints {
-				acPtOld := appOld.AccessPoints[idx]
-				if acPtNew.Type != acPtOld.Type {
-					continue
-				}
-				if acPtNew.Address != acPtOld.Address {
-					continue
-				}
-				if acPtNew.Port != acPtOld.Port {
-					continue
-				}
-				if acPtNew.UseSecureProtocol != acPtOld.UseSecureProtocol {
-					continue
-				}
-				if acPtNew.Key != acPtOld.Key {
-					continue
-				}
-			}
-			found = true
 		}
 		if !found {
 			newUpdatedApps = append(newUpdatedApps, an)
@@ -185,6 +189,12 @@ func printNewOrUpdatedApps(newApps []App, oldApps []App) {
 				s := fmt.Sprintf("%s: %s (auth key: %s)", acPt.Type, url, authKeyFoundStr)
 				acPts = append(acPts, s)
 			}
+			if configPath := app.GetBaseApp().ConfigPath; configPath != "" {
+				acPts = append(acPts, fmt.Sprintf("config: %s", configPath))
+			}
+			if app.GetBaseApp().Static {
+				acPts = append(acPts, "static")
+			}
 			log.Printf("   %s: %s", app.GetBaseApp().Type, strings.Join(acPts, ", "))
 		}
 	} else if len(oldApps) == 0 {
@@ -254,6 +264,16 @@ func (sm *appMonitor) detectApps(storkAgent *StorkAgent) {
 		}
 	}
 
+	// Multiple instances of the same app may run on the machine. Make sure
+	// they are reported in the same order regardless of the order of the
+	// processes, and that each instance is reported only once.
+	apps = sortAndDeduplicateApps(apps)
+
+	// Append the apps defined in the agent configuration.
+	if storkAgent != nil {
+		apps = mergeDetectedAndStaticApps(apps, storkAgent.staticApps)
+	}
+
 	// check changes in apps and print them
 	printNewOrUpdatedApps(apps, sm.apps)
 
@@ -261,6 +281,39 @@ func (sm *appMonitor) detectApps(storkAgent *StorkAgent) {
 	sm.apps = apps
 }
 
+// Sorts the detected apps by type and configuration path and removes the
+// duplicates, i.e., the apps of the same type using the same configuration
+// file. It happens when the same app instance is represented by multiple
+// processes. The apps without the configuration path are ordered by the PID.
+func sortAndDeduplicateApps(apps []App) []App {
+	sort.SliceStable(apps, func(i, j int) bool {
+		appI := apps[i].GetBaseApp()
+		appJ := apps[j].GetBaseApp()
+		if appI.Type != appJ.Type {
+			return appI.Type < appJ.Type
+		}
+		if appI.ConfigPath != appJ.ConfigPath {
+			return appI.ConfigPath < appJ.ConfigPath
+		}
+		return appI.Pid < appJ.Pid
+	})
+
+	var uniqueApps []App
+	for _, app := range apps {
+		if len(uniqueApps) > 0 {
+			last := uniqueApps[len(uniqueApps)-1].GetBaseApp()
+			current := app.GetBaseApp()
+			if current.ConfigPath != "" && last.Type == current.Type && last.ConfigPath == current.ConfigPath {
+				log.Debugf("Skipping %s app (PID: %d) using the same config file as the app (PID: %d): %s",
+					current.Type, current.Pid, last.Pid, current.ConfigPath)
+				continue
+			}
+		}
+		uniqueApps = append(uniqueApps, app)
+	}
+	return uniqueApps
+}
+
 // Gathers the configured log files for detected apps and enables them
 // for viewing from the UI.
 func (sm *appMonitor) detectAllowedLogs(storkAgent *StorkAgent) {
@@ -270,6 +323,13 @@ func (sm *appMonitor) detectAllowedLogs(storkAgent *StorkAgent) {
 		return
 	}
 	for _, app := range sm.apps {
+		// The log files of the static apps are configured explicitly.
+		if app.GetBaseApp().Static {
+			for _, p := range app.GetBaseApp().AllowedLogs {
+				storkAgent.logTailer.allow(p)
+			}
+			continue
+		}
 		paths, err := app.DetectAllowedLogs()
 		if err != nil {
 			ap := app.GetBaseApp().AccessPoints[0]
@@ -296,13 +356,18 @@ func (sm *appMonitor) GetApps() []App {
 	return applications
 }
 
-// Get an app from a monitor that matches provided params.
-func (sm *appMonitor) GetApp(appType, apType, address string, port int64) App {
+// Get an app from a monitor that matches provided params. The configuration
+// path distinguishes the app instances sharing the same access point. If it
+// is empty, the first app with the matching access point is returned.
+func (sm *appMonitor) GetApp(appType, apType, address string, port int64, configPath string) App {
 	apps := sm.GetApps()
 	for _, app := range apps {
 		if app.GetBaseApp().Type != appType {
 			continue
 		}
+		if configPath != "" && app.GetBaseApp().ConfigPath != configPath {
+			continue
+		}
 		for _, ap := range app.GetBaseApp().AccessPoints {
 			if ap.Type == apType && ap.Address == address && ap.Port == port {
 				return app
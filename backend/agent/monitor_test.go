@@ -66,7 +66,7 @@ func TestGetApp(t *testing.T) {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		app := am.GetApp(AppTypeKea, AccessPointControl, "1.2.3.1", 1234)
+		app := am.GetApp(AppTypeKea, AccessPointControl, "1.2.3.1", 1234, "")
 		require.NotNil(t, app)
 		require.EqualValues(t, AppTypeKea, app.GetBaseApp().Type)
 	}()
@@ -78,7 +78,7 @@ func TestGetApp(t *testing.T) {
 	wg.Add(1) // expect 1 Done in the wait group
 	go func() {
 		defer wg.Done()
-		app := am.GetApp(AppTypeBind9, AccessPointControl, "2.3.4.4", 2345)
+		app := am.GetApp(AppTypeBind9, AccessPointControl, "2.3.4.4", 2345, "")
 		require.NotNil(t, app)
 		require.EqualValues(t, AppTypeBind9, app.GetBaseApp().Type)
 	}()
@@ -90,7 +90,7 @@ func TestGetApp(t *testing.T) {
 	wg.Add(1) // expect 1 Done in the wait group
 	go func() {
 		defer wg.Done()
-		app := am.GetApp(AppTypeKea, AccessPointControl, "0.0.0.0", 1)
+		app := am.GetApp(AppTypeKea, AccessPointControl, "0.0.0.0", 1, "")
 		require.Nil(t, app)
 	}()
 	ret = <-am.(*appMonitor).requests
@@ -98,6 +98,60 @@ func TestGetApp(t *testing.T) {
 	wg.Wait()
 }
 
+// Check that the app instances sharing the same access point are
+// distinguished by the configuration path.
+func TestGetAppSameAccessPoint(t *testing.T) {
+	am := NewAppMonitor()
+
+	apps := []App{
+		&Bind9App{
+			BaseApp: BaseApp{
+				Type:         AppTypeBind9,
+				AccessPoints: makeAccessPoint(AccessPointControl, "127.0.0.1", "abcd", 953, false),
+				ConfigPath:   "/etc/bind1/named.conf",
+			},
+		},
+		&Bind9App{
+			BaseApp: BaseApp{
+				Type:         AppTypeBind9,
+				AccessPoints: makeAccessPoint(AccessPointControl, "127.0.0.1", "abcd", 953, false),
+				ConfigPath:   "/etc/bind2/named.conf",
+			},
+		},
+	}
+
+	getApp := func(configPath string) App {
+		var app App
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app = am.GetApp(AppTypeBind9, AccessPointControl, "127.0.0.1", 953, configPath)
+		}()
+		ret := <-am.(*appMonitor).requests
+		ret <- apps
+		wg.Wait()
+		return app
+	}
+
+	app := getApp("/etc/bind2/named.conf")
+	require.NotNil(t, app)
+	require.Equal(t, "/etc/bind2/named.conf", app.GetBaseApp().ConfigPath)
+
+	app = getApp("/etc/bind1/named.conf")
+	require.NotNil(t, app)
+	require.Equal(t, "/etc/bind1/named.conf", app.GetBaseApp().ConfigPath)
+
+	// Unknown config path.
+	require.Nil(t, getApp("/etc/bind3/named.conf"))
+
+	// No config path specified, e.g., by an older server. The first
+	// matching app is returned.
+	app = getApp("")
+	require.NotNil(t, app)
+	require.Equal(t, "/etc/bind1/named.conf", app.GetBaseApp().ConfigPath)
+}
+
 // Test that the reading from non existing file causes an error.
 func TestReadKeaConfigNonExisting(t *testing.T) {
 	// Arrange
@@ -300,9 +354,10 @@ func makeKeaConfFileWithInclude() (string, func()) {
 }
 
 func TestDetectKeaApp(t *testing.T) {
-	checkApp := func(app App) {
+	checkApp := func(app App, configPath string) {
 		require.NotNil(t, app)
 		require.Equal(t, AppTypeKea, app.GetBaseApp().Type)
+		require.Equal(t, configPath, app.GetBaseApp().ConfigPath)
 		require.Len(t, app.GetBaseApp().AccessPoints, 1)
 		ctrlPoint := app.GetBaseApp().AccessPoints[0]
 		require.Equal(t, AccessPointControl, ctrlPoint.Type)
@@ -319,12 +374,12 @@ func TestDetectKeaApp(t *testing.T) {
 
 		// check kea app detection
 		app := detectKeaApp([]string{"", "", tmpFilePath}, "", httpClient)
-		checkApp(app)
+		checkApp(app, tmpFilePath)
 
 		// check kea app detection when kea conf file is relative to CWD of kea process
 		cwd, file := path.Split(tmpFilePath)
 		app = detectKeaApp([]string{"", "", file}, cwd, httpClient)
-		checkApp(app)
+		checkApp(app, tmpFilePath)
 	})
 
 	t.Run("config file with include statement", func(t *testing.T) {
@@ -334,12 +389,12 @@ func TestDetectKeaApp(t *testing.T) {
 
 		// check kea app detection
 		app := detectKeaApp([]string{"", "", tmpFilePath}, "", httpClient)
-		checkApp(app)
+		checkApp(app, tmpFilePath)
 
 		// check kea app detection when kea conf file is relative to CWD of kea process
 		cwd, file := path.Split(tmpFilePath)
 		app = detectKeaApp([]string{"", "", file}, cwd, httpClient)
-		checkApp(app)
+		checkApp(app, tmpFilePath)
 	})
 }
 
@@ -454,6 +509,110 @@ func TestPrintNewOrUpdatedApps(t *testing.T) {
 	printNewOrUpdatedApps(newApps, oldApps)
 }
 
+// Test that the apps are the same if they have the same type, configuration
+// path and access points.
+func TestIsSameApp(t *testing.T) {
+	app1 := &BaseApp{
+		Type:         AppTypeKea,
+		AccessPoints: makeAccessPoint(AccessPointControl, "localhost", "", 45634, false),
+		ConfigPath:   "/etc/kea1/kea-ctrl-agent.conf",
+	}
+	app2 := &BaseApp{
+		Type:         AppTypeKea,
+		AccessPoints: makeAccessPoint(AccessPointControl, "localhost", "", 45634, false),
+		ConfigPath:   "/etc/kea1/kea-ctrl-agent.conf",
+	}
+	require.True(t, isSameApp(app1, app2))
+
+	// Different config path.
+	app2.ConfigPath = "/etc/kea2/kea-ctrl-agent.conf"
+	require.False(t, isSameApp(app1, app2))
+
+	// Different port.
+	app2.ConfigPath = app1.ConfigPath
+	app2.AccessPoints[0].Port = 45635
+	require.False(t, isSameApp(app1, app2))
+
+	// Different number of access points.
+	app2.AccessPoints = nil
+	require.False(t, isSameApp(app1, app2))
+
+	// Different type.
+	app2.AccessPoints = app1.AccessPoints
+	app2.Type = AppTypeBind9
+	require.False(t, isSameApp(app1, app2))
+}
+
+// Test that no new apps are reported when the detected apps didn't change.
+func TestPrintNewOrUpdatedAppsNoChange(t *testing.T) {
+	// Arrange
+	output := logrus.StandardLogger().Out
+	defer func() {
+		logrus.SetOutput(output)
+	}()
+	var buffer bytes.Buffer
+	logrus.SetOutput(&buffer)
+
+	newApps := []App{
+		&KeaApp{
+			BaseApp: BaseApp{
+				Type:         AppTypeKea,
+				AccessPoints: makeAccessPoint(AccessPointControl, "localhost", "", 45634, false),
+				ConfigPath:   "/etc/kea1/kea-ctrl-agent.conf",
+			},
+		},
+	}
+	oldApps := []App{
+		&KeaApp{
+			BaseApp: BaseApp{
+				Type:         AppTypeKea,
+				AccessPoints: makeAccessPoint(AccessPointControl, "localhost", "", 45634, false),
+				ConfigPath:   "/etc/kea1/kea-ctrl-agent.conf",
+			},
+		},
+	}
+
+	// Act
+	printNewOrUpdatedApps(newApps, oldApps)
+
+	// Assert
+	require.NotContains(t, buffer.String(), "New or updated apps detected")
+
+	// Act
+	newApps[0].GetBaseApp().ConfigPath = "/etc/kea2/kea-ctrl-agent.conf"
+	printNewOrUpdatedApps(newApps, oldApps)
+
+	// Assert
+	require.Contains(t, buffer.String(), "New or updated apps detected")
+	require.Contains(t, buffer.String(), "config: /etc/kea2/kea-ctrl-agent.conf")
+}
+
+// Test that multiple instances of the same app are sorted by the
+// configuration path and the duplicates are removed.
+func TestSortAndDeduplicateApps(t *testing.T) {
+	// Arrange
+	apps := []App{
+		&KeaApp{BaseApp: BaseApp{Pid: 4, Type: AppTypeKea, ConfigPath: "/etc/kea2/kea-ctrl-agent.conf"}},
+		&Bind9App{BaseApp: BaseApp{Pid: 1, Type: AppTypeBind9, ConfigPath: "/etc/bind/named.conf"}},
+		&KeaApp{BaseApp: BaseApp{Pid: 3, Type: AppTypeKea, ConfigPath: "/etc/kea1/kea-ctrl-agent.conf"}},
+		&KeaApp{BaseApp: BaseApp{Pid: 2, Type: AppTypeKea, ConfigPath: "/etc/kea2/kea-ctrl-agent.conf"}},
+		&KeaApp{BaseApp: BaseApp{Pid: 6, Type: AppTypeKea}},
+		&KeaApp{BaseApp: BaseApp{Pid: 5, Type: AppTypeKea}},
+	}
+
+	// Act
+	apps = sortAndDeduplicateApps(apps)
+
+	// Assert
+	require.Len(t, apps, 5)
+	require.Equal(t, AppTypeBind9, apps[0].GetBaseApp().Type)
+	require.EqualValues(t, 5, apps[1].GetBaseApp().Pid)
+	require.EqualValues(t, 6, apps[2].GetBaseApp().Pid)
+	require.Equal(t, "/etc/kea1/kea-ctrl-agent.conf", apps[3].GetBaseApp().ConfigPath)
+	require.Equal(t, "/etc/kea2/kea-ctrl-agent.conf", apps[4].GetBaseApp().ConfigPath)
+	require.EqualValues(t, 2, apps[4].GetBaseApp().Pid)
+}
+
 // The monitor periodically searches for the Kea/Bind9 instances. Usually, at
 // least one application should be available. If no monitored app is found,
 // the Stork prints the warning message to indicate that something unexpected
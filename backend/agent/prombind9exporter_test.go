@@ -37,7 +37,7 @@ func (fam *PromFakeBind9AppMonitor) GetApps() []App {
 	return []App{ba}
 }
 
-func (fam *PromFakeBind9AppMonitor) GetApp(appType, apType, address string, port int64) App {
+func (fam *PromFakeBind9AppMonitor) GetApp(appType, apType, address string, port int64, configPath string) App {
 	return nil
 }
 
@@ -334,6 +334,8 @@ type PromKeaExporter struct {
 	PktStatsMap    map[string]statisticDescriptor
 	Adr4StatsMap   map[string]*prometheus.GaugeVec
 	Adr6StatsMap   map[string]*prometheus.GaugeVec
+	Pool4StatsMap  map[string]*prometheus.GaugeVec
+	Pool6StatsMap  map[string]*prometheus.GaugeVec
 	Global4StatMap map[string]prometheus.Gauge
 	Global6StatMap map[string]prometheus.Gauge
 
@@ -353,6 +355,8 @@ func NewPromKeaExporter(settings *cli.Context, appMonitor AppMonitor) *PromKeaEx
 		Registry:       prometheus.NewRegistry(),
 		Adr4StatsMap:   nil,
 		Adr6StatsMap:   nil,
+		Pool4StatsMap:  nil,
+		Pool6StatsMap:  nil,
 		Global4StatMap: nil,
 		Global6StatMap: nil,
 		ignoredStats: map[string]bool{
@@ -611,8 +615,113 @@ func NewPromKeaExporter(settings *cli.Context, appMonitor AppMonitor) *PromKeaEx
 			Help:      "Cumulative number of assigned PD prefixes since server startup",
 		}, []string{"subnet"})
 
+		// Pool statistics are returned by Kea 2.3.0 and later. The pool
+		// label holds the index of the pool in the subnet configuration.
+		// The map keys are the statistic names with the pool type prefix.
+		pool4StatsMap := make(map[string]*prometheus.GaugeVec)
+		pool4StatsMap["pool.total-addresses"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp4",
+			Name:      "pool_addresses_total",
+			Help:      "Size of address pool",
+		}, []string{"subnet", "pool"})
+		pool4StatsMap["pool.assigned-addresses"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp4",
+			Name:      "pool_addresses_assigned_total",
+			Help:      "Assigned addresses in the pool",
+		}, []string{"subnet", "pool"})
+		pool4StatsMap["pool.declined-addresses"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp4",
+			Name:      "pool_addresses_declined_total",
+			Help:      "Declined counts in the pool",
+		}, []string{"subnet", "pool"})
+		pool4StatsMap["pool.reclaimed-declined-addresses"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp4",
+			Name:      "pool_addresses_declined_reclaimed_total",
+			Help:      "Declined addresses that were reclaimed in the pool",
+		}, []string{"subnet", "pool"})
+		pool4StatsMap["pool.reclaimed-leases"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp4",
+			Name:      "pool_addresses_reclaimed_total",
+			Help:      "Expired addresses that were reclaimed in the pool",
+		}, []string{"subnet", "pool"})
+		pool4StatsMap["pool.cumulative-assigned-addresses"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp4",
+			Name:      "pool_cumulative_addresses_assigned_total",
+			Help:      "Cumulative number of assigned addresses in the pool since server startup",
+		}, []string{"subnet", "pool"})
+
+		pool6StatsMap := make(map[string]*prometheus.GaugeVec)
+		pool6StatsMap["pool.total-nas"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp6",
+			Name:      "pool_na_total",
+			Help:      "Size of non-temporary address pool",
+		}, []string{"subnet", "pool"})
+		pool6StatsMap["pool.assigned-nas"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp6",
+			Name:      "pool_na_assigned_total",
+			Help:      "Assigned non-temporary addresses (IA_NA) in the pool",
+		}, []string{"subnet", "pool"})
+		pool6StatsMap["pool.cumulative-assigned-nas"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp6",
+			Name:      "pool_cumulative_nas_assigned_total",
+			Help:      "Cumulative number of assigned NA addresses in the pool since server startup",
+		}, []string{"subnet", "pool"})
+		pool6StatsMap["pool.declined-addresses"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp6",
+			Name:      "pool_addresses_declined_total",
+			Help:      "Declined counts in the pool",
+		}, []string{"subnet", "pool"})
+		pool6StatsMap["pool.reclaimed-declined-addresses"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp6",
+			Name:      "pool_addresses_declined_reclaimed_total",
+			Help:      "Declined addresses that were reclaimed in the pool",
+		}, []string{"subnet", "pool"})
+		pool6StatsMap["pool.reclaimed-leases"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp6",
+			Name:      "pool_addresses_reclaimed_total",
+			Help:      "Expired addresses that were reclaimed in the pool",
+		}, []string{"subnet", "pool"})
+		pool6StatsMap["pd-pool.total-pds"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp6",
+			Name:      "pool_pd_total",
+			Help:      "Size of prefix delegation pool",
+		}, []string{"subnet", "pool"})
+		pool6StatsMap["pd-pool.assigned-pds"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp6",
+			Name:      "pool_pd_assigned_total",
+			Help:      "Assigned prefix delegations (IA_PD) in the pool",
+		}, []string{"subnet", "pool"})
+		pool6StatsMap["pd-pool.cumulative-assigned-pds"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp6",
+			Name:      "pool_cumulative_pds_assigned_total",
+			Help:      "Cumulative number of assigned PD prefixes in the pool since server startup",
+		}, []string{"subnet", "pool"})
+		pool6StatsMap["pd-pool.reclaimed-leases"] = factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: AppTypeKea,
+			Subsystem: "dhcp6",
+			Name:      "pool_pd_reclaimed_total",
+			Help:      "Expired prefixes that were reclaimed in the pool",
+		}, []string{"subnet", "pool"})
+
 		pke.Adr4StatsMap = adr4StatsMap
 		pke.Adr6StatsMap = adr6StatsMap
+		pke.Pool4StatsMap = pool4StatsMap
+		pke.Pool6StatsMap = pool6StatsMap
 	}
 
 	// prepare http handler
@@ -690,6 +799,12 @@ func (pke *PromKeaExporter) Shutdown() {
 	for _, stat := range pke.Adr6StatsMap {
 		pke.Registry.Unregister(stat)
 	}
+	for _, stat := range pke.Pool4StatsMap {
+		pke.Registry.Unregister(stat)
+	}
+	for _, stat := range pke.Pool6StatsMap {
+		pke.Registry.Unregister(stat)
+	}
 	for _, stat := range pke.Global4StatMap {
 		pke.Registry.Unregister(stat)
 	}
@@ -718,8 +833,13 @@ func (pke *PromKeaExporter) statsCollectorLoop() {
 	}
 }
 
+// Pattern of the pool statistic names, e.g. subnet[1].pool[0].assigned-addresses
+// or subnet[1].pd-pool[0].assigned-pds. It captures the subnet ID, pool type,
+// pool index and statistic name.
+var poolStatisticPattern = regexp.MustCompile(`^subnet\[(\d+)\]\.(pool|pd-pool)\[(\d+)\]\.(.+)$`)
+
 // setDaemonStats stores the stat values from a daemon in the proper prometheus object.
-func (pke *PromKeaExporter) setDaemonStats(dhcpStatMap *map[string]*prometheus.GaugeVec, globalStatMap map[string]prometheus.Gauge, response map[string]GetAllStatisticResponseItemValue, ignoredStats map[string]bool, nameLookup subnetNameLookup) {
+func (pke *PromKeaExporter) setDaemonStats(dhcpStatMap, poolStatMap *map[string]*prometheus.GaugeVec, globalStatMap map[string]prometheus.Gauge, response map[string]GetAllStatisticResponseItemValue, ignoredStats map[string]bool, nameLookup subnetNameLookup) {
 	for statName, statEntry := range response {
 		// skip ignored stats
 		if ignoredStats[statName] {
@@ -737,6 +857,27 @@ func (pke *PromKeaExporter) setDaemonStats(dhcpStatMap *map[string]*prometheus.G
 				log.Warningf("Encountered unsupported stat: %s", statName)
 				ignoredStats[statName] = true
 			}
+		case poolStatisticPattern.MatchString(statName):
+			// The pool stats are collected together with the per-subnet stats.
+			if *poolStatMap == nil {
+				continue
+			}
+			matches := poolStatisticPattern.FindStringSubmatch(statName)
+			subnetIDRaw := matches[1]
+			metricName := matches[2] + "." + matches[4]
+
+			subnetID, err := strconv.Atoi(subnetIDRaw)
+			subnetName := subnetIDRaw
+			if err == nil {
+				subnetName = nameLookup.getNameOrDefault(subnetID)
+			}
+
+			if stat, ok := (*poolStatMap)[metricName]; ok {
+				stat.With(prometheus.Labels{"subnet": subnetName, "pool": matches[3]}).Set(statEntry.Value)
+			} else {
+				log.Warningf("Encountered unsupported stat: %s", statName)
+				ignoredStats[statName] = true
+			}
 		case strings.HasPrefix(statName, "subnet["):
 			// Check if collecting the per-subnet metrics is enabled.
 			// Processing subnet metrics for the Kea instance with
@@ -860,11 +1001,11 @@ func (pke *PromKeaExporter) collectStats() error {
 		// required commands.
 		if response.Dhcp4 != nil {
 			subnetNameLookup.setFamily(4)
-			pke.setDaemonStats(&pke.Adr4StatsMap, pke.Global4StatMap, response.Dhcp4, pke.ignoredStats, subnetNameLookup)
+			pke.setDaemonStats(&pke.Adr4StatsMap, &pke.Pool4StatsMap, pke.Global4StatMap, response.Dhcp4, pke.ignoredStats, subnetNameLookup)
 		}
 		if response.Dhcp6 != nil {
 			subnetNameLookup.setFamily(6)
-			pke.setDaemonStats(&pke.Adr6StatsMap, pke.Global6StatMap, response.Dhcp6, pke.ignoredStats, subnetNameLookup)
+			pke.setDaemonStats(&pke.Adr6StatsMap, &pke.Pool6StatsMap, pke.Global6StatMap, response.Dhcp6, pke.ignoredStats, subnetNameLookup)
 		}
 	}
 	return lastErr
@@ -45,6 +45,8 @@ func TestNewPromKeaExporterBasic(t *testing.T) {
 	require.Len(t, pke.PktStatsMap, 31)
 	require.Len(t, pke.Adr4StatsMap, 6)
 	require.Len(t, pke.Adr6StatsMap, 9)
+	require.Len(t, pke.Pool4StatsMap, 6)
+	require.Len(t, pke.Pool6StatsMap, 10)
 }
 
 // Check starting PromKeaExporter and collecting stats.
@@ -333,6 +335,62 @@ func (s *FakeKeaCASender) sendCommandToKeaCA(ctrl *AccessPoint, request string)
 	return s.payload, s.err
 }
 
+// Test that the pool statistics are exported with the subnet and pool labels.
+func TestSetDaemonStatsPoolStats(t *testing.T) {
+	// Arrange
+	fam := newFakeMonitorWithDefaults()
+	settings := cli.NewContext(nil, flag.NewFlagSet("", 0), nil)
+	pke := NewPromKeaExporter(settings, fam)
+	defer pke.Shutdown()
+	lookup := newLazySubnetNameLookup(newFakeKeaCASender(), &AccessPoint{Address: "foo"})
+
+	response := map[string]GetAllStatisticResponseItemValue{
+		"subnet[1].assigned-addresses":                 {Value: 11},
+		"subnet[1].pool[0].assigned-addresses":         {Value: 10},
+		"subnet[1].pool[1].assigned-addresses":         {Value: 1},
+		"subnet[1].pool[1].total-addresses":            {Value: 100},
+		"subnet[7].pool[0].cumulative-assigned-leases": {Value: 5},
+	}
+
+	// Act
+	pke.setDaemonStats(&pke.Adr4StatsMap, &pke.Pool4StatsMap, pke.Global4StatMap, response, pke.ignoredStats, lookup)
+
+	// Assert
+	metric, _ := pke.Adr4StatsMap["assigned-addresses"].GetMetricWith(prometheus.Labels{"subnet": "foo"})
+	require.Equal(t, 11.0, testutil.ToFloat64(metric))
+	metric, _ = pke.Pool4StatsMap["pool.assigned-addresses"].GetMetricWith(prometheus.Labels{"subnet": "foo", "pool": "0"})
+	require.Equal(t, 10.0, testutil.ToFloat64(metric))
+	metric, _ = pke.Pool4StatsMap["pool.assigned-addresses"].GetMetricWith(prometheus.Labels{"subnet": "foo", "pool": "1"})
+	require.Equal(t, 1.0, testutil.ToFloat64(metric))
+	metric, _ = pke.Pool4StatsMap["pool.total-addresses"].GetMetricWith(prometheus.Labels{"subnet": "foo", "pool": "1"})
+	require.Equal(t, 100.0, testutil.ToFloat64(metric))
+	require.True(t, pke.ignoredStats["subnet[7].pool[0].cumulative-assigned-leases"])
+}
+
+// Test that the prefix delegation pool statistics are exported.
+func TestSetDaemonStatsPdPoolStats(t *testing.T) {
+	// Arrange
+	fam := newFakeMonitorWithDefaults()
+	settings := cli.NewContext(nil, flag.NewFlagSet("", 0), nil)
+	pke := NewPromKeaExporter(settings, fam)
+	defer pke.Shutdown()
+	lookup := newLazySubnetNameLookup(newFakeKeaCASender(), &AccessPoint{Address: "foo"})
+
+	response := map[string]GetAllStatisticResponseItemValue{
+		"subnet[1].pool[0].assigned-nas":    {Value: 3},
+		"subnet[1].pd-pool[0].assigned-pds": {Value: 4},
+	}
+
+	// Act
+	pke.setDaemonStats(&pke.Adr6StatsMap, &pke.Pool6StatsMap, pke.Global6StatMap, response, pke.ignoredStats, lookup)
+
+	// Assert
+	metric, _ := pke.Pool6StatsMap["pool.assigned-nas"].GetMetricWith(prometheus.Labels{"subnet": "foo", "pool": "0"})
+	require.Equal(t, 3.0, testutil.ToFloat64(metric))
+	metric, _ = pke.Pool6StatsMap["pd-pool.assigned-pds"].GetMetricWith(prometheus.Labels{"subnet": "foo", "pool": "0"})
+	require.Equal(t, 4.0, testutil.ToFloat64(metric))
+}
+
 // Test that the lazy subnet name lookup is constructed properly.
 func TestNewLazySubnetNameLookup(t *testing.T) {
 	// Arrange
@@ -470,6 +528,7 @@ func TestDisablePerSubnetStatsCollecting(t *testing.T) {
 
 	// Assert
 	require.Nil(t, pke.Adr4StatsMap)
+	require.Nil(t, pke.Pool4StatsMap)
 
 	// check if pkt4-nak-received is 19
 	metric, _ := pke.PktStatsMap["pkt4-nak-received"].Stat.GetMetricWith(prometheus.Labels{"operation": "nak"})
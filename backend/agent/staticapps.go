@@ -0,0 +1,253 @@
+package agent
+
+// Apps defined statically in the agent configuration. They are monitored
+// in addition to the apps detected from the running processes. It is
+// useful when the agent cannot see the processes of the monitored daemons,
+// e.g., when they run in other containers or on remote hosts.
+//
+// The definitions are read from a dedicated JSON file, for example:
+//
+//	{
+//	    "kea": [
+//	        {
+//	            "address": "10.0.0.1",
+//	            "port": 8000,
+//	            "use-secure-protocol": true,
+//	            "user": "stork",
+//	            "password": "secret",
+//	            "daemons": [ "dhcp4", "dhcp6" ],
+//	            "allowed-logs": [ "/var/log/kea/kea-dhcp4.log" ]
+//	        }
+//	    ],
+//	    "bind9": [
+//	        {
+//	            "address": "10.0.0.2",
+//	            "port": 953,
+//	            "rndc-key-file": "/etc/stork/rndc.key",
+//	            "statistics-address": "10.0.0.2",
+//	            "statistics-port": 8053,
+//	            "allowed-logs": [ "/var/log/named/named.log" ]
+//	        }
+//	    ]
+//	}
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	storkutil "isc.org/stork/util"
+)
+
+// StaticAppsFile path to a file holding the apps defined statically in
+// the agent configuration. It is being modified by tests so needs to be
+// writable.
+var StaticAppsFile = "/etc/stork/agent-apps.json" //nolint:gochecknoglobals
+
+// Structure of the static apps JSON file.
+type StaticAppsContent struct {
+	Kea   []StaticKeaAppEntry   `json:"kea"`
+	Bind9 []StaticBind9AppEntry `json:"bind9"`
+}
+
+// Single Kea Control Agent entry of the static apps JSON file.
+type StaticKeaAppEntry struct {
+	Address           string   `json:"address"`
+	Port              int64    `json:"port"`
+	UseSecureProtocol bool     `json:"use-secure-protocol"`
+	User              *string  `json:"user"`
+	Password          *string  `json:"password"`
+	Daemons           []string `json:"daemons"`
+	AllowedLogs       []string `json:"allowed-logs"`
+}
+
+// Single BIND 9 control channel entry of the static apps JSON file.
+type StaticBind9AppEntry struct {
+	Address           string   `json:"address"`
+	Port              int64    `json:"port"`
+	RndcKeyFile       string   `json:"rndc-key-file"`
+	StatisticsAddress string   `json:"statistics-address"`
+	StatisticsPort    int64    `json:"statistics-port"`
+	AllowedLogs       []string `json:"allowed-logs"`
+}
+
+// Read the static apps content from reader and validate it.
+func readStaticApps(reader io.Reader) (*StaticAppsContent, error) {
+	rawContent, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot read the static apps")
+	}
+	var content StaticAppsContent
+	err = json.Unmarshal(rawContent, &content)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot parse the static apps")
+	}
+	for i, entry := range content.Kea {
+		if len(entry.Address) == 0 || entry.Port <= 0 {
+			return nil, errors.Errorf("missing address or port of the Kea app #%d", i+1)
+		}
+		if (entry.User == nil) != (entry.Password == nil) {
+			return nil, errors.Errorf("both user and password must be specified for the Kea app #%d", i+1)
+		}
+	}
+	for i, entry := range content.Bind9 {
+		if len(entry.Address) == 0 || entry.Port <= 0 {
+			return nil, errors.Errorf("missing address or port of the BIND 9 app #%d", i+1)
+		}
+		if (len(entry.StatisticsAddress) == 0) != (entry.StatisticsPort <= 0) {
+			return nil, errors.Errorf("both statistics address and port must be specified for the BIND 9 app #%d", i+1)
+		}
+	}
+	return &content, nil
+}
+
+// Creates the Kea app from the static definition. The Basic Auth
+// credentials, if specified, are added to the credentials store of the
+// HTTP client.
+func newStaticKeaApp(entry *StaticKeaAppEntry, httpClient *HTTPClient) (App, error) {
+	if entry.User != nil && entry.Password != nil {
+		err := httpClient.credentials.AddOrUpdateBasicAuth(entry.Address, entry.Port,
+			NewBasicAuthCredentials(*entry.User, *entry.Password))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "cannot add the credentials of the Kea app %s:%d", entry.Address, entry.Port)
+		}
+	}
+	return &KeaApp{
+		BaseApp: BaseApp{
+			Type: AppTypeKea,
+			AccessPoints: []AccessPoint{
+				{
+					Type:              AccessPointControl,
+					Address:           entry.Address,
+					Port:              entry.Port,
+					UseSecureProtocol: entry.UseSecureProtocol,
+				},
+			},
+			Static:      true,
+			AllowedLogs: entry.AllowedLogs,
+		},
+		HTTPClient:        httpClient,
+		ConfiguredDaemons: entry.Daemons,
+	}, nil
+}
+
+// Creates the BIND 9 app from the static definition. The rndc executable
+// is looked up in the system.
+func newStaticBind9App(entry *StaticBind9AppEntry, executor storkutil.CommandExecutor) (App, error) {
+	rndcPath, err := determineBinPath("", rndcExec, executor)
+	if err != nil {
+		return nil, err
+	}
+	rndcClient := NewRndcClient(func(command []string) ([]byte, error) {
+		return executor.Output(command[0], command[1:]...)
+	})
+	rndcClient.BaseCommand = []string{rndcPath, "-s", entry.Address, "-p", fmt.Sprintf("%d", entry.Port)}
+	if len(entry.RndcKeyFile) > 0 {
+		rndcClient.BaseCommand = append(rndcClient.BaseCommand, "-k", entry.RndcKeyFile)
+	}
+
+	accessPoints := []AccessPoint{
+		{
+			Type:    AccessPointControl,
+			Address: entry.Address,
+			Port:    entry.Port,
+		},
+	}
+	if entry.StatisticsPort > 0 {
+		accessPoints = append(accessPoints, AccessPoint{
+			Type:    AccessPointStatistics,
+			Address: entry.StatisticsAddress,
+			Port:    entry.StatisticsPort,
+		})
+	}
+
+	return &Bind9App{
+		BaseApp: BaseApp{
+			Type:         AppTypeBind9,
+			AccessPoints: accessPoints,
+			Static:       true,
+			AllowedLogs:  entry.AllowedLogs,
+		},
+		RndcClient: rndcClient,
+	}, nil
+}
+
+// Creates the apps from the static definitions. The invalid definitions
+// are skipped.
+func newStaticApps(content *StaticAppsContent, httpClient *HTTPClient, executor storkutil.CommandExecutor) []App {
+	var apps []App
+	for i := range content.Kea {
+		app, err := newStaticKeaApp(&content.Kea[i], httpClient)
+		if err != nil {
+			log.WithError(err).Warn("Skipping the statically defined Kea app")
+			continue
+		}
+		apps = append(apps, app)
+	}
+	for i := range content.Bind9 {
+		app, err := newStaticBind9App(&content.Bind9[i], executor)
+		if err != nil {
+			log.WithError(err).Warn("Skipping the statically defined BIND 9 app")
+			continue
+		}
+		apps = append(apps, app)
+	}
+	return apps
+}
+
+// Creates the apps defined in the static apps file. If the file is
+// missing or invalid, no static apps are returned.
+func loadStaticApps(httpClient *HTTPClient) []App {
+	if _, err := os.Stat(StaticAppsFile); err != nil {
+		log.Infof("The static apps file (%s) is missing - only the detected apps are monitored", StaticAppsFile)
+		return nil
+	}
+	file, err := os.Open(StaticAppsFile)
+	if err != nil {
+		log.Warnf("Cannot open the static apps file (%s) - only the detected apps are monitored, %+v", StaticAppsFile, err)
+		return nil
+	}
+	defer file.Close()
+	content, err := readStaticApps(file)
+	if err != nil {
+		log.Warnf("Cannot read the static apps from file (%s) - only the detected apps are monitored, %+v", StaticAppsFile, err)
+		return nil
+	}
+	apps := newStaticApps(content, httpClient, storkutil.NewSystemCommandExecutor())
+	log.Infof("Configured to monitor %d static app(s) from file (%s)", len(apps), StaticAppsFile)
+	return apps
+}
+
+// Merges the detected apps with the static apps. The static app is skipped
+// if any detected app of the same type has the same control access point.
+func mergeDetectedAndStaticApps(detectedApps, staticApps []App) []App {
+	apps := detectedApps
+	for _, staticApp := range staticApps {
+		staticAccessPoint, err := getAccessPoint(staticApp, AccessPointControl)
+		if err != nil {
+			continue
+		}
+		duplicate := false
+		for _, detectedApp := range detectedApps {
+			if detectedApp.GetBaseApp().Type != staticApp.GetBaseApp().Type {
+				continue
+			}
+			detectedAccessPoint, err := getAccessPoint(detectedApp, AccessPointControl)
+			if err == nil && detectedAccessPoint.Address == staticAccessPoint.Address &&
+				detectedAccessPoint.Port == staticAccessPoint.Port {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			log.Debugf("Skipping the static %s app %s:%d because it has been detected",
+				staticApp.GetBaseApp().Type, staticAccessPoint.Address, staticAccessPoint.Port)
+			continue
+		}
+		apps = append(apps, staticApp)
+	}
+	return apps
+}
@@ -0,0 +1,217 @@
+package agent
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the static apps are read from the JSON content.
+func TestReadStaticApps(t *testing.T) {
+	content := `{
+		"kea": [
+			{
+				"address": "10.0.0.1",
+				"port": 8000,
+				"use-secure-protocol": true,
+				"user": "foo",
+				"password": "bar",
+				"daemons": [ "dhcp4" ],
+				"allowed-logs": [ "/var/log/kea.log" ]
+			}
+		],
+		"bind9": [
+			{
+				"address": "10.0.0.2",
+				"port": 953,
+				"rndc-key-file": "/etc/stork/rndc.key",
+				"statistics-address": "10.0.0.2",
+				"statistics-port": 8053
+			}
+		]
+	}`
+	apps, err := readStaticApps(strings.NewReader(content))
+	require.NoError(t, err)
+	require.NotNil(t, apps)
+
+	require.Len(t, apps.Kea, 1)
+	require.Equal(t, "10.0.0.1", apps.Kea[0].Address)
+	require.EqualValues(t, 8000, apps.Kea[0].Port)
+	require.True(t, apps.Kea[0].UseSecureProtocol)
+	require.Equal(t, "foo", *apps.Kea[0].User)
+	require.Equal(t, "bar", *apps.Kea[0].Password)
+	require.Equal(t, []string{"dhcp4"}, apps.Kea[0].Daemons)
+	require.Equal(t, []string{"/var/log/kea.log"}, apps.Kea[0].AllowedLogs)
+
+	require.Len(t, apps.Bind9, 1)
+	require.Equal(t, "10.0.0.2", apps.Bind9[0].Address)
+	require.EqualValues(t, 953, apps.Bind9[0].Port)
+	require.Equal(t, "/etc/stork/rndc.key", apps.Bind9[0].RndcKeyFile)
+	require.Equal(t, "10.0.0.2", apps.Bind9[0].StatisticsAddress)
+	require.EqualValues(t, 8053, apps.Bind9[0].StatisticsPort)
+}
+
+// Test that reading the invalid static apps fails.
+func TestReadStaticAppsInvalid(t *testing.T) {
+	contents := []string{
+		"{",
+		`{ "kea": [ { "port": 8000 } ] }`,
+		`{ "kea": [ { "address": "10.0.0.1" } ] }`,
+		`{ "kea": [ { "address": "10.0.0.1", "port": 8000, "user": "foo" } ] }`,
+		`{ "bind9": [ { "address": "10.0.0.1", "port": 953, "statistics-port": 8053 } ] }`,
+	}
+	for _, content := range contents {
+		_, err := readStaticApps(strings.NewReader(content))
+		require.Error(t, err, content)
+	}
+}
+
+// Test that the apps are created from the static definitions.
+func TestNewStaticApps(t *testing.T) {
+	httpClient := NewHTTPClient(false)
+	user := "foo"
+	password := "bar"
+	content := &StaticAppsContent{
+		Kea: []StaticKeaAppEntry{
+			{
+				Address:     "10.0.0.1",
+				Port:        8000,
+				User:        &user,
+				Password:    &password,
+				Daemons:     []string{"dhcp4", "dhcp6"},
+				AllowedLogs: []string{"/var/log/kea.log"},
+			},
+		},
+		Bind9: []StaticBind9AppEntry{
+			{
+				Address:           "10.0.0.2",
+				Port:              953,
+				RndcKeyFile:       "/etc/stork/rndc.key",
+				StatisticsAddress: "10.0.0.3",
+				StatisticsPort:    8053,
+			},
+		},
+	}
+	executor := &catCommandExecutor{file: "/usr/sbin"}
+
+	apps := newStaticApps(content, httpClient, executor)
+	require.Len(t, apps, 2)
+
+	keaApp, ok := apps[0].(*KeaApp)
+	require.True(t, ok)
+	require.Equal(t, AppTypeKea, keaApp.Type)
+	require.True(t, keaApp.Static)
+	require.Equal(t, []string{"/var/log/kea.log"}, keaApp.AllowedLogs)
+	require.Equal(t, []string{"dhcp4", "dhcp6"}, keaApp.GetConfiguredDaemons())
+	require.Len(t, keaApp.AccessPoints, 1)
+	require.Equal(t, "10.0.0.1", keaApp.AccessPoints[0].Address)
+	require.EqualValues(t, 8000, keaApp.AccessPoints[0].Port)
+	credentials, ok := httpClient.credentials.GetBasicAuth("10.0.0.1", 8000)
+	require.True(t, ok)
+	require.Equal(t, "foo", credentials.User)
+	require.Equal(t, "bar", credentials.Password)
+
+	bind9App, ok := apps[1].(*Bind9App)
+	require.True(t, ok)
+	require.Equal(t, AppTypeBind9, bind9App.Type)
+	require.True(t, bind9App.Static)
+	require.Len(t, bind9App.AccessPoints, 2)
+	require.Equal(t, AccessPointControl, bind9App.AccessPoints[0].Type)
+	require.Equal(t, AccessPointStatistics, bind9App.AccessPoints[1].Type)
+	require.Equal(t, "10.0.0.3", bind9App.AccessPoints[1].Address)
+	require.Equal(t,
+		[]string{"/usr/sbin/rndc", "-s", "10.0.0.2", "-p", "953", "-k", "/etc/stork/rndc.key"},
+		bind9App.RndcClient.BaseCommand)
+}
+
+// Test that the static apps are merged with the detected apps and the
+// duplicates are skipped.
+func TestMergeDetectedAndStaticApps(t *testing.T) {
+	newApp := func(appType, address string, port int64, static bool) App {
+		return &KeaApp{
+			BaseApp: BaseApp{
+				Type: appType,
+				AccessPoints: []AccessPoint{
+					{
+						Type:    AccessPointControl,
+						Address: address,
+						Port:    port,
+					},
+				},
+				Static: static,
+			},
+		}
+	}
+	detectedApps := []App{
+		newApp(AppTypeKea, "127.0.0.1", 8000, false),
+	}
+	staticApps := []App{
+		newApp(AppTypeKea, "127.0.0.1", 8000, true),
+		newApp(AppTypeKea, "127.0.0.1", 8001, true),
+		newApp(AppTypeBind9, "127.0.0.1", 8000, true),
+	}
+
+	apps := mergeDetectedAndStaticApps(detectedApps, staticApps)
+	require.Len(t, apps, 3)
+	require.False(t, apps[0].GetBaseApp().Static)
+	require.EqualValues(t, 8001, apps[1].GetBaseApp().AccessPoints[0].Port)
+	require.Equal(t, AppTypeBind9, apps[2].GetBaseApp().Type)
+
+	require.Len(t, mergeDetectedAndStaticApps(nil, staticApps), 3)
+	require.Len(t, mergeDetectedAndStaticApps(detectedApps, nil), 1)
+}
+
+// Test that the static apps are loaded from the file and no apps are
+// returned when the file is missing or invalid.
+func TestLoadStaticApps(t *testing.T) {
+	restore := RememberPaths()
+	defer restore()
+
+	tmpDir := t.TempDir()
+	StaticAppsFile = path.Join(tmpDir, "agent-apps.json")
+	httpClient := NewHTTPClient(false)
+
+	// Missing file.
+	require.Empty(t, loadStaticApps(httpClient))
+
+	// Valid file.
+	err := os.WriteFile(StaticAppsFile, []byte(`{ "kea": [ { "address": "10.0.0.1", "port": 8000 } ] }`), 0o600)
+	require.NoError(t, err)
+	apps := loadStaticApps(httpClient)
+	require.Len(t, apps, 1)
+	require.True(t, apps[0].GetBaseApp().Static)
+
+	// Invalid file.
+	err = os.WriteFile(StaticAppsFile, []byte(`{ "kea": [ { "address": "10.0.0.1" } ] }`), 0o600)
+	require.NoError(t, err)
+	require.Empty(t, loadStaticApps(httpClient))
+}
+
+// Test that the log files of the static apps are allowed for viewing
+// without contacting the apps.
+func TestDetectAllowedLogsStaticApp(t *testing.T) {
+	sa, _ := setupAgentTest()
+	am := &appMonitor{}
+	am.apps = append(am.apps, &KeaApp{
+		BaseApp: BaseApp{
+			Type: AppTypeKea,
+			AccessPoints: []AccessPoint{
+				{
+					Type:    AccessPointControl,
+					Address: "localhost",
+					Port:    45678,
+				},
+			},
+			Static:      true,
+			AllowedLogs: []string{"/tmp/kea.log"},
+		},
+		HTTPClient: sa.HTTPClient,
+	})
+
+	am.detectAllowedLogs(sa)
+
+	require.True(t, sa.logTailer.allowed("/tmp/kea.log"))
+}
@@ -8,13 +8,15 @@ import (
 )
 
 // Helper function to store and defer restore
-// original paths of: certificates, secrets and credentials.
+// original paths of: certificates, secrets, credentials, command policy and static apps.
 func RememberPaths() func() {
 	originalKeyPEMFile := KeyPEMFile
 	originalCertPEMFile := CertPEMFile
 	originalRootCAFile := RootCAFile
 	originalAgentTokenFile := AgentTokenFile
 	originalCredentialsFile := CredentialsFile
+	originalCommandPolicyFile := CommandPolicyFile
+	originalStaticAppsFile := StaticAppsFile
 
 	return func() {
 		KeyPEMFile = originalKeyPEMFile
@@ -22,6 +24,8 @@ func RememberPaths() func() {
 		RootCAFile = originalRootCAFile
 		AgentTokenFile = originalAgentTokenFile
 		CredentialsFile = originalCredentialsFile
+		CommandPolicyFile = originalCommandPolicyFile
+		StaticAppsFile = originalStaticAppsFile
 	}
 }
 
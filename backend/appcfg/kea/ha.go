@@ -1,5 +1,10 @@
 package keaconfig
 
+import (
+	"sort"
+	"strings"
+)
+
 // A structure reflecting an array of high availability configurations
 // for a Kea server. It is a top level HA library configuration.
 type HALibraryParams struct {
@@ -38,10 +43,9 @@ type Peer struct {
 	AutoFailover *bool   `json:"auto-failover"`
 }
 
-// Convenience function returning the first HA configuration. Note that Kea in
-// fact supports only one configuration these days. It may be extended in the
-// future but for now it is convenient to directly access the first HA
-// configuration.
+// Convenience function returning the first HA configuration. Kea supports
+// multiple HA relationships in the hub-and-spoke configuration. In this case,
+// the GetRelationships function should be used to get all of them.
 func (params HALibraryParams) GetFirst() *HA {
 	if len(params.HA) > 0 {
 		return &params.HA[0]
@@ -49,6 +53,56 @@ func (params HALibraryParams) GetFirst() *HA {
 	return &HA{}
 }
 
+// Returns all HA relationships configured for the server. Kea 2.4 and
+// earlier support only one relationship. Later versions support multiple
+// relationships in the hub-and-spoke configuration.
+func (params HALibraryParams) GetRelationships() (relationships []*HA) {
+	for i := range params.HA {
+		relationships = append(relationships, &params.HA[i])
+	}
+	return relationships
+}
+
+// Returns the HA relationship in which the server has the specified name
+// or nil if there is no such relationship. The server names are unique
+// across the relationships.
+func (params HALibraryParams) GetRelationshipByServerName(serverName string) *HA {
+	for i := range params.HA {
+		if params.HA[i].ThisServerName != nil && *params.HA[i].ThisServerName == serverName {
+			return &params.HA[i]
+		}
+	}
+	return nil
+}
+
+// Returns the configuration of this server among the peers or nil if
+// this server is not found.
+func (c HA) GetThisServer() *Peer {
+	if c.ThisServerName == nil {
+		return nil
+	}
+	for i := range c.Peers {
+		if c.Peers[i].Name != nil && *c.Peers[i].Name == *c.ThisServerName {
+			return &c.Peers[i]
+		}
+	}
+	return nil
+}
+
+// Returns a name identifying the relationship. It consists of the sorted
+// and comma-separated names of the peers. The name is the same in the
+// configurations of all servers in the relationship.
+func (c HA) GetRelationshipName() string {
+	var names []string
+	for _, p := range c.Peers {
+		if p.Name != nil {
+			names = append(names, *p.Name)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
 // Checks if the mandatory Kea HA configuration parameters are set. It doesn't
 // check parameters consistency, though.
 func (c HA) IsValid() bool {
@@ -46,3 +46,72 @@ func TestHAConfigParametersValid(t *testing.T) {
 	cfg.Peers = append(cfg.Peers, p)
 	require.False(t, cfg.IsValid())
 }
+
+// Returns the HA relationship configuration with the specified peers.
+func getTestHARelationship(thisServerName string, peerNames ...string) HA {
+	mode := "hot-standby"
+	relationship := HA{
+		ThisServerName: &thisServerName,
+		Mode:           &mode,
+	}
+	for i := range peerNames {
+		url := "http://" + peerNames[i] + ":8000"
+		role := "primary"
+		if i > 0 {
+			role = "standby"
+		}
+		relationship.Peers = append(relationship.Peers, Peer{
+			Name: &peerNames[i],
+			URL:  &url,
+			Role: &role,
+		})
+	}
+	return relationship
+}
+
+// Checks if the relationships are returned for the hub-and-spoke
+// configuration.
+func TestHALibraryParamsGetRelationships(t *testing.T) {
+	params := HALibraryParams{}
+	require.Empty(t, params.GetRelationships())
+	require.Nil(t, params.GetRelationshipByServerName("server1"))
+	require.False(t, params.GetFirst().IsValid())
+
+	params.HA = []HA{
+		getTestHARelationship("server1", "server1", "server2"),
+		getTestHARelationship("server3", "server3", "server4"),
+	}
+	relationships := params.GetRelationships()
+	require.Len(t, relationships, 2)
+	require.Equal(t, "server1", *relationships[0].ThisServerName)
+	require.Equal(t, "server3", *relationships[1].ThisServerName)
+	require.Same(t, params.GetFirst(), relationships[0])
+
+	relationship := params.GetRelationshipByServerName("server3")
+	require.NotNil(t, relationship)
+	require.Same(t, relationships[1], relationship)
+	require.Nil(t, params.GetRelationshipByServerName("server2"))
+}
+
+// Checks if this server's configuration is found among the peers.
+func TestHAGetThisServer(t *testing.T) {
+	relationship := getTestHARelationship("server2", "server1", "server2")
+	peer := relationship.GetThisServer()
+	require.NotNil(t, peer)
+	require.Equal(t, "server2", *peer.Name)
+	require.Equal(t, "standby", *peer.Role)
+
+	relationship = getTestHARelationship("server3", "server1", "server2")
+	require.Nil(t, relationship.GetThisServer())
+
+	require.Nil(t, HA{}.GetThisServer())
+}
+
+// Checks if the relationship name is the same for all peers.
+func TestHAGetRelationshipName(t *testing.T) {
+	relationship1 := getTestHARelationship("server1", "server1", "server2", "server3")
+	relationship2 := getTestHARelationship("server3", "server3", "server1", "server2")
+	require.Equal(t, "server1,server2,server3", relationship1.GetRelationshipName())
+	require.Equal(t, relationship1.GetRelationshipName(), relationship2.GetRelationshipName())
+	require.Empty(t, HA{}.GetRelationshipName())
+}
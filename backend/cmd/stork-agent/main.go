@@ -219,7 +219,7 @@ func setupApp(reload bool) *cli.App {
 			&cli.BoolFlag{
 				Name:    "prometheus-kea-exporter-per-subnet-stats",
 				Value:   true,
-				Usage:   "Enable or disable collecting per-subnet stats from Kea",
+				Usage:   "Enable or disable collecting per-subnet and per-pool stats from Kea",
 				EnvVars: []string{"STORK_AGENT_PROMETHEUS_KEA_EXPORTER_PER_SUBNET_STATS"},
 			},
 			// Prometheus Bind 9 exporter settings
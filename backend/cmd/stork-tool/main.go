@@ -5,6 +5,7 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -14,6 +15,7 @@ import (
 	"isc.org/stork/hooksutil"
 	"isc.org/stork/server/certs"
 	dbops "isc.org/stork/server/database"
+	"isc.org/stork/server/eventcenter"
 	storkutil "isc.org/stork/util"
 )
 
@@ -198,6 +200,20 @@ func runCertImport(settings *cli.Context) error {
 	return certs.ImportSecret(db, settings.String("object"), settings.String("file"))
 }
 
+// Execute events prune command. It deletes the events exceeding the
+// retention limits specified in the server settings.
+func runEventsPrune(settings *cli.Context) error {
+	db := getDBConn(settings)
+	defer db.Close()
+
+	count, err := eventcenter.Prune(db, settings.String("archive-dir"), time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	log.WithField("count", count).Info("Pruned events")
+	return nil
+}
+
 // Inspect the hook file.
 func inspectHookFile(path string, library *hooksutil.LibraryManager, err error) {
 	if err != nil {
@@ -358,6 +374,16 @@ func setupApp() *cli.App {
 			EnvVars: []string{"STORK_TOOL_CERT_FILE"},
 		})
 
+	var eventsPruneFlags []cli.Flag
+	eventsPruneFlags = append(eventsPruneFlags, dbFlags...)
+	eventsPruneFlags = append(eventsPruneFlags,
+		&cli.StringFlag{
+			Name:    "archive-dir",
+			Usage:   "The directory the pruned events are archived to as a compressed JSON lines file; if not provided, then the events are not archived",
+			Aliases: []string{"a"},
+			EnvVars: []string{"STORK_TOOL_EVENTS_ARCHIVE_DIR"},
+		})
+
 	hookInspectFlags := []cli.Flag{
 		&cli.StringFlag{
 			Name:     "path",
@@ -383,7 +409,7 @@ func setupApp() *cli.App {
 	app := &cli.App{
 		Name:  "Stork Tool",
 		Usage: "A tool for managing Stork Server.",
-		Description: `The tool operates in three areas:
+		Description: `The tool operates in four areas:
 
    - Certificate Management - it allows for exporting Stork Server keys, certificates,
      and tokens that are used to secure communication between the Stork Server
@@ -393,7 +419,10 @@ func setupApp() *cli.App {
      and a user that can access this database with a generated password;
 
    - Database Migration - it allows for performing database schema migrations,
-     overwriting the db schema version and getting its current value.`,
+     overwriting the db schema version and getting its current value;
+
+   - Events Management - it allows for pruning the events exceeding the retention
+     limits specified in the server settings.`,
 		Version:  stork.Version,
 		HelpName: "stork-tool",
 		Flags: []cli.Flag{
@@ -522,6 +551,16 @@ func setupApp() *cli.App {
 				Category:    "Certificates Management",
 				Action:      runCertImport,
 			},
+			// EVENTS MANAGEMENT
+			{
+				Name:        "events-prune",
+				Usage:       "Delete the events exceeding the retention limits",
+				UsageText:   "stork-tool events-prune [options for db connection] [-a directory]",
+				Description: ``,
+				Flags:       eventsPruneFlags,
+				Category:    "Events Management",
+				Action:      runEventsPrune,
+			},
 			{
 				Name:        "hook-inspect",
 				Usage:       "Prints details about hooks",
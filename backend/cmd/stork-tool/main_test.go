@@ -15,6 +15,7 @@ import (
 
 	"isc.org/stork"
 	"isc.org/stork/server/certs"
+	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
 	"isc.org/stork/testutil"
 )
@@ -46,6 +47,7 @@ func getExpectedMainFragments() []string {
 		"db-reset",
 		"db-version",
 		"db-set-version",
+		"events-prune",
 	}
 }
 
@@ -97,7 +99,7 @@ func TestDbOptsHelp(t *testing.T) {
 		"STORK_DATABASE_",
 	}
 
-	cmds := []string{"db-init", "db-up", "db-down", "db-reset", "db-version", "db-set-version", "cert-export", "cert-import"}
+	cmds := []string{"db-init", "db-up", "db-down", "db-reset", "db-version", "db-set-version", "cert-export", "cert-import", "events-prune"}
 	for _, cmd := range cmds {
 		// Run the --help version and get its output.
 		toolCmd := exec.Command(ToolBin, cmd, "-h")
@@ -191,6 +193,36 @@ func TestRunCertImport(t *testing.T) {
 	main()
 }
 
+// Check if events-prune can be invoked.
+func TestRunEventsPrune(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	db, settings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	require.NoError(t, dbmodel.SetSettingInt(db, "event_retention_max_info", 1))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, dbmodel.AddEvent(db, &dbmodel.Event{Text: "info", Level: dbmodel.EvInfo}))
+	}
+
+	os.Args = []string{
+		"stork-tool", "events-prune",
+		"--db-name", settings.DBName,
+		"--db-user", settings.User,
+		"--db-password", settings.Password,
+		"--db-host", settings.Host,
+		"--db-port", strconv.Itoa(settings.Port),
+		"-a", sb.BasePath,
+	}
+	main()
+
+	_, total, err := dbmodel.GetEventsByPage(db, 0, 10, dbmodel.EvInfo, nil, nil, nil, nil, "", dbmodel.SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+}
+
 // Check if db-create command can be invoked.
 func TestRunDBCreate(t *testing.T) {
 	_, settings, teardown := dbtest.SetupDatabaseTestCaseWithMaintenanceCredentials(t)
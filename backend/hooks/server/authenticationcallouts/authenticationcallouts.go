@@ -22,9 +22,7 @@ type AuthenticationMetadata interface {
 }
 
 // The metadata of the authentication method that uses the data from the login
-// form. Currently, we expect that all methods implement this interface.
-// The non-form flow (e.g.: OpenID Connect, Basic Auth, Bearer Token, JWT) are
-// not supported.
+// form.
 type AuthenticationMetadataForm interface {
 	// Returns a label for the identifier field in the login form on UI.
 	GetIdentifierFormLabel() string
@@ -32,6 +30,16 @@ type AuthenticationMetadataForm interface {
 	GetSecretFormLabel() string
 }
 
+// The metadata of the authentication method that redirects the user to an
+// external identity provider (e.g.: OpenID Connect, OAuth2). The UI displays
+// a button starting the redirect instead of the login form. The callout
+// carrier of such a method must implement the
+// "AuthenticationRedirectCallouts" interface.
+type AuthenticationMetadataRedirect interface {
+	// Returns a label of the button redirecting to the identity provider.
+	GetRedirectButtonLabel() string
+}
+
 // The logged user metadata. It's a data transfer object (DTO) to avoid using
 // heavy dbmodel dependencies.
 type User struct {
@@ -59,9 +67,30 @@ type AuthenticationCallouts interface {
 	// Returns authentication metadata used to list the authentication methods
 	// on the UI. The metadata object should also implement the interface
 	// specific to the flow of providing credentials
-	// (e.g.: "AuthenticationMetadataForm" - for form-based).
-	// Note: Other flows are unsupported but expected in the future as
-	// redirecting to external authentication provider, Single Sign On, Basic
-	// Auth, Token-based authentication, or Multi-Factor authentication
+	// (e.g.: "AuthenticationMetadataForm" - for form-based,
+	// "AuthenticationMetadataRedirect" - for redirect-based).
+	// Note: Other flows are unsupported but expected in the future as Basic
+	// Auth or Multi-Factor authentication.
 	GetMetadata() AuthenticationMetadata
 }
+
+// Set of callouts used to perform the redirect-based authentication (e.g.:
+// OpenID Connect authorization code flow). They must be implemented by the
+// callout carriers whose metadata implements the
+// "AuthenticationMetadataRedirect" interface. The carriers must implement
+// the "AuthenticationCallouts" interface too.
+type AuthenticationRedirectCallouts interface {
+	// Called to begin the authentication. It accepts the HTTP request and the
+	// absolute Stork URL the identity provider should redirect the user to
+	// after the authentication. Returns the URL of the identity provider the
+	// user is redirected to and the flow state (e.g.: the state parameter,
+	// nonce, PKCE code verifier). The flow state is opaque for Stork. It is
+	// stored in the user session and passed to the completing callout.
+	BeginAuthentication(ctx context.Context, request *http.Request, callbackURL string) (redirectURL string, flowState string, err error)
+	// Called to complete the authentication when the identity provider
+	// redirects the user back to Stork. It accepts the HTTP request received
+	// by the callback URL (e.g.: with the authorization code in the query),
+	// the callback URL and the flow state returned by the beginning callout.
+	// Returns a user metadata or error if an authentication failed.
+	CompleteAuthentication(ctx context.Context, request *http.Request, callbackURL, flowState string) (*User, error)
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -49,6 +50,14 @@ type AgentStats struct {
 	mutex         *sync.Mutex
 }
 
+// Statistics of the gRPC calls to a given agent. The duration is the
+// total duration of the calls, including the retries.
+type AgentCallStats struct {
+	Calls    int64
+	Failures int64
+	Duration time.Duration
+}
+
 // Runtime information about the agent, e.g. connection, communication
 // statistics.
 type Agent struct {
@@ -133,6 +142,7 @@ type ConnectedAgents interface {
 	Shutdown()
 	GetConnectedAgent(address string) (*Agent, error)
 	GetConnectedAgentStats(address string, port int64) *AgentStats
+	GetAgentCallStats() map[string]AgentCallStats
 	Ping(ctx context.Context, address string, agentPort int64) error
 	GetState(ctx context.Context, address string, agentPort int64) (*State, error)
 	ForwardRndcCommand(ctx context.Context, app ControlledApp, command string) (*RndcOutput, error)
@@ -149,6 +159,8 @@ type connectedAgentsData struct {
 	CommLoopReqs  chan *commLoopReq
 	DoneCommLoop  chan bool
 	Wg            *sync.WaitGroup
+	callStats     map[string]*AgentCallStats
+	callMutex     *sync.Mutex
 	serverCertPEM []byte
 	serverKeyPEM  []byte
 	caCertPEM     []byte
@@ -163,6 +175,8 @@ func NewConnectedAgents(settings *AgentsSettings, eventCenter eventcenter.EventC
 		CommLoopReqs:  make(chan *commLoopReq),
 		DoneCommLoop:  make(chan bool),
 		Wg:            &sync.WaitGroup{},
+		callStats:     make(map[string]*AgentCallStats),
+		callMutex:     &sync.Mutex{},
 		caCertPEM:     caCertPEM,
 		serverCertPEM: serverCertPEM,
 		serverKeyPEM:  serverKeyPEM,
@@ -229,3 +243,31 @@ func (agents *connectedAgentsData) GetConnectedAgentStats(address string, port i
 	}
 	return nil
 }
+
+// Records the duration and the result of the gRPC call to the agent.
+func (agents *connectedAgentsData) recordCall(address string, duration time.Duration, err error) {
+	agents.callMutex.Lock()
+	defer agents.callMutex.Unlock()
+	stats, ok := agents.callStats[address]
+	if !ok {
+		stats = &AgentCallStats{}
+		agents.callStats[address] = stats
+	}
+	stats.Calls++
+	if err != nil {
+		stats.Failures++
+	}
+	stats.Duration += duration
+}
+
+// Returns the statistics of the gRPC calls to the agents. The map is
+// indexed by the agent addresses with ports.
+func (agents *connectedAgentsData) GetAgentCallStats() map[string]AgentCallStats {
+	agents.callMutex.Lock()
+	defer agents.callMutex.Unlock()
+	stats := make(map[string]AgentCallStats, len(agents.callStats))
+	for address, agentStats := range agents.callStats {
+		stats[address] = *agentStats
+	}
+	return stats
+}
@@ -1,7 +1,9 @@
 package agentcomm
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	storktest "isc.org/stork/server/test/dbmodel"
@@ -214,6 +216,29 @@ func TestConnectingToAgent(t *testing.T) {
 	require.EqualValues(t, 1, agent.Stats.CurrentErrors)
 }
 
+// Test that the gRPC calls to the agents are counted.
+func TestRecordCall(t *testing.T) {
+	settings := AgentsSettings{}
+	fec := &storktest.FakeEventCenter{}
+	agents := NewConnectedAgents(&settings, fec, CACertPEM, ServerCertPEM, ServerKeyPEM)
+	defer agents.Shutdown()
+
+	require.Empty(t, agents.GetAgentCallStats())
+
+	agentsData := agents.(*connectedAgentsData)
+	agentsData.recordCall("127.0.0.1:8080", time.Second, nil)
+	agentsData.recordCall("127.0.0.1:8080", 2*time.Second, errors.New("test error"))
+	agentsData.recordCall("127.0.0.1:8081", time.Second, nil)
+
+	stats := agents.GetAgentCallStats()
+	require.Len(t, stats, 2)
+	require.EqualValues(t, 2, stats["127.0.0.1:8080"].Calls)
+	require.EqualValues(t, 1, stats["127.0.0.1:8080"].Failures)
+	require.Equal(t, 3*time.Second, stats["127.0.0.1:8080"].Duration)
+	require.EqualValues(t, 1, stats["127.0.0.1:8081"].Calls)
+	require.Zero(t, stats["127.0.0.1:8081"].Failures)
+}
+
 // Check if credentials for TLS can be prepared using prepareTLSCreds.
 func TestPrepareTLSCreds(t *testing.T) {
 	creds, err := prepareTLSCreds(CACertPEM, ServerCertPEM, ServerKeyPEM)
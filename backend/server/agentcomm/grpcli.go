@@ -38,10 +38,12 @@ const (
 )
 
 // The application entry detected by an agent. It unambiguously indicates the
-// application location.
+// application location. The configuration path distinguishes multiple
+// instances of the same app type running on one machine.
 type App struct {
 	Type         string
 	AccessPoints []AccessPoint
+	ConfigPath   string
 }
 
 // Currently supported types are: "kea" and "bind9".
@@ -81,6 +83,7 @@ type State struct {
 type ControlledApp interface {
 	dbmodel.AppTag
 	GetControlAccessPoint() (string, int64, string, bool, error)
+	GetConfigPath() string
 	GetMachineTag() dbmodel.MachineTag
 	GetDaemonTags() []dbmodel.DaemonTag
 }
@@ -138,6 +141,7 @@ func (agents *connectedAgentsData) GetState(ctx context.Context, address string,
 		apps = append(apps, &App{
 			Type:         app.Type,
 			AccessPoints: accessPoints,
+			ConfigPath:   app.ConfigPath,
 		})
 	}
 
@@ -188,8 +192,9 @@ func (agents *connectedAgentsData) ForwardRndcCommand(ctx context.Context, app C
 
 	// Prepare the on-wire representation of the commands.
 	req := &agentapi.ForwardRndcCommandReq{
-		Address: ctrlAddress,
-		Port:    ctrlPort,
+		Address:    ctrlAddress,
+		Port:       ctrlPort,
+		ConfigPath: app.GetConfigPath(),
 		RndcRequest: &agentapi.RndcRequest{
 			Request: command,
 		},
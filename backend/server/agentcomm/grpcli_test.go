@@ -9,6 +9,7 @@ import (
 	"github.com/golang/mock/gomock"
 	pkgerrors "github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
 	agentapi "isc.org/stork/api"
 	keactrl "isc.org/stork/appctrl/kea"
 	dbmodel "isc.org/stork/server/database/model"
@@ -64,6 +65,12 @@ func TestPing(t *testing.T) {
 	ctx := context.Background()
 	err := agents.Ping(ctx, "127.0.0.1", 8080)
 	require.NoError(t, err)
+
+	// The call should be recorded in the statistics.
+	stats := agents.GetAgentCallStats()
+	require.Contains(t, stats, "127.0.0.1:8080")
+	require.EqualValues(t, 1, stats["127.0.0.1:8080"].Calls)
+	require.Zero(t, stats["127.0.0.1:8080"].Failures)
 }
 
 // Check if GetState works.
@@ -464,7 +471,11 @@ func TestForwardRndcCommand(t *testing.T) {
 		},
 	}
 
+	var req *agentapi.ForwardRndcCommandReq
 	mockAgentClient.EXPECT().ForwardRndcCommand(gomock.Any(), gomock.Any()).
+		Do(func(ctx context.Context, in *agentapi.ForwardRndcCommandReq, opts ...grpc.CallOption) {
+			req = in
+		}).
 		Return(&rsp, nil)
 
 	ctx := context.Background()
@@ -479,6 +490,7 @@ func TestForwardRndcCommand(t *testing.T) {
 			Port:    953,
 			Key:     "",
 		}},
+		ConfigPath: "/etc/bind/named.conf",
 	}
 
 	out, err := agents.ForwardRndcCommand(ctx, dbApp, "test")
@@ -486,6 +498,12 @@ func TestForwardRndcCommand(t *testing.T) {
 	require.Equal(t, out.Output, "all good")
 	require.NoError(t, out.Error)
 
+	// The config path identifies the daemon among the daemons sharing
+	// the same control access point.
+	require.NotNil(t, req)
+	require.Equal(t, "/etc/bind/named.conf", req.ConfigPath)
+	require.Equal(t, "test", req.RndcRequest.Request)
+
 	agent, err := agents.GetConnectedAgent("127.0.0.1:8080")
 	require.NoError(t, err)
 	require.NotNil(t, agent)
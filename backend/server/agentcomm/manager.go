@@ -2,6 +2,7 @@ package agentcomm
 
 import (
 	"context"
+	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -74,13 +75,22 @@ func doCall(ctx context.Context, agent *Agent, in interface{}) (interface{}, err
 }
 
 // Forward request received from channel to given agent and send back response
-// via channel to requestor.
+// via channel to requestor. The duration and the result of the call are
+// recorded in the agent call statistics.
 func (agents *connectedAgentsData) handleRequest(req *commLoopReq) {
+	startedAt := time.Now()
+	response, err := agents.forwardRequest(req)
+	agents.recordCall(req.AgentAddr, time.Since(startedAt), err)
+	req.RespChan <- &channelResp{Response: response, Err: err}
+}
+
+// Pass the request to the agent. If the call fails, the connection to the
+// agent is re-established and the call is repeated once.
+func (agents *connectedAgentsData) forwardRequest(req *commLoopReq) (interface{}, error) {
 	// get agent and its grpc connection
 	agent, err := agents.GetConnectedAgent(req.AgentAddr)
 	if err != nil {
-		req.RespChan <- &channelResp{Response: nil, Err: err}
-		return
+		return nil, err
 	}
 
 	// do call
@@ -95,11 +105,7 @@ func (agents *connectedAgentsData) handleRequest(req *commLoopReq) {
 			log.WithFields(log.Fields{
 				"agent": agent.Address,
 			}).Warn(err)
-			req.RespChan <- &channelResp{
-				Response: nil,
-				Err:      errors.WithMessagef(err2, "grpc manager is unable to re-establish connection with the agent %s", agent.Address),
-			}
-			return
+			return nil, errors.WithMessagef(err2, "grpc manager is unable to re-establish connection with the agent %s", agent.Address)
 		}
 
 		// do call once again
@@ -108,13 +114,9 @@ func (agents *connectedAgentsData) handleRequest(req *commLoopReq) {
 			log.WithFields(log.Fields{
 				"agent": agent.Address,
 			}).Warn(err)
-			req.RespChan <- &channelResp{
-				Response: nil,
-				Err:      errors.WithMessagef(err2, "grpc manager is unable to re-establish connection with the agent %s", agent.Address),
-			}
-			return
+			return nil, errors.WithMessagef(err2, "grpc manager is unable to re-establish connection with the agent %s", agent.Address)
 		}
 	}
 
-	req.RespChan <- &channelResp{Response: response, Err: nil}
+	return response, nil
 }
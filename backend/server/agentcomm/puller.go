@@ -1,6 +1,7 @@
 package agentcomm
 
 import (
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -11,6 +12,15 @@ import (
 	storkutil "isc.org/stork/util"
 )
 
+// Runtime statistics of the periodic puller. The durations are the
+// durations of the puller function executions.
+type PullerStats struct {
+	Runs          int64
+	Errors        int64
+	LastDuration  time.Duration
+	TotalDuration time.Duration
+}
+
 // Structure representing a periodic puller which is configured to
 // execute a function specified by a caller according to the timer
 // interval specified in the database. The user's function typically
@@ -20,6 +30,8 @@ type PeriodicPuller struct {
 	intervalSettingName string
 	lastInvokedAt       *atomic.Value
 	lastFinishedAt      *atomic.Value
+	stats               *PullerStats
+	statsMutex          *sync.Mutex
 	DB                  *dbops.PgDB
 	Agents              ConnectedAgents
 }
@@ -34,13 +46,26 @@ func NewPeriodicPuller(db *dbops.PgDB, agents ConnectedAgents, pullerName, inter
 	var lastFinishedAt atomic.Value
 	lastInvokedAt.Store(time.Time{})
 	lastFinishedAt.Store(time.Time{})
+	stats := &PullerStats{}
+	statsMutex := &sync.Mutex{}
 
 	periodicExecutor, err := storkutil.NewPeriodicExecutor(
 		pullerName,
 		func() error {
-			lastInvokedAt.Store(time.Now())
+			invokedAt := time.Now()
+			lastInvokedAt.Store(invokedAt)
 			err := pullFunc()
-			lastFinishedAt.Store(time.Now())
+			finishedAt := time.Now()
+			lastFinishedAt.Store(finishedAt)
+
+			statsMutex.Lock()
+			defer statsMutex.Unlock()
+			stats.Runs++
+			if err != nil {
+				stats.Errors++
+			}
+			stats.LastDuration = finishedAt.Sub(invokedAt)
+			stats.TotalDuration += stats.LastDuration
 			return err
 		},
 		func() (int64, error) {
@@ -58,6 +83,8 @@ func NewPeriodicPuller(db *dbops.PgDB, agents ConnectedAgents, pullerName, inter
 		intervalSettingName: intervalSettingName,
 		lastInvokedAt:       &lastInvokedAt,
 		lastFinishedAt:      &lastFinishedAt,
+		stats:               stats,
+		statsMutex:          statsMutex,
 		DB:                  db,
 		Agents:              agents,
 	}
@@ -79,3 +106,10 @@ func (p *PeriodicPuller) GetLastFinishedAt() time.Time {
 func (p *PeriodicPuller) GetLastInvokedAt() time.Time {
 	return p.lastInvokedAt.Load().(time.Time)
 }
+
+// Returns the runtime statistics of the puller.
+func (p *PeriodicPuller) GetStats() PullerStats {
+	p.statsMutex.Lock()
+	defer p.statsMutex.Unlock()
+	return *p.stats
+}
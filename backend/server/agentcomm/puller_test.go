@@ -1,6 +1,7 @@
 package agentcomm
 
 import (
+	"errors"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -141,3 +142,35 @@ func TestPullerSavesLastInvokedTime(t *testing.T) {
 	require.LessOrEqual(t, startTime, *pullTime)
 	require.LessOrEqual(t, invokedTime, *pullTime)
 }
+
+// Test that the puller counts its runs and errors.
+func TestPullerCollectsStats(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+	_ = dbmodel.SetSettingInt(db, "kea_hosts_puller_interval", 1)
+
+	var runs int64
+	puller, _ := NewPeriodicPuller(db, nil, "test puller", "kea_hosts_puller_interval",
+		func() error {
+			// Fail the first run.
+			if atomic.AddInt64(&runs, 1) == 1 {
+				return errors.New("test error")
+			}
+			time.Sleep(10 * time.Millisecond)
+			return nil
+		})
+	defer puller.Shutdown()
+
+	// Act
+	require.Eventually(t, func() bool {
+		return puller.GetStats().Runs >= 2
+	}, 5*time.Second, 100*time.Millisecond)
+	stats := puller.GetStats()
+
+	// Assert
+	require.EqualValues(t, 1, stats.Errors)
+	require.GreaterOrEqual(t, stats.LastDuration, 10*time.Millisecond)
+	require.GreaterOrEqual(t, stats.TotalDuration, stats.LastDuration)
+}
@@ -2,6 +2,7 @@ package agentcommtest
 
 import (
 	"context"
+	"time"
 
 	keactrl "isc.org/stork/appctrl/kea"
 	"isc.org/stork/server/agentcomm"
@@ -21,6 +22,8 @@ type FakeAgents struct {
 	RecordedCommand string
 	mockRndcOutput  string
 
+	mockRndcCommandOutputs map[string]string
+
 	RecordedStatsURL string
 	mockNamedFunc    func(int, interface{})
 
@@ -106,6 +109,17 @@ func (fa *FakeAgents) GetConnectedAgentStats(address string, port int64) *agentc
 	return &stats
 }
 
+// Returns fake statistics of the gRPC calls to the agents.
+func (fa *FakeAgents) GetAgentCallStats() map[string]agentcomm.AgentCallStats {
+	return map[string]agentcomm.AgentCallStats{
+		"localhost:8080": {
+			Calls:    10,
+			Failures: 2,
+			Duration: 5 * time.Second,
+		},
+	}
+}
+
 // FakeAgents specific implementation of the GetState.
 func (fa *FakeAgents) GetState(ctx context.Context, address string, agentPort int64) (*agentcomm.State, error) {
 	fa.GetStateCalled = true
@@ -184,6 +198,13 @@ func (fa *FakeAgents) ForwardRndcCommand(ctx context.Context, app agentcomm.Cont
 	fa.RecordedAddress, fa.RecordedPort, fa.RecordedKey, _, _ = app.GetControlAccessPoint()
 	fa.RecordedCommand = command
 
+	if output, ok := fa.mockRndcCommandOutputs[command]; ok {
+		return &agentcomm.RndcOutput{
+			Output: output,
+			Error:  nil,
+		}, nil
+	}
+
 	if fa.mockRndcOutput != "" {
 		output := &agentcomm.RndcOutput{
 			Output: fa.mockRndcOutput,
@@ -195,6 +216,15 @@ func (fa *FakeAgents) ForwardRndcCommand(ctx context.Context, app agentcomm.Cont
 	return nil, nil
 }
 
+// Sets the mocked output of the specified rndc command. The commands
+// without the mocked output return the output of the status command.
+func (fa *FakeAgents) SetRndcCommandOutput(command, output string) {
+	if fa.mockRndcCommandOutputs == nil {
+		fa.mockRndcCommandOutputs = make(map[string]string)
+	}
+	fa.mockRndcCommandOutputs[command] = output
+}
+
 // Mimics tailing text file.
 func (fa *FakeAgents) TailTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64) ([]string, error) {
 	return []string{"lorem ipsum"}, nil
@@ -0,0 +1,368 @@
+package alerting
+
+import (
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+)
+
+// Subnet or shared network checked by the alert rule together with the
+// value of the rule metric.
+type alertObject struct {
+	objectType string
+	id         int64
+	name       string
+	subnet     *dbmodel.Subnet
+	value      float64
+	// Set when the object no longer exists or has no statistics.
+	missing bool
+}
+
+// Checks if the alert rule is valid.
+func ValidateRule(rule *dbmodel.AlertRule) error {
+	if rule.Name == "" {
+		return errors.New("alert rule name must not be empty")
+	}
+	switch rule.Scope {
+	case dbmodel.AlertScopeSubnet:
+		if rule.SubnetID == 0 || rule.SharedNetworkID != 0 {
+			return errors.New("alert rule with the subnet scope must specify only the subnet")
+		}
+	case dbmodel.AlertScopeSharedNetwork:
+		if rule.SharedNetworkID == 0 || rule.SubnetID != 0 {
+			return errors.New("alert rule with the shared network scope must specify only the shared network")
+		}
+	case dbmodel.AlertScopeAll:
+		if rule.SubnetID != 0 || rule.SharedNetworkID != 0 {
+			return errors.New("alert rule with the all scope must not specify the subnet or shared network")
+		}
+	default:
+		return errors.Errorf("invalid alert rule scope %s", rule.Scope)
+	}
+	switch rule.Metric {
+	case dbmodel.AlertMetricAddressUtilization, dbmodel.AlertMetricPDUtilization:
+		if rule.Threshold < 0 || rule.Threshold > 100 {
+			return errors.New("utilization threshold must be between 0 and 100")
+		}
+	case dbmodel.AlertMetricDeclined, dbmodel.AlertMetricFreeAddresses:
+		if rule.Threshold < 0 {
+			return errors.New("threshold must not be negative")
+		}
+	default:
+		return errors.Errorf("invalid alert rule metric %s", rule.Metric)
+	}
+	if rule.Hysteresis < 0 {
+		return errors.New("hysteresis must not be negative")
+	}
+	if rule.Duration < 0 {
+		return errors.New("duration must not be negative")
+	}
+	if rule.Level != dbmodel.EvWarning && rule.Level != dbmodel.EvError {
+		return errors.Errorf("invalid alert level %d", rule.Level)
+	}
+	return nil
+}
+
+// Converts the statistic value to a float. It returns false if the
+// statistic doesn't exist.
+func getStatistic(stats dbmodel.SubnetStats, name string) (float64, bool) {
+	switch value := stats[name].(type) {
+	case uint64:
+		return float64(value), true
+	case int64:
+		return float64(value), true
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(value).Float64()
+		return f, true
+	case float64:
+		return value, true
+	}
+	return 0, false
+}
+
+// Returns the number of the addresses that are not assigned. The names
+// of the statistics depend on the family.
+func getFreeAddresses(stats dbmodel.SubnetStats, totalName, assignedName string) (float64, bool) {
+	total, ok := getStatistic(stats, totalName)
+	if !ok {
+		return 0, false
+	}
+	assigned, ok := getStatistic(stats, assignedName)
+	if !ok {
+		return 0, false
+	}
+	return total - assigned, true
+}
+
+// Returns the value of the metric for the subnet. It returns false if the
+// statistics haven't been pulled yet or the metric doesn't apply to the
+// subnet.
+func getSubnetMetric(subnet *dbmodel.Subnet, metric dbmodel.AlertMetric) (float64, bool) {
+	if subnet.Stats == nil {
+		return 0, false
+	}
+	suffix := "nas"
+	if subnet.GetFamily() == 4 {
+		suffix = "addresses"
+	}
+	switch metric {
+	case dbmodel.AlertMetricAddressUtilization:
+		return float64(subnet.AddrUtilization) / 10, true
+	case dbmodel.AlertMetricPDUtilization:
+		if subnet.GetFamily() == 4 {
+			return 0, false
+		}
+		return float64(subnet.PdUtilization) / 10, true
+	case dbmodel.AlertMetricDeclined:
+		return getStatistic(subnet.Stats, "declined-"+suffix)
+	case dbmodel.AlertMetricFreeAddresses:
+		return getFreeAddresses(subnet.Stats, "total-"+suffix, "assigned-"+suffix)
+	}
+	return 0, false
+}
+
+// Returns the value of the metric for the shared network. The shared
+// network statistics don't include the declined addresses, so they are
+// summed up from the subnets belonging to the shared network.
+func getSharedNetworkMetric(network *dbmodel.SharedNetwork, subnets []dbmodel.Subnet, metric dbmodel.AlertMetric) (float64, bool) {
+	if network.Stats == nil {
+		return 0, false
+	}
+	switch metric {
+	case dbmodel.AlertMetricAddressUtilization:
+		return float64(network.AddrUtilization) / 10, true
+	case dbmodel.AlertMetricPDUtilization:
+		if network.Family == 4 {
+			return 0, false
+		}
+		return float64(network.PdUtilization) / 10, true
+	case dbmodel.AlertMetricDeclined:
+		var declined float64
+		found := false
+		for i := range subnets {
+			if subnets[i].SharedNetworkID != network.ID {
+				continue
+			}
+			if value, ok := getSubnetMetric(&subnets[i], metric); ok {
+				declined += value
+				found = true
+			}
+		}
+		return declined, found
+	case dbmodel.AlertMetricFreeAddresses:
+		return getFreeAddresses(network.Stats, "total-nas", "assigned-nas")
+	}
+	return 0, false
+}
+
+// Returns the subnets and shared networks checked by the rule together
+// with the values of the rule metric. The rule with the all scope checks
+// all subnets and all shared networks.
+func getAlertObjects(rule *dbmodel.AlertRule, subnets []dbmodel.Subnet, networks []dbmodel.SharedNetwork) (objects []alertObject) {
+	switch rule.Scope {
+	case dbmodel.AlertScopeSubnet, dbmodel.AlertScopeAll:
+		for i := range subnets {
+			subnet := &subnets[i]
+			if rule.Scope == dbmodel.AlertScopeSubnet && subnet.ID != rule.SubnetID {
+				continue
+			}
+			if value, ok := getSubnetMetric(subnet, rule.Metric); ok {
+				objects = append(objects, alertObject{
+					objectType: dbmodel.AlertObjectSubnet,
+					id:         subnet.ID,
+					name:       subnet.Prefix,
+					subnet:     &dbmodel.Subnet{ID: subnet.ID, Prefix: subnet.Prefix},
+					value:      value,
+				})
+			}
+		}
+	}
+	switch rule.Scope {
+	case dbmodel.AlertScopeSharedNetwork, dbmodel.AlertScopeAll:
+		for i := range networks {
+			network := &networks[i]
+			if rule.Scope == dbmodel.AlertScopeSharedNetwork && network.ID != rule.SharedNetworkID {
+				continue
+			}
+			if value, ok := getSharedNetworkMetric(network, subnets, rule.Metric); ok {
+				objects = append(objects, alertObject{
+					objectType: dbmodel.AlertObjectSharedNetwork,
+					id:         network.ID,
+					name:       network.Name,
+					value:      value,
+				})
+			}
+		}
+	}
+	return objects
+}
+
+// Returns the metric value formatted for the event text.
+func formatMetric(metric dbmodel.AlertMetric, value float64) string {
+	switch metric {
+	case dbmodel.AlertMetricAddressUtilization:
+		return fmt.Sprintf("address utilization %.1f%%", value)
+	case dbmodel.AlertMetricPDUtilization:
+		return fmt.Sprintf("delegated prefix utilization %.1f%%", value)
+	case dbmodel.AlertMetricDeclined:
+		return fmt.Sprintf("%.0f declined addresses", value)
+	default:
+		return fmt.Sprintf("%.0f free addresses", value)
+	}
+}
+
+// Creates the event raised when the alert fires or clears.
+func createAlertEvent(rule *dbmodel.AlertRule, object *alertObject, firing bool) *dbmodel.Event {
+	target := "{subnet}"
+	objects := []interface{}{}
+	if object.subnet != nil {
+		objects = append(objects, object.subnet)
+	} else {
+		target = fmt.Sprintf("shared network %s", object.name)
+	}
+	details := fmt.Sprintf("threshold: %g, hysteresis: %g, duration: %ds",
+		rule.Threshold, rule.Hysteresis, rule.Duration)
+	objects = append(objects, details)
+
+	if firing {
+		text := fmt.Sprintf("Alert %s is firing for %s: %s", rule.Name, target, formatMetric(rule.Metric, object.value))
+		return eventcenter.CreateEvent(rule.Level, text, objects...)
+	}
+	if object.missing {
+		text := fmt.Sprintf("Alert %s cleared for %s: statistics are no longer available", rule.Name, target)
+		return eventcenter.CreateEvent(dbmodel.EvInfo, text, objects...)
+	}
+	text := fmt.Sprintf("Alert %s cleared for %s: %s", rule.Name, target, formatMetric(rule.Metric, object.value))
+	return eventcenter.CreateEvent(dbmodel.EvInfo, text, objects...)
+}
+
+// Updates the state of the alert raised by the rule for the object. The
+// alert becomes pending when the value crosses the threshold and fires
+// when it remains crossed for the rule's duration. The pending alert is
+// removed when the value no longer crosses the threshold and the firing
+// alert is cleared when the value crosses the threshold adjusted by the
+// hysteresis. The events are raised when the alert fires and clears.
+func evaluateObject(db *pg.DB, eventCenter eventcenter.EventCenter, rule *dbmodel.AlertRule, object *alertObject, state *dbmodel.AlertState, now time.Time) error {
+	switch {
+	case state == nil:
+		if !rule.IsBreached(object.value) {
+			return nil
+		}
+		state = &dbmodel.AlertState{
+			RuleID:     rule.ID,
+			ObjectType: object.objectType,
+			ObjectID:   object.id,
+			State:      dbmodel.AlertStatePending,
+			Since:      now,
+		}
+	case state.State == dbmodel.AlertStatePending && !rule.IsBreached(object.value):
+		return dbmodel.DeleteAlertState(db, rule.ID, object.objectType, object.id)
+	case state.State == dbmodel.AlertStateFiring && rule.IsCleared(object.value):
+		if err := dbmodel.DeleteAlertState(db, rule.ID, object.objectType, object.id); err != nil {
+			return err
+		}
+		eventCenter.AddEvent(createAlertEvent(rule, object, false))
+		return nil
+	}
+
+	state.ObjectName = object.name
+	state.Value = object.value
+	state.UpdatedAt = now
+	fired := false
+	if state.State == dbmodel.AlertStatePending && !now.Before(state.Since.Add(time.Duration(rule.Duration)*time.Second)) {
+		state.State = dbmodel.AlertStateFiring
+		state.FiredAt = now
+		fired = true
+	}
+	if err := dbmodel.UpsertAlertState(db, state); err != nil {
+		return err
+	}
+	if fired {
+		eventCenter.AddEvent(createAlertEvent(rule, object, true))
+	}
+	return nil
+}
+
+// Evaluates the rule for all subnets and shared networks it applies to.
+// The states of the alerts raised for the objects that no longer exist
+// or have no statistics are removed and the firing alerts are cleared.
+func evaluateRule(db *pg.DB, eventCenter eventcenter.EventCenter, rule *dbmodel.AlertRule, subnets []dbmodel.Subnet, networks []dbmodel.SharedNetwork, now time.Time) error {
+	states, err := dbmodel.GetAlertStatesByRuleID(db, rule.ID)
+	if err != nil {
+		return err
+	}
+	type stateKey struct {
+		objectType string
+		id         int64
+	}
+	statesMap := make(map[stateKey]*dbmodel.AlertState)
+	for i := range states {
+		statesMap[stateKey{states[i].ObjectType, states[i].ObjectID}] = &states[i]
+	}
+
+	var lastErr error
+	objects := getAlertObjects(rule, subnets, networks)
+	for i := range objects {
+		key := stateKey{objects[i].objectType, objects[i].id}
+		if err = evaluateObject(db, eventCenter, rule, &objects[i], statesMap[key], now); err != nil {
+			lastErr = err
+		}
+		delete(statesMap, key)
+	}
+	for key, state := range statesMap {
+		if err = dbmodel.DeleteAlertState(db, rule.ID, key.objectType, key.id); err != nil {
+			lastErr = err
+			continue
+		}
+		if state.State != dbmodel.AlertStateFiring {
+			continue
+		}
+		object := &alertObject{
+			objectType: state.ObjectType,
+			id:         state.ObjectID,
+			name:       state.ObjectName,
+			value:      state.Value,
+			missing:    true,
+		}
+		if object.objectType == dbmodel.AlertObjectSubnet {
+			object.subnet = &dbmodel.Subnet{ID: state.ObjectID, Prefix: state.ObjectName}
+		}
+		eventCenter.AddEvent(createAlertEvent(rule, object, false))
+	}
+	return lastErr
+}
+
+// Evaluates the enabled alert rules against the current subnet and shared
+// network statistics. It should be called after the statistics are pulled.
+// The now value is the current time. The events are raised via the event
+// center when the alerts fire or clear. The function returns the last
+// encountered error.
+func EvaluateRules(db *pg.DB, eventCenter eventcenter.EventCenter, now time.Time) error {
+	rules, err := dbmodel.GetEnabledAlertRules(db)
+	if err != nil || len(rules) == 0 {
+		return err
+	}
+	subnets, err := dbmodel.GetAllSubnets(db, 0)
+	if err != nil {
+		return err
+	}
+	networks, err := dbmodel.GetAllSharedNetworks(db, 0)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for i := range rules {
+		if err = evaluateRule(db, eventCenter, &rules[i], subnets, networks, now); err != nil {
+			lastErr = err
+			log.WithField("rule", rules[i].Name).WithError(err).Error("Problem evaluating alert rule")
+		}
+	}
+	return lastErr
+}
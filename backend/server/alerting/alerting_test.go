@@ -0,0 +1,408 @@
+package alerting
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Utilization statistics set in the subnets and shared networks in the
+// tests.
+type testUtilization struct {
+	address float64
+	pd      float64
+	stats   dbmodel.SubnetStats
+}
+
+func (u *testUtilization) GetAddressUtilization() float64 {
+	return u.address
+}
+
+func (u *testUtilization) GetDelegatedPrefixUtilization() float64 {
+	return u.pd
+}
+
+func (u *testUtilization) GetStatistics() dbmodel.SubnetStats {
+	return u.stats
+}
+
+// Test that the alert rules are validated.
+func TestValidateRule(t *testing.T) {
+	valid := func() *dbmodel.AlertRule {
+		return &dbmodel.AlertRule{
+			Name:      "full",
+			Scope:     dbmodel.AlertScopeSubnet,
+			SubnetID:  1,
+			Metric:    dbmodel.AlertMetricAddressUtilization,
+			Threshold: 90,
+			Level:     dbmodel.EvWarning,
+		}
+	}
+	require.NoError(t, ValidateRule(valid()))
+
+	rule := valid()
+	rule.Name = ""
+	require.ErrorContains(t, ValidateRule(rule), "name must not be empty")
+
+	rule = valid()
+	rule.SharedNetworkID = 2
+	require.ErrorContains(t, ValidateRule(rule), "subnet scope")
+
+	rule = valid()
+	rule.Scope = dbmodel.AlertScopeSharedNetwork
+	require.ErrorContains(t, ValidateRule(rule), "shared network scope")
+
+	rule = valid()
+	rule.Scope = dbmodel.AlertScopeAll
+	require.ErrorContains(t, ValidateRule(rule), "all scope")
+	rule.SubnetID = 0
+	require.NoError(t, ValidateRule(rule))
+
+	rule = valid()
+	rule.Scope = "pool"
+	require.ErrorContains(t, ValidateRule(rule), "invalid alert rule scope")
+
+	rule = valid()
+	rule.Metric = "leases"
+	require.ErrorContains(t, ValidateRule(rule), "invalid alert rule metric")
+
+	rule = valid()
+	rule.Threshold = 101
+	require.ErrorContains(t, ValidateRule(rule), "between 0 and 100")
+
+	rule = valid()
+	rule.Metric = dbmodel.AlertMetricFreeAddresses
+	rule.Threshold = 1000
+	require.NoError(t, ValidateRule(rule))
+	rule.Threshold = -1
+	require.ErrorContains(t, ValidateRule(rule), "must not be negative")
+
+	rule = valid()
+	rule.Hysteresis = -1
+	require.ErrorContains(t, ValidateRule(rule), "hysteresis")
+
+	rule = valid()
+	rule.Duration = -1
+	require.ErrorContains(t, ValidateRule(rule), "duration")
+
+	rule = valid()
+	rule.Level = dbmodel.EvInfo
+	require.ErrorContains(t, ValidateRule(rule), "invalid alert level")
+}
+
+// Test that the metric values are calculated from the subnet and shared
+// network statistics.
+func TestGetMetrics(t *testing.T) {
+	subnet4 := &dbmodel.Subnet{
+		ID:              1,
+		Prefix:          "192.0.2.0/24",
+		SharedNetworkID: 1,
+		AddrUtilization: 925,
+		Stats: dbmodel.SubnetStats{
+			"total-addresses":    uint64(256),
+			"assigned-addresses": uint64(236),
+			"declined-addresses": uint64(3),
+		},
+	}
+	subnet6 := &dbmodel.Subnet{
+		ID:              2,
+		Prefix:          "2001:db8:1::/64",
+		AddrUtilization: 500,
+		PdUtilization:   250,
+		Stats: dbmodel.SubnetStats{
+			"total-nas":    big.NewInt(0).Lsh(big.NewInt(1), 64),
+			"assigned-nas": int64(100),
+			"declined-nas": int64(7),
+		},
+	}
+
+	value, ok := getSubnetMetric(subnet4, dbmodel.AlertMetricAddressUtilization)
+	require.True(t, ok)
+	require.EqualValues(t, 92.5, value)
+	_, ok = getSubnetMetric(subnet4, dbmodel.AlertMetricPDUtilization)
+	require.False(t, ok)
+	value, ok = getSubnetMetric(subnet4, dbmodel.AlertMetricDeclined)
+	require.True(t, ok)
+	require.EqualValues(t, 3, value)
+	value, ok = getSubnetMetric(subnet4, dbmodel.AlertMetricFreeAddresses)
+	require.True(t, ok)
+	require.EqualValues(t, 20, value)
+
+	value, ok = getSubnetMetric(subnet6, dbmodel.AlertMetricPDUtilization)
+	require.True(t, ok)
+	require.EqualValues(t, 25, value)
+	value, ok = getSubnetMetric(subnet6, dbmodel.AlertMetricDeclined)
+	require.True(t, ok)
+	require.EqualValues(t, 7, value)
+	value, ok = getSubnetMetric(subnet6, dbmodel.AlertMetricFreeAddresses)
+	require.True(t, ok)
+	require.InDelta(t, 18446744073709551516., value, 1e5)
+
+	// The subnet without statistics is skipped.
+	_, ok = getSubnetMetric(&dbmodel.Subnet{Prefix: "192.0.2.0/24"}, dbmodel.AlertMetricAddressUtilization)
+	require.False(t, ok)
+
+	network := &dbmodel.SharedNetwork{
+		ID:              1,
+		Name:            "frontend",
+		Family:          4,
+		AddrUtilization: 900,
+		Stats: dbmodel.SubnetStats{
+			"total-nas":    uint64(512),
+			"assigned-nas": uint64(460),
+		},
+	}
+	subnets := []dbmodel.Subnet{*subnet4, *subnet6, {
+		ID:              3,
+		Prefix:          "192.0.3.0/24",
+		SharedNetworkID: 1,
+		Stats:           dbmodel.SubnetStats{"declined-addresses": uint64(2)},
+	}}
+	value, ok = getSharedNetworkMetric(network, subnets, dbmodel.AlertMetricAddressUtilization)
+	require.True(t, ok)
+	require.EqualValues(t, 90, value)
+	_, ok = getSharedNetworkMetric(network, subnets, dbmodel.AlertMetricPDUtilization)
+	require.False(t, ok)
+	value, ok = getSharedNetworkMetric(network, subnets, dbmodel.AlertMetricDeclined)
+	require.True(t, ok)
+	require.EqualValues(t, 5, value)
+	value, ok = getSharedNetworkMetric(network, subnets, dbmodel.AlertMetricFreeAddresses)
+	require.True(t, ok)
+	require.EqualValues(t, 52, value)
+}
+
+// Test that the alerts become pending, fire and clear, and the events are
+// raised.
+func TestEvaluateRules(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fec := &storktest.FakeEventCenter{}
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	// No rules.
+	require.NoError(t, EvaluateRules(db, fec, now))
+
+	network := &dbmodel.SharedNetwork{
+		Name:   "frontend",
+		Family: 4,
+		Subnets: []dbmodel.Subnet{
+			{Prefix: "192.0.2.0/24"},
+		},
+	}
+	require.NoError(t, dbmodel.AddSharedNetwork(db, network))
+	subnet := &network.Subnets[0]
+	other := &dbmodel.Subnet{Prefix: "192.0.3.0/24"}
+	require.NoError(t, dbmodel.AddSubnet(db, other))
+
+	setUtilization := func(address float64, assigned uint64) {
+		stats := &testUtilization{
+			address: address,
+			stats: dbmodel.SubnetStats{
+				"total-addresses":    uint64(100),
+				"assigned-addresses": assigned,
+				"declined-addresses": uint64(0),
+			},
+		}
+		require.NoError(t, subnet.UpdateStatistics(db, stats))
+		// The shared network utilization stays below the threshold of the
+		// rule with the all scope.
+		require.NoError(t, dbmodel.UpdateStatisticsInSharedNetwork(db, network.ID, &testUtilization{
+			address: address / 2,
+			stats: dbmodel.SubnetStats{
+				"total-nas":    uint64(100),
+				"assigned-nas": assigned,
+			},
+		}))
+	}
+	setUtilization(0.95, 95)
+	require.NoError(t, other.UpdateStatistics(db, &testUtilization{
+		address: 0.1,
+		stats: dbmodel.SubnetStats{
+			"total-addresses":    uint64(100),
+			"assigned-addresses": uint64(10),
+		},
+	}))
+
+	subnetRule := &dbmodel.AlertRule{
+		Name:       "subnet full",
+		Enabled:    true,
+		Scope:      dbmodel.AlertScopeAll,
+		Metric:     dbmodel.AlertMetricAddressUtilization,
+		Threshold:  90,
+		Hysteresis: 5,
+		Duration:   300,
+		Level:      dbmodel.EvWarning,
+	}
+	_, err := dbmodel.AddAlertRule(db, subnetRule)
+	require.NoError(t, err)
+	networkRule := &dbmodel.AlertRule{
+		Name:            "network exhausted",
+		Enabled:         true,
+		Scope:           dbmodel.AlertScopeSharedNetwork,
+		SharedNetworkID: network.ID,
+		Metric:          dbmodel.AlertMetricFreeAddresses,
+		Threshold:       10,
+		Level:           dbmodel.EvError,
+	}
+	_, err = dbmodel.AddAlertRule(db, networkRule)
+	require.NoError(t, err)
+	_, err = dbmodel.AddAlertRule(db, &dbmodel.AlertRule{
+		Name:   "disabled",
+		Scope:  dbmodel.AlertScopeAll,
+		Metric: dbmodel.AlertMetricAddressUtilization,
+		Level:  dbmodel.EvError,
+	})
+	require.NoError(t, err)
+
+	// The subnet alert is pending until the duration elapses. The shared
+	// network alert has no duration, so it fires immediately.
+	require.NoError(t, EvaluateRules(db, fec, now))
+	states, err := dbmodel.GetAlertStatesByRuleID(db, subnetRule.ID)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	require.Equal(t, subnet.ID, states[0].ObjectID)
+	require.Equal(t, "192.0.2.0/24", states[0].ObjectName)
+	require.Equal(t, dbmodel.AlertStatePending, states[0].State)
+	require.EqualValues(t, 95, states[0].Value)
+
+	states, err = dbmodel.GetAlertStatesByRuleID(db, networkRule.ID)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	require.Equal(t, dbmodel.AlertObjectSharedNetwork, states[0].ObjectType)
+	require.Equal(t, dbmodel.AlertStateFiring, states[0].State)
+	require.EqualValues(t, 5, states[0].Value)
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvError, fec.Events[0].Level)
+	require.Equal(t, "Alert network exhausted is firing for shared network frontend: 5 free addresses", fec.Events[0].Text)
+	require.Equal(t, "threshold: 10, hysteresis: 0, duration: 0s", fec.Events[0].Details)
+
+	// The subnet alert fires after the duration.
+	require.NoError(t, EvaluateRules(db, fec, now.Add(5*time.Minute)))
+	states, err = dbmodel.GetAlertStatesByRuleID(db, subnetRule.ID)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	require.Equal(t, dbmodel.AlertStateFiring, states[0].State)
+	require.Equal(t, now, states[0].Since)
+	require.Equal(t, now.Add(5*time.Minute), states[0].FiredAt)
+
+	require.Len(t, fec.Events, 2)
+	require.Equal(t, dbmodel.EvWarning, fec.Events[1].Level)
+	require.Contains(t, fec.Events[1].Text, "Alert subnet full is firing for <subnet id=")
+	require.Contains(t, fec.Events[1].Text, "address utilization 95.0%")
+	require.Equal(t, subnet.ID, fec.Events[1].Relations.SubnetID)
+
+	// The firing alert doesn't fire again.
+	require.NoError(t, EvaluateRules(db, fec, now.Add(10*time.Minute)))
+	require.Len(t, fec.Events, 2)
+
+	// The alert isn't cleared until the value drops below the threshold
+	// adjusted by the hysteresis.
+	setUtilization(0.88, 88)
+	require.NoError(t, EvaluateRules(db, fec, now.Add(15*time.Minute)))
+	require.Len(t, fec.Events, 3)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[2].Level)
+	require.Equal(t, "Alert network exhausted cleared for shared network frontend: 12 free addresses", fec.Events[2].Text)
+	states, err = dbmodel.GetAlertStatesByRuleID(db, subnetRule.ID)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	require.EqualValues(t, 88, states[0].Value)
+
+	setUtilization(0.8, 80)
+	require.NoError(t, EvaluateRules(db, fec, now.Add(20*time.Minute)))
+	require.Len(t, fec.Events, 4)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[3].Level)
+	require.Contains(t, fec.Events[3].Text, "Alert subnet full cleared for <subnet id=")
+
+	states, err = dbmodel.GetAlertStates(db)
+	require.NoError(t, err)
+	require.Empty(t, states)
+
+	// The pending alert is removed without raising events when the value
+	// drops below the threshold before the duration elapses.
+	setUtilization(0.91, 91)
+	require.NoError(t, EvaluateRules(db, fec, now.Add(25*time.Minute)))
+	states, err = dbmodel.GetAlertStatesByRuleID(db, subnetRule.ID)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+	setUtilization(0.89, 89)
+	require.NoError(t, EvaluateRules(db, fec, now.Add(30*time.Minute)))
+	states, err = dbmodel.GetAlertStatesByRuleID(db, subnetRule.ID)
+	require.NoError(t, err)
+	require.Empty(t, states)
+	require.Len(t, fec.Events, 4)
+}
+
+// Test that the rule with the all scope checks the subnets and the shared
+// networks, and the firing alerts are cleared when their objects are gone.
+func TestEvaluateRulesAllScopeMissingObjects(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fec := &storktest.FakeEventCenter{}
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	network := &dbmodel.SharedNetwork{
+		Name:   "frontend",
+		Family: 4,
+		Subnets: []dbmodel.Subnet{
+			{Prefix: "192.0.2.0/24"},
+		},
+	}
+	require.NoError(t, dbmodel.AddSharedNetwork(db, network))
+	subnet := &network.Subnets[0]
+	utilization := &testUtilization{
+		address: 0.95,
+		stats: dbmodel.SubnetStats{
+			"total-addresses":    uint64(100),
+			"assigned-addresses": uint64(95),
+		},
+	}
+	require.NoError(t, subnet.UpdateStatistics(db, utilization))
+	require.NoError(t, dbmodel.UpdateStatisticsInSharedNetwork(db, network.ID, utilization))
+
+	rule := &dbmodel.AlertRule{
+		Name:      "full",
+		Enabled:   true,
+		Scope:     dbmodel.AlertScopeAll,
+		Metric:    dbmodel.AlertMetricAddressUtilization,
+		Threshold: 90,
+		Level:     dbmodel.EvWarning,
+	}
+	_, err := dbmodel.AddAlertRule(db, rule)
+	require.NoError(t, err)
+
+	// The alerts fire for both the subnet and the shared network.
+	require.NoError(t, EvaluateRules(db, fec, now))
+	states, err := dbmodel.GetAlertStatesByRuleID(db, rule.ID)
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+	require.Equal(t, dbmodel.AlertObjectSharedNetwork, states[0].ObjectType)
+	require.Equal(t, network.ID, states[0].ObjectID)
+	require.Equal(t, dbmodel.AlertStateFiring, states[0].State)
+	require.Equal(t, dbmodel.AlertObjectSubnet, states[1].ObjectType)
+	require.Equal(t, subnet.ID, states[1].ObjectID)
+	require.Equal(t, dbmodel.AlertStateFiring, states[1].State)
+	require.Len(t, fec.Events, 2)
+
+	// The alerts are cleared when the objects are removed.
+	require.NoError(t, dbmodel.DeleteSharedNetworkWithSubnets(db, network.ID))
+	require.NoError(t, EvaluateRules(db, fec, now.Add(time.Minute)))
+	states, err = dbmodel.GetAlertStatesByRuleID(db, rule.ID)
+	require.NoError(t, err)
+	require.Empty(t, states)
+
+	require.Len(t, fec.Events, 4)
+	texts := []string{fec.Events[2].Text, fec.Events[3].Text}
+	require.Contains(t, texts, "Alert full cleared for shared network frontend: statistics are no longer available")
+	for _, event := range fec.Events[2:] {
+		require.Equal(t, dbmodel.EvInfo, event.Level)
+		require.Contains(t, event.Text, "statistics are no longer available")
+	}
+}
@@ -2,8 +2,11 @@ package bind9
 
 import (
 	"context"
+	"fmt"
 	"regexp"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -16,6 +19,16 @@ import (
 // Provide example date format how named returns dates.
 const namedLongDateFormat = "Mon, 02 Jan 2006 15:04:05 MST"
 
+// Limits of the rndc zonestatus queries sent for the zones not reported as
+// loaded in the statistics. The number of queries per state pull is capped,
+// each query has its own timeout and all queries must fit in the total time.
+// The total time is counted separately from the time of the state pull.
+const (
+	maxZoneStatusQueries   = 50
+	zoneStatusTimeout      = 2 * time.Second
+	zoneStatusTotalTimeout = 10 * time.Second
+)
+
 // The cache statistics of the Bind9 named daemon.
 type CacheStatsData struct {
 	CacheHits   int64 `json:"CacheHits"`
@@ -29,9 +42,25 @@ type ResolverData struct {
 	CacheStats CacheStatsData `json:"cachestats"`
 }
 
+// The zone entry of the view statistics JSON structure. The serial is
+// a number for the loaded zones, but some BIND 9 versions return a
+// string for the zones which are not loaded. The times are in the
+// ISO 8601 format. The refresh and expiry times are returned for the
+// secondary zones only.
+type ZoneData struct {
+	Name    string      `json:"name"`
+	Class   string      `json:"class"`
+	Serial  interface{} `json:"serial"`
+	Type    string      `json:"type"`
+	Loaded  string      `json:"loaded"`
+	Expires string      `json:"expires"`
+	Refresh string      `json:"refresh"`
+}
+
 // The view statistics data JSON structure.
 type ViewStatsData struct {
 	Resolver ResolverData `json:"resolver"`
+	Zones    []*ZoneData  `json:"zones"`
 }
 
 // JSON Structure of response returned by the named Bind 9 daemon on fetching
@@ -40,13 +69,21 @@ type NamedStatsGetResponse struct {
 	Views map[string]*ViewStatsData `json:"views,omitempty"`
 }
 
+// Holds the BIND 9 app state fetched from the daemon which is not stored
+// in the app structure.
+type AppStateMeta struct {
+	Zones []*dbmodel.Bind9Zone
+}
+
 // Get statistics from named daemon using ForwardToNamedStats function.
-func GetAppStatistics(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *dbmodel.App) {
+// It returns the view statistics containing the zones served by the daemon.
+// It returns an error if the statistics cannot be fetched.
+func GetAppStatistics(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *dbmodel.App) (map[string]*ViewStatsData, error) {
 	// prepare URL to named
 	statsChannel, err := dbApp.GetAccessPoint(dbmodel.AccessPointStatistics)
 	if err != nil {
 		log.Warnf("Problem getting named statistics-channel access point: %s", err)
-		return
+		return nil, err
 	}
 
 	ctx2, cancel := context.WithTimeout(ctx, 2*time.Second)
@@ -57,6 +94,8 @@ func GetAppStatistics(ctx context.Context, agents agentcomm.ConnectedAgents, dbA
 	err = agents.ForwardToNamedStats(ctx2, dbApp.Machine.Address, dbApp.Machine.AgentPort, statsChannel.Address, statsChannel.Port, "json/v1", &statsOutput)
 	if err != nil {
 		log.Warnf("Problem retrieving stats from named: %s", err)
+		dbApp.Daemons[0].Bind9Daemon.Stats.NamedStats = &dbmodel.Bind9NamedStats{}
+		return nil, err
 	}
 
 	namedStats := &dbmodel.Bind9NamedStats{}
@@ -88,12 +127,170 @@ func GetAppStatistics(ctx context.Context, agents agentcomm.ConnectedAgents, dbA
 	}
 
 	dbApp.Daemons[0].Bind9Daemon.Stats.NamedStats = namedStats
+	return statsOutput.Views, nil
+}
+
+// Converts the zone type returned by named to the current naming.
+func normalizeZoneType(zoneType string) string {
+	zoneType = strings.ToLower(zoneType)
+	switch zoneType {
+	case "master":
+		return dbmodel.Bind9ZoneTypePrimary
+	case "slave":
+		return dbmodel.Bind9ZoneTypeSecondary
+	default:
+		return zoneType
+	}
+}
+
+// Parses the time returned in the zone entry of the statistics. It returns
+// zero time if the time is not specified or invalid.
+func parseZoneTime(value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Warnf("Cannot parse BIND 9 zone time %s: %s", value, err)
+		return time.Time{}
+	}
+	return parsed.UTC()
+}
+
+// Converts the zone entries of the statistics to the zones stored in the
+// database. The built-in zones are skipped. The zone is considered loaded
+// if the statistics contain its serial and load time.
+func getZonesFromStats(views map[string]*ViewStatsData) []*dbmodel.Bind9Zone {
+	var zones []*dbmodel.Bind9Zone
+	for viewName, view := range views {
+		if view == nil {
+			continue
+		}
+		for _, zoneData := range view.Zones {
+			if zoneData == nil || zoneData.Name == "" {
+				continue
+			}
+			zoneType := normalizeZoneType(zoneData.Type)
+			if zoneType == "builtin" {
+				continue
+			}
+			zone := &dbmodel.Bind9Zone{
+				View:      viewName,
+				Name:      zoneData.Name,
+				Class:     zoneData.Class,
+				Type:      zoneType,
+				LoadedAt:  parseZoneTime(zoneData.Loaded),
+				RefreshAt: parseZoneTime(zoneData.Refresh),
+				ExpiresAt: parseZoneTime(zoneData.Expires),
+			}
+			if zone.Class == "" {
+				zone.Class = "IN"
+			}
+			if serial, ok := zoneData.Serial.(float64); ok && serial >= 0 {
+				zone.Serial = int64(serial)
+				zone.Loaded = !zone.LoadedAt.IsZero()
+			}
+			zones = append(zones, zone)
+		}
+	}
+	// Make the order deterministic.
+	sort.Slice(zones, func(i, j int) bool {
+		if zones[i].View != zones[j].View {
+			return zones[i].View < zones[j].View
+		}
+		if zones[i].Name != zones[j].Name {
+			return zones[i].Name < zones[j].Name
+		}
+		return zones[i].Class < zones[j].Class
+	})
+	return zones
+}
+
+// Parses the output of the rndc zonestatus command and updates the zone.
+// The zone is marked as loaded if the output contains the serial.
+func parseZoneStatus(output string, zone *dbmodel.Bind9Zone) {
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+		switch key {
+		case "type":
+			zone.Type = normalizeZoneType(value)
+		case "serial":
+			serial, err := strconv.ParseInt(value, 10, 64)
+			if err == nil {
+				zone.Serial = serial
+				zone.Loaded = true
+			}
+		case "last loaded":
+			if t, err := time.Parse(namedLongDateFormat, value); err == nil {
+				zone.LoadedAt = t.UTC()
+			}
+		case "next refresh":
+			if t, err := time.Parse(namedLongDateFormat, value); err == nil {
+				zone.RefreshAt = t.UTC()
+			}
+		case "expires":
+			if t, err := time.Parse(namedLongDateFormat, value); err == nil {
+				zone.ExpiresAt = t.UTC()
+			}
+		}
+	}
+}
+
+// Uses the rndc zonestatus command to fetch the state of the zones which
+// are not reported as loaded in the statistics. The zones for which the
+// command fails are marked as not loaded. At most maxZoneStatusQueries
+// zones are queried and the remaining ones are left not loaded. It should
+// be called after the state pull with a context which is not bound by the
+// state pull timeout. It does nothing if the state is nil.
+func GetZonesStatus(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *dbmodel.App, state *AppStateMeta) {
+	if state == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, zoneStatusTotalTimeout)
+	defer cancel()
+
+	queries := 0
+	for _, zone := range state.Zones {
+		if zone.Loaded {
+			continue
+		}
+		if queries >= maxZoneStatusQueries {
+			log.Warnf("Skipped checking status of some BIND 9 zones: limit of %d zonestatus queries reached", maxZoneStatusQueries)
+			return
+		}
+		if ctx.Err() != nil {
+			log.Warnf("Skipped checking status of some BIND 9 zones: %s", ctx.Err())
+			return
+		}
+		queries++
+		command := fmt.Sprintf("zonestatus %s %s %s", zone.Name, zone.Class, zone.View)
+		ctx2, cancel2 := context.WithTimeout(ctx, zoneStatusTimeout)
+		out, err := agents.ForwardRndcCommand(ctx2, dbApp, command)
+		cancel2()
+		if err != nil {
+			log.Warnf("Problem getting BIND 9 zone %s status: %s", zone.Name, err)
+			continue
+		}
+		if out == nil || out.Error != nil {
+			continue
+		}
+		parseZoneStatus(out.Output, zone)
+	}
 }
 
 // Get state of named daemon using ForwardRndcCommand function.
 // The state that is stored into dbApp includes: version, number of zones, and
-// some runtime state.
-func GetAppState(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *dbmodel.App, eventCenter eventcenter.EventCenter) {
+// some runtime state. The zones served by the daemon are returned in the
+// state meta; the status of the zones not reported as loaded in the
+// statistics is fetched separately with GetZonesStatus. It returns nil state if the statistics cannot be fetched so
+// the zones stored in the database are left untouched.
+func GetAppState(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *dbmodel.App, eventCenter eventcenter.EventCenter) *AppStateMeta {
 	ctx2, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
@@ -101,7 +298,7 @@ func GetAppState(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *d
 	out, err := agents.ForwardRndcCommand(ctx2, dbApp, command)
 	if err != nil {
 		log.Warnf("Problem getting BIND 9 status: %s", err)
-		return
+		return nil
 	}
 
 	bind9Daemon := dbmodel.NewBind9Daemon(false)
@@ -176,18 +373,31 @@ func GetAppState(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *d
 	}
 
 	// Get statistics
-	GetAppStatistics(ctx, agents, dbApp)
+	views, err := GetAppStatistics(ctx, agents, dbApp)
+	if err != nil {
+		return nil
+	}
+
+	// Get zones
+	state := &AppStateMeta{
+		Zones: getZonesFromStats(views),
+	}
+
+	return state
 }
 
-// Inserts or updates information about BIND 9 app in the database.
-func CommitAppIntoDB(db *dbops.PgDB, app *dbmodel.App, eventCenter eventcenter.EventCenter) (err error) {
+// Inserts or updates information about BIND 9 app in the database. If the
+// state is specified, the zones served by the daemon are replaced with the
+// zones from the state.
+func CommitAppIntoDB(db *dbops.PgDB, app *dbmodel.App, eventCenter eventcenter.EventCenter, state *AppStateMeta) (err error) {
 	if app.ID == 0 {
 		_, err = dbmodel.AddApp(db, app)
 		eventCenter.AddInfoEvent("added {app}", app.Machine, app)
 	} else {
 		_, _, err = dbmodel.UpdateApp(db, app)
 	}
-	// todo: perform any additional actions required after storing the
-	// app in the db.
-	return err
+	if err != nil || state == nil || len(app.Daemons) == 0 {
+		return err
+	}
+	return dbmodel.CommitBind9Zones(db, app.Daemons[0].ID, state.Zones)
 }
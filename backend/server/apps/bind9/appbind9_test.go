@@ -2,6 +2,7 @@ package bind9
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -112,13 +113,13 @@ func TestCommitAppIntoDB(t *testing.T) {
 		Active:       true,
 		AccessPoints: accessPoints,
 	}
-	err = CommitAppIntoDB(db, app, fec)
+	err = CommitAppIntoDB(db, app, fec, nil)
 	require.NoError(t, err)
 
 	accessPoints = []*dbmodel.AccessPoint{}
 	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "", "", 2345, false)
 	app.AccessPoints = accessPoints
-	err = CommitAppIntoDB(db, app, fec)
+	err = CommitAppIntoDB(db, app, fec, nil)
 	require.NoError(t, err)
 
 	returned, err := dbmodel.GetAppByID(db, app.ID)
@@ -127,3 +128,255 @@ func TestCommitAppIntoDB(t *testing.T) {
 	require.Len(t, returned.AccessPoints, 1)
 	require.EqualValues(t, 2345, returned.AccessPoints[0].Port)
 }
+
+// Named statistics-channel response including the zones.
+func mockNamedWithZones(callNo int, response interface{}) {
+	statsOutput := response.(*NamedStatsGetResponse)
+	*statsOutput = NamedStatsGetResponse{
+		Views: map[string]*ViewStatsData{
+			"_default": {
+				Zones: []*ZoneData{
+					{
+						Name:   "example.com",
+						Class:  "IN",
+						Serial: float64(2023010101),
+						Type:   "master",
+						Loaded: "2023-01-01T10:00:00Z",
+					},
+					{
+						Name:    "example.org",
+						Class:   "IN",
+						Serial:  float64(2023010102),
+						Type:    "secondary",
+						Loaded:  "2023-01-01T11:00:00Z",
+						Refresh: "2023-01-01T12:00:00Z",
+						Expires: "2023-01-08T11:00:00Z",
+					},
+					{
+						Name:   "example.net",
+						Class:  "IN",
+						Serial: "-",
+						Type:   "slave",
+					},
+				},
+			},
+			"_bind": {
+				Zones: []*ZoneData{
+					{
+						Name:  "authors.bind",
+						Class: "CH",
+						Type:  "builtin",
+					},
+				},
+			},
+		},
+	}
+}
+
+// Test that the zone types are converted to the current naming.
+func TestNormalizeZoneType(t *testing.T) {
+	require.Equal(t, "primary", normalizeZoneType("master"))
+	require.Equal(t, "primary", normalizeZoneType("primary"))
+	require.Equal(t, "secondary", normalizeZoneType("slave"))
+	require.Equal(t, "secondary", normalizeZoneType("Secondary"))
+	require.Equal(t, "forward", normalizeZoneType("forward"))
+}
+
+// Test that the zones are extracted from the statistics.
+func TestGetZonesFromStats(t *testing.T) {
+	response := &NamedStatsGetResponse{}
+	mockNamedWithZones(0, response)
+
+	zones := getZonesFromStats(response.Views)
+	require.Len(t, zones, 3)
+
+	require.Equal(t, "_default", zones[0].View)
+	require.Equal(t, "example.com", zones[0].Name)
+	require.Equal(t, "IN", zones[0].Class)
+	require.Equal(t, "primary", zones[0].Type)
+	require.EqualValues(t, 2023010101, zones[0].Serial)
+	require.True(t, zones[0].Loaded)
+	require.Equal(t, time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC), zones[0].LoadedAt)
+	require.Zero(t, zones[0].RefreshAt)
+	require.Zero(t, zones[0].ExpiresAt)
+
+	require.Equal(t, "example.net", zones[1].Name)
+	require.Equal(t, "secondary", zones[1].Type)
+	require.False(t, zones[1].Loaded)
+	require.Zero(t, zones[1].Serial)
+
+	require.Equal(t, "example.org", zones[2].Name)
+	require.Equal(t, "secondary", zones[2].Type)
+	require.True(t, zones[2].Loaded)
+	require.Equal(t, time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC), zones[2].RefreshAt)
+	require.Equal(t, time.Date(2023, 1, 8, 11, 0, 0, 0, time.UTC), zones[2].ExpiresAt)
+
+	require.Empty(t, getZonesFromStats(nil))
+}
+
+// Test parsing the output of the rndc zonestatus command.
+func TestParseZoneStatus(t *testing.T) {
+	output := `name: example.net
+type: secondary
+files: example.net.db
+serial: 2023010103
+nodes: 5
+last loaded: Sun, 01 Jan 2023 09:00:00 GMT
+next refresh: Sun, 01 Jan 2023 13:00:00 GMT
+expires: Sun, 08 Jan 2023 09:00:00 GMT
+secure: no
+dynamic: no`
+	zone := &dbmodel.Bind9Zone{Name: "example.net"}
+	parseZoneStatus(output, zone)
+
+	require.Equal(t, "secondary", zone.Type)
+	require.EqualValues(t, 2023010103, zone.Serial)
+	require.True(t, zone.Loaded)
+	require.Equal(t, time.Date(2023, 1, 1, 9, 0, 0, 0, time.UTC), zone.LoadedAt)
+	require.Equal(t, time.Date(2023, 1, 1, 13, 0, 0, 0, time.UTC), zone.RefreshAt)
+	require.Equal(t, time.Date(2023, 1, 8, 9, 0, 0, 0, time.UTC), zone.ExpiresAt)
+
+	// The zone is not loaded.
+	zone = &dbmodel.Bind9Zone{Name: "example.net"}
+	parseZoneStatus("rndc: 'zonestatus' failed: not loaded", zone)
+	require.False(t, zone.Loaded)
+}
+
+// Test that the zones are returned in the BIND 9 app state and the zones
+// not reported as loaded in the statistics are later checked with rndc.
+func TestGetAppStateZones(t *testing.T) {
+	ctx := context.Background()
+
+	fa := agentcommtest.NewFakeAgents(nil, mockNamedWithZones)
+	fa.SetRndcCommandOutput("zonestatus example.net IN _default", `name: example.net
+type: secondary
+serial: 2023010103
+last loaded: Sun, 01 Jan 2023 09:00:00 GMT`)
+	fec := &storktest.FakeEventCenter{}
+
+	var accessPoints []*dbmodel.AccessPoint
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "127.0.0.1", "abcd", 953, false)
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointStatistics, "127.0.0.1", "abcd", 8000, false)
+	dbApp := dbmodel.App{
+		AccessPoints: accessPoints,
+		Machine: &dbmodel.Machine{
+			Address:   "192.0.2.0",
+			AgentPort: 1111,
+		},
+	}
+
+	state := GetAppState(ctx, fa, &dbApp, fec)
+	require.NotNil(t, state)
+	require.Len(t, state.Zones, 3)
+	require.Equal(t, "example.net", state.Zones[1].Name)
+	require.False(t, state.Zones[1].Loaded)
+
+	GetZonesStatus(ctx, fa, &dbApp, state)
+	require.True(t, state.Zones[1].Loaded)
+	require.EqualValues(t, 2023010103, state.Zones[1].Serial)
+}
+
+// Test that no zones are returned in the BIND 9 app state when the
+// statistics cannot be fetched, so the stored zones are not replaced.
+func TestGetAppStateZonesNoStatistics(t *testing.T) {
+	ctx := context.Background()
+
+	fa := agentcommtest.NewFakeAgents(nil, mockNamedWithZones)
+	fec := &storktest.FakeEventCenter{}
+
+	var accessPoints []*dbmodel.AccessPoint
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "127.0.0.1", "abcd", 953, false)
+	dbApp := dbmodel.App{
+		AccessPoints: accessPoints,
+		Machine: &dbmodel.Machine{
+			Address:   "192.0.2.0",
+			AgentPort: 1111,
+		},
+	}
+
+	state := GetAppState(ctx, fa, &dbApp, fec)
+	require.Nil(t, state)
+	require.True(t, dbApp.Active)
+	require.Empty(t, fa.RecordedStatsURL)
+}
+
+// Test that fetching the zones status of a nil state does nothing.
+func TestGetZonesStatusNilState(t *testing.T) {
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	GetZonesStatus(context.Background(), fa, &dbmodel.App{}, nil)
+	require.Empty(t, fa.RecordedCommand)
+}
+
+// Test that the number of the rndc zonestatus queries is limited.
+func TestGetZonesStatusLimit(t *testing.T) {
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+
+	var accessPoints []*dbmodel.AccessPoint
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "127.0.0.1", "abcd", 953, false)
+	dbApp := &dbmodel.App{
+		AccessPoints: accessPoints,
+	}
+
+	var zones []*dbmodel.Bind9Zone
+	for i := 0; i < maxZoneStatusQueries+10; i++ {
+		zones = append(zones, &dbmodel.Bind9Zone{
+			View:  "_default",
+			Name:  fmt.Sprintf("zone%d.example.org", i),
+			Class: "IN",
+		})
+	}
+	GetZonesStatus(context.Background(), fa, dbApp, &AppStateMeta{Zones: zones})
+
+	// The last queried zone is the last one within the limit.
+	require.Equal(t, fmt.Sprintf("zonestatus zone%d.example.org IN _default", maxZoneStatusQueries-1), fa.RecordedCommand)
+}
+
+// Test that the zones are committed with the BIND 9 app and replaced on
+// subsequent updates.
+func TestCommitAppIntoDBZones(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fec := &storktest.FakeEventCenter{}
+
+	machine := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, machine)
+	require.NoError(t, err)
+
+	var accessPoints []*dbmodel.AccessPoint
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "", "", 953, false)
+	app := &dbmodel.App{
+		MachineID:    machine.ID,
+		Machine:      machine,
+		Type:         dbmodel.AppTypeBind9,
+		Active:       true,
+		AccessPoints: accessPoints,
+		Daemons: []*dbmodel.Daemon{
+			dbmodel.NewBind9Daemon(true),
+		},
+	}
+	state := &AppStateMeta{
+		Zones: []*dbmodel.Bind9Zone{
+			{View: "_default", Name: "example.com", Class: "IN", Type: "primary", Serial: 1, Loaded: true},
+			{View: "_default", Name: "example.org", Class: "IN", Type: "secondary", Serial: 2, Loaded: true},
+		},
+	}
+	err = CommitAppIntoDB(db, app, fec, state)
+	require.NoError(t, err)
+
+	zones, err := dbmodel.GetBind9ZonesByDaemonID(db, app.Daemons[0].ID)
+	require.NoError(t, err)
+	require.Len(t, zones, 2)
+
+	state.Zones = state.Zones[1:]
+	err = CommitAppIntoDB(db, app, fec, state)
+	require.NoError(t, err)
+
+	zones, err = dbmodel.GetBind9ZonesByDaemonID(db, app.Daemons[0].ID)
+	require.NoError(t, err)
+	require.Len(t, zones, 1)
+	require.Equal(t, "example.org", zones[0].Name)
+}
@@ -0,0 +1,209 @@
+package bind9
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	log "github.com/sirupsen/logrus"
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+)
+
+// Name of the setting holding the time (in seconds) after which the
+// secondary zone which is behind the primary or hasn't been refreshed
+// on time is reported.
+const ZoneLagThresholdSettingName = "bind9_zone_lag_threshold"
+
+// Statuses of the secondary zones determined by comparing them with
+// the primary zones.
+const (
+	ZoneDriftStatusOK        = "ok"
+	ZoneDriftStatusLagging   = "lagging"
+	ZoneDriftStatusStale     = "stale"
+	ZoneDriftStatusExpired   = "expired"
+	ZoneDriftStatusNotLoaded = "not-loaded"
+)
+
+// Describes the state of the secondary zone against its primary. The
+// primary serial is the highest serial of the zone with the same name,
+// class and view among the primary zones monitored by Stork. It is only
+// set when such a primary zone is monitored. The serial lag is a number of the
+// serial increments the secondary zone is behind the primary zone. The
+// lagging since time is the time when the primary zone was loaded with
+// the serial the secondary zone hasn't caught up with.
+type ZoneDrift struct {
+	PrimarySerial int64
+	HasPrimary    bool
+	SerialLag     int64
+	LaggingSince  time.Time
+	Status        string
+}
+
+// Returns the number of increments between the serial and the primary
+// serial using the serial number arithmetic (RFC 1982). It returns zero
+// if the serial is equal or ahead of the primary serial.
+func getSerialLag(serial, primarySerial int64) int64 {
+	lag := (uint32(primarySerial) - uint32(serial))
+	if lag == 0 || lag >= 1<<31 {
+		return 0
+	}
+	return int64(lag)
+}
+
+// Returns a key grouping the same zones served by different servers.
+func getZoneGroupKey(zone *dbmodel.Bind9Zone) string {
+	return fmt.Sprintf("%s/%s/%s", zone.Name, zone.Class, zone.View)
+}
+
+// Compares the secondary zones with the primary zones having the same
+// name, class and view. It returns the drift of each secondary zone
+// indexed by the zone ID. The secondary zone is lagging when its serial
+// is behind the primary serial for longer than the threshold. It is
+// stale when it hasn't been refreshed at the scheduled time and the
+// threshold elapsed since then.
+func GetZonesDrift(zones []dbmodel.Bind9Zone, threshold time.Duration, now time.Time) map[int64]*ZoneDrift {
+	// Find the primary zone with the highest serial in each group.
+	primaries := make(map[string]*dbmodel.Bind9Zone)
+	for i := range zones {
+		zone := &zones[i]
+		if zone.Type != dbmodel.Bind9ZoneTypePrimary || !zone.Loaded {
+			continue
+		}
+		key := getZoneGroupKey(zone)
+		if primary, ok := primaries[key]; !ok || getSerialLag(primary.Serial, zone.Serial) > 0 {
+			primaries[key] = zone
+		}
+	}
+
+	drifts := make(map[int64]*ZoneDrift)
+	for i := range zones {
+		zone := &zones[i]
+		if zone.Type != dbmodel.Bind9ZoneTypeSecondary {
+			continue
+		}
+		drift := &ZoneDrift{
+			Status: ZoneDriftStatusOK,
+		}
+		drifts[zone.ID] = drift
+
+		if primary, ok := primaries[getZoneGroupKey(zone)]; ok {
+			drift.HasPrimary = true
+			drift.PrimarySerial = primary.Serial
+			if zone.Loaded {
+				drift.SerialLag = getSerialLag(zone.Serial, primary.Serial)
+			}
+			if drift.SerialLag > 0 {
+				drift.LaggingSince = primary.LoadedAt
+			}
+		}
+
+		switch {
+		case !zone.Loaded:
+			drift.Status = ZoneDriftStatusNotLoaded
+		case !zone.ExpiresAt.IsZero() && now.After(zone.ExpiresAt):
+			drift.Status = ZoneDriftStatusExpired
+		case drift.SerialLag > 0 && (drift.LaggingSince.IsZero() || now.Sub(drift.LaggingSince) > threshold):
+			drift.Status = ZoneDriftStatusLagging
+		case !zone.RefreshAt.IsZero() && now.Sub(zone.RefreshAt) > threshold:
+			drift.Status = ZoneDriftStatusStale
+		}
+	}
+	return drifts
+}
+
+// The checker periodically comparing the secondary zones with the
+// primary zones and raising events when the secondary zones lag behind
+// the primaries or fail to refresh.
+type ZoneDriftChecker struct {
+	*agentcomm.PeriodicPuller
+	EventCenter eventcenter.EventCenter
+	// Recent statuses of the secondary zones indexed by the app ID,
+	// view, zone name and class. The daemon ID is not used because it
+	// may change when the app state is pulled.
+	statuses map[string]string
+}
+
+// Creates a checker comparing the serials of the zones fetched from the
+// BIND 9 servers.
+func NewZoneDriftChecker(db *pg.DB, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter) (*ZoneDriftChecker, error) {
+	checker := &ZoneDriftChecker{
+		EventCenter: eventCenter,
+		statuses:    make(map[string]string),
+	}
+	periodicPuller, err := agentcomm.NewPeriodicPuller(db, agents, "BIND 9 zone drift checker", "bind9_zone_drift_checker_interval",
+		checker.checkZones)
+	if err != nil {
+		return nil, err
+	}
+	checker.PeriodicPuller = periodicPuller
+	return checker, nil
+}
+
+// Shutdown ZoneDriftChecker. It stops goroutine that checks the zones.
+func (checker *ZoneDriftChecker) Shutdown() {
+	checker.PeriodicPuller.Shutdown()
+}
+
+// Returns the event text describing the secondary zone status.
+func getZoneDriftEventText(zone *dbmodel.Bind9Zone, drift *ZoneDrift) string {
+	zoneText := fmt.Sprintf("Secondary zone %s/%s in view %s served by {daemon}", zone.Name, zone.Class, zone.View)
+	switch drift.Status {
+	case ZoneDriftStatusNotLoaded:
+		return fmt.Sprintf("%s is not loaded", zoneText)
+	case ZoneDriftStatusExpired:
+		return fmt.Sprintf("%s has expired", zoneText)
+	case ZoneDriftStatusLagging:
+		return fmt.Sprintf("%s has serial %d which is %d serial(s) behind the primary serial %d",
+			zoneText, zone.Serial, drift.SerialLag, drift.PrimarySerial)
+	case ZoneDriftStatusStale:
+		return fmt.Sprintf("%s has not been refreshed since %s", zoneText, zone.RefreshAt.Format(time.RFC3339))
+	default:
+		return fmt.Sprintf("%s is in sync with the primary", zoneText)
+	}
+}
+
+// Compares the zones and raises the events for the secondary zones whose
+// status has changed since the last check.
+func (checker *ZoneDriftChecker) checkZones() error {
+	threshold, err := dbmodel.GetSettingInt(checker.DB, ZoneLagThresholdSettingName)
+	if err != nil {
+		return err
+	}
+	zones, err := dbmodel.GetAllBind9Zones(checker.DB)
+	if err != nil {
+		return err
+	}
+	drifts := GetZonesDrift(zones, time.Duration(threshold)*time.Second, time.Now().UTC())
+
+	statuses := make(map[string]string)
+	problemsCnt := 0
+	for i := range zones {
+		zone := &zones[i]
+		drift, ok := drifts[zone.ID]
+		if !ok || zone.Daemon == nil || zone.Daemon.App == nil {
+			continue
+		}
+		key := fmt.Sprintf("%d/%s", zone.Daemon.AppID, getZoneGroupKey(zone))
+		statuses[key] = drift.Status
+		if drift.Status != ZoneDriftStatusOK {
+			problemsCnt++
+		}
+
+		previousStatus, known := checker.statuses[key]
+		if previousStatus == drift.Status || (!known && drift.Status == ZoneDriftStatusOK) {
+			continue
+		}
+		text := getZoneDriftEventText(zone, drift)
+		if drift.Status == ZoneDriftStatusOK {
+			checker.EventCenter.AddInfoEvent(text, zone.Daemon)
+		} else {
+			checker.EventCenter.AddWarningEvent(text, zone.Daemon)
+		}
+	}
+	checker.statuses = statuses
+
+	log.Printf("Completed checking BIND 9 secondary zones: %d/%d not in sync", problemsCnt, len(drifts))
+	return nil
+}
@@ -0,0 +1,185 @@
+package bind9
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Test that the serial lag is calculated using the serial number arithmetic.
+func TestGetSerialLag(t *testing.T) {
+	require.Zero(t, getSerialLag(5, 5))
+	require.EqualValues(t, 2, getSerialLag(3, 5))
+	require.Zero(t, getSerialLag(5, 3))
+	// The primary serial wrapped around.
+	require.EqualValues(t, 3, getSerialLag(4294967295, 2))
+	require.Zero(t, getSerialLag(2, 4294967295))
+}
+
+// Test that the secondary zones are compared with the primary zones.
+func TestGetZonesDrift(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	threshold := 10 * time.Minute
+	zones := []dbmodel.Bind9Zone{
+		// Primary zone loaded an hour ago.
+		{ID: 1, View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary, Serial: 10, Loaded: true, LoadedAt: now.Add(-time.Hour)},
+		// Secondary zone in sync.
+		{ID: 2, View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypeSecondary, Serial: 10, Loaded: true, RefreshAt: now.Add(time.Hour)},
+		// Secondary zone behind the primary longer than the threshold.
+		{ID: 3, View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypeSecondary, Serial: 8, Loaded: true, RefreshAt: now.Add(time.Hour)},
+		// Secondary zone in other view without primary and not refreshed.
+		{ID: 4, View: "external", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypeSecondary, Serial: 8, Loaded: true, RefreshAt: now.Add(-time.Hour)},
+		// Secondary zone which has expired.
+		{ID: 5, View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypeSecondary, Serial: 8, Loaded: true, ExpiresAt: now.Add(-time.Minute)},
+		// Secondary zone which is not loaded.
+		{ID: 6, View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypeSecondary},
+		// Primary zone recently loaded.
+		{ID: 7, View: "_default", Name: "example.org", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary, Serial: 3, Loaded: true, LoadedAt: now.Add(-time.Minute)},
+		// Secondary zone behind the primary shorter than the threshold.
+		{ID: 8, View: "_default", Name: "example.org", Class: "IN", Type: dbmodel.Bind9ZoneTypeSecondary, Serial: 2, Loaded: true},
+	}
+
+	drifts := GetZonesDrift(zones, threshold, now)
+	require.Len(t, drifts, 6)
+	require.NotContains(t, drifts, int64(1))
+	require.NotContains(t, drifts, int64(7))
+
+	require.Equal(t, ZoneDriftStatusOK, drifts[2].Status)
+	require.True(t, drifts[2].HasPrimary)
+	require.EqualValues(t, 10, drifts[2].PrimarySerial)
+	require.Zero(t, drifts[2].SerialLag)
+	require.Zero(t, drifts[2].LaggingSince)
+
+	require.Equal(t, ZoneDriftStatusLagging, drifts[3].Status)
+	require.EqualValues(t, 2, drifts[3].SerialLag)
+	require.Equal(t, now.Add(-time.Hour), drifts[3].LaggingSince)
+
+	require.Equal(t, ZoneDriftStatusStale, drifts[4].Status)
+	require.False(t, drifts[4].HasPrimary)
+	require.Zero(t, drifts[4].SerialLag)
+
+	require.Equal(t, ZoneDriftStatusExpired, drifts[5].Status)
+	require.Equal(t, ZoneDriftStatusNotLoaded, drifts[6].Status)
+	require.Zero(t, drifts[6].SerialLag)
+
+	require.Equal(t, ZoneDriftStatusOK, drifts[8].Status)
+	require.EqualValues(t, 1, drifts[8].SerialLag)
+}
+
+// Test that the highest serial among the primary zones is used for
+// the comparison.
+func TestGetZonesDriftMultiplePrimaries(t *testing.T) {
+	zones := []dbmodel.Bind9Zone{
+		{ID: 1, View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary, Serial: 4, Loaded: true},
+		{ID: 2, View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary, Serial: 6, Loaded: true},
+		{ID: 3, View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary, Serial: 100},
+		{ID: 4, View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypeSecondary, Serial: 5, Loaded: true},
+	}
+
+	drifts := GetZonesDrift(zones, time.Minute, time.Now())
+	require.Len(t, drifts, 1)
+	require.EqualValues(t, 6, drifts[4].PrimarySerial)
+	require.EqualValues(t, 1, drifts[4].SerialLag)
+	require.Equal(t, ZoneDriftStatusLagging, drifts[4].Status)
+}
+
+// Check creating and shutting down ZoneDriftChecker.
+func TestZoneDriftCheckerBasic(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := dbmodel.InitializeSettings(db, 0)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+
+	checker, err := NewZoneDriftChecker(db, fa, fec)
+	require.NoError(t, err)
+	require.NotNil(t, checker)
+	checker.Shutdown()
+}
+
+// Test that the events are raised when the secondary zone status changes.
+func TestZoneDriftCheckerCheckZones(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := dbmodel.InitializeSettings(db, 0)
+	require.NoError(t, err)
+
+	addApp := func(address string) *dbmodel.App {
+		m := &dbmodel.Machine{
+			Address:   address,
+			AgentPort: 8080,
+		}
+		err := dbmodel.AddMachine(db, m)
+		require.NoError(t, err)
+		app := &dbmodel.App{
+			MachineID: m.ID,
+			Type:      dbmodel.AppTypeBind9,
+			Active:    true,
+			Daemons: []*dbmodel.Daemon{
+				dbmodel.NewBind9Daemon(true),
+			},
+		}
+		_, err = dbmodel.AddApp(db, app)
+		require.NoError(t, err)
+		return app
+	}
+	primaryApp := addApp("primary")
+	secondaryApp := addApp("secondary")
+
+	err = dbmodel.CommitBind9Zones(db, primaryApp.Daemons[0].ID, []*dbmodel.Bind9Zone{
+		{View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary, Serial: 7, Loaded: true},
+	})
+	require.NoError(t, err)
+	err = dbmodel.CommitBind9Zones(db, secondaryApp.Daemons[0].ID, []*dbmodel.Bind9Zone{
+		{View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypeSecondary, Serial: 7, Loaded: true},
+	})
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	checker, err := NewZoneDriftChecker(db, fa, fec)
+	require.NoError(t, err)
+	defer checker.Shutdown()
+
+	// The zone is in sync. No event should be raised.
+	err = checker.checkZones()
+	require.NoError(t, err)
+	require.Empty(t, fec.Events)
+
+	// The secondary zone falls behind the primary zone.
+	err = dbmodel.CommitBind9Zones(db, primaryApp.Daemons[0].ID, []*dbmodel.Bind9Zone{
+		{View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary, Serial: 9, Loaded: true},
+	})
+	require.NoError(t, err)
+	err = checker.checkZones()
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "2 serial(s) behind the primary serial 9")
+	require.EqualValues(t, secondaryApp.ID, fec.Events[0].Relations.AppID)
+
+	// The status hasn't changed so no new event is raised.
+	err = checker.checkZones()
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 1)
+
+	// The secondary zone catches up.
+	err = dbmodel.CommitBind9Zones(db, secondaryApp.Daemons[0].ID, []*dbmodel.Bind9Zone{
+		{View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypeSecondary, Serial: 9, Loaded: true},
+	})
+	require.NoError(t, err)
+	err = checker.checkZones()
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 2)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[1].Level)
+	require.Contains(t, fec.Events[1].Text, "is in sync with the primary")
+}
@@ -0,0 +1,273 @@
+package kea
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	keactrl "isc.org/stork/appctrl/kea"
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Names of the actions controlling the HA service. Each action is
+// translated to a Kea command sent to one of the HA peers.
+const (
+	// Puts the selected server in the in-maintenance state. The
+	// ha-maintenance-start command is sent to its partner.
+	HAActionMaintenanceStart = "maintenance-start"
+	// Cancels the maintenance of the selected server. The
+	// ha-maintenance-cancel command is sent to its partner.
+	HAActionMaintenanceCancel = "maintenance-cancel"
+	// Configures the scopes served by the selected server using the
+	// ha-scopes command.
+	HAActionScopes = "scopes"
+	// Resumes the paused state machine of the selected server using the
+	// ha-continue command.
+	HAActionContinue = "continue"
+	// Synchronizes the leases of the selected server with its partner
+	// using the ha-sync command.
+	HAActionSync = "sync"
+	// Notifies the partner of the selected server that the lease
+	// synchronization is complete using the ha-sync-complete-notify
+	// command.
+	HAActionSyncCompleteNotify = "sync-complete-notify"
+)
+
+// Default maximum period (in seconds) the DHCP service of the partner is
+// paused during the lease synchronization.
+const defaultHASyncMaxPeriod = 60
+
+// Describes an action requested for the HA service. The daemon is the
+// server the action concerns. Depending on the action, the command is
+// sent to this server or to its partner. The scopes are used by the
+// scopes action and the maximum period (in seconds) by the sync action.
+type HAAction struct {
+	Name      string
+	DaemonID  int64
+	Scopes    []string
+	MaxPeriod int64
+}
+
+// The command to be sent to the HA peer to perform the requested action.
+type HAActionCommand struct {
+	Daemon  *dbmodel.Daemon
+	Command *keactrl.Command
+}
+
+// Checks if the HA server serves the DHCP clients in a normal way.
+func isNormalOperationHAState(state dbmodel.HAState) bool {
+	switch state {
+	case dbmodel.HAStateLoadBalancing, dbmodel.HAStateHotStandby:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the name of the daemon's partner in the HA relationship of the
+// daemon corresponding to the service. It returns an empty string if the
+// name cannot be found.
+func getHAPartnerName(daemon *dbmodel.Daemon, haService *dbmodel.BaseHAService) string {
+	config := getHARelationshipConfig(daemon, haService)
+	if config == nil || !config.IsValid() {
+		return ""
+	}
+	for _, peer := range config.Peers {
+		if *peer.Name == *config.ThisServerName {
+			continue
+		}
+		switch *peer.Role {
+		case "primary", "secondary", "standby":
+			return *peer.Name
+		}
+	}
+	return ""
+}
+
+// Validates the action against the current HA state of the servers and
+// prepares the command to be sent to the appropriate server. It returns
+// an error if the action is not supported, the servers are not in the
+// states allowing the action, or the action would cause an unsafe
+// transition, e.g. both servers serving the same scopes.
+func PrepareHAActionCommand(service *dbmodel.Service, action *HAAction) (*HAActionCommand, error) {
+	if service.HAService == nil {
+		return nil, errors.Errorf("service %d is not an HA service", service.ID)
+	}
+	daemon := service.GetDaemonByID(action.DaemonID)
+	if daemon == nil || (action.DaemonID != service.HAService.PrimaryID && action.DaemonID != service.HAService.SecondaryID) {
+		return nil, errors.Errorf("daemon %d is not a primary or secondary server of the HA service %d", action.DaemonID, service.ID)
+	}
+	state := service.GetDaemonHAState(daemon.ID)
+
+	var partner *dbmodel.Daemon
+	var partnerState dbmodel.HAState
+	if partnerID := service.GetHAPartnerID(daemon.ID); partnerID != 0 {
+		partner = service.GetDaemonByID(partnerID)
+		partnerState = service.GetDaemonHAState(partnerID)
+	}
+	requirePartner := func() error {
+		if partner == nil {
+			return errors.Errorf("the partner of daemon %d is unknown in the HA service %d", daemon.ID, service.ID)
+		}
+		return nil
+	}
+
+	var (
+		target    *dbmodel.Daemon
+		command   string
+		arguments map[string]interface{}
+	)
+	switch action.Name {
+	case HAActionMaintenanceStart:
+		if err := requirePartner(); err != nil {
+			return nil, err
+		}
+		if state == dbmodel.HAStateInMaintenance || partnerState == dbmodel.HAStatePartnerInMaintenance {
+			return nil, errors.Errorf("daemon %d is already in maintenance", daemon.ID)
+		}
+		// The partner takes over the DHCP service so it must be operating.
+		if !isNormalOperationHAState(partnerState) && partnerState != dbmodel.HAStatePartnerDown {
+			return nil, errors.Errorf("cannot start maintenance of daemon %d because its partner is in the %s state; the partner must be in the %s, %s or %s state",
+				daemon.ID, partnerState, dbmodel.HAStateLoadBalancing, dbmodel.HAStateHotStandby, dbmodel.HAStatePartnerDown)
+		}
+		target = partner
+		command = "ha-maintenance-start"
+
+	case HAActionMaintenanceCancel:
+		if err := requirePartner(); err != nil {
+			return nil, err
+		}
+		if partnerState != dbmodel.HAStatePartnerInMaintenance {
+			return nil, errors.Errorf("cannot cancel maintenance of daemon %d because its partner is in the %s state instead of %s",
+				daemon.ID, partnerState, dbmodel.HAStatePartnerInMaintenance)
+		}
+		target = partner
+		command = "ha-maintenance-cancel"
+
+	case HAActionScopes:
+		if state == dbmodel.HAStateUnavailable {
+			return nil, errors.Errorf("cannot set scopes of daemon %d because it is unavailable", daemon.ID)
+		}
+		// Serving the partner's scopes while the partner serves them would
+		// cause both servers to respond to the same clients.
+		if partner != nil && (isNormalOperationHAState(partnerState) || partnerState == dbmodel.HAStatePartnerDown) {
+			if partnerName := getHAPartnerName(daemon, service.HAService); len(partnerName) > 0 {
+				for _, scope := range action.Scopes {
+					if scope == partnerName {
+						return nil, errors.Errorf("cannot enable the %s scope on daemon %d because its partner is in the %s state and serves this scope",
+							scope, daemon.ID, partnerState)
+					}
+				}
+			}
+		}
+		target = daemon
+		command = "ha-scopes"
+		scopes := action.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		arguments = map[string]interface{}{
+			"scopes": scopes,
+		}
+
+	case HAActionContinue:
+		if state == dbmodel.HAStateUnavailable {
+			return nil, errors.Errorf("cannot resume the state machine of daemon %d because it is unavailable", daemon.ID)
+		}
+		target = daemon
+		command = "ha-continue"
+
+	case HAActionSync:
+		if err := requirePartner(); err != nil {
+			return nil, err
+		}
+		// The synchronization pauses the DHCP service of the partner and
+		// replaces the leases of the server. It must not be done when the
+		// server serves the clients.
+		if state == dbmodel.HAStateUnavailable || isNormalOperationHAState(state) || state == dbmodel.HAStatePartnerDown {
+			return nil, errors.Errorf("cannot synchronize leases of daemon %d in the %s state", daemon.ID, state)
+		}
+		if partnerState == dbmodel.HAStateUnavailable {
+			return nil, errors.Errorf("cannot synchronize leases of daemon %d because its partner is unavailable", daemon.ID)
+		}
+		partnerName := getHAPartnerName(daemon, service.HAService)
+		if len(partnerName) == 0 {
+			return nil, errors.Errorf("cannot find the partner's name in the HA configuration of daemon %d", daemon.ID)
+		}
+		maxPeriod := action.MaxPeriod
+		if maxPeriod <= 0 {
+			maxPeriod = defaultHASyncMaxPeriod
+		}
+		target = daemon
+		command = "ha-sync"
+		arguments = map[string]interface{}{
+			"server-name": partnerName,
+			"max-period":  maxPeriod,
+		}
+
+	case HAActionSyncCompleteNotify:
+		if err := requirePartner(); err != nil {
+			return nil, err
+		}
+		if partnerState != dbmodel.HAStatePartnerDown {
+			return nil, errors.Errorf("cannot notify the partner of daemon %d about the completed synchronization because it is in the %s state instead of %s",
+				daemon.ID, partnerState, dbmodel.HAStatePartnerDown)
+		}
+		target = partner
+		command = "ha-sync-complete-notify"
+
+	default:
+		return nil, errors.Errorf("unsupported HA action %s", action.Name)
+	}
+
+	// The server having several HA relationships (hub-and-spoke) needs the
+	// name of its partner to select the relationship the command applies
+	// to. The single relationship servers accept it too.
+	if _, ok := arguments["server-name"]; !ok {
+		if serverName := getHAPartnerName(target, service.HAService); len(serverName) > 0 {
+			if arguments == nil {
+				arguments = map[string]interface{}{}
+			}
+			arguments["server-name"] = serverName
+		}
+	}
+	var commandArguments interface{}
+	if arguments != nil {
+		commandArguments = arguments
+	}
+
+	return &HAActionCommand{
+		Daemon:  target,
+		Command: keactrl.NewCommand(command, []string{target.Name}, commandArguments),
+	}, nil
+}
+
+// Sends the command performing the HA action to the daemon belonging to
+// the specified app. The app must include the machine and access points.
+// It returns the text of the Kea response.
+func SendHAActionCommand(ctx context.Context, agents agentcomm.ConnectedAgents, app *dbmodel.App, command *HAActionCommand) (string, error) {
+	// todo: hardcoding the timeout is a temporary solution. The lease
+	// synchronization may take longer.
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	response := []keactrl.ResponseHeader{}
+	result, err := agents.ForwardToKeaOverHTTP(ctx, app, []keactrl.SerializableCommand{command.Command}, &response)
+	if err != nil {
+		return "", err
+	}
+	if result.Error != nil {
+		return "", result.Error
+	}
+	if len(result.CmdsErrors) > 0 && result.CmdsErrors[0] != nil {
+		return "", result.CmdsErrors[0]
+	}
+	if len(response) == 0 {
+		return "", errors.Errorf("invalid response to %s command received", command.Command.Command)
+	}
+	if response[0].Result != keactrl.ResponseSuccess {
+		return "", errors.Errorf("%s command failed: %s", command.Command.Command, response[0].Text)
+	}
+	return response[0].Text, nil
+}
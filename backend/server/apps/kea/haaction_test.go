@@ -0,0 +1,326 @@
+package kea
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	require "github.com/stretchr/testify/require"
+	keactrl "isc.org/stork/appctrl/kea"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Returns a load-balancing HA service with the primary and secondary
+// servers in the specified states.
+func getHAActionTestService(primaryState, secondaryState dbmodel.HAState) *dbmodel.Service {
+	primary := &dbmodel.Daemon{
+		ID:    1,
+		Name:  "dhcp4",
+		AppID: 10,
+		KeaDaemon: &dbmodel.KeaDaemon{
+			Config:        getHATestConfig("Dhcp4", "server1", "load-balancing", "server1", "server2", "server4"),
+			KeaDHCPDaemon: &dbmodel.KeaDHCPDaemon{},
+		},
+	}
+	secondary := &dbmodel.Daemon{
+		ID:    2,
+		Name:  "dhcp4",
+		AppID: 20,
+		KeaDaemon: &dbmodel.KeaDaemon{
+			Config:        getHATestConfig("Dhcp4", "server2", "load-balancing", "server1", "server2", "server4"),
+			KeaDHCPDaemon: &dbmodel.KeaDHCPDaemon{},
+		},
+	}
+	backup := &dbmodel.Daemon{
+		ID:    3,
+		Name:  "dhcp4",
+		AppID: 30,
+	}
+	return &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			ID:      5,
+			Daemons: []*dbmodel.Daemon{primary, secondary, backup},
+		},
+		HAService: &dbmodel.BaseHAService{
+			HAType:             dbmodel.HATypeDhcp4,
+			HAMode:             dbmodel.HAModeLoadBalancing,
+			PrimaryID:          1,
+			SecondaryID:        2,
+			BackupID:           []int64{3},
+			PrimaryLastState:   primaryState,
+			SecondaryLastState: secondaryState,
+		},
+	}
+}
+
+// Test that the partner's name is found in the HA configuration.
+func TestGetHAPartnerName(t *testing.T) {
+	service := getHAActionTestService(dbmodel.HAStateLoadBalancing, dbmodel.HAStateLoadBalancing)
+	require.Equal(t, "server2", getHAPartnerName(service.Daemons[0], service.HAService))
+	require.Equal(t, "server1", getHAPartnerName(service.Daemons[1], service.HAService))
+	require.Empty(t, getHAPartnerName(service.Daemons[2], service.HAService))
+
+	// The relationship name doesn't match the configuration.
+	service.HAService.Relationship = "server3,server4"
+	require.Empty(t, getHAPartnerName(service.Daemons[0], service.HAService))
+}
+
+// Test that the maintenance start command is sent to the partner of the
+// server to be maintained.
+func TestPrepareHAActionCommandMaintenanceStart(t *testing.T) {
+	service := getHAActionTestService(dbmodel.HAStateLoadBalancing, dbmodel.HAStateLoadBalancing)
+	command, err := PrepareHAActionCommand(service, &HAAction{Name: HAActionMaintenanceStart, DaemonID: 1})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, command.Daemon.ID)
+	require.Equal(t, "ha-maintenance-start", command.Command.Command)
+	require.Equal(t, []string{"dhcp4"}, command.Command.Daemons)
+	require.Equal(t, map[string]interface{}{"server-name": "server1"}, command.Command.Arguments)
+
+	// The partner is down, it cannot take over.
+	service = getHAActionTestService(dbmodel.HAStateLoadBalancing, dbmodel.HAStateUnavailable)
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionMaintenanceStart, DaemonID: 1})
+	require.Error(t, err)
+
+	// Already in maintenance.
+	service = getHAActionTestService(dbmodel.HAStateInMaintenance, dbmodel.HAStatePartnerInMaintenance)
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionMaintenanceStart, DaemonID: 1})
+	require.Error(t, err)
+}
+
+// Test that the maintenance can be canceled only when it is in progress.
+func TestPrepareHAActionCommandMaintenanceCancel(t *testing.T) {
+	service := getHAActionTestService(dbmodel.HAStatePartnerInMaintenance, dbmodel.HAStateInMaintenance)
+	command, err := PrepareHAActionCommand(service, &HAAction{Name: HAActionMaintenanceCancel, DaemonID: 2})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, command.Daemon.ID)
+	require.Equal(t, "ha-maintenance-cancel", command.Command.Command)
+	require.Equal(t, map[string]interface{}{"server-name": "server2"}, command.Command.Arguments)
+
+	service = getHAActionTestService(dbmodel.HAStateLoadBalancing, dbmodel.HAStateLoadBalancing)
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionMaintenanceCancel, DaemonID: 2})
+	require.Error(t, err)
+}
+
+// Test that the server cannot be configured to serve the scopes of the
+// partner serving them.
+func TestPrepareHAActionCommandScopes(t *testing.T) {
+	service := getHAActionTestService(dbmodel.HAStatePartnerDown, dbmodel.HAStateTerminated)
+	command, err := PrepareHAActionCommand(service, &HAAction{Name: HAActionScopes, DaemonID: 1, Scopes: []string{"server1", "server2"}})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, command.Daemon.ID)
+	require.Equal(t, "ha-scopes", command.Command.Command)
+	require.Equal(t, map[string]interface{}{"scopes": []string{"server1", "server2"}, "server-name": "server2"}, command.Command.Arguments)
+
+	// No scopes.
+	command, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionScopes, DaemonID: 1})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"scopes": []string{}, "server-name": "server2"}, command.Command.Arguments)
+
+	// The secondary serves its scope.
+	service = getHAActionTestService(dbmodel.HAStateLoadBalancing, dbmodel.HAStateLoadBalancing)
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionScopes, DaemonID: 1, Scopes: []string{"server1", "server2"}})
+	require.Error(t, err)
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionScopes, DaemonID: 1, Scopes: []string{"server1"}})
+	require.NoError(t, err)
+
+	// The server is unavailable.
+	service = getHAActionTestService(dbmodel.HAStateUnavailable, dbmodel.HAStateLoadBalancing)
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionScopes, DaemonID: 1, Scopes: []string{"server1"}})
+	require.Error(t, err)
+}
+
+// Test that the continue command is sent to the selected server.
+func TestPrepareHAActionCommandContinue(t *testing.T) {
+	service := getHAActionTestService(dbmodel.HAStateWaiting, dbmodel.HAStateWaiting)
+	command, err := PrepareHAActionCommand(service, &HAAction{Name: HAActionContinue, DaemonID: 2})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, command.Daemon.ID)
+	require.Equal(t, "ha-continue", command.Command.Command)
+	require.Equal(t, map[string]interface{}{"server-name": "server1"}, command.Command.Arguments)
+
+	// The server name is omitted when the HA configuration is unknown.
+	service.Daemons[1].KeaDaemon.Config = nil
+	command, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionContinue, DaemonID: 2})
+	require.NoError(t, err)
+	require.Nil(t, command.Command.Arguments)
+
+	service = getHAActionTestService(dbmodel.HAStateWaiting, dbmodel.HAStateUnavailable)
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionContinue, DaemonID: 2})
+	require.Error(t, err)
+}
+
+// Test that the leases can be synchronized only by the server which
+// doesn't serve the clients.
+func TestPrepareHAActionCommandSync(t *testing.T) {
+	service := getHAActionTestService(dbmodel.HAStatePartnerDown, dbmodel.HAStateWaiting)
+	command, err := PrepareHAActionCommand(service, &HAAction{Name: HAActionSync, DaemonID: 2})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, command.Daemon.ID)
+	require.Equal(t, "ha-sync", command.Command.Command)
+	require.Equal(t, map[string]interface{}{"server-name": "server1", "max-period": int64(60)}, command.Command.Arguments)
+
+	command, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionSync, DaemonID: 2, MaxPeriod: 30})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"server-name": "server1", "max-period": int64(30)}, command.Command.Arguments)
+
+	// The server serves the clients.
+	service = getHAActionTestService(dbmodel.HAStateLoadBalancing, dbmodel.HAStateLoadBalancing)
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionSync, DaemonID: 2})
+	require.Error(t, err)
+
+	// The partner is unavailable.
+	service = getHAActionTestService(dbmodel.HAStateUnavailable, dbmodel.HAStateWaiting)
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionSync, DaemonID: 2})
+	require.Error(t, err)
+}
+
+// Test that the sync complete notification is sent to the partner in the
+// partner-down state.
+func TestPrepareHAActionCommandSyncCompleteNotify(t *testing.T) {
+	service := getHAActionTestService(dbmodel.HAStatePartnerDown, dbmodel.HAStateWaiting)
+	command, err := PrepareHAActionCommand(service, &HAAction{Name: HAActionSyncCompleteNotify, DaemonID: 2})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, command.Daemon.ID)
+	require.Equal(t, "ha-sync-complete-notify", command.Command.Command)
+	require.Equal(t, map[string]interface{}{"server-name": "server2"}, command.Command.Arguments)
+
+	service = getHAActionTestService(dbmodel.HAStateLoadBalancing, dbmodel.HAStateWaiting)
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionSyncCompleteNotify, DaemonID: 2})
+	require.Error(t, err)
+}
+
+// Test that the commands sent to the servers in the hub-and-spoke
+// configuration select the relationship by the partner's name.
+func TestPrepareHAActionCommandHubAndSpoke(t *testing.T) {
+	getService := func(hubState, spokeState dbmodel.HAState) *dbmodel.Service {
+		hub := &dbmodel.Daemon{
+			ID:    1,
+			Name:  "dhcp4",
+			AppID: 10,
+			KeaDaemon: &dbmodel.KeaDaemon{
+				Config:        getHAHubAndSpokeTestConfig("server1", "server3"),
+				KeaDHCPDaemon: &dbmodel.KeaDHCPDaemon{},
+			},
+		}
+		spoke := &dbmodel.Daemon{
+			ID:    2,
+			Name:  "dhcp4",
+			AppID: 20,
+			KeaDaemon: &dbmodel.KeaDaemon{
+				Config:        getHAHubAndSpokeTestConfig("server4"),
+				KeaDHCPDaemon: &dbmodel.KeaDHCPDaemon{},
+			},
+		}
+		return &dbmodel.Service{
+			BaseService: dbmodel.BaseService{
+				ID:      5,
+				Daemons: []*dbmodel.Daemon{hub, spoke},
+			},
+			HAService: &dbmodel.BaseHAService{
+				HAType:             dbmodel.HATypeDhcp4,
+				HAMode:             dbmodel.HAModeHotStandby,
+				Relationship:       "server3,server4",
+				PrimaryID:          1,
+				SecondaryID:        2,
+				PrimaryLastState:   hubState,
+				SecondaryLastState: spokeState,
+			},
+		}
+	}
+
+	// The maintenance of the hub in the second relationship is started
+	// by the spoke.
+	service := getService(dbmodel.HAStateHotStandby, dbmodel.HAStateHotStandby)
+	command, err := PrepareHAActionCommand(service, &HAAction{Name: HAActionMaintenanceStart, DaemonID: 1})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, command.Daemon.ID)
+	require.Equal(t, map[string]interface{}{"server-name": "server3"}, command.Command.Arguments)
+
+	// The hub selects the relationship by the spoke's name.
+	command, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionScopes, DaemonID: 1, Scopes: []string{"server3"}})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, command.Daemon.ID)
+	require.Equal(t, map[string]interface{}{"scopes": []string{"server3"}, "server-name": "server4"}, command.Command.Arguments)
+
+	service = getService(dbmodel.HAStatePartnerDown, dbmodel.HAStateWaiting)
+	command, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionContinue, DaemonID: 2})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"server-name": "server3"}, command.Command.Arguments)
+
+	command, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionSync, DaemonID: 2})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"server-name": "server3", "max-period": int64(60)}, command.Command.Arguments)
+
+	command, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionSyncCompleteNotify, DaemonID: 2})
+	require.NoError(t, err)
+	require.EqualValues(t, 1, command.Daemon.ID)
+	require.Equal(t, "ha-sync-complete-notify", command.Command.Command)
+	require.Equal(t, map[string]interface{}{"server-name": "server4"}, command.Command.Arguments)
+
+	// The first relationship of the hub is selected by the other spoke's
+	// name.
+	service = getService(dbmodel.HAStateHotStandby, dbmodel.HAStateHotStandby)
+	service.HAService.Relationship = "server1,server2"
+	command, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionScopes, DaemonID: 1, Scopes: []string{"server1"}})
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{"scopes": []string{"server1"}, "server-name": "server2"}, command.Command.Arguments)
+}
+
+// Test that the invalid actions are rejected.
+func TestPrepareHAActionCommandInvalid(t *testing.T) {
+	service := getHAActionTestService(dbmodel.HAStateLoadBalancing, dbmodel.HAStateLoadBalancing)
+
+	// Unknown action.
+	_, err := PrepareHAActionCommand(service, &HAAction{Name: "foo", DaemonID: 1})
+	require.Error(t, err)
+
+	// Backup server.
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionContinue, DaemonID: 3})
+	require.Error(t, err)
+
+	// Unknown daemon.
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionContinue, DaemonID: 4})
+	require.Error(t, err)
+
+	// Not an HA service.
+	service.HAService = nil
+	_, err = PrepareHAActionCommand(service, &HAAction{Name: HAActionContinue, DaemonID: 1})
+	require.Error(t, err)
+}
+
+// Test that the HA action command is sent to the Kea server and the
+// response is interpreted.
+func TestSendHAActionCommand(t *testing.T) {
+	mock := func(result int) func(int, []interface{}) {
+		return func(callNo int, responses []interface{}) {
+			json := []byte(fmt.Sprintf(`[{"result": %d, "text": "Server is now in maintenance state."}]`, result))
+			command := keactrl.NewCommand("ha-maintenance-start", []string{"dhcp4"}, nil)
+			_ = keactrl.UnmarshalResponseList(command, json, responses[0])
+		}
+	}
+	service := getHAActionTestService(dbmodel.HAStateLoadBalancing, dbmodel.HAStateLoadBalancing)
+	command, err := PrepareHAActionCommand(service, &HAAction{Name: HAActionMaintenanceStart, DaemonID: 1})
+	require.NoError(t, err)
+
+	app := &dbmodel.App{
+		ID:   20,
+		Type: dbmodel.AppTypeKea,
+		Machine: &dbmodel.Machine{
+			Address:   "192.0.2.66",
+			AgentPort: 8080,
+		},
+		AccessPoints: dbmodel.AppendAccessPoint(nil, dbmodel.AccessPointControl, "192.0.2.66", "", 8000, false),
+	}
+
+	fa := agentcommtest.NewFakeAgents(mock(0), nil)
+	text, err := SendHAActionCommand(context.Background(), fa, app, command)
+	require.NoError(t, err)
+	require.Equal(t, "Server is now in maintenance state.", text)
+	require.Len(t, fa.RecordedCommands, 1)
+	require.Equal(t, "ha-maintenance-start", fa.RecordedCommands[0].GetCommand())
+
+	fa = agentcommtest.NewFakeAgents(mock(1), nil)
+	_, err = SendHAActionCommand(context.Background(), fa, app, command)
+	require.Error(t, err)
+}
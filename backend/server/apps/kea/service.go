@@ -6,22 +6,83 @@ import (
 	dbmodel "isc.org/stork/server/database/model"
 )
 
-// Checks if the specified Kea daemon belongs to a given HA service.
-// This is done by matching the HA configuration of the given daemon
-// with the HA configurations of the other daemons already associated
-// with the service. In particular, the HA mode must match and for
-// the peers' configurations the server names, URLs and roles must
-// match.
-func daemonBelongsToHAService(daemon *dbmodel.Daemon, service *dbmodel.Service) bool {
+// Returns the HA relationship configured for the daemon which corresponds
+// to the HA service. The relationship is matched by its name. If the
+// service has no relationship name, i.e. it was detected before Stork
+// supported multiple relationships, the first relationship is returned.
+// It returns nil if the daemon has no corresponding relationship.
+func getHARelationshipConfig(daemon *dbmodel.Daemon, haService *dbmodel.BaseHAService) *keaconfig.HA {
+	if daemon.KeaDaemon == nil || daemon.KeaDaemon.Config == nil {
+		return nil
+	}
+	_, params, ok := daemon.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
+	if !ok {
+		return nil
+	}
+	relationships := params.GetRelationships()
+	if len(haService.Relationship) == 0 {
+		if len(relationships) > 0 {
+			return relationships[0]
+		}
+		return nil
+	}
+	for _, relationship := range relationships {
+		if relationship.GetRelationshipName() == haService.Relationship {
+			return relationship
+		}
+	}
+	return nil
+}
+
+// Checks if the HA relationship configured on one server matches the
+// relationship configured on the other server. The HA mode must match
+// and for each peer in the other server's configuration there must be
+// a peer with the same name, URL and role in the server's configuration.
+func haRelationshipsMatch(relationship, otherRelationship *keaconfig.HA) bool {
+	if !relationship.IsValid() || !otherRelationship.IsValid() || *relationship.Mode != *otherRelationship.Mode {
+		return false
+	}
+	for _, otherPeer := range otherRelationship.Peers {
+		// For the given peer in the other configuration let's find the
+		// corresponding one specified in the configuration.
+		found := false
+		for _, peer := range relationship.Peers {
+			if (*peer.Name == *otherPeer.Name) &&
+				(*peer.URL == *otherPeer.URL) &&
+				(*peer.Role == *otherPeer.Role) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks if the specified HA relationship of the Kea daemon belongs to a
+// given HA service. This is done by matching the relationship with the
+// HA configurations of the other daemons already associated with the
+// service. In particular, the HA mode must match and for the peers'
+// configurations the server names, URLs and roles must match. A daemon
+// may belong to several HA services in the hub-and-spoke configuration,
+// one per relationship. Therefore, the relationship name must match the
+// name recorded for the service if it has been recorded.
+func daemonBelongsToHAService(daemon *dbmodel.Daemon, relationship *keaconfig.HA, service *dbmodel.Service) bool {
 	// If there are no daemons associated with the service, there is
 	// nothing we can compare the daemon's configuration with.
-	if len(service.Daemons) == 0 {
+	if len(service.Daemons) == 0 || service.HAService == nil {
 		return false
 	}
 
-	// Get the daemon's HA configuration and check if it is set.
-	_, daemonConfigHA, ok := daemon.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
-	if !ok || !daemonConfigHA.GetFirst().IsValid() {
+	// Check if the relationship is valid.
+	if !relationship.IsValid() {
+		return false
+	}
+
+	// The service corresponds to another relationship.
+	if len(service.HAService.Relationship) > 0 && service.HAService.Relationship != relationship.GetRelationshipName() {
 		return false
 	}
 
@@ -39,34 +100,14 @@ func daemonBelongsToHAService(daemon *dbmodel.Daemon, service *dbmodel.Service)
 			continue
 		}
 
-		// Get the HA configuration of the daemon belonging to the service.
-		var serviceDaemonConfigHA keaconfig.HALibraryParams
-		_, serviceDaemonConfigHA, ok = sd.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
-		if !ok || !serviceDaemonConfigHA.GetFirst().IsValid() || (*daemonConfigHA.GetFirst().Mode != *serviceDaemonConfigHA.GetFirst().Mode) {
-			// There is something wrong with the service or the mode is not matching.
-			// This service is not matching.
+		// Get the HA relationship of the daemon belonging to the service and
+		// compare it with the daemon's relationship.
+		serviceRelationship := getHARelationshipConfig(sd, service.HAService)
+		if serviceRelationship == nil || !haRelationshipsMatch(relationship, serviceRelationship) {
+			// There is something wrong with the service or the relationship is
+			// not matching.
 			return false
 		}
-
-		// Now we have to compare the peers' configurations.
-		for _, servicePeer := range serviceDaemonConfigHA.GetFirst().Peers {
-			// For the given peer in the service let's find the corresponding one
-			// specified in the daemons's configuration.
-			ok = false
-			for _, daemonPeer := range daemonConfigHA.GetFirst().Peers {
-				if (*daemonPeer.Name == *servicePeer.Name) &&
-					(*daemonPeer.URL == *servicePeer.URL) &&
-					(*daemonPeer.Role == *servicePeer.Role) {
-					// Match found.
-					ok = true
-					break
-				}
-			}
-			// Peer not found on the URL is not matching.
-			if !ok {
-				return false
-			}
-		}
 	}
 
 	// Passed all checks that could possibly eliminate the daemon from the service.
@@ -93,38 +134,35 @@ func DetectHAServices(dbi dbops.DBI, daemon *dbmodel.Daemon) (services []dbmodel
 	}
 
 	// Check if the configuration contains any HA configuration.
-	if _, params, ok := daemon.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary(); ok {
+	_, params, ok := daemon.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
+	if !ok {
+		return services
+	}
+
+	dbServices, _ := dbmodel.GetDetailedAllServices(dbi)
+
+	// The daemon belongs to a separate service for each HA relationship.
+	for _, relationship := range params.GetRelationships() {
 		// Make sure that the required parameters are set.
-		if !params.GetFirst().IsValid() {
-			return services
+		if !relationship.IsValid() {
+			continue
 		}
 
 		// HA configuration must contain this-server-name parameter which indicates
-		// which of the peers' configurations belongs to it. Let's iterate over
-		// the configured peers to identify the one.
-		index := -1
-		for i, p := range params.GetFirst().Peers {
-			// this-server-name matches one of the peers. Remember which one.
-			if *p.Name == *params.GetFirst().ThisServerName {
-				index = i
-			}
-		}
+		// which of the peers' configurations belongs to it.
+		thisServer := relationship.GetThisServer()
 
 		// This server not found.
-		if index < 0 {
-			return services
+		if thisServer == nil {
+			continue
 		}
 
-		// This server configuration found.
-		thisServer := params.GetFirst().Peers[index]
-
-		// Next, check if there are any existing services matching this daemon.
-		index = -1
-		dbServices, _ := dbmodel.GetDetailedAllServices(dbi)
+		// Next, check if there are any existing services matching this relationship.
+		index := -1
 		for i, service := range dbServices {
 			if (service.HAService != nil) &&
 				(service.HAService.HAType == daemon.Name) &&
-				daemonBelongsToHAService(daemon, &dbServices[i]) {
+				daemonBelongsToHAService(daemon, relationship, &dbServices[i]) {
 				index = i
 				break
 			}
@@ -147,9 +185,15 @@ func DetectHAServices(dbi dbops.DBI, daemon *dbmodel.Daemon) (services []dbmodel
 
 		// Set HA mode, if not set yet.
 		if len(service.HAService.HAMode) == 0 {
-			service.HAService.HAMode = *params.GetFirst().Mode
+			service.HAService.HAMode = *relationship.Mode
 		}
 
+		// Remember which relationship the service corresponds to. The services
+		// detected before supporting multiple relationships have no name.
+		// The HA service is shared with the services fetched from the database
+		// so the service is no longer matched by other relationships.
+		service.HAService.Relationship = relationship.GetRelationshipName()
+
 		// Depending on the role of this server we will be setting different column
 		// of the HA service column.
 		switch *(thisServer.Role) {
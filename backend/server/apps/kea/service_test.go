@@ -425,7 +425,9 @@ func TestAppBelongsToHAServiceBlankService(t *testing.T) {
 	// The daemon doesn't belong to the service because the service includes
 	// no meaningful information to make such determination. In that case
 	// it is up to the administrator to explicitly add the daemon to the service.
-	require.False(t, daemonBelongsToHAService(app.Daemons[0], service))
+	_, params, ok := app.Daemons[0].KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
+	require.True(t, ok)
+	require.False(t, daemonBelongsToHAService(app.Daemons[0], params.GetFirst(), service))
 }
 
 // Test that a daemon can be dissociated with all services it belongs to.
@@ -486,3 +488,200 @@ func TestReduceHAServices(t *testing.T) {
 	require.NotNil(t, services[0].HAService)
 	require.Equal(t, "hot-standby", services[0].HAService.HAMode)
 }
+
+// Returns the DHCPv4 server configuration with the hot-standby relationships
+// of the hub-and-spoke configuration. The hub has the server1 name in the
+// relationship with server2 and the server3 name in the relationship with
+// server4. The relationships are included for the specified names of this
+// server, e.g., server1 and server3 for the hub.
+func getHAHubAndSpokeTestConfig(thisServerNames ...string) *dbmodel.KeaConfig {
+	relationships := map[string]string{
+		"server1": `[
+            { "name": "server1", "url": "http://192.0.2.33:8000", "role": "primary" },
+            { "name": "server2", "url": "http://192.0.2.66:8000", "role": "standby" }
+        ]`,
+		"server3": `[
+            { "name": "server3", "url": "http://192.0.2.33:8001", "role": "primary" },
+            { "name": "server4", "url": "http://192.0.2.133:8000", "role": "standby" }
+        ]`,
+	}
+	relationships["server2"] = relationships["server1"]
+	relationships["server4"] = relationships["server3"]
+
+	var relationshipsList string
+	for _, thisServerName := range thisServerNames {
+		if len(relationshipsList) > 0 {
+			relationshipsList += ",\n"
+		}
+		relationshipsList += fmt.Sprintf(`{
+            "this-server-name": "%s",
+            "mode": "hot-standby",
+            "peers": %s
+        }`, thisServerName, relationships[thisServerName])
+	}
+
+	configStr := fmt.Sprintf(`{
+        "Dhcp4": {
+            "hooks-libraries": [
+                {
+                    "library": "libdhcp_ha.so",
+                    "parameters": {
+                        "high-availability": [ %s ]
+                    }
+                }
+            ]
+        }
+    }`, relationshipsList)
+
+	var config dbmodel.KeaConfig
+	_ = json.Unmarshal([]byte(configStr), &config)
+	return &config
+}
+
+// Test that a separate service is created for each HA relationship in the
+// hub-and-spoke configuration.
+func TestDetectHAServicesHubAndSpoke(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fec := &storktest.FakeEventCenter{}
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+
+	addApp := func(address string, thisServerNames ...string) *dbmodel.App {
+		m := &dbmodel.Machine{
+			Address:   address,
+			AgentPort: 8080,
+		}
+		err := dbmodel.AddMachine(db, m)
+		require.NoError(t, err)
+
+		app := &dbmodel.App{
+			MachineID:    m.ID,
+			Machine:      m,
+			Type:         dbmodel.AppTypeKea,
+			Active:       true,
+			AccessPoints: dbmodel.AppendAccessPoint(nil, dbmodel.AccessPointControl, address, "", 8000, false),
+			Daemons: []*dbmodel.Daemon{
+				{
+					Name: "dhcp4",
+					KeaDaemon: &dbmodel.KeaDaemon{
+						Config:        getHAHubAndSpokeTestConfig(thisServerNames...),
+						KeaDHCPDaemon: &dbmodel.KeaDHCPDaemon{},
+					},
+				},
+			},
+		}
+		err = CommitAppIntoDB(db, app, fec, nil, lookup)
+		require.NoError(t, err)
+		return app
+	}
+
+	// The hub belongs to two services.
+	hub := addApp("192.0.2.33", "server1", "server3")
+	services, err := dbmodel.GetDetailedAllServices(db)
+	require.NoError(t, err)
+	require.Len(t, services, 2)
+	require.Equal(t, "server1,server2", services[0].HAService.Relationship)
+	require.Equal(t, "server3,server4", services[1].HAService.Relationship)
+	for _, service := range services {
+		require.Equal(t, "hot-standby", service.HAService.HAMode)
+		require.Equal(t, hub.Daemons[0].ID, service.HAService.PrimaryID)
+		require.Zero(t, service.HAService.SecondaryID)
+		require.Len(t, service.Daemons, 1)
+	}
+
+	// Each spoke joins the service of its relationship.
+	spoke1 := addApp("192.0.2.66", "server2")
+	spoke2 := addApp("192.0.2.133", "server4")
+	services, err = dbmodel.GetDetailedAllServices(db)
+	require.NoError(t, err)
+	require.Len(t, services, 2)
+	require.Equal(t, "server1,server2", services[0].HAService.Relationship)
+	require.Equal(t, hub.Daemons[0].ID, services[0].HAService.PrimaryID)
+	require.Equal(t, spoke1.Daemons[0].ID, services[0].HAService.SecondaryID)
+	require.Len(t, services[0].Daemons, 2)
+	require.Equal(t, "server3,server4", services[1].HAService.Relationship)
+	require.Equal(t, hub.Daemons[0].ID, services[1].HAService.PrimaryID)
+	require.Equal(t, spoke2.Daemons[0].ID, services[1].HAService.SecondaryID)
+	require.Len(t, services[1].Daemons, 2)
+
+	// Detecting the services again should return the existing services.
+	detected := DetectHAServices(db, hub.Daemons[0])
+	require.Len(t, detected, 2)
+	require.Equal(t, services[0].ID, detected[0].ID)
+	require.Equal(t, services[1].ID, detected[1].ID)
+}
+
+// Test that the service detected before supporting multiple relationships
+// is matched with the first relationship.
+func TestDetectHAServicesUnnamedRelationship(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:   "192.0.2.33",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	app := &dbmodel.App{
+		MachineID: m.ID,
+		Type:      dbmodel.AppTypeKea,
+		Daemons: []*dbmodel.Daemon{
+			{
+				Name: "dhcp4",
+				KeaDaemon: &dbmodel.KeaDaemon{
+					Config:        getHAHubAndSpokeTestConfig("server1", "server3"),
+					KeaDHCPDaemon: &dbmodel.KeaDHCPDaemon{},
+				},
+			},
+		},
+	}
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+
+	// The service without the relationship name.
+	service := &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			Daemons: app.Daemons,
+		},
+		HAService: &dbmodel.BaseHAService{
+			HAType:    "dhcp4",
+			HAMode:    "hot-standby",
+			PrimaryID: app.Daemons[0].ID,
+		},
+	}
+	err = dbmodel.AddService(db, service)
+	require.NoError(t, err)
+
+	services := DetectHAServices(db, app.Daemons[0])
+	require.Len(t, services, 2)
+	require.Equal(t, service.ID, services[0].ID)
+	require.Equal(t, "server1,server2", services[0].HAService.Relationship)
+	require.True(t, services[1].IsNew())
+	require.Equal(t, "server3,server4", services[1].HAService.Relationship)
+}
+
+// Test that the relationship configuration corresponding to the service
+// is returned.
+func TestGetHARelationshipConfig(t *testing.T) {
+	daemon := &dbmodel.Daemon{
+		Name: "dhcp4",
+		KeaDaemon: &dbmodel.KeaDaemon{
+			Config:        getHAHubAndSpokeTestConfig("server1", "server3"),
+			KeaDHCPDaemon: &dbmodel.KeaDHCPDaemon{},
+		},
+	}
+
+	relationship := getHARelationshipConfig(daemon, &dbmodel.BaseHAService{Relationship: "server3,server4"})
+	require.NotNil(t, relationship)
+	require.Equal(t, "server3", *relationship.ThisServerName)
+
+	// The first relationship is returned for the service without the name.
+	relationship = getHARelationshipConfig(daemon, &dbmodel.BaseHAService{})
+	require.NotNil(t, relationship)
+	require.Equal(t, "server1", *relationship.ThisServerName)
+
+	require.Nil(t, getHARelationshipConfig(daemon, &dbmodel.BaseHAService{Relationship: "server5,server6"}))
+	require.Nil(t, getHARelationshipConfig(&dbmodel.Daemon{Name: "dhcp4"}, &dbmodel.BaseHAService{}))
+}
@@ -186,6 +186,9 @@ type statisticsCounter struct {
 	outOfPoolAddresses map[int64]uint64
 	outOfPoolPrefixes  map[int64]uint64
 	excludedDaemons    map[int64]bool
+	// The statistics of the daemons are summed from their local subnets
+	// the same way as the statistics of the shared networks.
+	daemons map[int64]*sharedNetworkStats
 }
 
 // Constructor of the statistics counter.
@@ -195,6 +198,7 @@ func newStatisticsCounter() *statisticsCounter {
 		global:             newGlobalStats(),
 		outOfPoolAddresses: make(map[int64]uint64),
 		outOfPoolPrefixes:  make(map[int64]uint64),
+		daemons:            make(map[int64]*sharedNetworkStats),
 	}
 }
 
@@ -237,6 +241,8 @@ func (c *statisticsCounter) add(subnet *dbmodel.Subnet) subnetStats {
 		}
 	}
 
+	c.addDaemons(subnet)
+
 	outOfPoolAddresses, ok := c.outOfPoolAddresses[subnet.ID]
 	if !ok {
 		outOfPoolAddresses = 0
@@ -254,6 +260,32 @@ func (c *statisticsCounter) add(subnet *dbmodel.Subnet) subnetStats {
 	return c.addIPv6Subnet(subnet, outOfPoolAddresses, outOfPoolPrefixes)
 }
 
+// Add the statistics of the local subnets to the statistics of the daemons
+// they belong to. The statistics of the excluded daemons are included
+// because they describe the utilization seen by these daemons. The
+// out-of-pool reservations are not included.
+func (c *statisticsCounter) addDaemons(subnet *dbmodel.Subnet) {
+	for _, localSubnet := range subnet.LocalSubnets {
+		if localSubnet.Stats == nil {
+			continue
+		}
+		stats, ok := c.daemons[localSubnet.DaemonID]
+		if !ok {
+			stats = newSharedNetworkStats()
+			c.daemons[localSubnet.DaemonID] = stats
+		}
+		if subnet.GetFamily() == 4 {
+			stats.totalAddresses.Add(getLocalSubnetStat(localSubnet, "total-addresses"))
+			stats.totalAssignedAddresses.Add(getLocalSubnetStat(localSubnet, "assigned-addresses"))
+			continue
+		}
+		stats.totalAddresses.Add(getLocalSubnetStat(localSubnet, "total-nas"))
+		stats.totalAssignedAddresses.Add(getLocalSubnetStat(localSubnet, "assigned-nas"))
+		stats.totalDelegatedPrefixes.Add(getLocalSubnetStat(localSubnet, "total-pds"))
+		stats.totalAssignedDelegatedPrefixes.Add(getLocalSubnetStat(localSubnet, "assigned-pds"))
+	}
+}
+
 // The resulting addresses counter will be a sum of the addresses returned by Kea for this
 // subnet and the outOfPool counter holding the number of the out-of-pool reservations
 // that Kea does not include in its statistics.
@@ -354,3 +386,16 @@ func sumStatLocalSubnetsIPv4(subnet *dbmodel.Subnet, statName string, excludedDa
 	}
 	return sum
 }
+
+// Return the specific statistic of the local subnet. It returns zero if
+// the statistic is missing or negative.
+func getLocalSubnetStat(localSubnet *dbmodel.LocalSubnet, statName string) *storkutil.BigCounter {
+	counter := storkutil.NewBigCounter(0)
+	switch value := localSubnet.Stats[statName].(type) {
+	case uint64:
+		counter.AddUint64(value)
+	case *big.Int:
+		counter.AddBigInt(value)
+	}
+	return counter
+}
@@ -403,6 +403,85 @@ func TestCounterAddEmptySubnet(t *testing.T) {
 	require.InDelta(t, float64(0.0), statistics.GetDelegatedPrefixUtilization(), float64(0.001))
 }
 
+// Test that the counter sums the statistics of the local subnets per daemon.
+// The excluded daemons and out-of-pool reservations don't affect them.
+func TestCounterAddDaemons(t *testing.T) {
+	// Arrange
+	subnets := []*dbmodel.Subnet{
+		{
+			ID:     1,
+			Prefix: "192.0.2.0/24",
+			LocalSubnets: []*dbmodel.LocalSubnet{
+				{
+					DaemonID: 1,
+					Stats: dbmodel.SubnetStats{
+						"total-addresses":    uint64(100),
+						"assigned-addresses": uint64(10),
+					},
+				},
+				{
+					DaemonID: 2,
+					Stats: dbmodel.SubnetStats{
+						"total-addresses":    uint64(100),
+						"assigned-addresses": uint64(20),
+					},
+				},
+			},
+		},
+		{
+			ID:     2,
+			Prefix: "192.0.3.0/24",
+			LocalSubnets: []*dbmodel.LocalSubnet{
+				{
+					DaemonID: 1,
+					Stats: dbmodel.SubnetStats{
+						"total-addresses":    uint64(300),
+						"assigned-addresses": uint64(90),
+					},
+				},
+			},
+		},
+		{
+			ID:     3,
+			Prefix: "20::/64",
+			LocalSubnets: []*dbmodel.LocalSubnet{
+				{
+					DaemonID: 3,
+					Stats: dbmodel.SubnetStats{
+						"total-nas":    big.NewInt(0).SetUint64(math.MaxUint64),
+						"assigned-nas": uint64(0),
+						"total-pds":    uint64(40),
+						"assigned-pds": uint64(30),
+					},
+				},
+				{
+					DaemonID: 4,
+				},
+			},
+		},
+	}
+
+	counter := newStatisticsCounter()
+	counter.setExcludedDaemons([]int64{2})
+	counter.setOutOfPoolAddresses(map[int64]uint64{1: 100})
+
+	// Act
+	for _, subnet := range subnets {
+		_ = counter.add(subnet)
+	}
+
+	// Assert
+	require.Len(t, counter.daemons, 3)
+	statistics := counter.daemons[1]
+	require.InDelta(t, float64(100.0/400.0), statistics.GetAddressUtilization(), float64(0.001))
+	require.Zero(t, statistics.GetDelegatedPrefixUtilization())
+	statistics = counter.daemons[2]
+	require.InDelta(t, float64(20.0/100.0), statistics.GetAddressUtilization(), float64(0.001))
+	statistics = counter.daemons[3]
+	require.Zero(t, statistics.GetAddressUtilization())
+	require.InDelta(t, float64(30.0/40.0), statistics.GetDelegatedPrefixUtilization(), float64(0.001))
+}
+
 // Test that the counter add extra IPv4 and IPv6 addresses, and delegated prefixes.
 func TestCounterRealKeaResponse(t *testing.T) {
 	// Arrange
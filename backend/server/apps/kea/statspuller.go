@@ -2,28 +2,50 @@ package kea
 
 import (
 	"context"
+	"encoding/json"
 	"math/big"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	keactrl "isc.org/stork/appctrl/kea"
 	"isc.org/stork/server/agentcomm"
+	"isc.org/stork/server/alerting"
 	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
 )
 
+// Minimal Kea version returning the per-pool statistics.
+var minPoolStatsKeaVersion = [3]int{2, 3, 0} //nolint:gochecknoglobals
+
+// Pattern of the Kea version, e.g. 2.3.0 or 2.3.1-git.
+var keaVersionPattern = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+
 // Statistics puller is responsible for fetching the data using the Kea
 // statistic hook.
 type StatsPuller struct {
 	*agentcomm.PeriodicPuller
 	*RpsWorker
+	EventCenter eventcenter.EventCenter
+	// Indicates if the per-pool statistics are pulled from the Kea
+	// servers supporting them.
+	poolStatsEnabled bool
 }
 
 // Create a StatsPuller object that in background pulls Kea stats about leases.
 // Beneath it spawns a goroutine that pulls stats periodically from Kea apps (that are stored in database).
-func NewStatsPuller(db *pg.DB, agents agentcomm.ConnectedAgents) (*StatsPuller, error) {
-	statsPuller := &StatsPuller{}
+// The alert rules are evaluated after each pull and the alerts are raised via
+// the event center. The per-pool statistics are pulled from Kea 2.3.0 and
+// later unless disabled with the poolStatsEnabled flag.
+func NewStatsPuller(db *pg.DB, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter, poolStatsEnabled bool) (*StatsPuller, error) {
+	statsPuller := &StatsPuller{
+		EventCenter:      eventCenter,
+		poolStatsEnabled: poolStatsEnabled,
+	}
 	periodicPuller, err := agentcomm.NewPeriodicPuller(db, agents, "Kea Stats puller", "kea_stats_puller_interval",
 		statsPuller.pullStats)
 	if err != nil {
@@ -119,6 +141,12 @@ func (statsPuller *StatsPuller) pullStats() error {
 	}
 	counter.setExcludedDaemons(excludedDaemons)
 
+	// The utilization of the subnets, shared networks and daemons is
+	// recorded in the utilization history. The pool samples are recorded
+	// when the pool statistics are stored.
+	now := time.Now().UTC()
+	var samples []dbmodel.UtilizationSample
+
 	// go through all Subnets and:
 	// 1) estimate utilization per Subnet and per SharedNetwork
 	// 2) estimate global stats
@@ -135,6 +163,10 @@ func (statsPuller *StatsPuller) pullStats() error {
 				su.GetAddressUtilization(), su.GetDelegatedPrefixUtilization(), sn.ID, err)
 			continue
 		}
+
+		sample := dbmodel.NewUtilizationSample(su, now)
+		sample.SubnetID = sn.ID
+		samples = append(samples, sample)
 	}
 
 	// shared network utilization
@@ -149,6 +181,27 @@ func (statsPuller *StatsPuller) pullStats() error {
 				u.GetAddressUtilization(), u.GetDelegatedPrefixUtilization(), sharedNetworkID, err)
 			continue
 		}
+
+		sample := dbmodel.NewUtilizationSample(u, now)
+		sample.SharedNetworkID = sharedNetworkID
+		samples = append(samples, sample)
+	}
+
+	// daemon utilization
+	for daemonID, u := range counter.daemons {
+		sample := dbmodel.NewUtilizationSample(u, now)
+		sample.DaemonID = daemonID
+		samples = append(samples, sample)
+	}
+
+	// record the utilization history and aggregate the older samples
+	err = dbmodel.AddUtilizationSamples(statsPuller.DB, samples)
+	if err == nil {
+		err = dbmodel.RollUpUtilizationSamples(statsPuller.DB, now)
+	}
+	if err != nil {
+		lastErr = err
+		log.WithError(err).Error("Cannot update utilization history")
 	}
 
 	// global stats to collect
@@ -169,6 +222,12 @@ func (statsPuller *StatsPuller) pullStats() error {
 		lastErr = err
 	}
 
+	// raise or clear the alerts using the updated statistics
+	err = alerting.EvaluateRules(statsPuller.DB, statsPuller.EventCenter, now)
+	if err != nil {
+		lastErr = err
+	}
+
 	return lastErr
 }
 
@@ -190,6 +249,135 @@ type StatLeaseGetResponse struct {
 	Arguments *StatLeaseGetArgs `json:"arguments,omitempty"`
 }
 
+// Represents unmarshaled response from Kea daemon to statistic-get-all command.
+// Each statistic is a list of value and timestamp pairs, the most recent first.
+// The values are kept raw to avoid losing the precision of the large counters.
+type StatisticGetAllResponse struct {
+	keactrl.ResponseHeader
+	Arguments map[string][][]json.RawMessage `json:"arguments,omitempty"`
+}
+
+// Pattern of the per-pool statistic names returned by Kea 2.3.0 and later,
+// e.g. subnet[1].pool[0].assigned-addresses or subnet[1].pd-pool[0].total-pds.
+var poolStatisticPattern = regexp.MustCompile(`^subnet\[(\d+)\]\.(pool|pd-pool)\[(\d+)\]\.(.+)$`)
+
+// A key identifying the pool statistics. The index is the position of the
+// pool in the subnet configuration.
+type poolStatsKey struct {
+	localSubnetID int64
+	prefixPool    bool
+	index         int
+}
+
+// Parses the statistic value returned by Kea. The values exceeding the int64
+// range are returned as big integers.
+func parseStatisticValue(raw json.RawMessage) (interface{}, error) {
+	text := string(raw)
+	if value, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return value, nil
+	}
+	if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return value, nil
+	}
+	if value, ok := new(big.Int).SetString(text, 10); ok {
+		return value, nil
+	}
+	return nil, errors.Errorf("invalid statistic value %s", text)
+}
+
+// Extracts the per-pool statistics from the statistic-get-all response.
+// The statistics other than the per-pool ones are skipped.
+func getPoolStats(arguments map[string][][]json.RawMessage) map[poolStatsKey]dbmodel.SubnetStats {
+	poolStats := make(map[poolStatsKey]dbmodel.SubnetStats)
+	for name, samples := range arguments {
+		matches := poolStatisticPattern.FindStringSubmatch(name)
+		if matches == nil || len(samples) == 0 || len(samples[0]) == 0 {
+			continue
+		}
+		localSubnetID, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		index, err := strconv.Atoi(matches[3])
+		if err != nil {
+			continue
+		}
+		value, err := parseStatisticValue(samples[0][0])
+		if err != nil {
+			log.WithError(err).WithField("statistic", name).Warn("Skipping invalid pool statistic")
+			continue
+		}
+		key := poolStatsKey{
+			localSubnetID: localSubnetID,
+			prefixPool:    matches[2] == "pd-pool",
+			index:         index,
+		}
+		stats, ok := poolStats[key]
+		if !ok {
+			stats = dbmodel.SubnetStats{}
+			poolStats[key] = stats
+		}
+		stats[matches[4]] = value
+	}
+	return poolStats
+}
+
+// Process the per-pool statistics from the statistic-get-all command response
+// for given daemon. The statistics are matched with the pools by their
+// positions in the subnet configuration. The responses without the per-pool
+// statistics, e.g. from Kea versions earlier than 2.3.0, are ignored.
+func (statsPuller *StatsPuller) storePoolStats(response interface{}, subnetsMap map[localSubnetKey]*dbmodel.LocalSubnet, dbApp *dbmodel.App, family int) error {
+	statsResp, ok := response.(*[]StatisticGetAllResponse)
+	if !ok {
+		return errors.Errorf("response type is invalid: %+v", response)
+	}
+	if len(*statsResp) == 0 {
+		return nil
+	}
+	sr := (*statsResp)[0]
+	if sr.Result != keactrl.ResponseSuccess {
+		return errors.Errorf("error returned by Kea in response to statistic-get-all command: %s", sr.Text)
+	}
+
+	var lastErr error
+	var samples []dbmodel.UtilizationSample
+	now := time.Now().UTC()
+	for key, stats := range getPoolStats(sr.Arguments) {
+		sn, ok := subnetsMap[localSubnetKey{key.localSubnetID, family}]
+		if !ok {
+			continue
+		}
+		var err error
+		var sample dbmodel.UtilizationSample
+		switch {
+		case key.prefixPool && key.index < len(sn.PrefixPools):
+			err = sn.PrefixPools[key.index].UpdateStats(statsPuller.DB, stats)
+			sample = sn.PrefixPools[key.index].NewUtilizationSample(now)
+		case !key.prefixPool && key.index < len(sn.AddressPools):
+			err = sn.AddressPools[key.index].UpdateStats(statsPuller.DB, stats)
+			sample = sn.AddressPools[key.index].NewUtilizationSample(now)
+		default:
+			log.Warnf("Cannot find pool %d in local subnet ID %d, app ID %d", key.index, key.localSubnetID, dbApp.ID)
+			continue
+		}
+		if err != nil {
+			log.Errorf("Problem updating Kea stats for pool %d in local subnet ID %d, app ID %d: %s",
+				key.index, key.localSubnetID, dbApp.ID, err.Error())
+			lastErr = err
+			continue
+		}
+		samples = append(samples, sample)
+	}
+
+	// Record the pool utilization history used for the forecasts. The
+	// samples are rolled up with the other samples after the pull.
+	if err := dbmodel.AddUtilizationSamples(statsPuller.DB, samples); err != nil {
+		log.Errorf("Problem recording utilization samples of the pools of app ID %d: %s", dbApp.ID, err.Error())
+		lastErr = err
+	}
+	return lastErr
+}
+
 // A key that is used in map that is mapping from (local subnet id, inet family) to LocalSubnet struct.
 type localSubnetKey struct {
 	LocalSubnetID int64
@@ -258,6 +446,26 @@ func (statsPuller *StatsPuller) storeDaemonStats(response interface{}, subnetsMa
 	return lastErr
 }
 
+// Checks if the Kea daemon returns the per-pool statistics, i.e., its
+// version is 2.3.0 or later. The daemons with an unknown version are
+// assumed to not return them.
+func isPoolStatsSupported(daemon *dbmodel.Daemon) bool {
+	match := keaVersionPattern.FindStringSubmatch(strings.TrimSpace(daemon.Version))
+	if match == nil {
+		return false
+	}
+	for i, minimum := range minPoolStatsKeaVersion {
+		value, err := strconv.Atoi(match[i+1])
+		if err != nil {
+			return false
+		}
+		if value != minimum {
+			return value > minimum
+		}
+	}
+	return true
+}
+
 func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 	// If no dhcp daemons found then exit.
 	if len(dbApp.GetActiveDHCPDaemonNames()) == 0 {
@@ -274,6 +482,9 @@ func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 	cmdDaemons := []*dbmodel.Daemon{}
 	responses := []interface{}{}
 
+	// Daemons queried for the per-pool statistics
+	poolStatsDaemons := []*dbmodel.Daemon{}
+
 	// Iterate over active daemons, adding commands and response containers
 	// for dhcp4 and dhcp6 daemons.
 	for _, d := range dbApp.Daemons {
@@ -302,6 +513,10 @@ func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 					cmdDaemons = append(cmdDaemons, d)
 					responses = append(responses, RpsAddCmd4(&cmds, dhcp4Daemons))
 				}
+
+				if statsPuller.poolStatsEnabled && isPoolStatsSupported(d) {
+					poolStatsDaemons = append(poolStatsDaemons, d)
+				}
 			case dhcp6:
 
 				// Add daemon, cmd and response for DHCP6 lease stats
@@ -319,6 +534,10 @@ func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 					cmdDaemons = append(cmdDaemons, d)
 					responses = append(responses, RpsAddCmd6(&cmds, dhcp6Daemons))
 				}
+
+				if statsPuller.poolStatsEnabled && isPoolStatsSupported(d) {
+					poolStatsDaemons = append(poolStatsDaemons, d)
+				}
 			}
 		}
 	}
@@ -328,6 +547,19 @@ func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 		return nil
 	}
 
+	// Add the commands fetching the per-pool statistics after the other
+	// commands. They are returned by the statistic-get-all command, which
+	// is sent only to Kea 2.3.0 and later because the earlier versions
+	// don't return the per-pool statistics.
+	for _, d := range poolStatsDaemons {
+		cmdDaemons = append(cmdDaemons, d)
+		cmds = append(cmds, &keactrl.Command{
+			Command: "statistic-get-all",
+			Daemons: []string{d.Name},
+		})
+		responses = append(responses, &[]StatisticGetAllResponse{})
+	}
+
 	// forward commands to kea
 	ctx := context.Background()
 
@@ -382,6 +614,12 @@ func (statsPuller *StatsPuller) processAppResponses(dbApp *dbmodel.App, cmds []*
 					log.Errorf("Error handling statistic-get (v4) response: %+v", err)
 					lastErr = err
 				}
+			case "statistic-get-all":
+				err = statsPuller.storePoolStats(responses[idx], subnetsMap, dbApp, 4)
+				if err != nil {
+					log.Errorf("Error handling statistic-get-all (v4) response: %+v", err)
+					lastErr = err
+				}
 			}
 
 		case dhcp6:
@@ -398,6 +636,12 @@ func (statsPuller *StatsPuller) processAppResponses(dbApp *dbmodel.App, cmds []*
 					log.Errorf("Error handling statistic-get (v6) response: %+v", err)
 					lastErr = err
 				}
+			case "statistic-get-all":
+				err = statsPuller.storePoolStats(responses[idx], subnetsMap, dbApp, 6)
+				if err != nil {
+					log.Errorf("Error handling statistic-get-all (v6) response: %+v", err)
+					lastErr = err
+				}
 			}
 		}
 	}
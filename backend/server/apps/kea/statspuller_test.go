@@ -5,6 +5,7 @@ import (
 	"math"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/stretchr/testify/require"
@@ -12,13 +13,16 @@ import (
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test/dbmodel"
 )
 
 // Prepares the Kea mock. It accepts list of serialized JSON responses in order:
 // 1. DHCPv4
 // 2. DHCPv4 RSP
 // 3. DHCPv6
-// 4. DHCPv6 RSP.
+// 4. DHCPv6 RSP
+// 5. DHCPv4 per-pool statistics (optional)
+// 6. DHCPv6 per-pool statistics (optional).
 func createKeaMock(jsonFactory func(callNo int) (jsons []string)) func(callNo int, cmdResponses []interface{}) {
 	return func(callNo int, cmdResponses []interface{}) {
 		jsons := jsonFactory(callNo)
@@ -45,6 +49,12 @@ func createKeaMock(jsonFactory func(callNo int) (jsons []string)) func(callNo in
 		rpsCmd = []*keactrl.Command{}
 		_ = RpsAddCmd6(&rpsCmd, daemons)
 		keactrl.UnmarshalResponseList(rpsCmd[0], []byte(jsons[3]), cmdResponses[3])
+
+		// Optional per-pool statistics responses.
+		for i := 4; i < len(cmdResponses) && i < len(jsons); i++ {
+			command = keactrl.NewCommand("statistic-get-all", nil, nil)
+			keactrl.UnmarshalResponseList(command, []byte(jsons[i]), cmdResponses[i])
+		}
 	}
 }
 
@@ -354,7 +364,7 @@ func TestStatsPullerBasic(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(nil, nil)
 
 	// Act
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
 	defer sp.Shutdown()
 
 	// Assert
@@ -404,7 +414,7 @@ func TestStatsPullerEmptyResponse(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
 
 	// prepare stats puller
-	sp, _ := NewStatsPuller(db, fa)
+	sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
 	defer sp.Shutdown()
 
 	// Act
@@ -447,7 +457,7 @@ func checkStatsPullerPullStats(t *testing.T, statsFormat string) {
 	}
 
 	// prepare stats puller
-	sp, _ := NewStatsPuller(db, fa)
+	sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
 	defer sp.Shutdown()
 
 	// Act
@@ -494,8 +504,23 @@ func checkStatsPullerPullStats(t *testing.T, statsFormat string) {
 			require.InDelta(t, 60.0/(256.0+2), float64(sn.AddrUtilization)/1000.0, 0.001)
 			require.InDelta(t, 15.0/(1048.0+1), float64(sn.PdUtilization)/1000.0, 0.001)
 		}
+
+		// The utilization is recorded in the history.
+		samples, err := dbmodel.GetUtilizationSamples(db, dbmodel.UtilizationObjectSubnet, sn.ID,
+			dbmodel.UtilizationResolutionRaw, time.Time{}, time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, samples, 1)
+		require.Equal(t, sn.AddrUtilization, samples[0].AddrUtilization)
+		require.Equal(t, sn.PdUtilization, samples[0].PdUtilization)
 	}
 
+	// The daemon utilization is recorded in the history.
+	samples, err := dbmodel.GetUtilizationSamples(db, dbmodel.UtilizationObjectDaemon, 1,
+		dbmodel.UtilizationResolutionRaw, time.Time{}, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	require.Len(t, samples, 1)
+	require.NotZero(t, samples[0].AddrUtilization)
+
 	// Check global statistics
 	globals, err := dbmodel.GetAllStats(db)
 	require.NoError(t, err)
@@ -560,7 +585,7 @@ func TestGetStatsFromAppWithoutStatCmd(t *testing.T) {
 		},
 	}
 
-	sp, _ := NewStatsPuller(db, fa)
+	sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
 
 	// Act
 	err := sp.getStatsFromApp(app)
@@ -920,7 +945,7 @@ func TestPrepareHAEnvironment(t *testing.T) {
 	keaMock := createKeaMock(func(callNo int) (jsons []string) { return []string{} })
 
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
 
 	// Assert
 	require.NoError(t, err)
@@ -943,7 +968,7 @@ func TestStatsPullerPullStatsHAPairNotInitializedYet(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
 
 	// prepare stats puller
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
 	require.NoError(t, err)
 	defer sp.Shutdown()
 
@@ -986,7 +1011,7 @@ func TestStatsPullerPullStatsHAPairHealthy(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
 
 	// prepare stats puller
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
 	require.NoError(t, err)
 	defer sp.Shutdown()
 
@@ -1025,7 +1050,7 @@ func TestStatsPullerPullStatsHAPairPrimaryIsDownSecondaryIsReady(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
 
 	// prepare stats puller
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
 	require.NoError(t, err)
 	defer sp.Shutdown()
 
@@ -1064,7 +1089,7 @@ func TestStatsPullerPullStatsHAPairPrimaryIsDownSecondaryIsDown(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
 
 	// prepare stats puller
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
 	require.NoError(t, err)
 	defer sp.Shutdown()
 
@@ -1078,3 +1103,285 @@ func TestStatsPullerPullStatsHAPairPrimaryIsDownSecondaryIsDown(t *testing.T) {
 
 	verifyCountingStatisticsFromPrimary(t, db)
 }
+
+// Test that the per-pool statistics are extracted from the statistic-get-all
+// response.
+func TestGetPoolStats(t *testing.T) {
+	// Arrange
+	arguments := map[string][][]json.RawMessage{
+		"subnet[1].pool[0].assigned-addresses": {{json.RawMessage("5"), json.RawMessage(`"2023-03-01 12:00:00.000000"`)}, {json.RawMessage("4")}},
+		"subnet[1].pool[0].total-addresses":    {{json.RawMessage("10")}},
+		"subnet[1].pd-pool[2].total-pds":       {{json.RawMessage("18446744073709551616")}},
+		"subnet[1].pd-pool[2].assigned-pds":    {{json.RawMessage("-1")}},
+		"subnet[1].assigned-addresses":         {{json.RawMessage("7")}},
+		"subnet[1].pool[1].total-addresses":    {},
+		"subnet[1].pool[3].total-addresses":    {{json.RawMessage(`"foo"`)}},
+		"pkt4-ack-sent":                        {{json.RawMessage("1")}},
+	}
+
+	// Act
+	poolStats := getPoolStats(arguments)
+
+	// Assert
+	require.Len(t, poolStats, 2)
+	stats := poolStats[poolStatsKey{localSubnetID: 1, index: 0}]
+	require.Len(t, stats, 2)
+	require.EqualValues(t, uint64(5), stats["assigned-addresses"])
+	require.EqualValues(t, uint64(10), stats["total-addresses"])
+
+	stats = poolStats[poolStatsKey{localSubnetID: 1, prefixPool: true, index: 2}]
+	require.Len(t, stats, 2)
+	expected, _ := big.NewInt(0).SetString("18446744073709551616", 10)
+	require.Equal(t, expected, stats["total-pds"])
+	require.EqualValues(t, int64(-1), stats["assigned-pds"])
+}
+
+// Test that the per-pool statistics are pulled and stored in the pools.
+func TestStatsPullerPullPoolStats(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+	_ = dbmodel.InitializeStats(db)
+
+	v4Config, v6Config := createDhcpConfigs()
+	app := createAppWithSubnets(t, db, 0, v4Config, v6Config)
+	for _, daemon := range app.Daemons {
+		daemon.Version = "2.3.0"
+	}
+
+	keaMock := createKeaMock(func(callNo int) []string {
+		return []string{
+			`[{
+				"result": 0, "text": "Everything is fine",
+				"arguments": {
+					"result-set": {
+						"columns": [ "subnet-id", "total-addresses", "assigned-addresses", "declined-addresses" ],
+						"rows": [ [ 20, 10, 10, 0 ] ]
+					},
+					"timestamp": "2023-03-01 12:00:00.000000"
+				}
+			}]`,
+			`[{
+				"result": 0, "text": "Everything is fine",
+				"arguments": {
+					"pkt4-ack-sent": [ [ 0, "2023-03-01 12:00:00.000000" ] ]
+				}
+			}]`,
+			`[{
+				"result": 0, "text": "Everything is fine",
+				"arguments": {
+					"result-set": {
+						"columns": [ "subnet-id", "total-nas", "assigned-nas", "declined-nas", "total-pds", "assigned-pds" ],
+						"rows": [ [ 50, 65280, 16320, 0, 65536, 0 ] ]
+					},
+					"timestamp": "2023-03-01 12:00:00.000000"
+				}
+			}]`,
+			`[{
+				"result": 0, "text": "Everything is fine",
+				"arguments": {
+					"pkt6-reply-sent": [ [ 0, "2023-03-01 12:00:00.000000" ] ]
+				}
+			}]`,
+			`[{
+				"result": 0, "text": "Everything is fine",
+				"arguments": {
+					"subnet[20].pool[0].total-addresses": [ [ 10, "2023-03-01 12:00:00.000000" ] ],
+					"subnet[20].pool[0].assigned-addresses": [ [ 10, "2023-03-01 12:00:00.000000" ] ],
+					"subnet[20].total-addresses": [ [ 10, "2023-03-01 12:00:00.000000" ] ]
+				}
+			}]`,
+			`[{
+				"result": 0, "text": "Everything is fine",
+				"arguments": {
+					"subnet[50].pool[0].total-nas": [ [ 65280, "2023-03-01 12:00:00.000000" ] ],
+					"subnet[50].pool[0].assigned-nas": [ [ 16320, "2023-03-01 12:00:00.000000" ] ],
+					"subnet[50].pd-pool[0].total-pds": [ [ 65536, "2023-03-01 12:00:00.000000" ] ],
+					"subnet[50].pd-pool[0].assigned-pds": [ [ 0, "2023-03-01 12:00:00.000000" ] ],
+					"subnet[50].pd-pool[1].assigned-pds": [ [ 1, "2023-03-01 12:00:00.000000" ] ]
+				}
+			}]`,
+		}
+	})
+
+	fa := agentcommtest.NewFakeAgents(keaMock, nil)
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	for i := range app.Daemons {
+		sharedNetworks, subnets, err := detectDaemonNetworks(db, app.Daemons[i], lookup)
+		require.NoError(t, err)
+		_, err = dbmodel.CommitNetworksIntoDB(db, sharedNetworks, subnets, app.Daemons[i])
+		require.NoError(t, err)
+	}
+
+	sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
+	defer sp.Shutdown()
+
+	// Act
+	err := sp.getStatsFromApp(app)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, fa.RecordedCommands, 6)
+	require.Equal(t, "statistic-get-all", fa.RecordedCommands[4].GetCommand())
+	require.Equal(t, "statistic-get-all", fa.RecordedCommands[5].GetCommand())
+
+	subnets, err := dbmodel.GetAllSubnets(db, 0)
+	require.NoError(t, err)
+	poolsChecked := 0
+	for _, sn := range subnets {
+		subnet, err := dbmodel.GetSubnet(db, sn.ID)
+		require.NoError(t, err)
+		switch subnet.Prefix {
+		case "192.0.3.0/24":
+			// The subnet has a single full pool.
+			pool := subnet.LocalSubnets[0].AddressPools[0]
+			require.EqualValues(t, uint64(10), pool.Stats["assigned-addresses"])
+			require.EqualValues(t, 1000, pool.Utilization)
+			require.NotZero(t, pool.StatsCollectedAt)
+			// The pool utilization is recorded in the history.
+			samples, err := dbmodel.GetUtilizationSamples(db, dbmodel.UtilizationObjectAddressPool, pool.ID,
+				dbmodel.UtilizationResolutionRaw, pool.StatsCollectedAt.Add(-time.Minute), pool.StatsCollectedAt.Add(time.Minute))
+			require.NoError(t, err)
+			require.Len(t, samples, 1)
+			require.EqualValues(t, 1000, samples[0].AddrUtilization)
+			require.Zero(t, samples[0].PdUtilization)
+			poolsChecked++
+		case "2001:db8:3::/64":
+			pool := subnet.LocalSubnets[0].AddressPools[0]
+			require.EqualValues(t, uint64(16320), pool.Stats["assigned-nas"])
+			require.EqualValues(t, 250, pool.Utilization)
+			prefixPool := subnet.LocalSubnets[0].PrefixPools[0]
+			require.EqualValues(t, uint64(65536), prefixPool.Stats["total-pds"])
+			require.Zero(t, prefixPool.Utilization)
+			require.NotZero(t, prefixPool.StatsCollectedAt)
+			samples, err := dbmodel.GetUtilizationSamples(db, dbmodel.UtilizationObjectPrefixPool, prefixPool.ID,
+				dbmodel.UtilizationResolutionRaw, prefixPool.StatsCollectedAt.Add(-time.Minute), prefixPool.StatsCollectedAt.Add(time.Minute))
+			require.NoError(t, err)
+			require.Len(t, samples, 1)
+			require.Zero(t, samples[0].PdUtilization)
+			poolsChecked += 2
+		default:
+			for _, localSubnet := range subnet.LocalSubnets {
+				for _, pool := range localSubnet.AddressPools {
+					require.Nil(t, pool.Stats)
+				}
+			}
+		}
+	}
+	require.Equal(t, 3, poolsChecked)
+}
+
+// Test that the Kea versions returning the per-pool statistics are
+// recognized.
+func TestIsPoolStatsSupported(t *testing.T) {
+	for version, supported := range map[string]bool{
+		"2.3.0":     true,
+		"2.3.1-git": true,
+		"2.4.0":     true,
+		"3.0.0":     true,
+		"2.2.0":     false,
+		"2.2.10":    false,
+		"1.9.11":    false,
+		"":          false,
+		"foo":       false,
+	} {
+		require.Equal(t, supported, isPoolStatsSupported(&dbmodel.Daemon{Version: version}), version)
+	}
+}
+
+// Test that the per-pool statistics are not pulled from the Kea versions
+// earlier than 2.3.0 and when they are disabled.
+func TestStatsPullerPullPoolStatsUnsupportedOrDisabled(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+	_ = dbmodel.InitializeStats(db)
+
+	v4Config, v6Config := createDhcpConfigs()
+	app := createAppWithSubnets(t, db, 0, v4Config, v6Config)
+
+	keaMock := createKeaMock(func(callNo int) []string {
+		return []string{
+			`[{
+				"result": 0, "text": "Everything is fine",
+				"arguments": {
+					"result-set": {
+						"columns": [ "subnet-id", "total-addresses", "assigned-addresses", "declined-addresses" ],
+						"rows": [ [ 20, 10, 10, 0 ] ]
+					},
+					"timestamp": "2023-03-01 12:00:00.000000"
+				}
+			}]`,
+			`[{
+				"result": 0, "text": "Everything is fine",
+				"arguments": {
+					"pkt4-ack-sent": [ [ 0, "2023-03-01 12:00:00.000000" ] ]
+				}
+			}]`,
+			`[{
+				"result": 0, "text": "Everything is fine",
+				"arguments": {
+					"result-set": {
+						"columns": [ "subnet-id", "total-nas", "assigned-nas", "declined-nas", "total-pds", "assigned-pds" ],
+						"rows": [ [ 50, 65280, 16320, 0, 65536, 0 ] ]
+					},
+					"timestamp": "2023-03-01 12:00:00.000000"
+				}
+			}]`,
+			`[{
+				"result": 0, "text": "Everything is fine",
+				"arguments": {
+					"pkt6-reply-sent": [ [ 0, "2023-03-01 12:00:00.000000" ] ]
+				}
+			}]`,
+		}
+	})
+
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	for i := range app.Daemons {
+		sharedNetworks, subnets, err := detectDaemonNetworks(db, app.Daemons[i], lookup)
+		require.NoError(t, err)
+		_, err = dbmodel.CommitNetworksIntoDB(db, sharedNetworks, subnets, app.Daemons[i])
+		require.NoError(t, err)
+	}
+
+	t.Run("unsupported version", func(t *testing.T) {
+		for _, daemon := range app.Daemons {
+			daemon.Version = "2.2.0"
+		}
+		fa := agentcommtest.NewFakeAgents(keaMock, nil)
+		sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, true)
+		defer sp.Shutdown()
+
+		// Act
+		err := sp.getStatsFromApp(app)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, fa.RecordedCommands, 4)
+		for _, command := range fa.RecordedCommands {
+			require.NotEqual(t, "statistic-get-all", command.GetCommand())
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		for _, daemon := range app.Daemons {
+			daemon.Version = "2.3.0"
+		}
+		fa := agentcommtest.NewFakeAgents(keaMock, nil)
+		sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{}, false)
+		defer sp.Shutdown()
+
+		// Act
+		err := sp.getStatsFromApp(app)
+
+		// Assert
+		require.NoError(t, err)
+		require.Len(t, fa.RecordedCommands, 4)
+		for _, command := range fa.RecordedCommands {
+			require.NotEqual(t, "statistic-get-all", command.GetCommand())
+		}
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	keaconfig "isc.org/stork/appcfg/kea"
 	keactrl "isc.org/stork/appctrl/kea"
 	"isc.org/stork/server/agentcomm"
 	dbops "isc.org/stork/server/database"
@@ -18,9 +19,10 @@ import (
 // Represents the status of the local server (the one that
 // responded to the command).
 type HALocalStatus struct {
-	Role   string
-	Scopes []string
-	State  string
+	Role       string
+	Scopes     []string
+	State      string
+	ServerName string `json:"server-name"`
 }
 
 // Represents the status of the remote server.
@@ -35,6 +37,7 @@ type HARemoteStatus struct {
 	UnackedClients     int64    `json:"unacked-clients"`
 	UnackedClientsLeft int64    `json:"unacked-clients-left"`
 	AnalyzedPackets    int64    `json:"analyzed-packets"`
+	ServerName         string   `json:"server-name"`
 }
 
 // Represents the status of the HA enabled Kea servers.
@@ -44,7 +47,9 @@ type HAServersStatus struct {
 }
 
 // Represent a status of a single HA relationship encapsulated in the
-// high-availability list of a status-get response.
+// high-availability list of a status-get response. The list contains
+// multiple relationships in the hub-and-spoke configuration. Kea versions
+// supporting it include the server names in the status.
 type HARelationshipStatus struct {
 	HAMode    string          `json:"ha-mode"`
 	HAServers HAServersStatus `json:"ha-servers"`
@@ -218,16 +223,95 @@ func updateHAServiceStatus(status *HAServersStatus, daemon *dbmodel.Daemon, serv
 	}
 }
 
+// Finds the HA service corresponding to the status of the HA relationship
+// returned by the daemon. The index is the position of the relationship
+// status in the status-get response. The relationship is identified by the
+// local server name if it is returned by Kea. Otherwise, the position of
+// the relationship in the daemon's configuration is used. The services
+// detected before supporting multiple relationships are assumed to
+// correspond to the first relationship. It returns nil if the relationship
+// is not found in the configuration, or the daemon is neither the primary
+// nor the secondary server of the matching service.
+func findHAServiceForRelationshipStatus(services []dbmodel.Service, daemon *dbmodel.Daemon, index int, status *HAServersStatus) *dbmodel.BaseHAService {
+	var relationship *keaconfig.HA
+	if daemon.KeaDaemon != nil && daemon.KeaDaemon.Config != nil {
+		if _, params, ok := daemon.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary(); ok {
+			if len(status.Local.ServerName) > 0 {
+				relationship = params.GetRelationshipByServerName(status.Local.ServerName)
+				if relationship == nil {
+					return nil
+				}
+			} else if relationships := params.GetRelationships(); index < len(relationships) {
+				relationship = relationships[index]
+			}
+		}
+	}
+	for i := range services {
+		haService := services[i].HAService
+		if haService == nil || haService.HAType != daemon.Name ||
+			(haService.PrimaryID != daemon.ID && haService.SecondaryID != daemon.ID) {
+			continue
+		}
+		if relationship != nil && len(haService.Relationship) > 0 {
+			if haService.Relationship == relationship.GetRelationshipName() {
+				return haService
+			}
+			continue
+		}
+		if index == 0 {
+			return haService
+		}
+	}
+	return nil
+}
+
+// Returns the HA state transitions of the app's daemons belonging to the
+// HA service. The transitions carry the states of the daemons and their
+// view of the partners, i.e. whether the communication with the partner
+// is interrupted and how many clients are unacked by the partner.
+func getHAStateTransitions(app *dbmodel.App, service *dbmodel.Service) (transitions []*dbmodel.HAStateTransition) {
+	haService := service.HAService
+	for _, daemon := range app.Daemons {
+		transition := &dbmodel.HAStateTransition{
+			ServiceID: service.ID,
+			DaemonID:  daemon.ID,
+		}
+		switch daemon.ID {
+		case haService.PrimaryID:
+			transition.State = haService.PrimaryLastState
+			transition.PartnerCommInterrupted = haService.SecondaryCommInterrupted
+			transition.PartnerUnackedClients = haService.SecondaryUnackedClients
+		case haService.SecondaryID:
+			transition.State = haService.SecondaryLastState
+			transition.PartnerCommInterrupted = haService.PrimaryCommInterrupted
+			transition.PartnerUnackedClients = haService.PrimaryUnackedClients
+		default:
+			continue
+		}
+		transitions = append(transitions, transition)
+	}
+	return transitions
+}
+
 // Iterates over the slice of HA services and updates them in the database.
-func (puller *HAStatusPuller) commitHAServicesStatus(appID int64, services []dbmodel.Service) {
+// The HA state transitions of the app's daemons are recorded if their
+// states have changed since the last pull.
+func (puller *HAStatusPuller) commitHAServicesStatus(app *dbmodel.App, services []dbmodel.Service) {
 	for i := range services {
 		// Update the information about the HA service in the database.
 		err := dbmodel.UpdateBaseHAService(puller.DB, services[i].HAService)
 		if err != nil {
-			log.Errorf("Error occurred while updating HA services status for Kea app %d: %+v", appID, err)
+			log.Errorf("Error occurred while updating HA services status for Kea app %d: %+v", app.ID, err)
 
 			continue
 		}
+		// Only the daemons belonging to the app are recorded. The states
+		// of their partners are recorded when the partners' apps are pulled.
+		for _, transition := range getHAStateTransitions(app, &services[i]) {
+			if _, err = dbmodel.AddHAStateTransitionIfChanged(puller.DB, transition); err != nil {
+				log.Errorf("Error occurred while recording HA state transition for Kea app %d: %+v", app.ID, err)
+			}
+		}
 	}
 }
 
@@ -294,8 +378,8 @@ func (puller *HAStatusPuller) pullDataForApp(app *dbmodel.App) (bool, bool) {
 				dbServices[j].HAService.SecondaryLastScopes = []string{}
 				dbServices[j].HAService.SecondaryReachable = false
 			}
-			haServices = append(haServices, dbServices[j])
 		}
+		haServices = append(haServices, dbServices[j])
 	}
 
 	ctx := context.Background()
@@ -308,43 +392,35 @@ func (puller *HAStatusPuller) pullDataForApp(app *dbmodel.App) (bool, bool) {
 	}
 	// Go over the returned status values and match with the daemons.
 	for _, status := range appStatus {
-		// If no HA status, there is nothing to do.
-		if status.HAServers == nil && len(status.HA) == 0 {
-			continue
+		// Kea versions earlier than 1.7.8 return the status of a single
+		// relationship. Later versions return the list of relationships.
+		relationshipStatuses := status.HA
+		if len(relationshipStatuses) == 0 && status.HAServers != nil {
+			relationshipStatuses = []HARelationshipStatus{{HAServers: *status.HAServers}}
 		}
-		// Find the matching service for the returned status.
-		index := -1
-
-		for i := range haServices {
-			if haServices[i].HAService.HAType == status.Daemon {
-				index = i
-			}
-		}
-		if index < 0 {
+		// If no HA status, there is nothing to do.
+		if len(relationshipStatuses) == 0 {
 			continue
 		}
-		service := haServices[index].HAService
 		for _, daemon := range app.Daemons {
-			// Update the HA service status only if the given server is primary
-			// or secondary.
-			if service.PrimaryID == daemon.ID || service.SecondaryID == daemon.ID {
-				// todo: Currently Kea supports only one HA service per daemon. This
-				// will change but for now it is safe to assume that only one status
-				// is returned. Supporting more requires some mechanisms to
-				// distinguish the HA relationships which should be first designed
-				// on the Kea side.
-				if len(status.HA) > 0 {
-					updateHAServiceStatus(&status.HA[0].HAServers, daemon, service)
-				} else if status.HAServers != nil {
-					updateHAServiceStatus(status.HAServers, daemon, service)
+			if daemon.Name != status.Daemon {
+				continue
+			}
+			// Update the HA service of each relationship for which the given
+			// server is primary or secondary.
+			for i := range relationshipStatuses {
+				service := findHAServiceForRelationshipStatus(haServices, daemon, i, &relationshipStatuses[i].HAServers)
+				if service == nil {
+					continue
 				}
+				updateHAServiceStatus(&relationshipStatuses[i].HAServers, daemon, service)
 			}
 		}
 	}
 
 	// Update the services as appropriate regardless if we successfully communicated
 	// with the servers or not.
-	puller.commitHAServicesStatus(app.ID, haServices)
+	puller.commitHAServicesStatus(app, haServices)
 	return true, true
 }
 
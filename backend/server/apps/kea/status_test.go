@@ -713,6 +713,69 @@ func testPullHAStatus(t *testing.T, version178 bool) {
 		require.EqualValues(t, 4, service.HAService.PrimaryUnackedClientsLeft)
 		require.EqualValues(t, 15, service.HAService.PrimaryAnalyzedPackets)
 	}
+
+	// The state transitions of the primary DHCPv4 server should be recorded.
+	transitions, total, err := dbmodel.GetHAStateTransitionsByPage(db, services[0].ID, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Len(t, transitions, 2)
+	require.EqualValues(t, keaApp.Daemons[0].ID, transitions[0].DaemonID)
+	require.Equal(t, dbmodel.HAStatePartnerDown, transitions[0].State)
+	require.Equal(t, dbmodel.HAStateLoadBalancing, transitions[0].PreviousState)
+	require.True(t, transitions[0].IsFailover())
+	require.Zero(t, transitions[0].PartnerUnackedClients)
+	require.EqualValues(t, keaApp.Daemons[0].ID, transitions[1].DaemonID)
+	require.Equal(t, dbmodel.HAStateLoadBalancing, transitions[1].State)
+	require.Empty(t, transitions[1].PreviousState)
+	if version178 {
+		require.NotNil(t, transitions[1].PartnerCommInterrupted)
+		require.True(t, *transitions[1].PartnerCommInterrupted)
+		require.EqualValues(t, 2, transitions[1].PartnerUnackedClients)
+	}
+}
+
+// Test that the HA state transitions are created only for the primary
+// and secondary servers belonging to the app.
+func TestGetHAStateTransitions(t *testing.T) {
+	app := &dbmodel.App{
+		Daemons: []*dbmodel.Daemon{
+			{ID: 1, Name: "dhcp4"},
+			{ID: 2, Name: "dhcp6"},
+			{ID: 3, Name: "ca"},
+		},
+	}
+	commInterrupted := true
+	service := &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			ID: 7,
+		},
+		HAService: &dbmodel.BaseHAService{
+			PrimaryID:                1,
+			SecondaryID:              4,
+			PrimaryLastState:         dbmodel.HAStatePartnerDown,
+			SecondaryLastState:       dbmodel.HAStateUnavailable,
+			SecondaryCommInterrupted: &commInterrupted,
+			SecondaryUnackedClients:  5,
+			PrimaryUnackedClients:    8,
+		},
+	}
+	transitions := getHAStateTransitions(app, service)
+	require.Len(t, transitions, 1)
+	require.EqualValues(t, 7, transitions[0].ServiceID)
+	require.EqualValues(t, 1, transitions[0].DaemonID)
+	require.Equal(t, dbmodel.HAStatePartnerDown, transitions[0].State)
+	require.Equal(t, &commInterrupted, transitions[0].PartnerCommInterrupted)
+	require.EqualValues(t, 5, transitions[0].PartnerUnackedClients)
+
+	// The app's daemon is the secondary server.
+	service.HAService.PrimaryID = 4
+	service.HAService.SecondaryID = 2
+	transitions = getHAStateTransitions(app, service)
+	require.Len(t, transitions, 1)
+	require.EqualValues(t, 2, transitions[0].DaemonID)
+	require.Equal(t, dbmodel.HAStateUnavailable, transitions[0].State)
+	require.Nil(t, transitions[0].PartnerCommInterrupted)
+	require.EqualValues(t, 8, transitions[0].PartnerUnackedClients)
 }
 
 // Test that HA status can be fetched and updated via the HA status puller
@@ -726,3 +789,69 @@ func TestPullHAStatus(t *testing.T) {
 func TestPullHAStatus178(t *testing.T) {
 	testPullHAStatus(t, true)
 }
+
+// Test that the status of each HA relationship is matched with the
+// service corresponding to the relationship.
+func TestFindHAServiceForRelationshipStatus(t *testing.T) {
+	hub := &dbmodel.Daemon{
+		ID:   1,
+		Name: "dhcp4",
+		KeaDaemon: &dbmodel.KeaDaemon{
+			Config:        getHAHubAndSpokeTestConfig("server1", "server3"),
+			KeaDHCPDaemon: &dbmodel.KeaDHCPDaemon{},
+		},
+	}
+	services := []dbmodel.Service{
+		{
+			HAService: &dbmodel.BaseHAService{
+				HAType:       "dhcp4",
+				Relationship: "server1,server2",
+				PrimaryID:    1,
+				SecondaryID:  2,
+			},
+		},
+		{
+			HAService: &dbmodel.BaseHAService{
+				HAType:       "dhcp4",
+				Relationship: "server3,server4",
+				PrimaryID:    1,
+				SecondaryID:  4,
+			},
+		},
+	}
+
+	// The relationship is identified by the server name.
+	service := findHAServiceForRelationshipStatus(services, hub, 0, &HAServersStatus{
+		Local: HALocalStatus{ServerName: "server3"},
+	})
+	require.Same(t, services[1].HAService, service)
+	service = findHAServiceForRelationshipStatus(services, hub, 1, &HAServersStatus{
+		Local: HALocalStatus{ServerName: "server1"},
+	})
+	require.Same(t, services[0].HAService, service)
+
+	// The server name is not returned by the older Kea versions. The
+	// position of the relationship in the configuration is used.
+	service = findHAServiceForRelationshipStatus(services, hub, 0, &HAServersStatus{})
+	require.Same(t, services[0].HAService, service)
+	service = findHAServiceForRelationshipStatus(services, hub, 1, &HAServersStatus{})
+	require.Same(t, services[1].HAService, service)
+	require.Nil(t, findHAServiceForRelationshipStatus(services, hub, 2, &HAServersStatus{}))
+
+	// Unknown server name.
+	require.Nil(t, findHAServiceForRelationshipStatus(services, hub, 0, &HAServersStatus{
+		Local: HALocalStatus{ServerName: "server5"},
+	}))
+
+	// The service without the relationship name corresponds to the first
+	// relationship.
+	services[1].HAService.Relationship = ""
+	services = services[1:]
+	service = findHAServiceForRelationshipStatus(services, hub, 0, &HAServersStatus{})
+	require.Same(t, services[0].HAService, service)
+	require.Nil(t, findHAServiceForRelationshipStatus(services, hub, 1, &HAServersStatus{}))
+
+	// The daemon is not primary nor secondary.
+	services[0].HAService.PrimaryID = 3
+	require.Nil(t, findHAServiceForRelationshipStatus(services, hub, 0, &HAServersStatus{}))
+}
@@ -104,13 +104,21 @@ func updateMachineFields(db *dbops.PgDB, dbMachine *dbmodel.Machine, m *agentcom
 }
 
 // appCompare compares two apps for equality.  Two apps are considered equal if
-// their type matches and if they have the same control port.  Return true if
-// equal, false otherwise.
+// their type matches and if they have the same configuration path. If the
+// configuration path is unknown for any of the apps, they are considered equal
+// if they have the same control port.  Return true if equal, false otherwise.
 func appCompare(dbApp *dbmodel.App, app *agentcomm.App) bool {
 	if dbApp.Type.String() != app.Type {
 		return false
 	}
 
+	// Multiple instances of the same app may run on one machine, e.g., in
+	// different network namespaces, and use the same control port. The
+	// configuration path is the only reliable way to tell them apart.
+	if dbApp.ConfigPath != "" && app.ConfigPath != "" {
+		return dbApp.ConfigPath == app.ConfigPath
+	}
+
 	var controlPortEqual bool
 	for _, pt1 := range dbApp.AccessPoints {
 		if pt1.Type != dbmodel.AccessPointControl {
@@ -179,6 +187,11 @@ func mergeNewAndOldApps(db *dbops.PgDB, dbMachine *dbmodel.Machine, discoveredAp
 		// try to match apps on machine with old apps from database
 		var dbApp *dbmodel.App
 		for _, dbAppOld := range oldAppsList {
+			// An app recorded in the database can correspond to at most
+			// one detected app.
+			if isAppMatched(matchedApps, dbAppOld) {
+				continue
+			}
 			// If there is one app of a given type detected on the machine and one app recorded in the database
 			// we assume that this is the same app. If there are more apps of a given type than used to be,
 			// or there are less apps than it used to be we have to compare their access control information
@@ -202,6 +215,7 @@ func mergeNewAndOldApps(db *dbops.PgDB, dbMachine *dbmodel.Machine, discoveredAp
 		} else {
 			dbApp.Machine = dbMachine
 		}
+		dbApp.ConfigPath = app.ConfigPath
 		allApps = append(allApps, dbApp)
 
 		// add or update access points
@@ -220,14 +234,7 @@ func mergeNewAndOldApps(db *dbops.PgDB, dbMachine *dbmodel.Machine, discoveredAp
 
 	// add old, not matched apps to all apps
 	for _, dbApp := range oldAppsList {
-		toAdd := true
-		for _, app := range matchedApps {
-			if dbApp == app {
-				toAdd = false
-				break
-			}
-		}
-		if toAdd {
+		if !isAppMatched(matchedApps, dbApp) {
 			dbApp.Machine = dbMachine
 			allApps = append(allApps, dbApp)
 		}
@@ -236,6 +243,17 @@ func mergeNewAndOldApps(db *dbops.PgDB, dbMachine *dbmodel.Machine, discoveredAp
 	return allApps, ""
 }
 
+// Checks if the app from the database has been already matched with one of
+// the detected apps.
+func isAppMatched(matchedApps []*dbmodel.App, dbApp *dbmodel.App) bool {
+	for _, app := range matchedApps {
+		if dbApp == app {
+			return true
+		}
+	}
+	return false
+}
+
 // Retrieve remotely machine and its apps state, and store it in the database.
 func GetMachineAndAppsState(ctx context.Context, db *dbops.PgDB, dbMachine *dbmodel.Machine, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter, reviewDispatcher configreview.Dispatcher, lookup keaconfig.DHCPOptionDefinitionLookup) string {
 	ctx2, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -286,8 +304,11 @@ func GetMachineAndAppsState(ctx context.Context, db *dbops.PgDB, dbMachine *dbmo
 				conditionallyBeginKeaConfigReviews(dbApp, state, reviewDispatcher, isStorkAgentChanged)
 			}
 		case dbmodel.AppTypeBind9:
-			bind9.GetAppState(ctx2, agents, dbApp, eventCenter)
-			err = bind9.CommitAppIntoDB(db, dbApp, eventCenter)
+			state := bind9.GetAppState(ctx2, agents, dbApp, eventCenter)
+			// The zone status queries have their own time budget, so they
+			// don't compete with the state pull of the other apps.
+			bind9.GetZonesStatus(ctx, agents, dbApp, state)
+			err = bind9.CommitAppIntoDB(db, dbApp, eventCenter, state)
 		default:
 			err = nil
 		}
@@ -161,6 +161,84 @@ func TestAppCompare(t *testing.T) {
 	// different ports so not equal
 	dbApp.AccessPoints[0].Port = 4321
 	require.False(t, appCompare(dbApp, app))
+
+	// the same config paths so equal regardless of the ports
+	dbApp.ConfigPath = "/etc/kea1/kea-ctrl-agent.conf"
+	app.ConfigPath = "/etc/kea1/kea-ctrl-agent.conf"
+	require.True(t, appCompare(dbApp, app))
+
+	// different config paths so not equal regardless of the ports
+	dbApp.AccessPoints[0].Port = 1234
+	app.ConfigPath = "/etc/kea2/kea-ctrl-agent.conf"
+	require.False(t, appCompare(dbApp, app))
+
+	// config path unknown for one of the apps so the ports are compared
+	dbApp.ConfigPath = ""
+	require.True(t, appCompare(dbApp, app))
+}
+
+// Test that multiple Kea apps running on the same machine and using the
+// same control port are matched with the apps in the database by their
+// configuration paths.
+func TestMergeNewAndOldAppsMultipleKeaInstances(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:    "localhost",
+		AgentPort:  8080,
+		Authorized: true,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	var dbApps []*dbmodel.App
+	for _, configPath := range []string{"/etc/kea1/kea-ctrl-agent.conf", "/etc/kea2/kea-ctrl-agent.conf"} {
+		var ap []*dbmodel.AccessPoint
+		app := &dbmodel.App{
+			MachineID:    m.ID,
+			Type:         dbmodel.AppTypeKea,
+			Active:       true,
+			ConfigPath:   configPath,
+			AccessPoints: dbmodel.AppendAccessPoint(ap, dbmodel.AccessPointControl, "127.0.0.1", "", 8000, false),
+		}
+		_, err = dbmodel.AddApp(db, app)
+		require.NoError(t, err)
+		dbApps = append(dbApps, app)
+	}
+
+	// The agent reports the apps in the reverse order.
+	discoveredApps := []*agentcomm.App{
+		{
+			Type:         datamodel.AppTypeKea.String(),
+			AccessPoints: agentcomm.MakeAccessPoint(dbmodel.AccessPointControl, "127.0.0.1", "", 8000),
+			ConfigPath:   "/etc/kea2/kea-ctrl-agent.conf",
+		},
+		{
+			Type:         datamodel.AppTypeKea.String(),
+			AccessPoints: agentcomm.MakeAccessPoint(dbmodel.AccessPointControl, "127.0.0.1", "", 8000),
+			ConfigPath:   "/etc/kea1/kea-ctrl-agent.conf",
+		},
+		{
+			Type:         datamodel.AppTypeKea.String(),
+			AccessPoints: agentcomm.MakeAccessPoint(dbmodel.AccessPointControl, "127.0.0.1", "", 8000),
+			ConfigPath:   "/etc/kea3/kea-ctrl-agent.conf",
+		},
+	}
+
+	allApps, errStr := mergeNewAndOldApps(db, m, discoveredApps)
+	require.Empty(t, errStr)
+	require.Len(t, allApps, 3)
+
+	require.Equal(t, dbApps[1].ID, allApps[0].ID)
+	require.Equal(t, "/etc/kea2/kea-ctrl-agent.conf", allApps[0].ConfigPath)
+
+	require.Equal(t, dbApps[0].ID, allApps[1].ID)
+	require.Equal(t, "/etc/kea1/kea-ctrl-agent.conf", allApps[1].ConfigPath)
+
+	// The new instance is not matched with any existing app.
+	require.Zero(t, allApps[2].ID)
+	require.Equal(t, "/etc/kea3/kea-ctrl-agent.conf", allApps[2].ConfigPath)
 }
 
 // Test that new configuration review is scheduled when a daemon's
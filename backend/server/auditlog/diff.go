@@ -0,0 +1,111 @@
+package auditlog
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Value stored in the audit log instead of the sensitive values.
+const RedactedValue = "<redacted>"
+
+// Fragments of the field names holding the sensitive values. The values
+// of these fields are never stored in the audit log. The log only tells
+// that they have changed.
+var sensitiveFieldFragments = []string{"password", "secret", "token"}
+
+// Checks if the field holds a sensitive value.
+func isSensitiveField(name string) bool {
+	name = strings.ToLower(name)
+	for _, fragment := range sensitiveFieldFragments {
+		if strings.Contains(name, fragment) {
+			return true
+		}
+	}
+	return false
+}
+
+// Converts the object to the map of its fields using the JSON encoding.
+// The nested objects are flattened, i.e. their fields are stored under
+// the keys being the dot-separated paths. The arrays are not flattened.
+func flatten(object interface{}) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if object == nil || (reflect.ValueOf(object).Kind() == reflect.Ptr && reflect.ValueOf(object).IsNil()) {
+		return fields, nil
+	}
+	encoded, err := json.Marshal(object)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot serialize the object for the audit log")
+	}
+	var decoded interface{}
+	if err = json.Unmarshal(encoded, &decoded); err != nil {
+		return nil, errors.Wrap(err, "cannot parse the serialized object for the audit log")
+	}
+	flattenValue("", decoded, fields)
+	return fields, nil
+}
+
+// Recursively stores the value in the fields map under the specified path.
+func flattenValue(path string, value interface{}, fields map[string]interface{}) {
+	if object, ok := value.(map[string]interface{}); ok && len(object) > 0 {
+		for key, nested := range object {
+			nestedPath := key
+			if path != "" {
+				nestedPath = path + "." + key
+			}
+			flattenValue(nestedPath, nested, fields)
+		}
+		return
+	}
+	if path != "" && value != nil {
+		fields[path] = value
+	}
+}
+
+// Computes the difference between the object states before and after the
+// change. The nil before state means that the object has been created and
+// the nil after state means that the object has been deleted. The objects
+// are compared by their JSON representations, so the REST API models are
+// the natural input. The returned map contains only the modified fields.
+// The values of the sensitive fields, e.g. passwords, are redacted.
+func ComputeDiff(before, after interface{}) (map[string]dbmodel.AuditChange, error) {
+	beforeFields, err := flatten(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := flatten(after)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := make(map[string]dbmodel.AuditChange)
+	for name, beforeValue := range beforeFields {
+		afterValue, ok := afterFields[name]
+		if ok && reflect.DeepEqual(beforeValue, afterValue) {
+			continue
+		}
+		diff[name] = dbmodel.AuditChange{Before: beforeValue, After: afterValue}
+	}
+	for name, afterValue := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			diff[name] = dbmodel.AuditChange{After: afterValue}
+		}
+	}
+
+	for name, change := range diff {
+		if !isSensitiveField(name) {
+			continue
+		}
+		if change.Before != nil {
+			change.Before = RedactedValue
+		}
+		if change.After != nil {
+			change.After = RedactedValue
+		}
+		diff[name] = change
+	}
+	return diff, nil
+}
@@ -0,0 +1,87 @@
+package auditlog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Test object used in the diff tests.
+type testObject struct {
+	Name     string            `json:"name,omitempty"`
+	Port     int64             `json:"port,omitempty"`
+	Password string            `json:"password,omitempty"`
+	Groups   []int64           `json:"groups,omitempty"`
+	Nested   *testNestedObject `json:"nested,omitempty"`
+}
+
+// Nested test object.
+type testNestedObject struct {
+	Enabled bool   `json:"enabled"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// Test that the diff of the modified object contains only the modified
+// fields including the nested ones.
+func TestComputeDiffUpdate(t *testing.T) {
+	before := &testObject{
+		Name:   "foo",
+		Port:   8080,
+		Groups: []int64{1, 2},
+		Nested: &testNestedObject{Enabled: true, Comment: "old"},
+	}
+	after := &testObject{
+		Name:   "foo",
+		Port:   8081,
+		Groups: []int64{1},
+		Nested: &testNestedObject{Enabled: true},
+	}
+	diff, err := ComputeDiff(before, after)
+	require.NoError(t, err)
+	require.Len(t, diff, 3)
+	require.Equal(t, dbmodel.AuditChange{Before: float64(8080), After: float64(8081)}, diff["port"])
+	require.Equal(t, dbmodel.AuditChange{Before: []interface{}{float64(1), float64(2)}, After: []interface{}{float64(1)}}, diff["groups"])
+	require.Equal(t, dbmodel.AuditChange{Before: "old"}, diff["nested.comment"])
+}
+
+// Test that all fields are included in the diff of the created and the
+// deleted object.
+func TestComputeDiffCreateDelete(t *testing.T) {
+	object := &testObject{
+		Name:   "foo",
+		Nested: &testNestedObject{},
+	}
+	diff, err := ComputeDiff(nil, object)
+	require.NoError(t, err)
+	require.Len(t, diff, 2)
+	require.Equal(t, dbmodel.AuditChange{After: "foo"}, diff["name"])
+	require.Equal(t, dbmodel.AuditChange{After: false}, diff["nested.enabled"])
+
+	diff, err = ComputeDiff(object, (*testObject)(nil))
+	require.NoError(t, err)
+	require.Len(t, diff, 2)
+	require.Equal(t, dbmodel.AuditChange{Before: "foo"}, diff["name"])
+
+	diff, err = ComputeDiff(object, object)
+	require.NoError(t, err)
+	require.Empty(t, diff)
+}
+
+// Test that the sensitive values are not included in the diff.
+func TestComputeDiffRedacted(t *testing.T) {
+	diff, err := ComputeDiff(&testObject{Password: "old"}, &testObject{Password: "new"})
+	require.NoError(t, err)
+	require.Equal(t, dbmodel.AuditChange{Before: RedactedValue, After: RedactedValue}, diff["password"])
+
+	diff, err = ComputeDiff(nil, map[string]interface{}{"clientSecret": "s3cr3t", "apiToken": "x"})
+	require.NoError(t, err)
+	require.Equal(t, dbmodel.AuditChange{After: RedactedValue}, diff["clientSecret"])
+	require.Equal(t, dbmodel.AuditChange{After: RedactedValue}, diff["apiToken"])
+}
+
+// Test that the objects that cannot be serialized are rejected.
+func TestComputeDiffError(t *testing.T) {
+	_, err := ComputeDiff(nil, map[string]interface{}{"foo": make(chan int)})
+	require.Error(t, err)
+}
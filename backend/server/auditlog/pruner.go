@@ -0,0 +1,71 @@
+package auditlog
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	dbmodel "isc.org/stork/server/database/model"
+	storkutil "isc.org/stork/util"
+)
+
+// Name of the setting holding the number of days the audit log entries
+// are kept. The zero value means that the entries are never deleted.
+const RetentionSettingName = "audit_log_retention_days"
+
+// Interval of the audit log pruning in seconds.
+const pruneInterval int64 = 3600
+
+// Background worker periodically deleting the audit log entries older
+// than the retention period.
+type Pruner struct {
+	db       *pg.DB
+	executor *storkutil.PeriodicExecutor
+}
+
+// Creates the pruner and starts deleting the expired audit log entries
+// periodically.
+func NewPruner(db *pg.DB) (*Pruner, error) {
+	pruner := &Pruner{db: db}
+	executor, err := storkutil.NewPeriodicExecutor("audit log pruner", pruner.prune,
+		func() (int64, error) {
+			return pruneInterval, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	pruner.executor = executor
+	return pruner, nil
+}
+
+// Deletes the audit log entries older than the retention period.
+func (pruner *Pruner) prune() error {
+	return Prune(pruner.db, time.Now().UTC())
+}
+
+// Deletes the audit log entries older than the retention period specified
+// in the settings. The now value is the current time.
+func Prune(db *pg.DB, now time.Time) error {
+	days, err := dbmodel.GetSettingInt(db, RetentionSettingName)
+	if err != nil {
+		return errors.WithMessagef(err, "problem getting setting %s from db", RetentionSettingName)
+	}
+	if days <= 0 {
+		return nil
+	}
+	count, err := dbmodel.DeleteAuditEntriesBefore(db, now.AddDate(0, 0, -int(days)))
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		log.WithField("count", count).Info("Deleted expired audit log entries")
+	}
+	return nil
+}
+
+// Stops pruning the audit log.
+func (pruner *Pruner) Shutdown() {
+	pruner.executor.Shutdown()
+}
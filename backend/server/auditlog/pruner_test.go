@@ -0,0 +1,54 @@
+package auditlog
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the audit entries older than the retention period are deleted.
+func TestPrune(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	require.NoError(t, dbmodel.SetSettingInt(db, RetentionSettingName, 7))
+
+	now := time.Now().UTC()
+	for _, days := range []int{30, 8, 6} {
+		entry := &dbmodel.AuditEntry{
+			CreatedAt:    now.AddDate(0, 0, -days),
+			UserIdentity: "admin",
+			Action:       dbmodel.AuditActionUpdate,
+			ObjectType:   dbmodel.AuditObjectSettings,
+		}
+		require.NoError(t, dbmodel.AddAuditEntry(db, entry))
+	}
+
+	require.NoError(t, Prune(db, now))
+	entries, total, err := dbmodel.GetAuditEntriesByPage(db, 0, 10, nil, "", dbmodel.SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.WithinDuration(t, now.AddDate(0, 0, -6), entries[0].CreatedAt, time.Second)
+
+	// The zero retention disables pruning.
+	require.NoError(t, dbmodel.SetSettingInt(db, RetentionSettingName, 0))
+	require.NoError(t, Prune(db, now.AddDate(1, 0, 0)))
+	_, total, err = dbmodel.GetAuditEntriesByPage(db, 0, 10, nil, "", dbmodel.SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+}
+
+// Test that the pruner can be started and stopped.
+func TestNewPruner(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	pruner, err := NewPruner(db)
+	require.NoError(t, err)
+	require.NotNil(t, pruner)
+	pruner.Shutdown()
+}
@@ -4,23 +4,202 @@ import (
 	"fmt"
 	"net/http"
 	"path"
+	"strconv"
 	"strings"
 
 	dbmodel "isc.org/stork/server/database/model"
 )
 
-// Checks if the given user is permitted to access a resource. Currently the
-// access pattern is very simple, the super-admin user can access all
-// resources. The admin-user can access all resources except those related
-// to users management.
-func Authorize(user *dbmodel.SystemUser, req *http.Request) (ok bool, err error) {
+// Interface used to find out which app and machine the resources
+// specified in the request belong to. It is used to check if the scoped
+// permissions grant access to the resources.
+type ScopeResolver interface {
+	// Returns the ID of the machine the app is running on.
+	GetAppMachineID(appID int64) (int64, error)
+	// Returns the ID of the app the daemon belongs to.
+	GetDaemonAppID(daemonID int64) (int64, error)
+	// Returns the IDs of the machine groups the machine belongs to.
+	GetMachineGroupIDs(machineID int64) ([]int64, error)
+}
+
+// Describes the resource type specified by a URL path prefix.
+type resourcePrefix struct {
+	prefix   string
+	resource string
+	// Scope type of the ID following the prefix in the path.
+	idScope dbmodel.PermissionScopeType
+	// Indicates if the ID following the prefix belongs to a daemon.
+	daemon bool
+}
+
+// Maps the URL paths to the resource types. The longer prefixes precede
+// the shorter ones starting with the same text.
+var resourcePrefixes = []resourcePrefix{
+	{prefix: "/api/machines-server-token/", resource: dbmodel.PermissionResourceMachines},
+	{prefix: "/api/machines/", resource: dbmodel.PermissionResourceMachines, idScope: dbmodel.PermissionScopeMachine},
+	{prefix: "/api/machine-groups/", resource: dbmodel.PermissionResourceMachines},
+	{prefix: "/api/apps-stats/", resource: dbmodel.PermissionResourceApps},
+	{prefix: "/api/apps/", resource: dbmodel.PermissionResourceApps, idScope: dbmodel.PermissionScopeApp},
+	{prefix: "/api/app/", resource: dbmodel.PermissionResourceApps, idScope: dbmodel.PermissionScopeApp},
+	{prefix: "/api/daemons/", resource: dbmodel.PermissionResourceApps, idScope: dbmodel.PermissionScopeApp, daemon: true},
+	{prefix: "/api/logs/", resource: dbmodel.PermissionResourceApps},
+	{prefix: "/api/services/", resource: dbmodel.PermissionResourceServices},
+	{prefix: "/api/hosts/", resource: dbmodel.PermissionResourceHosts},
+	{prefix: "/api/leases/", resource: dbmodel.PermissionResourceHosts},
+	{prefix: "/api/subnets/", resource: dbmodel.PermissionResourceSubnets},
+	{prefix: "/api/shared-networks/", resource: dbmodel.PermissionResourceSubnets},
+	{prefix: "/api/zones/", resource: dbmodel.PermissionResourceZones},
+	{prefix: "/api/events/", resource: dbmodel.PermissionResourceEvents},
+	{prefix: "/api/alert-rules/", resource: dbmodel.PermissionResourceEvents},
+	{prefix: "/api/alerts/", resource: dbmodel.PermissionResourceEvents},
+	{prefix: "/api/maintenance-windows/", resource: dbmodel.PermissionResourceEvents},
+	{prefix: "/api/settings/", resource: dbmodel.PermissionResourceSettings},
+	{prefix: "/api/pullers/", resource: dbmodel.PermissionResourceSettings},
+	{prefix: "/api/overview/", resource: dbmodel.PermissionResourceDashboard},
+	{prefix: "/api/groups/", resource: dbmodel.PermissionResourceGroups},
+	{prefix: "/api/users/", resource: dbmodel.PermissionResourceUsers},
+	{prefix: "/api/audit-log/", resource: dbmodel.PermissionResourceAudit},
+}
+
+// Identifies the app, machine and machine groups the requested resource
+// belongs to. The zero values mean that the request doesn't target any
+// particular app or machine.
+type requestScope struct {
+	appID           int64
+	machineID       int64
+	machineGroupIDs []int64
+}
+
+// Returns the resource type of the request path and the prefix
+// description. It returns false if the path doesn't match any resource.
+func getResource(urlPath string) (*resourcePrefix, bool) {
+	for i := range resourcePrefixes {
+		if strings.HasPrefix(urlPath, resourcePrefixes[i].prefix) {
+			return &resourcePrefixes[i], true
+		}
+	}
+	return nil, false
+}
+
+// Returns the action performed by the request. The GET and HEAD requests
+// read the resources and all other methods modify them.
+func getAction(req *http.Request) dbmodel.PermissionAction {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return dbmodel.PermissionActionRead
+	}
+	return dbmodel.PermissionActionWrite
+}
+
+// Parses the positive integer. It returns zero if the value is not a
+// positive integer.
+func parseID(value string) int64 {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id <= 0 {
+		return 0
+	}
+	return id
+}
+
+// Determines the app and machine targeted by the request. The IDs are
+// taken from the path, e.g. /api/apps/{id}, or from the appId and machine
+// query parameters. The resolver is used to find the machine owning the
+// app and the app owning the daemon. The machine groups of the machine
+// are resolved only if the machineGroups flag is set.
+func getRequestScope(urlPath string, req *http.Request, prefix *resourcePrefix, resolver ScopeResolver, machineGroups bool) (scope requestScope, err error) {
+	if prefix.idScope != dbmodel.PermissionScopeNone {
+		id := parseID(strings.SplitN(strings.TrimPrefix(urlPath, prefix.prefix), "/", 2)[0])
+		if id > 0 {
+			switch {
+			case prefix.daemon:
+				if resolver != nil {
+					scope.appID, err = resolver.GetDaemonAppID(id)
+				}
+			case prefix.idScope == dbmodel.PermissionScopeApp:
+				scope.appID = id
+			default:
+				scope.machineID = id
+			}
+		}
+	}
+	if scope.appID == 0 && scope.machineID == 0 && req.URL != nil {
+		query := req.URL.Query()
+		scope.appID = parseID(query.Get("appId"))
+		if scope.appID == 0 {
+			scope.machineID = parseID(query.Get("machine"))
+		}
+	}
+	if err == nil && scope.appID > 0 && resolver != nil {
+		scope.machineID, err = resolver.GetAppMachineID(scope.appID)
+	}
+	if err == nil && machineGroups && scope.machineID > 0 && resolver != nil {
+		scope.machineGroupIDs, err = resolver.GetMachineGroupIDs(scope.machineID)
+	}
+	return scope, err
+}
+
+// Checks if the permission scope covers the resource targeted by the
+// request. The requests not targeting any particular app or machine, e.g.
+// listing all hosts, have the zero scope. The scoped permissions never
+// match them because the list endpoints don't filter their results by
+// the scope, so they would reveal the resources outside of the scope.
+func scopeMatches(permission *dbmodel.SystemGroupPermission, scope requestScope) bool {
+	switch permission.ScopeType {
+	case dbmodel.PermissionScopeNone:
+		return true
+	case dbmodel.PermissionScopeApp:
+		return scope.appID != 0 && scope.appID == permission.ScopeID
+	case dbmodel.PermissionScopeMachine:
+		return scope.machineID != 0 && scope.machineID == permission.ScopeID
+	case dbmodel.PermissionScopeMachineGroup:
+		for _, id := range scope.machineGroupIDs {
+			if id == permission.ScopeID {
+				return true
+			}
+		}
+		return false
+	default:
+		return false
+	}
+}
+
+// Checks if the given user may perform the action on all resources of the
+// given type. The permissions limited to the selected apps or machines
+// are not taken into account. It is used by the handlers returning the
+// resources of different types, e.g. the global search, to skip the
+// resources the user is not permitted to access.
+func IsPermitted(user *dbmodel.SystemUser, permissions []dbmodel.SystemGroupPermission, resource string, action dbmodel.PermissionAction) bool {
+	if user == nil {
+		return false
+	}
+	if user.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
+		return true
+	}
+	for i := range permissions {
+		if permissions[i].ScopeType == dbmodel.PermissionScopeNone && permissions[i].Grants(resource, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if the given user is permitted to access a resource. The
+// super-admin user can access all resources. Other users can access their
+// own profiles and log out. The access to other resources depends on the
+// permissions granted to the groups the user belongs to. The request path
+// determines the resource type and the request method determines the
+// action, i.e. GET and HEAD require the read permission and other methods
+// require the write permission. The permissions limited to the selected
+// apps, machines or machine groups grant access only to the requests
+// targeting these apps or machines. The resolver is used to find the apps,
+// machines and machine groups the requested resources belong to.
+func Authorize(user *dbmodel.SystemUser, permissions []dbmodel.SystemGroupPermission, req *http.Request, resolver ScopeResolver) (ok bool, err error) {
 	// If there is no user (possibly the user has not signed in) or the
 	// request is nil, reject access to the resource.
 	if user == nil || req == nil {
 		return false, nil
 	}
 
-	// If the user is super-admin he can access all resources.
+	// If the user is super-admin they can access all resources.
 	if user.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
 		return true, nil
 	}
@@ -30,22 +209,64 @@ func Authorize(user *dbmodel.SystemUser, req *http.Request) (ok bool, err error)
 		urlPath += "/"
 	}
 
-	if strings.HasPrefix(urlPath, "/api/users/") {
-		// If the user does not belong to the super-admin group and trying to
-		// access the user specific information, check if the data the user
-		// is trying to access belong to this user. If not, reject access.
-		return strings.HasPrefix(urlPath, fmt.Sprintf("/api/users/%d/", user.ID)), nil
+	if strings.HasPrefix(urlPath, fmt.Sprintf("/api/users/%d/", user.ID)) {
+		// The users can access their own profiles.
+		return true, nil
 	} else if strings.HasPrefix(urlPath, "/api/sessions/") && req.Method == "DELETE" {
 		// Log out is available for all users.
 		return true, nil
+	} else if urlPath == "/api/sessions/" && req.Method == "GET" {
+		// The users can fetch their own session information.
+		return true, nil
+	} else if urlPath == "/api/records/" && getAction(req) == dbmodel.PermissionActionRead {
+		// The global search is available for all users. It returns only
+		// the resources the user is permitted to view.
+		return true, nil
+	}
+
+	// The resources not recognized are available only to the super-admin.
+	prefix, ok := getResource(urlPath)
+	if !ok {
+		return false, nil
+	}
+	action := getAction(req)
+
+	// The machine groups are resolved only if they can grant access.
+	machineGroups := false
+	for i := range permissions {
+		if permissions[i].ScopeType == dbmodel.PermissionScopeMachineGroup && permissions[i].Grants(prefix.resource, action) {
+			machineGroups = true
+			break
+		}
 	}
 
-	// All other resources can be accessed by the admin user.
-	if user.InGroup(&dbmodel.SystemGroup{ID: dbmodel.AdminGroupID}) {
-		return true, err
+	var (
+		scope         requestScope
+		scopeResolved bool
+	)
+	for i := range permissions {
+		permission := &permissions[i]
+		if !permission.Grants(prefix.resource, action) {
+			continue
+		}
+		if permission.ScopeType == dbmodel.PermissionScopeNone {
+			return true, nil
+		}
+		// Resolve the scope of the request lazily because it may require
+		// the database queries.
+		if !scopeResolved {
+			scope, err = getRequestScope(urlPath, req, prefix, resolver, machineGroups)
+			if err != nil {
+				return false, err
+			}
+			scopeResolved = true
+		}
+		if scopeMatches(permission, scope) {
+			return true, nil
+		}
 	}
 
-	// User who doesn't belong to any group is not allowed to access
-	// system resources.
+	// User who doesn't have appropriate permissions is not allowed to
+	// access the resource.
 	return false, nil
 }
@@ -5,13 +5,84 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	dbmodel "isc.org/stork/server/database/model"
 )
 
+// Resolver returning the apps, machines and machine groups from the maps.
+type testScopeResolver struct {
+	appMachines   map[int64]int64
+	daemonApps    map[int64]int64
+	machineGroups map[int64][]int64
+}
+
+// Returns the machine ID of the app from the map.
+func (r *testScopeResolver) GetAppMachineID(appID int64) (int64, error) {
+	if appID == 42 {
+		return 0, errors.New("database error")
+	}
+	return r.appMachines[appID], nil
+}
+
+// Returns the app ID of the daemon from the map.
+func (r *testScopeResolver) GetDaemonAppID(daemonID int64) (int64, error) {
+	return r.daemonApps[daemonID], nil
+}
+
+// Returns the machine group IDs of the machine from the map.
+func (r *testScopeResolver) GetMachineGroupIDs(machineID int64) ([]int64, error) {
+	return r.machineGroups[machineID], nil
+}
+
+// Returns the resolver where the app 1 runs on the machine 1, the app 2
+// runs on the machine 2 and the daemons 11 and 21 belong to the apps 1
+// and 2 respectively. The machine 1 belongs to the machine groups 7 and
+// 8, and the machine 2 belongs to the machine group 8.
+func newTestScopeResolver() *testScopeResolver {
+	return &testScopeResolver{
+		appMachines:   map[int64]int64{1: 1, 2: 2},
+		daemonApps:    map[int64]int64{11: 1, 21: 2},
+		machineGroups: map[int64][]int64{1: {7, 8}, 2: {8}},
+	}
+}
+
+// Returns the permissions of the admin group as created by the database
+// migration.
+func getAdminPermissions() []dbmodel.SystemGroupPermission {
+	permissions := []dbmodel.SystemGroupPermission{}
+	for _, resource := range []string{
+		dbmodel.PermissionResourceMachines,
+		dbmodel.PermissionResourceApps,
+		dbmodel.PermissionResourceServices,
+		dbmodel.PermissionResourceHosts,
+		dbmodel.PermissionResourceSubnets,
+		dbmodel.PermissionResourceZones,
+		dbmodel.PermissionResourceEvents,
+		dbmodel.PermissionResourceSettings,
+		dbmodel.PermissionResourceDashboard,
+	} {
+		permissions = append(permissions, dbmodel.SystemGroupPermission{
+			GroupID:  dbmodel.AdminGroupID,
+			Resource: resource,
+			Action:   dbmodel.PermissionActionWrite,
+		})
+	}
+	permissions = append(permissions, dbmodel.SystemGroupPermission{
+		GroupID:  dbmodel.AdminGroupID,
+		Resource: dbmodel.PermissionResourceGroups,
+		Action:   dbmodel.PermissionActionRead,
+	}, dbmodel.SystemGroupPermission{
+		GroupID:  dbmodel.AdminGroupID,
+		Resource: dbmodel.PermissionResourceAudit,
+		Action:   dbmodel.PermissionActionRead,
+	})
+	return permissions
+}
+
 // Helper function checking if the user belonging to the specified group
-// has access to the resource.
-func authorizeAccept(t *testing.T, groupID int, path, method string) bool {
+// and having the specified permissions has access to the resource.
+func authorizeAcceptWithPermissions(t *testing.T, groupID int, permissions []dbmodel.SystemGroupPermission, path, method string) bool {
 	// Create user with ID 5 and specified group id if the group id is
 	// positive.
 	user := &dbmodel.SystemUser{
@@ -27,12 +98,23 @@ func authorizeAccept(t *testing.T, groupID int, path, method string) bool {
 
 	// Create request with the specified path and authorize.
 	req, _ := http.NewRequestWithContext(context.Background(), method, "http://example.org/api"+path, nil)
-	ok, err := Authorize(user, req)
+	ok, err := Authorize(user, permissions, req, newTestScopeResolver())
 	require.NoError(t, err)
 
 	return ok
 }
 
+// Helper function checking if the user belonging to the specified group
+// has access to the resource. The admin group has the permissions created
+// by the database migration.
+func authorizeAccept(t *testing.T, groupID int, path, method string) bool {
+	var permissions []dbmodel.SystemGroupPermission
+	if groupID == dbmodel.AdminGroupID {
+		permissions = getAdminPermissions()
+	}
+	return authorizeAcceptWithPermissions(t, groupID, permissions, path, method)
+}
+
 // Verify that users belonging to the super-admin and admin group
 // has appropriate access privileges.
 func TestAuthorize(t *testing.T) {
@@ -56,20 +138,246 @@ func TestAuthorize(t *testing.T) {
 
 	// Admin group have no restriction on machines.
 	require.True(t, authorizeAccept(t, 2, "/machines/1/", "GET"))
+	require.True(t, authorizeAccept(t, 2, "/machines/1/", "PUT"))
+
+	// The machine groups are managed with the machines permission.
+	require.True(t, authorizeAccept(t, 2, "/machine-groups", "POST"))
+	require.False(t, authorizeAccept(t, 3, "/machine-groups", "GET"))
+
+	// Admin can list the groups but not modify them.
+	require.True(t, authorizeAccept(t, 2, "/groups", "GET"))
+	require.False(t, authorizeAccept(t, 2, "/groups", "POST"))
+	require.False(t, authorizeAccept(t, 2, "/groups/5", "DELETE"))
+	require.True(t, authorizeAccept(t, 1, "/groups/5", "DELETE"))
+
+	// Admin can view the audit log.
+	require.True(t, authorizeAccept(t, 2, "/audit-log?start=0&limit=10", "GET"))
+	require.False(t, authorizeAccept(t, 3, "/audit-log", "GET"))
+
+	// Admin can manage the alert rules and view the alerts.
+	require.True(t, authorizeAccept(t, 2, "/alert-rules/1", "PUT"))
+	require.True(t, authorizeAccept(t, 2, "/alerts", "GET"))
+	require.False(t, authorizeAccept(t, 3, "/alerts", "GET"))
+
+	// Admin can manage the maintenance windows.
+	require.True(t, authorizeAccept(t, 2, "/maintenance-windows/1", "DELETE"))
+	require.False(t, authorizeAccept(t, 3, "/maintenance-windows", "GET"))
+
+	// Unknown resources are available only to the super-admin.
+	require.False(t, authorizeAccept(t, 2, "/foo", "GET"))
+	require.True(t, authorizeAccept(t, 1, "/foo", "GET"))
 
 	// But someone who belongs to no groups would not be able
 	// to access machines.
 	require.False(t, authorizeAccept(t, 0, "/machines/1/", "GET"))
 
-	// The same in case of someone belonging to non existing group.
+	// The same in case of someone belonging to a group without permissions.
 	require.False(t, authorizeAccept(t, 3, "/machines/1/", "GET"))
 
 	// Someone who belongs to no groups would be able to log out.
 	require.True(t, authorizeAccept(t, 0, "/sessions", "DELETE"))
 
+	// Someone who belongs to no groups would be able to fetch their session.
+	require.True(t, authorizeAccept(t, 0, "/sessions", "GET"))
+	require.False(t, authorizeAccept(t, 0, "/sessions/foo", "GET"))
+
 	// Someone who belongs to no groups would be able to their and only their profile.
 	require.True(t, authorizeAccept(t, 0, "/users/5", "GET"))
 	require.True(t, authorizeAccept(t, 0, "/users/5/password", "GET"))
 	require.False(t, authorizeAccept(t, 0, "/users/4", "GET"))
 	require.False(t, authorizeAccept(t, 0, "/users/4/password", "GET"))
 }
+
+// Test that the read permission allows only fetching the resources and
+// the write permission allows modifying them.
+func TestAuthorizeActions(t *testing.T) {
+	permissions := []dbmodel.SystemGroupPermission{
+		{Resource: dbmodel.PermissionResourceHosts, Action: dbmodel.PermissionActionWrite},
+		{Resource: dbmodel.PermissionResourceSubnets, Action: dbmodel.PermissionActionRead},
+	}
+	check := func(path, method string) bool {
+		return authorizeAcceptWithPermissions(t, 3, permissions, path, method)
+	}
+
+	require.True(t, check("/hosts", "GET"))
+	require.True(t, check("/hosts/1", "HEAD"))
+	require.True(t, check("/hosts/new/transaction", "POST"))
+	require.True(t, check("/hosts/1", "DELETE"))
+	require.True(t, check("/leases?text=foo", "GET"))
+
+	require.True(t, check("/subnets", "GET"))
+	require.True(t, check("/shared-networks", "GET"))
+	require.False(t, check("/subnets/1", "PUT"))
+
+	require.False(t, check("/machines", "GET"))
+	require.False(t, check("/overview", "GET"))
+
+	// The wildcard permission covers all resources.
+	permissions = []dbmodel.SystemGroupPermission{
+		{Resource: dbmodel.PermissionResourceAll, Action: dbmodel.PermissionActionRead},
+	}
+	require.True(t, check("/machines", "GET"))
+	require.True(t, check("/users", "GET"))
+	require.False(t, check("/users/4", "PUT"))
+	require.False(t, check("/settings", "PUT"))
+}
+
+// Test that the scoped permissions grant access only to the resources
+// belonging to the selected apps and machines.
+func TestAuthorizeScopes(t *testing.T) {
+	permissions := []dbmodel.SystemGroupPermission{
+		{
+			Resource:  dbmodel.PermissionResourceApps,
+			Action:    dbmodel.PermissionActionWrite,
+			ScopeType: dbmodel.PermissionScopeApp,
+			ScopeID:   1,
+		},
+		{
+			Resource:  dbmodel.PermissionResourceMachines,
+			Action:    dbmodel.PermissionActionRead,
+			ScopeType: dbmodel.PermissionScopeMachine,
+			ScopeID:   2,
+		},
+		{
+			Resource:  dbmodel.PermissionResourceHosts,
+			Action:    dbmodel.PermissionActionRead,
+			ScopeType: dbmodel.PermissionScopeMachine,
+			ScopeID:   2,
+		},
+		{
+			Resource:  dbmodel.PermissionResourceEvents,
+			Action:    dbmodel.PermissionActionRead,
+			ScopeType: dbmodel.PermissionScopeMachine,
+			ScopeID:   1,
+		},
+	}
+	check := func(path, method string) bool {
+		return authorizeAcceptWithPermissions(t, 3, permissions, path, method)
+	}
+
+	// App scope.
+	require.True(t, check("/apps/1", "GET"))
+	require.True(t, check("/apps/1/name", "PUT"))
+	require.True(t, check("/app/1/access-points/control/key", "GET"))
+	require.False(t, check("/apps/2", "GET"))
+	require.False(t, check("/apps", "GET"))
+	require.False(t, check("/apps/foo", "GET"))
+
+	// The daemons belong to the apps.
+	require.True(t, check("/daemons/11/config", "GET"))
+	require.False(t, check("/daemons/21/config", "GET"))
+	require.False(t, check("/daemons/31/config", "GET"))
+
+	// Machine scope.
+	require.True(t, check("/machines/2", "GET"))
+	require.False(t, check("/machines/2", "PUT"))
+	require.False(t, check("/machines/1", "GET"))
+
+	// The app 2 runs on the machine 2.
+	require.True(t, check("/hosts?appId=2", "GET"))
+	require.False(t, check("/hosts?appId=1", "GET"))
+	require.False(t, check("/hosts", "GET"))
+
+	// The machine can be specified in the query.
+	require.True(t, check("/events?machine=1", "GET"))
+	require.False(t, check("/events?machine=2", "GET"))
+	require.False(t, check("/events", "GET"))
+}
+
+// Test that the global search is available for all users and the
+// permissions to view the particular resource types are checked.
+func TestAuthorizeSearch(t *testing.T) {
+	require.True(t, authorizeAccept(t, 0, "/records?text=foo", "GET"))
+	require.False(t, authorizeAccept(t, 0, "/records", "POST"))
+
+	user := &dbmodel.SystemUser{ID: 5}
+	permissions := []dbmodel.SystemGroupPermission{
+		{Resource: dbmodel.PermissionResourceHosts, Action: dbmodel.PermissionActionWrite},
+		{Resource: dbmodel.PermissionResourceSubnets, Action: dbmodel.PermissionActionRead},
+		{
+			Resource:  dbmodel.PermissionResourceMachines,
+			Action:    dbmodel.PermissionActionRead,
+			ScopeType: dbmodel.PermissionScopeMachine,
+			ScopeID:   1,
+		},
+	}
+	require.True(t, IsPermitted(user, permissions, dbmodel.PermissionResourceHosts, dbmodel.PermissionActionRead))
+	require.True(t, IsPermitted(user, permissions, dbmodel.PermissionResourceSubnets, dbmodel.PermissionActionRead))
+	require.False(t, IsPermitted(user, permissions, dbmodel.PermissionResourceSubnets, dbmodel.PermissionActionWrite))
+	// The scoped permissions don't grant access to all resources.
+	require.False(t, IsPermitted(user, permissions, dbmodel.PermissionResourceMachines, dbmodel.PermissionActionRead))
+	require.False(t, IsPermitted(user, permissions, dbmodel.PermissionResourceUsers, dbmodel.PermissionActionRead))
+	require.False(t, IsPermitted(nil, permissions, dbmodel.PermissionResourceHosts, dbmodel.PermissionActionRead))
+
+	// The super-admin can view everything.
+	user.Groups = []*dbmodel.SystemGroup{{ID: dbmodel.SuperAdminGroupID}}
+	require.True(t, IsPermitted(user, nil, dbmodel.PermissionResourceUsers, dbmodel.PermissionActionWrite))
+}
+
+// Test that the machine-group scoped permissions grant access only to the
+// resources belonging to the machines in the group.
+func TestAuthorizeMachineGroupScope(t *testing.T) {
+	permissions := []dbmodel.SystemGroupPermission{
+		{
+			Resource:  dbmodel.PermissionResourceHosts,
+			Action:    dbmodel.PermissionActionWrite,
+			ScopeType: dbmodel.PermissionScopeMachineGroup,
+			ScopeID:   7,
+		},
+		{
+			Resource:  dbmodel.PermissionResourceMachines,
+			Action:    dbmodel.PermissionActionRead,
+			ScopeType: dbmodel.PermissionScopeMachineGroup,
+			ScopeID:   8,
+		},
+	}
+	check := func(path, method string) bool {
+		return authorizeAcceptWithPermissions(t, 3, permissions, path, method)
+	}
+
+	// The app 1 runs on the machine 1 belonging to the group 7.
+	require.True(t, check("/hosts?appId=1", "GET"))
+	require.True(t, check("/hosts?machine=1", "GET"))
+	require.False(t, check("/hosts?appId=2", "GET"))
+	require.False(t, check("/hosts?machine=2", "GET"))
+
+	// Both machines belong to the group 8.
+	require.True(t, check("/machines/1", "GET"))
+	require.True(t, check("/machines/2", "GET"))
+	require.False(t, check("/machines/3", "GET"))
+	require.False(t, check("/machines/2", "PUT"))
+}
+
+// Test that the scoped permissions don't grant access to the list
+// endpoints because they don't filter the results by the scope.
+func TestAuthorizeScopedList(t *testing.T) {
+	for _, scopeType := range []dbmodel.PermissionScopeType{
+		dbmodel.PermissionScopeApp,
+		dbmodel.PermissionScopeMachine,
+		dbmodel.PermissionScopeMachineGroup,
+	} {
+		permissions := []dbmodel.SystemGroupPermission{
+			{Resource: dbmodel.PermissionResourceAll, Action: dbmodel.PermissionActionRead, ScopeType: scopeType, ScopeID: 1},
+		}
+		for _, path := range []string{"/apps", "/machines", "/hosts", "/subnets", "/events", "/zones"} {
+			require.False(t, authorizeAcceptWithPermissions(t, 3, permissions, path, "GET"), "%s %s", scopeType, path)
+		}
+	}
+}
+
+// Test that the error resolving the scope is returned.
+func TestAuthorizeScopeError(t *testing.T) {
+	user := &dbmodel.SystemUser{ID: 5}
+	permissions := []dbmodel.SystemGroupPermission{
+		{
+			Resource:  dbmodel.PermissionResourceApps,
+			Action:    dbmodel.PermissionActionRead,
+			ScopeType: dbmodel.PermissionScopeMachine,
+			ScopeID:   1,
+		},
+	}
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "http://example.org/api/apps/42", nil)
+	ok, err := Authorize(user, permissions, req, newTestScopeResolver())
+	require.Error(t, err)
+	require.False(t, ok)
+}
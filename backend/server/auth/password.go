@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/pkg/errors"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Password policy and account lockout configuration of the internal users.
+// It is read from the global settings. The zero values disable the
+// respective rules.
+type PasswordPolicy struct {
+	// Minimal number of characters in the password.
+	MinLength int
+	// Password must contain both lower and upper case letters.
+	RequireMixedCase bool
+	// Password must contain at least one digit.
+	RequireDigit bool
+	// Password must contain at least one character other than a letter
+	// or a digit.
+	RequireSpecialCharacter bool
+	// Number of the recent passwords, including the current one, that
+	// cannot be reused.
+	HistoryCount int
+	// Number of days after which the password expires and has to be
+	// changed on the next login.
+	ExpirationDays int
+	// Password set by the administrator for another user has to be
+	// changed on the first login.
+	ChangeOnFirstLogin bool
+	// Number of the consecutive failed login attempts after which the
+	// user is locked out.
+	LockoutThreshold int
+	// Duration of the first lockout. It is doubled for every consecutive
+	// lockout.
+	LockoutDuration time.Duration
+}
+
+// Returns the password policy with default values. They are used when the
+// settings are not initialized.
+func NewDefaultPasswordPolicy() *PasswordPolicy {
+	return &PasswordPolicy{
+		LockoutThreshold: 5,
+		LockoutDuration:  5 * time.Minute,
+	}
+}
+
+// Reads the password policy from the global settings. The missing settings
+// are replaced with the default values.
+func GetPasswordPolicy(db *pg.DB) (*PasswordPolicy, error) {
+	settings, err := dbmodel.GetAllSettings(db)
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot get the password policy")
+	}
+	policy := NewDefaultPasswordPolicy()
+	getInt := func(name string, value *int) {
+		if v, ok := settings[name].(int64); ok {
+			*value = int(v)
+		}
+	}
+	getBool := func(name string, value *bool) {
+		if v, ok := settings[name].(bool); ok {
+			*value = v
+		}
+	}
+	getInt("password_min_length", &policy.MinLength)
+	getBool("password_require_mixed_case", &policy.RequireMixedCase)
+	getBool("password_require_digit", &policy.RequireDigit)
+	getBool("password_require_special_character", &policy.RequireSpecialCharacter)
+	getInt("password_history_count", &policy.HistoryCount)
+	getInt("password_expiration_days", &policy.ExpirationDays)
+	getBool("password_change_on_first_login", &policy.ChangeOnFirstLogin)
+	getInt("account_lockout_threshold", &policy.LockoutThreshold)
+	if v, ok := settings["account_lockout_duration"].(int64); ok {
+		policy.LockoutDuration = time.Duration(v) * time.Second
+	}
+	return policy, nil
+}
+
+// Checks if the password meets the complexity rules. The returned error
+// lists all violated rules and can be presented to the user.
+func (policy *PasswordPolicy) Validate(password string) error {
+	var hasLower, hasUpper, hasDigit, hasSpecial bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		case !unicode.IsLetter(r):
+			hasSpecial = true
+		}
+	}
+
+	var violations []string
+	if len([]rune(password)) < policy.MinLength {
+		violations = append(violations, fmt.Sprintf("be at least %d characters long", policy.MinLength))
+	}
+	if policy.RequireMixedCase && !(hasLower && hasUpper) {
+		violations = append(violations, "contain both lower and upper case letters")
+	}
+	if policy.RequireDigit && !hasDigit {
+		violations = append(violations, "contain a digit")
+	}
+	if policy.RequireSpecialCharacter && !hasSpecial {
+		violations = append(violations, "contain a special character")
+	}
+	if len(violations) > 0 {
+		return errors.Errorf("password must %s", strings.Join(violations, ", "))
+	}
+	return nil
+}
+
+// Checks if the password changed at the specified time has expired.
+func (policy *PasswordPolicy) IsExpired(changedAt, now time.Time) bool {
+	if policy.ExpirationDays <= 0 {
+		return false
+	}
+	return now.After(changedAt.AddDate(0, 0, policy.ExpirationDays))
+}
@@ -0,0 +1,94 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the default password policy doesn't impose the complexity
+// rules but enables the lockout.
+func TestDefaultPasswordPolicy(t *testing.T) {
+	policy := NewDefaultPasswordPolicy()
+	require.NoError(t, policy.Validate("a"))
+	require.False(t, policy.IsExpired(time.Time{}, time.Now()))
+	require.Equal(t, 5, policy.LockoutThreshold)
+	require.Equal(t, 5*time.Minute, policy.LockoutDuration)
+}
+
+// Test that the password complexity rules are enforced.
+func TestPasswordPolicyValidate(t *testing.T) {
+	policy := &PasswordPolicy{
+		MinLength:               8,
+		RequireMixedCase:        true,
+		RequireDigit:            true,
+		RequireSpecialCharacter: true,
+	}
+	require.NoError(t, policy.Validate("Secret-123"))
+	require.NoError(t, policy.Validate("Zażółć+9"))
+
+	err := policy.Validate("secret")
+	require.EqualError(t, err, "password must be at least 8 characters long, "+
+		"contain both lower and upper case letters, contain a digit, contain a special character")
+
+	require.EqualError(t, policy.Validate("SECRET-123"), "password must contain both lower and upper case letters")
+	require.EqualError(t, policy.Validate("Secret-abc"), "password must contain a digit")
+	require.EqualError(t, policy.Validate("Secret1234"), "password must contain a special character")
+	require.EqualError(t, policy.Validate("Se-1"), "password must be at least 8 characters long")
+}
+
+// Test that the password expires after the specified number of days.
+func TestPasswordPolicyIsExpired(t *testing.T) {
+	policy := &PasswordPolicy{ExpirationDays: 30}
+	changedAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	require.False(t, policy.IsExpired(changedAt, changedAt.AddDate(0, 0, 29)))
+	require.False(t, policy.IsExpired(changedAt, changedAt.AddDate(0, 0, 30)))
+	require.True(t, policy.IsExpired(changedAt, changedAt.AddDate(0, 0, 30).Add(time.Second)))
+
+	policy.ExpirationDays = 0
+	require.False(t, policy.IsExpired(changedAt, changedAt.AddDate(10, 0, 0)))
+}
+
+// Test that the password policy is read from the settings.
+func TestGetPasswordPolicy(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	// The settings are not initialized.
+	policy, err := GetPasswordPolicy(db)
+	require.NoError(t, err)
+	require.Equal(t, NewDefaultPasswordPolicy(), policy)
+
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	policy, err = GetPasswordPolicy(db)
+	require.NoError(t, err)
+	require.Equal(t, NewDefaultPasswordPolicy(), policy)
+
+	require.NoError(t, dbmodel.SetSettingInt(db, "password_min_length", 12))
+	require.NoError(t, dbmodel.SetSettingBool(db, "password_require_mixed_case", true))
+	require.NoError(t, dbmodel.SetSettingBool(db, "password_require_digit", true))
+	require.NoError(t, dbmodel.SetSettingBool(db, "password_require_special_character", true))
+	require.NoError(t, dbmodel.SetSettingInt(db, "password_history_count", 3))
+	require.NoError(t, dbmodel.SetSettingInt(db, "password_expiration_days", 90))
+	require.NoError(t, dbmodel.SetSettingBool(db, "password_change_on_first_login", true))
+	require.NoError(t, dbmodel.SetSettingInt(db, "account_lockout_threshold", 10))
+	require.NoError(t, dbmodel.SetSettingInt(db, "account_lockout_duration", 60))
+
+	policy, err = GetPasswordPolicy(db)
+	require.NoError(t, err)
+	require.Equal(t, &PasswordPolicy{
+		MinLength:               12,
+		RequireMixedCase:        true,
+		RequireDigit:            true,
+		RequireSpecialCharacter: true,
+		HistoryCount:            3,
+		ExpirationDays:          90,
+		ChangeOnFirstLogin:      true,
+		LockoutThreshold:        10,
+		LockoutDuration:         time.Minute,
+	}, policy)
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1" //nolint:gosec
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Parameters of the time-based one-time passwords (TOTP) defined in
+// RFC 6238. These are the defaults supported by all popular authenticator
+// apps.
+const (
+	// Length of the TOTP codes.
+	TOTPDigits = 6
+	// Time step of the TOTP codes.
+	TOTPPeriod = 30 * time.Second
+	// Number of the time steps before and after the current one in which
+	// the codes are accepted. It tolerates the clock skew between the
+	// server and the authenticator.
+	TOTPSkew = 1
+	// Length of the random secret in bytes (160 bits as recommended by
+	// RFC 4226).
+	totpSecretLength = 20
+)
+
+// Encoding of the TOTP secrets used by the authenticator apps.
+var totpSecretEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Generates a new random TOTP secret. It is returned in the base32 encoding
+// without padding, as expected by the authenticator apps.
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, totpSecretLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate random TOTP secret")
+	}
+	return totpSecretEncoding.EncodeToString(b), nil
+}
+
+// Returns the URI used to provision the TOTP secret in the authenticator
+// apps. It is typically presented as a QR code. See
+// https://github.com/google/google-authenticator/wiki/Key-Uri-Format.
+func GetTOTPProvisioningURI(issuer, account, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(TOTPDigits))
+	query.Set("period", fmt.Sprint(int(TOTPPeriod.Seconds())))
+	uri := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+	return uri.String()
+}
+
+// Returns the TOTP time step for the specified time.
+func GetTOTPStep(now time.Time) int64 {
+	return now.Unix() / int64(TOTPPeriod.Seconds())
+}
+
+// Computes the TOTP code for the time step using the base32-encoded secret.
+func ComputeTOTPCode(secret string, step int64) (string, error) {
+	key, err := totpSecretEncoding.DecodeString(strings.ToUpper(strings.TrimRight(secret, "=")))
+	if err != nil {
+		return "", errors.Wrap(err, "invalid TOTP secret")
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	_, _ = mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation described in RFC 4226.
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < TOTPDigits; i++ {
+		modulo *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTPDigits, value%modulo), nil
+}
+
+// Validates the TOTP code at the specified time. The codes from the time
+// steps not later than the last used step are rejected to prevent replaying
+// the codes. It returns the time step matching the code, which should be
+// stored as the last used step, and a boolean flag indicating if the code
+// is valid.
+func ValidateTOTPCode(secret, code string, now time.Time, lastUsedStep int64) (int64, bool, error) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != TOTPDigits {
+		return 0, false, nil
+	}
+	current := GetTOTPStep(now)
+	for step := current - TOTPSkew; step <= current+TOTPSkew; step++ {
+		if step <= lastUsedStep {
+			continue
+		}
+		expected, err := ComputeTOTPCode(secret, step)
+		if err != nil {
+			return 0, false, err
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step, true, nil
+		}
+	}
+	return 0, false, nil
+}
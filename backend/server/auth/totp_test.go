@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Base32 encoding of the secret used in the test vectors of RFC 6238.
+const rfcTestSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// Test that the TOTP codes match the test vectors from RFC 6238. The
+// vectors specify 8 digits, so the last 6 digits are compared.
+func TestComputeTOTPCode(t *testing.T) {
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range vectors {
+		code, err := ComputeTOTPCode(rfcTestSecret, GetTOTPStep(time.Unix(unix, 0)))
+		require.NoError(t, err)
+		require.Equal(t, expected, code, "time %d", unix)
+	}
+}
+
+// Test that invalid secret is rejected.
+func TestComputeTOTPCodeInvalidSecret(t *testing.T) {
+	_, err := ComputeTOTPCode("not base32!", 1)
+	require.Error(t, err)
+}
+
+// Test generating random secrets.
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret1, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	require.Len(t, secret1, 32)
+
+	secret2, err := GenerateTOTPSecret()
+	require.NoError(t, err)
+	require.NotEqual(t, secret1, secret2)
+
+	_, err = ComputeTOTPCode(secret1, 1)
+	require.NoError(t, err)
+}
+
+// Test that the codes from the adjacent time steps are accepted and the
+// used codes are rejected.
+func TestValidateTOTPCode(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := GetTOTPStep(now)
+
+	code, err := ComputeTOTPCode(rfcTestSecret, current)
+	require.NoError(t, err)
+	step, ok, err := ValidateTOTPCode(rfcTestSecret, code, now, 0)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, current, step)
+
+	// The code can't be used again.
+	_, ok, err = ValidateTOTPCode(rfcTestSecret, code, now, step)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// The previous and next codes are accepted because of the skew.
+	for _, s := range []int64{current - 1, current + 1} {
+		code, err = ComputeTOTPCode(rfcTestSecret, s)
+		require.NoError(t, err)
+		step, ok, err = ValidateTOTPCode(rfcTestSecret, code, now, 0)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, s, step)
+	}
+
+	// The older codes are rejected.
+	code, err = ComputeTOTPCode(rfcTestSecret, current-2)
+	require.NoError(t, err)
+	_, ok, err = ValidateTOTPCode(rfcTestSecret, code, now, 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// Invalid length.
+	_, ok, err = ValidateTOTPCode(rfcTestSecret, "123", now, 0)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+// Test generating the provisioning URI.
+func TestGetTOTPProvisioningURI(t *testing.T) {
+	uri := GetTOTPProvisioningURI("Stork", "admin", rfcTestSecret)
+
+	parsed, err := url.Parse(uri)
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", parsed.Scheme)
+	require.Equal(t, "totp", parsed.Host)
+	require.Equal(t, "/Stork:admin", parsed.Path)
+	require.Equal(t, rfcTestSecret, parsed.Query().Get("secret"))
+	require.Equal(t, "Stork", parsed.Query().Get("issuer"))
+	require.Equal(t, "6", parsed.Query().Get("digits"))
+	require.Equal(t, "30", parsed.Query().Get("period"))
+}
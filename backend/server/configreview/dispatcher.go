@@ -297,6 +297,19 @@ type dispatcherImpl struct {
 	enforceSeq int
 	// Checker controller manages the state of configuration checkers.
 	checkerController checkerController
+	// Runtime statistics of the checkers indexed by the checker names.
+	checkerStats map[string]*CheckerStats
+	// Mutex protecting the checker statistics.
+	statsMutex *sync.Mutex
+}
+
+// Runtime statistics of a configuration review checker. The duration is
+// the total duration of the checker runs. The issues are the number of
+// the reports created by the checker.
+type CheckerStats struct {
+	Runs     int64
+	Issues   int64
+	Duration time.Duration
 }
 
 // Dispatcher interface. The interface is used in the unit tests that
@@ -311,6 +324,7 @@ type Dispatcher interface {
 	Shutdown()
 	BeginReview(daemon *dbmodel.Daemon, triggers Triggers, callback CallbackFunc) bool
 	ReviewInProgress(daemonID int64) bool
+	GetCheckerStats() map[string]CheckerStats
 }
 
 // Creates new context instance when a review is scheduled. The daemon
@@ -435,7 +449,9 @@ func (d *dispatcherImpl) runForDaemon(daemon *dbmodel.Daemon, triggers Triggers,
 				}
 
 				// Execute checker.
+				startedAt := time.Now()
 				report, err := checker.checkFn(ctx)
+				d.recordCheckerRun(checker.name, time.Since(startedAt), report != nil)
 				if err != nil {
 					log.Errorf("Malformed report created by the config review checker %s: %+v",
 						checker.name, err)
@@ -692,6 +708,8 @@ func NewDispatcher(db *dbops.PgDB) Dispatcher {
 		state:             make(map[int64]bool),
 		enforceSeq:        enforceDispatchSeq,
 		checkerController: newCheckerController(),
+		checkerStats:      make(map[string]*CheckerStats),
+		statsMutex:        &sync.Mutex{},
 	}
 	return dispatcher
 }
@@ -974,3 +992,31 @@ func LoadAndValidateCheckerPreferences(db dbops.DBI, d Dispatcher) error {
 	}
 	return nil
 }
+
+// Records the duration of the checker run and whether it created a report.
+func (d *dispatcherImpl) recordCheckerRun(checkerName string, duration time.Duration, issue bool) {
+	d.statsMutex.Lock()
+	defer d.statsMutex.Unlock()
+	stats, ok := d.checkerStats[checkerName]
+	if !ok {
+		stats = &CheckerStats{}
+		d.checkerStats[checkerName] = stats
+	}
+	stats.Runs++
+	if issue {
+		stats.Issues++
+	}
+	stats.Duration += duration
+}
+
+// Returns the runtime statistics of the checkers that have been run at
+// least once. The map is indexed by the checker names.
+func (d *dispatcherImpl) GetCheckerStats() map[string]CheckerStats {
+	d.statsMutex.Lock()
+	defer d.statsMutex.Unlock()
+	stats := make(map[string]CheckerStats, len(d.checkerStats))
+	for name, checkerStats := range d.checkerStats {
+		stats[name] = *checkerStats
+	}
+	return stats
+}
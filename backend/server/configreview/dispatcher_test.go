@@ -252,6 +252,33 @@ func TestPopulateKeaReports(t *testing.T) {
 	review, err = dbmodel.GetConfigReviewByDaemonID(db, daemons[1].ID)
 	require.NoError(t, err)
 	require.Nil(t, review)
+
+	// The checker runs should be counted regardless of the review result.
+	stats := dispatcher.GetCheckerStats()
+	require.Len(t, stats, 3)
+	require.EqualValues(t, 2, stats["dhcp_test_checker"].Runs)
+	require.Zero(t, stats["dhcp_test_checker"].Issues)
+	require.EqualValues(t, 1, stats["dhcp4_test_checker"].Runs)
+	require.EqualValues(t, 1, stats["dhcp4_test_checker"].Issues)
+	require.EqualValues(t, 1, stats["dhcp6_test_checker"].Issues)
+}
+
+// Test that the checker runs and issues are counted.
+func TestRecordCheckerRun(t *testing.T) {
+	dispatcher := NewDispatcher(nil).(*dispatcherImpl)
+	require.Empty(t, dispatcher.GetCheckerStats())
+
+	dispatcher.recordCheckerRun("foo", time.Second, true)
+	dispatcher.recordCheckerRun("foo", 2*time.Second, false)
+	dispatcher.recordCheckerRun("bar", time.Second, false)
+
+	stats := dispatcher.GetCheckerStats()
+	require.Len(t, stats, 2)
+	require.EqualValues(t, 2, stats["foo"].Runs)
+	require.EqualValues(t, 1, stats["foo"].Issues)
+	require.Equal(t, 3*time.Second, stats["foo"].Duration)
+	require.EqualValues(t, 1, stats["bar"].Runs)
+	require.Zero(t, stats["bar"].Issues)
 }
 
 // Tests that the configuration reviews for the BIND9 daemon are populated
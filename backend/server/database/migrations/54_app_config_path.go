@@ -0,0 +1,20 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			-- Path to the main configuration file of the app. It
+			-- distinguishes the apps of the same type running on
+			-- the same machine.
+			ALTER TABLE app ADD COLUMN config_path TEXT;
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE app DROP COLUMN config_path;
+		`)
+		return err
+	})
+}
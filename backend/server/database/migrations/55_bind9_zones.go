@@ -0,0 +1,37 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Zones served by the BIND 9 daemons.
+            CREATE TABLE IF NOT EXISTS bind9_zone (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                daemon_id BIGINT NOT NULL,
+                view TEXT NOT NULL,
+                name TEXT NOT NULL,
+                class TEXT NOT NULL,
+                type TEXT NOT NULL,
+                serial BIGINT,
+                loaded BOOLEAN NOT NULL DEFAULT FALSE,
+                loaded_at TIMESTAMP WITHOUT TIME ZONE,
+                refresh_at TIMESTAMP WITHOUT TIME ZONE,
+                expires_at TIMESTAMP WITHOUT TIME ZONE,
+                updated_at TIMESTAMP WITHOUT TIME ZONE DEFAULT timezone('utc'::text, now()) NOT NULL,
+                CONSTRAINT bind9_zone_daemon_id_fkey FOREIGN KEY (daemon_id)
+                    REFERENCES daemon (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT bind9_zone_daemon_view_name_class_unique_idx UNIQUE (daemon_id, view, name, class)
+            );
+            CREATE INDEX bind9_zone_name_idx ON bind9_zone USING btree (name);
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DROP TABLE IF EXISTS bind9_zone;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,36 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- HA state transitions observed by the HA peers.
+            CREATE TABLE IF NOT EXISTS ha_state_transition (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                service_id BIGINT NOT NULL,
+                daemon_id BIGINT NOT NULL,
+                observed_at TIMESTAMP WITHOUT TIME ZONE DEFAULT timezone('utc'::text, now()) NOT NULL,
+                previous_state TEXT,
+                state TEXT NOT NULL,
+                partner_comm_interrupted BOOLEAN,
+                partner_unacked_clients BIGINT NOT NULL DEFAULT 0,
+                CONSTRAINT ha_state_transition_service_id_fkey FOREIGN KEY (service_id)
+                    REFERENCES service (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT ha_state_transition_daemon_id_fkey FOREIGN KEY (daemon_id)
+                    REFERENCES daemon (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE
+            );
+            CREATE INDEX ha_state_transition_service_daemon_idx ON ha_state_transition USING btree (service_id, daemon_id, id);
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DROP TABLE IF EXISTS ha_state_transition;
+        `)
+		return err
+	})
+}
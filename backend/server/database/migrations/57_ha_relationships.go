@@ -0,0 +1,20 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Name identifying the HA relationship among multiple relationships
+            -- configured on the servers in the hub-and-spoke configuration. It
+            -- is empty for the services detected before this column was added.
+            ALTER TABLE ha_service ADD COLUMN IF NOT EXISTS relationship TEXT NOT NULL DEFAULT '';
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            ALTER TABLE ha_service DROP COLUMN IF EXISTS relationship;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,70 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- The group names must be unique because they are used to
+            -- recognize the groups in the UI and by the external
+            -- authentication methods.
+            ALTER TABLE system_group ADD CONSTRAINT system_group_name_unique UNIQUE (name);
+
+            -- Permissions granted to the users belonging to the groups. The
+            -- read action allows fetching the resources and the write action
+            -- allows fetching and modifying them. The optional scope limits
+            -- the permission to a selected app or machine.
+            CREATE TABLE IF NOT EXISTS system_group_permission (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                group_id BIGINT NOT NULL,
+                resource TEXT NOT NULL,
+                action TEXT NOT NULL,
+                scope_type TEXT,
+                scope_id BIGINT,
+                CONSTRAINT system_group_permission_group_id_fkey FOREIGN KEY (group_id)
+                    REFERENCES system_group (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT system_group_permission_action_check CHECK (action IN ('read', 'write')),
+                CONSTRAINT system_group_permission_scope_check CHECK (
+                    (scope_type IS NULL AND scope_id IS NULL) OR
+                    (scope_type IN ('app', 'machine') AND scope_id IS NOT NULL)
+                )
+            );
+            CREATE INDEX system_group_permission_group_id_idx ON system_group_permission (group_id);
+
+            -- The admin group can do everything except managing user accounts
+            -- and groups. It can list the groups, though.
+            INSERT INTO system_group_permission (group_id, resource, action)
+                SELECT 2, r, 'write' FROM unnest(ARRAY[
+                    'machines', 'apps', 'services', 'hosts', 'subnets', 'zones',
+                    'events', 'settings', 'dashboard'
+                ]) AS r;
+            INSERT INTO system_group_permission (group_id, resource, action)
+                VALUES (2, 'groups', 'read');
+
+            -- New predefined groups.
+            INSERT INTO system_group (name, description) VALUES
+                ('read-only', 'This group of users can view all system components except user accounts but cannot modify them.'),
+                ('dhcp-operator', 'This group of users can view all system components except user accounts and manage host reservations.');
+
+            INSERT INTO system_group_permission (group_id, resource, action)
+                SELECT g.id, r, 'read'
+                FROM system_group AS g, unnest(ARRAY[
+                    'machines', 'apps', 'services', 'hosts', 'subnets', 'zones',
+                    'events', 'settings', 'dashboard', 'groups'
+                ]) AS r
+                WHERE g.name IN ('read-only', 'dhcp-operator');
+            INSERT INTO system_group_permission (group_id, resource, action)
+                SELECT id, 'hosts', 'write' FROM system_group WHERE name = 'dhcp-operator';
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DROP TABLE IF EXISTS system_group_permission;
+            DELETE FROM system_group WHERE name IN ('read-only', 'dhcp-operator');
+            ALTER TABLE system_group DROP CONSTRAINT IF EXISTS system_group_name_unique;
+        `)
+		return err
+	})
+}
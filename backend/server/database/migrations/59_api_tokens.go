@@ -0,0 +1,36 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Personal API tokens used by the scripts to access the REST API
+            -- on behalf of the users. Only the hashes of the tokens are
+            -- stored. The optional permissions limit the access of the token
+            -- to a subset of the permissions of the user.
+            CREATE TABLE IF NOT EXISTS api_token (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                name TEXT NOT NULL,
+                token_hash TEXT NOT NULL,
+                created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                expires_at TIMESTAMP WITHOUT TIME ZONE,
+                last_used_at TIMESTAMP WITHOUT TIME ZONE,
+                permissions JSONB,
+                CONSTRAINT api_token_user_id_fkey FOREIGN KEY (user_id)
+                    REFERENCES system_user (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT api_token_token_hash_unique UNIQUE (token_hash),
+                CONSTRAINT api_token_user_id_name_unique UNIQUE (user_id, name)
+            );
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DROP TABLE IF EXISTS api_token;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,44 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Audit trail of the changes made by the users. The user identity
+            -- is copied to the entry, so it is preserved after the user is
+            -- deleted. The diff holds the values of the modified object fields
+            -- before and after the change.
+            CREATE TABLE IF NOT EXISTS audit_entry (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                user_id INTEGER,
+                user_identity TEXT NOT NULL,
+                source_ip TEXT,
+                action TEXT NOT NULL,
+                object_type TEXT NOT NULL,
+                object_id TEXT,
+                object_name TEXT,
+                diff JSONB,
+                CONSTRAINT audit_entry_user_id_fkey FOREIGN KEY (user_id)
+                    REFERENCES system_user (id)
+                        ON UPDATE CASCADE
+                        ON DELETE SET NULL
+            );
+            CREATE INDEX audit_entry_created_at_idx ON audit_entry (created_at);
+            CREATE INDEX audit_entry_user_id_idx ON audit_entry (user_id);
+            CREATE INDEX audit_entry_object_idx ON audit_entry (object_type, object_id);
+
+            -- The admins can view the audit log.
+            INSERT INTO system_group_permission (group_id, resource, action)
+                VALUES (2, 'audit', 'read');
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DELETE FROM system_group_permission WHERE resource = 'audit';
+            DROP TABLE IF EXISTS audit_entry;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,50 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- TOTP second authentication factor of the internal users.
+            -- The secret is inactive until the user confirms the enrollment
+            -- with a valid code. The last used step protects against
+            -- replaying the same code.
+            CREATE TABLE IF NOT EXISTS system_user_totp (
+                user_id INTEGER NOT NULL PRIMARY KEY,
+                secret TEXT NOT NULL,
+                enabled BOOLEAN NOT NULL DEFAULT false,
+                last_used_step BIGINT NOT NULL DEFAULT 0,
+                created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                CONSTRAINT system_user_totp_user_id_fkey FOREIGN KEY (user_id)
+                    REFERENCES system_user (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE
+            );
+
+            -- One-time recovery codes used when the authenticator is not
+            -- available. Only the hashes of the codes are stored.
+            CREATE TABLE IF NOT EXISTS system_user_recovery_code (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                code_hash TEXT NOT NULL,
+                used_at TIMESTAMP WITHOUT TIME ZONE,
+                CONSTRAINT system_user_recovery_code_user_id_fkey FOREIGN KEY (user_id)
+                    REFERENCES system_user (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE
+            );
+            CREATE INDEX system_user_recovery_code_user_id_idx ON system_user_recovery_code (user_id);
+
+            -- Members of the group are required to use the second factor.
+            ALTER TABLE system_group ADD COLUMN IF NOT EXISTS totp_required BOOLEAN NOT NULL DEFAULT false;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            ALTER TABLE system_group DROP COLUMN IF EXISTS totp_required;
+            DROP TABLE IF EXISTS system_user_recovery_code;
+            DROP TABLE IF EXISTS system_user_totp;
+        `)
+		return err
+	})
+}
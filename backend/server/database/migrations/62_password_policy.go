@@ -0,0 +1,61 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Time of the last password change used to enforce the password
+            -- expiration and the flag indicating that the user has to change
+            -- the password on the next login. Adding the columns doesn't
+            -- fire the trigger hashing the password.
+            ALTER TABLE system_user_password
+                ADD COLUMN IF NOT EXISTS changed_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc');
+            ALTER TABLE system_user_password
+                ADD COLUMN IF NOT EXISTS change_required BOOLEAN NOT NULL DEFAULT false;
+
+            -- Hashes of the recent passwords of the users. They are used to
+            -- prevent reusing the passwords. The latest entry is the current
+            -- password.
+            CREATE TABLE IF NOT EXISTS system_user_password_history (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                password_hash TEXT NOT NULL,
+                created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                CONSTRAINT system_user_password_history_user_id_fkey FOREIGN KEY (user_id)
+                    REFERENCES system_user (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE
+            );
+            CREATE INDEX system_user_password_history_user_id_idx ON system_user_password_history (user_id);
+
+            -- Seed the history with the current passwords.
+            INSERT INTO system_user_password_history (user_id, password_hash)
+                SELECT id, password_hash FROM system_user_password;
+
+            -- Failed login attempts of the internal users. The user is locked
+            -- out until the specified time after exceeding the number of the
+            -- allowed attempts. The lockout count is used to extend the
+            -- lockout duration when the user is locked out again.
+            CREATE TABLE IF NOT EXISTS system_user_lockout (
+                user_id INTEGER NOT NULL PRIMARY KEY,
+                failed_attempts INTEGER NOT NULL DEFAULT 0,
+                lockout_count INTEGER NOT NULL DEFAULT 0,
+                locked_until TIMESTAMP WITHOUT TIME ZONE,
+                CONSTRAINT system_user_lockout_user_id_fkey FOREIGN KEY (user_id)
+                    REFERENCES system_user (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE
+            );
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DROP TABLE IF EXISTS system_user_lockout;
+            DROP TABLE IF EXISTS system_user_password_history;
+            ALTER TABLE system_user_password DROP COLUMN IF EXISTS change_required;
+            ALTER TABLE system_user_password DROP COLUMN IF EXISTS changed_at;
+        `)
+		return err
+	})
+}
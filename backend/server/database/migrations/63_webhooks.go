@@ -0,0 +1,57 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Destinations the events are sent to. The events are filtered by
+            -- the minimal level, the relations to the machines, apps, daemons,
+            -- subnets and users, and the regular expression matched against
+            -- the event text. The template is used to render the JSON payload.
+            -- The secret is used to sign the payload.
+            CREATE TABLE IF NOT EXISTS webhook (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                name TEXT NOT NULL,
+                url TEXT NOT NULL,
+                enabled BOOLEAN NOT NULL DEFAULT true,
+                secret TEXT,
+                template TEXT,
+                level INTEGER NOT NULL DEFAULT 0,
+                filters JSONB,
+                text_pattern TEXT,
+                max_retries INTEGER NOT NULL DEFAULT 3,
+                created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                CONSTRAINT webhook_name_unique UNIQUE (name)
+            );
+
+            -- Results of sending the events to the webhooks.
+            CREATE TABLE IF NOT EXISTS webhook_delivery (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                webhook_id BIGINT NOT NULL,
+                event_id INTEGER,
+                created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                attempts INTEGER NOT NULL DEFAULT 0,
+                status_code INTEGER,
+                success BOOLEAN NOT NULL DEFAULT false,
+                error TEXT,
+                CONSTRAINT webhook_delivery_webhook_id_fkey FOREIGN KEY (webhook_id)
+                    REFERENCES webhook (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT webhook_delivery_event_id_fkey FOREIGN KEY (event_id)
+                    REFERENCES event (id)
+                        ON UPDATE CASCADE
+                        ON DELETE SET NULL
+            );
+            CREATE INDEX webhook_delivery_webhook_id_idx ON webhook_delivery (webhook_id);
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DROP TABLE IF EXISTS webhook_delivery;
+            DROP TABLE IF EXISTS webhook;
+        `)
+		return err
+	})
+}
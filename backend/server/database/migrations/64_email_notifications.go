@@ -0,0 +1,61 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Subscriptions of the users to the event notifications sent by
+            -- email. The events are filtered by the minimal level and the
+            -- relations to the machines, apps, daemons, subnets and users.
+            -- The zero digest interval means that each event is sent in a
+            -- separate email. Otherwise, the events are sent in a digest
+            -- every specified number of minutes. The last event ID is the ID
+            -- of the last event included in the digest.
+            CREATE TABLE IF NOT EXISTS email_subscription (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                user_id INTEGER NOT NULL,
+                level INTEGER NOT NULL DEFAULT 0,
+                filters JSONB,
+                digest_interval INTEGER NOT NULL DEFAULT 0,
+                last_event_id INTEGER NOT NULL DEFAULT 0,
+                last_digest_at TIMESTAMP WITHOUT TIME ZONE,
+                created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                CONSTRAINT email_subscription_user_id_fkey FOREIGN KEY (user_id)
+                    REFERENCES system_user (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT email_subscription_digest_interval_check CHECK (digest_interval >= 0)
+            );
+            CREATE INDEX email_subscription_user_id_idx ON email_subscription (user_id);
+
+            -- Results of sending the notifications.
+            CREATE TABLE IF NOT EXISTS email_delivery (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                subscription_id BIGINT,
+                user_id INTEGER NOT NULL,
+                created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                recipient TEXT,
+                event_count INTEGER NOT NULL DEFAULT 0,
+                success BOOLEAN NOT NULL DEFAULT false,
+                error TEXT,
+                CONSTRAINT email_delivery_subscription_id_fkey FOREIGN KEY (subscription_id)
+                    REFERENCES email_subscription (id)
+                        ON UPDATE CASCADE
+                        ON DELETE SET NULL,
+                CONSTRAINT email_delivery_user_id_fkey FOREIGN KEY (user_id)
+                    REFERENCES system_user (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE
+            );
+            CREATE INDEX email_delivery_user_id_idx ON email_delivery (user_id);
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DROP TABLE IF EXISTS email_delivery;
+            DROP TABLE IF EXISTS email_subscription;
+        `)
+		return err
+	})
+}
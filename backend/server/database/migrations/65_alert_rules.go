@@ -0,0 +1,79 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Rules raising the alerts when the subnet or shared network
+            -- statistics cross the threshold. The scope specifies whether
+            -- the rule applies to a single subnet, a single shared network
+            -- or all subnets. The alert is cleared when the value crosses
+            -- the threshold adjusted by the hysteresis in the opposite
+            -- direction. The duration is the number of seconds the
+            -- threshold must be crossed before the alert fires.
+            CREATE TABLE IF NOT EXISTS alert_rule (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                name TEXT NOT NULL,
+                enabled BOOLEAN NOT NULL DEFAULT true,
+                scope TEXT NOT NULL,
+                subnet_id BIGINT,
+                shared_network_id BIGINT,
+                metric TEXT NOT NULL,
+                threshold DOUBLE PRECISION NOT NULL DEFAULT 0,
+                hysteresis DOUBLE PRECISION NOT NULL DEFAULT 0,
+                duration INTEGER NOT NULL DEFAULT 0,
+                level INTEGER NOT NULL DEFAULT 1,
+                created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                CONSTRAINT alert_rule_name_unique UNIQUE (name),
+                CONSTRAINT alert_rule_subnet_id_fkey FOREIGN KEY (subnet_id)
+                    REFERENCES subnet (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT alert_rule_shared_network_id_fkey FOREIGN KEY (shared_network_id)
+                    REFERENCES shared_network (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT alert_rule_scope_check CHECK (
+                    (scope = 'subnet' AND subnet_id IS NOT NULL AND shared_network_id IS NULL) OR
+                    (scope = 'shared-network' AND shared_network_id IS NOT NULL AND subnet_id IS NULL) OR
+                    (scope = 'all' AND subnet_id IS NULL AND shared_network_id IS NULL)
+                ),
+                CONSTRAINT alert_rule_metric_check CHECK (
+                    metric IN ('address-utilization', 'pd-utilization', 'declined', 'free-addresses')
+                ),
+                CONSTRAINT alert_rule_hysteresis_check CHECK (hysteresis >= 0),
+                CONSTRAINT alert_rule_duration_check CHECK (duration >= 0)
+            );
+
+            -- State of the alerts raised by the rules for the particular
+            -- subnets and shared networks. The alert is pending until the
+            -- threshold has been crossed for the rule's duration and then
+            -- it is firing. The state is deleted when the alert clears.
+            CREATE TABLE IF NOT EXISTS alert_state (
+                rule_id BIGINT NOT NULL,
+                object_type TEXT NOT NULL,
+                object_id BIGINT NOT NULL,
+                object_name TEXT,
+                state TEXT NOT NULL,
+                value DOUBLE PRECISION NOT NULL DEFAULT 0,
+                since TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+                fired_at TIMESTAMP WITHOUT TIME ZONE,
+                updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+                CONSTRAINT alert_state_pkey PRIMARY KEY (rule_id, object_type, object_id),
+                CONSTRAINT alert_state_rule_id_fkey FOREIGN KEY (rule_id)
+                    REFERENCES alert_rule (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT alert_state_state_check CHECK (state IN ('pending', 'firing'))
+            );
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DROP TABLE IF EXISTS alert_state;
+            DROP TABLE IF EXISTS alert_rule;
+        `)
+		return err
+	})
+}
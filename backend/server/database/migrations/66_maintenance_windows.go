@@ -0,0 +1,61 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Planned maintenance of a machine, an app or a service. The
+            -- events related to the objects in maintenance are tagged with
+            -- the window ID and are not sent to the webhooks, by email or
+            -- to the forwarders. The downgrade flag indicates that their
+            -- level is lowered to info. The recurring window repeats
+            -- every day or week. The active flag holds the window state
+            -- seen by the server, so the server can raise the events when
+            -- the window starts and ends.
+            CREATE TABLE IF NOT EXISTS maintenance_window (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                name TEXT NOT NULL,
+                description TEXT,
+                machine_id BIGINT,
+                app_id BIGINT,
+                service_id BIGINT,
+                starts_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+                ends_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+                recurrence TEXT NOT NULL DEFAULT 'none',
+                downgrade BOOLEAN NOT NULL DEFAULT false,
+                active BOOLEAN NOT NULL DEFAULT false,
+                created_at TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT (now() AT TIME ZONE 'utc'),
+                CONSTRAINT maintenance_window_machine_id_fkey FOREIGN KEY (machine_id)
+                    REFERENCES machine (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT maintenance_window_app_id_fkey FOREIGN KEY (app_id)
+                    REFERENCES app (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT maintenance_window_service_id_fkey FOREIGN KEY (service_id)
+                    REFERENCES service (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT maintenance_window_scope_check CHECK (num_nonnulls(machine_id, app_id, service_id) = 1),
+                CONSTRAINT maintenance_window_time_check CHECK (ends_at > starts_at),
+                CONSTRAINT maintenance_window_recurrence_check CHECK (recurrence IN ('none', 'daily', 'weekly'))
+            );
+
+            -- Window the event has been raised in.
+            ALTER TABLE event ADD COLUMN IF NOT EXISTS maintenance_window_id BIGINT;
+            ALTER TABLE event ADD CONSTRAINT event_maintenance_window_id_fkey FOREIGN KEY (maintenance_window_id)
+                REFERENCES maintenance_window (id)
+                    ON UPDATE CASCADE
+                    ON DELETE SET NULL;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            ALTER TABLE event DROP COLUMN IF EXISTS maintenance_window_id;
+            DROP TABLE IF EXISTS maintenance_window;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,57 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Utilization history of a subnet, a shared network or a
+            -- daemon. The raw samples are recorded by the statistics puller
+            -- and rolled up to the 5-minute and hourly samples holding the
+            -- average and maximum utilization in the period. The
+            -- utilization is expressed in tenths of a percent, the same as
+            -- in the subnet table.
+            CREATE TABLE IF NOT EXISTS utilization_sample (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                subnet_id BIGINT,
+                shared_network_id BIGINT,
+                daemon_id BIGINT,
+                resolution TEXT NOT NULL,
+                sampled_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+                addr_utilization SMALLINT NOT NULL DEFAULT 0,
+                pd_utilization SMALLINT NOT NULL DEFAULT 0,
+                addr_utilization_max SMALLINT NOT NULL DEFAULT 0,
+                pd_utilization_max SMALLINT NOT NULL DEFAULT 0,
+                CONSTRAINT utilization_sample_subnet_id_fkey FOREIGN KEY (subnet_id)
+                    REFERENCES subnet (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT utilization_sample_shared_network_id_fkey FOREIGN KEY (shared_network_id)
+                    REFERENCES shared_network (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT utilization_sample_daemon_id_fkey FOREIGN KEY (daemon_id)
+                    REFERENCES daemon (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT utilization_sample_object_check CHECK (num_nonnulls(subnet_id, shared_network_id, daemon_id) = 1),
+                CONSTRAINT utilization_sample_resolution_check CHECK (resolution IN ('raw', '5m', '1h'))
+            );
+
+            CREATE UNIQUE INDEX IF NOT EXISTS utilization_sample_subnet_idx
+                ON utilization_sample (subnet_id, resolution, sampled_at) WHERE subnet_id IS NOT NULL;
+            CREATE UNIQUE INDEX IF NOT EXISTS utilization_sample_shared_network_idx
+                ON utilization_sample (shared_network_id, resolution, sampled_at) WHERE shared_network_id IS NOT NULL;
+            CREATE UNIQUE INDEX IF NOT EXISTS utilization_sample_daemon_idx
+                ON utilization_sample (daemon_id, resolution, sampled_at) WHERE daemon_id IS NOT NULL;
+            CREATE INDEX IF NOT EXISTS utilization_sample_resolution_idx
+                ON utilization_sample (resolution, sampled_at);
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DROP TABLE IF EXISTS utilization_sample;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,27 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Forecasted times when the addresses and delegated prefixes
+            -- of the subnets and shared networks are exhausted. They are
+            -- estimated from the utilization history and are NULL if the
+            -- utilization doesn't grow.
+            ALTER TABLE subnet ADD COLUMN IF NOT EXISTS addr_exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+            ALTER TABLE subnet ADD COLUMN IF NOT EXISTS pd_exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+            ALTER TABLE shared_network ADD COLUMN IF NOT EXISTS addr_exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+            ALTER TABLE shared_network ADD COLUMN IF NOT EXISTS pd_exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            ALTER TABLE subnet DROP COLUMN IF EXISTS addr_exhaustion_at;
+            ALTER TABLE subnet DROP COLUMN IF EXISTS pd_exhaustion_at;
+            ALTER TABLE shared_network DROP COLUMN IF EXISTS addr_exhaustion_at;
+            ALTER TABLE shared_network DROP COLUMN IF EXISTS pd_exhaustion_at;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,30 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Statistics of the address and prefix delegation pools returned
+            -- by Kea 2.3.0 and later, and the pool utilization calculated from
+            -- them. The utilization is expressed in tenths of a percent.
+            ALTER TABLE address_pool ADD COLUMN IF NOT EXISTS stats JSONB;
+            ALTER TABLE address_pool ADD COLUMN IF NOT EXISTS stats_collected_at TIMESTAMP WITHOUT TIME ZONE;
+            ALTER TABLE address_pool ADD COLUMN IF NOT EXISTS utilization SMALLINT;
+            ALTER TABLE prefix_pool ADD COLUMN IF NOT EXISTS stats JSONB;
+            ALTER TABLE prefix_pool ADD COLUMN IF NOT EXISTS stats_collected_at TIMESTAMP WITHOUT TIME ZONE;
+            ALTER TABLE prefix_pool ADD COLUMN IF NOT EXISTS utilization SMALLINT;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            ALTER TABLE address_pool DROP COLUMN IF EXISTS stats;
+            ALTER TABLE address_pool DROP COLUMN IF EXISTS stats_collected_at;
+            ALTER TABLE address_pool DROP COLUMN IF EXISTS utilization;
+            ALTER TABLE prefix_pool DROP COLUMN IF EXISTS stats;
+            ALTER TABLE prefix_pool DROP COLUMN IF EXISTS stats_collected_at;
+            ALTER TABLE prefix_pool DROP COLUMN IF EXISTS utilization;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,52 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Named groups of machines. The permissions can be limited to
+            -- the machines belonging to a group and the apps running on them.
+            CREATE TABLE IF NOT EXISTS machine_group (
+                id BIGSERIAL NOT NULL PRIMARY KEY,
+                name TEXT NOT NULL,
+                description TEXT,
+                CONSTRAINT machine_group_name_unique UNIQUE (name)
+            );
+
+            CREATE TABLE IF NOT EXISTS machine_group_to_machine (
+                machine_group_id BIGINT NOT NULL,
+                machine_id BIGINT NOT NULL,
+                CONSTRAINT machine_group_to_machine_pkey PRIMARY KEY (machine_group_id, machine_id),
+                CONSTRAINT machine_group_to_machine_group_id_fkey FOREIGN KEY (machine_group_id)
+                    REFERENCES machine_group (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE,
+                CONSTRAINT machine_group_to_machine_machine_id_fkey FOREIGN KEY (machine_id)
+                    REFERENCES machine (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE
+            );
+            CREATE INDEX machine_group_to_machine_machine_id_idx ON machine_group_to_machine (machine_id);
+
+            ALTER TABLE system_group_permission DROP CONSTRAINT IF EXISTS system_group_permission_scope_check;
+            ALTER TABLE system_group_permission ADD CONSTRAINT system_group_permission_scope_check CHECK (
+                (scope_type IS NULL AND scope_id IS NULL) OR
+                (scope_type IN ('app', 'machine', 'machine-group') AND scope_id IS NOT NULL)
+            );
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            DELETE FROM system_group_permission WHERE scope_type = 'machine-group';
+            ALTER TABLE system_group_permission DROP CONSTRAINT IF EXISTS system_group_permission_scope_check;
+            ALTER TABLE system_group_permission ADD CONSTRAINT system_group_permission_scope_check CHECK (
+                (scope_type IS NULL AND scope_id IS NULL) OR
+                (scope_type IN ('app', 'machine') AND scope_id IS NOT NULL)
+            );
+            DROP TABLE IF EXISTS machine_group_to_machine;
+            DROP TABLE IF EXISTS machine_group;
+        `)
+		return err
+	})
+}
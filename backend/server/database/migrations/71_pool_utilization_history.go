@@ -0,0 +1,56 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+            -- Utilization history of the address and prefix delegation
+            -- pools. The pool samples are recorded and rolled up the same
+            -- way as the subnet samples.
+            ALTER TABLE utilization_sample ADD COLUMN IF NOT EXISTS address_pool_id BIGINT;
+            ALTER TABLE utilization_sample ADD COLUMN IF NOT EXISTS prefix_pool_id BIGINT;
+            ALTER TABLE utilization_sample
+                ADD CONSTRAINT utilization_sample_address_pool_id_fkey FOREIGN KEY (address_pool_id)
+                    REFERENCES address_pool (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE;
+            ALTER TABLE utilization_sample
+                ADD CONSTRAINT utilization_sample_prefix_pool_id_fkey FOREIGN KEY (prefix_pool_id)
+                    REFERENCES prefix_pool (id)
+                        ON UPDATE CASCADE
+                        ON DELETE CASCADE;
+            ALTER TABLE utilization_sample DROP CONSTRAINT IF EXISTS utilization_sample_object_check;
+            ALTER TABLE utilization_sample
+                ADD CONSTRAINT utilization_sample_object_check
+                    CHECK (num_nonnulls(subnet_id, shared_network_id, daemon_id, address_pool_id, prefix_pool_id) = 1);
+
+            CREATE UNIQUE INDEX IF NOT EXISTS utilization_sample_address_pool_idx
+                ON utilization_sample (address_pool_id, resolution, sampled_at) WHERE address_pool_id IS NOT NULL;
+            CREATE UNIQUE INDEX IF NOT EXISTS utilization_sample_prefix_pool_idx
+                ON utilization_sample (prefix_pool_id, resolution, sampled_at) WHERE prefix_pool_id IS NOT NULL;
+
+            -- Forecasted time when the pool is exhausted. It is NULL if
+            -- the pool utilization doesn't grow.
+            ALTER TABLE address_pool ADD COLUMN IF NOT EXISTS exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+            ALTER TABLE prefix_pool ADD COLUMN IF NOT EXISTS exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+        `)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+            ALTER TABLE address_pool DROP COLUMN IF EXISTS exhaustion_at;
+            ALTER TABLE prefix_pool DROP COLUMN IF EXISTS exhaustion_at;
+            DELETE FROM utilization_sample
+                WHERE address_pool_id IS NOT NULL OR prefix_pool_id IS NOT NULL;
+            ALTER TABLE utilization_sample DROP CONSTRAINT IF EXISTS utilization_sample_object_check;
+            ALTER TABLE utilization_sample
+                ADD CONSTRAINT utilization_sample_object_check
+                    CHECK (num_nonnulls(subnet_id, shared_network_id, daemon_id) = 1);
+            DROP INDEX IF EXISTS utilization_sample_address_pool_idx;
+            DROP INDEX IF EXISTS utilization_sample_prefix_pool_idx;
+            ALTER TABLE utilization_sample DROP COLUMN IF EXISTS address_pool_id;
+            ALTER TABLE utilization_sample DROP COLUMN IF EXISTS prefix_pool_id;
+        `)
+		return err
+	})
+}
@@ -0,0 +1,277 @@
+package dbmodel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Specifies the objects the alert rule applies to.
+type AlertScope string
+
+// Supported alert rule scopes.
+const (
+	AlertScopeSubnet        AlertScope = "subnet"
+	AlertScopeSharedNetwork AlertScope = "shared-network"
+	AlertScopeAll           AlertScope = "all"
+)
+
+// Specifies the statistic checked by the alert rule.
+type AlertMetric string
+
+// Supported alert rule metrics. The utilizations are expressed in
+// percents. The declined metric is the number of the declined addresses
+// and the free addresses metric is the number of the addresses that are
+// not assigned.
+const (
+	AlertMetricAddressUtilization AlertMetric = "address-utilization"
+	AlertMetricPDUtilization      AlertMetric = "pd-utilization"
+	AlertMetricDeclined           AlertMetric = "declined"
+	AlertMetricFreeAddresses      AlertMetric = "free-addresses"
+)
+
+// State of the alert raised by the rule for a particular object.
+type AlertStateName string
+
+// Supported alert states. The alert is pending when the threshold has
+// been crossed but not for the duration specified in the rule yet.
+const (
+	AlertStatePending AlertStateName = "pending"
+	AlertStateFiring  AlertStateName = "firing"
+)
+
+// Types of the objects the alerts are raised for.
+const (
+	AlertObjectSubnet        = "subnet"
+	AlertObjectSharedNetwork = "shared-network"
+)
+
+// Represents a rule raising the alerts when the subnet or shared network
+// statistic crosses the threshold. The subnet ID is set for the subnet
+// scope and the shared network ID is set for the shared network scope.
+// The rule with the all scope applies to all subnets and all shared
+// networks, and each of them is checked separately. The alert is cleared
+// when the value crosses the threshold adjusted by the hysteresis in the
+// opposite direction. The duration is the number of seconds the threshold
+// must be crossed before the alert fires. The level is the level of the
+// event raised when the alert fires.
+type AlertRule struct {
+	ID              int64
+	Name            string
+	Enabled         bool `pg:",use_zero"`
+	Scope           AlertScope
+	SubnetID        int64
+	SharedNetworkID int64
+	Metric          AlertMetric
+	Threshold       float64    `pg:",use_zero"`
+	Hysteresis      float64    `pg:",use_zero"`
+	Duration        int        `pg:",use_zero"`
+	Level           EventLevel `pg:",use_zero"`
+	CreatedAt       time.Time
+}
+
+// Represents the state of the alert raised by the rule for a subnet or
+// a shared network. The since value is the time when the threshold was
+// crossed and the fired at value is the time when the alert fired. The
+// value is the most recent value of the metric.
+type AlertState struct {
+	RuleID     int64      `pg:",pk"`
+	Rule       *AlertRule `pg:"rel:has-one"`
+	ObjectType string     `pg:",pk"`
+	ObjectID   int64      `pg:",pk"`
+	ObjectName string
+	State      AlertStateName
+	Value      float64 `pg:",use_zero"`
+	Since      time.Time
+	FiredAt    time.Time
+	UpdatedAt  time.Time
+}
+
+// Checks if the metric is the "higher is worse" metric, i.e. the alert
+// fires when the value is greater than or equal to the threshold. The
+// free addresses metric fires when the value drops to the threshold.
+func (rule *AlertRule) isRising() bool {
+	return rule.Metric != AlertMetricFreeAddresses
+}
+
+// Checks if the value crosses the threshold.
+func (rule *AlertRule) IsBreached(value float64) bool {
+	if rule.isRising() {
+		return value >= rule.Threshold
+	}
+	return value <= rule.Threshold
+}
+
+// Checks if the value crosses the threshold adjusted by the hysteresis
+// in the direction clearing the alert.
+func (rule *AlertRule) IsCleared(value float64) bool {
+	if rule.isRising() {
+		return value < rule.Threshold-rule.Hysteresis
+	}
+	return value > rule.Threshold+rule.Hysteresis
+}
+
+// Checks if the error returned by the database is caused by the duplicated
+// alert rule name.
+func isAlertRuleConflict(err error) bool {
+	var pgError pg.Error
+	return errors.As(err, &pgError) && pgError.IntegrityViolation()
+}
+
+// Inserts a new alert rule into the database. The returned conflict value
+// indicates that a rule with the same name already exists.
+func AddAlertRule(dbi dbops.DBI, rule *AlertRule) (conflict bool, err error) {
+	if rule.CreatedAt.IsZero() {
+		rule.CreatedAt = time.Now().UTC()
+	}
+	_, err = dbi.Model(rule).Insert()
+	if err != nil {
+		return isAlertRuleConflict(err), pkgerrors.Wrapf(err, "problem inserting alert rule %s", rule.Name)
+	}
+	return false, nil
+}
+
+// Updates the alert rule and removes the states of its alerts in a
+// transaction.
+func updateAlertRule(tx *pg.Tx, rule *AlertRule) (conflict bool, err error) {
+	result, err := tx.Model(rule).
+		ExcludeColumn("created_at").
+		WherePK().
+		Update()
+	if err != nil {
+		return isAlertRuleConflict(err), pkgerrors.Wrapf(err, "problem updating alert rule %d", rule.ID)
+	} else if result.RowsAffected() <= 0 {
+		return false, pkgerrors.Wrapf(ErrNotExists, "alert rule %d does not exist", rule.ID)
+	}
+	_, err = tx.Model((*AlertState)(nil)).Where("rule_id = ?", rule.ID).Delete()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem deleting alert states of rule %d", rule.ID)
+	}
+	return false, nil
+}
+
+// Updates the alert rule in the database. The states of the alerts raised
+// by the rule are removed, so the rule is evaluated from scratch. The
+// returned conflict value indicates that another rule with the same name
+// already exists. It returns ErrNotExists if the rule doesn't exist. It
+// begins a new transaction when dbi has a *pg.DB type or uses an existing
+// transaction when dbi has a *pg.Tx type.
+func UpdateAlertRule(dbi dbops.DBI, rule *AlertRule) (conflict bool, err error) {
+	if db, ok := dbi.(*pg.DB); ok {
+		err = db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			conflict, err = updateAlertRule(tx, rule)
+			return err
+		})
+		return
+	}
+	return updateAlertRule(dbi.(*pg.Tx), rule)
+}
+
+// Returns all alert rules ordered by ID.
+func GetAlertRules(dbi dbops.DBI) ([]AlertRule, error) {
+	rules := []AlertRule{}
+	err := dbi.Model(&rules).OrderExpr("id ASC").Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting alert rules")
+	}
+	return rules, nil
+}
+
+// Returns the enabled alert rules ordered by ID.
+func GetEnabledAlertRules(dbi dbops.DBI) ([]AlertRule, error) {
+	rules := []AlertRule{}
+	err := dbi.Model(&rules).Where("enabled").OrderExpr("id ASC").Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting enabled alert rules")
+	}
+	return rules, nil
+}
+
+// Returns the alert rule with the specified ID. It returns nil if the
+// rule doesn't exist.
+func GetAlertRuleByID(dbi dbops.DBI, id int64) (*AlertRule, error) {
+	rule := &AlertRule{}
+	err := dbi.Model(rule).Where("id = ?", id).Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting alert rule %d", id)
+	}
+	return rule, nil
+}
+
+// Deletes the alert rule together with the states of its alerts. It
+// returns ErrNotExists if the rule doesn't exist.
+func DeleteAlertRule(dbi dbops.DBI, id int64) error {
+	result, err := dbi.Model((*AlertRule)(nil)).Where("id = ?", id).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting alert rule %d", id)
+	} else if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "alert rule %d does not exist", id)
+	}
+	return nil
+}
+
+// Inserts or updates the alert state.
+func UpsertAlertState(dbi dbops.DBI, state *AlertState) error {
+	_, err := dbi.Model(state).
+		OnConflict("(rule_id, object_type, object_id) DO UPDATE").
+		Set("object_name = EXCLUDED.object_name").
+		Set("state = EXCLUDED.state").
+		Set("value = EXCLUDED.value").
+		Set("since = EXCLUDED.since").
+		Set("fired_at = EXCLUDED.fired_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem upserting state of alert rule %d for %s %d",
+			state.RuleID, state.ObjectType, state.ObjectID)
+	}
+	return nil
+}
+
+// Returns the states of the alerts raised by the rule.
+func GetAlertStatesByRuleID(dbi dbops.DBI, ruleID int64) ([]AlertState, error) {
+	states := []AlertState{}
+	err := dbi.Model(&states).
+		Where("rule_id = ?", ruleID).
+		OrderExpr("object_type ASC, object_id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting alert states of rule %d", ruleID)
+	}
+	return states, nil
+}
+
+// Returns the states of the alerts raised by all rules together with the
+// rules. The firing alerts are returned first, starting from the most
+// recent ones.
+func GetAlertStates(dbi dbops.DBI) ([]AlertState, error) {
+	states := []AlertState{}
+	err := dbi.Model(&states).
+		Relation("Rule").
+		OrderExpr("alert_state.state ASC, alert_state.since DESC, alert_state.rule_id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting alert states")
+	}
+	return states, nil
+}
+
+// Deletes the alert state.
+func DeleteAlertState(dbi dbops.DBI, ruleID int64, objectType string, objectID int64) error {
+	_, err := dbi.Model((*AlertState)(nil)).
+		Where("rule_id = ?", ruleID).
+		Where("object_type = ?", objectType).
+		Where("object_id = ?", objectID).
+		Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting state of alert rule %d for %s %d",
+			ruleID, objectType, objectID)
+	}
+	return nil
+}
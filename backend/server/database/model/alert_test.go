@@ -0,0 +1,193 @@
+package dbmodel
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test checking if the value crosses the threshold or clears the alert.
+func TestAlertRuleIsBreachedIsCleared(t *testing.T) {
+	rule := &AlertRule{
+		Metric:     AlertMetricAddressUtilization,
+		Threshold:  90,
+		Hysteresis: 5,
+	}
+	require.True(t, rule.IsBreached(90))
+	require.True(t, rule.IsBreached(95))
+	require.False(t, rule.IsBreached(89.9))
+	require.False(t, rule.IsCleared(85))
+	require.True(t, rule.IsCleared(84.9))
+
+	// The alert fires when the number of the free addresses drops.
+	rule = &AlertRule{
+		Metric:     AlertMetricFreeAddresses,
+		Threshold:  10,
+		Hysteresis: 5,
+	}
+	require.True(t, rule.IsBreached(10))
+	require.True(t, rule.IsBreached(0))
+	require.False(t, rule.IsBreached(11))
+	require.False(t, rule.IsCleared(15))
+	require.True(t, rule.IsCleared(16))
+}
+
+// Test that the alert rules can be added, updated, fetched and deleted.
+func TestAddGetUpdateDeleteAlertRule(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &Subnet{Prefix: "192.0.2.0/24"}
+	require.NoError(t, AddSubnet(db, subnet))
+
+	rule := &AlertRule{
+		Name:      "subnet full",
+		Enabled:   true,
+		Scope:     AlertScopeSubnet,
+		SubnetID:  subnet.ID,
+		Metric:    AlertMetricAddressUtilization,
+		Threshold: 90,
+		Duration:  300,
+		Level:     EvWarning,
+	}
+	conflict, err := AddAlertRule(db, rule)
+	require.NoError(t, err)
+	require.False(t, conflict)
+	require.NotZero(t, rule.ID)
+
+	disabled := &AlertRule{
+		Name:   "declined",
+		Scope:  AlertScopeAll,
+		Metric: AlertMetricDeclined,
+		Level:  EvError,
+	}
+	_, err = AddAlertRule(db, disabled)
+	require.NoError(t, err)
+
+	// The rule name must be unique.
+	conflict, err = AddAlertRule(db, &AlertRule{Name: "subnet full", Scope: AlertScopeAll, Metric: AlertMetricDeclined})
+	require.Error(t, err)
+	require.True(t, conflict)
+
+	rules, err := GetAlertRules(db)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	require.Equal(t, "subnet full", rules[0].Name)
+	require.Equal(t, subnet.ID, rules[0].SubnetID)
+	require.EqualValues(t, 90, rules[0].Threshold)
+	require.Equal(t, 300, rules[0].Duration)
+	require.False(t, rules[1].Enabled)
+
+	rules, err = GetEnabledAlertRules(db)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	require.Equal(t, rule.ID, rules[0].ID)
+
+	// Updating the rule removes the states of its alerts.
+	require.NoError(t, UpsertAlertState(db, &AlertState{
+		RuleID:     rule.ID,
+		ObjectType: AlertObjectSubnet,
+		ObjectID:   subnet.ID,
+		State:      AlertStateFiring,
+		Since:      time.Now().UTC(),
+		UpdatedAt:  time.Now().UTC(),
+	}))
+	rule.Threshold = 80
+	conflict, err = UpdateAlertRule(db, rule)
+	require.NoError(t, err)
+	require.False(t, conflict)
+	returned, err := GetAlertRuleByID(db, rule.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 80, returned.Threshold)
+	states, err := GetAlertStatesByRuleID(db, rule.ID)
+	require.NoError(t, err)
+	require.Empty(t, states)
+
+	disabled.Name = "subnet full"
+	conflict, err = UpdateAlertRule(db, disabled)
+	require.Error(t, err)
+	require.True(t, conflict)
+
+	_, err = UpdateAlertRule(db, &AlertRule{ID: rule.ID + 100, Name: "other", Scope: AlertScopeAll, Metric: AlertMetricDeclined})
+	require.True(t, errors.Is(err, ErrNotExists))
+
+	require.NoError(t, DeleteAlertRule(db, rule.ID))
+	err = DeleteAlertRule(db, rule.ID)
+	require.True(t, errors.Is(err, ErrNotExists))
+	returned, err = GetAlertRuleByID(db, rule.ID)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+}
+
+// Test that the alert states can be inserted, updated, fetched and deleted.
+func TestUpsertGetDeleteAlertState(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rule := &AlertRule{Name: "free", Scope: AlertScopeAll, Metric: AlertMetricFreeAddresses, Level: EvWarning}
+	_, err := AddAlertRule(db, rule)
+	require.NoError(t, err)
+
+	since := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	pending := &AlertState{
+		RuleID:     rule.ID,
+		ObjectType: AlertObjectSubnet,
+		ObjectID:   1,
+		ObjectName: "192.0.2.0/24",
+		State:      AlertStatePending,
+		Value:      5,
+		Since:      since,
+		UpdatedAt:  since,
+	}
+	require.NoError(t, UpsertAlertState(db, pending))
+	firing := &AlertState{
+		RuleID:     rule.ID,
+		ObjectType: AlertObjectSharedNetwork,
+		ObjectID:   1,
+		ObjectName: "frontend",
+		State:      AlertStateFiring,
+		Since:      since.Add(-time.Hour),
+		FiredAt:    since,
+		UpdatedAt:  since,
+	}
+	require.NoError(t, UpsertAlertState(db, firing))
+
+	// Update the existing state.
+	pending.Value = 3
+	pending.UpdatedAt = since.Add(time.Minute)
+	require.NoError(t, UpsertAlertState(db, pending))
+
+	states, err := GetAlertStatesByRuleID(db, rule.ID)
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+	require.Equal(t, AlertObjectSharedNetwork, states[0].ObjectType)
+	require.Equal(t, AlertObjectSubnet, states[1].ObjectType)
+	require.EqualValues(t, 3, states[1].Value)
+	require.Equal(t, since.Add(time.Minute), states[1].UpdatedAt)
+
+	// The firing alerts are returned first.
+	states, err = GetAlertStates(db)
+	require.NoError(t, err)
+	require.Len(t, states, 2)
+	require.Equal(t, AlertStateFiring, states[0].State)
+	require.Equal(t, "frontend", states[0].ObjectName)
+	require.Equal(t, since, states[0].FiredAt)
+	require.NotNil(t, states[0].Rule)
+	require.Equal(t, "free", states[0].Rule.Name)
+	require.Equal(t, AlertStatePending, states[1].State)
+	require.True(t, states[1].FiredAt.IsZero())
+
+	require.NoError(t, DeleteAlertState(db, rule.ID, AlertObjectSubnet, 1))
+	states, err = GetAlertStatesByRuleID(db, rule.ID)
+	require.NoError(t, err)
+	require.Len(t, states, 1)
+
+	// Deleting the rule deletes its states.
+	require.NoError(t, DeleteAlertRule(db, rule.ID))
+	states, err = GetAlertStates(db)
+	require.NoError(t, err)
+	require.Empty(t, states)
+}
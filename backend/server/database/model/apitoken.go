@@ -0,0 +1,153 @@
+package dbmodel
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Prefix of the generated API tokens. It makes the tokens easy to
+// recognize, e.g. by the secret scanners.
+const APITokenPrefix = "stork_"
+
+// Represents a personal API token of a user. The token itself is never
+// stored in the database. The hash of the token is used to find the token
+// presented by a client. The zero expiration time means that the token
+// never expires. The permissions, if specified, limit the access of the
+// token to a subset of the permissions the user has.
+type APIToken struct {
+	ID          int64
+	UserID      int
+	User        *SystemUser `pg:"rel:has-one"`
+	Name        string
+	TokenHash   string
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+	LastUsedAt  time.Time
+	Permissions []SystemGroupPermission
+}
+
+// Generates a new random API token. It returns the token and its hash to
+// be stored in the database.
+func GenerateAPIToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", pkgerrors.Wrap(err, "failed to generate random API token")
+	}
+	token = APITokenPrefix + hex.EncodeToString(b)
+	return token, HashAPIToken(token), nil
+}
+
+// Returns the hash of the API token. The tokens are long random strings,
+// so a single round of SHA-256 is sufficient to protect them.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Checks if the token has expired at the specified time.
+func (token *APIToken) IsExpired(now time.Time) bool {
+	return !token.ExpiresAt.IsZero() && !now.Before(token.ExpiresAt)
+}
+
+// Checks if the token has its own permissions limiting the access.
+func (token *APIToken) HasPermissions() bool {
+	return len(token.Permissions) > 0
+}
+
+// Inserts a new API token into the database. The returned conflict value
+// indicates that the user already has a token with the same name.
+func AddAPIToken(dbi dbops.DBI, token *APIToken) (conflict bool, err error) {
+	for i := range token.Permissions {
+		if err = token.Permissions[i].Validate(); err != nil {
+			return false, err
+		}
+	}
+	if token.CreatedAt.IsZero() {
+		token.CreatedAt = time.Now().UTC()
+	}
+	_, err = dbi.Model(token).Insert()
+	if err != nil {
+		var pgError pg.Error
+		if errors.As(err, &pgError) {
+			conflict = pgError.IntegrityViolation()
+		}
+		return conflict, pkgerrors.Wrapf(err, "problem inserting API token %s of user %d", token.Name, token.UserID)
+	}
+	return false, nil
+}
+
+// Returns the API tokens of the user ordered by ID.
+func GetAPITokensByUserID(dbi dbops.DBI, userID int) ([]APIToken, error) {
+	tokens := []APIToken{}
+	err := dbi.Model(&tokens).
+		Where("user_id = ?", userID).
+		OrderExpr("id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting API tokens of user %d", userID)
+	}
+	return tokens, nil
+}
+
+// Returns the API token with the specified hash together with the user
+// and the user's groups. It returns nil if the token doesn't exist.
+func GetAPITokenByHash(dbi dbops.DBI, hash string) (*APIToken, error) {
+	token := &APIToken{}
+	err := dbi.Model(token).
+		Relation("User.Groups").
+		Where("api_token.token_hash = ?", hash).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting API token by hash")
+	}
+	return token, nil
+}
+
+// Sets the time when the token was last used.
+func UpdateAPITokenLastUsed(dbi dbops.DBI, id int64, lastUsedAt time.Time) error {
+	_, err := dbi.Model((*APIToken)(nil)).
+		Set("last_used_at = ?", lastUsedAt).
+		Where("id = ?", id).
+		Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem updating last use time of API token %d", id)
+	}
+	return nil
+}
+
+// Deletes (revokes) the API token of the user. It returns ErrNotExists if
+// the user has no such token.
+func DeleteAPIToken(dbi dbops.DBI, userID int, id int64) error {
+	result, err := dbi.Model((*APIToken)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting API token %d of user %d", id, userID)
+	} else if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "API token %d of user %d does not exist", id, userID)
+	}
+	return nil
+}
+
+// Deletes (revokes) all API tokens of the user. It is called when the
+// administrator resets the password of the user, e.g. because the account
+// may have been compromised.
+func DeleteAPITokensByUserID(dbi dbops.DBI, userID int) error {
+	_, err := dbi.Model((*APIToken)(nil)).
+		Where("user_id = ?", userID).
+		Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting API tokens of user %d", userID)
+	}
+	return nil
+}
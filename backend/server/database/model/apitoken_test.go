@@ -0,0 +1,144 @@
+package dbmodel
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the generated tokens are random and hashed.
+func TestGenerateAPIToken(t *testing.T) {
+	token1, hash1, err := GenerateAPIToken()
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(token1, APITokenPrefix))
+	require.Len(t, token1, len(APITokenPrefix)+64)
+	require.Equal(t, HashAPIToken(token1), hash1)
+	require.NotContains(t, hash1, token1)
+
+	token2, hash2, err := GenerateAPIToken()
+	require.NoError(t, err)
+	require.NotEqual(t, token1, token2)
+	require.NotEqual(t, hash1, hash2)
+}
+
+// Test the token expiration.
+func TestAPITokenIsExpired(t *testing.T) {
+	now := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	token := &APIToken{}
+	require.False(t, token.IsExpired(now))
+
+	token.ExpiresAt = now.Add(time.Second)
+	require.False(t, token.IsExpired(now))
+
+	token.ExpiresAt = now
+	require.True(t, token.IsExpired(now))
+}
+
+// Test that the API tokens can be added, fetched and deleted.
+func TestAddGetDeleteAPIToken(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "bot",
+		Lastname: "Bot",
+		Name:     "CI",
+		Groups:   []*SystemGroup{{ID: 3}},
+	}
+	_, err := CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	plain, hash, err := GenerateAPIToken()
+	require.NoError(t, err)
+	expiresAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	token := &APIToken{
+		UserID:    user.ID,
+		Name:      "ci",
+		TokenHash: hash,
+		ExpiresAt: expiresAt,
+		Permissions: []SystemGroupPermission{
+			{Resource: PermissionResourceHosts, Action: PermissionActionRead},
+		},
+	}
+	conflict, err := AddAPIToken(db, token)
+	require.NoError(t, err)
+	require.False(t, conflict)
+	require.NotZero(t, token.ID)
+
+	// The token name must be unique for the user.
+	conflict, err = AddAPIToken(db, &APIToken{UserID: user.ID, Name: "ci", TokenHash: "abc"})
+	require.Error(t, err)
+	require.True(t, conflict)
+
+	// Invalid permissions are rejected.
+	_, err = AddAPIToken(db, &APIToken{
+		UserID:      user.ID,
+		Name:        "invalid",
+		TokenHash:   "def",
+		Permissions: []SystemGroupPermission{{Resource: "foo", Action: PermissionActionRead}},
+	})
+	require.Error(t, err)
+
+	// Token without expiration and permissions.
+	_, hash2, err := GenerateAPIToken()
+	require.NoError(t, err)
+	_, err = AddAPIToken(db, &APIToken{UserID: user.ID, Name: "backup", TokenHash: hash2})
+	require.NoError(t, err)
+
+	tokens, err := GetAPITokensByUserID(db, user.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 2)
+	require.Equal(t, "ci", tokens[0].Name)
+	require.Equal(t, expiresAt, tokens[0].ExpiresAt)
+	require.True(t, tokens[0].LastUsedAt.IsZero())
+	require.True(t, tokens[0].HasPermissions())
+	require.Equal(t, PermissionResourceHosts, tokens[0].Permissions[0].Resource)
+	require.Equal(t, "backup", tokens[1].Name)
+	require.True(t, tokens[1].ExpiresAt.IsZero())
+	require.False(t, tokens[1].HasPermissions())
+
+	// Find the token by hash.
+	returned, err := GetAPITokenByHash(db, HashAPIToken(plain))
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+	require.Equal(t, token.ID, returned.ID)
+	require.NotNil(t, returned.User)
+	require.Equal(t, "bot", returned.User.Login)
+	require.Len(t, returned.User.Groups, 1)
+	require.EqualValues(t, 3, returned.User.Groups[0].ID)
+
+	returned, err = GetAPITokenByHash(db, HashAPIToken("stork_unknown"))
+	require.NoError(t, err)
+	require.Nil(t, returned)
+
+	// Record the token use.
+	lastUsedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	err = UpdateAPITokenLastUsed(db, token.ID, lastUsedAt)
+	require.NoError(t, err)
+	tokens, err = GetAPITokensByUserID(db, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, lastUsedAt, tokens[0].LastUsedAt)
+
+	// The token of the user can be deleted only once.
+	require.ErrorIs(t, DeleteAPIToken(db, user.ID+1, token.ID), ErrNotExists)
+	require.NoError(t, DeleteAPIToken(db, user.ID, token.ID))
+	require.ErrorIs(t, DeleteAPIToken(db, user.ID, token.ID), ErrNotExists)
+
+	// All tokens of the user can be revoked at once.
+	require.NoError(t, DeleteAPITokensByUserID(db, user.ID))
+	tokens, err = GetAPITokensByUserID(db, user.ID)
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+	require.NoError(t, DeleteAPITokensByUserID(db, user.ID))
+	_, err = AddAPIToken(db, &APIToken{UserID: user.ID, Name: "backup", TokenHash: hash2})
+	require.NoError(t, err)
+
+	// The tokens are deleted together with the user.
+	require.NoError(t, DeleteUser(db, user))
+	tokens, err = GetAPITokensByUserID(db, user.ID)
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+}
@@ -37,6 +37,10 @@ type App struct {
 	Active    bool
 	Meta      AppMeta
 	Name      string
+	// Path to the main configuration file of the app reported by the
+	// agent. It identifies the app instance when multiple instances of
+	// the same type run on the machine.
+	ConfigPath string
 
 	AccessPoints []*AccessPoint `pg:"rel:has-many"`
 
@@ -554,6 +558,11 @@ func (app App) GetControlAccessPoint() (address string, port int64, key string,
 	return
 }
 
+// Returns the path to the main configuration file of the app.
+func (app App) GetConfigPath() string {
+	return app.ConfigPath
+}
+
 // Returns MachineTag interface to the machine owning the app.
 func (app App) GetMachineTag() MachineTag {
 	return app.Machine
@@ -0,0 +1,145 @@
+package dbmodel
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditActionCreate    = "create"
+	AuditActionUpdate    = "update"
+	AuditActionDelete    = "delete"
+	AuditActionAuthorize = "authorize"
+	AuditActionDownload  = "download"
+)
+
+// Types of the objects recorded in the audit log.
+const (
+	AuditObjectHost                  = "host"
+	AuditObjectMachine               = "machine"
+	AuditObjectMachineGroup          = "machine-group"
+	AuditObjectSettings              = "settings"
+	AuditObjectConfigCheckerSettings = "config-checkers"
+	AuditObjectUser                  = "user"
+	AuditObjectGroup                 = "group"
+	AuditObjectAPIToken              = "api-token"
+	AuditObjectDump                  = "dump"
+	AuditObjectTOTP                  = "totp"
+	AuditObjectWebhook               = "webhook"
+	AuditObjectEmailSubscription     = "email-subscription"
+	AuditObjectAlertRule             = "alert-rule"
+	AuditObjectMaintenanceWindow     = "maintenance-window"
+)
+
+// Value of the object field before and after the change. The nil value
+// means that the field was not set.
+type AuditChange struct {
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// Represents an entry of the audit log describing a change made by a user.
+// The user identity is copied to the entry because the user may be deleted
+// while the entry is preserved. In such a case, the user ID is zero. The
+// diff maps the names of the modified object fields to their values before
+// and after the change.
+type AuditEntry struct {
+	ID           int64
+	CreatedAt    time.Time
+	UserID       int
+	UserIdentity string
+	SourceIP     string
+	Action       string
+	ObjectType   string
+	ObjectID     string
+	ObjectName   string
+	Diff         map[string]AuditChange
+}
+
+// Criteria used to filter the audit entries. The nil values are ignored.
+type AuditEntriesByPageFilters struct {
+	UserID     *int64
+	Action     *string
+	ObjectType *string
+	ObjectID   *string
+	From       *time.Time
+	To         *time.Time
+}
+
+// Inserts the audit entry into the database. The zero user ID is stored
+// as NULL.
+func AddAuditEntry(dbi dbops.DBI, entry *AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now().UTC()
+	}
+	_, err := dbi.Model(entry).Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem inserting audit entry %s %s", entry.Action, entry.ObjectType)
+	}
+	return nil
+}
+
+// Fetches a page of the audit entries matching the filters. The offset
+// and limit specify the beginning of the page and the maximum size of the
+// page. Limit has to be greater than 0, otherwise error is returned.
+// sortField allows indicating sort column in database and sortDir allows
+// selection the order of sorting. If sortField is empty then id is used
+// for sorting. It returns the entries and the total number of the entries
+// matching the filters.
+func GetAuditEntriesByPage(dbi dbops.DBI, offset, limit int64, filters *AuditEntriesByPageFilters, sortField string, sortDir SortDirEnum) ([]AuditEntry, int64, error) {
+	if limit == 0 {
+		return nil, 0, pkgerrors.New("limit should be greater than 0")
+	}
+	entries := []AuditEntry{}
+
+	q := dbi.Model(&entries)
+	if filters != nil {
+		if filters.UserID != nil {
+			q = q.Where("user_id = ?", *filters.UserID)
+		}
+		if filters.Action != nil {
+			q = q.Where("action = ?", *filters.Action)
+		}
+		if filters.ObjectType != nil {
+			q = q.Where("object_type = ?", *filters.ObjectType)
+		}
+		if filters.ObjectID != nil {
+			q = q.Where("object_id = ?", *filters.ObjectID)
+		}
+		if filters.From != nil {
+			q = q.Where("created_at >= ?", filters.From.UTC())
+		}
+		if filters.To != nil {
+			q = q.Where("created_at < ?", filters.To.UTC())
+		}
+	}
+
+	q = q.OrderExpr(prepareOrderExpr("audit_entry", sortField, sortDir))
+	q = q.Offset(int(offset))
+	q = q.Limit(int(limit))
+
+	total, err := q.SelectAndCount()
+	if err != nil {
+		if pkgerrors.Is(err, pg.ErrNoRows) {
+			return []AuditEntry{}, 0, nil
+		}
+		return nil, 0, pkgerrors.Wrap(err, "problem getting audit entries")
+	}
+	return entries, int64(total), nil
+}
+
+// Deletes the audit entries created before the specified time. It returns
+// the number of deleted entries.
+func DeleteAuditEntriesBefore(dbi dbops.DBI, before time.Time) (int64, error) {
+	result, err := dbi.Model((*AuditEntry)(nil)).
+		Where("created_at < ?", before.UTC()).
+		Delete()
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "problem deleting audit entries older than %s", before)
+	}
+	return int64(result.RowsAffected()), nil
+}
@@ -0,0 +1,166 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the audit entries can be added, filtered and fetched by page.
+func TestAddGetAuditEntries(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "auditor",
+		Lastname: "Auditor",
+		Name:     "Anne",
+	}
+	_, err := CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	entries := []AuditEntry{
+		{
+			CreatedAt:    now.Add(-2 * time.Hour),
+			UserID:       user.ID,
+			UserIdentity: user.Identity(),
+			SourceIP:     "192.0.2.1",
+			Action:       AuditActionUpdate,
+			ObjectType:   AuditObjectSettings,
+			Diff: map[string]AuditChange{
+				"bind9StatsPullerInterval": {Before: float64(60), After: float64(30)},
+			},
+		},
+		{
+			CreatedAt:    now.Add(-time.Hour),
+			UserID:       user.ID,
+			UserIdentity: user.Identity(),
+			Action:       AuditActionDelete,
+			ObjectType:   AuditObjectHost,
+			ObjectID:     "7",
+			ObjectName:   "hw-address=01:02:03:04:05:06",
+		},
+		{
+			CreatedAt:    now,
+			UserIdentity: "removed",
+			Action:       AuditActionDelete,
+			ObjectType:   AuditObjectHost,
+			ObjectID:     "8",
+		},
+	}
+	for i := range entries {
+		require.NoError(t, AddAuditEntry(db, &entries[i]))
+		require.NotZero(t, entries[i].ID)
+	}
+
+	// No filters.
+	returned, total, err := GetAuditEntriesByPage(db, 0, 10, nil, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	require.Len(t, returned, 3)
+	require.Equal(t, "192.0.2.1", returned[0].SourceIP)
+	require.Equal(t, user.ID, returned[0].UserID)
+	require.Contains(t, returned[0].Diff, "bind9StatsPullerInterval")
+	require.EqualValues(t, 60, returned[0].Diff["bind9StatsPullerInterval"].Before)
+	require.EqualValues(t, 30, returned[0].Diff["bind9StatsPullerInterval"].After)
+	require.Zero(t, returned[2].UserID)
+
+	// Paging and sorting.
+	returned, total, err = GetAuditEntriesByPage(db, 0, 1, nil, "created_at", SortDirDesc)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	require.Len(t, returned, 1)
+	require.Equal(t, "8", returned[0].ObjectID)
+
+	// Filters.
+	userID := int64(user.ID)
+	action := AuditActionDelete
+	objectType := AuditObjectHost
+	objectID := "7"
+	from := now.Add(-90 * time.Minute)
+	to := now
+
+	filters := &AuditEntriesByPageFilters{UserID: &userID}
+	_, total, err = GetAuditEntriesByPage(db, 0, 10, filters, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+
+	filters = &AuditEntriesByPageFilters{Action: &action, ObjectType: &objectType}
+	_, total, err = GetAuditEntriesByPage(db, 0, 10, filters, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+
+	filters = &AuditEntriesByPageFilters{ObjectType: &objectType, ObjectID: &objectID}
+	returned, total, err = GetAuditEntriesByPage(db, 0, 10, filters, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, entries[1].ID, returned[0].ID)
+
+	filters = &AuditEntriesByPageFilters{From: &from, To: &to}
+	returned, total, err = GetAuditEntriesByPage(db, 0, 10, filters, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, entries[1].ID, returned[0].ID)
+
+	// Zero limit is not allowed.
+	_, _, err = GetAuditEntriesByPage(db, 0, 0, nil, "", SortDirAny)
+	require.Error(t, err)
+}
+
+// Test that the user ID is cleared when the user is deleted but the
+// audit entries are preserved.
+func TestAuditEntryUserDeleted(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "auditor",
+		Lastname: "Auditor",
+		Name:     "Anne",
+	}
+	_, err := CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	entry := &AuditEntry{
+		UserID:       user.ID,
+		UserIdentity: user.Identity(),
+		Action:       AuditActionCreate,
+		ObjectType:   AuditObjectUser,
+	}
+	require.NoError(t, AddAuditEntry(db, entry))
+	require.NoError(t, DeleteUser(db, user))
+
+	returned, total, err := GetAuditEntriesByPage(db, 0, 10, nil, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Zero(t, returned[0].UserID)
+	require.Equal(t, user.Identity(), returned[0].UserIdentity)
+}
+
+// Test that the old audit entries are deleted.
+func TestDeleteAuditEntriesBefore(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	now := time.Now().UTC()
+	for _, age := range []time.Duration{48 * time.Hour, 25 * time.Hour, time.Hour} {
+		entry := &AuditEntry{
+			CreatedAt:    now.Add(-age),
+			UserIdentity: "admin",
+			Action:       AuditActionUpdate,
+			ObjectType:   AuditObjectSettings,
+		}
+		require.NoError(t, AddAuditEntry(db, entry))
+	}
+
+	count, err := DeleteAuditEntriesBefore(db, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.EqualValues(t, 2, count)
+
+	_, total, err := GetAuditEntriesByPage(db, 0, 10, nil, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+}
@@ -0,0 +1,197 @@
+package dbmodel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Zone types reported by BIND 9. The legacy names (master, slave) are
+// converted to the current ones when the zones are fetched.
+const (
+	Bind9ZoneTypePrimary   = "primary"
+	Bind9ZoneTypeSecondary = "secondary"
+)
+
+// A structure reflecting a zone served by a BIND 9 daemon in a given view.
+// The serial, loaded, refresh and expiry times are valid only if the zone
+// is loaded. The refresh and expiry times are set only for the secondary
+// zones.
+type Bind9Zone struct {
+	ID        int64
+	DaemonID  int64
+	Daemon    *Daemon `pg:"rel:has-one"`
+	View      string
+	Name      string
+	Class     string
+	Type      string
+	Serial    int64 `pg:",use_zero"`
+	Loaded    bool  `pg:",use_zero"`
+	LoadedAt  time.Time
+	RefreshAt time.Time
+	ExpiresAt time.Time
+	UpdatedAt time.Time
+}
+
+// Container for values filtering zones fetched by page.
+type Bind9ZonesByPageFilters struct {
+	AppID    *int64
+	DaemonID *int64
+	ZoneType *string
+	Text     *string
+}
+
+// Replaces the zones of the daemon with the specified ones in a transaction.
+// The zones are upserted by the daemon, view, name and class so the zones
+// still served by the daemon keep their IDs. Only the zones that are no
+// longer served are deleted.
+func commitBind9Zones(tx *pg.Tx, daemonID int64, zones []*Bind9Zone) error {
+	ids := []int64{}
+	if len(zones) > 0 {
+		updatedAt := time.Now().UTC()
+		for _, zone := range zones {
+			zone.ID = 0
+			zone.DaemonID = daemonID
+			zone.UpdatedAt = updatedAt
+		}
+		_, err := tx.Model(&zones).
+			OnConflict("(daemon_id, view, name, class) DO UPDATE").
+			Set("type = EXCLUDED.type").
+			Set("serial = EXCLUDED.serial").
+			Set("loaded = EXCLUDED.loaded").
+			Set("loaded_at = EXCLUDED.loaded_at").
+			Set("refresh_at = EXCLUDED.refresh_at").
+			Set("expires_at = EXCLUDED.expires_at").
+			Set("updated_at = EXCLUDED.updated_at").
+			Returning("id").
+			Insert()
+		if err != nil {
+			return pkgerrors.Wrapf(err, "problem upserting zones of daemon %d", daemonID)
+		}
+		for _, zone := range zones {
+			ids = append(ids, zone.ID)
+		}
+	}
+	q := tx.Model((*Bind9Zone)(nil)).
+		Where("daemon_id = ?", daemonID)
+	if len(ids) > 0 {
+		q = q.Where("id NOT IN (?)", pg.In(ids))
+	}
+	if _, err := q.Delete(); err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting zones of daemon %d", daemonID)
+	}
+	return nil
+}
+
+// Replaces the zones of the daemon with the specified ones. The zones
+// no longer served by the daemon are removed from the database.
+func CommitBind9Zones(dbi dbops.DBI, daemonID int64, zones []*Bind9Zone) error {
+	if db, ok := dbi.(*pg.DB); ok {
+		return db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			return commitBind9Zones(tx, daemonID, zones)
+		})
+	}
+	return commitBind9Zones(dbi.(*pg.Tx), daemonID, zones)
+}
+
+// Retrieves the zone from the database by ID. It returns nil if the zone
+// doesn't exist.
+func GetBind9ZoneByID(dbi dbops.DBI, id int64) (*Bind9Zone, error) {
+	zone := Bind9Zone{}
+	err := dbi.Model(&zone).
+		Relation("Daemon.App.Machine").
+		Where("bind9_zone.id = ?", id).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting zone with ID %d", id)
+	}
+	return &zone, nil
+}
+
+// Retrieves all zones of the daemon ordered by the view and name.
+func GetBind9ZonesByDaemonID(dbi dbops.DBI, daemonID int64) ([]Bind9Zone, error) {
+	zones := []Bind9Zone{}
+	err := dbi.Model(&zones).
+		Where("bind9_zone.daemon_id = ?", daemonID).
+		OrderExpr("bind9_zone.view ASC, bind9_zone.name ASC, bind9_zone.class ASC").
+		Select()
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		return nil, pkgerrors.Wrapf(err, "problem getting zones of daemon %d", daemonID)
+	}
+	return zones, nil
+}
+
+// Retrieves all zones served by the BIND 9 daemons with the daemons,
+// apps and machines.
+func GetAllBind9Zones(dbi dbops.DBI) ([]Bind9Zone, error) {
+	zones := []Bind9Zone{}
+	err := dbi.Model(&zones).
+		Relation("Daemon.App.Machine").
+		OrderExpr("bind9_zone.name ASC, bind9_zone.class ASC, bind9_zone.view ASC, bind9_zone.id ASC").
+		Select()
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		return nil, pkgerrors.Wrapf(err, "problem getting zones")
+	}
+	return zones, nil
+}
+
+// Fetches a collection of zones from the database. The offset and limit
+// specify the beginning of the page and the maximum size of the page.
+// Limit has to be greater then 0, otherwise error is returned. The filters
+// allow selecting zones served by a given app or daemon, of a given type,
+// and zones whose name or view contains the specified text. sortField
+// allows indicating sort column in database and sortDir allows selection
+// the order of sorting. If sortField is empty then id is used for sorting.
+// If SortDirAny is used then ASC order is used.
+func GetBind9ZonesByPage(db *pg.DB, offset, limit int64, filters *Bind9ZonesByPageFilters, sortField string, sortDir SortDirEnum) ([]Bind9Zone, int64, error) {
+	if limit == 0 {
+		return nil, 0, pkgerrors.New("limit should be greater than 0")
+	}
+	zones := []Bind9Zone{}
+
+	// prepare query
+	q := db.Model(&zones).
+		Relation("Daemon.App.Machine")
+
+	if filters != nil {
+		if filters.AppID != nil {
+			q = q.Where("daemon.app_id = ?", *filters.AppID)
+		}
+		if filters.DaemonID != nil {
+			q = q.Where("bind9_zone.daemon_id = ?", *filters.DaemonID)
+		}
+		if filters.ZoneType != nil {
+			q = q.Where("bind9_zone.type = ?", *filters.ZoneType)
+		}
+		if filters.Text != nil {
+			text := "%" + *filters.Text + "%"
+			q = q.WhereGroup(func(qq *orm.Query) (*orm.Query, error) {
+				qq = qq.WhereOr("bind9_zone.name ILIKE ?", text).
+					WhereOr("bind9_zone.view ILIKE ?", text)
+				return qq, nil
+			})
+		}
+	}
+
+	// prepare sorting expression, offset and limit
+	ordExpr := prepareOrderExpr("bind9_zone", sortField, sortDir)
+	q = q.OrderExpr(ordExpr)
+	q = q.Offset(int(offset))
+	q = q.Limit(int(limit))
+
+	total, err := q.SelectAndCount()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return []Bind9Zone{}, 0, nil
+		}
+		return nil, 0, pkgerrors.Wrapf(err, "problem getting zones")
+	}
+	return zones, int64(total), nil
+}
@@ -0,0 +1,208 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Adds a machine with a BIND 9 app to the database and returns the app.
+func addTestBind9App(t *testing.T, db *pg.DB, address string) *App {
+	m := &Machine{
+		Address:   address,
+		AgentPort: 8080,
+	}
+	err := AddMachine(db, m)
+	require.NoError(t, err)
+
+	a := &App{
+		MachineID: m.ID,
+		Type:      AppTypeBind9,
+		Active:    true,
+		Daemons: []*Daemon{
+			NewBind9Daemon(true),
+		},
+	}
+	_, err = AddApp(db, a)
+	require.NoError(t, err)
+	require.Len(t, a.Daemons, 1)
+	require.NotZero(t, a.Daemons[0].ID)
+	return a
+}
+
+// Test that the zones are committed to the database and updated on
+// subsequent commits. The zones still served by the daemon should keep
+// their IDs.
+func TestCommitBind9Zones(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	app := addTestBind9App(t, db, "localhost")
+	daemonID := app.Daemons[0].ID
+
+	loadedAt := time.Date(2023, 1, 1, 10, 0, 0, 0, time.UTC)
+	zones := []*Bind9Zone{
+		{
+			View:     "_default",
+			Name:     "example.org",
+			Class:    "IN",
+			Type:     Bind9ZoneTypeSecondary,
+			Serial:   2,
+			Loaded:   true,
+			LoadedAt: loadedAt,
+		},
+		{
+			View:   "_default",
+			Name:   "example.com",
+			Class:  "IN",
+			Type:   Bind9ZoneTypePrimary,
+			Serial: 1,
+			Loaded: true,
+		},
+	}
+	err := CommitBind9Zones(db, daemonID, zones)
+	require.NoError(t, err)
+
+	returned, err := GetBind9ZonesByDaemonID(db, daemonID)
+	require.NoError(t, err)
+	require.Len(t, returned, 2)
+	require.Equal(t, "example.com", returned[0].Name)
+	require.Equal(t, Bind9ZoneTypePrimary, returned[0].Type)
+	require.EqualValues(t, 1, returned[0].Serial)
+	require.Zero(t, returned[0].LoadedAt)
+	require.Equal(t, "example.org", returned[1].Name)
+	require.Equal(t, loadedAt, returned[1].LoadedAt)
+	require.NotZero(t, returned[1].UpdatedAt)
+
+	exampleComID := returned[0].ID
+
+	// Update one zone and remove the other one.
+	zones[1].Serial = 3
+	err = CommitBind9Zones(db, daemonID, zones[1:])
+	require.NoError(t, err)
+	require.Equal(t, exampleComID, zones[1].ID)
+	returned, err = GetBind9ZonesByDaemonID(db, daemonID)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+	require.Equal(t, "example.com", returned[0].Name)
+	require.Equal(t, exampleComID, returned[0].ID)
+	require.EqualValues(t, 3, returned[0].Serial)
+
+	// Remove all zones.
+	err = CommitBind9Zones(db, daemonID, nil)
+	require.NoError(t, err)
+	returned, err = GetBind9ZonesByDaemonID(db, daemonID)
+	require.NoError(t, err)
+	require.Empty(t, returned)
+}
+
+// Test that the zone can be fetched by ID with the daemon, app and machine.
+func TestGetBind9ZoneByID(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	app := addTestBind9App(t, db, "localhost")
+	zones := []*Bind9Zone{
+		{View: "_default", Name: "example.com", Class: "IN", Type: Bind9ZoneTypePrimary},
+	}
+	err := CommitBind9Zones(db, app.Daemons[0].ID, zones)
+	require.NoError(t, err)
+	require.NotZero(t, zones[0].ID)
+
+	zone, err := GetBind9ZoneByID(db, zones[0].ID)
+	require.NoError(t, err)
+	require.NotNil(t, zone)
+	require.Equal(t, "example.com", zone.Name)
+	require.NotNil(t, zone.Daemon)
+	require.NotNil(t, zone.Daemon.App)
+	require.NotNil(t, zone.Daemon.App.Machine)
+	require.Equal(t, "localhost", zone.Daemon.App.Machine.Address)
+
+	zone, err = GetBind9ZoneByID(db, zones[0].ID+1)
+	require.NoError(t, err)
+	require.Nil(t, zone)
+}
+
+// Test that the zones are fetched by page and filtered.
+func TestGetBind9ZonesByPage(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	app1 := addTestBind9App(t, db, "host1")
+	app2 := addTestBind9App(t, db, "host2")
+
+	err := CommitBind9Zones(db, app1.Daemons[0].ID, []*Bind9Zone{
+		{View: "_default", Name: "example.com", Class: "IN", Type: Bind9ZoneTypePrimary},
+		{View: "_default", Name: "example.org", Class: "IN", Type: Bind9ZoneTypePrimary},
+	})
+	require.NoError(t, err)
+	err = CommitBind9Zones(db, app2.Daemons[0].ID, []*Bind9Zone{
+		{View: "external", Name: "example.com", Class: "IN", Type: Bind9ZoneTypeSecondary},
+	})
+	require.NoError(t, err)
+
+	// Zero limit is not allowed.
+	_, _, err = GetBind9ZonesByPage(db, 0, 0, nil, "", SortDirAny)
+	require.Error(t, err)
+
+	// No filters.
+	zones, total, err := GetBind9ZonesByPage(db, 0, 10, nil, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	require.Len(t, zones, 3)
+	require.NotNil(t, zones[0].Daemon)
+	require.NotNil(t, zones[0].Daemon.App)
+
+	// Paging.
+	zones, total, err = GetBind9ZonesByPage(db, 1, 1, nil, "name", SortDirAsc)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	require.Len(t, zones, 1)
+	require.Equal(t, "example.com", zones[0].Name)
+
+	// Filter by app.
+	zones, total, err = GetBind9ZonesByPage(db, 0, 10, &Bind9ZonesByPageFilters{AppID: &app2.ID}, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, "external", zones[0].View)
+
+	// Filter by zone type.
+	zoneType := Bind9ZoneTypePrimary
+	_, total, err = GetBind9ZonesByPage(db, 0, 10, &Bind9ZonesByPageFilters{ZoneType: &zoneType}, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+
+	// Filter by text.
+	text := "org"
+	zones, total, err = GetBind9ZonesByPage(db, 0, 10, &Bind9ZonesByPageFilters{Text: &text}, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.Equal(t, "example.org", zones[0].Name)
+
+	text = "extern"
+	_, total, err = GetBind9ZonesByPage(db, 0, 10, &Bind9ZonesByPageFilters{Text: &text}, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+}
+
+// Test that the zones are deleted together with the daemon.
+func TestBind9ZonesDeletedWithDaemon(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	app := addTestBind9App(t, db, "localhost")
+	err := CommitBind9Zones(db, app.Daemons[0].ID, []*Bind9Zone{
+		{View: "_default", Name: "example.com", Class: "IN", Type: Bind9ZoneTypePrimary},
+	})
+	require.NoError(t, err)
+
+	err = DeleteApp(db, app)
+	require.NoError(t, err)
+
+	zones, err := GetAllBind9Zones(db)
+	require.NoError(t, err)
+	require.Empty(t, zones)
+}
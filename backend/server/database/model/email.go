@@ -0,0 +1,194 @@
+package dbmodel
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Maximum number of the email deliveries held in the log of each user.
+// The oldest deliveries are removed when the new ones are added.
+const EmailDeliveryLogMaxSize = 1000
+
+// Represents a subscription of a user to the event notifications sent by
+// email. The events are sent if their level is equal to or greater than
+// the subscription level and they are related to the objects specified in
+// the filters. The zero digest interval means that each event is sent in
+// a separate email. Otherwise, the events are sent in a digest every
+// specified number of minutes. The last event ID is the ID of the last
+// event processed for the digest.
+type EmailSubscription struct {
+	ID             int64
+	UserID         int
+	User           *SystemUser `pg:"rel:has-one"`
+	Level          EventLevel  `pg:",use_zero"`
+	Filters        *Relations
+	DigestInterval int   `pg:",use_zero"`
+	LastEventID    int64 `pg:",use_zero"`
+	LastDigestAt   time.Time
+	CreatedAt      time.Time
+}
+
+// Represents the result of sending an email to a user. The subscription
+// ID is zero if the subscription has been deleted.
+type EmailDelivery struct {
+	ID             int64
+	SubscriptionID int64
+	UserID         int
+	CreatedAt      time.Time
+	Recipient      string
+	EventCount     int  `pg:",use_zero"`
+	Success        bool `pg:",use_zero"`
+	Error          string
+}
+
+// Checks if the subscription sends the events in digests.
+func (s *EmailSubscription) IsDigest() bool {
+	return s.DigestInterval > 0
+}
+
+// Checks if the digest should be sent at the specified time.
+func (s *EmailSubscription) IsDigestDue(now time.Time) bool {
+	return s.IsDigest() && !now.Before(s.LastDigestAt.Add(time.Duration(s.DigestInterval)*time.Minute))
+}
+
+// Inserts a new subscription into the database. The events created before
+// the subscription are not included in the digests.
+func AddEmailSubscription(dbi dbops.DBI, subscription *EmailSubscription) error {
+	lastEventID, err := GetLastEventID(dbi)
+	if err != nil {
+		return err
+	}
+	subscription.LastEventID = lastEventID
+	if subscription.CreatedAt.IsZero() {
+		subscription.CreatedAt = time.Now().UTC()
+	}
+	subscription.LastDigestAt = subscription.CreatedAt
+	_, err = dbi.Model(subscription).Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem inserting email subscription of user %d", subscription.UserID)
+	}
+	return nil
+}
+
+// Returns the subscriptions of the user ordered by ID.
+func GetEmailSubscriptionsByUserID(dbi dbops.DBI, userID int) ([]EmailSubscription, error) {
+	subscriptions := []EmailSubscription{}
+	err := dbi.Model(&subscriptions).
+		Where("user_id = ?", userID).
+		OrderExpr("id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting email subscriptions of user %d", userID)
+	}
+	return subscriptions, nil
+}
+
+// Returns the subscriptions of all users together with the users. The
+// digest parameter selects the subscriptions sending the events in
+// digests or the subscriptions sending each event separately.
+func GetEmailSubscriptions(dbi dbops.DBI, digest bool) ([]EmailSubscription, error) {
+	subscriptions := []EmailSubscription{}
+	q := dbi.Model(&subscriptions).Relation("User")
+	if digest {
+		q = q.Where("digest_interval > 0")
+	} else {
+		q = q.Where("digest_interval = 0")
+	}
+	err := q.OrderExpr("email_subscription.id ASC").Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting email subscriptions")
+	}
+	return subscriptions, nil
+}
+
+// Sets the ID of the last event processed for the digest and the time
+// the digest was sent.
+func UpdateEmailSubscriptionDigest(dbi dbops.DBI, id, lastEventID int64, lastDigestAt time.Time) error {
+	_, err := dbi.Model((*EmailSubscription)(nil)).
+		Set("last_event_id = ?", lastEventID).
+		Set("last_digest_at = ?", lastDigestAt).
+		Where("id = ?", id).
+		Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem updating digest of email subscription %d", id)
+	}
+	return nil
+}
+
+// Deletes the subscription of the user. It returns ErrNotExists if the
+// user has no such subscription.
+func DeleteEmailSubscription(dbi dbops.DBI, userID int, id int64) error {
+	result, err := dbi.Model((*EmailSubscription)(nil)).
+		Where("id = ?", id).
+		Where("user_id = ?", userID).
+		Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting email subscription %d of user %d", id, userID)
+	} else if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "email subscription %d of user %d does not exist", id, userID)
+	}
+	return nil
+}
+
+// Adds the delivery to the log in a transaction.
+func addEmailDelivery(tx *pg.Tx, delivery *EmailDelivery) error {
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now().UTC()
+	}
+	_, err := tx.Model(delivery).Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem inserting email delivery to user %d", delivery.UserID)
+	}
+
+	recent := tx.Model((*EmailDelivery)(nil)).
+		Column("id").
+		Where("user_id = ?", delivery.UserID).
+		OrderExpr("id DESC").
+		Limit(EmailDeliveryLogMaxSize)
+	_, err = tx.Model((*EmailDelivery)(nil)).
+		Where("user_id = ?", delivery.UserID).
+		Where("id NOT IN (?)", recent).
+		Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem pruning email delivery log of user %d", delivery.UserID)
+	}
+	return nil
+}
+
+// Adds the delivery to the log of the user and removes the oldest
+// deliveries exceeding the maximum log size. It begins a new transaction
+// when dbi has a *pg.DB type or uses an existing transaction when dbi has
+// a *pg.Tx type.
+func AddEmailDelivery(dbi dbops.DBI, delivery *EmailDelivery) error {
+	if db, ok := dbi.(*pg.DB); ok {
+		return db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			return addEmailDelivery(tx, delivery)
+		})
+	}
+	return addEmailDelivery(dbi.(*pg.Tx), delivery)
+}
+
+// Returns the email deliveries to the user, starting from the most recent
+// ones. The offset and limit specify the beginning of the page and the
+// maximum size of the page. It also returns the total number of the
+// deliveries to the user.
+func GetEmailDeliveriesByPage(dbi dbops.DBI, userID int, offset, limit int64) ([]EmailDelivery, int64, error) {
+	if limit == 0 {
+		return nil, 0, pkgerrors.New("limit should be greater than 0")
+	}
+	deliveries := []EmailDelivery{}
+	total, err := dbi.Model(&deliveries).
+		Where("user_id = ?", userID).
+		OrderExpr("id DESC").
+		Offset(int(offset)).
+		Limit(int(limit)).
+		SelectAndCount()
+	if err != nil {
+		return nil, 0, pkgerrors.Wrapf(err, "problem getting email deliveries to user %d", userID)
+	}
+	return deliveries, int64(total), nil
+}
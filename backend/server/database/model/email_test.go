@@ -0,0 +1,195 @@
+package dbmodel
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the email subscriptions can be added, fetched and deleted.
+func TestAddGetDeleteEmailSubscription(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "operator",
+		Email:    "operator@example.org",
+		Lastname: "Operator",
+		Name:     "Otto",
+	}
+	_, err := CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	// The events created before the subscription are not sent.
+	require.NoError(t, AddEvent(db, &Event{Text: "old event", Level: EvError}))
+	lastEventID, err := GetLastEventID(db)
+	require.NoError(t, err)
+	require.NotZero(t, lastEventID)
+
+	immediate := &EmailSubscription{
+		UserID:  user.ID,
+		Level:   EvWarning,
+		Filters: &Relations{MachineID: 1},
+	}
+	require.NoError(t, AddEmailSubscription(db, immediate))
+	require.NotZero(t, immediate.ID)
+	require.Equal(t, lastEventID, immediate.LastEventID)
+	require.Equal(t, immediate.CreatedAt, immediate.LastDigestAt)
+
+	digest := &EmailSubscription{
+		UserID:         user.ID,
+		DigestInterval: 60,
+	}
+	require.NoError(t, AddEmailSubscription(db, digest))
+
+	subscriptions, err := GetEmailSubscriptionsByUserID(db, user.ID)
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 2)
+	require.Equal(t, immediate.ID, subscriptions[0].ID)
+	require.Equal(t, EvWarning, subscriptions[0].Level)
+	require.EqualValues(t, 1, subscriptions[0].Filters.MachineID)
+	require.False(t, subscriptions[0].IsDigest())
+	require.Equal(t, digest.ID, subscriptions[1].ID)
+	require.True(t, subscriptions[1].IsDigest())
+
+	subscriptions, err = GetEmailSubscriptions(db, false)
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 1)
+	require.Equal(t, immediate.ID, subscriptions[0].ID)
+	require.NotNil(t, subscriptions[0].User)
+	require.Equal(t, "operator@example.org", subscriptions[0].User.Email)
+
+	subscriptions, err = GetEmailSubscriptions(db, true)
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 1)
+	require.Equal(t, digest.ID, subscriptions[0].ID)
+
+	// Record sending the digest.
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, UpdateEmailSubscriptionDigest(db, digest.ID, lastEventID+5, now))
+	subscriptions, err = GetEmailSubscriptions(db, true)
+	require.NoError(t, err)
+	require.Equal(t, lastEventID+5, subscriptions[0].LastEventID)
+	require.WithinDuration(t, now, subscriptions[0].LastDigestAt, time.Millisecond)
+
+	// The subscription can be deleted only by its owner.
+	err = DeleteEmailSubscription(db, user.ID+1, immediate.ID)
+	require.True(t, errors.Is(err, ErrNotExists))
+	require.NoError(t, DeleteEmailSubscription(db, user.ID, immediate.ID))
+	err = DeleteEmailSubscription(db, user.ID, immediate.ID)
+	require.True(t, errors.Is(err, ErrNotExists))
+
+	subscriptions, err = GetEmailSubscriptionsByUserID(db, user.ID)
+	require.NoError(t, err)
+	require.Len(t, subscriptions, 1)
+}
+
+// Test checking if the digest should be sent.
+func TestEmailSubscriptionIsDigestDue(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	subscription := &EmailSubscription{LastDigestAt: now.Add(-30 * time.Minute)}
+	require.False(t, subscription.IsDigestDue(now))
+
+	subscription.DigestInterval = 60
+	require.False(t, subscription.IsDigestDue(now))
+
+	subscription.DigestInterval = 30
+	require.True(t, subscription.IsDigestDue(now))
+
+	subscription.DigestInterval = 15
+	require.True(t, subscription.IsDigestDue(now))
+}
+
+// Test that the events created after the specified event are returned.
+func TestGetEventsAfterID(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	lastEventID, err := GetLastEventID(db)
+	require.NoError(t, err)
+	require.Zero(t, lastEventID)
+
+	for _, text := range []string{"first", "second", "third"} {
+		require.NoError(t, AddEvent(db, &Event{Text: text}))
+	}
+	lastEventID, err = GetLastEventID(db)
+	require.NoError(t, err)
+	require.NotZero(t, lastEventID)
+
+	events, err := GetEventsAfterID(db, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, "first", events[0].Text)
+	require.Equal(t, "second", events[1].Text)
+
+	events, err = GetEventsAfterID(db, events[1].ID, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	require.Equal(t, "third", events[0].Text)
+	require.Equal(t, lastEventID, events[0].ID)
+
+	events, err = GetEventsAfterID(db, lastEventID, 10)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+// Test that the email deliveries are recorded and pruned.
+func TestAddGetEmailDeliveries(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "operator",
+		Email:    "operator@example.org",
+		Lastname: "Operator",
+		Name:     "Otto",
+	}
+	_, err := CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	subscription := &EmailSubscription{UserID: user.ID}
+	require.NoError(t, AddEmailSubscription(db, subscription))
+
+	require.NoError(t, AddEmailDelivery(db, &EmailDelivery{
+		SubscriptionID: subscription.ID,
+		UserID:         user.ID,
+		Recipient:      user.Email,
+		EventCount:     1,
+		Success:        true,
+	}))
+	require.NoError(t, AddEmailDelivery(db, &EmailDelivery{
+		SubscriptionID: subscription.ID,
+		UserID:         user.ID,
+		Recipient:      user.Email,
+		EventCount:     3,
+		Error:          "connection refused",
+	}))
+
+	deliveries, total, err := GetEmailDeliveriesByPage(db, user.ID, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Len(t, deliveries, 2)
+	require.False(t, deliveries[0].Success)
+	require.Equal(t, 3, deliveries[0].EventCount)
+	require.Equal(t, "connection refused", deliveries[0].Error)
+	require.True(t, deliveries[1].Success)
+
+	deliveries, total, err = GetEmailDeliveriesByPage(db, user.ID, 1, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Len(t, deliveries, 1)
+	require.True(t, deliveries[0].Success)
+
+	_, _, err = GetEmailDeliveriesByPage(db, user.ID, 0, 0)
+	require.Error(t, err)
+
+	// The deliveries are kept after deleting the subscription.
+	require.NoError(t, DeleteEmailSubscription(db, user.ID, subscription.ID))
+	deliveries, total, err = GetEmailDeliveriesByPage(db, user.ID, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Zero(t, deliveries[0].SubscriptionID)
+}
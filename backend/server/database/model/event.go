@@ -2,10 +2,13 @@ package dbmodel
 
 import (
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/go-pg/pg/v10"
 	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
 )
 
 // The event severity level.
@@ -41,14 +44,17 @@ type Relations struct {
 	UserID    int64 `json:",omitempty"`
 }
 
-// Represents an event held in event table in the database.
+// Represents an event held in event table in the database. The maintenance
+// window ID is set when the event has been raised for an object in the
+// maintenance.
 type Event struct {
-	ID        int64
-	CreatedAt time.Time
-	Text      string
-	Level     EventLevel `pg:",use_zero"`
-	Relations *Relations
-	Details   string
+	ID                  int64
+	CreatedAt           time.Time
+	Text                string
+	Level               EventLevel `pg:",use_zero"`
+	Relations           *Relations
+	Details             string
+	MaintenanceWindowID int64
 }
 
 // Add given event to the database.
@@ -113,3 +119,85 @@ func GetEventsByPage(db *pg.DB, offset int64, limit int64, level EventLevel, dae
 	}
 	return events, int64(total), nil
 }
+
+// Fetches the events having the IDs greater than the specified ID, ordered
+// by ID. The limit specifies the maximum number of the returned events.
+func GetEventsAfterID(dbi dbops.DBI, id int64, limit int) ([]Event, error) {
+	events := []Event{}
+	err := dbi.Model(&events).
+		Where("id > ?", id).
+		OrderExpr("id ASC").
+		Limit(limit).
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting events after ID %d", id)
+	}
+	return events, nil
+}
+
+// Returns the ID of the most recent event or zero if there are no events.
+func GetLastEventID(dbi dbops.DBI) (int64, error) {
+	var id int64
+	_, err := dbi.QueryOne(pg.Scan(&id), "SELECT COALESCE(MAX(id), 0) FROM event")
+	if err != nil {
+		return 0, pkgerrors.Wrap(err, "problem getting the last event ID")
+	}
+	return id, nil
+}
+
+// Fetches the events which should be pruned, ordered by ID. The events
+// created before the specified time are pruned; the zero time disables
+// pruning by age. The maximum counts specify how many most recent events
+// of each level are kept; the levels not present in the map or having the
+// zero count are not limited. The limit specifies the maximum number of
+// the returned events.
+func GetEventsToPrune(dbi dbops.DBI, before time.Time, maxCounts map[EventLevel]int64, limit int) ([]Event, error) {
+	var conditions []string
+	var params []interface{}
+	if !before.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		params = append(params, before.UTC())
+	}
+	levels := []EventLevel{EvInfo, EvWarning, EvError}
+	for _, level := range levels {
+		if maxCounts[level] > 0 {
+			conditions = append(conditions, "(level = ? AND rank > ?)")
+			params = append(params, level, maxCounts[level])
+		}
+	}
+	events := []Event{}
+	if len(conditions) == 0 {
+		return events, nil
+	}
+	err := dbi.Model(&events).
+		Where(fmt.Sprintf(`id IN (
+			SELECT id FROM (
+				SELECT id, created_at, level,
+					ROW_NUMBER() OVER (PARTITION BY level ORDER BY created_at DESC, id DESC) AS rank
+				FROM event
+			) AS ranked
+			WHERE %s
+		)`, strings.Join(conditions, " OR ")), params...).
+		OrderExpr("id ASC").
+		Limit(limit).
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting events to prune")
+	}
+	return events, nil
+}
+
+// Deletes the events with the specified IDs. It returns the number of
+// the deleted events.
+func DeleteEvents(dbi dbops.DBI, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result, err := dbi.Model((*Event)(nil)).
+		Where("id IN (?)", pg.In(ids)).
+		Delete()
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "problem deleting %d events", len(ids))
+	}
+	return int64(result.RowsAffected()), nil
+}
@@ -2,6 +2,7 @@ package dbmodel
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	dbtest "isc.org/stork/server/database/test"
@@ -175,3 +176,60 @@ func TestConvertLevelToString(t *testing.T) {
 	require.EqualValues(t, "error", EvError.String())
 	require.EqualValues(t, "unknown", EventLevel(42).String())
 }
+
+// Test that the events exceeding the retention limits are selected and
+// deleted.
+func TestGetEventsToPruneAndDelete(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	var ids []int64
+	for i, level := range []EventLevel{EvInfo, EvError, EvInfo, EvInfo, EvError} {
+		event := &Event{
+			CreatedAt: now.AddDate(0, 0, i-4),
+			Text:      "event",
+			Level:     level,
+		}
+		require.NoError(t, AddEvent(db, event))
+		ids = append(ids, event.ID)
+	}
+
+	// No limits.
+	events, err := GetEventsToPrune(db, time.Time{}, nil, 10)
+	require.NoError(t, err)
+	require.Empty(t, events)
+
+	// The events older than two days.
+	events, err = GetEventsToPrune(db, now.AddDate(0, 0, -2), nil, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, ids[0], events[0].ID)
+	require.Equal(t, ids[1], events[1].ID)
+
+	// The most recent info event is kept.
+	events, err = GetEventsToPrune(db, time.Time{}, map[EventLevel]int64{EvInfo: 1}, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, ids[0], events[0].ID)
+	require.Equal(t, ids[2], events[1].ID)
+
+	// The limits are combined.
+	events, err = GetEventsToPrune(db, now.AddDate(0, 0, -3), map[EventLevel]int64{EvInfo: 2, EvError: 1}, 10)
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	events, err = GetEventsToPrune(db, now.AddDate(0, 0, -3), map[EventLevel]int64{EvInfo: 2, EvError: 1}, 2)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+
+	count, err := DeleteEvents(db, []int64{ids[0], ids[1]})
+	require.NoError(t, err)
+	require.EqualValues(t, 2, count)
+	count, err = DeleteEvents(db, nil)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	_, total, err := GetEventsByPage(db, 0, 10, EvInfo, nil, nil, nil, nil, "", SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+}
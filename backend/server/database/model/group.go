@@ -1,6 +1,7 @@
 package dbmodel
 
 import (
+	"context"
 	"errors"
 
 	"github.com/go-pg/pg/v10"
@@ -20,8 +21,18 @@ type SystemGroup struct {
 	ID          int
 	Name        string
 	Description string
+	// Indicates if the members of the group are required to use the TOTP
+	// second factor.
+	TOTPRequired bool `pg:"totp_required,use_zero"`
 
-	Users []*SystemUser `pg:"many2many:system_user_to_group,fk:group_id,join_fk:user_id"`
+	Users       []*SystemUser            `pg:"many2many:system_user_to_group,fk:group_id,join_fk:user_id"`
+	Permissions []*SystemGroupPermission `pg:"rel:has-many,join_fk:group_id"`
+}
+
+// Checks if the group is one of the groups which can't be modified or
+// deleted, i.e. super-admin or admin.
+func (group *SystemGroup) IsPredefined() bool {
+	return group.ID == SuperAdminGroupID || group.ID == AdminGroupID
 }
 
 // Fetches a collection of groups from the database. The offset and
@@ -35,13 +46,15 @@ type SystemGroup struct {
 // and error.
 func GetGroupsByPage(db *dbops.PgDB, offset, limit int64, filterText *string, sortField string, sortDir SortDirEnum) ([]SystemGroup, int64, error) {
 	var groups []SystemGroup
-	q := db.Model(&groups)
+	q := db.Model(&groups).Relation("Permissions", func(q *orm.Query) (*orm.Query, error) {
+		return q.OrderExpr("id ASC"), nil
+	})
 
 	if filterText != nil {
 		text := "%" + *filterText + "%"
 		q = q.WhereGroup(func(qq *orm.Query) (*orm.Query, error) {
-			qq = qq.WhereOr("name ILIKE ?", text)
-			qq = qq.WhereOr("description ILIKE ?", text)
+			qq = qq.WhereOr("system_group.name ILIKE ?", text)
+			qq = qq.WhereOr("system_group.description ILIKE ?", text)
 			return qq, nil
 		})
 	}
@@ -62,3 +75,120 @@ func GetGroupsByPage(db *dbops.PgDB, offset, limit int64, filterText *string, so
 
 	return groups, int64(total), err
 }
+
+// Fetches the group with its permissions by ID. It returns nil if the
+// group doesn't exist.
+func GetGroupByID(dbi dbops.DBI, id int) (*SystemGroup, error) {
+	group := &SystemGroup{}
+	err := dbi.Model(group).
+		Relation("Permissions", func(q *orm.Query) (*orm.Query, error) {
+			return q.OrderExpr("id ASC"), nil
+		}).
+		Where("system_group.id = ?", id).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting group with ID %d", id)
+	}
+	return group, nil
+}
+
+// Validates the permissions of the group and inserts them into the
+// database.
+func addGroupPermissions(dbi dbops.DBI, group *SystemGroup) error {
+	if len(group.Permissions) == 0 {
+		return nil
+	}
+	for _, permission := range group.Permissions {
+		if err := permission.Validate(); err != nil {
+			return err
+		}
+		permission.ID = 0
+		permission.GroupID = group.ID
+	}
+	_, err := dbi.Model(&group.Permissions).Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem inserting permissions of group %s", group.Name)
+	}
+	return nil
+}
+
+// Checks if the error is caused by the integrity violation, e.g. the
+// group name is already in use.
+func isGroupConflict(err error) bool {
+	var pgError pg.Error
+	return errors.As(err, &pgError) && pgError.IntegrityViolation()
+}
+
+// Inserts a new group with its permissions in a transaction.
+func addGroup(tx *pg.Tx, group *SystemGroup) (conflict bool, err error) {
+	_, err = tx.Model(group).ExcludeColumn("id").Returning("id").Insert()
+	if err != nil {
+		return isGroupConflict(err), pkgerrors.Wrapf(err, "problem inserting group %s", group.Name)
+	}
+	return false, addGroupPermissions(tx, group)
+}
+
+// Adds a new group with its permissions to the database. The returned
+// conflict value indicates if the group name is already in use. It
+// begins a new transaction when dbi has a *pg.DB type or uses an
+// existing transaction when dbi has a *pg.Tx type.
+func AddGroup(dbi dbops.DBI, group *SystemGroup) (conflict bool, err error) {
+	if db, ok := dbi.(*pg.DB); ok {
+		err = db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			conflict, err = addGroup(tx, group)
+			return err
+		})
+		return conflict, err
+	}
+	return addGroup(dbi.(*pg.Tx), group)
+}
+
+// Updates the group and replaces its permissions in a transaction.
+func updateGroup(tx *pg.Tx, group *SystemGroup) (conflict bool, err error) {
+	result, err := tx.Model(group).Column("name", "description").WherePK().Update()
+	if err != nil {
+		return isGroupConflict(err), pkgerrors.Wrapf(err, "problem updating group with ID %d", group.ID)
+	} else if result.RowsAffected() <= 0 {
+		return false, pkgerrors.Wrapf(ErrNotExists, "group with ID %d does not exist", group.ID)
+	}
+	_, err = tx.Model((*SystemGroupPermission)(nil)).Where("group_id = ?", group.ID).Delete()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem deleting permissions of group with ID %d", group.ID)
+	}
+	return false, addGroupPermissions(tx, group)
+}
+
+// Updates the name, description and permissions of the group. The
+// existing permissions are replaced with the permissions specified in
+// the group. The predefined groups can't be updated. The returned
+// conflict value indicates if the new group name is already in use.
+func UpdateGroup(dbi dbops.DBI, group *SystemGroup) (conflict bool, err error) {
+	if group.IsPredefined() {
+		return false, pkgerrors.Errorf("predefined group with ID %d cannot be modified", group.ID)
+	}
+	if db, ok := dbi.(*pg.DB); ok {
+		err = db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			conflict, err = updateGroup(tx, group)
+			return err
+		})
+		return conflict, err
+	}
+	return updateGroup(dbi.(*pg.Tx), group)
+}
+
+// Deletes the group with its permissions. The users belonging to the
+// group are removed from it. The predefined groups can't be deleted.
+func DeleteGroup(dbi dbops.DBI, id int) error {
+	if (&SystemGroup{ID: id}).IsPredefined() {
+		return pkgerrors.Errorf("predefined group with ID %d cannot be deleted", id)
+	}
+	result, err := dbi.Model((*SystemGroup)(nil)).Where("id = ?", id).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting group with ID %d", id)
+	} else if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "group with ID %d does not exist", id)
+	}
+	return nil
+}
@@ -14,31 +14,39 @@ func TestGetGroups(t *testing.T) {
 
 	groups, total, err := GetGroupsByPage(db, 0, 10, nil, "", SortDirAny)
 	require.NoError(t, err)
-	require.EqualValues(t, 2, total)
-	// There are two predefined groups.
-	require.Len(t, groups, 2)
+	require.EqualValues(t, 4, total)
+	// There are four predefined groups.
+	require.Len(t, groups, 4)
 
 	// Groups are supposed to be ordered by id.
 	require.Equal(t, 1, groups[0].ID)
 	require.Equal(t, "super-admin", groups[0].Name)
+	require.Empty(t, groups[0].Permissions)
 	require.Equal(t, 2, groups[1].ID)
 	require.Equal(t, "admin", groups[1].Name)
+	require.Len(t, groups[1].Permissions, 10)
+	require.Equal(t, "read-only", groups[2].Name)
+	require.Len(t, groups[2].Permissions, 10)
+	require.Equal(t, "dhcp-operator", groups[3].Name)
+	require.Len(t, groups[3].Permissions, 11)
 
 	// check sorting field and order ascending
 	groups, total, err = GetGroupsByPage(db, 0, 10, nil, "name", SortDirAsc)
 	require.NoError(t, err)
-	require.EqualValues(t, 2, total)
-	require.Len(t, groups, 2)
+	require.EqualValues(t, 4, total)
+	require.Len(t, groups, 4)
 	require.Equal(t, "admin", groups[0].Name)
-	require.Equal(t, "super-admin", groups[1].Name)
+	require.Equal(t, "dhcp-operator", groups[1].Name)
+	require.Equal(t, "read-only", groups[2].Name)
+	require.Equal(t, "super-admin", groups[3].Name)
 
 	// check sorting field and order descending
 	groups, total, err = GetGroupsByPage(db, 0, 10, nil, "name", SortDirDesc)
 	require.NoError(t, err)
-	require.EqualValues(t, 2, total)
-	require.Len(t, groups, 2)
+	require.EqualValues(t, 4, total)
+	require.Len(t, groups, 4)
 	require.Equal(t, "super-admin", groups[0].Name)
-	require.Equal(t, "admin", groups[1].Name)
+	require.Equal(t, "admin", groups[3].Name)
 
 	// check filtering by text
 	text := "super"
@@ -48,3 +56,116 @@ func TestGetGroups(t *testing.T) {
 	require.Len(t, groups, 1)
 	require.Equal(t, "super-admin", groups[0].Name)
 }
+
+// Test that the group with permissions can be added, updated and deleted.
+func TestAddUpdateDeleteGroup(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	group := &SystemGroup{
+		Name:        "subnet-viewer",
+		Description: "Views subnets on one machine.",
+		Permissions: []*SystemGroupPermission{
+			{
+				Resource:  PermissionResourceSubnets,
+				Action:    PermissionActionRead,
+				ScopeType: PermissionScopeMachine,
+				ScopeID:   1,
+			},
+			{
+				Resource: PermissionResourceDashboard,
+				Action:   PermissionActionRead,
+			},
+		},
+	}
+	conflict, err := AddGroup(db, group)
+	require.NoError(t, err)
+	require.False(t, conflict)
+	require.NotZero(t, group.ID)
+
+	returned, err := GetGroupByID(db, group.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+	require.Equal(t, "subnet-viewer", returned.Name)
+	require.Len(t, returned.Permissions, 2)
+	require.Equal(t, PermissionResourceSubnets, returned.Permissions[0].Resource)
+	require.Equal(t, PermissionScopeMachine, returned.Permissions[0].ScopeType)
+	require.EqualValues(t, 1, returned.Permissions[0].ScopeID)
+	require.Equal(t, PermissionScopeNone, returned.Permissions[1].ScopeType)
+	require.Zero(t, returned.Permissions[1].ScopeID)
+
+	// The group name must be unique.
+	conflict, err = AddGroup(db, &SystemGroup{Name: "subnet-viewer"})
+	require.Error(t, err)
+	require.True(t, conflict)
+
+	// Invalid permissions are rejected.
+	_, err = AddGroup(db, &SystemGroup{
+		Name: "invalid",
+		Permissions: []*SystemGroupPermission{
+			{Resource: "leases", Action: PermissionActionRead},
+		},
+	})
+	require.Error(t, err)
+	returned, err = GetGroupByID(db, group.ID+1)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+
+	// Replace the permissions.
+	group.Description = "Manages hosts."
+	group.Permissions = []*SystemGroupPermission{
+		{
+			Resource: PermissionResourceHosts,
+			Action:   PermissionActionWrite,
+		},
+	}
+	conflict, err = UpdateGroup(db, group)
+	require.NoError(t, err)
+	require.False(t, conflict)
+
+	returned, err = GetGroupByID(db, group.ID)
+	require.NoError(t, err)
+	require.Equal(t, "Manages hosts.", returned.Description)
+	require.Len(t, returned.Permissions, 1)
+	require.Equal(t, PermissionResourceHosts, returned.Permissions[0].Resource)
+
+	// The name can't be changed to the name of another group.
+	group.Name = "read-only"
+	conflict, err = UpdateGroup(db, group)
+	require.Error(t, err)
+	require.True(t, conflict)
+
+	// Non-existing group.
+	_, err = UpdateGroup(db, &SystemGroup{ID: group.ID + 100, Name: "foo"})
+	require.ErrorIs(t, err, ErrNotExists)
+
+	// Predefined groups can't be modified nor deleted.
+	_, err = UpdateGroup(db, &SystemGroup{ID: AdminGroupID, Name: "admin"})
+	require.Error(t, err)
+	require.Error(t, DeleteGroup(db, SuperAdminGroupID))
+
+	// Add a user to the group and delete the group.
+	user := &SystemUser{
+		Login:    "viewer",
+		Name:     "John",
+		Lastname: "Smith",
+		Groups:   []*SystemGroup{{ID: group.ID}},
+	}
+	_, err = CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	require.NoError(t, DeleteGroup(db, group.ID))
+	returned, err = GetGroupByID(db, group.ID)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+	require.ErrorIs(t, DeleteGroup(db, group.ID), ErrNotExists)
+
+	user, err = GetUserByID(db, user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	require.Empty(t, user.Groups)
+
+	permissions, err := GetPermissionsByGroupIDs(db, []int{group.ID})
+	require.NoError(t, err)
+	require.Empty(t, permissions)
+}
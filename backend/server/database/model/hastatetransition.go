@@ -0,0 +1,116 @@
+package dbmodel
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// A structure reflecting the HA state of a server observed by the Stork
+// server. A new transition is recorded when the server reports a
+// different state than before, or its view of the partner changes, i.e.
+// the communication with the partner is interrupted or restored, or the
+// number of unacked clients changes. The previous state is empty for the
+// first transition recorded for the server in the service.
+type HAStateTransition struct {
+	ID                     int64
+	ServiceID              int64
+	DaemonID               int64
+	Daemon                 *Daemon `pg:"rel:has-one"`
+	ObservedAt             time.Time
+	PreviousState          HAState
+	State                  HAState
+	PartnerCommInterrupted *bool
+	PartnerUnackedClients  int64 `pg:",use_zero"`
+}
+
+// Checks if the transition carries the same information as the other
+// transition, i.e. the state and the partner's status are equal.
+func (t *HAStateTransition) hasSameStatus(other *HAStateTransition) bool {
+	if t.State != other.State || t.PartnerUnackedClients != other.PartnerUnackedClients {
+		return false
+	}
+	if t.PartnerCommInterrupted == nil || other.PartnerCommInterrupted == nil {
+		return t.PartnerCommInterrupted == nil && other.PartnerCommInterrupted == nil
+	}
+	return *t.PartnerCommInterrupted == *other.PartnerCommInterrupted
+}
+
+// Checks if the transition indicates a failover, i.e. the server took
+// over the DHCP service of its partner.
+func (t *HAStateTransition) IsFailover() bool {
+	return t.State == HAStatePartnerDown && t.PreviousState != HAStatePartnerDown
+}
+
+// Returns the last transition recorded for the daemon in the service or
+// nil if there are no transitions.
+func GetLastHAStateTransition(dbi dbops.DBI, serviceID, daemonID int64) (*HAStateTransition, error) {
+	transition := HAStateTransition{}
+	err := dbi.Model(&transition).
+		Where("service_id = ?", serviceID).
+		Where("daemon_id = ?", daemonID).
+		OrderExpr("id DESC").
+		Limit(1).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting last HA state transition of daemon %d in service %d", daemonID, serviceID)
+	}
+	return &transition, nil
+}
+
+// Inserts the transition into the database if it differs from the last
+// transition recorded for the daemon in the service. The previous state
+// of the transition is set to the state of the last transition. It
+// returns a boolean value indicating whether the transition was inserted.
+func AddHAStateTransitionIfChanged(dbi dbops.DBI, transition *HAStateTransition) (bool, error) {
+	last, err := GetLastHAStateTransition(dbi, transition.ServiceID, transition.DaemonID)
+	if err != nil {
+		return false, err
+	}
+	if last != nil {
+		if last.hasSameStatus(transition) {
+			return false, nil
+		}
+		transition.PreviousState = last.State
+	}
+	if transition.ObservedAt.IsZero() {
+		transition.ObservedAt = time.Now().UTC()
+	}
+	_, err = dbi.Model(transition).Insert()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem inserting HA state transition of daemon %d in service %d",
+			transition.DaemonID, transition.ServiceID)
+	}
+	return true, nil
+}
+
+// Fetches the HA state transitions recorded for the service, from the
+// newest to the oldest. The offset and limit specify the beginning of the
+// page and the maximum size of the page. Limit has to be greater than 0,
+// otherwise error is returned. The transitions include the daemons with
+// their apps and machines.
+func GetHAStateTransitionsByPage(dbi dbops.DBI, serviceID, offset, limit int64) ([]HAStateTransition, int64, error) {
+	if limit == 0 {
+		return nil, 0, pkgerrors.New("limit should be greater than 0")
+	}
+	transitions := []HAStateTransition{}
+	total, err := dbi.Model(&transitions).
+		Relation("Daemon.App.Machine").
+		Where("ha_state_transition.service_id = ?", serviceID).
+		OrderExpr("ha_state_transition.id DESC").
+		Offset(int(offset)).
+		Limit(int(limit)).
+		SelectAndCount()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return []HAStateTransition{}, 0, nil
+		}
+		return nil, 0, pkgerrors.Wrapf(err, "problem getting HA state transitions of service %d", serviceID)
+	}
+	return transitions, int64(total), nil
+}
@@ -0,0 +1,165 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+	storkutil "isc.org/stork/util"
+)
+
+// Test that the transition is recorded only when the status changes.
+func TestAddHAStateTransitionIfChanged(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	services := addTestServices(t, db)
+	service := services[1]
+	daemonID := service.HAService.PrimaryID
+
+	// No transitions yet.
+	last, err := GetLastHAStateTransition(db, service.ID, daemonID)
+	require.NoError(t, err)
+	require.Nil(t, last)
+
+	// The first transition has no previous state.
+	observedAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	added, err := AddHAStateTransitionIfChanged(db, &HAStateTransition{
+		ServiceID:              service.ID,
+		DaemonID:               daemonID,
+		ObservedAt:             observedAt,
+		State:                  HAStateLoadBalancing,
+		PartnerCommInterrupted: storkutil.Ptr(false),
+	})
+	require.NoError(t, err)
+	require.True(t, added)
+
+	// The same status is not recorded again.
+	added, err = AddHAStateTransitionIfChanged(db, &HAStateTransition{
+		ServiceID:              service.ID,
+		DaemonID:               daemonID,
+		State:                  HAStateLoadBalancing,
+		PartnerCommInterrupted: storkutil.Ptr(false),
+	})
+	require.NoError(t, err)
+	require.False(t, added)
+
+	// The communication with the partner is interrupted.
+	added, err = AddHAStateTransitionIfChanged(db, &HAStateTransition{
+		ServiceID:              service.ID,
+		DaemonID:               daemonID,
+		ObservedAt:             observedAt.Add(time.Minute),
+		State:                  HAStateLoadBalancing,
+		PartnerCommInterrupted: storkutil.Ptr(true),
+		PartnerUnackedClients:  3,
+	})
+	require.NoError(t, err)
+	require.True(t, added)
+
+	// The server takes over the partner's service.
+	transition := &HAStateTransition{
+		ServiceID:              service.ID,
+		DaemonID:               daemonID,
+		ObservedAt:             observedAt.Add(2 * time.Minute),
+		State:                  HAStatePartnerDown,
+		PartnerCommInterrupted: storkutil.Ptr(true),
+		PartnerUnackedClients:  10,
+	}
+	added, err = AddHAStateTransitionIfChanged(db, transition)
+	require.NoError(t, err)
+	require.True(t, added)
+	require.Equal(t, HAStateLoadBalancing, transition.PreviousState)
+	require.True(t, transition.IsFailover())
+
+	last, err = GetLastHAStateTransition(db, service.ID, daemonID)
+	require.NoError(t, err)
+	require.NotNil(t, last)
+	require.Equal(t, HAStatePartnerDown, last.State)
+	require.Equal(t, HAStateLoadBalancing, last.PreviousState)
+	require.True(t, *last.PartnerCommInterrupted)
+	require.EqualValues(t, 10, last.PartnerUnackedClients)
+	require.Equal(t, observedAt.Add(2*time.Minute), last.ObservedAt)
+
+	// The transitions of the other daemon are recorded independently.
+	added, err = AddHAStateTransitionIfChanged(db, &HAStateTransition{
+		ServiceID: service.ID,
+		DaemonID:  service.HAService.SecondaryID,
+		State:     HAStateUnavailable,
+	})
+	require.NoError(t, err)
+	require.True(t, added)
+}
+
+// Test that the transitions of the service are returned from the newest.
+func TestGetHAStateTransitionsByPage(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	services := addTestServices(t, db)
+	service := services[1]
+
+	states := []HAState{HAStateWaiting, HAStateSyncing, HAStateReady, HAStateLoadBalancing}
+	for _, state := range states {
+		for _, daemonID := range []int64{service.HAService.PrimaryID, service.HAService.SecondaryID} {
+			added, err := AddHAStateTransitionIfChanged(db, &HAStateTransition{
+				ServiceID: service.ID,
+				DaemonID:  daemonID,
+				State:     state,
+			})
+			require.NoError(t, err)
+			require.True(t, added)
+		}
+	}
+
+	transitions, total, err := GetHAStateTransitionsByPage(db, service.ID, 0, 3)
+	require.NoError(t, err)
+	require.EqualValues(t, 8, total)
+	require.Len(t, transitions, 3)
+	require.Equal(t, HAStateLoadBalancing, transitions[0].State)
+	require.Equal(t, HAStateReady, transitions[0].PreviousState)
+	require.Equal(t, service.HAService.SecondaryID, transitions[0].DaemonID)
+	require.NotNil(t, transitions[0].Daemon)
+	require.NotNil(t, transitions[0].Daemon.App)
+	require.NotNil(t, transitions[0].Daemon.App.Machine)
+	require.Equal(t, service.HAService.PrimaryID, transitions[1].DaemonID)
+
+	transitions, total, err = GetHAStateTransitionsByPage(db, service.ID, 6, 3)
+	require.NoError(t, err)
+	require.EqualValues(t, 8, total)
+	require.Len(t, transitions, 2)
+	require.Equal(t, HAStateWaiting, transitions[1].State)
+	require.Empty(t, transitions[1].PreviousState)
+
+	// Other service has no transitions.
+	transitions, total, err = GetHAStateTransitionsByPage(db, services[3].ID, 0, 10)
+	require.NoError(t, err)
+	require.Zero(t, total)
+	require.Empty(t, transitions)
+
+	// Zero limit is not allowed.
+	_, _, err = GetHAStateTransitionsByPage(db, service.ID, 0, 0)
+	require.Error(t, err)
+}
+
+// Test that the transitions are removed together with the service.
+func TestDeleteServiceWithHAStateTransitions(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	services := addTestServices(t, db)
+	service := services[1]
+	_, err := AddHAStateTransitionIfChanged(db, &HAStateTransition{
+		ServiceID: service.ID,
+		DaemonID:  service.HAService.PrimaryID,
+		State:     HAStateLoadBalancing,
+	})
+	require.NoError(t, err)
+
+	err = DeleteService(db, service.ID)
+	require.NoError(t, err)
+
+	last, err := GetLastHAStateTransition(db, service.ID, service.HAService.PrimaryID)
+	require.NoError(t, err)
+	require.Nil(t, last)
+}
@@ -0,0 +1,185 @@
+package dbmodel
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+func init() {
+	// Register many to many relation between machine groups and machines.
+	orm.RegisterTable((*MachineGroupToMachine)(nil))
+}
+
+// Represents a named group of machines. The permissions having the
+// machine-group scope grant access to the resources belonging to the
+// machines in the group and the apps running on them.
+type MachineGroup struct {
+	ID          int64
+	Name        string
+	Description string
+
+	Machines []*Machine `pg:"many2many:machine_group_to_machine,fk:machine_group_id,join_fk:machine_id"`
+}
+
+// A structure reflecting the machine_group_to_machine SQL table which
+// associates the machines with the groups.
+type MachineGroupToMachine struct {
+	MachineGroupID int64 `pg:",pk,notnull,on_delete:CASCADE"`
+	MachineID      int64 `pg:",pk,notnull,on_delete:CASCADE"`
+}
+
+// Fetches all machine groups with their machines ordered by ID.
+func GetMachineGroups(dbi dbops.DBI) ([]MachineGroup, error) {
+	groups := []MachineGroup{}
+	err := dbi.Model(&groups).
+		Relation("Machines", func(q *orm.Query) (*orm.Query, error) {
+			return q.OrderExpr("machine.id ASC"), nil
+		}).
+		OrderExpr("machine_group.id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting machine groups")
+	}
+	return groups, nil
+}
+
+// Fetches the machine group with its machines by ID. It returns nil if
+// the group doesn't exist.
+func GetMachineGroupByID(dbi dbops.DBI, id int64) (*MachineGroup, error) {
+	group := &MachineGroup{}
+	err := dbi.Model(group).
+		Relation("Machines", func(q *orm.Query) (*orm.Query, error) {
+			return q.OrderExpr("machine.id ASC"), nil
+		}).
+		Where("machine_group.id = ?", id).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting machine group with ID %d", id)
+	}
+	return group, nil
+}
+
+// Returns the IDs of the machine groups the machine belongs to.
+func GetMachineGroupIDsByMachineID(dbi dbops.DBI, machineID int64) ([]int64, error) {
+	ids := []int64{}
+	err := dbi.Model((*MachineGroupToMachine)(nil)).
+		Column("machine_group_id").
+		Where("machine_id = ?", machineID).
+		Order("machine_group_id ASC").
+		Select(&ids)
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting machine groups of the machine with ID %d", machineID)
+	}
+	return ids, nil
+}
+
+// Associates the machines specified in the group with the group.
+func addMachineGroupMachines(dbi dbops.DBI, group *MachineGroup) error {
+	if len(group.Machines) == 0 {
+		return nil
+	}
+	var associations []MachineGroupToMachine
+	for _, machine := range group.Machines {
+		associations = append(associations, MachineGroupToMachine{
+			MachineGroupID: group.ID,
+			MachineID:      machine.ID,
+		})
+	}
+	_, err := dbi.Model(&associations).OnConflict("DO NOTHING").Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem associating machines with machine group %s", group.Name)
+	}
+	return nil
+}
+
+// Inserts a new machine group with its machines in a transaction.
+func addMachineGroup(tx *pg.Tx, group *MachineGroup) (conflict bool, err error) {
+	_, err = tx.Model(group).ExcludeColumn("id").Returning("id").Insert()
+	if err != nil {
+		return isGroupConflict(err), pkgerrors.Wrapf(err, "problem inserting machine group %s", group.Name)
+	}
+	return false, addMachineGroupMachines(tx, group)
+}
+
+// Adds a new machine group with its machines to the database. The
+// returned conflict value indicates if the group name is already in use
+// or any of the machines doesn't exist. It begins a new transaction when
+// dbi has a *pg.DB type or uses an existing transaction when dbi has a
+// *pg.Tx type.
+func AddMachineGroup(dbi dbops.DBI, group *MachineGroup) (conflict bool, err error) {
+	if db, ok := dbi.(*pg.DB); ok {
+		err = db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			conflict, err = addMachineGroup(tx, group)
+			return err
+		})
+		return conflict, err
+	}
+	return addMachineGroup(dbi.(*pg.Tx), group)
+}
+
+// Updates the machine group and replaces its machines in a transaction.
+func updateMachineGroup(tx *pg.Tx, group *MachineGroup) (conflict bool, err error) {
+	result, err := tx.Model(group).Column("name", "description").WherePK().Update()
+	if err != nil {
+		return isGroupConflict(err), pkgerrors.Wrapf(err, "problem updating machine group with ID %d", group.ID)
+	} else if result.RowsAffected() <= 0 {
+		return false, pkgerrors.Wrapf(ErrNotExists, "machine group with ID %d does not exist", group.ID)
+	}
+	_, err = tx.Model((*MachineGroupToMachine)(nil)).Where("machine_group_id = ?", group.ID).Delete()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem deleting machines of machine group with ID %d", group.ID)
+	}
+	err = addMachineGroupMachines(tx, group)
+	return isGroupConflict(err), err
+}
+
+// Updates the name, description and machines of the machine group. The
+// existing machines are replaced with the machines specified in the
+// group. The returned conflict value indicates if the new group name is
+// already in use or any of the machines doesn't exist.
+func UpdateMachineGroup(dbi dbops.DBI, group *MachineGroup) (conflict bool, err error) {
+	if db, ok := dbi.(*pg.DB); ok {
+		err = db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			conflict, err = updateMachineGroup(tx, group)
+			return err
+		})
+		return conflict, err
+	}
+	return updateMachineGroup(dbi.(*pg.Tx), group)
+}
+
+// Deletes the machine group and the permissions limited to it.
+func deleteMachineGroup(dbi dbops.DBI, id int64) error {
+	_, err := dbi.Model((*SystemGroupPermission)(nil)).
+		Where("scope_type = ?", PermissionScopeMachineGroup).
+		Where("scope_id = ?", id).
+		Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting permissions limited to machine group with ID %d", id)
+	}
+	result, err := dbi.Model((*MachineGroup)(nil)).Where("id = ?", id).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting machine group with ID %d", id)
+	} else if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "machine group with ID %d does not exist", id)
+	}
+	return nil
+}
+
+// Deletes the machine group. The permissions limited to the group are
+// deleted too because they would not grant access to any resources.
+func DeleteMachineGroup(dbi dbops.DBI, id int64) error {
+	if db, ok := dbi.(*pg.DB); ok {
+		return db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			return deleteMachineGroup(tx, id)
+		})
+	}
+	return deleteMachineGroup(dbi, id)
+}
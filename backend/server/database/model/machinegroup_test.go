@@ -0,0 +1,107 @@
+package dbmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the machine groups can be added, fetched, updated and deleted.
+func TestAddUpdateDeleteMachineGroup(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	var machines []*Machine
+	for i := 0; i < 3; i++ {
+		machine := &Machine{Address: "localhost", AgentPort: int64(8080 + i)}
+		require.NoError(t, AddMachine(db, machine))
+		machines = append(machines, machine)
+	}
+
+	group := &MachineGroup{
+		Name:        "dc1",
+		Description: "First data center",
+		Machines:    machines[:2],
+	}
+	conflict, err := AddMachineGroup(db, group)
+	require.NoError(t, err)
+	require.False(t, conflict)
+	require.NotZero(t, group.ID)
+
+	// The name must be unique.
+	conflict, err = AddMachineGroup(db, &MachineGroup{Name: "dc1"})
+	require.Error(t, err)
+	require.True(t, conflict)
+
+	returned, err := GetMachineGroupByID(db, group.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+	require.Equal(t, "dc1", returned.Name)
+	require.Equal(t, "First data center", returned.Description)
+	require.Len(t, returned.Machines, 2)
+	require.Equal(t, machines[0].ID, returned.Machines[0].ID)
+	require.Equal(t, machines[1].ID, returned.Machines[1].ID)
+
+	ids, err := GetMachineGroupIDsByMachineID(db, machines[1].ID)
+	require.NoError(t, err)
+	require.Equal(t, []int64{group.ID}, ids)
+	ids, err = GetMachineGroupIDsByMachineID(db, machines[2].ID)
+	require.NoError(t, err)
+	require.Empty(t, ids)
+
+	// Replace the machines.
+	group.Name = "dc2"
+	group.Machines = machines[2:]
+	conflict, err = UpdateMachineGroup(db, group)
+	require.NoError(t, err)
+	require.False(t, conflict)
+
+	groups, err := GetMachineGroups(db)
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	require.Equal(t, "dc2", groups[0].Name)
+	require.Len(t, groups[0].Machines, 1)
+	require.Equal(t, machines[2].ID, groups[0].Machines[0].ID)
+
+	// Non-existing machine.
+	group.Machines = []*Machine{{ID: machines[2].ID + 100}}
+	conflict, err = UpdateMachineGroup(db, group)
+	require.Error(t, err)
+	require.True(t, conflict)
+
+	// Non-existing group.
+	_, err = UpdateMachineGroup(db, &MachineGroup{ID: group.ID + 100, Name: "foo"})
+	require.ErrorIs(t, err, ErrNotExists)
+
+	// The permissions limited to the group are deleted with the group.
+	_, err = AddGroup(db, &SystemGroup{
+		Name: "dc2-hosts",
+		Permissions: []*SystemGroupPermission{
+			{
+				Resource:  PermissionResourceHosts,
+				Action:    PermissionActionWrite,
+				ScopeType: PermissionScopeMachineGroup,
+				ScopeID:   group.ID,
+			},
+			{Resource: PermissionResourceSubnets, Action: PermissionActionRead},
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, DeleteMachineGroup(db, group.ID))
+	require.ErrorIs(t, DeleteMachineGroup(db, group.ID), ErrNotExists)
+
+	returned, err = GetMachineGroupByID(db, group.ID)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+
+	groups2, _, err := GetGroupsByPage(db, 0, 10, nil, "", SortDirAny)
+	require.NoError(t, err)
+	for _, g := range groups2 {
+		if g.Name == "dc2-hosts" {
+			require.Len(t, g.Permissions, 1)
+			require.Equal(t, PermissionResourceSubnets, g.Permissions[0].Resource)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package dbmodel
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Specifies how often the maintenance window repeats.
+type MaintenanceRecurrence string
+
+// Supported maintenance window recurrences.
+const (
+	MaintenanceRecurrenceNone   MaintenanceRecurrence = "none"
+	MaintenanceRecurrenceDaily  MaintenanceRecurrence = "daily"
+	MaintenanceRecurrenceWeekly MaintenanceRecurrence = "weekly"
+)
+
+// Represents a planned maintenance of a machine, an app or a service.
+// Exactly one of the machine, app and service IDs is set. The window
+// starts and ends at the specified times. The recurring window repeats
+// every day or every week, and the start and end times specify its first
+// occurrence. The events related to the objects in the maintenance are
+// tagged with the window ID and are not sent to the external recipients.
+// If the downgrade flag is set, their level is lowered to info. The
+// active flag is the window state seen by the event center when it last
+// checked the windows.
+type MaintenanceWindow struct {
+	ID          int64
+	Name        string
+	Description string
+	MachineID   int64
+	Machine     *Machine `pg:"rel:has-one"`
+	AppID       int64
+	App         *App `pg:"rel:has-one"`
+	ServiceID   int64
+	Service     *BaseService `pg:"rel:has-one"`
+	StartsAt    time.Time
+	EndsAt      time.Time
+	Recurrence  MaintenanceRecurrence
+	Downgrade   bool `pg:",use_zero"`
+	Active      bool `pg:",use_zero"`
+	CreatedAt   time.Time
+}
+
+// Returns the interval between the occurrences of the recurring window.
+// It returns zero if the window doesn't repeat.
+func (w *MaintenanceWindow) GetPeriod() time.Duration {
+	switch w.Recurrence {
+	case MaintenanceRecurrenceDaily:
+		return 24 * time.Hour
+	case MaintenanceRecurrenceWeekly:
+		return 7 * 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// Checks if the window is active at the specified time.
+func (w *MaintenanceWindow) IsActive(now time.Time) bool {
+	if now.Before(w.StartsAt) {
+		return false
+	}
+	period := w.GetPeriod()
+	if period == 0 {
+		return now.Before(w.EndsAt)
+	}
+	return now.Sub(w.StartsAt)%period < w.EndsAt.Sub(w.StartsAt)
+}
+
+// Inserts a new maintenance window into the database.
+func AddMaintenanceWindow(dbi dbops.DBI, window *MaintenanceWindow) error {
+	if window.CreatedAt.IsZero() {
+		window.CreatedAt = time.Now().UTC()
+	}
+	_, err := dbi.Model(window).ExcludeColumn("active").Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem inserting maintenance window %s", window.Name)
+	}
+	return nil
+}
+
+// Updates the maintenance window in the database. The active flag is not
+// updated. It returns ErrNotExists if the window doesn't exist.
+func UpdateMaintenanceWindow(dbi dbops.DBI, window *MaintenanceWindow) error {
+	result, err := dbi.Model(window).
+		ExcludeColumn("created_at", "active").
+		WherePK().
+		Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem updating maintenance window %d", window.ID)
+	} else if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "maintenance window %d does not exist", window.ID)
+	}
+	return nil
+}
+
+// Sets the window state seen by the event center.
+func SetMaintenanceWindowActive(dbi dbops.DBI, id int64, active bool) error {
+	_, err := dbi.Model((*MaintenanceWindow)(nil)).
+		Set("active = ?", active).
+		Where("id = ?", id).
+		Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem updating state of maintenance window %d", id)
+	}
+	return nil
+}
+
+// Returns all maintenance windows ordered by the start time. The machines,
+// apps and services in the maintenance are returned with the windows. The
+// services include their daemons.
+func GetMaintenanceWindows(dbi dbops.DBI) ([]MaintenanceWindow, error) {
+	windows := []MaintenanceWindow{}
+	err := dbi.Model(&windows).
+		Relation("Machine").
+		Relation("App").
+		Relation("Service.Daemons").
+		OrderExpr("maintenance_window.starts_at ASC, maintenance_window.id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting maintenance windows")
+	}
+	return windows, nil
+}
+
+// Returns the maintenance window with the specified ID. It returns nil if
+// the window doesn't exist.
+func GetMaintenanceWindowByID(dbi dbops.DBI, id int64) (*MaintenanceWindow, error) {
+	window := &MaintenanceWindow{}
+	err := dbi.Model(window).
+		Relation("Machine").
+		Relation("App").
+		Relation("Service.Daemons").
+		Where("maintenance_window.id = ?", id).
+		Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting maintenance window %d", id)
+	}
+	return window, nil
+}
+
+// Deletes the maintenance window. The events raised in the window are
+// kept. It returns ErrNotExists if the window doesn't exist.
+func DeleteMaintenanceWindow(dbi dbops.DBI, id int64) error {
+	result, err := dbi.Model((*MaintenanceWindow)(nil)).Where("id = ?", id).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting maintenance window %d", id)
+	} else if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "maintenance window %d does not exist", id)
+	}
+	return nil
+}
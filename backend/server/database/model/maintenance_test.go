@@ -0,0 +1,151 @@
+package dbmodel
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test checking if the one-time maintenance window is active.
+func TestMaintenanceWindowIsActive(t *testing.T) {
+	startsAt := time.Date(2023, 3, 1, 22, 0, 0, 0, time.UTC)
+	window := &MaintenanceWindow{
+		StartsAt:   startsAt,
+		EndsAt:     startsAt.Add(2 * time.Hour),
+		Recurrence: MaintenanceRecurrenceNone,
+	}
+	require.Zero(t, window.GetPeriod())
+	require.False(t, window.IsActive(startsAt.Add(-time.Second)))
+	require.True(t, window.IsActive(startsAt))
+	require.True(t, window.IsActive(startsAt.Add(time.Hour)))
+	require.False(t, window.IsActive(startsAt.Add(2*time.Hour)))
+	require.False(t, window.IsActive(startsAt.Add(24*time.Hour)))
+}
+
+// Test checking if the recurring maintenance windows are active.
+func TestRecurringMaintenanceWindowIsActive(t *testing.T) {
+	startsAt := time.Date(2023, 3, 1, 22, 0, 0, 0, time.UTC)
+	window := &MaintenanceWindow{
+		StartsAt:   startsAt,
+		EndsAt:     startsAt.Add(2 * time.Hour),
+		Recurrence: MaintenanceRecurrenceDaily,
+	}
+	require.Equal(t, 24*time.Hour, window.GetPeriod())
+	require.False(t, window.IsActive(startsAt.Add(-time.Second)))
+	require.True(t, window.IsActive(startsAt))
+	require.False(t, window.IsActive(startsAt.Add(2*time.Hour)))
+	require.True(t, window.IsActive(startsAt.Add(25*time.Hour)))
+	require.False(t, window.IsActive(startsAt.Add(26*time.Hour)))
+	require.True(t, window.IsActive(startsAt.Add(30*24*time.Hour+time.Hour)))
+
+	window.Recurrence = MaintenanceRecurrenceWeekly
+	require.Equal(t, 7*24*time.Hour, window.GetPeriod())
+	require.False(t, window.IsActive(startsAt.Add(25*time.Hour)))
+	require.True(t, window.IsActive(startsAt.Add(7*24*time.Hour+time.Hour)))
+	require.False(t, window.IsActive(startsAt.Add(7*24*time.Hour+2*time.Hour)))
+}
+
+// Test that the maintenance windows can be added, updated, fetched and
+// deleted.
+func TestAddGetUpdateDeleteMaintenanceWindow(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	machine := &Machine{
+		Address:   "192.0.2.1",
+		AgentPort: 8080,
+	}
+	require.NoError(t, AddMachine(db, machine))
+	app := &App{
+		MachineID: machine.ID,
+		Type:      AppTypeKea,
+		Daemons: []*Daemon{{
+			Name: "dhcp4",
+		}},
+	}
+	_, err := AddApp(db, app)
+	require.NoError(t, err)
+	service := &Service{
+		BaseService: BaseService{
+			Name:    "ha",
+			Daemons: app.Daemons,
+		},
+	}
+	require.NoError(t, AddService(db, service))
+
+	startsAt := time.Date(2023, 3, 1, 22, 0, 0, 0, time.UTC)
+	machineWindow := &MaintenanceWindow{
+		Name:        "machine upgrade",
+		Description: "OS upgrade",
+		MachineID:   machine.ID,
+		StartsAt:    startsAt,
+		EndsAt:      startsAt.Add(time.Hour),
+		Recurrence:  MaintenanceRecurrenceNone,
+		Downgrade:   true,
+	}
+	require.NoError(t, AddMaintenanceWindow(db, machineWindow))
+	require.NotZero(t, machineWindow.ID)
+	require.NotZero(t, machineWindow.CreatedAt)
+
+	serviceWindow := &MaintenanceWindow{
+		Name:       "service backup",
+		ServiceID:  service.ID,
+		StartsAt:   startsAt.Add(-time.Hour),
+		EndsAt:     startsAt,
+		Recurrence: MaintenanceRecurrenceDaily,
+	}
+	require.NoError(t, AddMaintenanceWindow(db, serviceWindow))
+
+	// The windows are ordered by the start time.
+	windows, err := GetMaintenanceWindows(db)
+	require.NoError(t, err)
+	require.Len(t, windows, 2)
+	require.Equal(t, "service backup", windows[0].Name)
+	require.NotNil(t, windows[0].Service)
+	require.Len(t, windows[0].Service.Daemons, 1)
+	require.Equal(t, app.Daemons[0].ID, windows[0].Service.Daemons[0].ID)
+	require.Nil(t, windows[0].Machine)
+	require.Equal(t, "machine upgrade", windows[1].Name)
+	require.NotNil(t, windows[1].Machine)
+	require.Equal(t, "192.0.2.1", windows[1].Machine.Address)
+	require.True(t, windows[1].Downgrade)
+	require.False(t, windows[1].Active)
+
+	require.NoError(t, SetMaintenanceWindowActive(db, machineWindow.ID, true))
+
+	// Move the window to the app. The active flag is not changed.
+	machineWindow.MachineID = 0
+	machineWindow.AppID = app.ID
+	machineWindow.Downgrade = false
+	require.NoError(t, UpdateMaintenanceWindow(db, machineWindow))
+
+	window, err := GetMaintenanceWindowByID(db, machineWindow.ID)
+	require.NoError(t, err)
+	require.NotNil(t, window)
+	require.Zero(t, window.MachineID)
+	require.Nil(t, window.Machine)
+	require.Equal(t, app.ID, window.AppID)
+	require.NotNil(t, window.App)
+	require.False(t, window.Downgrade)
+	require.True(t, window.Active)
+	require.Equal(t, startsAt, window.StartsAt.UTC())
+
+	// The windows are deleted with the app.
+	require.NoError(t, DeleteApp(db, app))
+	window, err = GetMaintenanceWindowByID(db, machineWindow.ID)
+	require.NoError(t, err)
+	require.Nil(t, window)
+
+	require.NoError(t, DeleteMaintenanceWindow(db, serviceWindow.ID))
+	err = DeleteMaintenanceWindow(db, serviceWindow.ID)
+	require.True(t, errors.Is(err, ErrNotExists))
+	err = UpdateMaintenanceWindow(db, serviceWindow)
+	require.True(t, errors.Is(err, ErrNotExists))
+
+	windows, err = GetMaintenanceWindows(db)
+	require.NoError(t, err)
+	require.Empty(t, windows)
+}
@@ -1,6 +1,8 @@
 package dbmodel
 
 import (
+	"time"
+
 	"github.com/go-pg/pg/v10"
 	"github.com/pkg/errors"
 )
@@ -13,15 +15,59 @@ type CalculatedNetworkMetrics struct {
 	AddrUtilization int16
 	// Delegated prefix utilization in percentage multiplied by 10.
 	PdUtilization int16
+	// Forecasted exhaustion times. They are zero if the exhaustion is not
+	// forecasted.
+	AddrExhaustionAt time.Time
+	PdExhaustionAt   time.Time
+}
+
+// Utilization of the address or delegated prefix pool calculated from
+// the statistics returned by the Kea servers. The highest utilization is
+// taken if the pool is served by several servers.
+type CalculatedPoolMetrics struct {
+	// Prefix of the subnet the pool belongs to.
+	Subnet string
+	// Address range or delegated prefix pool prefix.
+	Pool string
+	// Indicates if it is a delegated prefix pool.
+	PrefixDelegation bool
+	// Utilization in percentage multiplied by 10.
+	Utilization int16
+	// Earliest forecasted exhaustion time among the servers. It is zero
+	// if the exhaustion is not forecasted.
+	ExhaustionAt time.Time
 }
 
-// Metric values calculated from the database.
+// Metric values of the primary or secondary server in the HA service.
+type CalculatedHAMetrics struct {
+	ServiceID   int64
+	ServiceName string
+	// Role of the server in the service: primary or secondary.
+	Role string
+	// Last known HA state of the server.
+	State HAState
+	// Number of clients unacked by the partner as seen by the server.
+	PartnerUnackedClients int64
+}
+
+// Number of the events of the level stored in the database.
+type CalculatedEventMetrics struct {
+	Level EventLevel `pg:",use_zero"`
+	Count int64
+}
+
+// Metric values calculated from the database. The event table size is
+// expressed in bytes and includes the indexes.
 type CalculatedMetrics struct {
 	AuthorizedMachines   int64
 	UnauthorizedMachines int64
 	UnreachableMachines  int64
 	SubnetMetrics        []CalculatedNetworkMetrics
 	SharedNetworkMetrics []CalculatedNetworkMetrics
+	PoolMetrics          []CalculatedPoolMetrics
+	HAMetrics            []CalculatedHAMetrics
+	EventMetrics         []CalculatedEventMetrics
+	EventTableSize       int64
 }
 
 // Calculates various metrics using several SELECT queries.
@@ -40,7 +86,7 @@ func GetCalculatedMetrics(db *pg.DB) (*CalculatedMetrics, error) {
 	err = db.Model().
 		Table("subnet").
 		ColumnExpr("\"prefix\" AS \"label\"").
-		Column("addr_utilization", "pd_utilization").
+		Column("addr_utilization", "pd_utilization", "addr_exhaustion_at", "pd_exhaustion_at").
 		Select(&metrics.SubnetMetrics)
 
 	if err != nil {
@@ -50,12 +96,73 @@ func GetCalculatedMetrics(db *pg.DB) (*CalculatedMetrics, error) {
 	err = db.Model().
 		Table("shared_network").
 		ColumnExpr("\"name\" AS \"label\"").
-		Column("addr_utilization", "pd_utilization").
+		Column("addr_utilization", "pd_utilization", "addr_exhaustion_at", "pd_exhaustion_at").
 		Select(&metrics.SharedNetworkMetrics)
 
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot calculate shared network metrics")
 	}
 
+	_, err = db.Query(&metrics.PoolMetrics, `
+		SELECT s.prefix AS subnet, p.lower_bound || '-' || p.upper_bound AS pool,
+			false AS prefix_delegation, MAX(p.utilization) AS utilization,
+			MIN(p.exhaustion_at) AS exhaustion_at
+		FROM address_pool AS p
+		JOIN local_subnet AS ls ON ls.id = p.local_subnet_id
+		JOIN subnet AS s ON s.id = ls.subnet_id
+		WHERE p.utilization IS NOT NULL
+		GROUP BY s.prefix, p.lower_bound, p.upper_bound
+		UNION ALL
+		SELECT s.prefix AS subnet, p.prefix AS pool,
+			true AS prefix_delegation, MAX(p.utilization) AS utilization,
+			MIN(p.exhaustion_at) AS exhaustion_at
+		FROM prefix_pool AS p
+		JOIN local_subnet AS ls ON ls.id = p.local_subnet_id
+		JOIN subnet AS s ON s.id = ls.subnet_id
+		WHERE p.utilization IS NOT NULL
+		GROUP BY s.prefix, p.prefix
+		ORDER BY subnet, pool
+	`)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot calculate pool metrics")
+	}
+
+	_, err = db.Query(&metrics.HAMetrics, `
+		SELECT ha.service_id, s.name AS service_name, 'primary' AS role,
+			ha.primary_last_state AS state,
+			ha.secondary_unacked_clients AS partner_unacked_clients
+		FROM ha_service AS ha
+		JOIN service AS s ON s.id = ha.service_id
+		WHERE ha.primary_id IS NOT NULL AND ha.primary_id <> 0
+		UNION ALL
+		SELECT ha.service_id, s.name AS service_name, 'secondary' AS role,
+			ha.secondary_last_state AS state,
+			ha.primary_unacked_clients AS partner_unacked_clients
+		FROM ha_service AS ha
+		JOIN service AS s ON s.id = ha.service_id
+		WHERE ha.secondary_id IS NOT NULL AND ha.secondary_id <> 0
+		ORDER BY service_id, role
+	`)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot calculate HA metrics")
+	}
+
+	_, err = db.Query(&metrics.EventMetrics, `
+		SELECT level, COUNT(*) AS count
+		FROM event
+		GROUP BY level
+		ORDER BY level
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot calculate event metrics")
+	}
+
+	_, err = db.QueryOne(pg.Scan(&metrics.EventTableSize), "SELECT pg_total_relation_size('event')")
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot calculate event table size")
+	}
+
 	return &metrics, nil
 }
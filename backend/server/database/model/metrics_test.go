@@ -21,6 +21,8 @@ func TestEmptyDatabaseMetrics(t *testing.T) {
 	require.Zero(t, metrics.AuthorizedMachines)
 	require.Zero(t, metrics.UnauthorizedMachines)
 	require.Zero(t, metrics.UnreachableMachines)
+	require.Empty(t, metrics.EventMetrics)
+	require.NotZero(t, metrics.EventTableSize)
 }
 
 // Metrics based on the machines should be properly calculated.
@@ -137,3 +139,51 @@ func TestFilledSharedNetworksDatabaseMetrics(t *testing.T) {
 	require.Zero(t, metrics.SharedNetworkMetrics[2].AddrUtilization)
 	require.Zero(t, metrics.SharedNetworkMetrics[2].PdUtilization)
 }
+
+// Metrics per HA server should be properly calculated.
+func TestFilledHAServicesDatabaseMetrics(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	services := addTestServices(t, db)
+
+	// Act
+	metrics, err := GetCalculatedMetrics(db)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, metrics.HAMetrics, 2)
+
+	require.Equal(t, services[1].ID, metrics.HAMetrics[0].ServiceID)
+	require.Equal(t, "service2", metrics.HAMetrics[0].ServiceName)
+	require.Equal(t, "primary", metrics.HAMetrics[0].Role)
+	require.EqualValues(t, HAStateLoadBalancing, metrics.HAMetrics[0].State)
+	require.Equal(t, services[1].HAService.SecondaryUnackedClients, metrics.HAMetrics[0].PartnerUnackedClients)
+
+	require.Equal(t, services[1].ID, metrics.HAMetrics[1].ServiceID)
+	require.Equal(t, "secondary", metrics.HAMetrics[1].Role)
+	require.EqualValues(t, HAStateSyncing, metrics.HAMetrics[1].State)
+	require.Equal(t, services[1].HAService.PrimaryUnackedClients, metrics.HAMetrics[1].PartnerUnackedClients)
+}
+
+// Metrics based on the events should be properly calculated.
+func TestFilledEventsDatabaseMetrics(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	for _, level := range []EventLevel{EvInfo, EvError, EvInfo} {
+		require.NoError(t, AddEvent(db, &Event{Text: "event", Level: level}))
+	}
+
+	// Act
+	metrics, err := GetCalculatedMetrics(db)
+
+	// Assert
+	require.NoError(t, err)
+	require.Len(t, metrics.EventMetrics, 2)
+	require.Equal(t, EvInfo, metrics.EventMetrics[0].Level)
+	require.EqualValues(t, 2, metrics.EventMetrics[0].Count)
+	require.Equal(t, EvError, metrics.EventMetrics[1].Level)
+	require.EqualValues(t, 1, metrics.EventMetrics[1].Count)
+	require.NotZero(t, metrics.EventTableSize)
+}
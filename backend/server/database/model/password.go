@@ -0,0 +1,213 @@
+package dbmodel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Maximum number of the passwords held in the history of each user. It
+// limits the number of the passwords that can be checked against reuse.
+const PasswordHistoryMaxSize = 24
+
+// Maximum time the user can be locked out for. The lockout duration is
+// doubled every time the user is locked out again but never exceeds it.
+const MaxLockoutDuration = 24 * time.Hour
+
+// Represents a password hash in the history of the user passwords.
+type SystemUserPasswordHistory struct {
+	tableName    struct{} `pg:"system_user_password_history"` //nolint:unused
+	ID           int64
+	UserID       int
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+// Represents the failed login attempts of a user. The user cannot log in
+// until the locked until time. The lockout count is the number of the
+// consecutive lockouts. It is reset when the user logs in successfully.
+type SystemUserLockout struct {
+	tableName      struct{} `pg:"system_user_lockout"` //nolint:unused
+	UserID         int      `pg:",pk"`
+	FailedAttempts int      `pg:",use_zero"`
+	LockoutCount   int      `pg:",use_zero"`
+	LockedUntil    time.Time
+}
+
+// Checks if the user is locked out at the specified time.
+func (lockout *SystemUserLockout) IsLocked(now time.Time) bool {
+	return lockout != nil && lockout.LockedUntil.After(now)
+}
+
+// Returns the duration of the lockout. The base duration is doubled for
+// every consecutive lockout but it never exceeds the maximum lockout
+// duration.
+func GetLockoutDuration(baseDuration time.Duration, lockoutCount int) time.Duration {
+	duration := baseDuration
+	for i := 1; i < lockoutCount && duration < MaxLockoutDuration; i++ {
+		duration *= 2
+	}
+	if duration > MaxLockoutDuration {
+		duration = MaxLockoutDuration
+	}
+	return duration
+}
+
+// Adds the password hash to the history of the user passwords and removes
+// the oldest entries exceeding the maximum history size.
+func addPasswordHistory(tx *pg.Tx, userID int, passwordHash string) error {
+	entry := &SystemUserPasswordHistory{
+		UserID:       userID,
+		PasswordHash: passwordHash,
+		CreatedAt:    time.Now().UTC(),
+	}
+	_, err := tx.Model(entry).Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem adding password to the history of user with ID %d", userID)
+	}
+
+	recent := tx.Model((*SystemUserPasswordHistory)(nil)).
+		Column("id").
+		Where("user_id = ?", userID).
+		OrderExpr("id DESC").
+		Limit(PasswordHistoryMaxSize)
+	_, err = tx.Model((*SystemUserPasswordHistory)(nil)).
+		Where("user_id = ?", userID).
+		Where("id NOT IN (?)", recent).
+		Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem pruning password history of user with ID %d", userID)
+	}
+	return nil
+}
+
+// Checks if the password matches any of the specified number of the recent
+// passwords of the user, including the current one.
+func IsPasswordInHistory(dbi dbops.DBI, userID int, password string, count int) (bool, error) {
+	if count <= 0 {
+		return false, nil
+	}
+	recent := dbi.Model((*SystemUserPasswordHistory)(nil)).
+		Column("id").
+		Where("user_id = ?", userID).
+		OrderExpr("id DESC").
+		Limit(count)
+	exists, err := dbi.Model((*SystemUserPasswordHistory)(nil)).
+		Where("id IN (?)", recent).
+		Where("password_hash = crypt(?, password_hash)", password).
+		Exists()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem checking password history of user with ID %d", userID)
+	}
+	return exists, nil
+}
+
+// Returns the password change time and the flag indicating if the password
+// change is required. The password hash is not returned. It returns nil if
+// the user has no password.
+func GetUserPasswordInfo(dbi dbops.DBI, userID int) (*SystemUserPassword, error) {
+	password := &SystemUserPassword{}
+	err := dbi.Model(password).
+		Column("id", "changed_at", "change_required").
+		Where("id = ?", userID).
+		Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, pkgerrors.Wrapf(err, "problem getting password of user with ID %d", userID)
+	}
+	return password, nil
+}
+
+// Returns the ID of the user having a password and the specified login or
+// email. It returns zero if there is no such user.
+func GetPasswordUserID(dbi dbops.DBI, login, email string) (int, error) {
+	var ids []int
+	err := dbi.Model((*SystemUser)(nil)).
+		Column("system_user.id").
+		Join("JOIN system_user_password").JoinOn("system_user.id = system_user_password.id").
+		Where("login = ? OR email = ?", login, email).
+		Limit(1).
+		Select(&ids)
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "problem getting user with login %s or email %s", login, email)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	return ids[0], nil
+}
+
+// Returns the failed login attempts of the user. It returns nil if the
+// user has no failed attempts recorded.
+func GetUserLockout(dbi dbops.DBI, userID int) (*SystemUserLockout, error) {
+	lockout := &SystemUserLockout{}
+	err := dbi.Model(lockout).Where("user_id = ?", userID).Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, pkgerrors.Wrapf(err, "problem getting lockout of user with ID %d", userID)
+	}
+	return lockout, nil
+}
+
+// Records the failed login attempt in a transaction.
+func recordFailedLogin(tx *pg.Tx, userID, threshold int, baseDuration time.Duration, now time.Time) (*SystemUserLockout, error) {
+	lockout := &SystemUserLockout{
+		UserID:         userID,
+		FailedAttempts: 1,
+	}
+	_, err := tx.Model(lockout).
+		OnConflict("(user_id) DO UPDATE").
+		Set("failed_attempts = system_user_lockout.failed_attempts + 1").
+		Returning("*").
+		Insert()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem recording failed login of user with ID %d", userID)
+	}
+	if threshold <= 0 || lockout.FailedAttempts < threshold {
+		return lockout, nil
+	}
+
+	lockout.FailedAttempts = 0
+	lockout.LockoutCount++
+	lockout.LockedUntil = now.Add(GetLockoutDuration(baseDuration, lockout.LockoutCount))
+	_, err = tx.Model(lockout).WherePK().Update()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem locking out user with ID %d", userID)
+	}
+	return lockout, nil
+}
+
+// Records the failed login attempt of the user. The user is locked out
+// when the number of the failed attempts reaches the threshold. The
+// lockout duration grows with the number of the consecutive lockouts.
+// The zero threshold disables the lockout. It returns the updated lockout
+// state. It begins a new transaction when dbi has a *pg.DB type or uses an
+// existing transaction when dbi has a *pg.Tx type.
+func RecordFailedLogin(dbi dbops.DBI, userID, threshold int, baseDuration time.Duration, now time.Time) (lockout *SystemUserLockout, err error) {
+	if db, ok := dbi.(*pg.DB); ok {
+		err = db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			lockout, err = recordFailedLogin(tx, userID, threshold, baseDuration, now)
+			return err
+		})
+		return
+	}
+	return recordFailedLogin(dbi.(*pg.Tx), userID, threshold, baseDuration, now)
+}
+
+// Deletes the failed login attempts and unlocks the user. It is called
+// when the user logs in successfully or is unlocked by the administrator.
+func DeleteUserLockout(dbi dbops.DBI, userID int) error {
+	_, err := dbi.Model((*SystemUserLockout)(nil)).Where("user_id = ?", userID).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting lockout of user with ID %d", userID)
+	}
+	return nil
+}
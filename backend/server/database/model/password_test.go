@@ -0,0 +1,223 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the lockout duration is doubled for the consecutive lockouts
+// and is limited by the maximum duration.
+func TestGetLockoutDuration(t *testing.T) {
+	require.Equal(t, 5*time.Minute, GetLockoutDuration(5*time.Minute, 0))
+	require.Equal(t, 5*time.Minute, GetLockoutDuration(5*time.Minute, 1))
+	require.Equal(t, 10*time.Minute, GetLockoutDuration(5*time.Minute, 2))
+	require.Equal(t, 40*time.Minute, GetLockoutDuration(5*time.Minute, 4))
+	require.Equal(t, MaxLockoutDuration, GetLockoutDuration(5*time.Minute, 20))
+	require.Equal(t, MaxLockoutDuration, GetLockoutDuration(48*time.Hour, 1))
+}
+
+// Test checking if the user is locked out.
+func TestLockoutIsLocked(t *testing.T) {
+	now := time.Now().UTC()
+
+	var lockout *SystemUserLockout
+	require.False(t, lockout.IsLocked(now))
+
+	lockout = &SystemUserLockout{}
+	require.False(t, lockout.IsLocked(now))
+
+	lockout.LockedUntil = now.Add(time.Minute)
+	require.True(t, lockout.IsLocked(now))
+	require.False(t, lockout.IsLocked(now.Add(time.Minute)))
+}
+
+// Test that the passwords are recorded in the history and the reused
+// passwords are detected.
+func TestPasswordHistory(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "jan",
+		Lastname: "Kowalski",
+		Name:     "Jan",
+	}
+	_, err := CreateUserWithPassword(db, user, "first")
+	require.NoError(t, err)
+
+	// The current password is in the history.
+	reused, err := IsPasswordInHistory(db, user.ID, "first", 1)
+	require.NoError(t, err)
+	require.True(t, reused)
+
+	// The zero count disables the check.
+	reused, err = IsPasswordInHistory(db, user.ID, "first", 0)
+	require.NoError(t, err)
+	require.False(t, reused)
+
+	require.NoError(t, SetPassword(db, user.ID, "second"))
+	require.NoError(t, SetPassword(db, user.ID, "third"))
+
+	reused, err = IsPasswordInHistory(db, user.ID, "first", 2)
+	require.NoError(t, err)
+	require.False(t, reused)
+
+	reused, err = IsPasswordInHistory(db, user.ID, "first", 3)
+	require.NoError(t, err)
+	require.True(t, reused)
+
+	reused, err = IsPasswordInHistory(db, user.ID, "fourth", 3)
+	require.NoError(t, err)
+	require.False(t, reused)
+
+	// The history is limited.
+	for i := 0; i < PasswordHistoryMaxSize; i++ {
+		require.NoError(t, SetPassword(db, user.ID, "password"))
+	}
+	count, err := db.Model((*SystemUserPasswordHistory)(nil)).Where("user_id = ?", user.ID).Count()
+	require.NoError(t, err)
+	require.Equal(t, PasswordHistoryMaxSize, count)
+
+	// The password hash is still valid after recording the history.
+	ok, err := Authenticate(db, &SystemUser{Login: "jan"}, "password")
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+// Test that the password change time and the change required flag are
+// set when the password is set.
+func TestGetUserPasswordInfo(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "jan",
+		Lastname: "Kowalski",
+		Name:     "Jan",
+	}
+	_, err := CreateUserWithTemporaryPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	info, err := GetUserPasswordInfo(db, user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, info)
+	require.True(t, info.ChangeRequired)
+	require.Empty(t, info.Password)
+	require.WithinDuration(t, time.Now().UTC(), info.ChangedAt, time.Minute)
+
+	require.NoError(t, SetPassword(db, user.ID, "new password"))
+	info, err = GetUserPasswordInfo(db, user.ID)
+	require.NoError(t, err)
+	require.False(t, info.ChangeRequired)
+
+	require.NoError(t, SetTemporaryPassword(db, user.ID, "temporary"))
+	info, err = GetUserPasswordInfo(db, user.ID)
+	require.NoError(t, err)
+	require.True(t, info.ChangeRequired)
+
+	ok, err := Authenticate(db, &SystemUser{Login: "jan"}, "temporary")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	// The user without password.
+	user = &SystemUser{
+		Login:    "john",
+		Lastname: "Smith",
+		Name:     "John",
+	}
+	_, err = CreateUser(db, user)
+	require.NoError(t, err)
+	info, err = GetUserPasswordInfo(db, user.ID)
+	require.NoError(t, err)
+	require.Nil(t, info)
+}
+
+// Test that the user having a password is found by login or email.
+func TestGetPasswordUserID(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "jan",
+		Email:    "jan@example.org",
+		Lastname: "Kowalski",
+		Name:     "Jan",
+	}
+	_, err := CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	id, err := GetPasswordUserID(db, "jan", "")
+	require.NoError(t, err)
+	require.Equal(t, user.ID, id)
+
+	id, err = GetPasswordUserID(db, "", "jan@example.org")
+	require.NoError(t, err)
+	require.Equal(t, user.ID, id)
+
+	id, err = GetPasswordUserID(db, "john", "")
+	require.NoError(t, err)
+	require.Zero(t, id)
+}
+
+// Test that the user is locked out after the specified number of the
+// failed login attempts and the lockout duration grows.
+func TestRecordFailedLogin(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "jan",
+		Lastname: "Kowalski",
+		Name:     "Jan",
+	}
+	_, err := CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	lockout, err := GetUserLockout(db, user.ID)
+	require.NoError(t, err)
+	require.Nil(t, lockout)
+
+	now := time.Now().UTC()
+	for i := 1; i < 3; i++ {
+		lockout, err = RecordFailedLogin(db, user.ID, 3, time.Minute, now)
+		require.NoError(t, err)
+		require.Equal(t, i, lockout.FailedAttempts)
+		require.False(t, lockout.IsLocked(now))
+	}
+
+	lockout, err = RecordFailedLogin(db, user.ID, 3, time.Minute, now)
+	require.NoError(t, err)
+	require.True(t, lockout.IsLocked(now))
+	require.Zero(t, lockout.FailedAttempts)
+	require.Equal(t, 1, lockout.LockoutCount)
+	require.WithinDuration(t, now.Add(time.Minute), lockout.LockedUntil, time.Second)
+
+	lockout, err = GetUserLockout(db, user.ID)
+	require.NoError(t, err)
+	require.True(t, lockout.IsLocked(now))
+
+	// The next lockout takes twice as long.
+	for i := 0; i < 3; i++ {
+		lockout, err = RecordFailedLogin(db, user.ID, 3, time.Minute, now)
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, lockout.LockoutCount)
+	require.WithinDuration(t, now.Add(2*time.Minute), lockout.LockedUntil, time.Second)
+
+	// Unlock the user.
+	require.NoError(t, DeleteUserLockout(db, user.ID))
+	lockout, err = GetUserLockout(db, user.ID)
+	require.NoError(t, err)
+	require.Nil(t, lockout)
+
+	// The zero threshold disables the lockout.
+	for i := 0; i < 5; i++ {
+		lockout, err = RecordFailedLogin(db, user.ID, 0, time.Minute, now)
+		require.NoError(t, err)
+		require.False(t, lockout.IsLocked(now))
+	}
+	require.Equal(t, 5, lockout.FailedAttempts)
+}
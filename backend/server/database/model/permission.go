@@ -0,0 +1,153 @@
+package dbmodel
+
+import (
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Type of the action the user may perform on a resource.
+type PermissionAction string
+
+// Actions the user may perform on resources. The write action implies
+// the read action.
+const (
+	PermissionActionRead  PermissionAction = "read"
+	PermissionActionWrite PermissionAction = "write"
+)
+
+// Type of the scope limiting a permission to a selected system component.
+type PermissionScopeType string
+
+// Supported permission scopes. The permission having the app scope
+// grants access to the resources belonging to the app with the specified
+// ID. The permission having the machine scope grants access to the
+// resources belonging to the machine with the specified ID and the apps
+// running on it. The permission having the machine-group scope grants
+// access to the resources belonging to the machines in the machine group
+// with the specified ID and the apps running on them.
+const (
+	PermissionScopeNone         PermissionScopeType = ""
+	PermissionScopeApp          PermissionScopeType = "app"
+	PermissionScopeMachine      PermissionScopeType = "machine"
+	PermissionScopeMachineGroup PermissionScopeType = "machine-group"
+)
+
+// Resource types the permissions are granted on.
+const (
+	PermissionResourceAll       = "*"
+	PermissionResourceMachines  = "machines"
+	PermissionResourceApps      = "apps"
+	PermissionResourceServices  = "services"
+	PermissionResourceHosts     = "hosts"
+	PermissionResourceSubnets   = "subnets"
+	PermissionResourceZones     = "zones"
+	PermissionResourceEvents    = "events"
+	PermissionResourceSettings  = "settings"
+	PermissionResourceDashboard = "dashboard"
+	PermissionResourceGroups    = "groups"
+	PermissionResourceUsers     = "users"
+	PermissionResourceAudit     = "audit"
+)
+
+// Returns the list of all resource types the permissions can be granted
+// on, including the wildcard.
+func GetPermissionResources() []string {
+	return []string{
+		PermissionResourceAll,
+		PermissionResourceMachines,
+		PermissionResourceApps,
+		PermissionResourceServices,
+		PermissionResourceHosts,
+		PermissionResourceSubnets,
+		PermissionResourceZones,
+		PermissionResourceEvents,
+		PermissionResourceSettings,
+		PermissionResourceDashboard,
+		PermissionResourceGroups,
+		PermissionResourceUsers,
+		PermissionResourceAudit,
+	}
+}
+
+// Represents a permission granted to the users belonging to a group.
+// The empty scope type means that the permission applies to all
+// resources of the given type.
+type SystemGroupPermission struct {
+	ID        int64
+	GroupID   int
+	Resource  string
+	Action    PermissionAction
+	ScopeType PermissionScopeType
+	ScopeID   int64
+}
+
+// Checks if the permission specifies a supported resource, action and
+// scope.
+func (p *SystemGroupPermission) Validate() error {
+	validResource := false
+	for _, resource := range GetPermissionResources() {
+		if p.Resource == resource {
+			validResource = true
+			break
+		}
+	}
+	if !validResource {
+		return pkgerrors.Errorf("unsupported permission resource %q", p.Resource)
+	}
+	switch p.Action {
+	case PermissionActionRead, PermissionActionWrite:
+	default:
+		return pkgerrors.Errorf("unsupported permission action %q", p.Action)
+	}
+	switch p.ScopeType {
+	case PermissionScopeNone:
+		if p.ScopeID != 0 {
+			return pkgerrors.New("scope ID specified for the permission without a scope type")
+		}
+	case PermissionScopeApp, PermissionScopeMachine, PermissionScopeMachineGroup:
+		if p.ScopeID <= 0 {
+			return pkgerrors.Errorf("invalid scope ID %d of the permission with the %s scope", p.ScopeID, p.ScopeType)
+		}
+	default:
+		return pkgerrors.Errorf("unsupported permission scope type %q", p.ScopeType)
+	}
+	return nil
+}
+
+// Checks if the permission grants performing the specified action on the
+// resource. It doesn't take the scope into account.
+func (p *SystemGroupPermission) Grants(resource string, action PermissionAction) bool {
+	if p.Resource != PermissionResourceAll && p.Resource != resource {
+		return false
+	}
+	return p.Action == PermissionActionWrite || p.Action == action
+}
+
+// Checks if the permission grants at least the same access as the other
+// permission. It must apply to the same or all resource types, allow the
+// same or a wider action and have the same scope or no scope.
+func (p *SystemGroupPermission) Covers(other *SystemGroupPermission) bool {
+	if other.Resource == PermissionResourceAll && p.Resource != PermissionResourceAll {
+		return false
+	}
+	if !p.Grants(other.Resource, other.Action) {
+		return false
+	}
+	return p.ScopeType == PermissionScopeNone || (p.ScopeType == other.ScopeType && p.ScopeID == other.ScopeID)
+}
+
+// Fetches the permissions granted to the specified groups.
+func GetPermissionsByGroupIDs(dbi dbops.DBI, groupIDs []int) ([]SystemGroupPermission, error) {
+	permissions := []SystemGroupPermission{}
+	if len(groupIDs) == 0 {
+		return permissions, nil
+	}
+	err := dbi.Model(&permissions).
+		WhereIn("group_id IN (?)", groupIDs).
+		OrderExpr("id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting permissions of the groups %v", groupIDs)
+	}
+	return permissions, nil
+}
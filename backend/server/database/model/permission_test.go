@@ -0,0 +1,146 @@
+package dbmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test the permission validation.
+func TestValidatePermission(t *testing.T) {
+	require.NoError(t, (&SystemGroupPermission{
+		Resource: PermissionResourceAll,
+		Action:   PermissionActionWrite,
+	}).Validate())
+	require.NoError(t, (&SystemGroupPermission{
+		Resource:  PermissionResourceHosts,
+		Action:    PermissionActionRead,
+		ScopeType: PermissionScopeApp,
+		ScopeID:   3,
+	}).Validate())
+	require.NoError(t, (&SystemGroupPermission{
+		Resource:  PermissionResourceHosts,
+		Action:    PermissionActionWrite,
+		ScopeType: PermissionScopeMachineGroup,
+		ScopeID:   2,
+	}).Validate())
+
+	// Unknown resource.
+	require.Error(t, (&SystemGroupPermission{
+		Resource: "foo",
+		Action:   PermissionActionRead,
+	}).Validate())
+	// Unknown action.
+	require.Error(t, (&SystemGroupPermission{
+		Resource: PermissionResourceHosts,
+		Action:   "delete",
+	}).Validate())
+	// Unknown scope.
+	require.Error(t, (&SystemGroupPermission{
+		Resource:  PermissionResourceHosts,
+		Action:    PermissionActionRead,
+		ScopeType: "subnet",
+		ScopeID:   1,
+	}).Validate())
+	// Missing scope ID.
+	require.Error(t, (&SystemGroupPermission{
+		Resource:  PermissionResourceHosts,
+		Action:    PermissionActionRead,
+		ScopeType: PermissionScopeMachine,
+	}).Validate())
+	// Scope ID without scope type.
+	require.Error(t, (&SystemGroupPermission{
+		Resource: PermissionResourceHosts,
+		Action:   PermissionActionRead,
+		ScopeID:  1,
+	}).Validate())
+}
+
+// Test that the write permission implies read and the wildcard matches
+// all resources.
+func TestPermissionGrants(t *testing.T) {
+	read := &SystemGroupPermission{Resource: PermissionResourceHosts, Action: PermissionActionRead}
+	require.True(t, read.Grants(PermissionResourceHosts, PermissionActionRead))
+	require.False(t, read.Grants(PermissionResourceHosts, PermissionActionWrite))
+	require.False(t, read.Grants(PermissionResourceSubnets, PermissionActionRead))
+
+	write := &SystemGroupPermission{Resource: PermissionResourceHosts, Action: PermissionActionWrite}
+	require.True(t, write.Grants(PermissionResourceHosts, PermissionActionRead))
+	require.True(t, write.Grants(PermissionResourceHosts, PermissionActionWrite))
+
+	all := &SystemGroupPermission{Resource: PermissionResourceAll, Action: PermissionActionRead}
+	require.True(t, all.Grants(PermissionResourceUsers, PermissionActionRead))
+	require.False(t, all.Grants(PermissionResourceUsers, PermissionActionWrite))
+}
+
+// Test that the permission covers the permissions granting the same or
+// narrower access.
+func TestPermissionCovers(t *testing.T) {
+	write := &SystemGroupPermission{Resource: PermissionResourceHosts, Action: PermissionActionWrite}
+	require.True(t, write.Covers(&SystemGroupPermission{Resource: PermissionResourceHosts, Action: PermissionActionRead}))
+	require.True(t, write.Covers(&SystemGroupPermission{Resource: PermissionResourceHosts, Action: PermissionActionWrite}))
+	require.True(t, write.Covers(&SystemGroupPermission{
+		Resource: PermissionResourceHosts, Action: PermissionActionWrite, ScopeType: PermissionScopeApp, ScopeID: 1,
+	}))
+	require.False(t, write.Covers(&SystemGroupPermission{Resource: PermissionResourceUsers, Action: PermissionActionRead}))
+	require.False(t, write.Covers(&SystemGroupPermission{Resource: PermissionResourceAll, Action: PermissionActionRead}))
+
+	read := &SystemGroupPermission{Resource: PermissionResourceHosts, Action: PermissionActionRead}
+	require.False(t, read.Covers(write))
+
+	scoped := &SystemGroupPermission{
+		Resource: PermissionResourceHosts, Action: PermissionActionWrite, ScopeType: PermissionScopeApp, ScopeID: 1,
+	}
+	require.False(t, scoped.Covers(write))
+	require.True(t, scoped.Covers(&SystemGroupPermission{
+		Resource: PermissionResourceHosts, Action: PermissionActionRead, ScopeType: PermissionScopeApp, ScopeID: 1,
+	}))
+	require.False(t, scoped.Covers(&SystemGroupPermission{
+		Resource: PermissionResourceHosts, Action: PermissionActionRead, ScopeType: PermissionScopeApp, ScopeID: 2,
+	}))
+	require.False(t, scoped.Covers(&SystemGroupPermission{
+		Resource: PermissionResourceHosts, Action: PermissionActionRead, ScopeType: PermissionScopeMachine, ScopeID: 1,
+	}))
+
+	all := &SystemGroupPermission{Resource: PermissionResourceAll, Action: PermissionActionRead}
+	require.True(t, all.Covers(read))
+	require.True(t, all.Covers(&SystemGroupPermission{Resource: PermissionResourceAll, Action: PermissionActionRead}))
+	require.False(t, all.Covers(write))
+}
+
+// Test that the permissions of the predefined groups are fetched.
+func TestGetPermissionsByGroupIDs(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	// The super-admin has no explicit permissions.
+	permissions, err := GetPermissionsByGroupIDs(db, []int{SuperAdminGroupID})
+	require.NoError(t, err)
+	require.Empty(t, permissions)
+
+	permissions, err = GetPermissionsByGroupIDs(db, []int{AdminGroupID})
+	require.NoError(t, err)
+	require.Len(t, permissions, 11)
+	for _, p := range permissions {
+		require.Equal(t, AdminGroupID, p.GroupID)
+		require.Equal(t, PermissionScopeNone, p.ScopeType)
+		if p.Resource == PermissionResourceGroups || p.Resource == PermissionResourceAudit {
+			require.Equal(t, PermissionActionRead, p.Action)
+		} else {
+			require.Equal(t, PermissionActionWrite, p.Action)
+		}
+	}
+
+	group, err := GetGroupByID(db, 4)
+	require.NoError(t, err)
+	require.Equal(t, "dhcp-operator", group.Name)
+
+	permissions, err = GetPermissionsByGroupIDs(db, []int{3, 4})
+	require.NoError(t, err)
+	require.Len(t, permissions, 21)
+
+	permissions, err = GetPermissionsByGroupIDs(db, nil)
+	require.NoError(t, err)
+	require.Empty(t, permissions)
+}
@@ -1,7 +1,9 @@
 package dbmodel
 
 import (
+	"math/big"
 	"net"
+	"strings"
 	"time"
 
 	errors "github.com/pkg/errors"
@@ -29,6 +31,15 @@ type AddressPool struct {
 	LocalSubnet       *LocalSubnet `pg:"rel:has-one"`
 
 	KeaParameters *keaconfig.PoolParameters
+
+	// Statistics returned by Kea 2.3.0 and later for the pool and the
+	// utilization calculated from them in tenths of a percent.
+	Stats            SubnetStats
+	StatsCollectedAt time.Time
+	Utilization      int16
+
+	// Forecasted time when the pool is exhausted.
+	ExhaustionAt time.Time
 }
 
 // Returns lower pool boundary.
@@ -74,6 +85,15 @@ type PrefixPool struct {
 	LocalSubnet       *LocalSubnet `pg:"rel:has-one"`
 
 	KeaParameters *keaconfig.PoolParameters
+
+	// Statistics returned by Kea 2.3.0 and later for the pool and the
+	// utilization calculated from them in tenths of a percent.
+	Stats            SubnetStats
+	StatsCollectedAt time.Time
+	Utilization      int16
+
+	// Forecasted time when the pool is exhausted.
+	ExhaustionAt time.Time
 }
 
 // Returns a pointer to a structure holding the delegated prefix data.
@@ -101,6 +121,107 @@ func (pp *PrefixPool) HasEqualData(other *PrefixPool) bool {
 		pp.ExcludedPrefix == other.ExcludedPrefix
 }
 
+// Returns the pool statistic as a big float. It returns nil if the
+// statistic is missing or has an unexpected type.
+func getPoolStat(stats SubnetStats, name string) *big.Float {
+	switch value := stats[name].(type) {
+	case uint64:
+		return new(big.Float).SetUint64(value)
+	case int64:
+		return new(big.Float).SetInt64(value)
+	case *big.Int:
+		return new(big.Float).SetInt(value)
+	default:
+		return nil
+	}
+}
+
+// Calculates the pool utilization as a ratio of the assigned leases to
+// the total leases in the pool. It returns zero if the statistics are
+// missing or the pool is empty.
+func calculatePoolUtilization(stats SubnetStats, assignedName, totalName string) float64 {
+	assigned := getPoolStat(stats, assignedName)
+	total := getPoolStat(stats, totalName)
+	if assigned == nil || total == nil || total.Sign() <= 0 {
+		return 0
+	}
+	utilization, _ := new(big.Float).Quo(assigned, total).Float64()
+	return utilization
+}
+
+// Returns the utilization of the address pool calculated from the
+// statistics as a ratio of the assigned addresses to the total addresses.
+func (ap *AddressPool) GetUtilization(stats SubnetStats) float64 {
+	if strings.Contains(ap.LowerBound, ":") {
+		return calculatePoolUtilization(stats, "assigned-nas", "total-nas")
+	}
+	return calculatePoolUtilization(stats, "assigned-addresses", "total-addresses")
+}
+
+// Updates the statistics pulled for the address pool and its utilization.
+func (ap *AddressPool) UpdateStats(dbi dbops.DBI, stats SubnetStats) error {
+	ap.Stats = stats
+	ap.StatsCollectedAt = storkutil.UTCNow()
+	ap.Utilization = int16(ap.GetUtilization(stats) * 1000)
+	result, err := dbi.Model(ap).
+		Column("stats", "stats_collected_at", "utilization").
+		WherePK().
+		Update()
+	if err != nil {
+		err = errors.Wrapf(err, "problem updating stats in address pool %s-%s", ap.LowerBound, ap.UpperBound)
+	} else if result.RowsAffected() <= 0 {
+		err = errors.Wrapf(ErrNotExists, "address pool with ID %d does not exist", ap.ID)
+	}
+	return err
+}
+
+// Creates the raw utilization sample of the address pool from its current
+// utilization.
+func (ap *AddressPool) NewUtilizationSample(sampledAt time.Time) UtilizationSample {
+	return UtilizationSample{
+		AddressPoolID:      ap.ID,
+		Resolution:         UtilizationResolutionRaw,
+		SampledAt:          sampledAt,
+		AddrUtilization:    ap.Utilization,
+		AddrUtilizationMax: ap.Utilization,
+	}
+}
+
+// Returns the utilization of the prefix pool calculated from the
+// statistics as a ratio of the assigned prefixes to the total prefixes.
+func (pp *PrefixPool) GetUtilization(stats SubnetStats) float64 {
+	return calculatePoolUtilization(stats, "assigned-pds", "total-pds")
+}
+
+// Updates the statistics pulled for the prefix pool and its utilization.
+func (pp *PrefixPool) UpdateStats(dbi dbops.DBI, stats SubnetStats) error {
+	pp.Stats = stats
+	pp.StatsCollectedAt = storkutil.UTCNow()
+	pp.Utilization = int16(pp.GetUtilization(stats) * 1000)
+	result, err := dbi.Model(pp).
+		Column("stats", "stats_collected_at", "utilization").
+		WherePK().
+		Update()
+	if err != nil {
+		err = errors.Wrapf(err, "problem updating stats in prefix pool %s", pp.Prefix)
+	} else if result.RowsAffected() <= 0 {
+		err = errors.Wrapf(ErrNotExists, "prefix pool with ID %d does not exist", pp.ID)
+	}
+	return err
+}
+
+// Creates the raw utilization sample of the prefix pool from its current
+// utilization.
+func (pp *PrefixPool) NewUtilizationSample(sampledAt time.Time) UtilizationSample {
+	return UtilizationSample{
+		PrefixPoolID:     pp.ID,
+		Resolution:       UtilizationResolutionRaw,
+		SampledAt:        sampledAt,
+		PdUtilization:    pp.Utilization,
+		PdUtilizationMax: pp.Utilization,
+	}
+}
+
 // Creates a new address pool given the address range.
 func NewAddressPool(lb, ub net.IP) *AddressPool {
 	pool := &AddressPool{
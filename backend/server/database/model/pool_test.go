@@ -1,6 +1,7 @@
 package dbmodel
 
 import (
+	"math/big"
 	"testing"
 	"time"
 
@@ -481,3 +482,124 @@ func TestAddressPoolHasEqualDataTheSame(t *testing.T) {
 	require.True(t, equalityFirstSecond)
 	require.True(t, equalitySecondFirst)
 }
+
+// Test that the pool utilization is calculated from the pool statistics.
+func TestPoolGetUtilization(t *testing.T) {
+	pool4 := &AddressPool{LowerBound: "192.0.2.10", UpperBound: "192.0.2.20"}
+	require.EqualValues(t, 0.5, pool4.GetUtilization(SubnetStats{
+		"total-addresses":    uint64(10),
+		"assigned-addresses": uint64(5),
+	}))
+	require.Zero(t, pool4.GetUtilization(SubnetStats{
+		"total-addresses":    uint64(0),
+		"assigned-addresses": uint64(0),
+	}))
+	require.Zero(t, pool4.GetUtilization(nil))
+
+	pool6 := &AddressPool{LowerBound: "2001:db8:1::10", UpperBound: "2001:db8:1::20"}
+	total := new(big.Int).Lsh(big.NewInt(1), 64)
+	require.EqualValues(t, 0.25, pool6.GetUtilization(SubnetStats{
+		"total-nas":    total,
+		"assigned-nas": new(big.Int).Rsh(total, 2),
+		// The IPv4 statistics are ignored.
+		"total-addresses":    uint64(10),
+		"assigned-addresses": uint64(10),
+	}))
+
+	prefixPool := &PrefixPool{Prefix: "2001:db8:2::/48", DelegatedLen: 64}
+	require.EqualValues(t, 1, prefixPool.GetUtilization(SubnetStats{
+		"total-pds":    uint64(65536),
+		"assigned-pds": int64(65536),
+	}))
+}
+
+// Test that the pool statistics and utilization are stored in the database.
+func TestUpdatePoolStats(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	apps := addTestSubnetApps(t, db)
+
+	subnet := Subnet{
+		Prefix: "2001:db8:1::/64",
+		LocalSubnets: []*LocalSubnet{
+			{
+				DaemonID: apps[0].Daemons[0].ID,
+				AddressPools: []AddressPool{
+					{
+						LowerBound: "2001:db8:1::10",
+						UpperBound: "2001:db8:1::20",
+					},
+				},
+				PrefixPools: []PrefixPool{
+					{
+						Prefix:       "2001:db8:2::/48",
+						DelegatedLen: 64,
+					},
+				},
+			},
+		},
+	}
+	require.NoError(t, AddSubnet(db, &subnet))
+	require.NoError(t, AddLocalSubnets(db, &subnet))
+
+	localSubnet := subnet.LocalSubnets[0]
+	err := localSubnet.AddressPools[0].UpdateStats(db, SubnetStats{
+		"total-nas":    uint64(16),
+		"assigned-nas": uint64(4),
+	})
+	require.NoError(t, err)
+	err = localSubnet.PrefixPools[0].UpdateStats(db, SubnetStats{
+		"total-pds":    uint64(65536),
+		"assigned-pds": uint64(65536),
+	})
+	require.NoError(t, err)
+
+	returnedSubnet, err := GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Len(t, returnedSubnet.LocalSubnets, 1)
+	pool := returnedSubnet.LocalSubnets[0].AddressPools[0]
+	require.EqualValues(t, 250, pool.Utilization)
+	require.EqualValues(t, uint64(4), pool.Stats["assigned-nas"])
+	require.NotZero(t, pool.StatsCollectedAt)
+	prefixPool := returnedSubnet.LocalSubnets[0].PrefixPools[0]
+	require.EqualValues(t, 1000, prefixPool.Utilization)
+	require.EqualValues(t, uint64(65536), prefixPool.Stats["total-pds"])
+
+	// The pools of the local subnets are fetched in the configuration order.
+	localSubnets, err := GetAppLocalSubnets(db, apps[0].ID)
+	require.NoError(t, err)
+	require.Len(t, localSubnets, 1)
+	require.Len(t, localSubnets[0].AddressPools, 1)
+	require.Equal(t, "2001:db8:1::10", localSubnets[0].AddressPools[0].LowerBound)
+	require.Nil(t, localSubnets[0].AddressPools[0].Stats)
+	require.Len(t, localSubnets[0].PrefixPools, 1)
+
+	// Updating the non-existing pool fails.
+	err = (&AddressPool{ID: pool.ID + 100}).UpdateStats(db, SubnetStats{})
+	require.ErrorIs(t, err, ErrNotExists)
+}
+
+// Test that the raw utilization samples are created from the pool
+// utilization.
+func TestPoolNewUtilizationSample(t *testing.T) {
+	sampledAt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	addressPool := &AddressPool{ID: 1, Utilization: 250}
+	sample := addressPool.NewUtilizationSample(sampledAt)
+	require.EqualValues(t, 1, sample.AddressPoolID)
+	require.Zero(t, sample.PrefixPoolID)
+	require.Equal(t, UtilizationResolutionRaw, sample.Resolution)
+	require.Equal(t, sampledAt, sample.SampledAt)
+	require.EqualValues(t, 250, sample.AddrUtilization)
+	require.EqualValues(t, 250, sample.AddrUtilizationMax)
+	require.Zero(t, sample.PdUtilization)
+
+	prefixPool := &PrefixPool{ID: 2, Utilization: 500}
+	sample = prefixPool.NewUtilizationSample(sampledAt)
+	require.EqualValues(t, 2, sample.PrefixPoolID)
+	require.Zero(t, sample.AddressPoolID)
+	require.EqualValues(t, 500, sample.PdUtilization)
+	require.EqualValues(t, 500, sample.PdUtilizationMax)
+	require.Zero(t, sample.AddrUtilization)
+}
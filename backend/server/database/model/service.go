@@ -80,6 +80,7 @@ type BaseHAService struct {
 	ServiceID                   int64
 	HAType                      HAType
 	HAMode                      HAMode
+	Relationship                string `pg:",use_zero"`
 	PrimaryID                   int64
 	SecondaryID                 int64
 	BackupID                    []int64 `pg:",array"`
@@ -447,6 +448,35 @@ func (s Service) GetDaemonHAState(daemonID int64) HAState {
 	return HAStateNone
 }
 
+// Returns the ID of the given daemon's partner in the HA service, i.e. the
+// secondary or standby server for the primary server and vice versa. It
+// returns zero if the daemon is not a primary or secondary server, or the
+// partner is not known (e.g., in the passive-backup mode).
+func (s Service) GetHAPartnerID(daemonID int64) int64 {
+	if s.HAService == nil || daemonID == 0 {
+		return 0
+	}
+	switch daemonID {
+	case s.HAService.PrimaryID:
+		return s.HAService.SecondaryID
+	case s.HAService.SecondaryID:
+		return s.HAService.PrimaryID
+	default:
+		return 0
+	}
+}
+
+// Returns the daemon belonging to the service by ID or nil if there is
+// no such daemon.
+func (s Service) GetDaemonByID(daemonID int64) *Daemon {
+	for _, daemon := range s.Daemons {
+		if daemon.ID == daemonID {
+			return daemon
+		}
+	}
+	return nil
+}
+
 // Returns last failover time of the given daemon's partner, i.e. the
 // time when the given daemon was considered offline for the last time
 // by the HA peer. The partner may have crashed but it may also be
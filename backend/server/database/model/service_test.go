@@ -300,6 +300,7 @@ func TestUpdateBaseHAService(t *testing.T) {
 	// Modify HA information.
 	service := services[1].HAService
 	service.SecondaryLastState = "load-balancing"
+	service.Relationship = "server1,server2"
 	err := UpdateBaseHAService(db, service)
 	require.NoError(t, err)
 
@@ -309,6 +310,7 @@ func TestUpdateBaseHAService(t *testing.T) {
 	require.NotNil(t, returned)
 	require.NotNil(t, returned.HAService)
 	require.Equal(t, service.SecondaryLastState, returned.HAService.SecondaryLastState)
+	require.Equal(t, "server1,server2", returned.HAService.Relationship)
 }
 
 // Test that the entire service information can be updated.
@@ -706,6 +708,43 @@ func TestGetDaemonHAState(t *testing.T) {
 	require.Empty(t, service.GetDaemonHAState(1))
 }
 
+// Test that the HA partner of the daemon is returned correctly.
+func TestGetHAPartnerID(t *testing.T) {
+	service := Service{}
+	require.Zero(t, service.GetHAPartnerID(1))
+
+	service.HAService = &BaseHAService{
+		HAType:      "dhcp4",
+		PrimaryID:   1,
+		SecondaryID: 2,
+		BackupID:    []int64{3},
+	}
+	require.EqualValues(t, 2, service.GetHAPartnerID(1))
+	require.EqualValues(t, 1, service.GetHAPartnerID(2))
+	require.Zero(t, service.GetHAPartnerID(3))
+	require.Zero(t, service.GetHAPartnerID(0))
+
+	// Passive-backup mode has no secondary server.
+	service.HAService.SecondaryID = 0
+	require.Zero(t, service.GetHAPartnerID(1))
+}
+
+// Test that the daemon belonging to the service is returned by ID.
+func TestGetServiceDaemonByID(t *testing.T) {
+	service := Service{
+		BaseService: BaseService{
+			Daemons: []*Daemon{
+				{ID: 1, Name: "dhcp4"},
+				{ID: 2, Name: "dhcp4"},
+			},
+		},
+	}
+	daemon := service.GetDaemonByID(2)
+	require.NotNil(t, daemon)
+	require.EqualValues(t, 2, daemon.ID)
+	require.Nil(t, service.GetDaemonByID(3))
+}
+
 // Test that the partner's failure time is returned correctly.
 func TestGetPartnerHAFailureTime(t *testing.T) {
 	// If this is not HA service, the time returned should be zero.
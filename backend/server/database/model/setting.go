@@ -78,6 +78,16 @@ func InitializeSettings(db *pg.DB, initialPullerInterval int64) error {
 			ValType: SettingValTypeInt,
 			Value:   mediumInterval,
 		},
+		{
+			Name:    "bind9_zone_drift_checker_interval", // in seconds
+			ValType: SettingValTypeInt,
+			Value:   longInterval,
+		},
+		{
+			Name:    "bind9_zone_lag_threshold", // in seconds
+			ValType: SettingValTypeInt,
+			Value:   "900",
+		},
 		{
 			Name:    "grafana_url",
 			ValType: SettingValTypeStr,
@@ -93,6 +103,116 @@ func InitializeSettings(db *pg.DB, initialPullerInterval int64) error {
 			ValType: SettingValTypeInt,
 			Value:   shortInterval, // in seconds
 		},
+		{
+			Name:    "audit_log_retention_days", // 0 means forever
+			ValType: SettingValTypeInt,
+			Value:   "365",
+		},
+		{
+			Name:    "event_retention_days", // 0 means forever
+			ValType: SettingValTypeInt,
+			Value:   "365",
+		},
+		{
+			Name:    "event_retention_max_info", // 0 means no limit
+			ValType: SettingValTypeInt,
+			Value:   "0",
+		},
+		{
+			Name:    "event_retention_max_warning", // 0 means no limit
+			ValType: SettingValTypeInt,
+			Value:   "0",
+		},
+		{
+			Name:    "event_retention_max_error", // 0 means no limit
+			ValType: SettingValTypeInt,
+			Value:   "0",
+		},
+		{
+			Name:    "utilization_forecast_horizon_days", // 0 disables the events
+			ValType: SettingValTypeInt,
+			Value:   "30",
+		},
+		{
+			Name:    "utilization_forecast_method", // linear or seasonal
+			ValType: SettingValTypeStr,
+			Value:   "linear",
+		},
+		{
+			Name:    "password_min_length", // 0 means no limit
+			ValType: SettingValTypeInt,
+			Value:   "0",
+		},
+		{
+			Name:    "password_require_mixed_case",
+			ValType: SettingValTypeBool,
+			Value:   "false",
+		},
+		{
+			Name:    "password_require_digit",
+			ValType: SettingValTypeBool,
+			Value:   "false",
+		},
+		{
+			Name:    "password_require_special_character",
+			ValType: SettingValTypeBool,
+			Value:   "false",
+		},
+		{
+			Name:    "password_history_count", // 0 allows reusing passwords
+			ValType: SettingValTypeInt,
+			Value:   "0",
+		},
+		{
+			Name:    "password_expiration_days", // 0 means never
+			ValType: SettingValTypeInt,
+			Value:   "0",
+		},
+		{
+			Name:    "password_change_on_first_login",
+			ValType: SettingValTypeBool,
+			Value:   "false",
+		},
+		{
+			Name:    "account_lockout_threshold", // 0 disables the lockout
+			ValType: SettingValTypeInt,
+			Value:   "5",
+		},
+		{
+			Name:    "account_lockout_duration", // in seconds
+			ValType: SettingValTypeInt,
+			Value:   "300",
+		},
+		{
+			Name:    "smtp_host", // empty disables the email notifications
+			ValType: SettingValTypeStr,
+			Value:   "",
+		},
+		{
+			Name:    "smtp_port",
+			ValType: SettingValTypeInt,
+			Value:   "25",
+		},
+		{
+			Name:    "smtp_security", // none, starttls or tls
+			ValType: SettingValTypeStr,
+			Value:   "starttls",
+		},
+		{
+			Name:    "smtp_username", // empty disables the authentication
+			ValType: SettingValTypeStr,
+			Value:   "",
+		},
+		{
+			Name:    "smtp_password",
+			ValType: SettingValTypePasswd,
+			Value:   "",
+		},
+		{
+			Name:    "smtp_sender",
+			ValType: SettingValTypeStr,
+			Value:   "stork@localhost",
+		},
 	}
 
 	// Check if there are new settings vs existing ones. Add new ones to DB.
@@ -49,6 +49,46 @@ func TestInitializeSettings(t *testing.T) {
 	require.NoError(t, err)
 	require.EqualValues(t, 30, val)
 
+	val, err = GetSettingInt(db, "bind9_zone_drift_checker_interval")
+	require.NoError(t, err)
+	require.EqualValues(t, 60, val)
+
+	val, err = GetSettingInt(db, "bind9_zone_lag_threshold")
+	require.NoError(t, err)
+	require.EqualValues(t, 900, val)
+
+	val, err = GetSettingInt(db, "audit_log_retention_days")
+	require.NoError(t, err)
+	require.EqualValues(t, 365, val)
+
+	val, err = GetSettingInt(db, "event_retention_days")
+	require.NoError(t, err)
+	require.EqualValues(t, 365, val)
+
+	val, err = GetSettingInt(db, "event_retention_max_info")
+	require.NoError(t, err)
+	require.Zero(t, val)
+
+	val, err = GetSettingInt(db, "utilization_forecast_horizon_days")
+	require.NoError(t, err)
+	require.EqualValues(t, 30, val)
+
+	method, err := GetSettingStr(db, "utilization_forecast_method")
+	require.NoError(t, err)
+	require.Equal(t, "linear", method)
+
+	val, err = GetSettingInt(db, "account_lockout_threshold")
+	require.NoError(t, err)
+	require.EqualValues(t, 5, val)
+
+	boolVal, err := GetSettingBool(db, "password_change_on_first_login")
+	require.NoError(t, err)
+	require.False(t, boolVal)
+
+	strVal, err := GetSettingStr(db, "smtp_security")
+	require.NoError(t, err)
+	require.Equal(t, "starttls", strVal)
+
 	// change the setting
 	err = SetSettingInt(db, "kea_stats_puller_interval", 123)
 	require.NoError(t, err)
@@ -33,6 +33,11 @@ type SharedNetwork struct {
 	PdUtilization    int16
 	Stats            SubnetStats
 	StatsCollectedAt time.Time
+
+	// Forecasted times when the addresses and delegated prefixes are
+	// exhausted. They are zero if the utilization doesn't grow.
+	AddrExhaustionAt time.Time
+	PdExhaustionAt   time.Time
 }
 
 // This structure holds shared network information retrieved from an app.
@@ -145,9 +150,10 @@ func AddLocalSharedNetworks(dbi dbops.DBI, network *SharedNetwork) error {
 }
 
 // Updates shared network in the database in a transaction. It neither adds
-// nor modifies associations with the subnets it contains.
+// nor modifies associations with the subnets it contains. The exhaustion
+// forecasts are not updated.
 func updateSharedNetwork(tx *pg.Tx, network *SharedNetwork) error {
-	result, err := tx.Model(network).WherePK().ExcludeColumn("created_at").Update()
+	result, err := tx.Model(network).WherePK().ExcludeColumn("created_at", "addr_exhaustion_at", "pd_exhaustion_at").Update()
 	if err != nil {
 		err = pkgerrors.Wrapf(err, "problem updating the shared network with ID %d", network.ID)
 	} else if result.RowsAffected() <= 0 {
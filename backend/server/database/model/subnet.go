@@ -166,6 +166,11 @@ type Subnet struct {
 	PdUtilization    int16
 	Stats            SubnetStats
 	StatsCollectedAt time.Time
+
+	// Forecasted times when the addresses and delegated prefixes are
+	// exhausted. They are zero if the utilization doesn't grow.
+	AddrExhaustionAt time.Time
+	PdExhaustionAt   time.Time
 }
 
 // Returns local subnet id for the specified daemon.
@@ -356,10 +361,11 @@ func addSubnet(tx *pg.Tx, subnet *Subnet) (err error) {
 	return nil
 }
 
-// Updates a subnet in the database within a transaction.
+// Updates a subnet in the database within a transaction. The exhaustion
+// forecasts are not updated.
 func updateSubnet(dbi dbops.DBI, subnet *Subnet) (err error) {
 	// Update the subnet first.
-	_, err = dbi.Model(subnet).WherePK().ExcludeColumn("created_at").Update()
+	_, err = dbi.Model(subnet).WherePK().ExcludeColumn("created_at", "addr_exhaustion_at", "pd_exhaustion_at").Update()
 
 	if err != nil {
 		err = pkgerrors.Wrapf(err, "problem updating subnet with prefix %s", subnet.Prefix)
@@ -868,7 +874,8 @@ func CommitNetworksIntoDB(dbi dbops.DBI, networks []SharedNetwork, subnets []Sub
 	return
 }
 
-// Fetch all local subnets for indicated app.
+// Fetch all local subnets for indicated app. The address and prefix pools
+// are fetched without the statistics and DHCP options.
 func GetAppLocalSubnets(dbi dbops.DBI, appID int64) ([]*LocalSubnet, error) {
 	subnets := []*LocalSubnet{}
 	q := dbi.Model(&subnets)
@@ -877,6 +884,15 @@ func GetAppLocalSubnets(dbi dbops.DBI, appID int64) ([]*LocalSubnet, error) {
 	q = q.Column("local_subnet.id", "local_subnet.daemon_id", "local_subnet.subnet_id", "local_subnet.local_subnet_id")
 	q = q.Relation("Subnet")
 	q = q.Relation("Daemon.App")
+	// the pools are ordered as in the configuration to match them with the pool statistics
+	q = q.Relation("AddressPools", func(q *orm.Query) (*orm.Query, error) {
+		return q.Column("address_pool.id", "address_pool.local_subnet_id", "address_pool.lower_bound", "address_pool.upper_bound").
+			Order("address_pool.id ASC"), nil
+	})
+	q = q.Relation("PrefixPools", func(q *orm.Query) (*orm.Query, error) {
+		return q.Column("prefix_pool.id", "prefix_pool.local_subnet_id", "prefix_pool.prefix", "prefix_pool.delegated_len").
+			Order("prefix_pool.id ASC"), nil
+	})
 	q = q.Where("d.app_id = ?", appID)
 
 	err := q.Select()
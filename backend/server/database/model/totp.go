@@ -0,0 +1,254 @@
+package dbmodel
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Number of the recovery codes generated when the user enrolls the TOTP
+// second factor.
+const RecoveryCodesCount = 10
+
+// Represents the TOTP second authentication factor of an internal user.
+// The secret becomes enabled when the user confirms the enrollment with a
+// valid code. The last used step is the time step of the last accepted code.
+// It is used to reject the replayed codes.
+type SystemUserTOTP struct {
+	tableName    struct{} `pg:"system_user_totp"` //nolint:unused
+	UserID       int      `pg:",pk"`
+	Secret       string
+	Enabled      bool  `pg:",use_zero"`
+	LastUsedStep int64 `pg:",use_zero"`
+	CreatedAt    time.Time
+}
+
+// Represents a one-time recovery code of a user. It can be used instead of
+// the TOTP code when the authenticator is not available. Only the hash of
+// the code is stored. The non-zero use time means that the code has been
+// used.
+type SystemUserRecoveryCode struct {
+	ID       int64
+	UserID   int
+	CodeHash string
+	UsedAt   time.Time
+}
+
+// Generates the recovery codes. It returns the codes presented to the user
+// and their hashes to be stored in the database.
+func GenerateRecoveryCodes(count int) (codes, hashes []string, err error) {
+	encoding := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < count; i++ {
+		b := make([]byte, 7)
+		if _, err = rand.Read(b); err != nil {
+			return nil, nil, pkgerrors.Wrap(err, "failed to generate random recovery code")
+		}
+		encoded := strings.ToLower(encoding.EncodeToString(b))
+		code := encoded[:5] + "-" + encoded[5:10]
+		codes = append(codes, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return codes, hashes, nil
+}
+
+// Returns the hash of the recovery code. The code is normalized, so the
+// case and the separators typed by the user are irrelevant. The codes
+// are random, so a single round of SHA-256 is sufficient to protect them.
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(code)
+	normalized = strings.NewReplacer("-", "", " ", "").Replace(normalized)
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// Returns the TOTP second factor of the user. It returns nil if the user
+// hasn't begun the enrollment.
+func GetUserTOTP(dbi dbops.DBI, userID int) (*SystemUserTOTP, error) {
+	totp := &SystemUserTOTP{}
+	err := dbi.Model(totp).Where("user_id = ?", userID).Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, pkgerrors.Wrapf(err, "problem getting TOTP of user with ID %d", userID)
+	}
+	return totp, nil
+}
+
+// Stores a new TOTP secret of the user beginning the enrollment. It
+// replaces the existing secret. The secret is disabled until the
+// enrollment is confirmed.
+func SetUserTOTPSecret(dbi dbops.DBI, userID int, secret string) error {
+	totp := &SystemUserTOTP{
+		UserID:    userID,
+		Secret:    secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	_, err := dbi.Model(totp).
+		OnConflict("(user_id) DO UPDATE").
+		Set("secret = EXCLUDED.secret").
+		Set("enabled = EXCLUDED.enabled").
+		Set("last_used_step = EXCLUDED.last_used_step").
+		Set("created_at = EXCLUDED.created_at").
+		Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem setting TOTP secret of user with ID %d", userID)
+	}
+	return nil
+}
+
+// Enables the TOTP of the user and replaces the recovery codes in a
+// transaction.
+func enableUserTOTP(tx *pg.Tx, userID int, lastUsedStep int64, codeHashes []string) error {
+	result, err := tx.Model((*SystemUserTOTP)(nil)).
+		Set("enabled = TRUE").
+		Set("last_used_step = ?", lastUsedStep).
+		Where("user_id = ?", userID).
+		Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem enabling TOTP of user with ID %d", userID)
+	} else if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "TOTP of user with ID %d does not exist", userID)
+	}
+
+	_, err = tx.Model((*SystemUserRecoveryCode)(nil)).Where("user_id = ?", userID).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting recovery codes of user with ID %d", userID)
+	}
+	if len(codeHashes) == 0 {
+		return nil
+	}
+	var codes []SystemUserRecoveryCode
+	for _, hash := range codeHashes {
+		codes = append(codes, SystemUserRecoveryCode{
+			UserID:   userID,
+			CodeHash: hash,
+		})
+	}
+	_, err = tx.Model(&codes).Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem inserting recovery codes of user with ID %d", userID)
+	}
+	return nil
+}
+
+// Enables the TOTP of the user confirming the enrollment. The last used
+// step is the step of the code confirming the enrollment. The existing
+// recovery codes are replaced with the new ones. It begins a new
+// transaction when dbi has a *pg.DB type or uses an existing transaction
+// when dbi has a *pg.Tx type.
+func EnableUserTOTP(dbi dbops.DBI, userID int, lastUsedStep int64, codeHashes []string) error {
+	if db, ok := dbi.(*pg.DB); ok {
+		return db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			return enableUserTOTP(tx, userID, lastUsedStep, codeHashes)
+		})
+	}
+	return enableUserTOTP(dbi.(*pg.Tx), userID, lastUsedStep, codeHashes)
+}
+
+// Sets the last used step of the user's TOTP. The step is updated only if
+// it is later than the stored one. It returns false if the step has been
+// already used, e.g. by a concurrent login.
+func UpdateUserTOTPLastUsedStep(dbi dbops.DBI, userID int, step int64) (bool, error) {
+	result, err := dbi.Model((*SystemUserTOTP)(nil)).
+		Set("last_used_step = ?", step).
+		Where("user_id = ?", userID).
+		Where("last_used_step < ?", step).
+		Update()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem updating TOTP last used step of user with ID %d", userID)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// Deletes the TOTP and the recovery codes of the user in a transaction.
+func deleteUserTOTP(tx *pg.Tx, userID int) error {
+	_, err := tx.Model((*SystemUserRecoveryCode)(nil)).Where("user_id = ?", userID).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting recovery codes of user with ID %d", userID)
+	}
+	_, err = tx.Model((*SystemUserTOTP)(nil)).Where("user_id = ?", userID).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting TOTP of user with ID %d", userID)
+	}
+	return nil
+}
+
+// Deletes the TOTP and the recovery codes of the user. It resets the second
+// factor, so the user has to enroll again. It begins a new transaction when
+// dbi has a *pg.DB type or uses an existing transaction when dbi has a
+// *pg.Tx type.
+func DeleteUserTOTP(dbi dbops.DBI, userID int) error {
+	if db, ok := dbi.(*pg.DB); ok {
+		return db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			return deleteUserTOTP(tx, userID)
+		})
+	}
+	return deleteUserTOTP(dbi.(*pg.Tx), userID)
+}
+
+// Marks the recovery code of the user as used. It returns false if the
+// code doesn't exist or has been already used.
+func UseRecoveryCode(dbi dbops.DBI, userID int, code string) (bool, error) {
+	result, err := dbi.Model((*SystemUserRecoveryCode)(nil)).
+		Set("used_at = ?", time.Now().UTC()).
+		Where("user_id = ?", userID).
+		Where("code_hash = ?", HashRecoveryCode(code)).
+		Where("used_at IS NULL").
+		Update()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem using recovery code of user with ID %d", userID)
+	}
+	return result.RowsAffected() > 0, nil
+}
+
+// Returns the number of the unused recovery codes of the user.
+func GetUnusedRecoveryCodesCount(dbi dbops.DBI, userID int) (int64, error) {
+	count, err := dbi.Model((*SystemUserRecoveryCode)(nil)).
+		Where("user_id = ?", userID).
+		Where("used_at IS NULL").
+		Count()
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "problem counting recovery codes of user with ID %d", userID)
+	}
+	return int64(count), nil
+}
+
+// Checks if any of the groups the user belongs to requires the TOTP second
+// factor.
+func IsUserTOTPRequired(dbi dbops.DBI, userID int) (bool, error) {
+	required, err := dbi.Model((*SystemGroup)(nil)).
+		Join("JOIN system_user_to_group AS ug ON ug.group_id = system_group.id").
+		Where("ug.user_id = ?", userID).
+		Where("system_group.totp_required").
+		Exists()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem checking if TOTP is required for user with ID %d", userID)
+	}
+	return required, nil
+}
+
+// Sets the flag indicating if the members of the group are required to use
+// the TOTP second factor. Unlike other group properties, it can be also
+// set for the predefined groups.
+func SetGroupTOTPRequired(dbi dbops.DBI, groupID int, required bool) error {
+	result, err := dbi.Model((*SystemGroup)(nil)).
+		Set("totp_required = ?", required).
+		Where("id = ?", groupID).
+		Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem updating TOTP requirement of group with ID %d", groupID)
+	} else if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "group with ID %d does not exist", groupID)
+	}
+	return nil
+}
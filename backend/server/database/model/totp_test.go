@@ -0,0 +1,174 @@
+package dbmodel
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the generated recovery codes are random and hashed.
+func TestGenerateRecoveryCodes(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes(RecoveryCodesCount)
+	require.NoError(t, err)
+	require.Len(t, codes, RecoveryCodesCount)
+	require.Len(t, hashes, RecoveryCodesCount)
+
+	unique := make(map[string]bool)
+	for i, code := range codes {
+		require.Len(t, code, 11)
+		require.EqualValues(t, '-', code[5])
+		require.Equal(t, HashRecoveryCode(code), hashes[i])
+		unique[code] = true
+	}
+	require.Len(t, unique, RecoveryCodesCount)
+}
+
+// Test that the recovery code hash ignores the case and separators.
+func TestHashRecoveryCode(t *testing.T) {
+	hash := HashRecoveryCode("abcde-fghij")
+	require.Equal(t, hash, HashRecoveryCode("ABCDEFGHIJ"))
+	require.Equal(t, hash, HashRecoveryCode("abcde fghij"))
+	require.NotEqual(t, hash, HashRecoveryCode("abcde-fghik"))
+}
+
+// Test the TOTP enrollment, confirmation and reset.
+func TestUserTOTP(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "alice",
+		Lastname: "Alice",
+		Name:     "Smith",
+	}
+	_, err := CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	// No enrollment yet.
+	totp, err := GetUserTOTP(db, user.ID)
+	require.NoError(t, err)
+	require.Nil(t, totp)
+
+	// Begin the enrollment.
+	err = SetUserTOTPSecret(db, user.ID, "first")
+	require.NoError(t, err)
+	totp, err = GetUserTOTP(db, user.ID)
+	require.NoError(t, err)
+	require.NotNil(t, totp)
+	require.Equal(t, "first", totp.Secret)
+	require.False(t, totp.Enabled)
+
+	// Confirm the enrollment.
+	codes, hashes, err := GenerateRecoveryCodes(3)
+	require.NoError(t, err)
+	err = EnableUserTOTP(db, user.ID, 100, hashes)
+	require.NoError(t, err)
+	totp, err = GetUserTOTP(db, user.ID)
+	require.NoError(t, err)
+	require.True(t, totp.Enabled)
+	require.EqualValues(t, 100, totp.LastUsedStep)
+
+	count, err := GetUnusedRecoveryCodesCount(db, user.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, count)
+
+	// The last used step can only be increased.
+	updated, err := UpdateUserTOTPLastUsedStep(db, user.ID, 100)
+	require.NoError(t, err)
+	require.False(t, updated)
+	updated, err = UpdateUserTOTPLastUsedStep(db, user.ID, 101)
+	require.NoError(t, err)
+	require.True(t, updated)
+
+	// The recovery code can be used once.
+	used, err := UseRecoveryCode(db, user.ID, strings.ToUpper(codes[0]))
+	require.NoError(t, err)
+	require.True(t, used)
+	used, err = UseRecoveryCode(db, user.ID, codes[0])
+	require.NoError(t, err)
+	require.False(t, used)
+	used, err = UseRecoveryCode(db, user.ID, "aaaaa-bbbbb")
+	require.NoError(t, err)
+	require.False(t, used)
+
+	count, err = GetUnusedRecoveryCodesCount(db, user.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, count)
+
+	// Beginning the enrollment again disables the TOTP.
+	err = SetUserTOTPSecret(db, user.ID, "second")
+	require.NoError(t, err)
+	totp, err = GetUserTOTP(db, user.ID)
+	require.NoError(t, err)
+	require.Equal(t, "second", totp.Secret)
+	require.False(t, totp.Enabled)
+	require.Zero(t, totp.LastUsedStep)
+
+	// Reset the second factor.
+	err = DeleteUserTOTP(db, user.ID)
+	require.NoError(t, err)
+	totp, err = GetUserTOTP(db, user.ID)
+	require.NoError(t, err)
+	require.Nil(t, totp)
+	count, err = GetUnusedRecoveryCodesCount(db, user.ID)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+// Test that enabling TOTP fails when the enrollment hasn't begun.
+func TestEnableUserTOTPNotEnrolled(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "alice",
+		Lastname: "Alice",
+		Name:     "Smith",
+	}
+	_, err := CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	err = EnableUserTOTP(db, user.ID, 1, nil)
+	require.ErrorIs(t, err, ErrNotExists)
+}
+
+// Test that the TOTP requirement is determined by the user groups.
+func TestIsUserTOTPRequired(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &SystemUser{
+		Login:    "alice",
+		Lastname: "Alice",
+		Name:     "Smith",
+		Groups:   []*SystemGroup{{ID: AdminGroupID}},
+	}
+	_, err := CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	required, err := IsUserTOTPRequired(db, user.ID)
+	require.NoError(t, err)
+	require.False(t, required)
+
+	// The requirement can be set for the predefined groups.
+	err = SetGroupTOTPRequired(db, SuperAdminGroupID, true)
+	require.NoError(t, err)
+	required, err = IsUserTOTPRequired(db, user.ID)
+	require.NoError(t, err)
+	require.False(t, required)
+
+	err = SetGroupTOTPRequired(db, AdminGroupID, true)
+	require.NoError(t, err)
+	required, err = IsUserTOTPRequired(db, user.ID)
+	require.NoError(t, err)
+	require.True(t, required)
+
+	group, err := GetGroupByID(db, AdminGroupID)
+	require.NoError(t, err)
+	require.True(t, group.TOTPRequired)
+
+	err = SetGroupTOTPRequired(db, 12345, true)
+	require.ErrorIs(t, err, ErrNotExists)
+}
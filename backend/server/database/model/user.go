@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/go-pg/pg/v10/orm"
@@ -34,9 +35,17 @@ type SystemUser struct {
 }
 
 // Represents a user password entry in system_user_password table in the database.
+// The change required flag indicates that the user has to change the password
+// on the next login, e.g., because it has been set by the administrator.
+//
+// Note that the trigger hashing the password also runs when the other columns
+// are updated. Therefore, the password must always be updated together with
+// them. The empty password keeps the existing hash.
 type SystemUserPassword struct {
-	ID       int
-	Password string `pg:"password_hash"`
+	ID             int
+	Password       string `pg:"password_hash"`
+	ChangedAt      time.Time
+	ChangeRequired bool `pg:",use_zero"`
 }
 
 // Represents an association table between the system user and group tables.
@@ -102,6 +111,21 @@ func CreateUser(db *pg.DB, user *SystemUser) (conflict bool, err error) {
 // conflict value indicates if the created user information is in conflict
 // with some existing user in the database, e.g. duplicated login or email.
 func CreateUserWithPassword(db *pg.DB, user *SystemUser, password string) (conflict bool, err error) {
+	return createUserWithPassword(db, user, password, false)
+}
+
+// Creates new user in the database with a temporary password. The user has
+// to change the password on the first login. The returned conflict value
+// indicates if the created user information is in conflict with some
+// existing user in the database, e.g. duplicated login or email.
+func CreateUserWithTemporaryPassword(db *pg.DB, user *SystemUser, password string) (conflict bool, err error) {
+	return createUserWithPassword(db, user, password, true)
+}
+
+// Internal function creating the user with a given password. The change
+// required flag indicates if the user has to change the password on the
+// first login.
+func createUserWithPassword(db *pg.DB, user *SystemUser, password string, changeRequired bool) (conflict bool, err error) {
 	err = db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
 		conflict, err = createUser(tx, user)
 		if err != nil {
@@ -109,12 +133,17 @@ func CreateUserWithPassword(db *pg.DB, user *SystemUser, password string) (confl
 		}
 
 		password := &SystemUserPassword{
-			ID:       user.ID,
-			Password: password,
+			ID:             user.ID,
+			Password:       password,
+			ChangedAt:      time.Now().UTC(),
+			ChangeRequired: changeRequired,
 		}
 
-		_, err := tx.Model(password).Insert()
-		return pkgerrors.Wrapf(err, "unable to insert a password for the created user %s", user.Identity())
+		_, err := tx.Model(password).Returning("password_hash").Insert()
+		if err != nil {
+			return pkgerrors.Wrapf(err, "unable to insert a password for the created user %s", user.Identity())
+		}
+		return addPasswordHistory(tx, user.ID, password.Password)
 	})
 	return
 }
@@ -208,21 +237,49 @@ func DeleteUser(db *pg.DB, user *SystemUser) (err error) {
 	return err
 }
 
-// Sets new password for the given user id.
+// Sets new password for the given user id. The empty password leaves the
+// current password unchanged.
 func SetPassword(db *pg.DB, id int, password string) (err error) {
-	userPassword := SystemUserPassword{
-		ID:       id,
-		Password: password,
+	return setPassword(db, id, password, false)
+}
+
+// Sets new temporary password for the given user id. The user has to change
+// it on the next login. It is typically used when the administrator sets
+// the password for another user. The empty password leaves the current
+// password unchanged.
+func SetTemporaryPassword(db *pg.DB, id int, password string) (err error) {
+	return setPassword(db, id, password, true)
+}
+
+// Internal function setting the password, recording it in the password
+// history and updating the password change time.
+func setPassword(db *pg.DB, id int, password string, changeRequired bool) (err error) {
+	if password == "" {
+		return nil
 	}
 
-	result, err := db.Model(&userPassword).Column("password_hash").WherePK().Update()
-	if err != nil {
-		err = pkgerrors.Wrapf(err, "database operation error while trying to set new password for user ID %d",
-			id)
-	} else if result.RowsAffected() == 0 {
-		err = pkgerrors.Wrapf(ErrNotExists, "failed to update password for non-existent user with ID %d", id)
+	userPassword := SystemUserPassword{
+		ID:             id,
+		Password:       password,
+		ChangedAt:      time.Now().UTC(),
+		ChangeRequired: changeRequired,
 	}
 
+	err = db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		result, err := tx.Model(&userPassword).
+			Column("password_hash", "changed_at", "change_required").
+			WherePK().
+			Returning("password_hash").
+			Update()
+		if err != nil {
+			return pkgerrors.Wrapf(err, "database operation error while trying to set new password for user ID %d",
+				id)
+		} else if result.RowsAffected() == 0 {
+			return pkgerrors.Wrapf(ErrNotExists, "failed to update password for non-existent user with ID %d", id)
+		}
+		return addPasswordHistory(tx, id, userPassword.Password)
+	})
+
 	return err
 }
 
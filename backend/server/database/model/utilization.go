@@ -0,0 +1,271 @@
+package dbmodel
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/go-pg/pg/v10/orm"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Resolution of the utilization samples.
+type UtilizationResolution string
+
+// Supported resolutions of the utilization samples. The raw samples are
+// recorded on each statistics pull. The other samples hold the average and
+// maximum utilization in 5-minute and hourly periods.
+const (
+	UtilizationResolutionRaw    UtilizationResolution = "raw"
+	UtilizationResolution5Min   UtilizationResolution = "5m"
+	UtilizationResolutionHourly UtilizationResolution = "1h"
+)
+
+// Returns the length of the period covered by a single sample. It returns
+// zero for the raw samples.
+func (r UtilizationResolution) GetPeriod() time.Duration {
+	switch r {
+	case UtilizationResolution5Min:
+		return 5 * time.Minute
+	case UtilizationResolutionHourly:
+		return time.Hour
+	default:
+		return 0
+	}
+}
+
+// Returns how long the samples of the resolution are kept.
+func (r UtilizationResolution) GetRetention() time.Duration {
+	switch r {
+	case UtilizationResolutionRaw:
+		return 24 * time.Hour
+	case UtilizationResolution5Min:
+		return 30 * 24 * time.Hour
+	default:
+		return 365 * 24 * time.Hour
+	}
+}
+
+// Checks if the resolution is supported.
+func (r UtilizationResolution) IsValid() bool {
+	switch r {
+	case UtilizationResolutionRaw, UtilizationResolution5Min, UtilizationResolutionHourly:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the finest resolution of the samples that are still kept for
+// the specified start of the time range.
+func GetUtilizationResolution(from, now time.Time) UtilizationResolution {
+	for _, resolution := range []UtilizationResolution{UtilizationResolutionRaw, UtilizationResolution5Min} {
+		if !from.Before(now.Add(-resolution.GetRetention())) {
+			return resolution
+		}
+	}
+	return UtilizationResolutionHourly
+}
+
+// Type of the object the utilization samples belong to.
+type UtilizationObjectType string
+
+// Objects the utilization history is kept for.
+const (
+	UtilizationObjectSubnet        UtilizationObjectType = "subnet"
+	UtilizationObjectSharedNetwork UtilizationObjectType = "shared-network"
+	UtilizationObjectDaemon        UtilizationObjectType = "daemon"
+	UtilizationObjectAddressPool   UtilizationObjectType = "address-pool"
+	UtilizationObjectPrefixPool    UtilizationObjectType = "prefix-pool"
+)
+
+// Names of the columns referencing the objects the samples belong to.
+var utilizationObjectColumns = map[UtilizationObjectType]string{
+	UtilizationObjectSubnet:        "subnet_id",
+	UtilizationObjectSharedNetwork: "shared_network_id",
+	UtilizationObjectDaemon:        "daemon_id",
+	UtilizationObjectAddressPool:   "address_pool_id",
+	UtilizationObjectPrefixPool:    "prefix_pool_id",
+}
+
+// Represents the address and delegated prefix utilization of a subnet, a
+// shared network, a daemon or a pool at the specified time. Exactly one of
+// the subnet, shared network, daemon and pool IDs is set. The address pool
+// samples hold only the address utilization and the prefix pool samples
+// hold only the delegated prefix utilization. The utilization is
+// expressed in tenths of a percent. The raw samples hold the utilization
+// at the time of the statistics pull and their maximum values are equal
+// to the utilization. The other samples hold the average and maximum
+// utilization in the period starting at the sample time.
+type UtilizationSample struct {
+	ID                 int64
+	SubnetID           int64
+	SharedNetworkID    int64
+	DaemonID           int64
+	AddressPoolID      int64
+	PrefixPoolID       int64
+	Resolution         UtilizationResolution
+	SampledAt          time.Time
+	AddrUtilization    int16 `pg:",use_zero"`
+	PdUtilization      int16 `pg:",use_zero"`
+	AddrUtilizationMax int16 `pg:",use_zero"`
+	PdUtilizationMax   int16 `pg:",use_zero"`
+}
+
+// Creates the raw sample from the utilization statistics. The caller sets
+// the ID of the object the sample belongs to.
+func NewUtilizationSample(statistics utilizationStats, sampledAt time.Time) UtilizationSample {
+	addrUtilization := int16(statistics.GetAddressUtilization() * 1000)
+	pdUtilization := int16(statistics.GetDelegatedPrefixUtilization() * 1000)
+	return UtilizationSample{
+		Resolution:         UtilizationResolutionRaw,
+		SampledAt:          sampledAt,
+		AddrUtilization:    addrUtilization,
+		PdUtilization:      pdUtilization,
+		AddrUtilizationMax: addrUtilization,
+		PdUtilizationMax:   pdUtilization,
+	}
+}
+
+// Inserts the utilization samples into the database. The samples of the
+// objects already having the samples of the same resolution at the same
+// time are skipped.
+func AddUtilizationSamples(dbi dbops.DBI, samples []UtilizationSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	_, err := dbi.Model(&samples).OnConflict("DO NOTHING").Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem inserting %d utilization samples", len(samples))
+	}
+	return nil
+}
+
+// Returns the utilization samples of the specified resolution belonging to
+// the object. The samples taken in the [from, to) time range are returned
+// ordered by the sample time.
+func GetUtilizationSamples(dbi dbops.DBI, objectType UtilizationObjectType, objectID int64, resolution UtilizationResolution, from, to time.Time) ([]UtilizationSample, error) {
+	column, ok := utilizationObjectColumns[objectType]
+	if !ok {
+		return nil, pkgerrors.Errorf("unsupported utilization object type %s", objectType)
+	}
+	samples := []UtilizationSample{}
+	err := dbi.Model(&samples).
+		Where("? = ?", pg.Ident(column), objectID).
+		Where("resolution = ?", resolution).
+		Where("sampled_at >= ?", from).
+		Where("sampled_at < ?", to).
+		OrderExpr("sampled_at ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting utilization samples of %s %d", objectType, objectID)
+	}
+	return samples, nil
+}
+
+// Aggregates the samples of the source resolution into the samples of the
+// target resolution. Only the periods that ended before the specified time
+// and are newer than the latest sample of the target resolution are
+// aggregated. It returns the number of the inserted samples.
+func rollUpUtilizationSamples(dbi dbops.DBI, source, target UtilizationResolution, now time.Time) (int64, error) {
+	period := target.GetPeriod()
+	var latest pg.NullTime
+	_, err := dbi.QueryOne(pg.Scan(&latest), "SELECT max(sampled_at) FROM utilization_sample WHERE resolution = ?", target)
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "problem getting latest %s utilization sample", target)
+	}
+	var from time.Time
+	if !latest.IsZero() {
+		from = latest.Add(period)
+	}
+	to := now.Truncate(period)
+	if !from.Before(to) {
+		return 0, nil
+	}
+	result, err := dbi.Exec(`
+        INSERT INTO utilization_sample (subnet_id, shared_network_id, daemon_id, address_pool_id, prefix_pool_id,
+            resolution, sampled_at, addr_utilization, pd_utilization, addr_utilization_max, pd_utilization_max)
+        SELECT subnet_id, shared_network_id, daemon_id, address_pool_id, prefix_pool_id, ?0,
+            to_timestamp(floor(extract(epoch FROM sampled_at) / ?1) * ?1) AT TIME ZONE 'UTC' AS period,
+            round(avg(addr_utilization)), round(avg(pd_utilization)),
+            max(addr_utilization_max), max(pd_utilization_max)
+        FROM utilization_sample
+        WHERE resolution = ?2 AND sampled_at >= ?3 AND sampled_at < ?4
+        GROUP BY subnet_id, shared_network_id, daemon_id, address_pool_id, prefix_pool_id, period
+        ON CONFLICT DO NOTHING`,
+		target, int64(period.Seconds()), source, from, to)
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "problem rolling up %s utilization samples to %s", source, target)
+	}
+	return int64(result.RowsAffected()), nil
+}
+
+// Aggregates the raw samples into the 5-minute samples and the 5-minute
+// samples into the hourly samples, and deletes the samples older than
+// their retention period. The now value is the current time.
+func RollUpUtilizationSamples(dbi dbops.DBI, now time.Time) error {
+	if _, err := rollUpUtilizationSamples(dbi, UtilizationResolutionRaw, UtilizationResolution5Min, now); err != nil {
+		return err
+	}
+	if _, err := rollUpUtilizationSamples(dbi, UtilizationResolution5Min, UtilizationResolutionHourly, now); err != nil {
+		return err
+	}
+	for _, resolution := range []UtilizationResolution{UtilizationResolutionRaw, UtilizationResolution5Min, UtilizationResolutionHourly} {
+		_, err := dbi.Model((*UtilizationSample)(nil)).
+			Where("resolution = ?", resolution).
+			Where("sampled_at < ?", now.Add(-resolution.GetRetention())).
+			Delete()
+		if err != nil {
+			return pkgerrors.Wrapf(err, "problem deleting old %s utilization samples", resolution)
+		}
+	}
+	return nil
+}
+
+// Returns the utilization samples of the specified resolution taken at or
+// after the specified time for all objects. The samples are ordered by
+// the sample time.
+func GetUtilizationSamplesSince(dbi dbops.DBI, resolution UtilizationResolution, from time.Time) ([]UtilizationSample, error) {
+	samples := []UtilizationSample{}
+	err := dbi.Model(&samples).
+		Where("resolution = ?", resolution).
+		Where("sampled_at >= ?", from).
+		OrderExpr("sampled_at ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting %s utilization samples", resolution)
+	}
+	return samples, nil
+}
+
+// Sets the forecasted times when the addresses and delegated prefixes of
+// the subnet, shared network or pool are exhausted. The zero times clear
+// the forecasts. The address pools take only the address forecast and
+// the prefix pools take only the delegated prefix forecast. The daemons
+// have no forecasts.
+func SetExhaustionForecast(dbi dbops.DBI, objectType UtilizationObjectType, objectID int64, addrExhaustionAt, pdExhaustionAt time.Time) error {
+	var q *orm.Query
+	switch objectType {
+	case UtilizationObjectSubnet:
+		q = dbi.Model((*Subnet)(nil)).
+			Set("addr_exhaustion_at = ?", pg.NullTime{Time: addrExhaustionAt}).
+			Set("pd_exhaustion_at = ?", pg.NullTime{Time: pdExhaustionAt})
+	case UtilizationObjectSharedNetwork:
+		q = dbi.Model((*SharedNetwork)(nil)).
+			Set("addr_exhaustion_at = ?", pg.NullTime{Time: addrExhaustionAt}).
+			Set("pd_exhaustion_at = ?", pg.NullTime{Time: pdExhaustionAt})
+	case UtilizationObjectAddressPool:
+		q = dbi.Model((*AddressPool)(nil)).
+			Set("exhaustion_at = ?", pg.NullTime{Time: addrExhaustionAt})
+	case UtilizationObjectPrefixPool:
+		q = dbi.Model((*PrefixPool)(nil)).
+			Set("exhaustion_at = ?", pg.NullTime{Time: pdExhaustionAt})
+	default:
+		return pkgerrors.Errorf("unsupported exhaustion forecast object type %s", objectType)
+	}
+	_, err := q.Where("id = ?", objectID).Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem setting exhaustion forecast of %s %d", objectType, objectID)
+	}
+	return nil
+}
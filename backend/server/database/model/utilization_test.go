@@ -0,0 +1,267 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test the periods and retention of the utilization sample resolutions.
+func TestUtilizationResolution(t *testing.T) {
+	require.Zero(t, UtilizationResolutionRaw.GetPeriod())
+	require.Equal(t, 5*time.Minute, UtilizationResolution5Min.GetPeriod())
+	require.Equal(t, time.Hour, UtilizationResolutionHourly.GetPeriod())
+
+	require.Equal(t, 24*time.Hour, UtilizationResolutionRaw.GetRetention())
+	require.Equal(t, 30*24*time.Hour, UtilizationResolution5Min.GetRetention())
+	require.Equal(t, 365*24*time.Hour, UtilizationResolutionHourly.GetRetention())
+
+	require.True(t, UtilizationResolutionHourly.IsValid())
+	require.False(t, UtilizationResolution("1d").IsValid())
+}
+
+// Test that the finest resolution still kept for the time range start is
+// selected.
+func TestGetUtilizationResolution(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, UtilizationResolutionRaw, GetUtilizationResolution(now.Add(-time.Hour), now))
+	require.Equal(t, UtilizationResolutionRaw, GetUtilizationResolution(now.Add(-24*time.Hour), now))
+	require.Equal(t, UtilizationResolution5Min, GetUtilizationResolution(now.Add(-25*time.Hour), now))
+	require.Equal(t, UtilizationResolutionHourly, GetUtilizationResolution(now.AddDate(0, -2, 0), now))
+}
+
+// Test that the raw sample is created from the utilization statistics.
+func TestNewUtilizationSample(t *testing.T) {
+	sampledAt := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	sample := NewUtilizationSample(newUtilizationStatsMock(0.25, 0.5, nil), sampledAt)
+	require.Equal(t, UtilizationResolutionRaw, sample.Resolution)
+	require.Equal(t, sampledAt, sample.SampledAt)
+	require.EqualValues(t, 250, sample.AddrUtilization)
+	require.EqualValues(t, 500, sample.PdUtilization)
+	require.EqualValues(t, 250, sample.AddrUtilizationMax)
+	require.EqualValues(t, 500, sample.PdUtilizationMax)
+}
+
+// Test that the utilization samples are inserted, rolled up, fetched and
+// deleted after their retention period.
+func TestAddRollUpGetUtilizationSamples(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	require.NoError(t, AddSubnet(db, subnet))
+	network := &SharedNetwork{
+		Name:   "frog",
+		Family: 4,
+	}
+	require.NoError(t, AddSharedNetwork(db, network))
+
+	start := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	var samples []UtilizationSample
+	for i := 0; i < 12; i++ {
+		sample := NewUtilizationSample(newUtilizationStatsMock(0.1*float64(i%6), 0, nil), start.Add(time.Duration(i)*time.Minute))
+		sample.SubnetID = subnet.ID
+		samples = append(samples, sample)
+		sample.SubnetID = 0
+		sample.SharedNetworkID = network.ID
+		samples = append(samples, sample)
+	}
+	require.NoError(t, AddUtilizationSamples(db, samples))
+	// The duplicates are skipped.
+	require.NoError(t, AddUtilizationSamples(db, samples[:2]))
+	require.NoError(t, AddUtilizationSamples(db, nil))
+
+	returned, err := GetUtilizationSamples(db, UtilizationObjectSubnet, subnet.ID, UtilizationResolutionRaw, start, start.Add(5*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, returned, 5)
+	require.EqualValues(t, 400, returned[4].AddrUtilization)
+	require.Equal(t, start.Add(4*time.Minute), returned[4].SampledAt.UTC())
+
+	_, err = GetUtilizationSamples(db, "pool", subnet.ID, UtilizationResolutionRaw, start, start.Add(time.Hour))
+	require.Error(t, err)
+
+	// Only the complete periods are rolled up.
+	now := start.Add(11 * time.Minute)
+	require.NoError(t, RollUpUtilizationSamples(db, now))
+	returned, err = GetUtilizationSamples(db, UtilizationObjectSubnet, subnet.ID, UtilizationResolution5Min, start, now)
+	require.NoError(t, err)
+	require.Len(t, returned, 2)
+	require.Equal(t, start, returned[0].SampledAt.UTC())
+	require.EqualValues(t, 200, returned[0].AddrUtilization)
+	require.EqualValues(t, 400, returned[0].AddrUtilizationMax)
+	require.Equal(t, start.Add(5*time.Minute), returned[1].SampledAt.UTC())
+	require.EqualValues(t, 220, returned[1].AddrUtilization)
+	require.EqualValues(t, 500, returned[1].AddrUtilizationMax)
+
+	returned, err = GetUtilizationSamples(db, UtilizationObjectSharedNetwork, network.ID, UtilizationResolution5Min, start, now)
+	require.NoError(t, err)
+	require.Len(t, returned, 2)
+
+	// The next periods are rolled up once they are complete.
+	now = start.Add(time.Hour)
+	require.NoError(t, RollUpUtilizationSamples(db, now))
+	returned, err = GetUtilizationSamples(db, UtilizationObjectSubnet, subnet.ID, UtilizationResolution5Min, start, now)
+	require.NoError(t, err)
+	require.Len(t, returned, 3)
+	require.EqualValues(t, 450, returned[2].AddrUtilization)
+
+	returned, err = GetUtilizationSamples(db, UtilizationObjectSubnet, subnet.ID, UtilizationResolutionHourly, start, now)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+	require.EqualValues(t, 290, returned[0].AddrUtilization)
+	require.EqualValues(t, 500, returned[0].AddrUtilizationMax)
+
+	// The raw samples are deleted after a day.
+	require.NoError(t, RollUpUtilizationSamples(db, start.Add(25*time.Hour)))
+	returned, err = GetUtilizationSamples(db, UtilizationObjectSubnet, subnet.ID, UtilizationResolutionRaw, start, now)
+	require.NoError(t, err)
+	require.Empty(t, returned)
+	returned, err = GetUtilizationSamples(db, UtilizationObjectSubnet, subnet.ID, UtilizationResolution5Min, start, now)
+	require.NoError(t, err)
+	require.Len(t, returned, 3)
+
+	// The samples are deleted with the subnet.
+	count, err := DeleteOrphanedSubnets(db)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+	returned, err = GetUtilizationSamples(db, UtilizationObjectSubnet, subnet.ID, UtilizationResolution5Min, start, now)
+	require.NoError(t, err)
+	require.Empty(t, returned)
+}
+
+// Test that the exhaustion forecasts of the subnets and shared networks
+// are set and cleared.
+func TestSetExhaustionForecast(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	require.NoError(t, AddSubnet(db, subnet))
+	network := &SharedNetwork{
+		Name:   "frog",
+		Family: 4,
+	}
+	require.NoError(t, AddSharedNetwork(db, network))
+
+	addrAt := time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC)
+	pdAt := addrAt.AddDate(0, 1, 0)
+	require.NoError(t, SetExhaustionForecast(db, UtilizationObjectSubnet, subnet.ID, addrAt, time.Time{}))
+	require.NoError(t, SetExhaustionForecast(db, UtilizationObjectSharedNetwork, network.ID, time.Time{}, pdAt))
+	require.Error(t, SetExhaustionForecast(db, UtilizationObjectDaemon, 1, addrAt, pdAt))
+
+	returnedSubnet, err := GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Equal(t, addrAt, returnedSubnet.AddrExhaustionAt.UTC())
+	require.Zero(t, returnedSubnet.PdExhaustionAt)
+
+	returnedNetwork, err := GetSharedNetwork(db, network.ID)
+	require.NoError(t, err)
+	require.Zero(t, returnedNetwork.AddrExhaustionAt)
+	require.Equal(t, pdAt, returnedNetwork.PdExhaustionAt.UTC())
+
+	// Updating the subnet doesn't clear the forecast.
+	returnedSubnet.ClientClass = "foo"
+	require.NoError(t, updateSubnet(db, returnedSubnet))
+	returnedSubnet, err = GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Equal(t, addrAt, returnedSubnet.AddrExhaustionAt.UTC())
+
+	// Clear the forecast.
+	require.NoError(t, SetExhaustionForecast(db, UtilizationObjectSubnet, subnet.ID, time.Time{}, time.Time{}))
+	returnedSubnet, err = GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Zero(t, returnedSubnet.AddrExhaustionAt)
+}
+
+// Test that the pool utilization samples are recorded and rolled up, and
+// the pool exhaustion forecasts are set and cleared.
+func TestPoolUtilizationSamplesAndForecast(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	machine := &Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	require.NoError(t, AddMachine(db, machine))
+	app := &App{
+		MachineID: machine.ID,
+		Type:      AppTypeKea,
+		Daemons: []*Daemon{
+			NewKeaDaemon(DaemonNameDHCPv6, true),
+		},
+	}
+	_, err := AddApp(db, app)
+	require.NoError(t, err)
+
+	subnet := &Subnet{
+		Prefix: "2001:db8:1::/64",
+		LocalSubnets: []*LocalSubnet{
+			{
+				DaemonID: app.Daemons[0].ID,
+				AddressPools: []AddressPool{
+					{LowerBound: "2001:db8:1::10", UpperBound: "2001:db8:1::1f", Utilization: 250},
+				},
+				PrefixPools: []PrefixPool{
+					{Prefix: "3000::/48", DelegatedLen: 64, Utilization: 500},
+				},
+			},
+		},
+	}
+	require.NoError(t, AddSubnet(db, subnet))
+	require.NoError(t, AddLocalSubnets(db, subnet))
+	addressPool := &subnet.LocalSubnets[0].AddressPools[0]
+	prefixPool := &subnet.LocalSubnets[0].PrefixPools[0]
+
+	start := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	var samples []UtilizationSample
+	for i := 0; i < 5; i++ {
+		samples = append(samples,
+			addressPool.NewUtilizationSample(start.Add(time.Duration(i)*time.Minute)),
+			prefixPool.NewUtilizationSample(start.Add(time.Duration(i)*time.Minute)))
+	}
+	require.NoError(t, AddUtilizationSamples(db, samples))
+	require.NoError(t, RollUpUtilizationSamples(db, start.Add(5*time.Minute)))
+
+	returned, err := GetUtilizationSamples(db, UtilizationObjectAddressPool, addressPool.ID, UtilizationResolution5Min, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+	require.EqualValues(t, 250, returned[0].AddrUtilization)
+	require.EqualValues(t, 250, returned[0].AddrUtilizationMax)
+	require.Zero(t, returned[0].PdUtilization)
+
+	returned, err = GetUtilizationSamples(db, UtilizationObjectPrefixPool, prefixPool.ID, UtilizationResolution5Min, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+	require.Zero(t, returned[0].AddrUtilization)
+	require.EqualValues(t, 500, returned[0].PdUtilization)
+
+	// The address pools take the address forecast and the prefix pools
+	// take the delegated prefix forecast.
+	addrAt := time.Date(2023, 3, 10, 12, 0, 0, 0, time.UTC)
+	pdAt := addrAt.AddDate(0, 1, 0)
+	require.NoError(t, SetExhaustionForecast(db, UtilizationObjectAddressPool, addressPool.ID, addrAt, pdAt))
+	require.NoError(t, SetExhaustionForecast(db, UtilizationObjectPrefixPool, prefixPool.ID, addrAt, pdAt))
+
+	returnedSubnet, err := GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Equal(t, addrAt, returnedSubnet.LocalSubnets[0].AddressPools[0].ExhaustionAt.UTC())
+	require.Equal(t, pdAt, returnedSubnet.LocalSubnets[0].PrefixPools[0].ExhaustionAt.UTC())
+
+	require.NoError(t, SetExhaustionForecast(db, UtilizationObjectAddressPool, addressPool.ID, time.Time{}, time.Time{}))
+	returnedSubnet, err = GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Zero(t, returnedSubnet.LocalSubnets[0].AddressPools[0].ExhaustionAt)
+
+	// The samples are deleted with the pool.
+	require.NoError(t, DeleteAddressPool(db, addressPool.ID))
+	returned, err = GetUtilizationSamples(db, UtilizationObjectAddressPool, addressPool.ID, UtilizationResolutionRaw, start, start.Add(time.Hour))
+	require.NoError(t, err)
+	require.Empty(t, returned)
+}
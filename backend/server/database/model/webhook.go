@@ -0,0 +1,191 @@
+package dbmodel
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Maximum number of the deliveries held in the log of each webhook. The
+// oldest deliveries are removed when the new ones are added.
+const WebhookDeliveryLogMaxSize = 1000
+
+// Represents a destination the events are sent to. The events are sent
+// if their level is equal to or greater than the webhook level, they are
+// related to the objects specified in the filters, and their text matches
+// the text pattern (a regular expression). The empty filters and text
+// pattern match all events. The template is used to render the JSON
+// payload; the default payload is sent if the template is empty. The
+// payload is signed with the secret if it is specified. The failed
+// deliveries are retried up to the maximum number of retries.
+type Webhook struct {
+	ID          int64
+	Name        string
+	URL         string
+	Enabled     bool `pg:",use_zero"`
+	Secret      string
+	Template    string
+	Level       EventLevel `pg:",use_zero"`
+	Filters     *Relations
+	TextPattern string
+	MaxRetries  int `pg:",use_zero"`
+	CreatedAt   time.Time
+}
+
+// Represents the result of sending an event to a webhook. The status code
+// is the HTTP status code returned in the last attempt. It is zero if the
+// webhook didn't respond.
+type WebhookDelivery struct {
+	ID         int64
+	WebhookID  int64
+	EventID    int64
+	CreatedAt  time.Time
+	Attempts   int `pg:",use_zero"`
+	StatusCode int
+	Success    bool `pg:",use_zero"`
+	Error      string
+}
+
+// Checks if the error returned by the database is caused by the duplicated
+// webhook name.
+func isWebhookConflict(err error) bool {
+	var pgError pg.Error
+	return errors.As(err, &pgError) && pgError.IntegrityViolation()
+}
+
+// Inserts a new webhook into the database. The returned conflict value
+// indicates that a webhook with the same name already exists.
+func AddWebhook(dbi dbops.DBI, webhook *Webhook) (conflict bool, err error) {
+	if webhook.CreatedAt.IsZero() {
+		webhook.CreatedAt = time.Now().UTC()
+	}
+	_, err = dbi.Model(webhook).Insert()
+	if err != nil {
+		return isWebhookConflict(err), pkgerrors.Wrapf(err, "problem inserting webhook %s", webhook.Name)
+	}
+	return false, nil
+}
+
+// Updates the webhook in the database. The returned conflict value
+// indicates that another webhook with the same name already exists. It
+// returns ErrNotExists if the webhook doesn't exist.
+func UpdateWebhook(dbi dbops.DBI, webhook *Webhook) (conflict bool, err error) {
+	result, err := dbi.Model(webhook).
+		ExcludeColumn("created_at").
+		WherePK().
+		Update()
+	if err != nil {
+		return isWebhookConflict(err), pkgerrors.Wrapf(err, "problem updating webhook %d", webhook.ID)
+	} else if result.RowsAffected() <= 0 {
+		return false, pkgerrors.Wrapf(ErrNotExists, "webhook %d does not exist", webhook.ID)
+	}
+	return false, nil
+}
+
+// Returns all webhooks ordered by ID.
+func GetWebhooks(dbi dbops.DBI) ([]Webhook, error) {
+	webhooks := []Webhook{}
+	err := dbi.Model(&webhooks).OrderExpr("id ASC").Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting webhooks")
+	}
+	return webhooks, nil
+}
+
+// Returns the enabled webhooks ordered by ID.
+func GetEnabledWebhooks(dbi dbops.DBI) ([]Webhook, error) {
+	webhooks := []Webhook{}
+	err := dbi.Model(&webhooks).Where("enabled").OrderExpr("id ASC").Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting enabled webhooks")
+	}
+	return webhooks, nil
+}
+
+// Returns the webhook with the specified ID. It returns nil if the
+// webhook doesn't exist.
+func GetWebhookByID(dbi dbops.DBI, id int64) (*Webhook, error) {
+	webhook := &Webhook{}
+	err := dbi.Model(webhook).Where("id = ?", id).Select()
+	if errors.Is(err, pg.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting webhook %d", id)
+	}
+	return webhook, nil
+}
+
+// Deletes the webhook together with its delivery log. It returns
+// ErrNotExists if the webhook doesn't exist.
+func DeleteWebhook(dbi dbops.DBI, id int64) error {
+	result, err := dbi.Model((*Webhook)(nil)).Where("id = ?", id).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting webhook %d", id)
+	} else if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "webhook %d does not exist", id)
+	}
+	return nil
+}
+
+// Adds the delivery to the log in a transaction.
+func addWebhookDelivery(tx *pg.Tx, delivery *WebhookDelivery) error {
+	if delivery.CreatedAt.IsZero() {
+		delivery.CreatedAt = time.Now().UTC()
+	}
+	_, err := tx.Model(delivery).Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem inserting delivery of webhook %d", delivery.WebhookID)
+	}
+
+	recent := tx.Model((*WebhookDelivery)(nil)).
+		Column("id").
+		Where("webhook_id = ?", delivery.WebhookID).
+		OrderExpr("id DESC").
+		Limit(WebhookDeliveryLogMaxSize)
+	_, err = tx.Model((*WebhookDelivery)(nil)).
+		Where("webhook_id = ?", delivery.WebhookID).
+		Where("id NOT IN (?)", recent).
+		Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem pruning delivery log of webhook %d", delivery.WebhookID)
+	}
+	return nil
+}
+
+// Adds the delivery to the log of the webhook and removes the oldest
+// deliveries exceeding the maximum log size. It begins a new transaction
+// when dbi has a *pg.DB type or uses an existing transaction when dbi has
+// a *pg.Tx type.
+func AddWebhookDelivery(dbi dbops.DBI, delivery *WebhookDelivery) error {
+	if db, ok := dbi.(*pg.DB); ok {
+		return db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			return addWebhookDelivery(tx, delivery)
+		})
+	}
+	return addWebhookDelivery(dbi.(*pg.Tx), delivery)
+}
+
+// Returns the deliveries of the webhook, starting from the most recent
+// ones. The offset and limit specify the beginning of the page and the
+// maximum size of the page. It also returns the total number of the
+// deliveries of the webhook.
+func GetWebhookDeliveriesByPage(dbi dbops.DBI, webhookID, offset, limit int64) ([]WebhookDelivery, int64, error) {
+	if limit == 0 {
+		return nil, 0, pkgerrors.New("limit should be greater than 0")
+	}
+	deliveries := []WebhookDelivery{}
+	total, err := dbi.Model(&deliveries).
+		Where("webhook_id = ?", webhookID).
+		OrderExpr("id DESC").
+		Offset(int(offset)).
+		Limit(int(limit)).
+		SelectAndCount()
+	if err != nil {
+		return nil, 0, pkgerrors.Wrapf(err, "problem getting deliveries of webhook %d", webhookID)
+	}
+	return deliveries, int64(total), nil
+}
@@ -0,0 +1,154 @@
+package dbmodel
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the webhooks can be added, updated, fetched and deleted.
+func TestAddUpdateGetDeleteWebhook(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	webhook := &Webhook{
+		Name:        "chat",
+		URL:         "https://chat.example.org/hooks/1",
+		Enabled:     true,
+		Secret:      "secret",
+		Template:    `{"text": {{json .Text}}}`,
+		Level:       EvWarning,
+		Filters:     &Relations{MachineID: 1},
+		TextPattern: "failed",
+		MaxRetries:  2,
+	}
+	conflict, err := AddWebhook(db, webhook)
+	require.NoError(t, err)
+	require.False(t, conflict)
+	require.NotZero(t, webhook.ID)
+	require.NotZero(t, webhook.CreatedAt)
+
+	// The names must be unique.
+	conflict, err = AddWebhook(db, &Webhook{Name: "chat", URL: "https://other.example.org"})
+	require.Error(t, err)
+	require.True(t, conflict)
+
+	disabled := &Webhook{Name: "tickets", URL: "https://tickets.example.org"}
+	_, err = AddWebhook(db, disabled)
+	require.NoError(t, err)
+
+	returned, err := GetWebhookByID(db, webhook.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+	require.Equal(t, webhook.Name, returned.Name)
+	require.Equal(t, webhook.URL, returned.URL)
+	require.True(t, returned.Enabled)
+	require.Equal(t, webhook.Secret, returned.Secret)
+	require.Equal(t, webhook.Template, returned.Template)
+	require.Equal(t, EvWarning, returned.Level)
+	require.EqualValues(t, 1, returned.Filters.MachineID)
+	require.Equal(t, webhook.TextPattern, returned.TextPattern)
+	require.Equal(t, 2, returned.MaxRetries)
+
+	webhooks, err := GetWebhooks(db)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 2)
+	require.Equal(t, webhook.ID, webhooks[0].ID)
+	require.Equal(t, disabled.ID, webhooks[1].ID)
+
+	webhooks, err = GetEnabledWebhooks(db)
+	require.NoError(t, err)
+	require.Len(t, webhooks, 1)
+	require.Equal(t, webhook.ID, webhooks[0].ID)
+
+	// Update the webhook.
+	returned.Enabled = false
+	returned.Filters = nil
+	returned.MaxRetries = 0
+	conflict, err = UpdateWebhook(db, returned)
+	require.NoError(t, err)
+	require.False(t, conflict)
+
+	returned, err = GetWebhookByID(db, webhook.ID)
+	require.NoError(t, err)
+	require.False(t, returned.Enabled)
+	require.Nil(t, returned.Filters)
+	require.Zero(t, returned.MaxRetries)
+	require.Equal(t, webhook.CreatedAt.Unix(), returned.CreatedAt.Unix())
+
+	webhooks, err = GetEnabledWebhooks(db)
+	require.NoError(t, err)
+	require.Empty(t, webhooks)
+
+	// Another webhook has the same name.
+	returned.Name = "tickets"
+	conflict, err = UpdateWebhook(db, returned)
+	require.Error(t, err)
+	require.True(t, conflict)
+
+	_, err = UpdateWebhook(db, &Webhook{ID: webhook.ID + 100, Name: "foo"})
+	require.ErrorIs(t, err, ErrNotExists)
+
+	// Delete the webhook.
+	require.NoError(t, DeleteWebhook(db, webhook.ID))
+	require.ErrorIs(t, DeleteWebhook(db, webhook.ID), ErrNotExists)
+
+	returned, err = GetWebhookByID(db, webhook.ID)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+}
+
+// Test that the webhook deliveries are logged and the log is limited.
+func TestWebhookDeliveries(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	webhook := &Webhook{Name: "chat", URL: "https://chat.example.org"}
+	_, err := AddWebhook(db, webhook)
+	require.NoError(t, err)
+
+	event := &Event{Text: "foo", Level: EvError}
+	require.NoError(t, AddEvent(db, event))
+
+	for i := 0; i < WebhookDeliveryLogMaxSize+5; i++ {
+		delivery := &WebhookDelivery{
+			WebhookID:  webhook.ID,
+			EventID:    event.ID,
+			Attempts:   1,
+			StatusCode: 200,
+			Success:    true,
+		}
+		require.NoError(t, AddWebhookDelivery(db, delivery))
+	}
+	failed := &WebhookDelivery{
+		WebhookID: webhook.ID,
+		Attempts:  3,
+		Error:     "connection refused",
+	}
+	require.NoError(t, AddWebhookDelivery(db, failed))
+
+	deliveries, total, err := GetWebhookDeliveriesByPage(db, webhook.ID, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, WebhookDeliveryLogMaxSize, total)
+	require.Len(t, deliveries, 10)
+	require.Equal(t, failed.ID, deliveries[0].ID)
+	require.Zero(t, deliveries[0].EventID)
+	require.Zero(t, deliveries[0].StatusCode)
+	require.False(t, deliveries[0].Success)
+	require.Equal(t, 3, deliveries[0].Attempts)
+	require.Equal(t, "connection refused", deliveries[0].Error)
+	require.Equal(t, event.ID, deliveries[1].EventID)
+	require.True(t, deliveries[1].Success)
+	require.Equal(t, 200, deliveries[1].StatusCode)
+
+	_, _, err = GetWebhookDeliveriesByPage(db, webhook.ID, 0, 0)
+	require.Error(t, err)
+
+	// The deliveries are deleted together with the webhook.
+	require.NoError(t, DeleteWebhook(db, webhook.ID))
+	deliveries, total, err = GetWebhookDeliveriesByPage(db, webhook.ID, 0, 10)
+	require.NoError(t, err)
+	require.Zero(t, total)
+	require.Empty(t, deliveries)
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alexedwards/scs/postgresstore"
 	"github.com/alexedwards/scs/v2"
@@ -14,6 +15,7 @@ import (
 	// Imports and registers the "postgres" driver used by database/sql.
 	_ "github.com/lib/pq"
 	"github.com/pkg/errors"
+	"isc.org/stork/server/auth"
 	dbops "isc.org/stork/server/database"
 	dbmodel "isc.org/stork/server/database/model"
 )
@@ -22,13 +24,36 @@ import (
 // structure with Stork-specific implementation of sessions.
 type SessionMgr struct {
 	scsSessionMgr *scs.SessionManager
+	// Database used to validate the API tokens.
+	db *dbops.PgDB
 }
 
+// Type of the key used to store the API token in the request context.
+type apiTokenContextKey struct{}
+
+// The last use time of the API token is updated in the database only if
+// the previous use was recorded earlier than this interval ago. It limits
+// the number of the database writes when a script sends many requests.
+const apiTokenLastUsedResolution = time.Minute
+
+// Maximum time between beginning and completing the redirect-based
+// authentication. The stored authentication flow is rejected after it.
+const authenticationFlowTimeout = 10 * time.Minute
+
+// Maximum time between the successful password authentication and
+// providing the second authentication factor.
+const secondFactorTimeout = 5 * time.Minute
+
+// Maximum number of the invalid second factor codes. The user has to
+// authenticate with the password again after exceeding it.
+const secondFactorMaxAttempts = 5
+
 // Creates new session manager instance. The new connection is created using the
-// lib/pq driver via scs.SessionManager.
-func NewSessionMgr(settings *dbops.DatabaseSettings) (*SessionMgr, error) {
+// lib/pq driver via scs.SessionManager. The db is used to validate the API
+// tokens presented by the clients.
+func NewSessionMgr(settings *dbops.DatabaseSettings, db *dbops.PgDB) (*SessionMgr, error) {
 	connParams := settings.ConvertToConnectionString()
-	db, err := sql.Open("postgres", connParams)
+	sqlDB, err := sql.Open("postgres", connParams)
 	if err != nil {
 		return nil, errors.Wrapf(err, "error connecting to the database for session management using credentials %s", connParams)
 	}
@@ -39,9 +64,9 @@ func NewSessionMgr(settings *dbops.DatabaseSettings) (*SessionMgr, error) {
 		logrus.WithError(err).Error("an error occurred in the session manager")
 		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
-	s.Store = postgresstore.New(db)
+	s.Store = postgresstore.New(sqlDB)
 
-	mgr := &SessionMgr{scsSessionMgr: s}
+	mgr := &SessionMgr{scsSessionMgr: s, db: db}
 
 	return mgr, nil
 }
@@ -78,6 +103,112 @@ func (s *SessionMgr) LoginHandler(ctx context.Context, user *dbmodel.SystemUser)
 	return nil
 }
 
+// Stores the state of the redirect-based authentication in the session of
+// the user being authenticated. The flow state is opaque data returned by
+// the authentication hook. The return URL is the UI location the user is
+// redirected to after the successful authentication.
+func (s *SessionMgr) PutAuthenticationFlow(ctx context.Context, authenticationMethodID, flowState, returnURL string) {
+	s.scsSessionMgr.Put(ctx, "authenticationFlowMethodID", authenticationMethodID)
+	s.scsSessionMgr.Put(ctx, "authenticationFlowState", flowState)
+	s.scsSessionMgr.Put(ctx, "authenticationFlowReturnURL", returnURL)
+	// The time is stored as the Unix timestamp because the session codec
+	// cannot encode the time.Time values.
+	s.scsSessionMgr.Put(ctx, "authenticationFlowStartedAt", int(time.Now().Unix()))
+}
+
+// Returns the state of the redirect-based authentication stored in the
+// session and removes it from the session, so it can be used only once.
+// The returned ok value is false if there is no authentication flow for the
+// specified method in the session or the flow has expired.
+func (s *SessionMgr) PopAuthenticationFlow(ctx context.Context, authenticationMethodID string) (flowState, returnURL string, ok bool) {
+	methodID := s.scsSessionMgr.PopString(ctx, "authenticationFlowMethodID")
+	flowState = s.scsSessionMgr.PopString(ctx, "authenticationFlowState")
+	returnURL = s.scsSessionMgr.PopString(ctx, "authenticationFlowReturnURL")
+	startedAt := time.Unix(int64(s.scsSessionMgr.PopInt(ctx, "authenticationFlowStartedAt")), 0)
+
+	if methodID == "" || methodID != authenticationMethodID {
+		return "", "", false
+	}
+	if time.Since(startedAt) > authenticationFlowTimeout {
+		return "", "", false
+	}
+	return flowState, returnURL, true
+}
+
+// Stores the user who has successfully authenticated with the password and
+// has to provide the second authentication factor to log in. The enroll flag
+// indicates that the user is required to use the second factor but hasn't
+// enrolled yet. The user is not logged in until the second factor is
+// verified.
+func (s *SessionMgr) PutPendingSecondFactor(ctx context.Context, userID int, enroll bool) error {
+	// Use a new session token to prevent the session fixation.
+	if err := s.scsSessionMgr.RenewToken(ctx); err != nil {
+		return errors.Wrapf(err, "error while creating new session identifier")
+	}
+	s.scsSessionMgr.Put(ctx, "secondFactorUserID", userID)
+	s.scsSessionMgr.Put(ctx, "secondFactorEnroll", enroll)
+	s.scsSessionMgr.Put(ctx, "secondFactorAttempts", 0)
+	// The time is stored as the Unix timestamp because the session codec
+	// cannot encode the time.Time values.
+	s.scsSessionMgr.Put(ctx, "secondFactorStartedAt", int(time.Now().Unix()))
+	return nil
+}
+
+// Returns the user awaiting the second authentication factor verification.
+// The returned ok value is false if there is no such user in the session,
+// the verification has expired or the user has exceeded the maximum number
+// of attempts.
+func (s *SessionMgr) GetPendingSecondFactor(ctx context.Context) (userID int, enroll, ok bool) {
+	userID = s.scsSessionMgr.GetInt(ctx, "secondFactorUserID")
+	if userID == 0 {
+		return 0, false, false
+	}
+	startedAt := time.Unix(int64(s.scsSessionMgr.GetInt(ctx, "secondFactorStartedAt")), 0)
+	if time.Since(startedAt) > secondFactorTimeout ||
+		s.scsSessionMgr.GetInt(ctx, "secondFactorAttempts") >= secondFactorMaxAttempts {
+		s.ClearPendingSecondFactor(ctx)
+		return 0, false, false
+	}
+	return userID, s.scsSessionMgr.GetBool(ctx, "secondFactorEnroll"), true
+}
+
+// Records the invalid second factor code provided by the user. It returns
+// the number of the remaining attempts. The pending verification is removed
+// from the session when there are no attempts left.
+func (s *SessionMgr) FailPendingSecondFactor(ctx context.Context) int {
+	attempts := s.scsSessionMgr.GetInt(ctx, "secondFactorAttempts") + 1
+	if attempts >= secondFactorMaxAttempts {
+		s.ClearPendingSecondFactor(ctx)
+		return 0
+	}
+	s.scsSessionMgr.Put(ctx, "secondFactorAttempts", attempts)
+	return secondFactorMaxAttempts - attempts
+}
+
+// Removes the pending second factor verification from the session.
+func (s *SessionMgr) ClearPendingSecondFactor(ctx context.Context) {
+	s.scsSessionMgr.Remove(ctx, "secondFactorUserID")
+	s.scsSessionMgr.Remove(ctx, "secondFactorEnroll")
+	s.scsSessionMgr.Remove(ctx, "secondFactorAttempts")
+	s.scsSessionMgr.Remove(ctx, "secondFactorStartedAt")
+}
+
+// Sets the flag indicating that the logged user has to change the password,
+// e.g., because it has expired or has been set by the administrator. The
+// user can't access other resources until the password is changed.
+func (s *SessionMgr) SetPasswordChangeRequired(ctx context.Context, required bool) {
+	if required {
+		s.scsSessionMgr.Put(ctx, "passwordChangeRequired", true)
+	} else {
+		s.scsSessionMgr.Remove(ctx, "passwordChangeRequired")
+	}
+}
+
+// Checks if the logged user has to change the password.
+func (s *SessionMgr) IsPasswordChangeRequired(ctx context.Context) bool {
+	return s.scsSessionMgr.GetBool(ctx, "passwordChangeRequired")
+}
+
 // Destroys user session as a result of logout.
 func (s *SessionMgr) LogoutHandler(ctx context.Context) error {
 	err := s.scsSessionMgr.Destroy(ctx)
@@ -100,10 +231,114 @@ func (s *SessionMgr) LogoutUser(ctx context.Context, user *dbmodel.SystemUser) e
 	return errors.Wrapf(err, "error while destroying a user session")
 }
 
+// Returns the bearer token from the Authorization header of the request.
+func getBearerToken(req *http.Request) (string, bool) {
+	header := req.Header.Get("Authorization")
+	scheme, token, found := strings.Cut(header, " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, len(token) > 0
+}
+
+// Checks if the owner of the API token may use it. The tokens of the
+// locked out users and of the users who have to change the password are
+// rejected until the users are unlocked or change the password. Otherwise,
+// the tokens would bypass the lockout and the password expiration.
+func (s *SessionMgr) isAPITokenOwnerActive(user *dbmodel.SystemUser, now time.Time) (bool, error) {
+	lockout, err := dbmodel.GetUserLockout(s.db, user.ID)
+	if err != nil {
+		return false, err
+	}
+	if lockout.IsLocked(now) {
+		return false, nil
+	}
+	if user.AuthenticationMethodID != dbmodel.AuthenticationMethodIDInternal {
+		return true, nil
+	}
+	info, err := dbmodel.GetUserPasswordInfo(s.db, user.ID)
+	if err != nil || info == nil {
+		return err == nil, err
+	}
+	if info.ChangeRequired {
+		return false, nil
+	}
+	policy, err := auth.GetPasswordPolicy(s.db)
+	if err != nil {
+		return false, err
+	}
+	return !policy.IsExpired(info.ChangedAt, now), nil
+}
+
+// Validates the API token and records its use. It returns nil if the
+// token doesn't exist or has expired, or if its owner is locked out or
+// has to change the password.
+func (s *SessionMgr) authenticateAPIToken(token string) (*dbmodel.APIToken, error) {
+	if s.db == nil {
+		return nil, nil
+	}
+	apiToken, err := dbmodel.GetAPITokenByHash(s.db, dbmodel.HashAPIToken(token))
+	if err != nil || apiToken == nil || apiToken.User == nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	if apiToken.IsExpired(now) {
+		return nil, nil
+	}
+	if active, err := s.isAPITokenOwnerActive(apiToken.User, now); err != nil || !active {
+		return nil, err
+	}
+	if now.Sub(apiToken.LastUsedAt) >= apiTokenLastUsedResolution {
+		if err = dbmodel.UpdateAPITokenLastUsed(s.db, apiToken.ID, now); err != nil {
+			// It is not critical. Let the request through.
+			logrus.WithError(err).Warn("Failed to record the API token use")
+		}
+		apiToken.LastUsedAt = now
+	}
+	return apiToken, nil
+}
+
 // Implements middleware which reads the session cookie, loads session data for the
-// user and stores the token/ in the Cookie being sent to the user.
+// user and stores the token/ in the Cookie being sent to the user. If the request
+// carries an API token in the Authorization header, the token is validated instead
+// and the request is handled on behalf of the token owner without creating a
+// session.
 func (s *SessionMgr) SessionMiddleware(handler http.Handler) http.Handler {
-	return s.scsSessionMgr.LoadAndSave(handler)
+	sessionHandler := s.scsSessionMgr.LoadAndSave(handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		token, ok := getBearerToken(req)
+		if !ok {
+			sessionHandler.ServeHTTP(w, req)
+			return
+		}
+		apiToken, err := s.authenticateAPIToken(token)
+		if err != nil {
+			logrus.WithError(err).Error("Failed to validate the API token")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if apiToken == nil {
+			http.Error(w, "invalid or expired API token", http.StatusUnauthorized)
+			return
+		}
+		// Load an empty session because some handlers use the session data.
+		// It is never saved, so the session cookie is not sent.
+		ctx, err := s.scsSessionMgr.Load(req.Context(), "")
+		if err != nil {
+			s.scsSessionMgr.ErrorFunc(w, req, err)
+			return
+		}
+		ctx = context.WithValue(ctx, apiTokenContextKey{}, apiToken)
+		handler.ServeHTTP(w, req.WithContext(ctx))
+	})
+}
+
+// Returns the API token used to authenticate the request or nil if the
+// request was authenticated with the session cookie.
+func (s *SessionMgr) APIToken(ctx context.Context) *dbmodel.APIToken {
+	apiToken, _ := ctx.Value(apiTokenContextKey{}).(*dbmodel.APIToken)
+	return apiToken
 }
 
 // Checks if the given session token exists in the database. This is typically used
@@ -116,8 +351,17 @@ func (s *SessionMgr) HasToken(token string) bool {
 // Checks if the user is logged to the system. It is assumed that the session data
 // is already fetched from the database and is stored in the request context.
 // The returned values are: ok - if the user is logged, user identifier and user
-// login.
+// login. The user authenticated with the API token is also considered logged.
 func (s *SessionMgr) Logged(ctx context.Context) (ok bool, user *dbmodel.SystemUser) {
+	if apiToken := s.APIToken(ctx); apiToken != nil {
+		tokenUser := *apiToken.User
+		tokenUser.Groups = nil
+		for _, g := range apiToken.User.Groups {
+			tokenUser.Groups = append(tokenUser.Groups, &dbmodel.SystemGroup{ID: g.ID})
+		}
+		return true, &tokenUser
+	}
+
 	id := s.scsSessionMgr.GetInt(ctx, "userID")
 	// User has no session.
 	if id == 0 {
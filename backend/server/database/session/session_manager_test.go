@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	dbmodel "isc.org/stork/server/database/model"
@@ -29,7 +30,7 @@ func TestMiddlewareNewSession(t *testing.T) {
 	_, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
 	defer teardown()
 
-	mgr, err := NewSessionMgr(dbSettings)
+	mgr, err := NewSessionMgr(dbSettings, nil)
 	require.NoError(t, err)
 
 	req := httptest.NewRequest("GET", "http://example.com/foo", nil)
@@ -99,7 +100,7 @@ func TestLoad(t *testing.T) {
 	_, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
 	defer teardown()
 
-	mgr, err := NewSessionMgr(dbSettings)
+	mgr, err := NewSessionMgr(dbSettings, nil)
 	require.NoError(t, err)
 
 	ctx := context.Background()
@@ -114,7 +115,7 @@ func TestLogOutUser(t *testing.T) {
 	defer teardown()
 
 	// Create session manager.
-	mgr, err := NewSessionMgr(dbSettings)
+	mgr, err := NewSessionMgr(dbSettings, nil)
 	require.NoError(t, err)
 
 	ctx := context.Background()
@@ -210,3 +211,252 @@ func TestLogOutUser(t *testing.T) {
 	logged, _ = mgr.Logged(ctx)
 	require.False(t, logged)
 }
+
+// Tests that the request carrying the API token is handled on behalf of
+// the token owner without creating a session.
+func TestMiddlewareAPIToken(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	mgr, err := NewSessionMgr(dbSettings, db)
+	require.NoError(t, err)
+
+	user := &dbmodel.SystemUser{
+		Login:    "bot",
+		Lastname: "Bot",
+		Name:     "CI",
+		Groups:   []*dbmodel.SystemGroup{{ID: dbmodel.AdminGroupID}},
+	}
+	_, err = dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	token, hash, err := dbmodel.GenerateAPIToken()
+	require.NoError(t, err)
+	_, err = dbmodel.AddAPIToken(db, &dbmodel.APIToken{
+		UserID:    user.ID,
+		Name:      "ci",
+		TokenHash: hash,
+	})
+	require.NoError(t, err)
+
+	expiredToken, expiredHash, err := dbmodel.GenerateAPIToken()
+	require.NoError(t, err)
+	_, err = dbmodel.AddAPIToken(db, &dbmodel.APIToken{
+		UserID:    user.ID,
+		Name:      "expired",
+		TokenHash: expiredHash,
+		ExpiresAt: time.Now().UTC().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+
+	var (
+		logged      bool
+		loggedUser  *dbmodel.SystemUser
+		loggedToken *dbmodel.APIToken
+	)
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		logged, loggedUser = mgr.Logged(r.Context())
+		loggedToken = mgr.APIToken(r.Context())
+	}
+	middlewareFunc := mgr.SessionMiddleware(http.HandlerFunc(handler))
+
+	serve := func(authorization string) *http.Response {
+		logged, loggedUser, loggedToken = false, nil, nil
+		req := httptest.NewRequest("GET", "http://example.com/foo", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		middlewareFunc.ServeHTTP(w, req)
+		resp := w.Result()
+		resp.Body.Close()
+		return resp
+	}
+
+	// Valid token.
+	resp := serve("Bearer " + token)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, logged)
+	require.NotNil(t, loggedUser)
+	require.Equal(t, user.ID, loggedUser.ID)
+	require.Equal(t, "bot", loggedUser.Login)
+	require.True(t, loggedUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.AdminGroupID}))
+	require.NotNil(t, loggedToken)
+	require.Equal(t, "ci", loggedToken.Name)
+
+	// No session is created for the token.
+	hasCookie, _ := getCookie(resp, "session")
+	require.False(t, hasCookie)
+
+	// The token use is recorded.
+	tokens, err := dbmodel.GetAPITokensByUserID(db, user.ID)
+	require.NoError(t, err)
+	require.False(t, tokens[0].LastUsedAt.IsZero())
+
+	// Expired token.
+	resp = serve("Bearer " + expiredToken)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.False(t, logged)
+
+	// Unknown token.
+	resp = serve("Bearer stork_foo")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// The token of the locked out user is rejected until the user is
+	// unlocked.
+	_, err = dbmodel.RecordFailedLogin(db, user.ID, 1, time.Hour, time.Now().UTC())
+	require.NoError(t, err)
+	resp = serve("Bearer " + token)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.False(t, logged)
+	require.NoError(t, dbmodel.DeleteUserLockout(db, user.ID))
+	resp = serve("Bearer " + token)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, logged)
+
+	// The token of the user who has to change the password is rejected
+	// until the password is changed.
+	require.NoError(t, dbmodel.SetTemporaryPassword(db, user.ID, "temporary"))
+	resp = serve("Bearer " + token)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	require.False(t, logged)
+	require.NoError(t, dbmodel.SetPassword(db, user.ID, "changed"))
+	resp = serve("Bearer " + token)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.True(t, logged)
+
+	// Other authorization schemes are ignored.
+	resp = serve("Basic Ym90OnBhc3M=")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.False(t, logged)
+	require.Nil(t, loggedToken)
+}
+
+// Test that the state of the redirect-based authentication is stored in the
+// session and can be retrieved only once.
+func TestAuthenticationFlow(t *testing.T) {
+	// Arrange
+	_, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	mgr, err := NewSessionMgr(dbSettings, nil)
+	require.NoError(t, err)
+
+	ctx, err := mgr.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Act
+	mgr.PutAuthenticationFlow(ctx, "oidc", "state", "/machines/all")
+	flowState, returnURL, ok := mgr.PopAuthenticationFlow(ctx, "oidc")
+
+	// Assert
+	require.True(t, ok)
+	require.Equal(t, "state", flowState)
+	require.Equal(t, "/machines/all", returnURL)
+
+	// The flow can be used only once.
+	_, _, ok = mgr.PopAuthenticationFlow(ctx, "oidc")
+	require.False(t, ok)
+}
+
+// Test that the state of the redirect-based authentication is not returned
+// for a different authentication method.
+func TestAuthenticationFlowOtherMethod(t *testing.T) {
+	// Arrange
+	_, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	mgr, err := NewSessionMgr(dbSettings, nil)
+	require.NoError(t, err)
+
+	ctx, err := mgr.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	mgr.PutAuthenticationFlow(ctx, "oidc", "state", "/")
+
+	// Act
+	flowState, _, ok := mgr.PopAuthenticationFlow(ctx, "ldap")
+
+	// Assert
+	require.False(t, ok)
+	require.Empty(t, flowState)
+}
+
+// Test that the user awaiting the second factor is stored in the session
+// but is not logged in.
+func TestPendingSecondFactor(t *testing.T) {
+	// Arrange
+	_, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	mgr, err := NewSessionMgr(dbSettings, nil)
+	require.NoError(t, err)
+
+	ctx, err := mgr.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Act
+	err = mgr.PutPendingSecondFactor(ctx, 42, true)
+	require.NoError(t, err)
+	userID, enroll, ok := mgr.GetPendingSecondFactor(ctx)
+
+	// Assert
+	require.True(t, ok)
+	require.EqualValues(t, 42, userID)
+	require.True(t, enroll)
+
+	logged, _ := mgr.Logged(ctx)
+	require.False(t, logged)
+
+	mgr.ClearPendingSecondFactor(ctx)
+	_, _, ok = mgr.GetPendingSecondFactor(ctx)
+	require.False(t, ok)
+}
+
+// Test that the pending second factor verification is removed after
+// exceeding the maximum number of attempts.
+func TestPendingSecondFactorAttempts(t *testing.T) {
+	// Arrange
+	_, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	mgr, err := NewSessionMgr(dbSettings, nil)
+	require.NoError(t, err)
+
+	ctx, err := mgr.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	err = mgr.PutPendingSecondFactor(ctx, 42, false)
+	require.NoError(t, err)
+
+	// Act & Assert
+	for i := secondFactorMaxAttempts - 1; i > 0; i-- {
+		require.Equal(t, i, mgr.FailPendingSecondFactor(ctx))
+		_, _, ok := mgr.GetPendingSecondFactor(ctx)
+		require.True(t, ok)
+	}
+	require.Zero(t, mgr.FailPendingSecondFactor(ctx))
+	_, _, ok := mgr.GetPendingSecondFactor(ctx)
+	require.False(t, ok)
+}
+
+// Test that the flag indicating that the user has to change the password
+// is stored in the session.
+func TestPasswordChangeRequired(t *testing.T) {
+	// Arrange
+	_, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	mgr, err := NewSessionMgr(dbSettings, nil)
+	require.NoError(t, err)
+
+	ctx, err := mgr.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Act & Assert
+	require.False(t, mgr.IsPasswordChangeRequired(ctx))
+	mgr.SetPasswordChangeRequired(ctx, true)
+	require.True(t, mgr.IsPasswordChangeRequired(ctx))
+	mgr.SetPasswordChangeRequired(ctx, false)
+	require.False(t, mgr.IsPasswordChangeRequired(ctx))
+}
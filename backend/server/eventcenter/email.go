@@ -0,0 +1,268 @@
+package eventcenter
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+
+	errors "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+const (
+	// Number of events waiting to be sent by email. The events are
+	// dropped when the queue is full.
+	emailQueueSize = 1000
+	// Interval of checking if the digests should be sent.
+	emailDigestCheckInterval = time.Minute
+	// Maximum number of events processed in a single digest. The
+	// remaining events are sent in the next digest.
+	emailDigestMaxEvents = 500
+	// Maximum length of the event text in the email subject.
+	emailSubjectMaxTextLength = 80
+)
+
+// Sends the event notifications by email to the subscribed users. The
+// events sent immediately are queued and sent in the background, so the
+// slow SMTP server doesn't block the event center. The digests are built
+// from the events stored in the database, so they are not lost when the
+// server is restarted. The result of each delivery is recorded in the
+// database.
+type emailNotifier struct {
+	db                  *dbops.PgDB
+	events              chan *dbmodel.Event
+	done                chan struct{}
+	wg                  *sync.WaitGroup
+	digestCheckInterval time.Duration
+}
+
+// Creates the email notifier and starts its loops sending the events
+// and the digests.
+func newEmailNotifier(db *dbops.PgDB) *emailNotifier {
+	n := &emailNotifier{
+		db:                  db,
+		events:              make(chan *dbmodel.Event, emailQueueSize),
+		done:                make(chan struct{}),
+		wg:                  &sync.WaitGroup{},
+		digestCheckInterval: emailDigestCheckInterval,
+	}
+	n.wg.Add(2)
+	go n.mainLoop()
+	go n.digestLoop()
+	return n
+}
+
+// Stops the notifier.
+func (n *emailNotifier) shutdown() {
+	close(n.done)
+	n.wg.Wait()
+}
+
+// Queues the event to be sent to the users subscribed to the individual
+// events. The event is dropped if the queue is full.
+func (n *emailNotifier) dispatchEvent(event *dbmodel.Event) {
+	select {
+	case n.events <- event:
+	default:
+		log.Warnf("Email queue is full; event %d is not sent by email", event.ID)
+	}
+}
+
+// A main loop sending the queued events.
+func (n *emailNotifier) mainLoop() {
+	defer n.wg.Done()
+	for {
+		select {
+		case <-n.done:
+			return
+		case event := <-n.events:
+			n.sendEvent(event)
+		}
+	}
+}
+
+// A loop periodically sending the digests.
+func (n *emailNotifier) digestLoop() {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.digestCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-n.done:
+			return
+		case <-ticker.C:
+			n.sendDigests(time.Now().UTC())
+		}
+	}
+}
+
+// Returns the SMTP configuration or nil if the email notifications are
+// disabled.
+func (n *emailNotifier) getSMTPConfig() *SMTPConfig {
+	config, err := GetSMTPConfig(n.db)
+	if err != nil {
+		log.WithError(err).Error("Problem getting SMTP configuration")
+		return nil
+	}
+	if !config.IsEnabled() {
+		return nil
+	}
+	return config
+}
+
+// Sends the event to the users subscribed to the individual events. Each
+// user receives at most one email per event, even if the event matches
+// many subscriptions of the user.
+func (n *emailNotifier) sendEvent(event *dbmodel.Event) {
+	subscriptions, err := dbmodel.GetEmailSubscriptions(n.db, false)
+	if err != nil {
+		log.WithError(err).Error("Problem getting email subscriptions from the database")
+		return
+	}
+	var matched []*dbmodel.EmailSubscription
+	users := make(map[int]bool)
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		if users[subscription.UserID] || !eventMatches(subscription.Level, subscription.Filters, event) {
+			continue
+		}
+		users[subscription.UserID] = true
+		matched = append(matched, subscription)
+	}
+	if len(matched) == 0 {
+		return
+	}
+	config := n.getSMTPConfig()
+	if config == nil {
+		return
+	}
+	for _, subscription := range matched {
+		n.deliver(config, subscription, []dbmodel.Event{*event})
+	}
+}
+
+// Sends the digests which are due at the specified time. The digest
+// contains the events created since the last digest that match the
+// subscription. The events are processed again in the next digest if
+// sending the digest fails.
+func (n *emailNotifier) sendDigests(now time.Time) {
+	subscriptions, err := dbmodel.GetEmailSubscriptions(n.db, true)
+	if err != nil {
+		log.WithError(err).Error("Problem getting email subscriptions from the database")
+		return
+	}
+	var config *SMTPConfig
+	for i := range subscriptions {
+		subscription := &subscriptions[i]
+		if !subscription.IsDigestDue(now) {
+			continue
+		}
+		if config == nil {
+			if config = n.getSMTPConfig(); config == nil {
+				return
+			}
+		}
+		events, err := dbmodel.GetEventsAfterID(n.db, subscription.LastEventID, emailDigestMaxEvents)
+		if err != nil {
+			log.WithError(err).Error("Problem getting events for the email digest")
+			return
+		}
+		var matched []dbmodel.Event
+		for j := range events {
+			if events[j].MaintenanceWindowID == 0 && eventMatches(subscription.Level, subscription.Filters, &events[j]) {
+				matched = append(matched, events[j])
+			}
+		}
+		lastEventID := subscription.LastEventID
+		if len(matched) == 0 || n.deliver(config, subscription, matched) {
+			if len(events) > 0 {
+				lastEventID = events[len(events)-1].ID
+			}
+		}
+		if err = dbmodel.UpdateEmailSubscriptionDigest(n.db, subscription.ID, lastEventID, now); err != nil {
+			log.WithError(err).Error("Problem updating email digest state")
+		}
+	}
+}
+
+// Sends the events to the subscribed user and records the delivery. It
+// returns true if the email has been sent successfully.
+func (n *emailNotifier) deliver(config *SMTPConfig, subscription *dbmodel.EmailSubscription, events []dbmodel.Event) bool {
+	delivery := &dbmodel.EmailDelivery{
+		SubscriptionID: subscription.ID,
+		UserID:         subscription.UserID,
+		EventCount:     len(events),
+	}
+	err := n.send(config, subscription.User, events)
+	if subscription.User != nil {
+		delivery.Recipient = subscription.User.Email
+	}
+	if err != nil {
+		delivery.Error = err.Error()
+		log.WithFields(log.Fields{
+			"user":   subscription.UserID,
+			"events": len(events),
+		}).WithError(err).Warn("Failed to send event notification by email")
+	} else {
+		delivery.Success = true
+	}
+	if err = dbmodel.AddEmailDelivery(n.db, delivery); err != nil {
+		log.WithError(err).Errorf("Problem recording email delivery to user %d", subscription.UserID)
+	}
+	return delivery.Success
+}
+
+// Composes the email presenting the events and sends it to the user.
+func (n *emailNotifier) send(config *SMTPConfig, user *dbmodel.SystemUser, events []dbmodel.Event) error {
+	if user == nil || user.Email == "" {
+		return errors.New("user has no email address")
+	}
+	from, err := mail.ParseAddress(config.Sender)
+	if err != nil {
+		return errors.Wrapf(err, "invalid sender address %s", config.Sender)
+	}
+	to, err := mail.ParseAddress(user.Email)
+	if err != nil {
+		return errors.Wrapf(err, "invalid recipient address %s", user.Email)
+	}
+	to.Name = strings.TrimSpace(user.Name + " " + user.Lastname)
+
+	subject, body := formatEmailEvents(events)
+	message, err := composeEmail(from, to, subject, body, time.Now())
+	if err != nil {
+		return err
+	}
+	return sendMail(config, from.Address, []string{to.Address}, message)
+}
+
+// Returns the subject and the body of the email presenting the events.
+// The subject of the email with a single event contains the event text.
+func formatEmailEvents(events []dbmodel.Event) (subject, body string) {
+	var b strings.Builder
+	for i := range events {
+		event := &events[i]
+		text := StripEventTags(event.Text)
+		fmt.Fprintf(&b, "%s [%s] %s\n", event.CreatedAt.UTC().Format("2006-01-02 15:04:05 UTC"), event.Level, text)
+		if event.Details != "" {
+			for _, line := range strings.Split(strings.TrimRight(event.Details, "\n"), "\n") {
+				fmt.Fprintf(&b, "    %s\n", line)
+			}
+		}
+	}
+	b.WriteString("\n-- \nYou receive this email because you subscribed to the Stork event notifications.\n")
+
+	if len(events) == 1 {
+		text := strings.Join(strings.Fields(StripEventTags(events[0].Text)), " ")
+		if runes := []rune(text); len(runes) > emailSubjectMaxTextLength {
+			text = string(runes[:emailSubjectMaxTextLength]) + "..."
+		}
+		subject = fmt.Sprintf("[Stork] %s: %s", events[0].Level, text)
+	} else {
+		subject = fmt.Sprintf("[Stork] %d new events", len(events))
+	}
+	return subject, b.String()
+}
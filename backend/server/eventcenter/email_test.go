@@ -0,0 +1,204 @@
+package eventcenter
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the email with a single event contains the event text in the
+// subject.
+func TestFormatEmailEventsSingle(t *testing.T) {
+	events := []dbmodel.Event{
+		{
+			CreatedAt: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC),
+			Level:     dbmodel.EvError,
+			Text:      `<machine id="1" address="192.0.2.1" hostname="foo"> is unreachable`,
+			Details:   "connection refused\ntimeout\n",
+		},
+	}
+	subject, body := formatEmailEvents(events)
+	require.Equal(t, "[Stork] error: 192.0.2.1 is unreachable", subject)
+	require.True(t, strings.HasPrefix(body, "2023-03-01 12:00:00 UTC [error] 192.0.2.1 is unreachable\n"+
+		"    connection refused\n"+
+		"    timeout\n"))
+
+	// The long text is truncated in the subject.
+	events[0].Text = strings.Repeat("a", 100)
+	subject, _ = formatEmailEvents(events)
+	require.Equal(t, "[Stork] error: "+strings.Repeat("a", 80)+"...", subject)
+}
+
+// Test that the email with many events contains the number of events in
+// the subject.
+func TestFormatEmailEventsDigest(t *testing.T) {
+	events := []dbmodel.Event{
+		{
+			CreatedAt: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC),
+			Level:     dbmodel.EvInfo,
+			Text:      "first",
+		},
+		{
+			CreatedAt: time.Date(2023, 3, 1, 12, 5, 0, 0, time.UTC),
+			Level:     dbmodel.EvWarning,
+			Text:      "second",
+		},
+	}
+	subject, body := formatEmailEvents(events)
+	require.Equal(t, "[Stork] 2 new events", subject)
+	require.Contains(t, body, "2023-03-01 12:00:00 UTC [info] first\n2023-03-01 12:05:00 UTC [warning] second\n")
+}
+
+// Configures the SMTP server in the settings and adds the user subscribed
+// to the event notifications.
+func setupEmailNotifierTest(t *testing.T, db *dbops.PgDB, sink *smtpSink, digestInterval int) (*dbmodel.SystemUser, *dbmodel.EmailSubscription) {
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	require.NoError(t, dbmodel.SetSettingStr(db, "smtp_host", "127.0.0.1"))
+	require.NoError(t, dbmodel.SetSettingInt(db, "smtp_port", sink.port()))
+	require.NoError(t, dbmodel.SetSettingStr(db, "smtp_security", SMTPSecurityNone))
+	require.NoError(t, dbmodel.SetSettingStr(db, "smtp_sender", "stork@example.org"))
+
+	user := &dbmodel.SystemUser{
+		Login:    "operator",
+		Email:    "operator@example.org",
+		Lastname: "Operator",
+		Name:     "Otto",
+	}
+	_, err := dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	subscription := &dbmodel.EmailSubscription{
+		UserID:         user.ID,
+		Level:          dbmodel.EvWarning,
+		DigestInterval: digestInterval,
+	}
+	require.NoError(t, dbmodel.AddEmailSubscription(db, subscription))
+	return user, subscription
+}
+
+// Test that the events are sent immediately to the subscribed users and
+// the deliveries are recorded.
+func TestEmailNotifierSendEvent(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	sink := newSMTPSink(t, nil, false)
+	defer sink.close()
+	user, subscription := setupEmailNotifierTest(t, db, sink, 0)
+
+	// The second subscription of the same user accepts all levels.
+	require.NoError(t, dbmodel.AddEmailSubscription(db, &dbmodel.EmailSubscription{UserID: user.ID}))
+
+	notifier := &emailNotifier{db: db}
+
+	// The info event matches only the second subscription.
+	notifier.sendEvent(&dbmodel.Event{Level: dbmodel.EvInfo, Text: "info"})
+	require.Len(t, sink.getMessages(), 1)
+
+	// The error event matches both subscriptions but only one email is sent.
+	notifier.sendEvent(&dbmodel.Event{Level: dbmodel.EvError, Text: "error"})
+	messages := sink.getMessages()
+	require.Len(t, messages, 2)
+	require.Equal(t, []string{"operator@example.org"}, messages[1].recipients)
+	require.Contains(t, messages[1].data, "Subject: [Stork] error: error")
+
+	deliveries, total, err := dbmodel.GetEmailDeliveriesByPage(db, user.ID, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.True(t, deliveries[0].Success)
+	require.Equal(t, "operator@example.org", deliveries[0].Recipient)
+	require.Equal(t, 1, deliveries[0].EventCount)
+
+	// Stop the SMTP server and check that the failure is recorded.
+	sink.close()
+	notifier.sendEvent(&dbmodel.Event{Level: dbmodel.EvError, Text: "error"})
+	deliveries, total, err = dbmodel.GetEmailDeliveriesByPage(db, user.ID, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, total)
+	require.False(t, deliveries[0].Success)
+	require.Equal(t, subscription.ID, deliveries[0].SubscriptionID)
+	require.Contains(t, deliveries[0].Error, "problem connecting to SMTP server")
+}
+
+// Test that nothing is sent when the SMTP server is not configured.
+func TestEmailNotifierDisabled(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	sink := newSMTPSink(t, nil, false)
+	defer sink.close()
+	user, _ := setupEmailNotifierTest(t, db, sink, 0)
+	require.NoError(t, dbmodel.SetSettingStr(db, "smtp_host", ""))
+
+	notifier := &emailNotifier{db: db}
+	notifier.sendEvent(&dbmodel.Event{Level: dbmodel.EvError, Text: "error"})
+	require.Empty(t, sink.getMessages())
+
+	_, total, err := dbmodel.GetEmailDeliveriesByPage(db, user.ID, 0, 10)
+	require.NoError(t, err)
+	require.Zero(t, total)
+}
+
+// Test that the digest contains the matching events created since the
+// last digest and that the events are sent again when sending fails.
+func TestEmailNotifierSendDigests(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	sink := newSMTPSink(t, nil, false)
+	defer sink.close()
+	user, subscription := setupEmailNotifierTest(t, db, sink, 60)
+
+	notifier := &emailNotifier{db: db}
+
+	require.NoError(t, dbmodel.AddEvent(db, &dbmodel.Event{Level: dbmodel.EvWarning, Text: "first"}))
+	require.NoError(t, dbmodel.AddEvent(db, &dbmodel.Event{Level: dbmodel.EvInfo, Text: "ignored"}))
+	require.NoError(t, dbmodel.AddEvent(db, &dbmodel.Event{Level: dbmodel.EvError, Text: "second"}))
+
+	// The digest is not due yet.
+	now := subscription.LastDigestAt.Add(30 * time.Minute)
+	notifier.sendDigests(now)
+	require.Empty(t, sink.getMessages())
+
+	// Stop the server to make sending the digest fail.
+	sink.close()
+	now = subscription.LastDigestAt.Add(time.Hour)
+	notifier.sendDigests(now)
+
+	deliveries, _, err := dbmodel.GetEmailDeliveriesByPage(db, user.ID, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	require.False(t, deliveries[0].Success)
+	require.Equal(t, 2, deliveries[0].EventCount)
+
+	// The events are sent in the next digest.
+	sink = newSMTPSink(t, nil, false)
+	defer sink.close()
+	require.NoError(t, dbmodel.SetSettingInt(db, "smtp_port", sink.port()))
+	now = now.Add(time.Hour)
+	notifier.sendDigests(now)
+
+	messages := sink.getMessages()
+	require.Len(t, messages, 1)
+	require.Contains(t, messages[0].data, "Subject: [Stork] 2 new events")
+	require.Contains(t, messages[0].data, "[warning] first")
+	require.Contains(t, messages[0].data, "[error] second")
+	require.NotContains(t, messages[0].data, "ignored")
+
+	// No new events so nothing is sent.
+	now = now.Add(time.Hour)
+	notifier.sendDigests(now)
+	require.Len(t, sink.getMessages(), 1)
+
+	subscriptions, err := dbmodel.GetEmailSubscriptionsByUserID(db, user.ID)
+	require.NoError(t, err)
+	lastEventID, err := dbmodel.GetLastEventID(db)
+	require.NoError(t, err)
+	require.Equal(t, lastEventID, subscriptions[0].LastEventID)
+	require.WithinDuration(t, now, subscriptions[0].LastDigestAt, time.Millisecond)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/go-pg/pg/v10"
 	log "github.com/sirupsen/logrus"
@@ -22,31 +23,48 @@ type EventCenter interface {
 	ServeHTTP(w http.ResponseWriter, req *http.Request)
 }
 
-// EventCenter. It has channel for receiving events
-// and a SSE broker for dispatching events to subscribers.
+// EventCenter. It has channel for receiving events, a SSE broker for
+// dispatching events to subscribers, a dispatcher sending events to the
+// webhooks, a notifier sending events by email, an optional forwarder
+// sending events to syslog or a file and a tracker of the maintenance
+// windows.
 type eventCenter struct {
 	db     *dbops.PgDB
 	done   chan bool
 	wg     *sync.WaitGroup
 	events chan *dbmodel.Event
 
-	sseBroker *SSEBroker
+	sseBroker   *SSEBroker
+	webhooks    *webhookDispatcher
+	email       *emailNotifier
+	forwarder   *eventForwarder
+	maintenance *maintenanceTracker
 }
 
-// Create new EventCenter object.
-func NewEventCenter(db *pg.DB) EventCenter {
+// Create new EventCenter object. The forwarder settings specify where the
+// events are forwarded to; the events are not forwarded if the settings
+// are nil. It returns an error if the forwarder settings are invalid.
+func NewEventCenter(db *pg.DB, forwarderSettings *ForwarderSettings) (EventCenter, error) {
+	forwarder, err := newEventForwarder(forwarderSettings)
+	if err != nil {
+		return nil, err
+	}
 	ec := &eventCenter{
-		db:        db,
-		done:      make(chan bool),
-		wg:        &sync.WaitGroup{},
-		events:    make(chan *dbmodel.Event),
-		sseBroker: NewSSEBroker(db),
+		db:          db,
+		done:        make(chan bool),
+		wg:          &sync.WaitGroup{},
+		events:      make(chan *dbmodel.Event),
+		sseBroker:   NewSSEBroker(db),
+		webhooks:    newWebhookDispatcher(db),
+		email:       newEmailNotifier(db),
+		forwarder:   forwarder,
+		maintenance: newMaintenanceTracker(db),
 	}
 	ec.wg.Add(1)
 	go ec.mainLoop()
 
 	log.Printf("Started EventCenter")
-	return ec
+	return ec, nil
 }
 
 // Add an event on info level to EventCenter. It takes event text and relating objects.
@@ -128,13 +146,48 @@ func (ec *eventCenter) Shutdown() {
 	log.Printf("Stopping EventCenter")
 	ec.done <- true
 	ec.wg.Wait()
+	ec.webhooks.shutdown()
+	ec.email.shutdown()
+	ec.forwarder.shutdown()
 	log.Printf("Stopped EventCenter")
 }
 
-// A main loop of EventCenter. It receives events via channel, stores
-// them into database and dispatches them to subscribers using SSE broker.
+// Stores the event into database and dispatches it to subscribers using
+// SSE broker, to the webhooks, to the users subscribed to the email
+// notifications and to the forwarder. The events raised in the maintenance
+// windows are not sent to the external recipients.
+func (ec *eventCenter) processEvent(event *dbmodel.Event) {
+	err := dbmodel.AddEvent(ec.db, event)
+	if err != nil {
+		log.Errorf("Problem adding event to db: %+v", err)
+		return
+	}
+	ec.sseBroker.dispatchEvent(event)
+	if event.MaintenanceWindowID != 0 {
+		return
+	}
+	ec.webhooks.dispatchEvent(event)
+	ec.email.dispatchEvent(event)
+	ec.forwarder.dispatchEvent(event)
+}
+
+// Reloads the maintenance windows and raises the events for the windows
+// that started or ended.
+func (ec *eventCenter) checkMaintenanceWindows() {
+	for _, event := range ec.maintenance.refresh(time.Now().UTC()) {
+		log.Printf("Event '%s'", event.Text)
+		ec.processEvent(event)
+	}
+}
+
+// A main loop of EventCenter. It receives events via channel, tags the
+// events raised in the maintenance windows and processes them. It also
+// periodically checks the maintenance windows.
 func (ec *eventCenter) mainLoop() {
 	defer ec.wg.Done()
+	ticker := time.NewTicker(maintenanceCheckInterval)
+	defer ticker.Stop()
+	ec.checkMaintenanceWindows()
 	for {
 		select {
 		// wait for done signal from shutdown function
@@ -142,12 +195,12 @@ func (ec *eventCenter) mainLoop() {
 			return
 		// get events from channel
 		case event := <-ec.events:
-			err := dbmodel.AddEvent(ec.db, event)
-			if err != nil {
-				log.Errorf("Problem adding event to db: %+v", err)
-				continue
+			if window := ec.maintenance.match(event, time.Now().UTC()); window != nil {
+				applyMaintenanceWindow(event, window)
 			}
-			ec.sseBroker.dispatchEvent(event)
+			ec.processEvent(event)
+		case <-ticker.C:
+			ec.checkMaintenanceWindows()
 		}
 	}
 }
@@ -379,7 +379,8 @@ func TestAddEvent(t *testing.T) {
 	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
 	defer teardown()
 
-	ec := NewEventCenter(db)
+	ec, err := NewEventCenter(db, nil)
+	require.NoError(t, err)
 
 	app := &dbmodel.App{
 		ID:   123,
@@ -408,7 +409,6 @@ func TestAddEvent(t *testing.T) {
 	// so wait for it a little bit
 	var events []dbmodel.Event
 	var total int64
-	var err error
 
 	require.Eventually(t, func() bool {
 		events, total, err = dbmodel.GetEventsByPage(db, 0, 10, 0, nil, nil, nil, nil, "", dbmodel.SortDirAny)
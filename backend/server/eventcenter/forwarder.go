@@ -0,0 +1,392 @@
+package eventcenter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	errors "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Transport protocols used to forward the events to syslog.
+const (
+	SyslogProtocolUDP = "udp"
+	SyslogProtocolTCP = "tcp"
+	SyslogProtocolTLS = "tls"
+)
+
+const (
+	// Number of events waiting to be forwarded. The events are dropped
+	// when the queue is full.
+	forwarderQueueSize = 1000
+	// Timeout of connecting and writing to the syslog collector.
+	syslogTimeout = 10 * time.Second
+	// Name of the application sent in the syslog messages.
+	syslogAppName = "stork-server"
+	// Structured data ID of the event relations. It contains the ISC
+	// private enterprise number.
+	syslogStructuredDataID = "stork@2495"
+)
+
+// Settings of the event forwarding specified with the server flags. The
+// events are forwarded to syslog if the syslog address is specified and
+// appended to the file as JSON lines if the file is specified.
+type ForwarderSettings struct {
+	SyslogAddress  string `long:"events-syslog-address" description:"The address (host:port) of the syslog collector the events are forwarded to; forwarding to syslog is disabled if not specified" env:"STORK_SERVER_EVENTS_SYSLOG_ADDRESS"`
+	SyslogProtocol string `long:"events-syslog-protocol" description:"The transport protocol used to forward the events to syslog" choice:"udp" choice:"tcp" choice:"tls" default:"udp" env:"STORK_SERVER_EVENTS_SYSLOG_PROTOCOL"`
+	SyslogFacility int    `long:"events-syslog-facility" description:"The syslog facility code of the forwarded events (0-23)" default:"16" env:"STORK_SERVER_EVENTS_SYSLOG_FACILITY"`
+	SyslogTLSCA    string `long:"events-syslog-tls-ca" description:"The certificate authority file used to verify the syslog collector certificate; the system certificates are used if not specified" env:"STORK_SERVER_EVENTS_SYSLOG_TLS_CA"`
+	JSONFile       string `long:"events-json-file" description:"The file the events are appended to as JSON lines; writing the events to the file is disabled if not specified" env:"STORK_SERVER_EVENTS_JSON_FILE"`
+}
+
+// Destination the events are forwarded to.
+type eventSink interface {
+	// Returns the sink name used in the logs.
+	name() string
+	// Forwards the event.
+	write(event *dbmodel.Event) error
+	// Releases the resources used by the sink.
+	close() error
+}
+
+// Forwards the events to external systems, e.g., SIEM, using the syslog
+// protocol or the JSON lines written to a file. The events are queued and
+// forwarded in the background, so a slow collector doesn't block the event
+// center. The events are dropped when the queue is full.
+type eventForwarder struct {
+	sinks   []eventSink
+	failing []bool
+	events  chan *dbmodel.Event
+	wg      *sync.WaitGroup
+}
+
+// Creates the forwarder sending the events to the sinks specified in the
+// settings and starts its loop. It returns nil if no sink is specified.
+func newEventForwarder(settings *ForwarderSettings) (*eventForwarder, error) {
+	if settings == nil {
+		return nil, nil
+	}
+	var sinks []eventSink
+	if settings.SyslogAddress != "" {
+		sink, err := newSyslogSink(settings)
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if settings.JSONFile != "" {
+		sink, err := newJSONLinesSink(settings.JSONFile)
+		if err != nil {
+			for _, s := range sinks {
+				_ = s.close()
+			}
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return startEventForwarder(sinks), nil
+}
+
+// Starts the forwarder loop sending the events to the sinks.
+func startEventForwarder(sinks []eventSink) *eventForwarder {
+	f := &eventForwarder{
+		sinks:   sinks,
+		failing: make([]bool, len(sinks)),
+		events:  make(chan *dbmodel.Event, forwarderQueueSize),
+		wg:      &sync.WaitGroup{},
+	}
+	for _, sink := range sinks {
+		log.Infof("Forwarding events to %s", sink.name())
+	}
+	f.wg.Add(1)
+	go f.mainLoop()
+	return f
+}
+
+// Queues the event to be forwarded. The event is dropped if the queue is
+// full. It does nothing if the forwarder is nil, i.e., no sink is
+// configured.
+func (f *eventForwarder) dispatchEvent(event *dbmodel.Event) {
+	if f == nil {
+		return
+	}
+	select {
+	case f.events <- event:
+	default:
+		log.Warnf("Event forwarding queue is full; event %d is not forwarded", event.ID)
+	}
+}
+
+// Forwards the queued events to the sinks until the queue is closed.
+func (f *eventForwarder) mainLoop() {
+	defer f.wg.Done()
+	for event := range f.events {
+		for i, sink := range f.sinks {
+			err := sink.write(event)
+			// Log only the changes of the sink state to avoid flooding the
+			// log when the collector is unavailable.
+			switch {
+			case err != nil && !f.failing[i]:
+				log.WithError(err).Errorf("Failed to forward events to %s", sink.name())
+			case err == nil && f.failing[i]:
+				log.Infof("Forwarding events to %s resumed", sink.name())
+			}
+			f.failing[i] = err != nil
+		}
+	}
+}
+
+// Forwards the remaining queued events and closes the sinks.
+func (f *eventForwarder) shutdown() {
+	if f == nil {
+		return
+	}
+	close(f.events)
+	f.wg.Wait()
+	for _, sink := range f.sinks {
+		if err := sink.close(); err != nil {
+			log.WithError(err).Warnf("Problem closing %s", sink.name())
+		}
+	}
+}
+
+// Returns the time of the event. It is the current time if the event
+// doesn't have it.
+func getEventTime(event *dbmodel.Event) time.Time {
+	if event.CreatedAt.IsZero() {
+		return time.Now().UTC()
+	}
+	return event.CreatedAt.UTC()
+}
+
+// Sends the events to the syslog collector in the RFC 5424 format. The
+// messages are sent over UDP, TCP or TLS. The stream transports use the
+// octet counting framing (RFC 6587). The connection is established when
+// the first event is sent and re-established after a failure.
+type syslogSink struct {
+	protocol  string
+	address   string
+	facility  int
+	tlsConfig *tls.Config
+	hostname  string
+	pid       int
+	conn      net.Conn
+}
+
+// Creates the syslog sink. It verifies the settings but doesn't connect
+// to the collector.
+func newSyslogSink(settings *ForwarderSettings) (*syslogSink, error) {
+	if _, _, err := net.SplitHostPort(settings.SyslogAddress); err != nil {
+		return nil, errors.Wrapf(err, "invalid syslog address %s", settings.SyslogAddress)
+	}
+	if settings.SyslogFacility < 0 || settings.SyslogFacility > 23 {
+		return nil, errors.Errorf("invalid syslog facility %d; it must be between 0 and 23", settings.SyslogFacility)
+	}
+	sink := &syslogSink{
+		protocol: settings.SyslogProtocol,
+		address:  settings.SyslogAddress,
+		facility: settings.SyslogFacility,
+		hostname: "-",
+		pid:      os.Getpid(),
+	}
+	if hostname, err := os.Hostname(); err == nil && hostname != "" {
+		sink.hostname = hostname
+	}
+	switch settings.SyslogProtocol {
+	case SyslogProtocolUDP, SyslogProtocolTCP:
+	case SyslogProtocolTLS:
+		host, _, _ := net.SplitHostPort(settings.SyslogAddress)
+		sink.tlsConfig = &tls.Config{
+			ServerName: host,
+			MinVersion: tls.VersionTLS12,
+		}
+		if settings.SyslogTLSCA != "" {
+			caPEM, err := os.ReadFile(settings.SyslogTLSCA)
+			if err != nil {
+				return nil, errors.Wrapf(err, "cannot read syslog CA certificate %s", settings.SyslogTLSCA)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(caPEM) {
+				return nil, errors.Errorf("no valid certificate found in %s", settings.SyslogTLSCA)
+			}
+			sink.tlsConfig.RootCAs = pool
+		}
+	default:
+		return nil, errors.Errorf("invalid syslog protocol %s", settings.SyslogProtocol)
+	}
+	return sink, nil
+}
+
+// Returns the sink name used in the logs.
+func (s *syslogSink) name() string {
+	return fmt.Sprintf("syslog %s://%s", s.protocol, s.address)
+}
+
+// Connects to the syslog collector.
+func (s *syslogSink) connect() (err error) {
+	dialer := &net.Dialer{Timeout: syslogTimeout}
+	switch s.protocol {
+	case SyslogProtocolTLS:
+		s.conn, err = tls.DialWithDialer(dialer, "tcp", s.address, s.tlsConfig)
+	default:
+		s.conn, err = dialer.Dial(s.protocol, s.address)
+	}
+	return errors.Wrapf(err, "problem connecting to syslog collector %s", s.address)
+}
+
+// Sends the event to the syslog collector. It reconnects and tries again
+// once if sending over the existing connection fails.
+func (s *syslogSink) write(event *dbmodel.Event) error {
+	message := formatSyslogMessage(event, s.facility, s.hostname, s.pid)
+	if s.protocol != SyslogProtocolUDP {
+		message = fmt.Sprintf("%d %s", len(message), message)
+	}
+	var err error
+	for attempt := 0; attempt < 2; attempt++ {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				return err
+			}
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if _, err = s.conn.Write([]byte(message)); err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+	}
+	return errors.Wrapf(err, "problem sending event to syslog collector %s", s.address)
+}
+
+// Closes the connection to the syslog collector.
+func (s *syslogSink) close() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+// Returns the syslog severity of the event level.
+func getSyslogSeverity(level dbmodel.EventLevel) int {
+	switch level {
+	case dbmodel.EvError:
+		return 3
+	case dbmodel.EvWarning:
+		return 4
+	default:
+		return 6
+	}
+}
+
+// Escapes the structured data parameter value as required by RFC 5424.
+var syslogParamEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// Formats the event as the RFC 5424 syslog message. The IDs of the event
+// and the related objects are included in the structured data. The
+// message contains the event text without the tags describing the
+// objects.
+func formatSyslogMessage(event *dbmodel.Event, facility int, hostname string, pid int) string {
+	params := []string{
+		fmt.Sprintf(`eventId="%d"`, event.ID),
+		fmt.Sprintf(`level="%s"`, event.Level),
+	}
+	if r := event.Relations; r != nil {
+		for _, relation := range []struct {
+			name string
+			id   int64
+		}{
+			{"machineId", r.MachineID},
+			{"appId", r.AppID},
+			{"daemonId", r.DaemonID},
+			{"subnetId", r.SubnetID},
+			{"userId", r.UserID},
+		} {
+			if relation.id != 0 {
+				params = append(params, fmt.Sprintf(`%s="%d"`, relation.name, relation.id))
+			}
+		}
+	}
+	if event.Details != "" {
+		params = append(params, fmt.Sprintf(`details="%s"`, syslogParamEscaper.Replace(event.Details)))
+	}
+	return fmt.Sprintf("<%d>1 %s %s %s %d event [%s %s] %s",
+		facility*8+getSyslogSeverity(event.Level),
+		getEventTime(event).Format("2006-01-02T15:04:05.000000Z07:00"),
+		hostname, syslogAppName, pid,
+		syslogStructuredDataID, strings.Join(params, " "),
+		StripEventTags(event.Text))
+}
+
+// Event written to the JSON lines file. The text doesn't contain the tags
+// describing the objects, the tagged text is the original event text. The
+// same format is used in the archives of the pruned events.
+type forwardedEvent struct {
+	ID                  int64              `json:"id"`
+	CreatedAt           time.Time          `json:"createdAt"`
+	Level               string             `json:"level"`
+	Text                string             `json:"text"`
+	TaggedText          string             `json:"taggedText"`
+	Details             string             `json:"details,omitempty"`
+	Relations           *dbmodel.Relations `json:"relations,omitempty"`
+	MaintenanceWindowID int64              `json:"maintenanceWindowId,omitempty"`
+}
+
+// Appends the events to the file as JSON lines, i.e., each event is a JSON
+// object in a separate line. The file is opened in the append mode, so it
+// can be rotated by copying and truncating it.
+type jsonLinesSink struct {
+	path    string
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// Opens the file the events are appended to. The file is created if it
+// doesn't exist.
+func newJSONLinesSink(path string) (*jsonLinesSink, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot open events file %s", path)
+	}
+	return &jsonLinesSink{
+		path:    path,
+		file:    file,
+		encoder: json.NewEncoder(file),
+	}, nil
+}
+
+// Returns the sink name used in the logs.
+func (s *jsonLinesSink) name() string {
+	return fmt.Sprintf("file %s", s.path)
+}
+
+// Appends the event to the file.
+func (s *jsonLinesSink) write(event *dbmodel.Event) error {
+	err := s.encoder.Encode(&forwardedEvent{
+		ID:         event.ID,
+		CreatedAt:  getEventTime(event),
+		Level:      event.Level.String(),
+		Text:       StripEventTags(event.Text),
+		TaggedText: event.Text,
+		Details:    event.Details,
+		Relations:  event.Relations,
+	})
+	return errors.Wrapf(err, "problem writing event to %s", s.path)
+}
+
+// Closes the file.
+func (s *jsonLinesSink) close() error {
+	return s.file.Close()
+}
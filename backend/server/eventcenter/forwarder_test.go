@@ -0,0 +1,306 @@
+package eventcenter
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Returns the event used in the forwarder tests.
+func getTestForwardedEvent() *dbmodel.Event {
+	return &dbmodel.Event{
+		ID:        7,
+		CreatedAt: time.Date(2023, 3, 1, 12, 0, 0, 123456000, time.UTC),
+		Level:     dbmodel.EvWarning,
+		Text:      `<subnet id="4" prefix="192.0.2.0/24"> is almost full`,
+		Details:   `used "95%" [of 100]`,
+		Relations: &dbmodel.Relations{SubnetID: 4, DaemonID: 2},
+	}
+}
+
+// Test that the event is formatted as the RFC 5424 syslog message.
+func TestFormatSyslogMessage(t *testing.T) {
+	message := formatSyslogMessage(getTestForwardedEvent(), 16, "stork.example.org", 123)
+	require.Equal(t, `<132>1 2023-03-01T12:00:00.123456Z stork.example.org stork-server 123 event `+
+		`[stork@2495 eventId="7" level="warning" daemonId="2" subnetId="4" details="used \"95%\" [of 100\]"] `+
+		`192.0.2.0/24 is almost full`, message)
+
+	event := &dbmodel.Event{
+		ID:        8,
+		CreatedAt: time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC),
+		Level:     dbmodel.EvError,
+		Text:      "server failed",
+	}
+	message = formatSyslogMessage(event, 1, "-", 5)
+	require.Equal(t, `<11>1 2023-03-01T12:00:00.000000Z - stork-server 5 event `+
+		`[stork@2495 eventId="8" level="error"] server failed`, message)
+}
+
+// Test that the forwarder settings are validated.
+func TestNewEventForwarderInvalidSettings(t *testing.T) {
+	forwarder, err := newEventForwarder(nil)
+	require.NoError(t, err)
+	require.Nil(t, forwarder)
+
+	forwarder, err = newEventForwarder(&ForwarderSettings{SyslogProtocol: SyslogProtocolUDP})
+	require.NoError(t, err)
+	require.Nil(t, forwarder)
+
+	_, err = newEventForwarder(&ForwarderSettings{
+		SyslogAddress:  "localhost",
+		SyslogProtocol: SyslogProtocolUDP,
+	})
+	require.ErrorContains(t, err, "invalid syslog address")
+
+	_, err = newEventForwarder(&ForwarderSettings{
+		SyslogAddress:  "localhost:514",
+		SyslogProtocol: SyslogProtocolUDP,
+		SyslogFacility: 24,
+	})
+	require.ErrorContains(t, err, "invalid syslog facility")
+
+	_, err = newEventForwarder(&ForwarderSettings{
+		SyslogAddress:  "localhost:514",
+		SyslogProtocol: "http",
+	})
+	require.ErrorContains(t, err, "invalid syslog protocol")
+
+	_, err = newEventForwarder(&ForwarderSettings{
+		SyslogAddress:  "localhost:6514",
+		SyslogProtocol: SyslogProtocolTLS,
+		SyslogTLSCA:    filepath.Join(t.TempDir(), "missing.pem"),
+	})
+	require.ErrorContains(t, err, "cannot read syslog CA certificate")
+
+	_, err = newEventForwarder(&ForwarderSettings{
+		JSONFile: filepath.Join(t.TempDir(), "missing", "events.json"),
+	})
+	require.ErrorContains(t, err, "cannot open events file")
+}
+
+// Test that the events are sent to the syslog collector over UDP.
+func TestSyslogSinkUDP(t *testing.T) {
+	collector, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer collector.Close()
+
+	sink, err := newSyslogSink(&ForwarderSettings{
+		SyslogAddress:  collector.LocalAddr().String(),
+		SyslogProtocol: SyslogProtocolUDP,
+		SyslogFacility: 16,
+	})
+	require.NoError(t, err)
+	defer sink.close()
+	require.Equal(t, "syslog udp://"+collector.LocalAddr().String(), sink.name())
+
+	require.NoError(t, sink.write(getTestForwardedEvent()))
+
+	buffer := make([]byte, 1024)
+	_ = collector.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := collector.ReadFrom(buffer)
+	require.NoError(t, err)
+	message := string(buffer[:n])
+	require.True(t, strings.HasPrefix(message, "<132>1 2023-03-01T12:00:00.123456Z "))
+	require.True(t, strings.HasSuffix(message, "] 192.0.2.0/24 is almost full"))
+}
+
+// Reads the syslog message framed using the octet counting.
+func readFramedSyslogMessage(t *testing.T, reader *bufio.Reader) string {
+	length, err := reader.ReadString(' ')
+	require.NoError(t, err)
+	n, err := strconv.Atoi(strings.TrimSpace(length))
+	require.NoError(t, err)
+	message := make([]byte, n)
+	_, err = reader.Read(message)
+	require.NoError(t, err)
+	return string(message)
+}
+
+// Test that the events are sent to the syslog collector over TCP and the
+// sink reconnects when the connection is closed.
+func TestSyslogSinkTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sink, err := newSyslogSink(&ForwarderSettings{
+		SyslogAddress:  listener.Addr().String(),
+		SyslogProtocol: SyslogProtocolTCP,
+		SyslogFacility: 16,
+	})
+	require.NoError(t, err)
+	defer sink.close()
+
+	require.NoError(t, sink.write(getTestForwardedEvent()))
+
+	conn, err := listener.Accept()
+	require.NoError(t, err)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	message := readFramedSyslogMessage(t, bufio.NewReader(conn))
+	require.True(t, strings.HasPrefix(message, "<132>1 "))
+	require.True(t, strings.HasSuffix(message, "] 192.0.2.0/24 is almost full"))
+
+	// Close the connection on the collector side. The first write may
+	// succeed because the closure is not detected yet, so write until the
+	// sink reconnects.
+	conn.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err == nil {
+			accepted <- conn
+		}
+	}()
+	require.Eventually(t, func() bool {
+		_ = sink.write(getTestForwardedEvent())
+		return len(accepted) > 0
+	}, 5*time.Second, 50*time.Millisecond)
+	conn = <-accepted
+	defer conn.Close()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	message = readFramedSyslogMessage(t, bufio.NewReader(conn))
+	require.True(t, strings.HasPrefix(message, "<132>1 "))
+}
+
+// Test that the events are sent to the syslog collector over TLS.
+func TestSyslogSinkTLS(t *testing.T) {
+	serverTLS, clientTLS := getSMTPTestTLSConfigs(t)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	sink, err := newSyslogSink(&ForwarderSettings{
+		SyslogAddress:  listener.Addr().String(),
+		SyslogProtocol: SyslogProtocolTLS,
+		SyslogFacility: 16,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "127.0.0.1", sink.tlsConfig.ServerName)
+	sink.tlsConfig = clientTLS
+	defer sink.close()
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		tlsConn := tls.Server(conn, serverTLS)
+		_ = tlsConn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		reader := bufio.NewReader(tlsConn)
+		length, err := reader.ReadString(' ')
+		if err != nil {
+			return
+		}
+		n, _ := strconv.Atoi(strings.TrimSpace(length))
+		message := make([]byte, n)
+		if _, err = reader.Read(message); err == nil {
+			received <- string(message)
+		}
+	}()
+
+	require.NoError(t, sink.write(getTestForwardedEvent()))
+	select {
+	case message := <-received:
+		require.True(t, strings.HasSuffix(message, "] 192.0.2.0/24 is almost full"))
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "message not received")
+	}
+}
+
+// Test that the events are appended to the file as JSON lines.
+func TestJSONLinesSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.json")
+	require.NoError(t, os.WriteFile(path, []byte("{\"id\":1}\n"), 0o600))
+
+	sink, err := newJSONLinesSink(path)
+	require.NoError(t, err)
+	require.NoError(t, sink.write(getTestForwardedEvent()))
+	require.NoError(t, sink.write(&dbmodel.Event{ID: 8, Text: "server started"}))
+	require.NoError(t, sink.close())
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 3)
+	require.JSONEq(t, `{
+		"id": 7,
+		"createdAt": "2023-03-01T12:00:00.123456Z",
+		"level": "warning",
+		"text": "192.0.2.0/24 is almost full",
+		"taggedText": "<subnet id=\"4\" prefix=\"192.0.2.0/24\"> is almost full",
+		"details": "used \"95%\" [of 100]",
+		"relations": {"SubnetID": 4, "DaemonID": 2}
+	}`, lines[1])
+
+	var event map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[2]), &event))
+	require.EqualValues(t, 8, event["id"])
+	require.Equal(t, "info", event["level"])
+	require.NotEmpty(t, event["createdAt"])
+	require.NotContains(t, event, "relations")
+}
+
+// Sink recording the forwarded events.
+type testEventSink struct {
+	mutex  sync.Mutex
+	events []*dbmodel.Event
+	block  chan struct{}
+	closed bool
+}
+
+func (s *testEventSink) name() string {
+	return "test"
+}
+
+func (s *testEventSink) write(event *dbmodel.Event) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, event)
+	return nil
+}
+
+func (s *testEventSink) close() error {
+	s.closed = true
+	return nil
+}
+
+// Test that the forwarder drops the events when the queue is full instead
+// of blocking and that it forwards the queued events on shutdown.
+func TestEventForwarderQueue(t *testing.T) {
+	sink := &testEventSink{block: make(chan struct{})}
+	forwarder := startEventForwarder([]eventSink{sink})
+
+	// The first event is taken from the queue and blocks the sink. The
+	// queue holds the next events and the remaining ones are dropped.
+	for i := 0; i < forwarderQueueSize+10; i++ {
+		forwarder.dispatchEvent(&dbmodel.Event{ID: int64(i)})
+	}
+	close(sink.block)
+	forwarder.shutdown()
+
+	require.True(t, sink.closed)
+	require.GreaterOrEqual(t, len(sink.events), forwarderQueueSize)
+	require.Less(t, len(sink.events), forwarderQueueSize+10)
+	require.EqualValues(t, 0, sink.events[0].ID)
+
+	// The nil forwarder does nothing.
+	var disabled *eventForwarder
+	disabled.dispatchEvent(&dbmodel.Event{})
+	disabled.shutdown()
+}
@@ -0,0 +1,159 @@
+package eventcenter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Interval of reloading the maintenance windows and checking if they
+// started or ended.
+const maintenanceCheckInterval = 30 * time.Second
+
+// Tracks the maintenance windows. It holds the windows loaded from the
+// database, so the events can be matched with the windows without
+// querying the database.
+type maintenanceTracker struct {
+	db      *dbops.PgDB
+	windows []dbmodel.MaintenanceWindow
+}
+
+// Creates the tracker of the maintenance windows.
+func newMaintenanceTracker(db *dbops.PgDB) *maintenanceTracker {
+	return &maintenanceTracker{db: db}
+}
+
+// Checks if the maintenance window is valid.
+func ValidateMaintenanceWindow(window *dbmodel.MaintenanceWindow) error {
+	if window.Name == "" {
+		return errors.New("maintenance window name must not be empty")
+	}
+	scopes := 0
+	for _, id := range []int64{window.MachineID, window.AppID, window.ServiceID} {
+		if id != 0 {
+			scopes++
+		}
+	}
+	if scopes != 1 {
+		return errors.New("maintenance window must specify exactly one machine, app or service")
+	}
+	if !window.EndsAt.After(window.StartsAt) {
+		return errors.New("maintenance window must end after it starts")
+	}
+	switch window.Recurrence {
+	case dbmodel.MaintenanceRecurrenceNone, dbmodel.MaintenanceRecurrenceDaily, dbmodel.MaintenanceRecurrenceWeekly:
+	default:
+		return errors.Errorf("invalid maintenance window recurrence %s", window.Recurrence)
+	}
+	if period := window.GetPeriod(); period != 0 && window.EndsAt.Sub(window.StartsAt) >= period {
+		return errors.Errorf("%s maintenance window must be shorter than its period", window.Recurrence)
+	}
+	return nil
+}
+
+// Creates the event raised when the maintenance window starts or ends.
+func createMaintenanceEvent(window *dbmodel.MaintenanceWindow, started bool) *dbmodel.Event {
+	action := "ended"
+	if started {
+		action = "started"
+	}
+	var objects []interface{}
+	target := "{machine}"
+	switch {
+	case window.Machine != nil:
+		objects = append(objects, window.Machine)
+	case window.App != nil:
+		target = "{app}"
+		objects = append(objects, window.App)
+	case window.Service != nil:
+		target = fmt.Sprintf("service %s", window.Service.Name)
+	default:
+		target = "deleted object"
+	}
+	objects = append(objects, window.Description)
+	text := fmt.Sprintf("Maintenance window %s %s for %s", window.Name, action, target)
+	return CreateEvent(dbmodel.EvInfo, text, objects...)
+}
+
+// Reloads the maintenance windows from the database and checks which
+// windows started or ended since the last check. It returns the events
+// to be raised for these windows. The now value is the current time.
+func (t *maintenanceTracker) refresh(now time.Time) (events []*dbmodel.Event) {
+	windows, err := dbmodel.GetMaintenanceWindows(t.db)
+	if err != nil {
+		log.WithError(err).Error("Problem getting maintenance windows from the database")
+		return nil
+	}
+	t.windows = windows
+	for i := range windows {
+		window := &windows[i]
+		active := window.IsActive(now)
+		if active == window.Active {
+			continue
+		}
+		if err = dbmodel.SetMaintenanceWindowActive(t.db, window.ID, active); err != nil {
+			log.WithError(err).Errorf("Problem updating state of maintenance window %s", window.Name)
+			continue
+		}
+		window.Active = active
+		events = append(events, createMaintenanceEvent(window, active))
+	}
+	return events
+}
+
+// Checks if the maintenance window covers any of the objects the event
+// is related to. The service window covers the daemons belonging to the
+// service and the events related to their apps but not to any particular
+// daemon.
+func maintenanceWindowCovers(window *dbmodel.MaintenanceWindow, relations *dbmodel.Relations) bool {
+	if relations == nil {
+		return false
+	}
+	switch {
+	case window.MachineID != 0:
+		return relations.MachineID == window.MachineID
+	case window.AppID != 0:
+		return relations.AppID == window.AppID
+	case window.Service != nil:
+		for _, daemon := range window.Service.Daemons {
+			if relations.DaemonID != 0 {
+				if relations.DaemonID == daemon.ID {
+					return true
+				}
+			} else if relations.AppID != 0 && relations.AppID == daemon.AppID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Returns the maintenance window active at the specified time and covering
+// the objects the event is related to. It returns nil if the event isn't
+// raised in any maintenance window.
+func (t *maintenanceTracker) match(event *dbmodel.Event, now time.Time) *dbmodel.MaintenanceWindow {
+	for i := range t.windows {
+		if t.windows[i].IsActive(now) && maintenanceWindowCovers(&t.windows[i], event.Relations) {
+			return &t.windows[i]
+		}
+	}
+	return nil
+}
+
+// Tags the event with the maintenance window and lowers its level if the
+// window downgrades the events.
+func applyMaintenanceWindow(event *dbmodel.Event, window *dbmodel.MaintenanceWindow) {
+	event.MaintenanceWindowID = window.ID
+	if window.Downgrade && event.Level > dbmodel.EvInfo {
+		details := fmt.Sprintf("Level lowered from %s during maintenance window %s", event.Level, window.Name)
+		if event.Details != "" {
+			details = event.Details + "\n" + details
+		}
+		event.Details = details
+		event.Level = dbmodel.EvInfo
+	}
+}
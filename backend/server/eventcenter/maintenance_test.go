@@ -0,0 +1,212 @@
+package eventcenter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test the validation of the maintenance windows.
+func TestValidateMaintenanceWindow(t *testing.T) {
+	startsAt := time.Date(2023, 3, 1, 22, 0, 0, 0, time.UTC)
+	window := &dbmodel.MaintenanceWindow{
+		Name:       "upgrade",
+		MachineID:  1,
+		StartsAt:   startsAt,
+		EndsAt:     startsAt.Add(2 * time.Hour),
+		Recurrence: dbmodel.MaintenanceRecurrenceDaily,
+	}
+	require.NoError(t, ValidateMaintenanceWindow(window))
+
+	// Exactly one object must be specified.
+	window.AppID = 2
+	require.Error(t, ValidateMaintenanceWindow(window))
+	window.MachineID = 0
+	require.NoError(t, ValidateMaintenanceWindow(window))
+	window.AppID = 0
+	require.Error(t, ValidateMaintenanceWindow(window))
+	window.ServiceID = 3
+
+	// The window must end after it starts.
+	window.EndsAt = startsAt
+	require.Error(t, ValidateMaintenanceWindow(window))
+
+	// The recurring window must be shorter than its period.
+	window.EndsAt = startsAt.Add(24 * time.Hour)
+	require.Error(t, ValidateMaintenanceWindow(window))
+	window.Recurrence = dbmodel.MaintenanceRecurrenceWeekly
+	require.NoError(t, ValidateMaintenanceWindow(window))
+	window.Recurrence = dbmodel.MaintenanceRecurrenceNone
+	window.EndsAt = startsAt.Add(30 * 24 * time.Hour)
+	require.NoError(t, ValidateMaintenanceWindow(window))
+
+	window.Recurrence = "monthly"
+	require.Error(t, ValidateMaintenanceWindow(window))
+	window.Recurrence = dbmodel.MaintenanceRecurrenceNone
+
+	window.Name = ""
+	require.Error(t, ValidateMaintenanceWindow(window))
+}
+
+// Test that the maintenance windows cover the events related to their
+// machines, apps and services.
+func TestMaintenanceWindowCovers(t *testing.T) {
+	machineWindow := &dbmodel.MaintenanceWindow{MachineID: 1}
+	require.True(t, maintenanceWindowCovers(machineWindow, &dbmodel.Relations{MachineID: 1, AppID: 2}))
+	require.False(t, maintenanceWindowCovers(machineWindow, &dbmodel.Relations{MachineID: 2}))
+	require.False(t, maintenanceWindowCovers(machineWindow, &dbmodel.Relations{AppID: 2}))
+	require.False(t, maintenanceWindowCovers(machineWindow, nil))
+
+	appWindow := &dbmodel.MaintenanceWindow{AppID: 2}
+	require.True(t, maintenanceWindowCovers(appWindow, &dbmodel.Relations{AppID: 2, DaemonID: 5}))
+	require.False(t, maintenanceWindowCovers(appWindow, &dbmodel.Relations{AppID: 3}))
+
+	serviceWindow := &dbmodel.MaintenanceWindow{
+		ServiceID: 3,
+		Service: &dbmodel.BaseService{
+			ID: 3,
+			Daemons: []*dbmodel.Daemon{
+				{ID: 5, AppID: 2},
+				{ID: 6, AppID: 4},
+			},
+		},
+	}
+	require.True(t, maintenanceWindowCovers(serviceWindow, &dbmodel.Relations{AppID: 2, DaemonID: 5}))
+	require.True(t, maintenanceWindowCovers(serviceWindow, &dbmodel.Relations{DaemonID: 6}))
+	require.True(t, maintenanceWindowCovers(serviceWindow, &dbmodel.Relations{AppID: 4}))
+	// Other daemon of the app belonging to the service.
+	require.False(t, maintenanceWindowCovers(serviceWindow, &dbmodel.Relations{AppID: 2, DaemonID: 7}))
+	require.False(t, maintenanceWindowCovers(serviceWindow, &dbmodel.Relations{MachineID: 1}))
+}
+
+// Test that the event is matched with the active maintenance window.
+func TestMaintenanceTrackerMatch(t *testing.T) {
+	startsAt := time.Date(2023, 3, 1, 22, 0, 0, 0, time.UTC)
+	tracker := newMaintenanceTracker(nil)
+	tracker.windows = []dbmodel.MaintenanceWindow{
+		{
+			ID:         1,
+			MachineID:  1,
+			StartsAt:   startsAt,
+			EndsAt:     startsAt.Add(time.Hour),
+			Recurrence: dbmodel.MaintenanceRecurrenceNone,
+		},
+		{
+			ID:         2,
+			AppID:      2,
+			StartsAt:   startsAt,
+			EndsAt:     startsAt.Add(time.Hour),
+			Recurrence: dbmodel.MaintenanceRecurrenceDaily,
+		},
+	}
+	event := &dbmodel.Event{
+		Relations: &dbmodel.Relations{MachineID: 1, AppID: 2},
+	}
+	window := tracker.match(event, startsAt.Add(30*time.Minute))
+	require.NotNil(t, window)
+	require.EqualValues(t, 1, window.ID)
+
+	window = tracker.match(event, startsAt.Add(24*time.Hour+30*time.Minute))
+	require.NotNil(t, window)
+	require.EqualValues(t, 2, window.ID)
+
+	require.Nil(t, tracker.match(event, startsAt.Add(2*time.Hour)))
+	require.Nil(t, tracker.match(&dbmodel.Event{}, startsAt))
+}
+
+// Test that the event is tagged with the maintenance window and its level
+// is lowered if the window downgrades the events.
+func TestApplyMaintenanceWindow(t *testing.T) {
+	window := &dbmodel.MaintenanceWindow{ID: 7, Name: "upgrade"}
+	event := &dbmodel.Event{Level: dbmodel.EvError, Details: "connection refused"}
+	applyMaintenanceWindow(event, window)
+	require.EqualValues(t, 7, event.MaintenanceWindowID)
+	require.Equal(t, dbmodel.EvError, event.Level)
+	require.Equal(t, "connection refused", event.Details)
+
+	window.Downgrade = true
+	applyMaintenanceWindow(event, window)
+	require.Equal(t, dbmodel.EvInfo, event.Level)
+	require.Equal(t, "connection refused\nLevel lowered from error during maintenance window upgrade", event.Details)
+
+	event = &dbmodel.Event{Level: dbmodel.EvWarning}
+	applyMaintenanceWindow(event, window)
+	require.Equal(t, dbmodel.EvInfo, event.Level)
+	require.Equal(t, "Level lowered from warning during maintenance window upgrade", event.Details)
+}
+
+// Test that the event is created when the maintenance window starts or ends.
+func TestCreateMaintenanceEvent(t *testing.T) {
+	window := &dbmodel.MaintenanceWindow{
+		Name:        "upgrade",
+		Description: "OS upgrade",
+		MachineID:   1,
+		Machine: &dbmodel.Machine{
+			ID:      1,
+			Address: "192.0.2.1",
+		},
+	}
+	event := createMaintenanceEvent(window, true)
+	require.Equal(t, dbmodel.EvInfo, event.Level)
+	require.Equal(t, `Maintenance window upgrade started for <machine id="1" address="192.0.2.1" hostname="">`, event.Text)
+	require.Equal(t, "OS upgrade", event.Details)
+	require.EqualValues(t, 1, event.Relations.MachineID)
+
+	window = &dbmodel.MaintenanceWindow{
+		Name:      "failover test",
+		ServiceID: 3,
+		Service:   &dbmodel.BaseService{ID: 3, Name: "ha"},
+	}
+	event = createMaintenanceEvent(window, false)
+	require.Equal(t, "Maintenance window failover test ended for service ha", event.Text)
+	require.Empty(t, event.Details)
+}
+
+// Test that the tracker raises the events when the maintenance windows
+// start and end.
+func TestMaintenanceTrackerRefresh(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	machine := &dbmodel.Machine{
+		Address:   "192.0.2.1",
+		AgentPort: 8080,
+	}
+	require.NoError(t, dbmodel.AddMachine(db, machine))
+
+	startsAt := time.Date(2023, 3, 1, 22, 0, 0, 0, time.UTC)
+	window := &dbmodel.MaintenanceWindow{
+		Name:       "upgrade",
+		MachineID:  machine.ID,
+		StartsAt:   startsAt,
+		EndsAt:     startsAt.Add(time.Hour),
+		Recurrence: dbmodel.MaintenanceRecurrenceNone,
+	}
+	require.NoError(t, dbmodel.AddMaintenanceWindow(db, window))
+
+	tracker := newMaintenanceTracker(db)
+	require.Empty(t, tracker.refresh(startsAt.Add(-time.Minute)))
+	require.Len(t, tracker.windows, 1)
+
+	events := tracker.refresh(startsAt)
+	require.Len(t, events, 1)
+	require.Contains(t, events[0].Text, "Maintenance window upgrade started")
+	require.EqualValues(t, machine.ID, events[0].Relations.MachineID)
+
+	// The state is stored in the database.
+	returned, err := dbmodel.GetMaintenanceWindowByID(db, window.ID)
+	require.NoError(t, err)
+	require.True(t, returned.Active)
+	require.Empty(t, tracker.refresh(startsAt.Add(time.Minute)))
+	require.NotNil(t, tracker.match(&dbmodel.Event{
+		Relations: &dbmodel.Relations{MachineID: machine.ID},
+	}, startsAt.Add(time.Minute)))
+
+	events = tracker.refresh(startsAt.Add(time.Hour))
+	require.Len(t, events, 1)
+	require.Contains(t, events[0].Text, "Maintenance window upgrade ended")
+	require.Empty(t, tracker.refresh(startsAt.Add(2*time.Hour)))
+}
@@ -0,0 +1,205 @@
+package eventcenter
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	dbmodel "isc.org/stork/server/database/model"
+	storkutil "isc.org/stork/util"
+)
+
+// Name of the setting holding the number of days the events are kept.
+// The zero value means that the events are not pruned by age.
+const RetentionDaysSettingName = "event_retention_days"
+
+// Names of the settings holding the maximum numbers of the events of
+// each level kept in the database. The zero value means no limit.
+var retentionMaxCountSettingNames = map[dbmodel.EventLevel]string{
+	dbmodel.EvInfo:    "event_retention_max_info",
+	dbmodel.EvWarning: "event_retention_max_warning",
+	dbmodel.EvError:   "event_retention_max_error",
+}
+
+// Interval of the event pruning in seconds.
+const eventPruneInterval int64 = 3600
+
+// Maximum number of the events archived and deleted in a single batch.
+const eventPruneBatchSize = 1000
+
+// Event retention settings specified on the command line.
+type RetentionSettings struct {
+	ArchiveDirectory string `long:"events-archive-dir" description:"The directory the pruned events are archived to as compressed JSON lines files; the pruned events are not archived if not specified" env:"STORK_SERVER_EVENTS_ARCHIVE_DIR"`
+}
+
+// Background worker periodically deleting the events exceeding the
+// retention limits specified in the settings.
+type Pruner struct {
+	db         *pg.DB
+	archiveDir string
+	executor   *storkutil.PeriodicExecutor
+}
+
+// Creates the pruner and starts deleting the events exceeding the
+// retention limits periodically. The pruned events are archived if the
+// archive directory is specified in the settings.
+func NewPruner(db *pg.DB, settings *RetentionSettings) (*Pruner, error) {
+	pruner := &Pruner{db: db}
+	if settings != nil {
+		pruner.archiveDir = settings.ArchiveDirectory
+	}
+	executor, err := storkutil.NewPeriodicExecutor("event pruner", pruner.prune,
+		func() (int64, error) {
+			return eventPruneInterval, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	pruner.executor = executor
+	return pruner, nil
+}
+
+// Deletes the events exceeding the retention limits.
+func (pruner *Pruner) prune() error {
+	_, err := Prune(pruner.db, pruner.archiveDir, time.Now().UTC())
+	return err
+}
+
+// Stops pruning the events.
+func (pruner *Pruner) Shutdown() {
+	pruner.executor.Shutdown()
+}
+
+// Writes the pruned events to a gzip-compressed JSON lines file. The file
+// is created when the first event is written.
+type eventArchive struct {
+	path   string
+	file   *os.File
+	writer *gzip.Writer
+}
+
+// Creates the archive of the events pruned at the specified time. The
+// file is created in the specified directory.
+func newEventArchive(dir string, now time.Time) *eventArchive {
+	name := fmt.Sprintf("events-%s.jsonl.gz", now.UTC().Format("20060102T150405Z"))
+	return &eventArchive{path: filepath.Join(dir, name)}
+}
+
+// Appends the events to the archive. The written data is flushed, so the
+// events can be safely deleted from the database.
+func (a *eventArchive) write(events []dbmodel.Event) error {
+	if a.file == nil {
+		file, err := os.OpenFile(a.path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o640)
+		if err != nil {
+			return errors.Wrapf(err, "cannot create events archive %s", a.path)
+		}
+		a.file = file
+		a.writer = gzip.NewWriter(file)
+	}
+	encoder := json.NewEncoder(a.writer)
+	for i := range events {
+		event := &events[i]
+		err := encoder.Encode(&forwardedEvent{
+			ID:                  event.ID,
+			CreatedAt:           event.CreatedAt,
+			Level:               event.Level.String(),
+			Text:                StripEventTags(event.Text),
+			TaggedText:          event.Text,
+			Details:             event.Details,
+			Relations:           event.Relations,
+			MaintenanceWindowID: event.MaintenanceWindowID,
+		})
+		if err != nil {
+			return errors.Wrapf(err, "problem writing event to %s", a.path)
+		}
+	}
+	if err := a.writer.Flush(); err != nil {
+		return errors.Wrapf(err, "problem writing events to %s", a.path)
+	}
+	return errors.Wrapf(a.file.Sync(), "problem writing events to %s", a.path)
+}
+
+// Completes the archive and closes the file.
+func (a *eventArchive) close() error {
+	if a.file == nil {
+		return nil
+	}
+	err := a.writer.Close()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	return errors.Wrapf(err, "problem closing events archive %s", a.path)
+}
+
+// Deletes the events older than the retention period and the events
+// exceeding the maximum numbers of the events of each level specified in
+// the settings. The events are archived to a new file in the archive
+// directory before they are deleted, unless the directory is empty. The
+// now value is the current time. It returns the number of the deleted
+// events.
+func Prune(db *pg.DB, archiveDir string, now time.Time) (int64, error) {
+	days, err := dbmodel.GetSettingInt(db, RetentionDaysSettingName)
+	if err != nil {
+		return 0, errors.WithMessagef(err, "problem getting setting %s from db", RetentionDaysSettingName)
+	}
+	var before time.Time
+	if days > 0 {
+		before = now.AddDate(0, 0, -int(days))
+	}
+	maxCounts := make(map[dbmodel.EventLevel]int64)
+	for level, name := range retentionMaxCountSettingNames {
+		if maxCounts[level], err = dbmodel.GetSettingInt(db, name); err != nil {
+			return 0, errors.WithMessagef(err, "problem getting setting %s from db", name)
+		}
+	}
+
+	var archive *eventArchive
+	if archiveDir != "" {
+		archive = newEventArchive(archiveDir, now)
+	}
+	var count int64
+	for {
+		var events []dbmodel.Event
+		events, err = dbmodel.GetEventsToPrune(db, before, maxCounts, eventPruneBatchSize)
+		if err != nil || len(events) == 0 {
+			break
+		}
+		if archive != nil {
+			if err = archive.write(events); err != nil {
+				break
+			}
+		}
+		ids := make([]int64, len(events))
+		for i := range events {
+			ids[i] = events[i].ID
+		}
+		var deleted int64
+		if deleted, err = dbmodel.DeleteEvents(db, ids); err != nil {
+			break
+		}
+		count += deleted
+		if len(events) < eventPruneBatchSize {
+			break
+		}
+	}
+	if archive != nil {
+		if closeErr := archive.close(); err == nil {
+			err = closeErr
+		}
+	}
+	if count > 0 {
+		fields := log.Fields{"count": count}
+		if archive != nil {
+			fields["archive"] = archive.path
+		}
+		log.WithFields(fields).Info("Deleted events exceeding the retention limits")
+	}
+	return count, err
+}
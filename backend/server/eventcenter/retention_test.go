@@ -0,0 +1,142 @@
+package eventcenter
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/testutil"
+)
+
+// Test that the events exceeding the retention limits are deleted.
+func TestPrune(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	require.NoError(t, dbmodel.SetSettingInt(db, RetentionDaysSettingName, 7))
+	require.NoError(t, dbmodel.SetSettingInt(db, "event_retention_max_warning", 1))
+
+	now := time.Now().UTC()
+	for _, days := range []int{30, 8, 6} {
+		require.NoError(t, dbmodel.AddEvent(db, &dbmodel.Event{
+			CreatedAt: now.AddDate(0, 0, -days),
+			Text:      "info",
+			Level:     dbmodel.EvInfo,
+		}))
+	}
+	for _, days := range []int{2, 1} {
+		require.NoError(t, dbmodel.AddEvent(db, &dbmodel.Event{
+			CreatedAt: now.AddDate(0, 0, -days),
+			Text:      "warning",
+			Level:     dbmodel.EvWarning,
+		}))
+	}
+
+	count, err := Prune(db, "", now)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, count)
+	events, total, err := dbmodel.GetEventsByPage(db, 0, 10, dbmodel.EvInfo, nil, nil, nil, nil, "", dbmodel.SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.WithinDuration(t, now.AddDate(0, 0, -6), events[0].CreatedAt, time.Second)
+	require.WithinDuration(t, now.AddDate(0, 0, -1), events[1].CreatedAt, time.Second)
+
+	// The zero values disable pruning.
+	require.NoError(t, dbmodel.SetSettingInt(db, RetentionDaysSettingName, 0))
+	require.NoError(t, dbmodel.SetSettingInt(db, "event_retention_max_warning", 0))
+	count, err = Prune(db, "", now.AddDate(1, 0, 0))
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+// Test that the pruned events are archived.
+func TestPruneArchive(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	require.NoError(t, dbmodel.SetSettingInt(db, "event_retention_max_error", 1))
+
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < eventPruneBatchSize+2; i++ {
+		require.NoError(t, dbmodel.AddEvent(db, &dbmodel.Event{
+			CreatedAt: now.Add(time.Duration(i-eventPruneBatchSize-2) * time.Second),
+			Text:      `<machine id="1" address="192.0.2.1" hostname="foo"> is unreachable`,
+			Level:     dbmodel.EvError,
+			Relations: &dbmodel.Relations{MachineID: 1},
+		}))
+	}
+
+	count, err := Prune(db, sb.BasePath, now)
+	require.NoError(t, err)
+	require.EqualValues(t, eventPruneBatchSize+1, count)
+
+	// All pruned events are in the archive.
+	path := filepath.Join(sb.BasePath, "events-20230301T120000Z.jsonl.gz")
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+	reader, err := gzip.NewReader(file)
+	require.NoError(t, err)
+	scanner := bufio.NewScanner(reader)
+	var lines int
+	for scanner.Scan() {
+		var event forwardedEvent
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		require.Equal(t, "error", event.Level)
+		require.Equal(t, "192.0.2.1 is unreachable", event.Text)
+		require.EqualValues(t, 1, event.Relations.MachineID)
+		lines++
+	}
+	require.NoError(t, scanner.Err())
+	require.Equal(t, eventPruneBatchSize+1, lines)
+
+	// The archive is not created when there are no events to prune.
+	count, err = Prune(db, sb.BasePath, now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, count)
+	_, err = os.Stat(filepath.Join(sb.BasePath, "events-20230301T130000Z.jsonl.gz"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
+
+// Test that the events are not deleted when they cannot be archived.
+func TestPruneArchiveError(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	require.NoError(t, dbmodel.SetSettingInt(db, "event_retention_max_info", 1))
+	for i := 0; i < 2; i++ {
+		require.NoError(t, dbmodel.AddEvent(db, &dbmodel.Event{Text: "info", Level: dbmodel.EvInfo}))
+	}
+
+	count, err := Prune(db, "/non/existing/dir", time.Now().UTC())
+	require.Error(t, err)
+	require.Zero(t, count)
+	_, total, err := dbmodel.GetEventsByPage(db, 0, 10, dbmodel.EvInfo, nil, nil, nil, nil, "", dbmodel.SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+}
+
+// Test that the pruner can be started and stopped.
+func TestNewPruner(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	pruner, err := NewPruner(db, &RetentionSettings{ArchiveDirectory: "/tmp"})
+	require.NoError(t, err)
+	require.NotNil(t, pruner)
+	require.Equal(t, "/tmp", pruner.archiveDir)
+	pruner.Shutdown()
+}
@@ -0,0 +1,183 @@
+package eventcenter
+
+import (
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	errors "github.com/pkg/errors"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Security of the connection to the SMTP server. The STARTTLS mode
+// upgrades the plain connection to TLS and fails if the server doesn't
+// support it. The TLS mode uses TLS from the beginning (typically on the
+// port 465).
+const (
+	SMTPSecurityNone     = "none"
+	SMTPSecuritySTARTTLS = "starttls"
+	SMTPSecurityTLS      = "tls"
+)
+
+// Timeout of the whole SMTP session.
+const smtpTimeout = 30 * time.Second
+
+// Configuration of the SMTP server used to send the email notifications.
+// It is read from the global settings. The empty host disables the email
+// notifications. The empty username disables the authentication.
+type SMTPConfig struct {
+	Host     string
+	Port     int64
+	Security string
+	Username string
+	Password string
+	Sender   string
+	// TLS configuration overriding the default one. It is used in the
+	// unit tests to trust the test server certificate.
+	tlsConfig *tls.Config
+}
+
+// Checks if the security mode is supported.
+func IsValidSMTPSecurity(security string) bool {
+	switch security {
+	case SMTPSecurityNone, SMTPSecuritySTARTTLS, SMTPSecurityTLS:
+		return true
+	default:
+		return false
+	}
+}
+
+// Reads the SMTP configuration from the global settings.
+func GetSMTPConfig(db *pg.DB) (*SMTPConfig, error) {
+	settings, err := dbmodel.GetAllSettings(db)
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot get the SMTP configuration")
+	}
+	config := &SMTPConfig{}
+	config.Host, _ = settings["smtp_host"].(string)
+	config.Port, _ = settings["smtp_port"].(int64)
+	config.Security, _ = settings["smtp_security"].(string)
+	config.Username, _ = settings["smtp_username"].(string)
+	config.Sender, _ = settings["smtp_sender"].(string)
+	if config.Username != "" {
+		config.Password, err = dbmodel.GetSettingPasswd(db, "smtp_password")
+		if err != nil {
+			return nil, errors.WithMessage(err, "cannot get the SMTP password")
+		}
+	}
+	return config, nil
+}
+
+// Checks if the email notifications are enabled.
+func (config *SMTPConfig) IsEnabled() bool {
+	return config.Host != ""
+}
+
+// Returns the TLS configuration used to connect to the SMTP server.
+func (config *SMTPConfig) getTLSConfig() *tls.Config {
+	if config.tlsConfig != nil {
+		return config.tlsConfig
+	}
+	return &tls.Config{
+		ServerName: config.Host,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// Sends the message to the recipients using the SMTP server. The message
+// must contain the headers. The password is sent only over the encrypted
+// connections or to the local server.
+func sendMail(config *SMTPConfig, sender string, recipients []string, message []byte) error {
+	if !IsValidSMTPSecurity(config.Security) {
+		return errors.Errorf("invalid SMTP security mode %s", config.Security)
+	}
+	address := net.JoinHostPort(config.Host, strconv.FormatInt(config.Port, 10))
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var (
+		conn net.Conn
+		err  error
+	)
+	if config.Security == SMTPSecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, config.getTLSConfig())
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "problem connecting to SMTP server %s", address)
+	}
+	_ = conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	client, err := smtp.NewClient(conn, config.Host)
+	if err != nil {
+		conn.Close()
+		return errors.Wrapf(err, "problem starting SMTP session with %s", address)
+	}
+	defer client.Close()
+
+	if config.Security == SMTPSecuritySTARTTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return errors.Errorf("SMTP server %s does not support STARTTLS", address)
+		}
+		if err = client.StartTLS(config.getTLSConfig()); err != nil {
+			return errors.Wrapf(err, "problem starting TLS with SMTP server %s", address)
+		}
+	}
+	if config.Username != "" {
+		auth := smtp.PlainAuth("", config.Username, config.Password, config.Host)
+		if err = client.Auth(auth); err != nil {
+			return errors.Wrapf(err, "problem authenticating to SMTP server %s", address)
+		}
+	}
+	if err = client.Mail(sender); err != nil {
+		return errors.Wrapf(err, "SMTP server %s rejected the sender %s", address, sender)
+	}
+	for _, recipient := range recipients {
+		if err = client.Rcpt(recipient); err != nil {
+			return errors.Wrapf(err, "SMTP server %s rejected the recipient %s", address, recipient)
+		}
+	}
+	writer, err := client.Data()
+	if err != nil {
+		return errors.Wrapf(err, "problem sending message to SMTP server %s", address)
+	}
+	if _, err = writer.Write(message); err != nil {
+		return errors.Wrapf(err, "problem sending message to SMTP server %s", address)
+	}
+	if err = writer.Close(); err != nil {
+		return errors.Wrapf(err, "SMTP server %s rejected the message", address)
+	}
+	return client.Quit()
+}
+
+// Creates the email message with the headers. The subject is encoded if
+// it contains the non-ASCII characters and the body is encoded using the
+// quoted-printable encoding.
+func composeEmail(from, to *mail.Address, subject, body string, now time.Time) ([]byte, error) {
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", from)
+	fmt.Fprintf(&message, "To: %s\r\n", to)
+	fmt.Fprintf(&message, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&message, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	message.WriteString("MIME-Version: 1.0\r\n")
+	message.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	message.WriteString("Content-Transfer-Encoding: quoted-printable\r\n")
+	message.WriteString("\r\n")
+
+	writer := quotedprintable.NewWriter(&message)
+	if _, err := writer.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, errors.Wrap(err, "problem encoding email body")
+	}
+	if err := writer.Close(); err != nil {
+		return nil, errors.Wrap(err, "problem encoding email body")
+	}
+	return message.Bytes(), nil
+}
@@ -0,0 +1,358 @@
+package eventcenter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"isc.org/stork/pki"
+)
+
+// Message received by the SMTP sink.
+type smtpSinkMessage struct {
+	from       string
+	recipients []string
+	data       string
+	tls        bool
+	username   string
+}
+
+// Minimal SMTP server used to test sending the emails. It accepts all
+// messages and stores them. It supports STARTTLS and TLS if the TLS
+// configuration is specified and the PLAIN authentication if the
+// username is specified.
+type smtpSink struct {
+	listener    net.Listener
+	tlsConfig   *tls.Config
+	implicitTLS bool
+	username    string
+	password    string
+	mutex       sync.Mutex
+	messages    []smtpSinkMessage
+	wg          sync.WaitGroup
+}
+
+// Starts the SMTP sink listening on a random local port. The sink uses
+// the TLS from the beginning of the connection if implicitTLS is true and
+// the TLS configuration is specified.
+func newSMTPSink(t *testing.T, tlsConfig *tls.Config, implicitTLS bool) *smtpSink {
+	var (
+		listener net.Listener
+		err      error
+	)
+	if tlsConfig != nil && implicitTLS {
+		listener, err = tls.Listen("tcp", "127.0.0.1:0", tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+	}
+	require.NoError(t, err)
+	sink := &smtpSink{
+		listener:    listener,
+		tlsConfig:   tlsConfig,
+		implicitTLS: implicitTLS,
+	}
+	sink.wg.Add(1)
+	go sink.serve()
+	return sink
+}
+
+// Stops the sink.
+func (s *smtpSink) close() {
+	s.listener.Close()
+	s.wg.Wait()
+}
+
+// Returns the port the sink listens on.
+func (s *smtpSink) port() int64 {
+	return int64(s.listener.Addr().(*net.TCPAddr).Port)
+}
+
+// Returns the received messages.
+func (s *smtpSink) getMessages() []smtpSinkMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]smtpSinkMessage{}, s.messages...)
+}
+
+// Accepts the connections.
+func (s *smtpSink) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(10 * time.Second))
+			s.handle(conn)
+		}()
+	}
+}
+
+// Runs the SMTP session.
+func (s *smtpSink) handle(conn net.Conn) {
+	isTLS := s.tlsConfig != nil && s.implicitTLS
+	text := textproto.NewConn(conn)
+	_ = text.PrintfLine("220 localhost ESMTP sink")
+
+	var (
+		message       smtpSinkMessage
+		authenticated string
+	)
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		command, argument, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(command) {
+		case "EHLO", "HELO":
+			extensions := []string{"localhost"}
+			if s.tlsConfig != nil && !isTLS {
+				extensions = append(extensions, "STARTTLS")
+			}
+			if s.username != "" {
+				extensions = append(extensions, "AUTH PLAIN")
+			}
+			extensions = append(extensions, "8BITMIME")
+			for i, extension := range extensions {
+				separator := "-"
+				if i == len(extensions)-1 {
+					separator = " "
+				}
+				_ = text.PrintfLine("250%s%s", separator, extension)
+			}
+		case "STARTTLS":
+			if s.tlsConfig == nil || isTLS {
+				_ = text.PrintfLine("502 not supported")
+				continue
+			}
+			_ = text.PrintfLine("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err = tlsConn.Handshake(); err != nil {
+				return
+			}
+			text = textproto.NewConn(tlsConn)
+			isTLS = true
+		case "AUTH":
+			mechanism, credentials, _ := strings.Cut(argument, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(credentials)
+			parts := strings.Split(string(decoded), "\x00")
+			if mechanism != "PLAIN" || len(parts) != 3 || parts[1] != s.username || parts[2] != s.password {
+				_ = text.PrintfLine("535 authentication failed")
+				continue
+			}
+			authenticated = parts[1]
+			_ = text.PrintfLine("235 authenticated")
+		case "MAIL":
+			from, _, _ := strings.Cut(strings.TrimPrefix(argument, "FROM:"), " ")
+			if s.username != "" && authenticated == "" {
+				_ = text.PrintfLine("530 authentication required")
+				continue
+			}
+			message = smtpSinkMessage{
+				from:     strings.Trim(from, "<>"),
+				tls:      isTLS,
+				username: authenticated,
+			}
+			_ = text.PrintfLine("250 OK")
+		case "RCPT":
+			message.recipients = append(message.recipients, strings.Trim(strings.TrimPrefix(argument, "TO:"), "<>"))
+			_ = text.PrintfLine("250 OK")
+		case "DATA":
+			_ = text.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(text.DotReader())
+			if err != nil {
+				return
+			}
+			message.data = string(data)
+			s.mutex.Lock()
+			s.messages = append(s.messages, message)
+			s.mutex.Unlock()
+			_ = text.PrintfLine("250 OK")
+		case "QUIT":
+			_ = text.PrintfLine("221 bye")
+			return
+		default:
+			_ = text.PrintfLine("250 OK")
+		}
+	}
+}
+
+// Generates the TLS configurations of the server and the client trusting
+// the server certificate.
+func getSMTPTestTLSConfigs(t *testing.T) (server, client *tls.Config) {
+	caKey, _, caCert, caPEM, err := pki.GenCAKeyCert(1)
+	require.NoError(t, err)
+	certPEM, keyPEM, err := pki.GenKeyCert("smtp", []string{"localhost"}, []net.IP{net.ParseIP("127.0.0.1")}, 2, caCert, caKey)
+	require.NoError(t, err)
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	pool := x509.NewCertPool()
+	require.True(t, pool.AppendCertsFromPEM(caPEM))
+
+	server = &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	client = &tls.Config{
+		RootCAs:    pool,
+		ServerName: "127.0.0.1",
+		MinVersion: tls.VersionTLS12,
+	}
+	return server, client
+}
+
+// Test the supported security modes.
+func TestIsValidSMTPSecurity(t *testing.T) {
+	require.True(t, IsValidSMTPSecurity(SMTPSecurityNone))
+	require.True(t, IsValidSMTPSecurity(SMTPSecuritySTARTTLS))
+	require.True(t, IsValidSMTPSecurity(SMTPSecurityTLS))
+	require.False(t, IsValidSMTPSecurity(""))
+	require.False(t, IsValidSMTPSecurity("ssl"))
+}
+
+// Test that the email message contains the headers and the encoded body.
+func TestComposeEmail(t *testing.T) {
+	from := &mail.Address{Name: "Stork", Address: "stork@example.org"}
+	to := &mail.Address{Name: "Otto Operator", Address: "operator@example.org"}
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	body := "first line\nsecond line with a very long text " + strings.Repeat("x", 100) + "\n"
+	message, err := composeEmail(from, to, "[Stork] error: zażółć", body, now)
+	require.NoError(t, err)
+
+	parsed, err := mail.ReadMessage(strings.NewReader(string(message)))
+	require.NoError(t, err)
+	require.Equal(t, `"Stork" <stork@example.org>`, parsed.Header.Get("From"))
+	require.Equal(t, `"Otto Operator" <operator@example.org>`, parsed.Header.Get("To"))
+	require.Equal(t, "Wed, 01 Mar 2023 12:00:00 +0000", parsed.Header.Get("Date"))
+	require.Equal(t, "quoted-printable", parsed.Header.Get("Content-Transfer-Encoding"))
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	require.NoError(t, err)
+	require.Equal(t, "[Stork] error: zażółć", subject)
+
+	decoded, err := io.ReadAll(quotedprintable.NewReader(parsed.Body))
+	require.NoError(t, err)
+	require.Equal(t, strings.ReplaceAll(body, "\n", "\r\n"), string(decoded))
+}
+
+// Test sending the email without encryption and authentication.
+func TestSendMailPlain(t *testing.T) {
+	sink := newSMTPSink(t, nil, false)
+	defer sink.close()
+
+	config := &SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     sink.port(),
+		Security: SMTPSecurityNone,
+	}
+	err := sendMail(config, "stork@example.org", []string{"operator@example.org"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	require.NoError(t, err)
+
+	messages := sink.getMessages()
+	require.Len(t, messages, 1)
+	require.Equal(t, "stork@example.org", messages[0].from)
+	require.Equal(t, []string{"operator@example.org"}, messages[0].recipients)
+	require.Equal(t, "Subject: test\n\nbody\n", messages[0].data)
+	require.False(t, messages[0].tls)
+
+	// STARTTLS is required but the server doesn't support it.
+	config.Security = SMTPSecuritySTARTTLS
+	err = sendMail(config, "stork@example.org", []string{"operator@example.org"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	require.ErrorContains(t, err, "does not support STARTTLS")
+	require.Len(t, sink.getMessages(), 1)
+}
+
+// Test sending the email over the connection upgraded with STARTTLS and
+// with the authentication.
+func TestSendMailSTARTTLS(t *testing.T) {
+	serverTLS, clientTLS := getSMTPTestTLSConfigs(t)
+	sink := newSMTPSink(t, serverTLS, false)
+	sink.username = "stork"
+	sink.password = "secret"
+	defer sink.close()
+
+	config := &SMTPConfig{
+		Host:      "127.0.0.1",
+		Port:      sink.port(),
+		Security:  SMTPSecuritySTARTTLS,
+		Username:  "stork",
+		Password:  "secret",
+		tlsConfig: clientTLS,
+	}
+	err := sendMail(config, "stork@example.org", []string{"operator@example.org"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	require.NoError(t, err)
+
+	messages := sink.getMessages()
+	require.Len(t, messages, 1)
+	require.True(t, messages[0].tls)
+	require.Equal(t, "stork", messages[0].username)
+
+	// Wrong password.
+	config.Password = "wrong"
+	err = sendMail(config, "stork@example.org", []string{"operator@example.org"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	require.ErrorContains(t, err, "problem authenticating")
+	require.Len(t, sink.getMessages(), 1)
+
+	// The server certificate is not trusted.
+	config.Password = "secret"
+	config.tlsConfig = nil
+	err = sendMail(config, "stork@example.org", []string{"operator@example.org"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	require.ErrorContains(t, err, "problem starting TLS")
+}
+
+// Test sending the email over the TLS connection.
+func TestSendMailTLS(t *testing.T) {
+	serverTLS, clientTLS := getSMTPTestTLSConfigs(t)
+	sink := newSMTPSink(t, serverTLS, true)
+	defer sink.close()
+
+	config := &SMTPConfig{
+		Host:      "127.0.0.1",
+		Port:      sink.port(),
+		Security:  SMTPSecurityTLS,
+		tlsConfig: clientTLS,
+	}
+	err := sendMail(config, "stork@example.org", []string{"operator@example.org"}, []byte("Subject: test\r\n\r\nbody\r\n"))
+	require.NoError(t, err)
+
+	messages := sink.getMessages()
+	require.Len(t, messages, 1)
+	require.True(t, messages[0].tls)
+}
+
+// Test that the unreachable server and the invalid security mode are
+// reported.
+func TestSendMailErrors(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := int64(listener.Addr().(*net.TCPAddr).Port)
+	listener.Close()
+
+	config := &SMTPConfig{
+		Host:     "127.0.0.1",
+		Port:     port,
+		Security: SMTPSecurityNone,
+	}
+	err = sendMail(config, "stork@example.org", []string{"operator@example.org"}, []byte("body"))
+	require.ErrorContains(t, err, "problem connecting to SMTP server")
+
+	config.Security = "ssl"
+	err = sendMail(config, "stork@example.org", []string{"operator@example.org"}, []byte("body"))
+	require.EqualError(t, err, "invalid SMTP security mode ssl")
+}
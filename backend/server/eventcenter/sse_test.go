@@ -17,7 +17,8 @@ func TestSSEBroker(t *testing.T) {
 	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
 	defer teardown()
 
-	ec := NewEventCenter(db)
+	ec, err := NewEventCenter(db, nil)
+	require.NoError(t, err)
 
 	req := httptest.NewRequest("GET", "http://localhost/sse", nil)
 	w := httptest.NewRecorder()
@@ -172,10 +172,30 @@ func (s *Subscriber) applyFiltersFromQuery(db *dbops.PgDB) (err error) {
 // the specified event.
 func (s *Subscriber) AcceptsEvent(event *dbmodel.Event) bool {
 	return !s.useFilter ||
-		((s.filters.MachineID == 0 || event.Relations.MachineID == s.filters.MachineID) &&
-			(s.filters.AppID == 0 || event.Relations.AppID == s.filters.AppID) &&
-			(s.filters.SubnetID == 0 || event.Relations.SubnetID == s.filters.SubnetID) &&
-			(s.filters.DaemonID == 0 || event.Relations.DaemonID == s.filters.DaemonID) &&
-			(s.filters.UserID == 0 || event.Relations.UserID == s.filters.UserID) &&
+		(s.filters.matches(event.Relations) &&
 			(s.level == 0 || event.Level >= s.level))
 }
+
+// Returns a boolean value indicating if the event relations match the
+// filters. The filters set to zero match any relations.
+func (f *subscriberFilters) matches(relations *dbmodel.Relations) bool {
+	if relations == nil {
+		relations = &dbmodel.Relations{}
+	}
+	return (f.MachineID == 0 || relations.MachineID == f.MachineID) &&
+		(f.AppID == 0 || relations.AppID == f.AppID) &&
+		(f.SubnetID == 0 || relations.SubnetID == f.SubnetID) &&
+		(f.DaemonID == 0 || relations.DaemonID == f.DaemonID) &&
+		(f.UserID == 0 || relations.UserID == f.UserID)
+}
+
+// Returns a boolean value indicating if the event level is equal to or
+// greater than the specified level and the event relations match the
+// filters. The zero level and the nil filters match all events. It is
+// used to filter the events sent to the webhooks and by email.
+func eventMatches(level dbmodel.EventLevel, filters *dbmodel.Relations, event *dbmodel.Event) bool {
+	if level != 0 && event.Level < level {
+		return false
+	}
+	return filters == nil || (*subscriberFilters)(filters).matches(event.Relations)
+}
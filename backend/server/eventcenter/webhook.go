@@ -0,0 +1,414 @@
+package eventcenter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"text/template"
+	"time"
+
+	errors "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Headers sent with the webhook requests. The signature header holds the
+// HMAC-SHA256 of the payload computed with the webhook secret. The event
+// header holds the ID of the sent event.
+const (
+	WebhookSignatureHeader = "X-Stork-Signature"
+	WebhookEventHeader     = "X-Stork-Event"
+)
+
+// Maximum number of the retries of a failed delivery.
+const MaxWebhookRetries = 10
+
+const (
+	// Number of events waiting to be sent to the webhooks. The events
+	// are dropped when the queue is full.
+	webhookQueueSize = 1000
+	// Number of events waiting to be sent to a single webhook. The events
+	// for the webhook are dropped when its queue is full, e.g. when the
+	// webhook is slow or unreachable and the deliveries are retried.
+	webhookWorkerQueueSize = 100
+	// Timeout of a single webhook request.
+	webhookRequestTimeout = 10 * time.Second
+	// Delay before the first retry. It is doubled for every next retry.
+	webhookRetryDelay = 5 * time.Second
+	// Maximum delay between the retries.
+	webhookMaxRetryDelay = 5 * time.Minute
+)
+
+// Matches the tags describing the objects in the event text, e.g.
+// <machine id="1" address="192.0.2.1" hostname="foo">.
+var (
+	eventTagPattern     = regexp.MustCompile(`<(daemon|app|machine|subnet|user) ([^>]*)>`)
+	eventTagAttrPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+)
+
+// Attributes of the tags used to present the objects in the plain text.
+var eventTagDisplayAttrs = map[string]string{
+	"daemon":  "name",
+	"app":     "name",
+	"machine": "address",
+	"subnet":  "prefix",
+	"user":    "login",
+}
+
+// Replaces the tags describing the objects in the event text with the
+// object names, e.g. the machine tag is replaced with the machine address.
+func StripEventTags(text string) string {
+	return eventTagPattern.ReplaceAllStringFunc(text, func(tag string) string {
+		match := eventTagPattern.FindStringSubmatch(tag)
+		for _, attr := range eventTagAttrPattern.FindAllStringSubmatch(match[2], -1) {
+			if attr[1] == eventTagDisplayAttrs[match[1]] {
+				return attr[2]
+			}
+		}
+		return match[1]
+	})
+}
+
+// Event data passed to the webhook payload template. It is also sent as
+// the payload when the webhook has no template. The text doesn't contain
+// the tags describing the objects, the tagged text is the original event
+// text.
+type webhookEvent struct {
+	ID         int64              `json:"id"`
+	CreatedAt  time.Time          `json:"createdAt"`
+	Level      string             `json:"level"`
+	Text       string             `json:"text"`
+	TaggedText string             `json:"taggedText"`
+	Details    string             `json:"details,omitempty"`
+	Relations  *dbmodel.Relations `json:"relations,omitempty"`
+	Webhook    string             `json:"webhook"`
+}
+
+// Functions available in the payload templates. The json function
+// renders any value, including the strings, as a JSON value.
+var webhookTemplateFuncs = template.FuncMap{
+	"json": func(value interface{}) (string, error) {
+		b, err := json.Marshal(value)
+		return string(b), err
+	},
+}
+
+// Renders the payload sent to the webhook. The payload is rendered from
+// the webhook template or, if the template is empty, it is the event
+// serialized to JSON. The rendered payload must be a valid JSON.
+func RenderWebhookPayload(webhook *dbmodel.Webhook, event *dbmodel.Event) ([]byte, error) {
+	data := &webhookEvent{
+		ID:         event.ID,
+		CreatedAt:  event.CreatedAt,
+		Level:      event.Level.String(),
+		Text:       StripEventTags(event.Text),
+		TaggedText: event.Text,
+		Details:    event.Details,
+		Relations:  event.Relations,
+		Webhook:    webhook.Name,
+	}
+	if webhook.Template == "" {
+		payload, err := json.Marshal(data)
+		return payload, errors.Wrap(err, "problem serializing event")
+	}
+
+	tmpl, err := template.New(webhook.Name).Funcs(webhookTemplateFuncs).Option("missingkey=error").Parse(webhook.Template)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem parsing payload template")
+	}
+	var payload bytes.Buffer
+	if err = tmpl.Execute(&payload, data); err != nil {
+		return nil, errors.Wrap(err, "problem rendering payload template")
+	}
+	if !json.Valid(payload.Bytes()) {
+		return nil, errors.New("rendered payload is not valid JSON")
+	}
+	return payload.Bytes(), nil
+}
+
+// Returns the signature of the payload sent in the signature header. It is
+// the HMAC-SHA256 of the payload computed with the webhook secret.
+func SignWebhookPayload(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Checks if the webhook configuration is correct. The URL must be an
+// absolute HTTP or HTTPS URL, the text pattern must be a valid regular
+// expression, and the template must render a valid JSON payload.
+func ValidateWebhook(webhook *dbmodel.Webhook) error {
+	if webhook.Name == "" {
+		return errors.New("webhook name must not be empty")
+	}
+	u, err := url.Parse(webhook.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("webhook URL must be an absolute HTTP or HTTPS URL")
+	}
+	if webhook.Level < dbmodel.EvInfo || webhook.Level > dbmodel.EvError {
+		return errors.Errorf("invalid event level %d", webhook.Level)
+	}
+	if webhook.MaxRetries < 0 || webhook.MaxRetries > MaxWebhookRetries {
+		return errors.Errorf("maximum number of retries must be between 0 and %d", MaxWebhookRetries)
+	}
+	if _, err = regexp.Compile(webhook.TextPattern); err != nil {
+		return errors.Wrap(err, "invalid text pattern")
+	}
+	sample := &dbmodel.Event{
+		ID:        1,
+		CreatedAt: time.Now().UTC(),
+		Level:     dbmodel.EvWarning,
+		Text:      `Communication with <machine id="1" address="192.0.2.1" hostname="foo"> failed`,
+		Details:   "connection refused",
+		Relations: &dbmodel.Relations{MachineID: 1},
+	}
+	_, err = RenderWebhookPayload(webhook, sample)
+	return err
+}
+
+// Returns a boolean value indicating if the webhook should receive the
+// specified event. The events are filtered like in the SSE subscriber.
+// Additionally, the event text, without the tags, must match the text
+// pattern of the webhook.
+func WebhookAcceptsEvent(webhook *dbmodel.Webhook, event *dbmodel.Event) bool {
+	if !eventMatches(webhook.Level, webhook.Filters, event) {
+		return false
+	}
+	if webhook.TextPattern != "" {
+		pattern, err := regexp.Compile(webhook.TextPattern)
+		if err != nil {
+			log.WithError(err).Warnf("Invalid text pattern of webhook %s", webhook.Name)
+			return false
+		}
+		return pattern.MatchString(StripEventTags(event.Text))
+	}
+	return true
+}
+
+// Sends the events to the webhooks. The events are queued and sent in
+// the background, so the slow webhooks don't block the event center. Each
+// webhook has its own bounded queue and a worker sending the events from
+// it in order, so a slow webhook doesn't delay the other webhooks. The
+// failed deliveries are retried with growing delays. The result of each
+// delivery is recorded in the database.
+type webhookDispatcher struct {
+	db         *dbops.PgDB
+	client     *http.Client
+	events     chan *dbmodel.Event
+	done       chan struct{}
+	wg         *sync.WaitGroup
+	retryDelay time.Duration
+	// Queues of the webhook workers indexed by the webhook ID. They are
+	// accessed only by the main loop.
+	queues map[int64]chan *webhookJob
+}
+
+// The event to be sent to the webhook by the webhook worker.
+type webhookJob struct {
+	webhook *dbmodel.Webhook
+	event   *dbmodel.Event
+}
+
+// Creates the webhook dispatcher and starts its main loop.
+func newWebhookDispatcher(db *dbops.PgDB) *webhookDispatcher {
+	d := &webhookDispatcher{
+		db: db,
+		client: &http.Client{
+			Timeout: webhookRequestTimeout,
+		},
+		events:     make(chan *dbmodel.Event, webhookQueueSize),
+		done:       make(chan struct{}),
+		wg:         &sync.WaitGroup{},
+		retryDelay: webhookRetryDelay,
+		queues:     make(map[int64]chan *webhookJob),
+	}
+	d.wg.Add(1)
+	go d.mainLoop()
+	return d
+}
+
+// Stops the dispatcher. The pending retries are abandoned.
+func (d *webhookDispatcher) shutdown() {
+	close(d.done)
+	d.wg.Wait()
+}
+
+// Queues the event to be sent to the webhooks. The event is dropped if
+// the queue is full.
+func (d *webhookDispatcher) dispatchEvent(event *dbmodel.Event) {
+	select {
+	case d.events <- event:
+	default:
+		log.Warnf("Webhook queue is full; event %d is not sent to the webhooks", event.ID)
+	}
+}
+
+// A main loop of the dispatcher. It receives the queued events and puts
+// each of them in the queues of the matching webhooks. The workers of the
+// webhooks which are no longer enabled are stopped after sending the
+// events already in their queues.
+func (d *webhookDispatcher) mainLoop() {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.done:
+			return
+		case event := <-d.events:
+			webhooks, err := dbmodel.GetEnabledWebhooks(d.db)
+			if err != nil {
+				log.WithError(err).Error("Problem getting webhooks from the database")
+				continue
+			}
+			enabled := make(map[int64]bool)
+			for i := range webhooks {
+				webhook := &webhooks[i]
+				enabled[webhook.ID] = true
+				if !WebhookAcceptsEvent(webhook, event) {
+					continue
+				}
+				d.enqueue(webhook, event)
+			}
+			for id, queue := range d.queues {
+				if !enabled[id] {
+					close(queue)
+					delete(d.queues, id)
+				}
+			}
+		}
+	}
+}
+
+// Puts the event in the queue of the webhook worker. The worker is started
+// if the webhook has none. The event is dropped if the queue is full.
+func (d *webhookDispatcher) enqueue(webhook *dbmodel.Webhook, event *dbmodel.Event) {
+	queue, ok := d.queues[webhook.ID]
+	if !ok {
+		queue = make(chan *webhookJob, webhookWorkerQueueSize)
+		d.queues[webhook.ID] = queue
+		d.wg.Add(1)
+		go d.worker(queue)
+	}
+	select {
+	case queue <- &webhookJob{webhook: webhook, event: event}:
+	default:
+		log.WithFields(log.Fields{
+			"webhook": webhook.Name,
+			"event":   event.ID,
+		}).Warn("Webhook queue is full; event is not sent to the webhook")
+	}
+}
+
+// A webhook worker. It sends the events from the queue of a webhook one
+// by one until the queue is closed or the dispatcher is stopped.
+func (d *webhookDispatcher) worker(queue chan *webhookJob) {
+	defer d.wg.Done()
+	for {
+		select {
+		case <-d.done:
+			return
+		case job, ok := <-queue:
+			if !ok {
+				return
+			}
+			d.deliver(job.webhook, job.event)
+		}
+	}
+}
+
+// Sends the event to the webhook and records the delivery result.
+func (d *webhookDispatcher) deliver(webhook *dbmodel.Webhook, event *dbmodel.Event) {
+	delivery := &dbmodel.WebhookDelivery{
+		WebhookID: webhook.ID,
+		EventID:   event.ID,
+	}
+	payload, err := RenderWebhookPayload(webhook, event)
+	if err != nil {
+		delivery.Error = err.Error()
+	} else {
+		d.send(webhook, event, payload, delivery)
+	}
+	if !delivery.Success {
+		log.WithFields(log.Fields{
+			"webhook":  webhook.Name,
+			"event":    event.ID,
+			"attempts": delivery.Attempts,
+		}).Warnf("Failed to send event to webhook: %s", delivery.Error)
+	}
+	if err = dbmodel.AddWebhookDelivery(d.db, delivery); err != nil {
+		log.WithError(err).Errorf("Problem recording delivery of event %d to webhook %s", event.ID, webhook.Name)
+	}
+}
+
+// Sends the payload to the webhook. The delivery is retried when the
+// webhook doesn't respond or returns a server error, until the maximum
+// number of retries is reached or the dispatcher is stopped.
+func (d *webhookDispatcher) send(webhook *dbmodel.Webhook, event *dbmodel.Event, payload []byte, delivery *dbmodel.WebhookDelivery) {
+	delay := d.retryDelay
+	for {
+		delivery.Attempts++
+		statusCode, retry, err := d.post(webhook, event, payload)
+		delivery.StatusCode = statusCode
+		if err == nil {
+			delivery.Success = true
+			delivery.Error = ""
+			return
+		}
+		delivery.Error = err.Error()
+		if !retry || delivery.Attempts > webhook.MaxRetries {
+			return
+		}
+		select {
+		case <-d.done:
+			return
+		case <-time.After(delay):
+		}
+		delay *= 2
+		if delay > webhookMaxRetryDelay {
+			delay = webhookMaxRetryDelay
+		}
+	}
+}
+
+// Sends a single webhook request. It returns the status code of the
+// response and a boolean value indicating if the request should be
+// retried. The errors don't include the URL because it may contain
+// a token authorizing the request.
+func (d *webhookDispatcher) post(webhook *dbmodel.Webhook, event *dbmodel.Event, payload []byte) (statusCode int, retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, false, errors.New("invalid webhook URL")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, fmt.Sprint(event.ID))
+	if webhook.Secret != "" {
+		req.Header.Set(WebhookSignatureHeader, SignWebhookPayload(webhook.Secret, payload))
+	}
+
+	rsp, err := d.client.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return 0, true, errors.Wrap(err, "problem sending request")
+	}
+	defer rsp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(rsp.Body, 64*1024))
+
+	if rsp.StatusCode >= 200 && rsp.StatusCode < 300 {
+		return rsp.StatusCode, false, nil
+	}
+	retry = rsp.StatusCode >= 500 ||
+		rsp.StatusCode == http.StatusTooManyRequests ||
+		rsp.StatusCode == http.StatusRequestTimeout
+	return rsp.StatusCode, retry, errors.Errorf("webhook responded with status %d", rsp.StatusCode)
+}
@@ -0,0 +1,360 @@
+package eventcenter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the tags are replaced with the object names.
+func TestStripEventTags(t *testing.T) {
+	text := `Communication with <daemon id="3" name="dhcp4" appId="2" appType="kea"> of ` +
+		`<app id="2" name="kea@foo" type="kea" version="2.2.0"> on ` +
+		`<machine id="1" address="192.0.2.1" hostname="foo"> in <subnet id="4" prefix="192.0.2.0/24"> ` +
+		`by <user id="5" login="admin" email="">`
+	require.Equal(t, "Communication with dhcp4 of kea@foo on 192.0.2.1 in 192.0.2.0/24 by admin", StripEventTags(text))
+	require.Equal(t, "no tags <b>", StripEventTags("no tags <b>"))
+}
+
+// Test that the default payload contains the event serialized to JSON.
+func TestRenderWebhookPayloadDefault(t *testing.T) {
+	webhook := &dbmodel.Webhook{Name: "chat"}
+	event := &dbmodel.Event{
+		ID:        7,
+		CreatedAt: time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC),
+		Level:     dbmodel.EvError,
+		Text:      `<machine id="1" address="192.0.2.1" hostname="foo"> is unreachable`,
+		Details:   "timeout",
+		Relations: &dbmodel.Relations{MachineID: 1},
+	}
+	payload, err := RenderWebhookPayload(webhook, event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"id": 7,
+		"createdAt": "2023-01-02T03:04:05Z",
+		"level": "error",
+		"text": "192.0.2.1 is unreachable",
+		"taggedText": "<machine id=\"1\" address=\"192.0.2.1\" hostname=\"foo\"> is unreachable",
+		"details": "timeout",
+		"relations": {"MachineID": 1},
+		"webhook": "chat"
+	}`, string(payload))
+}
+
+// Test that the payload is rendered from the template and the values are
+// escaped using the json function.
+func TestRenderWebhookPayloadTemplate(t *testing.T) {
+	webhook := &dbmodel.Webhook{
+		Name:     "chat",
+		Template: `{"text": {{json (printf "[%s] %s" .Level .Text)}}, "id": {{.ID}}}`,
+	}
+	event := &dbmodel.Event{
+		ID:    8,
+		Level: dbmodel.EvWarning,
+		Text:  `"quoted" text`,
+	}
+	payload, err := RenderWebhookPayload(webhook, event)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"text": "[warning] \"quoted\" text", "id": 8}`, string(payload))
+
+	// Not escaped text breaks the JSON.
+	webhook.Template = `{"text": "{{.Text}}"}`
+	_, err = RenderWebhookPayload(webhook, event)
+	require.EqualError(t, err, "rendered payload is not valid JSON")
+
+	// Unknown field.
+	webhook.Template = `{"text": {{json .Foo}}}`
+	_, err = RenderWebhookPayload(webhook, event)
+	require.Error(t, err)
+
+	// Syntax error.
+	webhook.Template = `{"text": {{json .Text}`
+	_, err = RenderWebhookPayload(webhook, event)
+	require.Error(t, err)
+}
+
+// Test that the payload signature is the HMAC-SHA256 of the payload.
+func TestSignWebhookPayload(t *testing.T) {
+	payload := []byte(`{"id": 1}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(payload)
+	require.Equal(t, "sha256="+hex.EncodeToString(mac.Sum(nil)), SignWebhookPayload("secret", payload))
+	require.NotEqual(t, SignWebhookPayload("secret", payload), SignWebhookPayload("other", payload))
+}
+
+// Test the webhook configuration validation.
+func TestValidateWebhook(t *testing.T) {
+	webhook := &dbmodel.Webhook{
+		Name:        "chat",
+		URL:         "https://chat.example.org/hooks/1?token=abc",
+		Template:    `{"text": {{json .Text}}}`,
+		Level:       dbmodel.EvWarning,
+		TextPattern: "failed|unreachable",
+		MaxRetries:  3,
+	}
+	require.NoError(t, ValidateWebhook(webhook))
+
+	invalid := *webhook
+	invalid.Name = ""
+	require.Error(t, ValidateWebhook(&invalid))
+
+	for _, u := range []string{"", "chat.example.org", "ftp://chat.example.org", "http://", "http://[::1"} {
+		invalid = *webhook
+		invalid.URL = u
+		require.Error(t, ValidateWebhook(&invalid), u)
+	}
+
+	invalid = *webhook
+	invalid.Level = 3
+	require.Error(t, ValidateWebhook(&invalid))
+
+	invalid = *webhook
+	invalid.MaxRetries = MaxWebhookRetries + 1
+	require.Error(t, ValidateWebhook(&invalid))
+
+	invalid = *webhook
+	invalid.TextPattern = "(foo"
+	require.Error(t, ValidateWebhook(&invalid))
+
+	invalid = *webhook
+	invalid.Template = `{"text": {{.Text}}}`
+	require.Error(t, ValidateWebhook(&invalid))
+}
+
+// Test that the events are filtered by level, relations and text.
+func TestWebhookAcceptsEvent(t *testing.T) {
+	event := &dbmodel.Event{
+		Level:     dbmodel.EvWarning,
+		Text:      `Communication with <machine id="1" address="192.0.2.1" hostname="foo"> failed`,
+		Relations: &dbmodel.Relations{MachineID: 1, AppID: 2},
+	}
+
+	webhook := &dbmodel.Webhook{}
+	require.True(t, WebhookAcceptsEvent(webhook, event))
+
+	webhook.Level = dbmodel.EvWarning
+	require.True(t, WebhookAcceptsEvent(webhook, event))
+	webhook.Level = dbmodel.EvError
+	require.False(t, WebhookAcceptsEvent(webhook, event))
+	webhook.Level = dbmodel.EvInfo
+
+	webhook.Filters = &dbmodel.Relations{MachineID: 1}
+	require.True(t, WebhookAcceptsEvent(webhook, event))
+	webhook.Filters = &dbmodel.Relations{MachineID: 1, DaemonID: 3}
+	require.False(t, WebhookAcceptsEvent(webhook, event))
+	require.False(t, WebhookAcceptsEvent(webhook, &dbmodel.Event{Text: "foo"}))
+	webhook.Filters = nil
+
+	// The pattern is matched against the text without the tags.
+	webhook.TextPattern = "^Communication with 192.0.2.1"
+	require.True(t, WebhookAcceptsEvent(webhook, event))
+	webhook.TextPattern = "hostname"
+	require.False(t, WebhookAcceptsEvent(webhook, event))
+	webhook.TextPattern = "(invalid"
+	require.False(t, WebhookAcceptsEvent(webhook, event))
+}
+
+// Test that the events are sent to the matching webhooks and the
+// deliveries are recorded.
+func TestWebhookDispatcher(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	type request struct {
+		header http.Header
+		body   []byte
+	}
+	requests := make(chan request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests <- request{header: r.Header, body: body}
+	}))
+	defer server.Close()
+
+	webhook := &dbmodel.Webhook{
+		Name:    "chat",
+		URL:     server.URL,
+		Enabled: true,
+		Secret:  "secret",
+		Level:   dbmodel.EvWarning,
+	}
+	_, err := dbmodel.AddWebhook(db, webhook)
+	require.NoError(t, err)
+	_, err = dbmodel.AddWebhook(db, &dbmodel.Webhook{Name: "disabled", URL: server.URL})
+	require.NoError(t, err)
+
+	d := newWebhookDispatcher(db)
+	defer d.shutdown()
+
+	info := &dbmodel.Event{Text: "info", Level: dbmodel.EvInfo}
+	require.NoError(t, dbmodel.AddEvent(db, info))
+	warning := &dbmodel.Event{Text: "warning", Level: dbmodel.EvWarning}
+	require.NoError(t, dbmodel.AddEvent(db, warning))
+
+	d.dispatchEvent(info)
+	d.dispatchEvent(warning)
+
+	var req request
+	select {
+	case req = <-requests:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "webhook not called")
+	}
+	require.Equal(t, "application/json", req.header.Get("Content-Type"))
+	require.Equal(t, fmt.Sprint(warning.ID), req.header.Get(WebhookEventHeader))
+	require.Equal(t, SignWebhookPayload("secret", req.body), req.header.Get(WebhookSignatureHeader))
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(req.body, &payload))
+	require.Equal(t, "warning", payload["text"])
+
+	require.Eventually(t, func() bool {
+		_, total, err := dbmodel.GetWebhookDeliveriesByPage(db, webhook.ID, 0, 10)
+		return err == nil && total == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	deliveries, _, err := dbmodel.GetWebhookDeliveriesByPage(db, webhook.ID, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, warning.ID, deliveries[0].EventID)
+	require.True(t, deliveries[0].Success)
+	require.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+	require.Equal(t, 1, deliveries[0].Attempts)
+
+	// The info event is not sent.
+	require.Empty(t, requests)
+}
+
+// Test that the failed deliveries are retried.
+func TestWebhookDispatcherRetry(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	var calls, rejected int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&rejected) != 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	retried := &dbmodel.Webhook{Name: "retried", URL: server.URL, Enabled: true, MaxRetries: 2}
+	_, err := dbmodel.AddWebhook(db, retried)
+	require.NoError(t, err)
+
+	d := newWebhookDispatcher(db)
+	d.retryDelay = time.Millisecond
+	defer d.shutdown()
+
+	event := &dbmodel.Event{Text: "foo", Level: dbmodel.EvError}
+	require.NoError(t, dbmodel.AddEvent(db, event))
+	d.dispatchEvent(event)
+
+	var deliveries []dbmodel.WebhookDelivery
+	require.Eventually(t, func() bool {
+		deliveries, _, err = dbmodel.GetWebhookDeliveriesByPage(db, retried.ID, 0, 10)
+		return err == nil && len(deliveries) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.True(t, deliveries[0].Success)
+	require.Equal(t, 3, deliveries[0].Attempts)
+	require.Equal(t, http.StatusNoContent, deliveries[0].StatusCode)
+	require.Empty(t, deliveries[0].Error)
+
+	// The client errors are not retried.
+	atomic.StoreInt32(&rejected, 1)
+	d.dispatchEvent(event)
+	require.Eventually(t, func() bool {
+		deliveries, _, err = dbmodel.GetWebhookDeliveriesByPage(db, retried.ID, 0, 10)
+		return err == nil && len(deliveries) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+	require.False(t, deliveries[0].Success)
+	require.Equal(t, 1, deliveries[0].Attempts)
+	require.Equal(t, http.StatusBadRequest, deliveries[0].StatusCode)
+	require.Equal(t, "webhook responded with status 400", deliveries[0].Error)
+}
+
+// Test that a slow webhook doesn't delay the other webhooks and receives
+// the events one by one.
+func TestWebhookDispatcherSlowWebhook(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	release := make(chan struct{})
+	defer func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	}()
+	var inFlight, maxInFlight int32
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			m := atomic.LoadInt32(&maxInFlight)
+			if n <= m || atomic.CompareAndSwapInt32(&maxInFlight, m, n) {
+				break
+			}
+		}
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer slowServer.Close()
+	fastServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer fastServer.Close()
+
+	slow := &dbmodel.Webhook{Name: "slow", URL: slowServer.URL, Enabled: true}
+	_, err := dbmodel.AddWebhook(db, slow)
+	require.NoError(t, err)
+	fast := &dbmodel.Webhook{Name: "fast", URL: fastServer.URL, Enabled: true}
+	_, err = dbmodel.AddWebhook(db, fast)
+	require.NoError(t, err)
+
+	d := newWebhookDispatcher(db)
+	defer d.shutdown()
+
+	for i := 0; i < 3; i++ {
+		event := &dbmodel.Event{Text: fmt.Sprintf("event %d", i), Level: dbmodel.EvError}
+		require.NoError(t, dbmodel.AddEvent(db, event))
+		d.dispatchEvent(event)
+	}
+
+	// The fast webhook receives all events while the slow one is blocked.
+	require.Eventually(t, func() bool {
+		_, total, err := dbmodel.GetWebhookDeliveriesByPage(db, fast.ID, 0, 10)
+		return err == nil && total == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	_, total, err := dbmodel.GetWebhookDeliveriesByPage(db, slow.ID, 0, 10)
+	require.NoError(t, err)
+	require.Zero(t, total)
+
+	// The slow webhook receives the events one by one.
+	close(release)
+	require.Eventually(t, func() bool {
+		_, total, err := dbmodel.GetWebhookDeliveriesByPage(db, slow.ID, 0, 10)
+		return err == nil && total == 3
+	}, 5*time.Second, 10*time.Millisecond)
+	require.EqualValues(t, 1, atomic.LoadInt32(&maxInFlight))
+}
@@ -0,0 +1,372 @@
+package forecast
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+	storkutil "isc.org/stork/util"
+)
+
+// Name of the setting holding the number of days before the forecasted
+// exhaustion when the warning event is raised. The zero value disables
+// the events.
+const HorizonSettingName = "utilization_forecast_horizon_days"
+
+// Name of the setting holding the method used to fit the utilization
+// growth.
+const MethodSettingName = "utilization_forecast_method"
+
+// Method used to fit the utilization growth.
+type Method string
+
+// Supported forecasting methods. The linear method fits a line to the
+// hourly average utilization. The seasonal method fits a line to the daily
+// utilization peaks, so the forecast isn't affected by the daily cycle of
+// the utilization and predicts when the peaks reach the pool size.
+const (
+	MethodLinear   Method = "linear"
+	MethodSeasonal Method = "seasonal"
+)
+
+// Checks if the forecasting method is supported.
+func IsValidMethod(method string) bool {
+	return method == string(MethodLinear) || method == string(MethodSeasonal)
+}
+
+// Interval of the forecasting in seconds.
+const forecastInterval int64 = 3600
+
+// Length of the utilization history used for the forecasts.
+const historyWindow = 7 * 24 * time.Hour
+
+// Minimal number of the hourly samples required by the linear method.
+const minLinearSamples = 12
+
+// Minimal number of the days with samples required by the seasonal method.
+const minSeasonalDays = 3
+
+// Maximal number of days to the forecasted exhaustion. The longer
+// forecasts are not meaningful and are discarded.
+const maxForecastDays = 3650
+
+// Utilization at the given number of days relative to the forecast time.
+// The utilization is expressed in tenths of a percent.
+type point struct {
+	days  float64
+	value float64
+}
+
+// Fits a line to the points using the least squares method. It returns
+// the slope per day and the value at the forecast time.
+func fitLine(points []point) (slope, intercept float64) {
+	n := float64(len(points))
+	var sumX, sumY, sumXX, sumXY float64
+	for _, p := range points {
+		sumX += p.days
+		sumY += p.value
+		sumXX += p.days * p.days
+		sumXY += p.days * p.value
+	}
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, sumY / n
+	}
+	slope = (n*sumXY - sumX*sumY) / denominator
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept
+}
+
+// Converts the hourly samples to the points fitted by the method. The
+// delegated prefix utilization is used instead of the address utilization
+// if the pd flag is set. It returns nil if there are too few samples.
+func getPoints(samples []dbmodel.UtilizationSample, method Method, pd bool, now time.Time) []point {
+	var points []point
+	if method == MethodSeasonal {
+		peaks := make(map[int]int16)
+		for _, sample := range samples {
+			day := int(now.Sub(sample.SampledAt) / (24 * time.Hour))
+			value := sample.AddrUtilizationMax
+			if pd {
+				value = sample.PdUtilizationMax
+			}
+			if peak, ok := peaks[day]; !ok || value > peak {
+				peaks[day] = value
+			}
+		}
+		if len(peaks) < minSeasonalDays {
+			return nil
+		}
+		for day, peak := range peaks {
+			points = append(points, point{days: -float64(day) - 0.5, value: float64(peak)})
+		}
+		return points
+	}
+	if len(samples) < minLinearSamples {
+		return nil
+	}
+	for _, sample := range samples {
+		value := sample.AddrUtilization
+		if pd {
+			value = sample.PdUtilization
+		}
+		points = append(points, point{
+			days:  sample.SampledAt.Sub(now).Hours() / 24,
+			value: float64(value),
+		})
+	}
+	return points
+}
+
+// Estimates the number of days until the utilization reaches 100%. It
+// returns false if there are too few samples or the utilization doesn't
+// grow.
+func estimateDaysToExhaustion(samples []dbmodel.UtilizationSample, method Method, pd bool, now time.Time) (float64, bool) {
+	points := getPoints(samples, method, pd, now)
+	if len(points) == 0 {
+		return 0, false
+	}
+	slope, current := fitLine(points)
+	if current >= 1000 {
+		return 0, true
+	}
+	if slope <= 0 {
+		return 0, false
+	}
+	days := (1000 - current) / slope
+	if days > maxForecastDays {
+		return 0, false
+	}
+	return days, true
+}
+
+// Returns the forecasted exhaustion time or zero time if the exhaustion
+// is not forecasted.
+func getExhaustionTime(samples []dbmodel.UtilizationSample, method Method, pd bool, now time.Time) time.Time {
+	days, ok := estimateDaysToExhaustion(samples, method, pd, now)
+	if !ok {
+		return time.Time{}
+	}
+	return now.Add(time.Duration(days * float64(24*time.Hour))).Round(time.Second)
+}
+
+// Checks if the forecasted exhaustion is within the horizon.
+func isWithinHorizon(exhaustionAt time.Time, horizon time.Duration, now time.Time) bool {
+	return !exhaustionAt.IsZero() && exhaustionAt.Before(now.Add(horizon))
+}
+
+// Subnet, shared network or pool the forecast is made for. The pools
+// refer to their subnets and the daemons serving them.
+type forecastObject struct {
+	objectType dbmodel.UtilizationObjectType
+	id         int64
+	name       string
+	subnet     *dbmodel.Subnet
+	daemon     *dbmodel.Daemon
+	addrAt     time.Time
+	pdAt       time.Time
+}
+
+// Identifies the object the utilization samples belong to.
+type objectKey struct {
+	objectType dbmodel.UtilizationObjectType
+	id         int64
+}
+
+// Creates the event raised when the forecasted exhaustion enters or
+// leaves the horizon.
+func createForecastEvent(object *forecastObject, resource string, exhaustionAt time.Time, horizonDays int64, method Method, now time.Time) *dbmodel.Event {
+	target := "{subnet}"
+	objects := []interface{}{}
+	switch object.objectType {
+	case dbmodel.UtilizationObjectSubnet:
+		objects = append(objects, object.subnet)
+	case dbmodel.UtilizationObjectSharedNetwork:
+		target = fmt.Sprintf("shared network %s", object.name)
+	default:
+		target = fmt.Sprintf("pool %s of {subnet} served by {daemon}", object.name)
+		objects = append(objects, object.subnet, object.daemon)
+	}
+	if exhaustionAt.IsZero() || !exhaustionAt.Before(now.AddDate(0, 0, int(horizonDays))) {
+		text := fmt.Sprintf("%s in %s are no longer forecast to be exhausted within %d days", resource, target, horizonDays)
+		return eventcenter.CreateEvent(dbmodel.EvInfo, text, objects...)
+	}
+	days := exhaustionAt.Sub(now).Hours() / 24
+	text := fmt.Sprintf("%s in %s are forecast to be exhausted in %.1f days", resource, target, days)
+	objects = append(objects, fmt.Sprintf("forecasted exhaustion: %s, method: %s",
+		exhaustionAt.Format(time.RFC3339), method))
+	return eventcenter.CreateEvent(dbmodel.EvWarning, text, objects...)
+}
+
+// Background worker periodically forecasting the exhaustion of the
+// subnets, shared networks and pools.
+type Forecaster struct {
+	db          *pg.DB
+	eventCenter eventcenter.EventCenter
+	executor    *storkutil.PeriodicExecutor
+}
+
+// Creates the forecaster and starts forecasting the exhaustion
+// periodically. The events are raised via the event center.
+func NewForecaster(db *pg.DB, eventCenter eventcenter.EventCenter) (*Forecaster, error) {
+	forecaster := &Forecaster{
+		db:          db,
+		eventCenter: eventCenter,
+	}
+	executor, err := storkutil.NewPeriodicExecutor("utilization forecaster", forecaster.forecast,
+		func() (int64, error) {
+			return forecastInterval, nil
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+	forecaster.executor = executor
+	return forecaster, nil
+}
+
+// Forecasts the exhaustion of the subnets, shared networks and pools.
+func (forecaster *Forecaster) forecast() error {
+	return Forecast(forecaster.db, forecaster.eventCenter, time.Now().UTC())
+}
+
+// Stops forecasting the exhaustion.
+func (forecaster *Forecaster) Shutdown() {
+	forecaster.executor.Shutdown()
+}
+
+// Forecasts when the addresses and delegated prefixes of the subnets,
+// shared networks and pools are exhausted using the hourly utilization
+// history and the method specified in the settings. The address pools are
+// forecasted only for the addresses and the prefix pools only for the
+// delegated prefixes. The forecasts are stored in the database. The
+// warning events are raised when the forecasted exhaustion enters the
+// horizon specified in the settings, and the info events when it leaves
+// the horizon. The now value is the current time. The function returns
+// the last encountered error.
+func Forecast(db *pg.DB, eventCenter eventcenter.EventCenter, now time.Time) error {
+	horizonDays, err := dbmodel.GetSettingInt(db, HorizonSettingName)
+	if err != nil {
+		return errors.WithMessagef(err, "problem getting setting %s from db", HorizonSettingName)
+	}
+	methodName, err := dbmodel.GetSettingStr(db, MethodSettingName)
+	if err != nil {
+		return errors.WithMessagef(err, "problem getting setting %s from db", MethodSettingName)
+	}
+	method := Method(methodName)
+	if !IsValidMethod(methodName) {
+		log.WithField("method", methodName).Warn("Unsupported utilization forecast method; using the linear method")
+		method = MethodLinear
+	}
+
+	samples, err := dbmodel.GetUtilizationSamplesSince(db, dbmodel.UtilizationResolutionHourly, now.Add(-historyWindow))
+	if err != nil {
+		return err
+	}
+	objectSamples := make(map[objectKey][]dbmodel.UtilizationSample)
+	for _, sample := range samples {
+		var key objectKey
+		switch {
+		case sample.SubnetID != 0:
+			key = objectKey{dbmodel.UtilizationObjectSubnet, sample.SubnetID}
+		case sample.SharedNetworkID != 0:
+			key = objectKey{dbmodel.UtilizationObjectSharedNetwork, sample.SharedNetworkID}
+		case sample.AddressPoolID != 0:
+			key = objectKey{dbmodel.UtilizationObjectAddressPool, sample.AddressPoolID}
+		case sample.PrefixPoolID != 0:
+			key = objectKey{dbmodel.UtilizationObjectPrefixPool, sample.PrefixPoolID}
+		default:
+			continue
+		}
+		objectSamples[key] = append(objectSamples[key], sample)
+	}
+
+	subnets, err := dbmodel.GetAllSubnets(db, 0)
+	if err != nil {
+		return err
+	}
+	networks, err := dbmodel.GetAllSharedNetworks(db, 0)
+	if err != nil {
+		return err
+	}
+	var objects []forecastObject
+	for i := range subnets {
+		subnet := &subnets[i]
+		subnetRef := &dbmodel.Subnet{ID: subnet.ID, Prefix: subnet.Prefix}
+		objects = append(objects, forecastObject{
+			objectType: dbmodel.UtilizationObjectSubnet,
+			id:         subnet.ID,
+			name:       subnet.Prefix,
+			subnet:     subnetRef,
+			addrAt:     subnet.AddrExhaustionAt,
+			pdAt:       subnet.PdExhaustionAt,
+		})
+		for _, localSubnet := range subnet.LocalSubnets {
+			for _, pool := range localSubnet.AddressPools {
+				objects = append(objects, forecastObject{
+					objectType: dbmodel.UtilizationObjectAddressPool,
+					id:         pool.ID,
+					name:       fmt.Sprintf("%s-%s", pool.LowerBound, pool.UpperBound),
+					subnet:     subnetRef,
+					daemon:     localSubnet.Daemon,
+					addrAt:     pool.ExhaustionAt,
+				})
+			}
+			for _, pool := range localSubnet.PrefixPools {
+				objects = append(objects, forecastObject{
+					objectType: dbmodel.UtilizationObjectPrefixPool,
+					id:         pool.ID,
+					name:       pool.Prefix,
+					subnet:     subnetRef,
+					daemon:     localSubnet.Daemon,
+					pdAt:       pool.ExhaustionAt,
+				})
+			}
+		}
+	}
+	for i := range networks {
+		network := &networks[i]
+		objects = append(objects, forecastObject{
+			objectType: dbmodel.UtilizationObjectSharedNetwork,
+			id:         network.ID,
+			name:       network.Name,
+			addrAt:     network.AddrExhaustionAt,
+			pdAt:       network.PdExhaustionAt,
+		})
+	}
+
+	horizon := time.Duration(horizonDays) * 24 * time.Hour
+	var lastErr error
+	for i := range objects {
+		object := &objects[i]
+		history := objectSamples[objectKey{object.objectType, object.id}]
+		var addrAt, pdAt time.Time
+		if object.objectType != dbmodel.UtilizationObjectPrefixPool {
+			addrAt = getExhaustionTime(history, method, false, now)
+		}
+		if object.objectType != dbmodel.UtilizationObjectAddressPool {
+			pdAt = getExhaustionTime(history, method, true, now)
+		}
+		if addrAt.Equal(object.addrAt) && pdAt.Equal(object.pdAt) {
+			continue
+		}
+		if err = dbmodel.SetExhaustionForecast(db, object.objectType, object.id, addrAt, pdAt); err != nil {
+			lastErr = err
+			continue
+		}
+		if horizonDays <= 0 {
+			continue
+		}
+		if isWithinHorizon(object.addrAt, horizon, now) != isWithinHorizon(addrAt, horizon, now) {
+			eventCenter.AddEvent(createForecastEvent(object, "Addresses", addrAt, horizonDays, method, now))
+		}
+		if isWithinHorizon(object.pdAt, horizon, now) != isWithinHorizon(pdAt, horizon, now) {
+			eventCenter.AddEvent(createForecastEvent(object, "Delegated prefixes", pdAt, horizonDays, method, now))
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,352 @@
+package forecast
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktestdbmodel "isc.org/stork/server/test/dbmodel"
+)
+
+// Returns the hourly samples taken in the last hours with the address
+// utilization growing by 10 tenths of a percent per hour and reaching the
+// specified value at the given time.
+func getLinearSamples(hours int, current int16, now time.Time) []dbmodel.UtilizationSample {
+	var samples []dbmodel.UtilizationSample
+	for h := hours; h > 0; h-- {
+		value := current - int16(10*h)
+		samples = append(samples, dbmodel.UtilizationSample{
+			Resolution:         dbmodel.UtilizationResolutionHourly,
+			SampledAt:          now.Add(-time.Duration(h) * time.Hour),
+			AddrUtilization:    value,
+			AddrUtilizationMax: value,
+		})
+	}
+	return samples
+}
+
+// Test that the forecasting methods are validated.
+func TestIsValidMethod(t *testing.T) {
+	require.True(t, IsValidMethod("linear"))
+	require.True(t, IsValidMethod("seasonal"))
+	require.False(t, IsValidMethod("quadratic"))
+	require.False(t, IsValidMethod(""))
+}
+
+// Test that the line is fitted to the points.
+func TestFitLine(t *testing.T) {
+	slope, intercept := fitLine([]point{{-2, 100}, {-1, 200}, {0, 300}})
+	require.InDelta(t, 100, slope, 1e-9)
+	require.InDelta(t, 300, intercept, 1e-9)
+
+	// All points at the same time.
+	slope, intercept = fitLine([]point{{-1, 100}, {-1, 300}})
+	require.Zero(t, slope)
+	require.InDelta(t, 200, intercept, 1e-9)
+}
+
+// Test that the exhaustion of the linearly growing utilization is
+// estimated.
+func TestEstimateDaysToExhaustionLinear(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	samples := getLinearSamples(24, 500, now)
+
+	days, ok := estimateDaysToExhaustion(samples, MethodLinear, false, now)
+	require.True(t, ok)
+	require.InDelta(t, 500./240., days, 1e-6)
+
+	// The delegated prefix utilization doesn't grow.
+	_, ok = estimateDaysToExhaustion(samples, MethodLinear, true, now)
+	require.False(t, ok)
+
+	exhaustionAt := getExhaustionTime(samples, MethodLinear, false, now)
+	require.Equal(t, now.Add(50*time.Hour), exhaustionAt)
+	require.True(t, isWithinHorizon(exhaustionAt, 3*24*time.Hour, now))
+	require.False(t, isWithinHorizon(exhaustionAt, 24*time.Hour, now))
+	require.False(t, isWithinHorizon(time.Time{}, 24*time.Hour, now))
+}
+
+// Test that the exhaustion is not forecasted when there are too few
+// samples or the utilization doesn't grow.
+func TestEstimateDaysToExhaustionNoForecast(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	_, ok := estimateDaysToExhaustion(getLinearSamples(5, 500, now), MethodLinear, false, now)
+	require.False(t, ok)
+
+	_, ok = estimateDaysToExhaustion(nil, MethodSeasonal, false, now)
+	require.False(t, ok)
+
+	// Declining utilization.
+	samples := getLinearSamples(24, 500, now)
+	for i, j := 0, len(samples)-1; i < j; i, j = i+1, j-1 {
+		samples[i].AddrUtilization, samples[j].AddrUtilization = samples[j].AddrUtilization, samples[i].AddrUtilization
+	}
+	_, ok = estimateDaysToExhaustion(samples, MethodLinear, false, now)
+	require.False(t, ok)
+
+	// The growth is too slow to be meaningful.
+	samples = getLinearSamples(7*24, 500, now)
+	for i := range samples {
+		samples[i].AddrUtilization = 100
+	}
+	samples[len(samples)-1].AddrUtilization = 101
+	_, ok = estimateDaysToExhaustion(samples, MethodLinear, false, now)
+	require.False(t, ok)
+}
+
+// Test that the exhausted pool is forecasted to be exhausted now.
+func TestEstimateDaysToExhaustionFull(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	samples := getLinearSamples(24, 1000, now)
+	for i := range samples {
+		samples[i].AddrUtilization = 1000
+	}
+	days, ok := estimateDaysToExhaustion(samples, MethodLinear, false, now)
+	require.True(t, ok)
+	require.Zero(t, days)
+}
+
+// Test that the seasonal method fits the daily peaks and ignores the
+// daily cycle of the utilization.
+func TestEstimateDaysToExhaustionSeasonal(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	var samples []dbmodel.UtilizationSample
+	for h := 119; h >= 0; h-- {
+		day := h / 24
+		value := int16(100)
+		if h%24 == 12 {
+			value = int16(600 - 100*day)
+		}
+		samples = append(samples, dbmodel.UtilizationSample{
+			Resolution:         dbmodel.UtilizationResolutionHourly,
+			SampledAt:          now.Add(-time.Duration(h) * time.Hour),
+			AddrUtilization:    100,
+			AddrUtilizationMax: value,
+		})
+	}
+
+	days, ok := estimateDaysToExhaustion(samples, MethodSeasonal, false, now)
+	require.True(t, ok)
+	require.InDelta(t, 3.5, days, 1e-6)
+
+	// The average utilization doesn't grow.
+	_, ok = estimateDaysToExhaustion(samples, MethodLinear, false, now)
+	require.False(t, ok)
+
+	// Too few days.
+	_, ok = estimateDaysToExhaustion(samples[72:], MethodSeasonal, false, now)
+	require.False(t, ok)
+}
+
+// Test that the events are created when the forecast enters and leaves
+// the horizon.
+func TestCreateForecastEvent(t *testing.T) {
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	object := &forecastObject{
+		objectType: dbmodel.UtilizationObjectSubnet,
+		id:         1,
+		name:       "192.0.2.0/24",
+		subnet:     &dbmodel.Subnet{ID: 1, Prefix: "192.0.2.0/24"},
+	}
+	event := createForecastEvent(object, "Addresses", now.Add(36*time.Hour), 30, MethodLinear, now)
+	require.Equal(t, dbmodel.EvWarning, event.Level)
+	require.Contains(t, event.Text, "Addresses in")
+	require.Contains(t, event.Text, "192.0.2.0/24")
+	require.Contains(t, event.Text, "forecast to be exhausted in 1.5 days")
+	require.EqualValues(t, 1, event.Relations.SubnetID)
+	require.Contains(t, event.Details, "2023-03-03T00:00:00Z")
+	require.Contains(t, event.Details, "method: linear")
+
+	object = &forecastObject{
+		objectType: dbmodel.UtilizationObjectSharedNetwork,
+		id:         2,
+		name:       "frog",
+	}
+	event = createForecastEvent(object, "Delegated prefixes", time.Time{}, 30, MethodSeasonal, now)
+	require.Equal(t, dbmodel.EvInfo, event.Level)
+	require.Equal(t, "Delegated prefixes in shared network frog are no longer forecast to be exhausted within 30 days", event.Text)
+
+	object = &forecastObject{
+		objectType: dbmodel.UtilizationObjectAddressPool,
+		id:         3,
+		name:       "192.0.2.10-192.0.2.100",
+		subnet:     &dbmodel.Subnet{ID: 1, Prefix: "192.0.2.0/24"},
+		daemon: &dbmodel.Daemon{
+			ID:    4,
+			Name:  dbmodel.DaemonNameDHCPv4,
+			AppID: 5,
+		},
+	}
+	event = createForecastEvent(object, "Addresses", now.Add(36*time.Hour), 30, MethodLinear, now)
+	require.Equal(t, dbmodel.EvWarning, event.Level)
+	require.Contains(t, event.Text, "Addresses in pool 192.0.2.10-192.0.2.100 of")
+	require.Contains(t, event.Text, "192.0.2.0/24")
+	require.Contains(t, event.Text, "served by <daemon")
+	require.EqualValues(t, 1, event.Relations.SubnetID)
+	require.EqualValues(t, 4, event.Relations.DaemonID)
+}
+
+// Test that the forecasts are stored in the database and the events are
+// raised when the forecasts cross the horizon.
+func TestForecast(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	require.NoError(t, dbmodel.AddSubnet(db, subnet))
+	network := &dbmodel.SharedNetwork{
+		Name:   "frog",
+		Family: 4,
+	}
+	require.NoError(t, dbmodel.AddSharedNetwork(db, network))
+
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	samples := getLinearSamples(24, 500, now)
+	for i := range samples {
+		samples[i].SubnetID = subnet.ID
+	}
+	require.NoError(t, dbmodel.AddUtilizationSamples(db, samples))
+
+	eventCenter := &storktestdbmodel.FakeEventCenter{}
+	require.NoError(t, Forecast(db, eventCenter, now))
+
+	returnedSubnet, err := dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(50*time.Hour), returnedSubnet.AddrExhaustionAt.UTC())
+	require.Zero(t, returnedSubnet.PdExhaustionAt)
+
+	returnedNetwork, err := dbmodel.GetSharedNetwork(db, network.ID)
+	require.NoError(t, err)
+	require.Zero(t, returnedNetwork.AddrExhaustionAt)
+
+	require.Len(t, eventCenter.Events, 1)
+	require.Equal(t, dbmodel.EvWarning, eventCenter.Events[0].Level)
+	require.EqualValues(t, subnet.ID, eventCenter.Events[0].Relations.SubnetID)
+
+	// The unchanged forecast doesn't raise the event again.
+	require.NoError(t, Forecast(db, eventCenter, now))
+	require.Len(t, eventCenter.Events, 1)
+
+	// The samples are outside of the history window so the forecast is
+	// cleared.
+	require.NoError(t, Forecast(db, eventCenter, now.AddDate(0, 0, 8)))
+	returnedSubnet, err = dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Zero(t, returnedSubnet.AddrExhaustionAt)
+	require.Len(t, eventCenter.Events, 2)
+	require.Equal(t, dbmodel.EvInfo, eventCenter.Events[1].Level)
+}
+
+// Test that the address pools are forecasted for the addresses and the
+// prefix pools for the delegated prefixes.
+func TestForecastPools(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	machine := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	require.NoError(t, dbmodel.AddMachine(db, machine))
+	app := &dbmodel.App{
+		MachineID: machine.ID,
+		Type:      dbmodel.AppTypeKea,
+		Daemons: []*dbmodel.Daemon{
+			dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv6, true),
+		},
+	}
+	_, err := dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "2001:db8:1::/64",
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{
+				DaemonID: app.Daemons[0].ID,
+				AddressPools: []dbmodel.AddressPool{
+					{LowerBound: "2001:db8:1::10", UpperBound: "2001:db8:1::1f"},
+				},
+				PrefixPools: []dbmodel.PrefixPool{
+					{Prefix: "3000::/48", DelegatedLen: 64},
+				},
+			},
+		},
+	}
+	require.NoError(t, dbmodel.AddSubnet(db, subnet))
+	require.NoError(t, dbmodel.AddLocalSubnets(db, subnet))
+	addressPool := subnet.LocalSubnets[0].AddressPools[0]
+	prefixPool := subnet.LocalSubnets[0].PrefixPools[0]
+
+	// The address pool samples grow for the addresses and the prefix pool
+	// samples for the delegated prefixes.
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	samples := getLinearSamples(24, 500, now)
+	for i := range samples {
+		samples[i].AddressPoolID = addressPool.ID
+	}
+	for _, sample := range getLinearSamples(24, 800, now) {
+		sample.PrefixPoolID = prefixPool.ID
+		sample.PdUtilization = sample.AddrUtilization
+		sample.PdUtilizationMax = sample.AddrUtilizationMax
+		sample.AddrUtilization = 0
+		sample.AddrUtilizationMax = 0
+		samples = append(samples, sample)
+	}
+	require.NoError(t, dbmodel.AddUtilizationSamples(db, samples))
+
+	eventCenter := &storktestdbmodel.FakeEventCenter{}
+	require.NoError(t, Forecast(db, eventCenter, now))
+
+	returnedSubnet, err := dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Zero(t, returnedSubnet.AddrExhaustionAt)
+	require.Len(t, returnedSubnet.LocalSubnets, 1)
+	require.Len(t, returnedSubnet.LocalSubnets[0].AddressPools, 1)
+	require.Equal(t, now.Add(50*time.Hour), returnedSubnet.LocalSubnets[0].AddressPools[0].ExhaustionAt.UTC())
+	require.Len(t, returnedSubnet.LocalSubnets[0].PrefixPools, 1)
+	require.Equal(t, now.Add(20*time.Hour), returnedSubnet.LocalSubnets[0].PrefixPools[0].ExhaustionAt.UTC())
+
+	require.Len(t, eventCenter.Events, 2)
+	for _, event := range eventCenter.Events {
+		require.Equal(t, dbmodel.EvWarning, event.Level)
+		require.EqualValues(t, subnet.ID, event.Relations.SubnetID)
+		require.EqualValues(t, app.Daemons[0].ID, event.Relations.DaemonID)
+	}
+	require.Contains(t, eventCenter.Events[0].Text, "Addresses in pool 2001:db8:1::10-2001:db8:1::1f")
+	require.Contains(t, eventCenter.Events[1].Text, "Delegated prefixes in pool 3000::/48")
+}
+
+// Test that the events are not raised when the horizon is zero.
+func TestForecastNoEvents(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	require.NoError(t, dbmodel.SetSettingInt(db, HorizonSettingName, 0))
+	require.NoError(t, dbmodel.SetSettingStr(db, MethodSettingName, "quadratic"))
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	require.NoError(t, dbmodel.AddSubnet(db, subnet))
+
+	now := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	samples := getLinearSamples(24, 500, now)
+	for i := range samples {
+		samples[i].SubnetID = subnet.ID
+	}
+	require.NoError(t, dbmodel.AddUtilizationSamples(db, samples))
+
+	eventCenter := &storktestdbmodel.FakeEventCenter{}
+	require.NoError(t, Forecast(db, eventCenter, now))
+	require.Empty(t, eventCenter.Events)
+
+	// The forecast is stored using the linear method.
+	returnedSubnet, err := dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.Equal(t, now.Add(50*time.Hour), returnedSubnet.AddrExhaustionAt.UTC())
+}
@@ -56,3 +56,74 @@ func (hm *HookManager) GetAuthenticationMetadata() []authenticationcallouts.Auth
 		return carrier.GetMetadata()
 	})
 }
+
+// Callout to begin the redirect-based authentication. It returns the URL of
+// the identity provider the user should be redirected to and the flow state
+// that must be passed to the completing callout.
+func (hm *HookManager) BeginAuthentication(ctx context.Context, request *http.Request, authenticationMethodID, callbackURL string) (redirectURL, flowState string, err error) {
+	type output struct {
+		redirectURL string
+		flowState   string
+		err         error
+	}
+
+	ok, data := hooksutil.CallSequentialUntilProcessed(hm.GetExecutor(), func(carrier authenticationcallouts.AuthenticationCallouts) (hooksutil.CallStatus, *output) {
+		if carrier.GetMetadata().GetID() != authenticationMethodID {
+			// Go to next authentication callout.
+			return hooksutil.CallStatusSkipped, nil
+		}
+
+		redirectCarrier, ok := carrier.(authenticationcallouts.AuthenticationRedirectCallouts)
+		if !ok {
+			return hooksutil.CallStatusProcessed, &output{
+				err: errors.Errorf("the '%s' authentication method doesn't support redirects", authenticationMethodID),
+			}
+		}
+
+		redirectURL, flowState, err := redirectCarrier.BeginAuthentication(ctx, request, callbackURL)
+		return hooksutil.CallStatusProcessed, &output{
+			redirectURL: redirectURL,
+			flowState:   flowState,
+			err:         err,
+		}
+	})
+
+	if !ok {
+		return "", "", errors.Errorf("the '%s' authentication method is not supported", authenticationMethodID)
+	}
+	return data.redirectURL, data.flowState, data.err
+}
+
+// Callout to complete the redirect-based authentication when the identity
+// provider redirects the user back to Stork.
+func (hm *HookManager) CompleteAuthentication(ctx context.Context, request *http.Request, authenticationMethodID, callbackURL, flowState string) (*authenticationcallouts.User, error) {
+	type output struct {
+		user *authenticationcallouts.User
+		err  error
+	}
+
+	ok, data := hooksutil.CallSequentialUntilProcessed(hm.GetExecutor(), func(carrier authenticationcallouts.AuthenticationCallouts) (hooksutil.CallStatus, *output) {
+		if carrier.GetMetadata().GetID() != authenticationMethodID {
+			// Go to next authentication callout.
+			return hooksutil.CallStatusSkipped, nil
+		}
+
+		redirectCarrier, ok := carrier.(authenticationcallouts.AuthenticationRedirectCallouts)
+		if !ok {
+			return hooksutil.CallStatusProcessed, &output{
+				err: errors.Errorf("the '%s' authentication method doesn't support redirects", authenticationMethodID),
+			}
+		}
+
+		user, err := redirectCarrier.CompleteAuthentication(ctx, request, callbackURL, flowState)
+		return hooksutil.CallStatusProcessed, &output{
+			user: user,
+			err:  err,
+		}
+	})
+
+	if !ok {
+		return nil, errors.Errorf("the '%s' authentication method is not supported", authenticationMethodID)
+	}
+	return data.user, data.err
+}
@@ -18,7 +18,14 @@ type authenticationCalloutCarrier interface { //nolint:unused
 	hooks.CalloutCarrier
 }
 
-//go:generate mockgen -package=hookmanager -destination=hookmanager_mock.go -source=authentication_test.go -mock_names=authenticationCalloutCarrier=MockAuthenticationCalloutCarrier isc.org/server/hookmanager authenticationCalloutCarrier AuthenticationMetadata
+// Carrier mock interface of the redirect-based authentication for mockgen.
+type authenticationRedirectCalloutCarrier interface { //nolint:unused
+	authenticationcallouts.AuthenticationCallouts
+	authenticationcallouts.AuthenticationRedirectCallouts
+	hooks.CalloutCarrier
+}
+
+//go:generate mockgen -package=hookmanager -destination=hookmanager_mock.go -source=authentication_test.go -mock_names=authenticationCalloutCarrier=MockAuthenticationCalloutCarrier,authenticationRedirectCalloutCarrier=MockAuthenticationRedirectCalloutCarrier isc.org/server/hookmanager authenticationCalloutCarrier AuthenticationMetadata
 //go:generate mockgen -package=hookmanager -destination=authenticationcallouts_mock.go -source=../../hooks/server/authenticationcallouts/authenticationcallouts.go isc.org/server/hookmanager AuthenticationMetadata
 
 // Test that the authentication callout is called.
@@ -270,3 +277,147 @@ func TestGetAuthenticationMetadataNoCalloutCarriers(t *testing.T) {
 	// Assert
 	require.Len(t, results, 0)
 }
+
+// Test that the redirect-based authentication is started by the matching
+// callout carrier.
+func TestBeginAuthentication(t *testing.T) {
+	// Arrange
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metadataMock := NewMockAuthenticationMetadata(ctrl)
+	metadataMock.EXPECT().
+		GetID().
+		Return("mock")
+
+	mock := NewMockAuthenticationRedirectCalloutCarrier(ctrl)
+	mock.EXPECT().
+		BeginAuthentication(gomock.Any(), gomock.Any(), "https://stork.example.org/api/sessions/callback/mock").
+		Return("https://idp.example.org/authorize", "state", nil).
+		Times(1)
+	mock.EXPECT().
+		GetMetadata().
+		Return(metadataMock)
+
+	hookManager := NewHookManager()
+	hookManager.RegisterCalloutCarrier(mock)
+
+	// Act
+	redirectURL, flowState, err := hookManager.BeginAuthentication(
+		context.Background(), nil, "mock",
+		"https://stork.example.org/api/sessions/callback/mock",
+	)
+
+	// Assert
+	require.NoError(t, err)
+	require.Equal(t, "https://idp.example.org/authorize", redirectURL)
+	require.Equal(t, "state", flowState)
+}
+
+// Test that an error is returned when beginning the redirect-based
+// authentication using the method that doesn't support redirects.
+func TestBeginAuthenticationNoRedirectSupport(t *testing.T) {
+	// Arrange
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metadataMock := NewMockAuthenticationMetadata(ctrl)
+	metadataMock.EXPECT().
+		GetID().
+		Return("mock")
+
+	mock := NewMockAuthenticationCalloutCarrier(ctrl)
+	mock.EXPECT().
+		GetMetadata().
+		Return(metadataMock)
+
+	hookManager := NewHookManager()
+	hookManager.RegisterCalloutCarrier(mock)
+
+	// Act
+	redirectURL, _, err := hookManager.BeginAuthentication(context.Background(), nil, "mock", "")
+
+	// Assert
+	require.ErrorContains(t, err, "doesn't support redirects")
+	require.Empty(t, redirectURL)
+}
+
+// Test that an error is returned when beginning the redirect-based
+// authentication using the unknown method.
+func TestBeginAuthenticationDefault(t *testing.T) {
+	// Arrange
+	hookManager := NewHookManager()
+
+	// Act
+	redirectURL, _, err := hookManager.BeginAuthentication(context.Background(), nil, "oidc", "")
+
+	// Assert
+	require.ErrorContains(t, err, "authentication method is not supported")
+	require.Empty(t, redirectURL)
+}
+
+// Test that the redirect-based authentication is completed by the matching
+// callout carrier and the flow state is passed to it.
+func TestCompleteAuthentication(t *testing.T) {
+	// Arrange
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metadataMock := NewMockAuthenticationMetadata(ctrl)
+	metadataMock.EXPECT().
+		GetID().
+		Return("mock")
+
+	mock := NewMockAuthenticationRedirectCalloutCarrier(ctrl)
+	mock.EXPECT().
+		CompleteAuthentication(gomock.Any(), gomock.Any(), gomock.Any(), "state").
+		Return(&authenticationcallouts.User{
+			ID:    "42",
+			Email: "foo@example.com",
+		}, nil).
+		Times(1)
+	mock.EXPECT().
+		GetMetadata().
+		Return(metadataMock)
+
+	hookManager := NewHookManager()
+	hookManager.RegisterCalloutCarrier(mock)
+
+	// Act
+	user, err := hookManager.CompleteAuthentication(context.Background(), nil, "mock", "", "state")
+
+	// Assert
+	require.NoError(t, err)
+	require.EqualValues(t, "foo@example.com", user.Email)
+}
+
+// Test that the error returned by the completing callout is propagated.
+func TestCompleteAuthenticationReturnError(t *testing.T) {
+	// Arrange
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metadataMock := NewMockAuthenticationMetadata(ctrl)
+	metadataMock.EXPECT().
+		GetID().
+		Return("mock")
+
+	mock := NewMockAuthenticationRedirectCalloutCarrier(ctrl)
+	mock.EXPECT().
+		CompleteAuthentication(gomock.Any(), gomock.Any(), gomock.Any(), gomock.Any()).
+		Return(nil, errors.New("foo")).
+		Times(1)
+	mock.EXPECT().
+		GetMetadata().
+		Return(metadataMock)
+
+	hookManager := NewHookManager()
+	hookManager.RegisterCalloutCarrier(mock)
+
+	// Act
+	user, err := hookManager.CompleteAuthentication(context.Background(), nil, "mock", "", "state")
+
+	// Assert
+	require.ErrorContains(t, err, "foo")
+	require.Nil(t, user)
+}
@@ -6,6 +6,9 @@ import (
 	"github.com/go-pg/pg/v10"
 	"github.com/pkg/errors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"isc.org/stork/server/agentcomm"
+	"isc.org/stork/server/apps"
+	"isc.org/stork/server/configreview"
 	dbmodel "isc.org/stork/server/database/model"
 	storkutil "isc.org/stork/util"
 )
@@ -32,9 +35,16 @@ type prometheusCollector struct {
 
 // Creates an instance of the metrics collector and starts
 // collecting the metrics according to the interval
-// specified in the database.
-func NewCollector(db *pg.DB) (Collector, error) {
+// specified in the database. Besides the metrics calculated
+// from the database, the collector reports the runtime
+// statistics of the pullers, the communication with the agents
+// and the configuration review checkers. These arguments may be
+// nil to skip their statistics.
+func NewCollector(db *pg.DB, pullers *apps.Pullers, agents agentcomm.ConnectedAgents, reviewDispatcher configreview.Dispatcher) (Collector, error) {
 	metrics := newMetrics(db)
+	metrics.pullers = pullers
+	metrics.agents = agents
+	metrics.reviewDispatcher = reviewDispatcher
 	intervalSettingName := "metrics_collector_interval"
 
 	// Initialize the metrics
@@ -50,7 +50,7 @@ func TestConstructController(t *testing.T) {
 	_ = dbmodel.InitializeSettings(db, 0)
 
 	// Act
-	collector, err := NewCollector(db)
+	collector, err := NewCollector(db, nil, nil, nil)
 	defer collector.Shutdown()
 
 	// Assert
@@ -66,7 +66,7 @@ func TestConstructControllerWhenIntervalSettingIsMissing(t *testing.T) {
 	defer teardown()
 
 	// Act
-	collector, err := NewCollector(db)
+	collector, err := NewCollector(db, nil, nil, nil)
 
 	// Assert
 	require.Nil(t, collector)
@@ -79,7 +79,7 @@ func TestCreateHttpHandler(t *testing.T) {
 	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
 	defer teardown()
 	_ = dbmodel.InitializeSettings(db, 0)
-	collector, _ := NewCollector(db)
+	collector, _ := NewCollector(db, nil, nil, nil)
 	defer collector.Shutdown()
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
 
@@ -96,7 +96,7 @@ func TestHandlerResponse(t *testing.T) {
 	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
 	defer teardown()
 	_ = dbmodel.InitializeSettings(db, 0)
-	collector, _ := NewCollector(db)
+	collector, _ := NewCollector(db, nil, nil, nil)
 	defer collector.Shutdown()
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
 	handler := collector.GetHTTPHandler(nextHandler)
@@ -123,7 +123,7 @@ func TestPeriodicMetricsUpdate(t *testing.T) {
 	_ = dbmodel.InitializeSettings(db, 0)
 	_ = dbmodel.SetSettingInt(db, "metrics_collector_interval", 1)
 
-	collector, _ := NewCollector(db)
+	collector, _ := NewCollector(db, nil, nil, nil)
 	defer collector.Shutdown()
 
 	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
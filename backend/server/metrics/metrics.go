@@ -10,25 +10,70 @@ package metrics
 
 import (
 	"reflect"
+	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
+	"isc.org/stork/server/agentcomm"
+	"isc.org/stork/server/apps"
+	"isc.org/stork/server/configreview"
 	dbmodel "isc.org/stork/server/database/model"
 )
 
+// Allows accessing the runtime statistics of the periodic puller.
+type pullerStatsSource interface {
+	GetName() string
+	GetStats() agentcomm.PullerStats
+}
+
+var _ pullerStatsSource = (*agentcomm.PeriodicPuller)(nil)
+
 // Set of Stork Server metrics.
 type metrics struct {
 	Registry *prometheus.Registry
 	db       *pg.DB
+	// Sources of the runtime statistics of the server components. The
+	// statistics of the nil sources are not reported.
+	pullers          *apps.Pullers
+	agents           agentcomm.ConnectedAgents
+	reviewDispatcher configreview.Dispatcher
 
-	AuthorizedMachineTotal          prometheus.Gauge
-	UnauthorizedMachineTotal        prometheus.Gauge
-	UnreachableMachineTotal         prometheus.Gauge
-	SubnetAddressUtilization        *prometheus.GaugeVec
-	SubnetPdUtilization             *prometheus.GaugeVec
-	SharedNetworkAddressUtilization *prometheus.GaugeVec
-	SharedNetworkPdUtilization      *prometheus.GaugeVec
+	AuthorizedMachineTotal             prometheus.Gauge
+	UnauthorizedMachineTotal           prometheus.Gauge
+	UnreachableMachineTotal            prometheus.Gauge
+	SubnetAddressUtilization           *prometheus.GaugeVec
+	SubnetPdUtilization                *prometheus.GaugeVec
+	SharedNetworkAddressUtilization    *prometheus.GaugeVec
+	SharedNetworkPdUtilization         *prometheus.GaugeVec
+	SubnetAddressExhaustionDays        *prometheus.GaugeVec
+	SubnetPdExhaustionDays             *prometheus.GaugeVec
+	SharedNetworkAddressExhaustionDays *prometheus.GaugeVec
+	SharedNetworkPdExhaustionDays      *prometheus.GaugeVec
+	PoolAddressUtilization             *prometheus.GaugeVec
+	PoolPdUtilization                  *prometheus.GaugeVec
+	PoolAddressExhaustionDays          *prometheus.GaugeVec
+	PoolPdExhaustionDays               *prometheus.GaugeVec
+	HAState                            *prometheus.GaugeVec
+	HAPartnerUnackedClients            *prometheus.GaugeVec
+	EventTotal                         *prometheus.GaugeVec
+	EventTableSize                     prometheus.Gauge
+	PullerRunTotal                     *prometheus.GaugeVec
+	PullerErrorTotal                   *prometheus.GaugeVec
+	PullerLastRunDuration              *prometheus.GaugeVec
+	PullerRunDurationTotal             *prometheus.GaugeVec
+	AgentCallTotal                     *prometheus.GaugeVec
+	AgentCallFailureTotal              *prometheus.GaugeVec
+	AgentCallDurationTotal             *prometheus.GaugeVec
+	CheckerRunTotal                    *prometheus.GaugeVec
+	CheckerIssueTotal                  *prometheus.GaugeVec
+	CheckerRunDurationTotal            *prometheus.GaugeVec
+	DBPoolHitTotal                     prometheus.Gauge
+	DBPoolMissTotal                    prometheus.Gauge
+	DBPoolTimeoutTotal                 prometheus.Gauge
+	DBPoolConnectionTotal              prometheus.Gauge
+	DBPoolIdleConnectionTotal          prometheus.Gauge
+	DBPoolStaleConnectionTotal         prometheus.Gauge
 }
 
 // Constructor of the metrics. They are automatically
@@ -85,6 +130,174 @@ func newMetrics(db *pg.DB) *metrics {
 			Subsystem: "shared_network",
 			Help:      "Shared-network delegated-prefix utilization",
 		}, []string{"name"}),
+		SubnetAddressExhaustionDays: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "address_exhaustion_days",
+			Subsystem: "subnet",
+			Help:      "Forecasted days until the subnet addresses are exhausted",
+		}, []string{"subnet"}),
+		SubnetPdExhaustionDays: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pd_exhaustion_days",
+			Subsystem: "subnet",
+			Help:      "Forecasted days until the subnet delegated prefixes are exhausted",
+		}, []string{"subnet"}),
+		SharedNetworkAddressExhaustionDays: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "address_exhaustion_days",
+			Subsystem: "shared_network",
+			Help:      "Forecasted days until the shared-network addresses are exhausted",
+		}, []string{"name"}),
+		SharedNetworkPdExhaustionDays: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pd_exhaustion_days",
+			Subsystem: "shared_network",
+			Help:      "Forecasted days until the shared-network delegated prefixes are exhausted",
+		}, []string{"name"}),
+		PoolAddressUtilization: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "address_utilization",
+			Subsystem: "pool",
+			Help:      "Address pool utilization",
+		}, []string{"subnet", "pool"}),
+		PoolPdUtilization: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pd_utilization",
+			Subsystem: "pool",
+			Help:      "Delegated-prefix pool utilization",
+		}, []string{"subnet", "pool"}),
+		PoolAddressExhaustionDays: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "address_exhaustion_days",
+			Subsystem: "pool",
+			Help:      "Forecasted days until the address pool is exhausted",
+		}, []string{"subnet", "pool"}),
+		PoolPdExhaustionDays: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pd_exhaustion_days",
+			Subsystem: "pool",
+			Help:      "Forecasted days until the delegated-prefix pool is exhausted",
+		}, []string{"subnet", "pool"}),
+		HAState: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "state",
+			Subsystem: "ha",
+			Help:      "Current HA state of the server (1 for the current state)",
+		}, []string{"service", "role", "state"}),
+		HAPartnerUnackedClients: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "partner_unacked_clients",
+			Subsystem: "ha",
+			Help:      "Clients unacked by the partner as seen by the HA server",
+		}, []string{"service", "role"}),
+		EventTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "total",
+			Subsystem: "event",
+			Help:      "Events stored in the database",
+		}, []string{"level"}),
+		EventTableSize: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "table_size_bytes",
+			Subsystem: "event",
+			Help:      "Size of the event table in the database including the indexes",
+		}),
+		PullerRunTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "runs_total",
+			Subsystem: "puller",
+			Help:      "Runs of the puller since the server startup",
+		}, []string{"puller"}),
+		PullerErrorTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "errors_total",
+			Subsystem: "puller",
+			Help:      "Runs of the puller that returned an error since the server startup",
+		}, []string{"puller"}),
+		PullerLastRunDuration: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_run_duration_seconds",
+			Subsystem: "puller",
+			Help:      "Duration of the last puller run",
+		}, []string{"puller"}),
+		PullerRunDurationTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "run_duration_seconds_total",
+			Subsystem: "puller",
+			Help:      "Total duration of the puller runs since the server startup",
+		}, []string{"puller"}),
+		AgentCallTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "grpc_calls_total",
+			Subsystem: "agent",
+			Help:      "gRPC calls to the agent since the server startup",
+		}, []string{"agent"}),
+		AgentCallFailureTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "grpc_call_failures_total",
+			Subsystem: "agent",
+			Help:      "Failed gRPC calls to the agent since the server startup",
+		}, []string{"agent"}),
+		AgentCallDurationTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "grpc_call_duration_seconds_total",
+			Subsystem: "agent",
+			Help:      "Total duration of the gRPC calls to the agent since the server startup",
+		}, []string{"agent"}),
+		CheckerRunTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "checker_runs_total",
+			Subsystem: "config_review",
+			Help:      "Runs of the configuration review checker since the server startup",
+		}, []string{"checker"}),
+		CheckerIssueTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "checker_issues_total",
+			Subsystem: "config_review",
+			Help:      "Issues found by the configuration review checker since the server startup",
+		}, []string{"checker"}),
+		CheckerRunDurationTotal: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "checker_duration_seconds_total",
+			Subsystem: "config_review",
+			Help:      "Total duration of the configuration review checker runs since the server startup",
+		}, []string{"checker"}),
+		DBPoolHitTotal: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "hits_total",
+			Subsystem: "db_pool",
+			Help:      "Times a free connection was found in the database connection pool",
+		}),
+		DBPoolMissTotal: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "misses_total",
+			Subsystem: "db_pool",
+			Help:      "Times a free connection was not found in the database connection pool",
+		}),
+		DBPoolTimeoutTotal: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "timeouts_total",
+			Subsystem: "db_pool",
+			Help:      "Times a wait for a database connection timed out",
+		}),
+		DBPoolConnectionTotal: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "connections_total",
+			Subsystem: "db_pool",
+			Help:      "Connections in the database connection pool",
+		}),
+		DBPoolIdleConnectionTotal: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "idle_connections_total",
+			Subsystem: "db_pool",
+			Help:      "Idle connections in the database connection pool",
+		}),
+		DBPoolStaleConnectionTotal: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "stale_connections_total",
+			Subsystem: "db_pool",
+			Help:      "Stale connections removed from the database connection pool",
+		}),
 	}
 
 	return &metrics
@@ -101,6 +314,13 @@ func (m *metrics) Update() error {
 	m.UnauthorizedMachineTotal.Set(float64(calculatedMetrics.UnauthorizedMachines))
 	m.UnreachableMachineTotal.Set(float64(calculatedMetrics.UnreachableMachines))
 
+	// The forecasts may disappear so the previous values must be removed.
+	m.SubnetAddressExhaustionDays.Reset()
+	m.SubnetPdExhaustionDays.Reset()
+	m.SharedNetworkAddressExhaustionDays.Reset()
+	m.SharedNetworkPdExhaustionDays.Reset()
+	now := time.Now()
+
 	for _, networkMetrics := range calculatedMetrics.SubnetMetrics {
 		m.SubnetAddressUtilization.
 			With(prometheus.Labels{"subnet": networkMetrics.Label}).
@@ -108,6 +328,16 @@ func (m *metrics) Update() error {
 		m.SubnetPdUtilization.
 			With(prometheus.Labels{"subnet": networkMetrics.Label}).
 			Set(float64(networkMetrics.PdUtilization) / 1000.)
+		if !networkMetrics.AddrExhaustionAt.IsZero() {
+			m.SubnetAddressExhaustionDays.
+				With(prometheus.Labels{"subnet": networkMetrics.Label}).
+				Set(getExhaustionDays(networkMetrics.AddrExhaustionAt, now))
+		}
+		if !networkMetrics.PdExhaustionAt.IsZero() {
+			m.SubnetPdExhaustionDays.
+				With(prometheus.Labels{"subnet": networkMetrics.Label}).
+				Set(getExhaustionDays(networkMetrics.PdExhaustionAt, now))
+		}
 	}
 
 	for _, networkMetrics := range calculatedMetrics.SharedNetworkMetrics {
@@ -117,11 +347,127 @@ func (m *metrics) Update() error {
 		m.SharedNetworkPdUtilization.
 			With(prometheus.Labels{"name": networkMetrics.Label}).
 			Set(float64(networkMetrics.PdUtilization) / 1000.)
+		if !networkMetrics.AddrExhaustionAt.IsZero() {
+			m.SharedNetworkAddressExhaustionDays.
+				With(prometheus.Labels{"name": networkMetrics.Label}).
+				Set(getExhaustionDays(networkMetrics.AddrExhaustionAt, now))
+		}
+		if !networkMetrics.PdExhaustionAt.IsZero() {
+			m.SharedNetworkPdExhaustionDays.
+				With(prometheus.Labels{"name": networkMetrics.Label}).
+				Set(getExhaustionDays(networkMetrics.PdExhaustionAt, now))
+		}
+	}
+
+	// The pools may be removed from the configurations.
+	m.PoolAddressUtilization.Reset()
+	m.PoolPdUtilization.Reset()
+	m.PoolAddressExhaustionDays.Reset()
+	m.PoolPdExhaustionDays.Reset()
+	for _, poolMetrics := range calculatedMetrics.PoolMetrics {
+		gauge := m.PoolAddressUtilization
+		exhaustionGauge := m.PoolAddressExhaustionDays
+		if poolMetrics.PrefixDelegation {
+			gauge = m.PoolPdUtilization
+			exhaustionGauge = m.PoolPdExhaustionDays
+		}
+		labels := prometheus.Labels{"subnet": poolMetrics.Subnet, "pool": poolMetrics.Pool}
+		gauge.With(labels).Set(float64(poolMetrics.Utilization) / 1000.)
+		if !poolMetrics.ExhaustionAt.IsZero() {
+			exhaustionGauge.With(labels).Set(getExhaustionDays(poolMetrics.ExhaustionAt, now))
+		}
+	}
+
+	// The state is a label so the previous states must be removed.
+	m.HAState.Reset()
+	m.HAPartnerUnackedClients.Reset()
+	for _, haMetrics := range calculatedMetrics.HAMetrics {
+		state := haMetrics.State
+		if state == dbmodel.HAStateNone {
+			state = dbmodel.HAStateUnavailable
+		}
+		m.HAState.
+			With(prometheus.Labels{"service": haMetrics.ServiceName, "role": haMetrics.Role, "state": string(state)}).
+			Set(1)
+		m.HAPartnerUnackedClients.
+			With(prometheus.Labels{"service": haMetrics.ServiceName, "role": haMetrics.Role}).
+			Set(float64(haMetrics.PartnerUnackedClients))
 	}
 
+	// The levels without the events are not returned.
+	m.EventTotal.Reset()
+	for _, eventMetrics := range calculatedMetrics.EventMetrics {
+		m.EventTotal.
+			With(prometheus.Labels{"level": eventMetrics.Level.String()}).
+			Set(float64(eventMetrics.Count))
+	}
+	m.EventTableSize.Set(float64(calculatedMetrics.EventTableSize))
+
+	poolStats := m.db.PoolStats()
+	m.DBPoolHitTotal.Set(float64(poolStats.Hits))
+	m.DBPoolMissTotal.Set(float64(poolStats.Misses))
+	m.DBPoolTimeoutTotal.Set(float64(poolStats.Timeouts))
+	m.DBPoolConnectionTotal.Set(float64(poolStats.TotalConns))
+	m.DBPoolIdleConnectionTotal.Set(float64(poolStats.IdleConns))
+	m.DBPoolStaleConnectionTotal.Set(float64(poolStats.StaleConns))
+
+	m.updateRuntimeMetrics()
+
 	return nil
 }
 
+// Collects the runtime statistics of the pullers, the communication with
+// the agents and the configuration review checkers.
+func (m *metrics) updateRuntimeMetrics() {
+	if m.pullers != nil {
+		v := reflect.ValueOf(*m.pullers)
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Field(i)
+			if !field.CanInterface() || field.IsNil() {
+				continue
+			}
+			puller, ok := field.Interface().(pullerStatsSource)
+			if !ok {
+				continue
+			}
+			stats := puller.GetStats()
+			labels := prometheus.Labels{"puller": puller.GetName()}
+			m.PullerRunTotal.With(labels).Set(float64(stats.Runs))
+			m.PullerErrorTotal.With(labels).Set(float64(stats.Errors))
+			m.PullerLastRunDuration.With(labels).Set(stats.LastDuration.Seconds())
+			m.PullerRunDurationTotal.With(labels).Set(stats.TotalDuration.Seconds())
+		}
+	}
+
+	if m.agents != nil {
+		for address, stats := range m.agents.GetAgentCallStats() {
+			labels := prometheus.Labels{"agent": address}
+			m.AgentCallTotal.With(labels).Set(float64(stats.Calls))
+			m.AgentCallFailureTotal.With(labels).Set(float64(stats.Failures))
+			m.AgentCallDurationTotal.With(labels).Set(stats.Duration.Seconds())
+		}
+	}
+
+	if m.reviewDispatcher != nil {
+		for checkerName, stats := range m.reviewDispatcher.GetCheckerStats() {
+			labels := prometheus.Labels{"checker": checkerName}
+			m.CheckerRunTotal.With(labels).Set(float64(stats.Runs))
+			m.CheckerIssueTotal.With(labels).Set(float64(stats.Issues))
+			m.CheckerRunDurationTotal.With(labels).Set(stats.Duration.Seconds())
+		}
+	}
+}
+
+// Returns the number of days from now until the forecasted exhaustion.
+// The exhaustion forecasted in the past is reported as zero days.
+func getExhaustionDays(exhaustionAt, now time.Time) float64 {
+	days := exhaustionAt.Sub(now).Hours() / 24
+	if days < 0 {
+		return 0
+	}
+	return days
+}
+
 // Unregister all metrics from the Prometheus registry.
 func (m *metrics) UnregisterAll() {
 	v := reflect.ValueOf(*m)
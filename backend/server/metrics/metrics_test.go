@@ -2,8 +2,18 @@ package metrics
 
 import (
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
+	"isc.org/stork/server/agentcomm"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	"isc.org/stork/server/apps"
+	"isc.org/stork/server/apps/kea"
+	"isc.org/stork/server/configreview"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
 )
 
 // All metrics should be properly constructed.
@@ -17,11 +27,11 @@ func TestNewMetrics(t *testing.T) {
 	// Prometheus has lazy-initialization of the metrics.
 	// Only the metrics with at least one value are
 	// enumerated by the gather.
-	// The 3 metrics are single counters (Gauge), they
+	// The 10 metrics are single counters (Gauge), they
 	// are initialized with 0 value at the beginning.
 	// Other metrics are vectors (GaugeVectors), they have
 	// no value at the beginning.
-	require.Len(t, mfs, 3)
+	require.Len(t, mfs, 10)
 }
 
 // All metrics should be unregistered.
@@ -36,3 +46,276 @@ func TestUnregisterAllMetrics(t *testing.T) {
 	// Arrange
 	require.Empty(t, mfs)
 }
+
+// The HA metrics should reflect the current states of the HA servers.
+func TestUpdateHAMetrics(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	var daemons []*dbmodel.Daemon
+	for i := 0; i < 2; i++ {
+		m := &dbmodel.Machine{
+			Address:   "localhost",
+			AgentPort: int64(8080 + i),
+		}
+		err := dbmodel.AddMachine(db, m)
+		require.NoError(t, err)
+		app := &dbmodel.App{
+			MachineID: m.ID,
+			Type:      dbmodel.AppTypeKea,
+			Daemons: []*dbmodel.Daemon{
+				dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true),
+			},
+		}
+		_, err = dbmodel.AddApp(db, app)
+		require.NoError(t, err)
+		daemons = append(daemons, app.Daemons[0])
+	}
+	service := &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			Name:    "ha-pair",
+			Daemons: daemons,
+		},
+		HAService: &dbmodel.BaseHAService{
+			HAType:                  dbmodel.HATypeDhcp4,
+			PrimaryID:               daemons[0].ID,
+			SecondaryID:             daemons[1].ID,
+			PrimaryLastState:        dbmodel.HAStatePartnerDown,
+			SecondaryLastState:      dbmodel.HAStateUnavailable,
+			SecondaryUnackedClients: 4,
+		},
+	}
+	err := dbmodel.AddService(db, service)
+	require.NoError(t, err)
+
+	metrics := newMetrics(db)
+	defer metrics.UnregisterAll()
+
+	// Act
+	err = metrics.Update()
+
+	// Assert
+	require.NoError(t, err)
+	require.EqualValues(t, 1, testutil.ToFloat64(metrics.HAState.WithLabelValues("ha-pair", "primary", "partner-down")))
+	require.EqualValues(t, 1, testutil.ToFloat64(metrics.HAState.WithLabelValues("ha-pair", "secondary", "unavailable")))
+	require.EqualValues(t, 4, testutil.ToFloat64(metrics.HAPartnerUnackedClients.WithLabelValues("ha-pair", "primary")))
+	require.EqualValues(t, 0, testutil.ToFloat64(metrics.HAPartnerUnackedClients.WithLabelValues("ha-pair", "secondary")))
+
+	// The previous state should be removed after the state change.
+	service.HAService.PrimaryLastState = dbmodel.HAStateLoadBalancing
+	err = dbmodel.UpdateBaseHAService(db, service.HAService)
+	require.NoError(t, err)
+	err = metrics.Update()
+	require.NoError(t, err)
+	require.EqualValues(t, 2, testutil.CollectAndCount(metrics.HAState))
+	require.EqualValues(t, 1, testutil.ToFloat64(metrics.HAState.WithLabelValues("ha-pair", "primary", "load-balancing")))
+}
+
+// The event metrics should reflect the number of the stored events.
+func TestUpdateEventMetrics(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	for _, level := range []dbmodel.EventLevel{dbmodel.EvWarning, dbmodel.EvWarning, dbmodel.EvError} {
+		err := dbmodel.AddEvent(db, &dbmodel.Event{Text: "event", Level: level})
+		require.NoError(t, err)
+	}
+
+	metrics := newMetrics(db)
+	defer metrics.UnregisterAll()
+
+	// Act
+	err := metrics.Update()
+
+	// Assert
+	require.NoError(t, err)
+	require.EqualValues(t, 2, testutil.CollectAndCount(metrics.EventTotal))
+	require.EqualValues(t, 2, testutil.ToFloat64(metrics.EventTotal.WithLabelValues("warning")))
+	require.EqualValues(t, 1, testutil.ToFloat64(metrics.EventTotal.WithLabelValues("error")))
+	require.Positive(t, testutil.ToFloat64(metrics.EventTableSize))
+}
+
+// The exhaustion metrics should reflect the forecasts stored in the database.
+func TestUpdateExhaustionMetrics(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err := dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+	network := &dbmodel.SharedNetwork{
+		Name:   "frog",
+		Family: 6,
+	}
+	err = dbmodel.AddSharedNetwork(db, network)
+	require.NoError(t, err)
+
+	now := time.Now()
+	err = dbmodel.SetExhaustionForecast(db, dbmodel.UtilizationObjectSubnet, subnet.ID, now.AddDate(0, 0, 10), time.Time{})
+	require.NoError(t, err)
+	err = dbmodel.SetExhaustionForecast(db, dbmodel.UtilizationObjectSharedNetwork, network.ID, time.Time{}, now.AddDate(0, 0, 20))
+	require.NoError(t, err)
+
+	metrics := newMetrics(db)
+	defer metrics.UnregisterAll()
+
+	// Act
+	err = metrics.Update()
+
+	// Assert
+	require.NoError(t, err)
+	require.InDelta(t, 10, testutil.ToFloat64(metrics.SubnetAddressExhaustionDays.WithLabelValues("192.0.2.0/24")), 0.01)
+	require.InDelta(t, 20, testutil.ToFloat64(metrics.SharedNetworkPdExhaustionDays.WithLabelValues("frog")), 0.01)
+	require.Zero(t, testutil.CollectAndCount(metrics.SubnetPdExhaustionDays))
+
+	// The metric should be removed when the forecast is cleared.
+	err = dbmodel.SetExhaustionForecast(db, dbmodel.UtilizationObjectSubnet, subnet.ID, time.Time{}, time.Time{})
+	require.NoError(t, err)
+	err = metrics.Update()
+	require.NoError(t, err)
+	require.Zero(t, testutil.CollectAndCount(metrics.SubnetAddressExhaustionDays))
+}
+
+// The pool metrics should reflect the pool utilization stored in the database.
+func TestUpdatePoolMetrics(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+	app := &dbmodel.App{
+		MachineID: m.ID,
+		Type:      dbmodel.AppTypeKea,
+		Daemons: []*dbmodel.Daemon{
+			dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv6, true),
+		},
+	}
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "2001:db8:1::/64",
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{
+				DaemonID: app.Daemons[0].ID,
+				AddressPools: []dbmodel.AddressPool{
+					{LowerBound: "2001:db8:1::10", UpperBound: "2001:db8:1::1f"},
+					{LowerBound: "2001:db8:1::20", UpperBound: "2001:db8:1::2f"},
+				},
+				PrefixPools: []dbmodel.PrefixPool{
+					{Prefix: "3000::/48", DelegatedLen: 64},
+				},
+			},
+		},
+	}
+	err = dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+	err = dbmodel.AddLocalSubnets(db, subnet)
+	require.NoError(t, err)
+
+	localSubnet := subnet.LocalSubnets[0]
+	err = localSubnet.AddressPools[0].UpdateStats(db, dbmodel.SubnetStats{
+		"total-nas":    uint64(16),
+		"assigned-nas": uint64(16),
+	})
+	require.NoError(t, err)
+	err = localSubnet.PrefixPools[0].UpdateStats(db, dbmodel.SubnetStats{
+		"total-pds":    uint64(65536),
+		"assigned-pds": uint64(16384),
+	})
+	require.NoError(t, err)
+	err = dbmodel.SetExhaustionForecast(db, dbmodel.UtilizationObjectPrefixPool, localSubnet.PrefixPools[0].ID,
+		time.Time{}, time.Now().Add(49*time.Hour))
+	require.NoError(t, err)
+
+	metrics := newMetrics(db)
+	defer metrics.UnregisterAll()
+
+	// Act
+	err = metrics.Update()
+
+	// Assert
+	require.NoError(t, err)
+	// The pool without the statistics has no metric.
+	require.EqualValues(t, 1, testutil.CollectAndCount(metrics.PoolAddressUtilization))
+	require.EqualValues(t, 1, testutil.ToFloat64(metrics.PoolAddressUtilization.WithLabelValues("2001:db8:1::/64", "2001:db8:1::10-2001:db8:1::1f")))
+	require.EqualValues(t, 1, testutil.CollectAndCount(metrics.PoolPdUtilization))
+	require.EqualValues(t, 0.25, testutil.ToFloat64(metrics.PoolPdUtilization.WithLabelValues("2001:db8:1::/64", "3000::/48")))
+	// Only the forecasted pool exhaustion is reported.
+	require.Zero(t, testutil.CollectAndCount(metrics.PoolAddressExhaustionDays))
+	require.EqualValues(t, 1, testutil.CollectAndCount(metrics.PoolPdExhaustionDays))
+	require.InDelta(t, 2, testutil.ToFloat64(metrics.PoolPdExhaustionDays.WithLabelValues("2001:db8:1::/64", "3000::/48")), 0.1)
+}
+
+// Fake configuration review dispatcher returning the checker statistics.
+type fakeReviewDispatcher struct {
+	configreview.Dispatcher
+}
+
+// Returns the fixed checker statistics.
+func (d *fakeReviewDispatcher) GetCheckerStats() map[string]configreview.CheckerStats {
+	return map[string]configreview.CheckerStats{
+		"dispensable_subnet": {
+			Runs:     4,
+			Issues:   1,
+			Duration: 2 * time.Second,
+		},
+	}
+}
+
+// The runtime metrics should reflect the statistics of the pullers, the
+// communication with the agents, the configuration review checkers and
+// the database connection pool.
+func TestUpdateRuntimeMetrics(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.SetSettingInt(db, "kea_status_puller_interval", 1)
+
+	puller, err := agentcomm.NewPeriodicPuller(db, nil, "test puller", "kea_status_puller_interval",
+		func() error {
+			return errors.New("test error")
+		})
+	require.NoError(t, err)
+	defer puller.Shutdown()
+	require.Eventually(t, func() bool {
+		return puller.GetStats().Runs >= 1
+	}, 5*time.Second, 100*time.Millisecond)
+
+	metrics := newMetrics(db)
+	metrics.pullers = &apps.Pullers{
+		HAStatusPuller: &kea.HAStatusPuller{PeriodicPuller: puller},
+	}
+	metrics.agents = agentcommtest.NewFakeAgents(nil, nil)
+	metrics.reviewDispatcher = &fakeReviewDispatcher{}
+	defer metrics.UnregisterAll()
+
+	// Act
+	err = metrics.Update()
+
+	// Assert
+	require.NoError(t, err)
+	require.Positive(t, testutil.ToFloat64(metrics.PullerRunTotal.WithLabelValues("test puller")))
+	require.Positive(t, testutil.ToFloat64(metrics.PullerErrorTotal.WithLabelValues("test puller")))
+	require.EqualValues(t, 1, testutil.CollectAndCount(metrics.PullerLastRunDuration))
+
+	require.EqualValues(t, 10, testutil.ToFloat64(metrics.AgentCallTotal.WithLabelValues("localhost:8080")))
+	require.EqualValues(t, 2, testutil.ToFloat64(metrics.AgentCallFailureTotal.WithLabelValues("localhost:8080")))
+	require.EqualValues(t, 5, testutil.ToFloat64(metrics.AgentCallDurationTotal.WithLabelValues("localhost:8080")))
+
+	require.EqualValues(t, 4, testutil.ToFloat64(metrics.CheckerRunTotal.WithLabelValues("dispensable_subnet")))
+	require.EqualValues(t, 1, testutil.ToFloat64(metrics.CheckerIssueTotal.WithLabelValues("dispensable_subnet")))
+	require.EqualValues(t, 2, testutil.ToFloat64(metrics.CheckerRunDurationTotal.WithLabelValues("dispensable_subnet")))
+
+	require.Positive(t, testutil.ToFloat64(metrics.DBPoolConnectionTotal))
+}
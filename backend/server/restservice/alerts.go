@@ -0,0 +1,314 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/alerting"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+)
+
+// Creates new instance of the alert rule model used by REST API from the
+// rule instance returned from the database.
+func newRestAlertRule(r *dbmodel.AlertRule) *models.AlertRule {
+	scope := string(r.Scope)
+	metric := string(r.Metric)
+	return &models.AlertRule{
+		ID:              r.ID,
+		Name:            &r.Name,
+		Enabled:         &r.Enabled,
+		Scope:           &scope,
+		SubnetID:        r.SubnetID,
+		SharedNetworkID: r.SharedNetworkID,
+		Metric:          &metric,
+		Threshold:       r.Threshold,
+		Hysteresis:      r.Hysteresis,
+		Duration:        int64(r.Duration),
+		Level:           int64(r.Level),
+		CreatedAt:       strfmt.DateTime(r.CreatedAt),
+	}
+}
+
+// Converts the alert rule received over the REST API to the database model
+// and validates it. The current rule is nil when the rule is created.
+func newDBAlertRule(r *models.AlertRule, current *dbmodel.AlertRule) (*dbmodel.AlertRule, error) {
+	if r == nil || r.Name == nil || r.Scope == nil || r.Metric == nil {
+		return nil, errors.New("missing alert rule name, scope or metric")
+	}
+	rule := &dbmodel.AlertRule{
+		Name:            strings.TrimSpace(*r.Name),
+		Enabled:         true,
+		Scope:           dbmodel.AlertScope(*r.Scope),
+		SubnetID:        r.SubnetID,
+		SharedNetworkID: r.SharedNetworkID,
+		Metric:          dbmodel.AlertMetric(*r.Metric),
+		Threshold:       r.Threshold,
+		Hysteresis:      r.Hysteresis,
+		Duration:        int(r.Duration),
+		Level:           dbmodel.EventLevel(r.Level),
+	}
+	if r.Enabled != nil {
+		rule.Enabled = *r.Enabled
+	}
+	if rule.Level == dbmodel.EvInfo {
+		rule.Level = dbmodel.EvWarning
+	}
+	if current != nil {
+		rule.ID = current.ID
+		rule.CreatedAt = current.CreatedAt
+	}
+	if err := alerting.ValidateRule(rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// Checks if the subnet or shared network checked by the alert rule exists.
+func (r *RestAPI) checkAlertRuleTarget(rule *dbmodel.AlertRule) error {
+	switch rule.Scope {
+	case dbmodel.AlertScopeSubnet:
+		subnet, err := dbmodel.GetSubnet(r.DB, rule.SubnetID)
+		if err != nil {
+			return err
+		}
+		if subnet == nil {
+			return errors.Wrapf(dbmodel.ErrNotExists, "subnet with ID %d does not exist", rule.SubnetID)
+		}
+	case dbmodel.AlertScopeSharedNetwork:
+		network, err := dbmodel.GetSharedNetwork(r.DB, rule.SharedNetworkID)
+		if err != nil {
+			return err
+		}
+		if network == nil {
+			return errors.Wrapf(dbmodel.ErrNotExists, "shared network with ID %d does not exist", rule.SharedNetworkID)
+		}
+	}
+	return nil
+}
+
+// Returns all alert rules.
+func (r *RestAPI) GetAlertRules(ctx context.Context, params events.GetAlertRulesParams) middleware.Responder {
+	dbRules, err := dbmodel.GetAlertRules(r.DB)
+	if err != nil {
+		log.WithError(err).Error("Failed to get alert rules from the database")
+
+		msg := "Failed to get alert rules from the database"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetAlertRulesDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	rules := &models.AlertRules{
+		Items: []*models.AlertRule{},
+		Total: int64(len(dbRules)),
+	}
+	for i := range dbRules {
+		rules.Items = append(rules.Items, newRestAlertRule(&dbRules[i]))
+	}
+	return events.NewGetAlertRulesOK().WithPayload(rules)
+}
+
+// Returns the alert rule with the specified ID.
+func (r *RestAPI) GetAlertRule(ctx context.Context, params events.GetAlertRuleParams) middleware.Responder {
+	dbRule, err := dbmodel.GetAlertRuleByID(r.DB, params.ID)
+	if err != nil {
+		log.WithField("alertRuleID", params.ID).WithError(err).Error("Failed to get alert rule from the database")
+
+		msg := fmt.Sprintf("Failed to get alert rule with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetAlertRuleDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if dbRule == nil {
+		msg := fmt.Sprintf("Cannot find alert rule with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetAlertRuleDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+	return events.NewGetAlertRuleOK().WithPayload(newRestAlertRule(dbRule))
+}
+
+// Creates a new alert rule.
+func (r *RestAPI) CreateAlertRule(ctx context.Context, params events.CreateAlertRuleParams) middleware.Responder {
+	dbRule, err := newDBAlertRule(params.Rule, nil)
+	if err == nil {
+		err = r.checkAlertRuleTarget(dbRule)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to create alert rule: %s", err)
+		log.Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewCreateAlertRuleDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	conflict, err := dbmodel.AddAlertRule(r.DB, dbRule)
+	if conflict {
+		msg := fmt.Sprintf("Alert rule %s already exists", dbRule.Name)
+		log.WithError(err).Info(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewCreateAlertRuleDefault(http.StatusConflict).WithPayload(&rspErr)
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to create alert rule")
+
+		msg := fmt.Sprintf("Failed to create alert rule %s", dbRule.Name)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewCreateAlertRuleDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("rule", dbRule.Name).Info("Created new alert rule")
+
+	rspRule := newRestAlertRule(dbRule)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionCreate, dbmodel.AuditObjectAlertRule, fmt.Sprint(dbRule.ID), dbRule.Name,
+		nil, rspRule)
+	return events.NewCreateAlertRuleOK().WithPayload(rspRule)
+}
+
+// Updates the alert rule. The alerts raised by the rule are removed.
+func (r *RestAPI) UpdateAlertRule(ctx context.Context, params events.UpdateAlertRuleParams) middleware.Responder {
+	current, err := dbmodel.GetAlertRuleByID(r.DB, params.ID)
+	if err != nil {
+		log.WithField("alertRuleID", params.ID).WithError(err).Error("Failed to get alert rule from the database")
+
+		msg := fmt.Sprintf("Failed to get alert rule with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateAlertRuleDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if current == nil {
+		msg := fmt.Sprintf("Cannot find alert rule with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateAlertRuleDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+
+	dbRule, err := newDBAlertRule(params.Rule, current)
+	if err == nil {
+		err = r.checkAlertRuleTarget(dbRule)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to update alert rule: %s", err)
+		log.WithField("alertRuleID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateAlertRuleDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	conflict, err := dbmodel.UpdateAlertRule(r.DB, dbRule)
+	if conflict {
+		msg := fmt.Sprintf("Alert rule %s already exists", dbRule.Name)
+		log.WithError(err).Info(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateAlertRuleDefault(http.StatusConflict).WithPayload(&rspErr)
+	}
+	if err != nil {
+		log.WithField("alertRuleID", params.ID).WithError(err).Error("Failed to update alert rule")
+
+		msg := fmt.Sprintf("Failed to update alert rule with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateAlertRuleDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("rule", dbRule.Name).Info("Updated alert rule")
+
+	rspRule := newRestAlertRule(dbRule)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectAlertRule, fmt.Sprint(dbRule.ID), dbRule.Name,
+		newRestAlertRule(current), rspRule)
+	return events.NewUpdateAlertRuleOK().WithPayload(rspRule)
+}
+
+// Deletes the alert rule together with its alerts.
+func (r *RestAPI) DeleteAlertRule(ctx context.Context, params events.DeleteAlertRuleParams) middleware.Responder {
+	current, err := dbmodel.GetAlertRuleByID(r.DB, params.ID)
+	if err == nil && current != nil {
+		err = dbmodel.DeleteAlertRule(r.DB, params.ID)
+	}
+	if (err == nil && current == nil) || errors.Is(err, dbmodel.ErrNotExists) {
+		msg := fmt.Sprintf("Cannot find alert rule with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewDeleteAlertRuleDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+	if err != nil {
+		log.WithField("alertRuleID", params.ID).WithError(err).Error("Failed to delete alert rule")
+
+		msg := fmt.Sprintf("Failed to delete alert rule with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewDeleteAlertRuleDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("rule", current.Name).Info("Deleted alert rule")
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectAlertRule, fmt.Sprint(params.ID), current.Name,
+		newRestAlertRule(current), nil)
+	return events.NewDeleteAlertRuleOK()
+}
+
+// Returns the pending and firing alerts.
+func (r *RestAPI) GetAlerts(ctx context.Context, params events.GetAlertsParams) middleware.Responder {
+	dbStates, err := dbmodel.GetAlertStates(r.DB)
+	if err != nil {
+		log.WithError(err).Error("Failed to get alerts from the database")
+
+		msg := "Failed to get alerts from the database"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetAlertsDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	alerts := &models.Alerts{
+		Items: []*models.Alert{},
+		Total: int64(len(dbStates)),
+	}
+	for _, s := range dbStates {
+		alert := &models.Alert{
+			RuleID:     s.RuleID,
+			ObjectType: s.ObjectType,
+			ObjectID:   s.ObjectID,
+			ObjectName: s.ObjectName,
+			State:      string(s.State),
+			Value:      s.Value,
+			Since:      strfmt.DateTime(s.Since),
+			UpdatedAt:  strfmt.DateTime(s.UpdatedAt),
+		}
+		if !s.FiredAt.IsZero() {
+			alert.FiredAt = strfmt.DateTime(s.FiredAt)
+		}
+		if s.Rule != nil {
+			alert.RuleName = s.Rule.Name
+			alert.Level = int64(s.Rule.Level)
+			alert.Metric = string(s.Rule.Metric)
+			alert.Threshold = s.Rule.Threshold
+		}
+		alerts.Items = append(alerts.Items, alert)
+	}
+	return events.NewGetAlertsOK().WithPayload(alerts)
+}
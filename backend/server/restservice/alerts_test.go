@@ -0,0 +1,202 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+	storkutil "isc.org/stork/util"
+)
+
+// Test that the alert rules can be created, fetched, updated and deleted
+// over the REST API.
+func TestAlertRules(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	subnet := &dbmodel.Subnet{Prefix: "192.0.2.0/24"}
+	require.NoError(t, dbmodel.AddSubnet(db, subnet))
+
+	// Create the rule with the default settings.
+	rsp := rapi.CreateAlertRule(ctx, events.CreateAlertRuleParams{
+		Rule: &models.AlertRule{
+			Name:      storkutil.Ptr("subnet full"),
+			Scope:     storkutil.Ptr("subnet"),
+			SubnetID:  subnet.ID,
+			Metric:    storkutil.Ptr("address-utilization"),
+			Threshold: 90,
+			Duration:  300,
+		},
+	})
+	require.IsType(t, &events.CreateAlertRuleOK{}, rsp)
+	created := rsp.(*events.CreateAlertRuleOK).Payload
+	require.NotZero(t, created.ID)
+	require.True(t, *created.Enabled)
+	require.EqualValues(t, dbmodel.EvWarning, created.Level)
+	require.Equal(t, subnet.ID, created.SubnetID)
+	require.EqualValues(t, 90, created.Threshold)
+
+	// The names are unique.
+	rsp = rapi.CreateAlertRule(ctx, events.CreateAlertRuleParams{
+		Rule: &models.AlertRule{
+			Name:   storkutil.Ptr("subnet full"),
+			Scope:  storkutil.Ptr("all"),
+			Metric: storkutil.Ptr("declined"),
+		},
+	})
+	require.IsType(t, &events.CreateAlertRuleDefault{}, rsp)
+	require.Equal(t, http.StatusConflict, getStatusCode(*rsp.(*events.CreateAlertRuleDefault)))
+
+	// Invalid threshold.
+	rsp = rapi.CreateAlertRule(ctx, events.CreateAlertRuleParams{
+		Rule: &models.AlertRule{
+			Name:      storkutil.Ptr("invalid"),
+			Scope:     storkutil.Ptr("all"),
+			Metric:    storkutil.Ptr("pd-utilization"),
+			Threshold: 120,
+		},
+	})
+	require.IsType(t, &events.CreateAlertRuleDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*events.CreateAlertRuleDefault)))
+
+	// Non-existing shared network.
+	rsp = rapi.CreateAlertRule(ctx, events.CreateAlertRuleParams{
+		Rule: &models.AlertRule{
+			Name:            storkutil.Ptr("network"),
+			Scope:           storkutil.Ptr("shared-network"),
+			SharedNetworkID: 100,
+			Metric:          storkutil.Ptr("free-addresses"),
+		},
+	})
+	require.IsType(t, &events.CreateAlertRuleDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*events.CreateAlertRuleDefault)))
+
+	rsp = rapi.GetAlertRules(ctx, events.GetAlertRulesParams{})
+	require.IsType(t, &events.GetAlertRulesOK{}, rsp)
+	rules := rsp.(*events.GetAlertRulesOK).Payload
+	require.EqualValues(t, 1, rules.Total)
+	require.Equal(t, "subnet full", *rules.Items[0].Name)
+
+	// Update the rule.
+	rsp = rapi.UpdateAlertRule(ctx, events.UpdateAlertRuleParams{
+		ID: created.ID,
+		Rule: &models.AlertRule{
+			Name:       storkutil.Ptr("all subnets full"),
+			Enabled:    storkutil.Ptr(false),
+			Scope:      storkutil.Ptr("all"),
+			Metric:     storkutil.Ptr("address-utilization"),
+			Threshold:  95,
+			Hysteresis: 5,
+			Level:      int64(dbmodel.EvError),
+		},
+	})
+	require.IsType(t, &events.UpdateAlertRuleOK{}, rsp)
+
+	rsp = rapi.GetAlertRule(ctx, events.GetAlertRuleParams{ID: created.ID})
+	require.IsType(t, &events.GetAlertRuleOK{}, rsp)
+	rule := rsp.(*events.GetAlertRuleOK).Payload
+	require.Equal(t, "all subnets full", *rule.Name)
+	require.False(t, *rule.Enabled)
+	require.Equal(t, "all", *rule.Scope)
+	require.Zero(t, rule.SubnetID)
+	require.EqualValues(t, 5, rule.Hysteresis)
+	require.EqualValues(t, dbmodel.EvError, rule.Level)
+
+	// The scope must match the specified subnet.
+	rsp = rapi.UpdateAlertRule(ctx, events.UpdateAlertRuleParams{
+		ID: created.ID,
+		Rule: &models.AlertRule{
+			Name:     storkutil.Ptr("all subnets full"),
+			Scope:    storkutil.Ptr("all"),
+			SubnetID: subnet.ID,
+			Metric:   storkutil.Ptr("address-utilization"),
+		},
+	})
+	require.IsType(t, &events.UpdateAlertRuleDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*events.UpdateAlertRuleDefault)))
+
+	rsp = rapi.UpdateAlertRule(ctx, events.UpdateAlertRuleParams{
+		ID: created.ID + 100,
+		Rule: &models.AlertRule{
+			Name:   storkutil.Ptr("other"),
+			Scope:  storkutil.Ptr("all"),
+			Metric: storkutil.Ptr("declined"),
+		},
+	})
+	require.IsType(t, &events.UpdateAlertRuleDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*events.UpdateAlertRuleDefault)))
+
+	rsp = rapi.DeleteAlertRule(ctx, events.DeleteAlertRuleParams{ID: created.ID})
+	require.IsType(t, &events.DeleteAlertRuleOK{}, rsp)
+	rsp = rapi.DeleteAlertRule(ctx, events.DeleteAlertRuleParams{ID: created.ID})
+	require.IsType(t, &events.DeleteAlertRuleDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*events.DeleteAlertRuleDefault)))
+	rsp = rapi.GetAlertRule(ctx, events.GetAlertRuleParams{ID: created.ID})
+	require.IsType(t, &events.GetAlertRuleDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*events.GetAlertRuleDefault)))
+}
+
+// Test that the alert state is returned over the REST API.
+func TestGetAlerts(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	rsp := rapi.GetAlerts(ctx, events.GetAlertsParams{})
+	require.IsType(t, &events.GetAlertsOK{}, rsp)
+	require.Zero(t, rsp.(*events.GetAlertsOK).Payload.Total)
+
+	rule := &dbmodel.AlertRule{
+		Name:      "declined",
+		Enabled:   true,
+		Scope:     dbmodel.AlertScopeAll,
+		Metric:    dbmodel.AlertMetricDeclined,
+		Threshold: 10,
+		Level:     dbmodel.EvError,
+	}
+	_, err = dbmodel.AddAlertRule(db, rule)
+	require.NoError(t, err)
+
+	since := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, dbmodel.UpsertAlertState(db, &dbmodel.AlertState{
+		RuleID:     rule.ID,
+		ObjectType: dbmodel.AlertObjectSubnet,
+		ObjectID:   1,
+		ObjectName: "192.0.2.0/24",
+		State:      dbmodel.AlertStateFiring,
+		Value:      12,
+		Since:      since,
+		FiredAt:    since,
+		UpdatedAt:  since,
+	}))
+
+	rsp = rapi.GetAlerts(ctx, events.GetAlertsParams{})
+	require.IsType(t, &events.GetAlertsOK{}, rsp)
+	alerts := rsp.(*events.GetAlertsOK).Payload
+	require.EqualValues(t, 1, alerts.Total)
+	alert := alerts.Items[0]
+	require.Equal(t, rule.ID, alert.RuleID)
+	require.Equal(t, "declined", alert.RuleName)
+	require.EqualValues(t, dbmodel.EvError, alert.Level)
+	require.Equal(t, "declined", alert.Metric)
+	require.EqualValues(t, 10, alert.Threshold)
+	require.Equal(t, "subnet", alert.ObjectType)
+	require.EqualValues(t, 1, alert.ObjectID)
+	require.Equal(t, "192.0.2.0/24", alert.ObjectName)
+	require.Equal(t, "firing", alert.State)
+	require.EqualValues(t, 12, alert.Value)
+	require.Equal(t, since, time.Time(alert.FiredAt))
+}
@@ -0,0 +1,214 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/users"
+)
+
+// Creates new instance of the API token model used by REST API from the
+// token instance returned from the database. The token itself is never
+// included.
+func newRestAPIToken(t dbmodel.APIToken) *models.APIToken {
+	token := &models.APIToken{
+		ID:          t.ID,
+		Name:        &t.Name,
+		CreatedAt:   strfmt.DateTime(t.CreatedAt),
+		ExpiresAt:   strfmt.DateTime(t.ExpiresAt),
+		LastUsedAt:  strfmt.DateTime(t.LastUsedAt),
+		Permissions: []*models.GroupPermission{},
+	}
+	for _, p := range t.Permissions {
+		token.Permissions = append(token.Permissions, newRestPermission(p))
+	}
+	return token
+}
+
+// Checks if the request was authenticated with an API token. The tokens
+// must not be used to manage the tokens because it would allow creating
+// a token with more permissions than the token used in the request.
+func (r *RestAPI) isAPITokenRequest(ctx context.Context) bool {
+	return r.SessionManager.APIToken(ctx) != nil
+}
+
+// Returns the personal API tokens of the user.
+func (r *RestAPI) GetUserAPITokens(ctx context.Context, params users.GetUserAPITokensParams) middleware.Responder {
+	if r.isAPITokenRequest(ctx) {
+		msg := "API tokens cannot be managed using an API token"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewGetUserAPITokensDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+
+	dbTokens, err := dbmodel.GetAPITokensByUserID(r.DB, int(params.ID))
+	if err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to get API tokens from the database")
+
+		msg := fmt.Sprintf("Failed to get API tokens of user with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewGetUserAPITokensDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	tokens := &models.APITokens{
+		Items: []*models.APIToken{},
+		Total: int64(len(dbTokens)),
+	}
+	for _, t := range dbTokens {
+		tokens.Items = append(tokens.Items, newRestAPIToken(t))
+	}
+	return users.NewGetUserAPITokensOK().WithPayload(tokens)
+}
+
+// Creates a new personal API token of the user. The generated token is
+// returned in the response and only its hash is stored in the database.
+func (r *RestAPI) CreateUserAPIToken(ctx context.Context, params users.CreateUserAPITokenParams) middleware.Responder {
+	if r.isAPITokenRequest(ctx) {
+		msg := "API tokens cannot be managed using an API token"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserAPITokenDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+
+	if params.Token == nil || params.Token.Name == nil || len(strings.TrimSpace(*params.Token.Name)) == 0 {
+		msg := "Failed to create API token: missing token name"
+		log.WithField("userID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserAPITokenDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	dbToken := &dbmodel.APIToken{
+		UserID:    int(params.ID),
+		Name:      strings.TrimSpace(*params.Token.Name),
+		ExpiresAt: time.Time(params.Token.ExpiresAt).UTC(),
+	}
+	if !dbToken.ExpiresAt.IsZero() && dbToken.IsExpired(time.Now().UTC()) {
+		msg := "Failed to create API token: expiration time is in the past"
+		log.WithField("userID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserAPITokenDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+	for _, p := range params.Token.Permissions {
+		permission, err := newDBPermission(p)
+		if err != nil {
+			msg := fmt.Sprintf("Failed to create API token: %s", err)
+			log.WithField("userID", params.ID).Warn(msg)
+			rspErr := models.APIError{
+				Message: &msg,
+			}
+			return users.NewCreateUserAPITokenDefault(http.StatusBadRequest).WithPayload(&rspErr)
+		}
+		dbToken.Permissions = append(dbToken.Permissions, *permission)
+	}
+
+	user, err := dbmodel.GetUserByID(r.DB, int(params.ID))
+	if err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to get user from the database")
+
+		msg := fmt.Sprintf("Failed to get user with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserAPITokenDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if user == nil {
+		msg := fmt.Sprintf("Failed to find user with ID %d in the database", params.ID)
+		log.WithField("userID", params.ID).Error(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserAPITokenDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+
+	token, hash, err := dbmodel.GenerateAPIToken()
+	if err == nil {
+		dbToken.TokenHash = hash
+		var conflict bool
+		conflict, err = dbmodel.AddAPIToken(r.DB, dbToken)
+		if conflict {
+			msg := fmt.Sprintf("API token %s already exists", dbToken.Name)
+			log.WithField("userID", params.ID).WithError(err).Info(msg)
+			rspErr := models.APIError{
+				Message: &msg,
+			}
+			return users.NewCreateUserAPITokenDefault(http.StatusConflict).WithPayload(&rspErr)
+		}
+	}
+	if err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to create API token")
+
+		msg := fmt.Sprintf("Failed to create API token for user %s", user.Identity())
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserAPITokenDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithFields(log.Fields{
+		"userID": params.ID,
+		"token":  dbToken.Name,
+	}).Info("Created new API token")
+
+	rspToken := newRestAPIToken(*dbToken)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionCreate, dbmodel.AuditObjectAPIToken, fmt.Sprint(dbToken.ID), dbToken.Name,
+		nil, rspToken)
+	rspToken.Token = token
+	return users.NewCreateUserAPITokenOK().WithPayload(rspToken)
+}
+
+// Revokes the API token of the user by deleting it from the database.
+func (r *RestAPI) DeleteUserAPIToken(ctx context.Context, params users.DeleteUserAPITokenParams) middleware.Responder {
+	if r.isAPITokenRequest(ctx) {
+		msg := "API tokens cannot be managed using an API token"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteUserAPITokenDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+
+	err := dbmodel.DeleteAPIToken(r.DB, int(params.ID), params.TokenID)
+	switch {
+	case errors.Is(err, dbmodel.ErrNotExists):
+		msg := fmt.Sprintf("Failed to find API token with ID %d of user with ID %d", params.TokenID, params.ID)
+		log.WithField("userID", params.ID).Error(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteUserAPITokenDefault(http.StatusNotFound).WithPayload(&rspErr)
+	case err != nil:
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to delete API token")
+
+		msg := fmt.Sprintf("Failed to delete API token with ID %d", params.TokenID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteUserAPITokenDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithFields(log.Fields{
+		"userID":  params.ID,
+		"tokenID": params.TokenID,
+	}).Info("Revoked API token")
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectAPIToken, fmt.Sprint(params.TokenID), "",
+		nil, nil)
+
+	return users.NewDeleteUserAPITokenOK()
+}
@@ -0,0 +1,177 @@
+package restservice
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/users"
+	storkutil "isc.org/stork/util"
+)
+
+// Test that the API tokens can be created, listed and revoked.
+func TestCreateGetDeleteUserAPIToken(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	ctx := context.Background()
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	user := &dbmodel.SystemUser{
+		Login:    "bot",
+		Lastname: "Bot",
+		Name:     "CI",
+		Groups:   []*dbmodel.SystemGroup{{ID: 3}},
+	}
+	_, err = dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	resource := "hosts"
+	action := "read"
+	expiresAt := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+	rsp := rapi.CreateUserAPIToken(ctx, users.CreateUserAPITokenParams{
+		ID: int64(user.ID),
+		Token: &models.APIToken{
+			Name:      storkutil.Ptr("ci"),
+			ExpiresAt: strfmt.DateTime(expiresAt),
+			Permissions: []*models.GroupPermission{
+				{Resource: &resource, Action: &action},
+			},
+		},
+	})
+	require.IsType(t, &users.CreateUserAPITokenOK{}, rsp)
+	created := rsp.(*users.CreateUserAPITokenOK).Payload
+	require.NotZero(t, created.ID)
+	require.True(t, strings.HasPrefix(created.Token, dbmodel.APITokenPrefix))
+	require.Len(t, created.Permissions, 1)
+
+	// The token is stored hashed.
+	dbToken, err := dbmodel.GetAPITokenByHash(db, dbmodel.HashAPIToken(created.Token))
+	require.NoError(t, err)
+	require.NotNil(t, dbToken)
+	require.Equal(t, expiresAt, dbToken.ExpiresAt)
+
+	// The same name again.
+	rsp = rapi.CreateUserAPIToken(ctx, users.CreateUserAPITokenParams{
+		ID:    int64(user.ID),
+		Token: &models.APIToken{Name: storkutil.Ptr("ci")},
+	})
+	require.IsType(t, &users.CreateUserAPITokenDefault{}, rsp)
+	require.Equal(t, http.StatusConflict, getStatusCode(*rsp.(*users.CreateUserAPITokenDefault)))
+
+	// Missing name.
+	rsp = rapi.CreateUserAPIToken(ctx, users.CreateUserAPITokenParams{
+		ID:    int64(user.ID),
+		Token: &models.APIToken{Name: storkutil.Ptr(" ")},
+	})
+	require.IsType(t, &users.CreateUserAPITokenDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*users.CreateUserAPITokenDefault)))
+
+	// Expiration time in the past.
+	rsp = rapi.CreateUserAPIToken(ctx, users.CreateUserAPITokenParams{
+		ID: int64(user.ID),
+		Token: &models.APIToken{
+			Name:      storkutil.Ptr("old"),
+			ExpiresAt: strfmt.DateTime(time.Now().Add(-time.Hour)),
+		},
+	})
+	require.IsType(t, &users.CreateUserAPITokenDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*users.CreateUserAPITokenDefault)))
+
+	// Non-existing user.
+	rsp = rapi.CreateUserAPIToken(ctx, users.CreateUserAPITokenParams{
+		ID:    int64(user.ID + 100),
+		Token: &models.APIToken{Name: storkutil.Ptr("ci")},
+	})
+	require.IsType(t, &users.CreateUserAPITokenDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*users.CreateUserAPITokenDefault)))
+
+	// List the tokens. The token itself is not returned.
+	rsp = rapi.GetUserAPITokens(ctx, users.GetUserAPITokensParams{ID: int64(user.ID)})
+	require.IsType(t, &users.GetUserAPITokensOK{}, rsp)
+	tokens := rsp.(*users.GetUserAPITokensOK).Payload
+	require.EqualValues(t, 1, tokens.Total)
+	require.Len(t, tokens.Items, 1)
+	require.Equal(t, "ci", *tokens.Items[0].Name)
+	require.Empty(t, tokens.Items[0].Token)
+
+	// Revoke the token.
+	rsp = rapi.DeleteUserAPIToken(ctx, users.DeleteUserAPITokenParams{ID: int64(user.ID), TokenID: created.ID})
+	require.IsType(t, &users.DeleteUserAPITokenOK{}, rsp)
+
+	rsp = rapi.DeleteUserAPIToken(ctx, users.DeleteUserAPITokenParams{ID: int64(user.ID), TokenID: created.ID})
+	require.IsType(t, &users.DeleteUserAPITokenDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*users.DeleteUserAPITokenDefault)))
+
+	dbToken, err = dbmodel.GetAPITokenByHash(db, dbmodel.HashAPIToken(created.Token))
+	require.NoError(t, err)
+	require.Nil(t, dbToken)
+}
+
+// Test that the API token permissions narrow down the user permissions
+// and the tokens cannot be managed using a token.
+func TestAPITokenAuthorization(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	user := &dbmodel.SystemUser{
+		Login:    "bot",
+		Lastname: "Bot",
+		Name:     "CI",
+		Groups:   []*dbmodel.SystemGroup{{ID: dbmodel.AdminGroupID}},
+	}
+	_, err = dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	token, hash, err := dbmodel.GenerateAPIToken()
+	require.NoError(t, err)
+	_, err = dbmodel.AddAPIToken(db, &dbmodel.APIToken{
+		UserID:    user.ID,
+		Name:      "hosts-reader",
+		TokenHash: hash,
+		Permissions: []dbmodel.SystemGroupPermission{
+			{Resource: dbmodel.PermissionResourceHosts, Action: dbmodel.PermissionActionRead},
+			// The user has no permission to manage the users, so this
+			// permission has no effect.
+			{Resource: dbmodel.PermissionResourceUsers, Action: dbmodel.PermissionActionRead},
+		},
+	})
+	require.NoError(t, err)
+
+	// Capture the errors returned by the authorizer for the requests passed
+	// through the session middleware.
+	var authErr error
+	handler := rapi.SessionManager.SessionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authErr = rapi.Authorizer(req)
+		if authErr == nil {
+			rsp := rapi.GetUserAPITokens(req.Context(), users.GetUserAPITokensParams{ID: int64(user.ID)})
+			require.IsType(t, &users.GetUserAPITokensDefault{}, rsp)
+			require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.GetUserAPITokensDefault)))
+		}
+	}))
+	authorize := func(method, path string) error {
+		req, _ := http.NewRequestWithContext(context.Background(), method, "http://example.org/api"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		authErr = errors.New("request rejected by the session middleware")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return authErr
+	}
+
+	require.NoError(t, authorize("GET", "/hosts"))
+	require.Error(t, authorize("DELETE", "/hosts/1"))
+	require.Error(t, authorize("GET", "/machines"))
+	require.Error(t, authorize("GET", "/users"))
+}
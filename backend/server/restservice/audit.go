@@ -0,0 +1,190 @@
+package restservice
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	pkgerrors "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/auditlog"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+)
+
+// Parses the addresses of the trusted reverse proxies. Each address is
+// an IP address or a prefix in the CIDR notation.
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	var parsed []*net.IPNet
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, pkgerrors.Errorf("invalid trusted proxy address %s", proxy)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			parsed = append(parsed, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, prefix, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, pkgerrors.Errorf("invalid trusted proxy prefix %s", proxy)
+		}
+		parsed = append(parsed, prefix)
+	}
+	return parsed, nil
+}
+
+// Returns the IP address of the client sending the request. It is the
+// remote address of the connection unless the request comes from one of
+// the trusted reverse proxies. In this case, the address specified by the
+// proxy in the X-Real-IP header takes precedence.
+func getSourceIP(req *http.Request, trustedProxies []*net.IPNet) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+	realIP := net.ParseIP(strings.TrimSpace(req.Header.Get("X-Real-IP")))
+	if realIP == nil {
+		return host
+	}
+	if remoteIP := net.ParseIP(host); remoteIP != nil {
+		for _, proxy := range trustedProxies {
+			if proxy.Contains(remoteIP) {
+				return realIP.String()
+			}
+		}
+	}
+	return host
+}
+
+// Records the change made by the user in the audit log. The user and the
+// source IP address are taken from the request. The before and after
+// values are the states of the changed object (typically the REST API
+// models) used to compute the diff. The nil before value means that the
+// object has been created and the nil after value means that the object
+// has been deleted. The failure to record the change is logged but it
+// doesn't affect the request.
+func (r *RestAPI) recordAudit(req *http.Request, action, objectType, objectID, objectName string, before, after interface{}) {
+	entry := &dbmodel.AuditEntry{
+		Action:     action,
+		ObjectType: objectType,
+		ObjectID:   objectID,
+		ObjectName: objectName,
+	}
+	if req != nil {
+		entry.SourceIP = getSourceIP(req, r.trustedProxies)
+		if ok, user := r.SessionManager.Logged(req.Context()); ok {
+			entry.UserID = user.ID
+			entry.UserIdentity = user.Identity()
+		}
+	}
+	if entry.UserIdentity == "" {
+		entry.UserIdentity = "unknown"
+	}
+
+	diff, err := auditlog.ComputeDiff(before, after)
+	if err != nil {
+		log.WithError(err).Warnf("Cannot compute the diff of the %s %s for the audit log", objectType, objectID)
+	}
+	if len(diff) > 0 {
+		entry.Diff = diff
+	}
+
+	if err = dbmodel.AddAuditEntry(r.DB, entry); err != nil {
+		log.WithError(err).Errorf("Cannot record %s of the %s %s in the audit log", action, objectType, objectID)
+	}
+}
+
+// Converts the audit log entry to the REST API format. The changes are
+// sorted by the field names.
+func newRestAuditEntry(dbEntry *dbmodel.AuditEntry) *models.AuditEntry {
+	entry := &models.AuditEntry{
+		ID:           dbEntry.ID,
+		CreatedAt:    strfmt.DateTime(dbEntry.CreatedAt),
+		UserID:       int64(dbEntry.UserID),
+		UserIdentity: dbEntry.UserIdentity,
+		SourceIP:     dbEntry.SourceIP,
+		Action:       dbEntry.Action,
+		ObjectType:   dbEntry.ObjectType,
+		ObjectID:     dbEntry.ObjectID,
+		ObjectName:   dbEntry.ObjectName,
+		Diff:         []*models.AuditChange{},
+	}
+	for field, change := range dbEntry.Diff {
+		entry.Diff = append(entry.Diff, &models.AuditChange{
+			Field:  field,
+			Before: change.Before,
+			After:  change.After,
+		})
+	}
+	sort.Slice(entry.Diff, func(i, j int) bool {
+		return entry.Diff[i].Field < entry.Diff[j].Field
+	})
+	return entry
+}
+
+// Get the audit log entries matching the filters, starting from the most
+// recent ones.
+func (r *RestAPI) GetAuditEntries(ctx context.Context, params events.GetAuditEntriesParams) middleware.Responder {
+	var start int64
+	if params.Start != nil {
+		start = *params.Start
+	}
+
+	var limit int64 = 10
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	filters := &dbmodel.AuditEntriesByPageFilters{
+		UserID:     params.User,
+		Action:     params.Action,
+		ObjectType: params.ObjectType,
+		ObjectID:   params.ObjectID,
+	}
+	if params.From != nil {
+		from := time.Time(*params.From)
+		filters.From = &from
+	}
+	if params.To != nil {
+		to := time.Time(*params.To)
+		filters.To = &to
+	}
+
+	dbEntries, total, err := dbmodel.GetAuditEntriesByPage(r.DB, start, limit, filters, "created_at", dbmodel.SortDirDesc)
+	if err != nil {
+		msg := "Problem fetching audit log entries from the database"
+		log.Error(err)
+		rsp := events.NewGetAuditEntriesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	entries := &models.AuditEntries{
+		Items: []*models.AuditEntry{},
+		Total: total,
+	}
+	for i := range dbEntries {
+		entries.Items = append(entries.Items, newRestAuditEntry(&dbEntries[i]))
+	}
+
+	rsp := events.NewGetAuditEntriesOK().WithPayload(entries)
+	return rsp
+}
@@ -0,0 +1,165 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+	"isc.org/stork/server/gen/restapi/operations/settings"
+	storkutil "isc.org/stork/util"
+)
+
+// Test that the source IP address is taken from the remote address or,
+// for the requests from the trusted proxies, from the proxy header.
+func TestGetSourceIP(t *testing.T) {
+	trustedProxies, err := parseTrustedProxies([]string{"192.0.2.1", "2001:db8:1::/64"})
+	require.NoError(t, err)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://example.org/api/settings", nil)
+	req.RemoteAddr = "192.0.2.1:43210"
+	require.Equal(t, "192.0.2.1", getSourceIP(req, trustedProxies))
+
+	req.RemoteAddr = "[2001:db8::1]:43210"
+	require.Equal(t, "2001:db8::1", getSourceIP(req, trustedProxies))
+
+	req.RemoteAddr = "unix"
+	require.Equal(t, "unix", getSourceIP(req, trustedProxies))
+
+	// The header is ignored for the requests not coming from the
+	// trusted proxies.
+	req.Header.Set("X-Real-IP", "198.51.100.1")
+	require.Equal(t, "unix", getSourceIP(req, trustedProxies))
+
+	req.RemoteAddr = "[2001:db8::1]:43210"
+	require.Equal(t, "2001:db8::1", getSourceIP(req, trustedProxies))
+
+	req.RemoteAddr = "192.0.2.2:43210"
+	require.Equal(t, "192.0.2.2", getSourceIP(req, trustedProxies))
+
+	req.RemoteAddr = "192.0.2.1:43210"
+	require.Equal(t, "192.0.2.1", getSourceIP(req, nil))
+
+	// The header is used for the requests from the trusted proxies.
+	require.Equal(t, "198.51.100.1", getSourceIP(req, trustedProxies))
+
+	req.RemoteAddr = "[2001:db8:1::5]:43210"
+	require.Equal(t, "198.51.100.1", getSourceIP(req, trustedProxies))
+
+	// Invalid header value.
+	req.Header.Set("X-Real-IP", "foo")
+	require.Equal(t, "2001:db8:1::5", getSourceIP(req, trustedProxies))
+}
+
+// Test parsing the trusted proxy addresses.
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"192.0.2.1", " 10.0.0.0/8", "", "::1"})
+	require.NoError(t, err)
+	require.Len(t, proxies, 3)
+	require.Equal(t, "192.0.2.1/32", proxies[0].String())
+	require.Equal(t, "10.0.0.0/8", proxies[1].String())
+	require.Equal(t, "::1/128", proxies[2].String())
+
+	_, err = parseTrustedProxies([]string{"foo"})
+	require.Error(t, err)
+
+	_, err = parseTrustedProxies([]string{"192.0.2.0/33"})
+	require.Error(t, err)
+
+	proxies, err = parseTrustedProxies(nil)
+	require.NoError(t, err)
+	require.Empty(t, proxies)
+}
+
+// Test that the changes are recorded in the audit log and that the audit
+// log entries can be fetched and filtered.
+func TestGetAuditEntries(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+
+	user, err := dbmodel.GetUserByID(db, 1)
+	require.NoError(t, err)
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	require.NoError(t, rapi.SessionManager.LoginHandler(ctx, user))
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPut, "http://example.org/api/settings", nil)
+	req.RemoteAddr = "192.0.2.1:43210"
+
+	current, err := rapi.getSettings()
+	require.NoError(t, err)
+	current.Bind9StatsPullerInterval = 10
+	current.GrafanaURL = "http://grafana.example.org"
+	rsp := rapi.UpdateSettings(ctx, settings.UpdateSettingsParams{
+		HTTPRequest: req,
+		Settings:    current,
+	})
+	require.IsType(t, &settings.UpdateSettingsOK{}, rsp)
+
+	// Record another change without a request.
+	rapi.recordAudit(nil, dbmodel.AuditActionDelete, dbmodel.AuditObjectHost, "7", "foo", &models.Host{Hostname: "foo"}, nil)
+
+	rsp = rapi.GetAuditEntries(ctx, events.GetAuditEntriesParams{})
+	require.IsType(t, &events.GetAuditEntriesOK{}, rsp)
+	entries := rsp.(*events.GetAuditEntriesOK).Payload
+	require.EqualValues(t, 2, entries.Total)
+	require.Len(t, entries.Items, 2)
+
+	// The most recent entry comes first.
+	require.Equal(t, dbmodel.AuditObjectHost, entries.Items[0].ObjectType)
+	require.Equal(t, "unknown", entries.Items[0].UserIdentity)
+	require.Zero(t, entries.Items[0].UserID)
+
+	entry := entries.Items[1]
+	require.EqualValues(t, 1, entry.UserID)
+	require.Equal(t, user.Identity(), entry.UserIdentity)
+	require.Equal(t, "192.0.2.1", entry.SourceIP)
+	require.Equal(t, dbmodel.AuditActionUpdate, entry.Action)
+	require.Equal(t, dbmodel.AuditObjectSettings, entry.ObjectType)
+	require.Len(t, entry.Diff, 2)
+	require.Equal(t, "bind9_stats_puller_interval", entry.Diff[0].Field)
+	require.EqualValues(t, 60, entry.Diff[0].Before)
+	require.EqualValues(t, 10, entry.Diff[0].After)
+	require.Equal(t, "grafana_url", entry.Diff[1].Field)
+	require.Nil(t, entry.Diff[1].Before)
+	require.Equal(t, "http://grafana.example.org", entry.Diff[1].After)
+
+	// Filter by user.
+	rsp = rapi.GetAuditEntries(ctx, events.GetAuditEntriesParams{
+		User: storkutil.Ptr(int64(1)),
+	})
+	require.IsType(t, &events.GetAuditEntriesOK{}, rsp)
+	entries = rsp.(*events.GetAuditEntriesOK).Payload
+	require.EqualValues(t, 1, entries.Total)
+	require.Equal(t, dbmodel.AuditObjectSettings, entries.Items[0].ObjectType)
+
+	// Filter by object.
+	rsp = rapi.GetAuditEntries(ctx, events.GetAuditEntriesParams{
+		ObjectType: storkutil.Ptr(dbmodel.AuditObjectHost),
+		ObjectID:   storkutil.Ptr("7"),
+	})
+	require.IsType(t, &events.GetAuditEntriesOK{}, rsp)
+	entries = rsp.(*events.GetAuditEntriesOK).Payload
+	require.EqualValues(t, 1, entries.Total)
+	require.Equal(t, "foo", entries.Items[0].ObjectName)
+	require.Len(t, entries.Items[0].Diff, 1)
+	require.Equal(t, "hostname", entries.Items[0].Diff[0].Field)
+
+	// Filter by time.
+	to := entries.Items[0].CreatedAt
+	rsp = rapi.GetAuditEntries(ctx, events.GetAuditEntriesParams{
+		To: &to,
+	})
+	require.IsType(t, &events.GetAuditEntriesOK{}, rsp)
+	entries = rsp.(*events.GetAuditEntriesOK).Payload
+	require.EqualValues(t, 1, entries.Total)
+	require.Equal(t, dbmodel.AuditObjectSettings, entries.Items[0].ObjectType)
+}
@@ -237,6 +237,16 @@ func convertConfigCheckerMetadataToRestAPI(metadata []*configreview.CheckerMetad
 	return payload
 }
 
+// Returns the states of the config checkers by their names. It is used
+// to record the changes of the checker states in the audit log.
+func getConfigCheckerStates(metadata []*configreview.CheckerMetadata) map[string]configreview.CheckerState {
+	states := make(map[string]configreview.CheckerState, len(metadata))
+	for _, m := range metadata {
+		states[m.Name] = m.State
+	}
+	return states
+}
+
 // Converts the config checker state from RestAPI to the internal type.
 func convertConfigCheckerStateFromRestAPI(state models.ConfigCheckerState) (configreview.CheckerState, bool) {
 	switch state {
@@ -325,6 +335,16 @@ func (r *RestAPI) PutDaemonConfigCheckerPreferences(ctx context.Context, params
 		return rsp
 	}
 
+	beforeMetadata, err := r.ReviewDispatcher.GetCheckersMetadata(daemon)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get checkers metadata for daemon (ID: %d, Name: %s)", daemon.ID, daemon.Name)
+		rsp := services.NewPutDaemonConfigCheckerPreferencesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
 	var newOrUpdatedPreferences []*dbmodel.ConfigCheckerPreference
 	var deletedPreferences []*dbmodel.ConfigCheckerPreference
 	for _, change := range params.Changes.Items {
@@ -387,6 +407,9 @@ func (r *RestAPI) PutDaemonConfigCheckerPreferences(ctx context.Context, params
 		return rsp
 	}
 
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectConfigCheckerSettings,
+		fmt.Sprint(daemon.ID), daemon.Name, getConfigCheckerStates(beforeMetadata), getConfigCheckerStates(metadata))
+
 	payload := convertConfigCheckerMetadataToRestAPI(metadata)
 
 	rsp := services.NewPutDaemonConfigCheckerPreferencesOK().WithPayload(payload)
@@ -396,6 +419,16 @@ func (r *RestAPI) PutDaemonConfigCheckerPreferences(ctx context.Context, params
 // Modifies the global checker preferences. The changes are persistent.
 // It returns a list of actual global config checker metadata.
 func (r *RestAPI) PutGlobalConfigCheckerPreferences(ctx context.Context, params services.PutGlobalConfigCheckerPreferencesParams) middleware.Responder {
+	beforeMetadata, err := r.ReviewDispatcher.GetCheckersMetadata(nil)
+	if err != nil {
+		log.Error(err)
+		msg := "Cannot get global checkers metadata for daemon"
+		rsp := services.NewPutDaemonConfigCheckerPreferencesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
 	var newOrUpdatedPreferences []*dbmodel.ConfigCheckerPreference
 	var deletedPreferences []*dbmodel.ConfigCheckerPreference
 
@@ -426,7 +459,7 @@ func (r *RestAPI) PutGlobalConfigCheckerPreferences(ctx context.Context, params
 		}
 	}
 
-	err := dbmodel.CommitCheckerPreferences(r.DB, newOrUpdatedPreferences, deletedPreferences)
+	err = dbmodel.CommitCheckerPreferences(r.DB, newOrUpdatedPreferences, deletedPreferences)
 	if err != nil {
 		log.Error(err)
 		msg := "Cannot commit the config checker changes into DB"
@@ -446,6 +479,9 @@ func (r *RestAPI) PutGlobalConfigCheckerPreferences(ctx context.Context, params
 		return rsp
 	}
 
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectConfigCheckerSettings,
+		"", "global", getConfigCheckerStates(beforeMetadata), getConfigCheckerStates(metadata))
+
 	payload := convertConfigCheckerMetadataToRestAPI(metadata)
 
 	rsp := services.NewGetDaemonConfigCheckersOK().WithPayload(payload)
@@ -0,0 +1,230 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/users"
+)
+
+// Creates new instance of the email subscription model used by REST API
+// from the subscription instance returned from the database.
+func newRestEmailSubscription(s dbmodel.EmailSubscription) *models.EmailSubscription {
+	subscription := &models.EmailSubscription{
+		ID:             s.ID,
+		Level:          int64(s.Level),
+		DigestInterval: int64(s.DigestInterval),
+		CreatedAt:      strfmt.DateTime(s.CreatedAt),
+	}
+	if s.IsDigest() {
+		subscription.LastDigestAt = strfmt.DateTime(s.LastDigestAt)
+	}
+	if s.Filters != nil {
+		subscription.MachineID = s.Filters.MachineID
+		subscription.AppID = s.Filters.AppID
+		subscription.DaemonID = s.Filters.DaemonID
+		subscription.SubnetID = s.Filters.SubnetID
+	}
+	return subscription
+}
+
+// Creates new instance of the email subscription stored in the database
+// from the subscription received over the REST API. It returns an error
+// if the subscription is invalid.
+func newDBEmailSubscription(userID int, s *models.EmailSubscription) (*dbmodel.EmailSubscription, error) {
+	if s.Level < int64(dbmodel.EvInfo) || s.Level > int64(dbmodel.EvError) {
+		return nil, errors.Errorf("invalid event level %d", s.Level)
+	}
+	if s.DigestInterval < 0 {
+		return nil, errors.Errorf("invalid digest interval %d", s.DigestInterval)
+	}
+	subscription := &dbmodel.EmailSubscription{
+		UserID:         userID,
+		Level:          dbmodel.EventLevel(s.Level),
+		DigestInterval: int(s.DigestInterval),
+	}
+	filters := &dbmodel.Relations{
+		MachineID: s.MachineID,
+		AppID:     s.AppID,
+		DaemonID:  s.DaemonID,
+		SubnetID:  s.SubnetID,
+	}
+	if *filters != (dbmodel.Relations{}) {
+		subscription.Filters = filters
+	}
+	return subscription, nil
+}
+
+// Returns the email subscriptions of the user.
+func (r *RestAPI) GetUserEmailSubscriptions(ctx context.Context, params users.GetUserEmailSubscriptionsParams) middleware.Responder {
+	dbSubscriptions, err := dbmodel.GetEmailSubscriptionsByUserID(r.DB, int(params.ID))
+	if err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to get email subscriptions from the database")
+
+		msg := fmt.Sprintf("Failed to get email subscriptions of user with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewGetUserEmailSubscriptionsDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	subscriptions := &models.EmailSubscriptions{
+		Items: []*models.EmailSubscription{},
+		Total: int64(len(dbSubscriptions)),
+	}
+	for _, s := range dbSubscriptions {
+		subscriptions.Items = append(subscriptions.Items, newRestEmailSubscription(s))
+	}
+	return users.NewGetUserEmailSubscriptionsOK().WithPayload(subscriptions)
+}
+
+// Subscribes the user to the event notifications sent by email. The user
+// must have an email address.
+func (r *RestAPI) CreateUserEmailSubscription(ctx context.Context, params users.CreateUserEmailSubscriptionParams) middleware.Responder {
+	if params.Subscription == nil {
+		msg := "Failed to create email subscription: missing subscription"
+		log.WithField("userID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserEmailSubscriptionDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	dbSubscription, err := newDBEmailSubscription(int(params.ID), params.Subscription)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to create email subscription: %s", err)
+		log.WithField("userID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserEmailSubscriptionDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	user, err := dbmodel.GetUserByID(r.DB, int(params.ID))
+	if err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to get user from the database")
+
+		msg := fmt.Sprintf("Failed to get user with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserEmailSubscriptionDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if user == nil {
+		msg := fmt.Sprintf("Failed to find user with ID %d in the database", params.ID)
+		log.WithField("userID", params.ID).Error(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserEmailSubscriptionDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+	if user.Email == "" {
+		msg := fmt.Sprintf("Failed to create email subscription: user %s has no email address", user.Identity())
+		log.WithField("userID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserEmailSubscriptionDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	if err = dbmodel.AddEmailSubscription(r.DB, dbSubscription); err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to create email subscription")
+
+		msg := fmt.Sprintf("Failed to create email subscription for user %s", user.Identity())
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserEmailSubscriptionDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithFields(log.Fields{
+		"userID":         params.ID,
+		"subscriptionID": dbSubscription.ID,
+	}).Info("Created new email subscription")
+
+	rspSubscription := newRestEmailSubscription(*dbSubscription)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionCreate, dbmodel.AuditObjectEmailSubscription, fmt.Sprint(dbSubscription.ID), user.Email,
+		nil, rspSubscription)
+	return users.NewCreateUserEmailSubscriptionOK().WithPayload(rspSubscription)
+}
+
+// Unsubscribes the user by deleting the email subscription from the
+// database.
+func (r *RestAPI) DeleteUserEmailSubscription(ctx context.Context, params users.DeleteUserEmailSubscriptionParams) middleware.Responder {
+	err := dbmodel.DeleteEmailSubscription(r.DB, int(params.ID), params.SubscriptionID)
+	switch {
+	case errors.Is(err, dbmodel.ErrNotExists):
+		msg := fmt.Sprintf("Failed to find email subscription with ID %d of user with ID %d", params.SubscriptionID, params.ID)
+		log.WithField("userID", params.ID).Error(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteUserEmailSubscriptionDefault(http.StatusNotFound).WithPayload(&rspErr)
+	case err != nil:
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to delete email subscription")
+
+		msg := fmt.Sprintf("Failed to delete email subscription with ID %d", params.SubscriptionID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteUserEmailSubscriptionDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithFields(log.Fields{
+		"userID":         params.ID,
+		"subscriptionID": params.SubscriptionID,
+	}).Info("Deleted email subscription")
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectEmailSubscription, fmt.Sprint(params.SubscriptionID), "",
+		nil, nil)
+
+	return users.NewDeleteUserEmailSubscriptionOK()
+}
+
+// Returns the log of the emails sent to the user.
+func (r *RestAPI) GetUserEmailDeliveries(ctx context.Context, params users.GetUserEmailDeliveriesParams) middleware.Responder {
+	var start int64
+	if params.Start != nil {
+		start = *params.Start
+	}
+
+	var limit int64 = 10
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	dbDeliveries, total, err := dbmodel.GetEmailDeliveriesByPage(r.DB, int(params.ID), start, limit)
+	if err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to get email deliveries from the database")
+
+		msg := fmt.Sprintf("Failed to get email deliveries to user with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewGetUserEmailDeliveriesDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	deliveries := &models.EmailDeliveries{
+		Items: []*models.EmailDelivery{},
+		Total: total,
+	}
+	for _, d := range dbDeliveries {
+		deliveries.Items = append(deliveries.Items, &models.EmailDelivery{
+			ID:             d.ID,
+			SubscriptionID: d.SubscriptionID,
+			CreatedAt:      strfmt.DateTime(d.CreatedAt),
+			Recipient:      d.Recipient,
+			EventCount:     int64(d.EventCount),
+			Success:        d.Success,
+			Error:          d.Error,
+		})
+	}
+	return users.NewGetUserEmailDeliveriesOK().WithPayload(deliveries)
+}
@@ -0,0 +1,128 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/users"
+)
+
+// Test that the email subscriptions can be created, listed and deleted.
+func TestCreateGetDeleteUserEmailSubscription(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	ctx := context.Background()
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	user := &dbmodel.SystemUser{
+		Login:    "operator",
+		Email:    "operator@example.org",
+		Lastname: "Operator",
+		Name:     "Otto",
+	}
+	_, err = dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	rsp := rapi.CreateUserEmailSubscription(ctx, users.CreateUserEmailSubscriptionParams{
+		ID: int64(user.ID),
+		Subscription: &models.EmailSubscription{
+			Level:          int64(dbmodel.EvWarning),
+			MachineID:      1,
+			DigestInterval: 60,
+		},
+	})
+	require.IsType(t, &users.CreateUserEmailSubscriptionOK{}, rsp)
+	created := rsp.(*users.CreateUserEmailSubscriptionOK).Payload
+	require.NotZero(t, created.ID)
+	require.EqualValues(t, dbmodel.EvWarning, created.Level)
+	require.EqualValues(t, 1, created.MachineID)
+	require.EqualValues(t, 60, created.DigestInterval)
+
+	// Invalid level.
+	rsp = rapi.CreateUserEmailSubscription(ctx, users.CreateUserEmailSubscriptionParams{
+		ID:           int64(user.ID),
+		Subscription: &models.EmailSubscription{Level: 3},
+	})
+	require.IsType(t, &users.CreateUserEmailSubscriptionDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*users.CreateUserEmailSubscriptionDefault)))
+
+	// Invalid digest interval.
+	rsp = rapi.CreateUserEmailSubscription(ctx, users.CreateUserEmailSubscriptionParams{
+		ID:           int64(user.ID),
+		Subscription: &models.EmailSubscription{DigestInterval: -1},
+	})
+	require.IsType(t, &users.CreateUserEmailSubscriptionDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*users.CreateUserEmailSubscriptionDefault)))
+
+	// Non-existing user.
+	rsp = rapi.CreateUserEmailSubscription(ctx, users.CreateUserEmailSubscriptionParams{
+		ID:           int64(user.ID + 100),
+		Subscription: &models.EmailSubscription{},
+	})
+	require.IsType(t, &users.CreateUserEmailSubscriptionDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*users.CreateUserEmailSubscriptionDefault)))
+
+	// The user without the email address cannot subscribe.
+	noEmail := &dbmodel.SystemUser{
+		Login:    "noemail",
+		Lastname: "Email",
+		Name:     "No",
+	}
+	_, err = dbmodel.CreateUserWithPassword(db, noEmail, "pass")
+	require.NoError(t, err)
+	rsp = rapi.CreateUserEmailSubscription(ctx, users.CreateUserEmailSubscriptionParams{
+		ID:           int64(noEmail.ID),
+		Subscription: &models.EmailSubscription{},
+	})
+	require.IsType(t, &users.CreateUserEmailSubscriptionDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*users.CreateUserEmailSubscriptionDefault)))
+
+	rsp = rapi.GetUserEmailSubscriptions(ctx, users.GetUserEmailSubscriptionsParams{ID: int64(user.ID)})
+	require.IsType(t, &users.GetUserEmailSubscriptionsOK{}, rsp)
+	subscriptions := rsp.(*users.GetUserEmailSubscriptionsOK).Payload
+	require.EqualValues(t, 1, subscriptions.Total)
+	require.Equal(t, created.ID, subscriptions.Items[0].ID)
+	require.EqualValues(t, 1, subscriptions.Items[0].MachineID)
+
+	// Get the delivery log.
+	require.NoError(t, dbmodel.AddEmailDelivery(db, &dbmodel.EmailDelivery{
+		SubscriptionID: created.ID,
+		UserID:         user.ID,
+		Recipient:      user.Email,
+		EventCount:     5,
+		Error:          "connection refused",
+	}))
+	rsp = rapi.GetUserEmailDeliveries(ctx, users.GetUserEmailDeliveriesParams{ID: int64(user.ID)})
+	require.IsType(t, &users.GetUserEmailDeliveriesOK{}, rsp)
+	deliveries := rsp.(*users.GetUserEmailDeliveriesOK).Payload
+	require.EqualValues(t, 1, deliveries.Total)
+	require.Equal(t, created.ID, deliveries.Items[0].SubscriptionID)
+	require.EqualValues(t, 5, deliveries.Items[0].EventCount)
+	require.False(t, deliveries.Items[0].Success)
+	require.Equal(t, "connection refused", deliveries.Items[0].Error)
+
+	// The subscription of the other user cannot be deleted.
+	rsp = rapi.DeleteUserEmailSubscription(ctx, users.DeleteUserEmailSubscriptionParams{
+		ID:             int64(noEmail.ID),
+		SubscriptionID: created.ID,
+	})
+	require.IsType(t, &users.DeleteUserEmailSubscriptionDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*users.DeleteUserEmailSubscriptionDefault)))
+
+	rsp = rapi.DeleteUserEmailSubscription(ctx, users.DeleteUserEmailSubscriptionParams{
+		ID:             int64(user.ID),
+		SubscriptionID: created.ID,
+	})
+	require.IsType(t, &users.DeleteUserEmailSubscriptionOK{}, rsp)
+
+	rsp = rapi.GetUserEmailSubscriptions(ctx, users.GetUserEmailSubscriptionsParams{ID: int64(user.ID)})
+	require.IsType(t, &users.GetUserEmailSubscriptionsOK{}, rsp)
+	require.Zero(t, rsp.(*users.GetUserEmailSubscriptionsOK).Payload.Total)
+}
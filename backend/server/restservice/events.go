@@ -27,11 +27,12 @@ func (r *RestAPI) getEvents(offset, limit int64, level dbmodel.EventLevel, daemo
 	// Convert events fetched from the database to REST.
 	for _, dbEvent := range dbEvents {
 		event := models.Event{
-			ID:        dbEvent.ID,
-			CreatedAt: strfmt.DateTime(dbEvent.CreatedAt),
-			Text:      dbEvent.Text,
-			Level:     int64(dbEvent.Level),
-			Details:   dbEvent.Details,
+			ID:                  dbEvent.ID,
+			CreatedAt:           strfmt.DateTime(dbEvent.CreatedAt),
+			Text:                dbEvent.Text,
+			Level:               int64(dbEvent.Level),
+			Details:             dbEvent.Details,
+			MaintenanceWindowID: dbEvent.MaintenanceWindowID,
 		}
 		events.Items = append(events.Items, &event)
 	}
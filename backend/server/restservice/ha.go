@@ -0,0 +1,172 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/apps/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+)
+
+// Performs the action on the HA service, e.g. starts the maintenance of
+// one of the servers. The action is validated against the current state
+// of the HA servers and sent to the appropriate server. An event is
+// recorded for each action sent to the server.
+func (r *RestAPI) PutHAServiceAction(ctx context.Context, params services.PutHAServiceActionParams) middleware.Responder {
+	if params.Action == nil || params.Action.Action == nil || params.Action.DaemonID == nil {
+		msg := "Missing HA action parameters"
+		log.Error(msg)
+		rsp := services.NewPutHAServiceActionDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	dbService, err := dbmodel.GetDetailedService(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get service with ID %d from the database", params.ID)
+		rsp := services.NewPutHAServiceActionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbService == nil {
+		msg := fmt.Sprintf("Cannot find service with ID %d", params.ID)
+		rsp := services.NewPutHAServiceActionDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	action := &kea.HAAction{
+		Name:      *params.Action.Action,
+		DaemonID:  *params.Action.DaemonID,
+		Scopes:    params.Action.Scopes,
+		MaxPeriod: params.Action.MaxPeriod,
+	}
+	command, err := kea.PrepareHAActionCommand(dbService, action)
+	if err != nil {
+		log.Warn(err)
+		msg := fmt.Sprintf("Cannot perform %s action: %s", action.Name, err)
+		rsp := services.NewPutHAServiceActionDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	dbApp, err := dbmodel.GetAppByID(r.DB, command.Daemon.AppID)
+	if err != nil || dbApp == nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get app with ID %d from the database", command.Daemon.AppID)
+		rsp := services.NewPutHAServiceActionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	_, dbUser := r.SessionManager.Logged(ctx)
+	daemon := dbService.GetDaemonByID(action.DaemonID)
+	commandName := command.Command.Command
+
+	text, err := kea.SendHAActionCommand(ctx, r.Agents, dbApp, command)
+	if err != nil {
+		log.Error(err)
+		r.EventCenter.AddWarningEvent(fmt.Sprintf("{user} failed to perform HA %s action for {daemon} in service %d", action.Name, dbService.ID),
+			dbUser, daemon, err.Error())
+		msg := fmt.Sprintf("Failed to send %s command to the Kea server: %s", commandName, err)
+		rsp := services.NewPutHAServiceActionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	r.EventCenter.AddInfoEvent(fmt.Sprintf("{user} performed HA %s action for {daemon} in service %d", action.Name, dbService.ID),
+		dbUser, daemon, text)
+
+	rsp := services.NewPutHAServiceActionOK().WithPayload(&models.HAServiceActionResult{
+		DaemonID: command.Daemon.ID,
+		Command:  commandName,
+		Text:     text,
+	})
+	return rsp
+}
+
+// Converts the HA state transition to the format used in REST API.
+func haStateTransitionToRestAPI(dbTransition *dbmodel.HAStateTransition) *models.HAStateTransition {
+	transition := &models.HAStateTransition{
+		ID:                     dbTransition.ID,
+		DaemonID:               dbTransition.DaemonID,
+		ObservedAt:             strfmt.DateTime(dbTransition.ObservedAt),
+		PreviousState:          dbTransition.PreviousState,
+		State:                  dbTransition.State,
+		Failover:               dbTransition.IsFailover(),
+		PartnerCommInterrupted: dbTransition.PartnerCommInterrupted,
+		PartnerUnackedClients:  dbTransition.PartnerUnackedClients,
+	}
+	if dbTransition.Daemon != nil && dbTransition.Daemon.App != nil {
+		transition.AppID = dbTransition.Daemon.App.ID
+		transition.AppName = dbTransition.Daemon.App.Name
+		if dbTransition.Daemon.App.Machine != nil {
+			transition.MachineAddress = dbTransition.Daemon.App.Machine.Address
+		}
+	}
+	return transition
+}
+
+// Returns the HA state transitions observed for the servers of the HA
+// service from the newest to the oldest.
+func (r *RestAPI) GetHAServiceTimeline(ctx context.Context, params services.GetHAServiceTimelineParams) middleware.Responder {
+	var start int64
+	if params.Start != nil {
+		start = *params.Start
+	}
+
+	var limit int64 = 10
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	dbService, err := dbmodel.GetDetailedService(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get service with ID %d from the database", params.ID)
+		rsp := services.NewGetHAServiceTimelineDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbService == nil || dbService.HAService == nil {
+		msg := fmt.Sprintf("Cannot find HA service with ID %d", params.ID)
+		rsp := services.NewGetHAServiceTimelineDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	dbTransitions, total, err := dbmodel.GetHAStateTransitionsByPage(r.DB, params.ID, start, limit)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get HA state transitions of service with ID %d from the database", params.ID)
+		rsp := services.NewGetHAServiceTimelineDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	transitions := &models.HAStateTransitions{
+		Items: []*models.HAStateTransition{},
+		Total: total,
+	}
+	for i := range dbTransitions {
+		transitions.Items = append(transitions.Items, haStateTransitionToRestAPI(&dbTransitions[i]))
+	}
+	rsp := services.NewGetHAServiceTimelineOK().WithPayload(transitions)
+	return rsp
+}
@@ -0,0 +1,272 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/require"
+	keactrl "isc.org/stork/appctrl/kea"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Adds a Kea app with the DHCPv4 daemon configured as the specified HA
+// peer of the load-balancing relationship between server1 and server2.
+func addHATestKeaApp(t *testing.T, db *pg.DB, address, thisServerName string) *dbmodel.App {
+	m := &dbmodel.Machine{
+		Address:   address,
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	daemon := dbmodel.NewKeaDaemon("dhcp4", true)
+	err = daemon.SetConfigFromJSON(fmt.Sprintf(`{
+		"Dhcp4": {
+			"hooks-libraries": [
+				{
+					"library": "libdhcp_ha.so",
+					"parameters": {
+						"high-availability": [{
+							"this-server-name": "%s",
+							"mode": "load-balancing",
+							"peers": [
+								{ "name": "server1", "url": "http://192.0.2.1:8000", "role": "primary" },
+								{ "name": "server2", "url": "http://192.0.2.2:8000", "role": "secondary" }
+							]
+						}]
+					}
+				}
+			]
+		}
+	}`, thisServerName))
+	require.NoError(t, err)
+
+	var accessPoints []*dbmodel.AccessPoint
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, address, "", 8000, false)
+	app := &dbmodel.App{
+		MachineID:    m.ID,
+		Type:         dbmodel.AppTypeKea,
+		Active:       true,
+		AccessPoints: accessPoints,
+		Daemons:      []*dbmodel.Daemon{daemon},
+	}
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+	return app
+}
+
+// Adds the HA service between the daemons of the apps in the specified states.
+func addHATestService(t *testing.T, db *pg.DB, primaryApp, secondaryApp *dbmodel.App, primaryState, secondaryState dbmodel.HAState) *dbmodel.Service {
+	service := &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			ServiceType: "ha_dhcp",
+		},
+		HAService: &dbmodel.BaseHAService{
+			HAType:             dbmodel.HATypeDhcp4,
+			HAMode:             dbmodel.HAModeLoadBalancing,
+			PrimaryID:          primaryApp.Daemons[0].ID,
+			SecondaryID:        secondaryApp.Daemons[0].ID,
+			PrimaryLastState:   primaryState,
+			SecondaryLastState: secondaryState,
+		},
+	}
+	err := dbmodel.AddService(db, service)
+	require.NoError(t, err)
+	for _, app := range []*dbmodel.App{primaryApp, secondaryApp} {
+		err = dbmodel.AddDaemonToService(db, service.ID, app.Daemons[0])
+		require.NoError(t, err)
+	}
+	return service
+}
+
+// Test that the HA maintenance is started by sending the command to the
+// partner of the server to be maintained.
+func TestPutHAServiceActionMaintenanceStart(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	primaryApp := addHATestKeaApp(t, db, "192.0.2.1", "server1")
+	secondaryApp := addHATestKeaApp(t, db, "192.0.2.2", "server2")
+	service := addHATestService(t, db, primaryApp, secondaryApp, dbmodel.HAStateLoadBalancing, dbmodel.HAStateLoadBalancing)
+
+	fa := agentcommtest.NewFakeAgents(func(callNo int, cmdResponses []interface{}) {
+		json := []byte(`[{"result": 0, "text": "Server is now in the partner-in-maintenance state."}]`)
+		command := keactrl.NewCommand("ha-maintenance-start", []string{"dhcp4"}, nil)
+		_ = keactrl.UnmarshalResponseList(command, json, cmdResponses[0])
+	}, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(dbSettings, db, fa, fec)
+	require.NoError(t, err)
+
+	user, err := dbmodel.GetUserByID(rapi.DB, 1)
+	require.NoError(t, err)
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	action := "maintenance-start"
+	params := services.PutHAServiceActionParams{
+		ID: service.ID,
+		Action: &models.HAServiceAction{
+			Action:   &action,
+			DaemonID: &primaryApp.Daemons[0].ID,
+		},
+	}
+	rsp := rapi.PutHAServiceAction(ctx, params)
+	require.IsType(t, &services.PutHAServiceActionOK{}, rsp)
+	result := rsp.(*services.PutHAServiceActionOK).Payload
+	require.Equal(t, secondaryApp.Daemons[0].ID, result.DaemonID)
+	require.Equal(t, "ha-maintenance-start", result.Command)
+	require.Equal(t, "Server is now in the partner-in-maintenance state.", result.Text)
+
+	// The command should be sent to the secondary server.
+	require.Len(t, fa.RecordedCommands, 1)
+	require.Equal(t, "ha-maintenance-start", fa.RecordedCommands[0].GetCommand())
+	require.Equal(t, []string{"dhcp4"}, fa.RecordedCommands[0].GetDaemonsList())
+	require.Len(t, fa.RecordedURLs, 1)
+	require.Contains(t, fa.RecordedURLs[0], "192.0.2.2")
+
+	// The event should be recorded.
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "performed HA maintenance-start action")
+	require.EqualValues(t, primaryApp.Daemons[0].ID, fec.Events[0].Relations.DaemonID)
+}
+
+// Test that the HA action is rejected when the servers are not in the
+// appropriate states.
+func TestPutHAServiceActionUnsafe(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	primaryApp := addHATestKeaApp(t, db, "192.0.2.1", "server1")
+	secondaryApp := addHATestKeaApp(t, db, "192.0.2.2", "server2")
+	service := addHATestService(t, db, primaryApp, secondaryApp, dbmodel.HAStateLoadBalancing, dbmodel.HAStateLoadBalancing)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(dbSettings, db, fa, fec)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// The maintenance is not in progress.
+	action := "maintenance-cancel"
+	rsp := rapi.PutHAServiceAction(ctx, services.PutHAServiceActionParams{
+		ID: service.ID,
+		Action: &models.HAServiceAction{
+			Action:   &action,
+			DaemonID: &primaryApp.Daemons[0].ID,
+		},
+	})
+	require.IsType(t, &services.PutHAServiceActionDefault{}, rsp)
+	defaultRsp := rsp.(*services.PutHAServiceActionDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+
+	// The secondary serves its scope.
+	action = "scopes"
+	rsp = rapi.PutHAServiceAction(ctx, services.PutHAServiceActionParams{
+		ID: service.ID,
+		Action: &models.HAServiceAction{
+			Action:   &action,
+			DaemonID: &primaryApp.Daemons[0].ID,
+			Scopes:   []string{"server1", "server2"},
+		},
+	})
+	require.IsType(t, &services.PutHAServiceActionDefault{}, rsp)
+	defaultRsp = rsp.(*services.PutHAServiceActionDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+
+	// Non-existing service.
+	rsp = rapi.PutHAServiceAction(ctx, services.PutHAServiceActionParams{
+		ID: service.ID + 1,
+		Action: &models.HAServiceAction{
+			Action:   &action,
+			DaemonID: &primaryApp.Daemons[0].ID,
+		},
+	})
+	require.IsType(t, &services.PutHAServiceActionDefault{}, rsp)
+	defaultRsp = rsp.(*services.PutHAServiceActionDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+
+	// Missing parameters.
+	rsp = rapi.PutHAServiceAction(ctx, services.PutHAServiceActionParams{
+		ID: service.ID,
+	})
+	require.IsType(t, &services.PutHAServiceActionDefault{}, rsp)
+	defaultRsp = rsp.(*services.PutHAServiceActionDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+
+	// No commands should be sent and no events recorded.
+	require.Empty(t, fa.RecordedCommands)
+	require.Empty(t, fec.Events)
+}
+
+// Test that the HA state transitions of the service are returned.
+func TestGetHAServiceTimeline(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	primaryApp := addHATestKeaApp(t, db, "192.0.2.1", "server1")
+	secondaryApp := addHATestKeaApp(t, db, "192.0.2.2", "server2")
+	service := addHATestService(t, db, primaryApp, secondaryApp, dbmodel.HAStatePartnerDown, dbmodel.HAStateUnavailable)
+
+	commInterrupted := true
+	for _, transition := range []*dbmodel.HAStateTransition{
+		{DaemonID: primaryApp.Daemons[0].ID, State: dbmodel.HAStateLoadBalancing},
+		{DaemonID: secondaryApp.Daemons[0].ID, State: dbmodel.HAStateLoadBalancing},
+		{DaemonID: primaryApp.Daemons[0].ID, State: dbmodel.HAStateLoadBalancing, PartnerCommInterrupted: &commInterrupted, PartnerUnackedClients: 3},
+		{DaemonID: secondaryApp.Daemons[0].ID, State: dbmodel.HAStateUnavailable},
+		{DaemonID: primaryApp.Daemons[0].ID, State: dbmodel.HAStatePartnerDown, PartnerCommInterrupted: &commInterrupted},
+	} {
+		transition.ServiceID = service.ID
+		_, err := dbmodel.AddHAStateTransitionIfChanged(db, transition)
+		require.NoError(t, err)
+	}
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	limit := int64(2)
+	rsp := rapi.GetHAServiceTimeline(ctx, services.GetHAServiceTimelineParams{
+		ID:    service.ID,
+		Limit: &limit,
+	})
+	require.IsType(t, &services.GetHAServiceTimelineOK{}, rsp)
+	transitions := rsp.(*services.GetHAServiceTimelineOK).Payload
+	require.EqualValues(t, 5, transitions.Total)
+	require.Len(t, transitions.Items, 2)
+
+	failover := transitions.Items[0]
+	require.Equal(t, primaryApp.Daemons[0].ID, failover.DaemonID)
+	require.Equal(t, primaryApp.ID, failover.AppID)
+	require.Equal(t, "192.0.2.1", failover.MachineAddress)
+	require.Equal(t, dbmodel.HAStatePartnerDown, failover.State)
+	require.Equal(t, dbmodel.HAStateLoadBalancing, failover.PreviousState)
+	require.True(t, failover.Failover)
+	require.NotNil(t, failover.PartnerCommInterrupted)
+	require.True(t, *failover.PartnerCommInterrupted)
+	require.Zero(t, failover.PartnerUnackedClients)
+
+	require.Equal(t, secondaryApp.Daemons[0].ID, transitions.Items[1].DaemonID)
+	require.Equal(t, dbmodel.HAStateUnavailable, transitions.Items[1].State)
+	require.False(t, transitions.Items[1].Failover)
+	require.Nil(t, transitions.Items[1].PartnerCommInterrupted)
+
+	// The non-existing service.
+	rsp = rapi.GetHAServiceTimeline(ctx, services.GetHAServiceTimelineParams{
+		ID: service.ID + 1,
+	})
+	require.IsType(t, &services.GetHAServiceTimelineDefault{}, rsp)
+	defaultRsp := rsp.(*services.GetHAServiceTimelineDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+}
@@ -74,6 +74,27 @@ func (r *RestAPI) convertFromHost(dbHost *dbmodel.Host) *models.Host {
 	return host
 }
 
+// Returns the host ID recorded in the audit log. The new hosts have no
+// ID until they are fetched from the Kea servers.
+func getAuditHostID(id int64) string {
+	if id == 0 {
+		return ""
+	}
+	return fmt.Sprint(id)
+}
+
+// Returns the host name recorded in the audit log. It is the hostname or
+// the first host identifier if the hostname is not specified.
+func getAuditHostName(host *models.Host) string {
+	if host.Hostname != "" {
+		return host.Hostname
+	}
+	if len(host.HostIdentifiers) > 0 {
+		return fmt.Sprintf("%s=%s", host.HostIdentifiers[0].IDType, host.HostIdentifiers[0].IDHexValue)
+	}
+	return ""
+}
+
 // Convert host reservation from the format used in REST API to a
 // database host representation.
 func (r *RestAPI) convertToHost(restHost *models.Host) (*dbmodel.Host, error) {
@@ -359,7 +380,7 @@ func (r *RestAPI) CreateHostBegin(ctx context.Context, params dhcp.CreateHostBeg
 // returns the HTTP error code if an error occurs or 0 when there is no error.
 // In addition it returns an error string to be included in the HTTP response
 // or an empty string if there is no error.
-func (r *RestAPI) commonCreateOrUpdateHostSubmit(ctx context.Context, transactionID int64, restHost *models.Host, applyFunc func(context.Context, *dbmodel.Host) (context.Context, error)) (int, string) {
+func (r *RestAPI) commonCreateOrUpdateHostSubmit(ctx context.Context, req *http.Request, transactionID int64, restHost *models.Host, applyFunc func(context.Context, *dbmodel.Host) (context.Context, error)) (int, string) {
 	// Make sure that the host information is present.
 	if restHost == nil {
 		msg := "host information not specified"
@@ -400,6 +421,19 @@ func (r *RestAPI) commonCreateOrUpdateHostSubmit(ctx context.Context, transactio
 		log.Error(err)
 		return http.StatusInternalServerError, msg
 	}
+	// Remember the current state of the updated host for the audit log.
+	var before *models.Host
+	if host.ID != 0 {
+		dbHost, err := dbmodel.GetHost(r.DB, host.ID)
+		if err != nil {
+			msg := fmt.Sprintf("problem with fetching host reservation with ID %d from db", host.ID)
+			log.Error(err)
+			return http.StatusInternalServerError, msg
+		}
+		if dbHost != nil {
+			before = r.convertFromHost(dbHost)
+		}
+	}
 	// Apply the host information (create Kea commands).
 	cctx, err = applyFunc(cctx, host)
 	if err != nil {
@@ -416,12 +450,19 @@ func (r *RestAPI) commonCreateOrUpdateHostSubmit(ctx context.Context, transactio
 	}
 	// Everything ok. Cleanup and send OK to the client.
 	r.ConfigManager.Done(cctx)
+
+	action := dbmodel.AuditActionCreate
+	if before != nil {
+		action = dbmodel.AuditActionUpdate
+	}
+	after := r.convertFromHost(host)
+	r.recordAudit(req, action, dbmodel.AuditObjectHost, getAuditHostID(host.ID), getAuditHostName(after), before, after)
 	return 0, ""
 }
 
 // Implements the POST call to apply and commit host reservation (hosts/new/transaction/{id}/submit).
 func (r *RestAPI) CreateHostSubmit(ctx context.Context, params dhcp.CreateHostSubmitParams) middleware.Responder {
-	if code, msg := r.commonCreateOrUpdateHostSubmit(ctx, params.ID, params.Host, r.ConfigManager.GetKeaModule().ApplyHostAdd); code != 0 {
+	if code, msg := r.commonCreateOrUpdateHostSubmit(ctx, params.HTTPRequest, params.ID, params.Host, r.ConfigManager.GetKeaModule().ApplyHostAdd); code != 0 {
 		// Error case.
 		rsp := dhcp.NewCreateHostSubmitDefault(code).WithPayload(&models.APIError{
 			Message: &msg,
@@ -550,7 +591,7 @@ func (r *RestAPI) UpdateHostBegin(ctx context.Context, params dhcp.UpdateHostBeg
 
 // Implements the POST call and commit an updated host reservation (hosts/{hostId}/transaction/{id}/submit).
 func (r *RestAPI) UpdateHostSubmit(ctx context.Context, params dhcp.UpdateHostSubmitParams) middleware.Responder {
-	if code, msg := r.commonCreateOrUpdateHostSubmit(ctx, params.ID, params.Host, r.ConfigManager.GetKeaModule().ApplyHostUpdate); code != 0 {
+	if code, msg := r.commonCreateOrUpdateHostSubmit(ctx, params.HTTPRequest, params.ID, params.Host, r.ConfigManager.GetKeaModule().ApplyHostUpdate); code != 0 {
 		// Error case.
 		rsp := dhcp.NewUpdateHostSubmitDefault(code).WithPayload(&models.APIError{
 			Message: &msg,
@@ -640,6 +681,9 @@ func (r *RestAPI) DeleteHost(ctx context.Context, params dhcp.DeleteHostParams)
 		})
 		return rsp
 	}
+	before := r.convertFromHost(dbHost)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectHost, getAuditHostID(dbHost.ID), getAuditHostName(before), before, nil)
+
 	// Send OK to the client.
 	rsp := dhcp.NewDeleteHostOK()
 	return rsp
@@ -0,0 +1,189 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+)
+
+// Creates new instance of the machine group model used by REST API from
+// the group instance returned from the database.
+func newRestMachineGroup(g *dbmodel.MachineGroup) *models.MachineGroup {
+	group := &models.MachineGroup{
+		ID:          g.ID,
+		Name:        &g.Name,
+		Description: g.Description,
+		MachineIds:  []int64{},
+	}
+	for _, machine := range g.Machines {
+		group.MachineIds = append(group.MachineIds, machine.ID)
+	}
+	return group
+}
+
+// Converts the machine group received over the REST API to the database
+// model. It returns an error if the group lacks the name.
+func newDBMachineGroup(g *models.MachineGroup) (*dbmodel.MachineGroup, error) {
+	if g == nil || g.Name == nil || len(strings.TrimSpace(*g.Name)) == 0 {
+		return nil, errors.New("missing machine group name")
+	}
+	group := &dbmodel.MachineGroup{
+		Name:        strings.TrimSpace(*g.Name),
+		Description: g.Description,
+	}
+	for _, id := range g.MachineIds {
+		group.Machines = append(group.Machines, &dbmodel.Machine{ID: id})
+	}
+	return group, nil
+}
+
+// Returns all machine groups.
+func (r *RestAPI) GetMachineGroups(ctx context.Context, params services.GetMachineGroupsParams) middleware.Responder {
+	dbGroups, err := dbmodel.GetMachineGroups(r.DB)
+	if err != nil {
+		log.WithError(err).Error("Failed to get machine groups from the database")
+
+		msg := "Failed to get machine groups from the database"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return services.NewGetMachineGroupsDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	groups := &models.MachineGroups{
+		Items: []*models.MachineGroup{},
+		Total: int64(len(dbGroups)),
+	}
+	for i := range dbGroups {
+		groups.Items = append(groups.Items, newRestMachineGroup(&dbGroups[i]))
+	}
+	return services.NewGetMachineGroupsOK().WithPayload(groups)
+}
+
+// Creates a new machine group.
+func (r *RestAPI) CreateMachineGroup(ctx context.Context, params services.CreateMachineGroupParams) middleware.Responder {
+	group, err := newDBMachineGroup(params.Group)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to create machine group: %s", err)
+		log.Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return services.NewCreateMachineGroupDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	conflict, err := dbmodel.AddMachineGroup(r.DB, group)
+	if conflict {
+		msg := fmt.Sprintf("Machine group %s already exists or refers to non-existing machines", group.Name)
+		log.WithError(err).Info(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return services.NewCreateMachineGroupDefault(http.StatusConflict).WithPayload(&rspErr)
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to create machine group")
+
+		msg := fmt.Sprintf("Failed to create machine group %s", group.Name)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return services.NewCreateMachineGroupDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("group", group.Name).Info("Created new machine group")
+
+	rspGroup := newRestMachineGroup(group)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionCreate, dbmodel.AuditObjectMachineGroup, fmt.Sprint(group.ID), group.Name,
+		nil, rspGroup)
+	return services.NewCreateMachineGroupOK().WithPayload(rspGroup)
+}
+
+// Updates the name, description and machines of the machine group.
+func (r *RestAPI) UpdateMachineGroup(ctx context.Context, params services.UpdateMachineGroupParams) middleware.Responder {
+	group, err := newDBMachineGroup(params.Group)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to update machine group: %s", err)
+		log.WithField("machineGroupID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return services.NewUpdateMachineGroupDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+	group.ID = params.ID
+
+	current, err := dbmodel.GetMachineGroupByID(r.DB, params.ID)
+	if err == nil && current != nil {
+		var conflict bool
+		conflict, err = dbmodel.UpdateMachineGroup(r.DB, group)
+		if conflict {
+			msg := fmt.Sprintf("Machine group %s already exists or refers to non-existing machines", group.Name)
+			log.WithError(err).Info(msg)
+			rspErr := models.APIError{
+				Message: &msg,
+			}
+			return services.NewUpdateMachineGroupDefault(http.StatusConflict).WithPayload(&rspErr)
+		}
+	}
+	if (err == nil && current == nil) || errors.Is(err, dbmodel.ErrNotExists) {
+		msg := fmt.Sprintf("Cannot find machine group with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return services.NewUpdateMachineGroupDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+	if err != nil {
+		log.WithField("machineGroupID", params.ID).WithError(err).Error("Failed to update machine group")
+
+		msg := fmt.Sprintf("Failed to update machine group with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return services.NewUpdateMachineGroupDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("group", group.Name).Info("Updated machine group")
+
+	rspGroup := newRestMachineGroup(group)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectMachineGroup, fmt.Sprint(group.ID), group.Name,
+		newRestMachineGroup(current), rspGroup)
+	return services.NewUpdateMachineGroupOK().WithPayload(rspGroup)
+}
+
+// Deletes the machine group and the permissions limited to it.
+func (r *RestAPI) DeleteMachineGroup(ctx context.Context, params services.DeleteMachineGroupParams) middleware.Responder {
+	current, err := dbmodel.GetMachineGroupByID(r.DB, params.ID)
+	if err == nil && current != nil {
+		err = dbmodel.DeleteMachineGroup(r.DB, params.ID)
+	}
+	if (err == nil && current == nil) || errors.Is(err, dbmodel.ErrNotExists) {
+		msg := fmt.Sprintf("Cannot find machine group with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return services.NewDeleteMachineGroupDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+	if err != nil {
+		log.WithField("machineGroupID", params.ID).WithError(err).Error("Failed to delete machine group")
+
+		msg := fmt.Sprintf("Failed to delete machine group with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return services.NewDeleteMachineGroupDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("group", current.Name).Info("Deleted machine group")
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectMachineGroup, fmt.Sprint(params.ID), current.Name,
+		newRestMachineGroup(current), nil)
+	return services.NewDeleteMachineGroupOK()
+}
@@ -0,0 +1,88 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+	storkutil "isc.org/stork/util"
+)
+
+// Test that the machine groups can be created, fetched, updated and
+// deleted over the REST API.
+func TestMachineGroups(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	machine1 := &dbmodel.Machine{Address: "localhost", AgentPort: 8080}
+	require.NoError(t, dbmodel.AddMachine(db, machine1))
+	machine2 := &dbmodel.Machine{Address: "localhost", AgentPort: 8081}
+	require.NoError(t, dbmodel.AddMachine(db, machine2))
+
+	rsp := rapi.CreateMachineGroup(ctx, services.CreateMachineGroupParams{
+		Group: &models.MachineGroup{
+			Name:        storkutil.Ptr("dc1"),
+			Description: "First data center",
+			MachineIds:  []int64{machine1.ID},
+		},
+	})
+	require.IsType(t, &services.CreateMachineGroupOK{}, rsp)
+	created := rsp.(*services.CreateMachineGroupOK).Payload
+	require.NotZero(t, created.ID)
+	require.Equal(t, []int64{machine1.ID}, created.MachineIds)
+
+	// The names are unique.
+	rsp = rapi.CreateMachineGroup(ctx, services.CreateMachineGroupParams{
+		Group: &models.MachineGroup{Name: storkutil.Ptr("dc1")},
+	})
+	require.IsType(t, &services.CreateMachineGroupDefault{}, rsp)
+	require.Equal(t, http.StatusConflict, getStatusCode(*rsp.(*services.CreateMachineGroupDefault)))
+
+	// Missing name.
+	rsp = rapi.CreateMachineGroup(ctx, services.CreateMachineGroupParams{
+		Group: &models.MachineGroup{Name: storkutil.Ptr(" ")},
+	})
+	require.IsType(t, &services.CreateMachineGroupDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*services.CreateMachineGroupDefault)))
+
+	// Replace the machines.
+	rsp = rapi.UpdateMachineGroup(ctx, services.UpdateMachineGroupParams{
+		ID: created.ID,
+		Group: &models.MachineGroup{
+			Name:       storkutil.Ptr("dc1"),
+			MachineIds: []int64{machine1.ID, machine2.ID},
+		},
+	})
+	require.IsType(t, &services.UpdateMachineGroupOK{}, rsp)
+
+	rsp = rapi.GetMachineGroups(ctx, services.GetMachineGroupsParams{})
+	require.IsType(t, &services.GetMachineGroupsOK{}, rsp)
+	groups := rsp.(*services.GetMachineGroupsOK).Payload
+	require.EqualValues(t, 1, groups.Total)
+	require.Equal(t, []int64{machine1.ID, machine2.ID}, groups.Items[0].MachineIds)
+
+	// Non-existing group.
+	rsp = rapi.UpdateMachineGroup(ctx, services.UpdateMachineGroupParams{
+		ID:    created.ID + 100,
+		Group: &models.MachineGroup{Name: storkutil.Ptr("dc2")},
+	})
+	require.IsType(t, &services.UpdateMachineGroupDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*services.UpdateMachineGroupDefault)))
+
+	// Delete the group.
+	rsp = rapi.DeleteMachineGroup(ctx, services.DeleteMachineGroupParams{ID: created.ID})
+	require.IsType(t, &services.DeleteMachineGroupOK{}, rsp)
+
+	rsp = rapi.DeleteMachineGroup(ctx, services.DeleteMachineGroupParams{ID: created.ID})
+	require.IsType(t, &services.DeleteMachineGroupDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*services.DeleteMachineGroupDefault)))
+}
@@ -499,6 +499,27 @@ func (r *RestAPI) PingMachine(ctx context.Context, params services.PingMachinePa
 	return rsp
 }
 
+// Machine fields recorded in the audit log.
+type auditMachine struct {
+	Address    string `json:"address"`
+	AgentPort  int64  `json:"agentPort"`
+	Authorized bool   `json:"authorized"`
+}
+
+// Returns the machine fields recorded in the audit log.
+func newAuditMachine(dbMachine *dbmodel.Machine) *auditMachine {
+	return &auditMachine{
+		Address:    dbMachine.Address,
+		AgentPort:  dbMachine.AgentPort,
+		Authorized: dbMachine.Authorized,
+	}
+}
+
+// Returns the machine name recorded in the audit log.
+func getAuditMachineName(dbMachine *dbmodel.Machine) string {
+	return fmt.Sprintf("%s:%d", dbMachine.Address, dbMachine.AgentPort)
+}
+
 // Get one machine by ID where Stork Agent is running.
 func (r *RestAPI) UpdateMachine(ctx context.Context, params services.UpdateMachineParams) middleware.Responder {
 	if params.Machine == nil || params.Machine.Address == nil {
@@ -570,6 +591,7 @@ func (r *RestAPI) UpdateMachine(ctx context.Context, params services.UpdateMachi
 	}
 
 	// copy fields
+	before := newAuditMachine(dbMachine)
 	dbMachine.Address = addr
 	dbMachine.AgentPort = params.Machine.AgentPort
 	prevAuthorized := dbMachine.Authorized
@@ -584,6 +606,13 @@ func (r *RestAPI) UpdateMachine(ctx context.Context, params services.UpdateMachi
 		return rsp
 	}
 
+	action := dbmodel.AuditActionUpdate
+	if prevAuthorized != dbMachine.Authorized {
+		action = dbmodel.AuditActionAuthorize
+	}
+	r.recordAudit(params.HTTPRequest, action, dbmodel.AuditObjectMachine, fmt.Sprint(dbMachine.ID),
+		getAuditMachineName(dbMachine), before, newAuditMachine(dbMachine))
+
 	// as we just authorized machine so get its state now
 	if !prevAuthorized && dbMachine.Authorized {
 		ctx2, cancel := context.WithTimeout(ctx, 10*time.Second)
@@ -694,6 +723,8 @@ func (r *RestAPI) DeleteMachine(ctx context.Context, params services.DeleteMachi
 	}
 
 	r.EventCenter.AddInfoEvent("removed {machine}", dbMachine)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectMachine, fmt.Sprint(dbMachine.ID),
+		getAuditMachineName(dbMachine), newAuditMachine(dbMachine), nil)
 
 	rsp := services.NewDeleteMachineOK()
 
@@ -724,6 +755,9 @@ func (r *RestAPI) GetMachineDump(ctx context.Context, params services.GetMachine
 		return rsp
 	}
 
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDownload, dbmodel.AuditObjectDump, fmt.Sprint(params.ID),
+		"", nil, nil)
+
 	dispositionHeaderValue := fmt.Sprintf(
 		"attachment; filename=\"stork-machine-%d-dump_%s.tar.gz\"",
 		params.ID,
@@ -1141,7 +1175,9 @@ func getKeaServicesStatus(db *dbops.PgDB, app *dbmodel.App) *models.ServicesStat
 		}
 		ha := s.HAService
 		keaStatus := models.KeaStatus{
-			Daemon: ha.HAType,
+			ServiceID:    s.ID,
+			Daemon:       ha.HAType,
+			Relationship: ha.Relationship,
 		}
 		secondaryRole := "secondary"
 		if ha.HAMode == dbmodel.HAModeHotStandby {
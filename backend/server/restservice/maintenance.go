@@ -0,0 +1,261 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+)
+
+// Creates new instance of the maintenance window model used by REST API
+// from the window instance returned from the database.
+func newRestMaintenanceWindow(w *dbmodel.MaintenanceWindow) *models.MaintenanceWindow {
+	startsAt := strfmt.DateTime(w.StartsAt)
+	endsAt := strfmt.DateTime(w.EndsAt)
+	return &models.MaintenanceWindow{
+		ID:          w.ID,
+		Name:        &w.Name,
+		Description: w.Description,
+		MachineID:   w.MachineID,
+		AppID:       w.AppID,
+		ServiceID:   w.ServiceID,
+		StartsAt:    &startsAt,
+		EndsAt:      &endsAt,
+		Recurrence:  string(w.Recurrence),
+		Downgrade:   w.Downgrade,
+		Active:      w.Active,
+		CreatedAt:   strfmt.DateTime(w.CreatedAt),
+	}
+}
+
+// Converts the maintenance window received over the REST API to the
+// database model and validates it. The current window is nil when the
+// window is created.
+func newDBMaintenanceWindow(w *models.MaintenanceWindow, current *dbmodel.MaintenanceWindow) (*dbmodel.MaintenanceWindow, error) {
+	if w == nil || w.Name == nil || w.StartsAt == nil || w.EndsAt == nil {
+		return nil, errors.New("missing maintenance window name, start or end time")
+	}
+	window := &dbmodel.MaintenanceWindow{
+		Name:        strings.TrimSpace(*w.Name),
+		Description: w.Description,
+		MachineID:   w.MachineID,
+		AppID:       w.AppID,
+		ServiceID:   w.ServiceID,
+		StartsAt:    time.Time(*w.StartsAt).UTC(),
+		EndsAt:      time.Time(*w.EndsAt).UTC(),
+		Recurrence:  dbmodel.MaintenanceRecurrence(w.Recurrence),
+		Downgrade:   w.Downgrade,
+	}
+	if window.Recurrence == "" {
+		window.Recurrence = dbmodel.MaintenanceRecurrenceNone
+	}
+	if current != nil {
+		window.ID = current.ID
+		window.Active = current.Active
+		window.CreatedAt = current.CreatedAt
+	}
+	if err := eventcenter.ValidateMaintenanceWindow(window); err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// Checks if the machine, app or service in the maintenance exists.
+func (r *RestAPI) checkMaintenanceWindowTarget(window *dbmodel.MaintenanceWindow) error {
+	switch {
+	case window.MachineID != 0:
+		machine, err := dbmodel.GetMachineByID(r.DB, window.MachineID)
+		if err != nil {
+			return err
+		}
+		if machine == nil {
+			return errors.Wrapf(dbmodel.ErrNotExists, "machine with ID %d does not exist", window.MachineID)
+		}
+	case window.AppID != 0:
+		app, err := dbmodel.GetAppByID(r.DB, window.AppID)
+		if err != nil {
+			return err
+		}
+		if app == nil {
+			return errors.Wrapf(dbmodel.ErrNotExists, "app with ID %d does not exist", window.AppID)
+		}
+	case window.ServiceID != 0:
+		service, err := dbmodel.GetDetailedService(r.DB, window.ServiceID)
+		if err != nil {
+			return err
+		}
+		if service == nil {
+			return errors.Wrapf(dbmodel.ErrNotExists, "service with ID %d does not exist", window.ServiceID)
+		}
+	}
+	return nil
+}
+
+// Returns all maintenance windows.
+func (r *RestAPI) GetMaintenanceWindows(ctx context.Context, params events.GetMaintenanceWindowsParams) middleware.Responder {
+	dbWindows, err := dbmodel.GetMaintenanceWindows(r.DB)
+	if err != nil {
+		log.WithError(err).Error("Failed to get maintenance windows from the database")
+
+		msg := "Failed to get maintenance windows from the database"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetMaintenanceWindowsDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	windows := &models.MaintenanceWindows{
+		Items: []*models.MaintenanceWindow{},
+		Total: int64(len(dbWindows)),
+	}
+	for i := range dbWindows {
+		windows.Items = append(windows.Items, newRestMaintenanceWindow(&dbWindows[i]))
+	}
+	return events.NewGetMaintenanceWindowsOK().WithPayload(windows)
+}
+
+// Returns the maintenance window with the specified ID.
+func (r *RestAPI) GetMaintenanceWindow(ctx context.Context, params events.GetMaintenanceWindowParams) middleware.Responder {
+	dbWindow, err := dbmodel.GetMaintenanceWindowByID(r.DB, params.ID)
+	if err != nil {
+		log.WithField("maintenanceWindowID", params.ID).WithError(err).Error("Failed to get maintenance window from the database")
+
+		msg := fmt.Sprintf("Failed to get maintenance window with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetMaintenanceWindowDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if dbWindow == nil {
+		msg := fmt.Sprintf("Cannot find maintenance window with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetMaintenanceWindowDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+	return events.NewGetMaintenanceWindowOK().WithPayload(newRestMaintenanceWindow(dbWindow))
+}
+
+// Creates a new maintenance window.
+func (r *RestAPI) CreateMaintenanceWindow(ctx context.Context, params events.CreateMaintenanceWindowParams) middleware.Responder {
+	dbWindow, err := newDBMaintenanceWindow(params.Window, nil)
+	if err == nil {
+		err = r.checkMaintenanceWindowTarget(dbWindow)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to create maintenance window: %s", err)
+		log.Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewCreateMaintenanceWindowDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	if err = dbmodel.AddMaintenanceWindow(r.DB, dbWindow); err != nil {
+		log.WithError(err).Error("Failed to create maintenance window")
+
+		msg := fmt.Sprintf("Failed to create maintenance window %s", dbWindow.Name)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewCreateMaintenanceWindowDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("window", dbWindow.Name).Info("Created new maintenance window")
+
+	rspWindow := newRestMaintenanceWindow(dbWindow)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionCreate, dbmodel.AuditObjectMaintenanceWindow, fmt.Sprint(dbWindow.ID), dbWindow.Name,
+		nil, rspWindow)
+	return events.NewCreateMaintenanceWindowOK().WithPayload(rspWindow)
+}
+
+// Updates the maintenance window.
+func (r *RestAPI) UpdateMaintenanceWindow(ctx context.Context, params events.UpdateMaintenanceWindowParams) middleware.Responder {
+	current, err := dbmodel.GetMaintenanceWindowByID(r.DB, params.ID)
+	if err != nil {
+		log.WithField("maintenanceWindowID", params.ID).WithError(err).Error("Failed to get maintenance window from the database")
+
+		msg := fmt.Sprintf("Failed to get maintenance window with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateMaintenanceWindowDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if current == nil {
+		msg := fmt.Sprintf("Cannot find maintenance window with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateMaintenanceWindowDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+
+	dbWindow, err := newDBMaintenanceWindow(params.Window, current)
+	if err == nil {
+		err = r.checkMaintenanceWindowTarget(dbWindow)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Failed to update maintenance window: %s", err)
+		log.WithField("maintenanceWindowID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateMaintenanceWindowDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	if err = dbmodel.UpdateMaintenanceWindow(r.DB, dbWindow); err != nil {
+		log.WithField("maintenanceWindowID", params.ID).WithError(err).Error("Failed to update maintenance window")
+
+		msg := fmt.Sprintf("Failed to update maintenance window with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateMaintenanceWindowDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("window", dbWindow.Name).Info("Updated maintenance window")
+
+	rspWindow := newRestMaintenanceWindow(dbWindow)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectMaintenanceWindow, fmt.Sprint(dbWindow.ID), dbWindow.Name,
+		newRestMaintenanceWindow(current), rspWindow)
+	return events.NewUpdateMaintenanceWindowOK().WithPayload(rspWindow)
+}
+
+// Deletes the maintenance window. The events raised in the window are kept.
+func (r *RestAPI) DeleteMaintenanceWindow(ctx context.Context, params events.DeleteMaintenanceWindowParams) middleware.Responder {
+	current, err := dbmodel.GetMaintenanceWindowByID(r.DB, params.ID)
+	if err == nil && current != nil {
+		err = dbmodel.DeleteMaintenanceWindow(r.DB, params.ID)
+	}
+	if (err == nil && current == nil) || errors.Is(err, dbmodel.ErrNotExists) {
+		msg := fmt.Sprintf("Cannot find maintenance window with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewDeleteMaintenanceWindowDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+	if err != nil {
+		log.WithField("maintenanceWindowID", params.ID).WithError(err).Error("Failed to delete maintenance window")
+
+		msg := fmt.Sprintf("Failed to delete maintenance window with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewDeleteMaintenanceWindowDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("window", current.Name).Info("Deleted maintenance window")
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectMaintenanceWindow, fmt.Sprint(params.ID), current.Name,
+		newRestMaintenanceWindow(current), nil)
+	return events.NewDeleteMaintenanceWindowOK()
+}
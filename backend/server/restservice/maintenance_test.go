@@ -0,0 +1,143 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+	storkutil "isc.org/stork/util"
+)
+
+// Test that the maintenance windows can be created, fetched, updated and
+// deleted over the REST API.
+func TestMaintenanceWindows(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	machine := &dbmodel.Machine{
+		Address:   "192.0.2.1",
+		AgentPort: 8080,
+	}
+	require.NoError(t, dbmodel.AddMachine(db, machine))
+
+	startsAt := time.Date(2023, 3, 1, 22, 0, 0, 0, time.UTC)
+	start := strfmt.DateTime(startsAt)
+	end := strfmt.DateTime(startsAt.Add(2 * time.Hour))
+
+	// Create the window with the default settings.
+	rsp := rapi.CreateMaintenanceWindow(ctx, events.CreateMaintenanceWindowParams{
+		Window: &models.MaintenanceWindow{
+			Name:        storkutil.Ptr("upgrade"),
+			Description: "OS upgrade",
+			MachineID:   machine.ID,
+			StartsAt:    &start,
+			EndsAt:      &end,
+		},
+	})
+	require.IsType(t, &events.CreateMaintenanceWindowOK{}, rsp)
+	created := rsp.(*events.CreateMaintenanceWindowOK).Payload
+	require.NotZero(t, created.ID)
+	require.Equal(t, "none", created.Recurrence)
+	require.False(t, created.Downgrade)
+	require.False(t, created.Active)
+
+	// The window must end after it starts.
+	rsp = rapi.CreateMaintenanceWindow(ctx, events.CreateMaintenanceWindowParams{
+		Window: &models.MaintenanceWindow{
+			Name:      storkutil.Ptr("invalid"),
+			MachineID: machine.ID,
+			StartsAt:  &end,
+			EndsAt:    &start,
+		},
+	})
+	require.IsType(t, &events.CreateMaintenanceWindowDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*events.CreateMaintenanceWindowDefault)))
+
+	// Non-existing app.
+	rsp = rapi.CreateMaintenanceWindow(ctx, events.CreateMaintenanceWindowParams{
+		Window: &models.MaintenanceWindow{
+			Name:     storkutil.Ptr("app"),
+			AppID:    100,
+			StartsAt: &start,
+			EndsAt:   &end,
+		},
+	})
+	require.IsType(t, &events.CreateMaintenanceWindowDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*events.CreateMaintenanceWindowDefault)))
+
+	rsp = rapi.GetMaintenanceWindows(ctx, events.GetMaintenanceWindowsParams{})
+	require.IsType(t, &events.GetMaintenanceWindowsOK{}, rsp)
+	windows := rsp.(*events.GetMaintenanceWindowsOK).Payload
+	require.EqualValues(t, 1, windows.Total)
+	require.Equal(t, "upgrade", *windows.Items[0].Name)
+
+	// Update the window.
+	rsp = rapi.UpdateMaintenanceWindow(ctx, events.UpdateMaintenanceWindowParams{
+		ID: created.ID,
+		Window: &models.MaintenanceWindow{
+			Name:       storkutil.Ptr("nightly backup"),
+			MachineID:  machine.ID,
+			StartsAt:   &start,
+			EndsAt:     &end,
+			Recurrence: "daily",
+			Downgrade:  true,
+		},
+	})
+	require.IsType(t, &events.UpdateMaintenanceWindowOK{}, rsp)
+
+	rsp = rapi.GetMaintenanceWindow(ctx, events.GetMaintenanceWindowParams{ID: created.ID})
+	require.IsType(t, &events.GetMaintenanceWindowOK{}, rsp)
+	window := rsp.(*events.GetMaintenanceWindowOK).Payload
+	require.Equal(t, "nightly backup", *window.Name)
+	require.Empty(t, window.Description)
+	require.Equal(t, "daily", window.Recurrence)
+	require.True(t, window.Downgrade)
+	require.Equal(t, startsAt, time.Time(*window.StartsAt).UTC())
+
+	// The daily window must be shorter than a day.
+	end = strfmt.DateTime(startsAt.Add(24 * time.Hour))
+	rsp = rapi.UpdateMaintenanceWindow(ctx, events.UpdateMaintenanceWindowParams{
+		ID: created.ID,
+		Window: &models.MaintenanceWindow{
+			Name:       storkutil.Ptr("nightly backup"),
+			MachineID:  machine.ID,
+			StartsAt:   &start,
+			EndsAt:     &end,
+			Recurrence: "daily",
+		},
+	})
+	require.IsType(t, &events.UpdateMaintenanceWindowDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*events.UpdateMaintenanceWindowDefault)))
+
+	rsp = rapi.UpdateMaintenanceWindow(ctx, events.UpdateMaintenanceWindowParams{
+		ID: created.ID + 100,
+		Window: &models.MaintenanceWindow{
+			Name:      storkutil.Ptr("other"),
+			MachineID: machine.ID,
+			StartsAt:  &start,
+			EndsAt:    &end,
+		},
+	})
+	require.IsType(t, &events.UpdateMaintenanceWindowDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*events.UpdateMaintenanceWindowDefault)))
+
+	rsp = rapi.DeleteMaintenanceWindow(ctx, events.DeleteMaintenanceWindowParams{ID: created.ID})
+	require.IsType(t, &events.DeleteMaintenanceWindowOK{}, rsp)
+	rsp = rapi.DeleteMaintenanceWindow(ctx, events.DeleteMaintenanceWindowParams{ID: created.ID})
+	require.IsType(t, &events.DeleteMaintenanceWindowDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*events.DeleteMaintenanceWindowDefault)))
+	rsp = rapi.GetMaintenanceWindow(ctx, events.GetMaintenanceWindowParams{ID: created.ID})
+	require.IsType(t, &events.GetMaintenanceWindowDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*events.GetMaintenanceWindowDefault)))
+}
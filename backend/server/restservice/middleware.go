@@ -1,6 +1,7 @@
 package restservice
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"os"
@@ -13,6 +14,8 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"isc.org/stork/server/auth"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
 	"isc.org/stork/server/eventcenter"
 	"isc.org/stork/server/metrics"
 )
@@ -284,14 +287,130 @@ func (r *RestAPI) InnerMiddleware(handler http.Handler) http.Handler {
 	return handler
 }
 
-// Checks if the user us authorized to access the system (has session).
+// Resolves the scopes of the requested resources using the database.
+type dbScopeResolver struct {
+	db *dbops.PgDB
+}
+
+// Returns the ID of the machine the app is running on or zero if the app
+// doesn't exist.
+func (r *dbScopeResolver) GetAppMachineID(appID int64) (int64, error) {
+	app, err := dbmodel.GetAppByID(r.db, appID)
+	if err != nil || app == nil {
+		return 0, err
+	}
+	return app.MachineID, nil
+}
+
+// Returns the ID of the app the daemon belongs to or zero if the daemon
+// doesn't exist.
+func (r *dbScopeResolver) GetDaemonAppID(daemonID int64) (int64, error) {
+	daemon, err := dbmodel.GetDaemonByID(r.db, daemonID)
+	if err != nil || daemon == nil {
+		return 0, err
+	}
+	return daemon.AppID, nil
+}
+
+// Returns the IDs of the machine groups the machine belongs to.
+func (r *dbScopeResolver) GetMachineGroupIDs(machineID int64) ([]int64, error) {
+	return dbmodel.GetMachineGroupIDsByMachineID(r.db, machineID)
+}
+
+// Checks if the request is allowed for the user who has to change the
+// password. Such user can only change the password, fetch the profile
+// and log out.
+func isPasswordChangeRequest(user *dbmodel.SystemUser, req *http.Request) bool {
+	switch path.Clean(req.URL.Path) {
+	case "/api/sessions",
+		fmt.Sprintf("/api/users/%d", user.ID),
+		fmt.Sprintf("/api/users/%d/password", user.ID):
+		return true
+	default:
+		return false
+	}
+}
+
+// Checks if the request modifies the profile of the user sending it, e.g.
+// changes the password, the email subscriptions or the second factor
+// settings. The API tokens must not be used for such requests because the
+// users can access their own profiles regardless of the permissions, so
+// the permissions of the token wouldn't restrict them.
+func isOwnProfileUpdate(user *dbmodel.SystemUser, req *http.Request) bool {
+	if req.Method == http.MethodGet || req.Method == http.MethodHead {
+		return false
+	}
+	urlPath := path.Clean(req.URL.Path)
+	prefix := fmt.Sprintf("/api/users/%d", user.ID)
+	return urlPath == prefix || strings.HasPrefix(urlPath, prefix+"/")
+}
+
+// Returns the permissions granted to the groups the user belongs to.
+func (r *RestAPI) getUserPermissions(user *dbmodel.SystemUser) ([]dbmodel.SystemGroupPermission, error) {
+	var groupIDs []int
+	for _, group := range user.Groups {
+		groupIDs = append(groupIDs, group.ID)
+	}
+	return dbmodel.GetPermissionsByGroupIDs(r.DB, groupIDs)
+}
+
+// Returns a function checking if the user sending the request may perform
+// the action on all resources of the given type. The API token with its
+// own permissions narrows down the permissions of the user, like in the
+// Authorizer.
+func (r *RestAPI) getPermissionChecker(ctx context.Context) (func(resource string, action dbmodel.PermissionAction) bool, error) {
+	ok, u := r.SessionManager.Logged(ctx)
+	if !ok {
+		return func(string, dbmodel.PermissionAction) bool { return false }, nil
+	}
+	permissions, err := r.getUserPermissions(u)
+	if err != nil {
+		return nil, err
+	}
+	apiToken := r.SessionManager.APIToken(ctx)
+	return func(resource string, action dbmodel.PermissionAction) bool {
+		if !auth.IsPermitted(u, permissions, resource, action) {
+			return false
+		}
+		if apiToken != nil && apiToken.HasPermissions() {
+			return auth.IsPermitted(&dbmodel.SystemUser{ID: u.ID}, apiToken.Permissions, resource, action)
+		}
+		return true
+	}, nil
+}
+
+// Checks if the user us authorized to access the system (has session)
+// and has the permissions to access the requested resource.
 func (r *RestAPI) Authorizer(req *http.Request) error {
 	ok, u := r.SessionManager.Logged(req.Context())
 	if !ok {
 		return errors.Errorf("user unauthorized")
 	}
 
-	ok, _ = auth.Authorize(u, req)
+	if r.SessionManager.IsPasswordChangeRequired(req.Context()) && !isPasswordChangeRequest(u, req) {
+		return errors.Errorf("user has to change the password")
+	}
+
+	if r.SessionManager.APIToken(req.Context()) != nil && isOwnProfileUpdate(u, req) {
+		return errors.Errorf("user profile cannot be modified using an API token")
+	}
+
+	permissions, err := r.getUserPermissions(u)
+	if err != nil {
+		log.WithError(err).Error("Failed to get the permissions of the user")
+		return errors.Errorf("failed to get the permissions of the user")
+	}
+
+	resolver := &dbScopeResolver{db: r.DB}
+	ok, err = auth.Authorize(u, permissions, req, resolver)
+	// The API token with its own permissions can only narrow down the
+	// permissions of the user.
+	if apiToken := r.SessionManager.APIToken(req.Context()); ok && apiToken != nil && apiToken.HasPermissions() {
+		ok, err = auth.Authorize(&dbmodel.SystemUser{ID: u.ID}, apiToken.Permissions, req, resolver)
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to authorize the user")
+	}
 	if !ok {
 		return errors.Errorf("user logged in but not allowed to access the resource")
 	}
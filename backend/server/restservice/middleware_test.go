@@ -1,6 +1,8 @@
 package restservice
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
 	dbsession "isc.org/stork/server/database/session"
 	dbtest "isc.org/stork/server/database/test"
 	storktest "isc.org/stork/server/test"
@@ -55,7 +58,7 @@ func TestInnerMiddleware(t *testing.T) {
 	defer teardown()
 	rapi, err := NewRestAPI(dbSettings, db)
 	require.NoError(t, err)
-	sm, err := dbsession.NewSessionMgr(rapi.DBSettings)
+	sm, err := dbsession.NewSessionMgr(rapi.DBSettings, rapi.DB)
 	require.NoError(t, err)
 	rapi.SessionManager = sm
 
@@ -354,3 +357,105 @@ func TestFileServerMiddelware(t *testing.T) {
 		require.NoError(t, err)
 	})
 }
+
+// Test that the authorizer checks the permissions of the logged user
+// stored in the database.
+func TestAuthorizerPermissions(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Create a user belonging to the read-only group.
+	user := &dbmodel.SystemUser{
+		Login:    "viewer",
+		Lastname: "Smith",
+		Name:     "John",
+		Groups:   []*dbmodel.SystemGroup{{ID: 3}},
+	}
+	_, err = dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	newRequest := func(method, path string) *http.Request {
+		req, _ := http.NewRequestWithContext(ctx, method, "http://example.org/api"+path, nil)
+		return req
+	}
+
+	require.NoError(t, rapi.Authorizer(newRequest("GET", "/machines")))
+	require.NoError(t, rapi.Authorizer(newRequest("GET", "/hosts")))
+	require.Error(t, rapi.Authorizer(newRequest("PUT", "/settings")))
+	require.Error(t, rapi.Authorizer(newRequest("POST", "/hosts/new/transaction")))
+	require.Error(t, rapi.Authorizer(newRequest("GET", "/users")))
+
+	// The user who is not logged in is not authorized.
+	req, _ := http.NewRequestWithContext(context.Background(), "GET", "http://example.org/api/machines", nil)
+	require.Error(t, rapi.Authorizer(req))
+}
+
+// Test that the API tokens can't be used to modify the profile of the
+// token owner, even though the users can access their own profiles
+// regardless of their permissions.
+func TestAuthorizerAPITokenOwnProfile(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	user := &dbmodel.SystemUser{
+		Login:    "bot",
+		Lastname: "Bot",
+		Name:     "CI",
+		Groups:   []*dbmodel.SystemGroup{{ID: dbmodel.AdminGroupID}},
+	}
+	_, err = dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	token, hash, err := dbmodel.GenerateAPIToken()
+	require.NoError(t, err)
+	_, err = dbmodel.AddAPIToken(db, &dbmodel.APIToken{
+		UserID:    user.ID,
+		Name:      "hosts-reader",
+		TokenHash: hash,
+		Permissions: []dbmodel.SystemGroupPermission{
+			{Resource: dbmodel.PermissionResourceHosts, Action: dbmodel.PermissionActionRead},
+		},
+	})
+	require.NoError(t, err)
+
+	var authErr error
+	handler := rapi.SessionManager.SessionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authErr = rapi.Authorizer(req)
+	}))
+	authorize := func(method, path string) error {
+		req, _ := http.NewRequestWithContext(context.Background(), method, "http://example.org/api"+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		authErr = errors.New("request rejected by the session middleware")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return authErr
+	}
+
+	self := fmt.Sprintf("/users/%d", user.ID)
+	require.NoError(t, authorize("GET", self))
+	require.Error(t, authorize("PUT", self+"/password"))
+	require.Error(t, authorize("POST", self+"/totp"))
+	require.Error(t, authorize("PUT", self+"/totp"))
+	require.Error(t, authorize("DELETE", self+"/totp"))
+	require.Error(t, authorize("POST", self+"/email-subscriptions"))
+
+	// The session-authenticated user can modify their own profile.
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+	req, _ := http.NewRequestWithContext(ctx, "PUT", "http://example.org/api"+self+"/password", nil)
+	require.NoError(t, rapi.Authorizer(req))
+	req, _ = http.NewRequestWithContext(ctx, "DELETE", "http://example.org/api"+self+"/totp", nil)
+	require.NoError(t, rapi.Authorizer(req))
+}
@@ -56,6 +56,8 @@ type RestAPISettings struct {
 	TLSCACertificate  flags.Filename `long:"rest-tls-ca" description:"The certificate authority file to be used with mutual tls auth" env:"STORK_REST_TLS_CA_CERTIFICATE"`
 
 	StaticFilesDir string `long:"rest-static-files-dir" description:"The directory with static files for the UI" default:"" env:"STORK_REST_STATIC_FILES_DIR"`
+
+	TrustedProxies []string `long:"rest-trusted-proxy" description:"The IP address or prefix of a reverse proxy trusted to specify the client address in the X-Real-IP header; it can be specified multiple times" env:"STORK_REST_TRUSTED_PROXIES" env-delim:","`
 }
 
 // Runtime information and settings for RestAPI service.
@@ -82,6 +84,9 @@ type RestAPI struct {
 	hasListeners bool
 	Host         string // actual host for listening
 	Port         int    // actual port for listening
+
+	// Parsed addresses of the trusted reverse proxies.
+	trustedProxies []*net.IPNet
 }
 
 // Instantiates RestAPI structure.
@@ -198,8 +203,16 @@ func NewRestAPI(args ...interface{}) (*RestAPI, error) {
 		return nil, pkgerrors.Errorf("dbops.DatabaseSettings parameter is required in NewRestAPI call")
 	}
 
+	if api.Settings != nil {
+		trustedProxies, err := parseTrustedProxies(api.Settings.TrustedProxies)
+		if err != nil {
+			return nil, err
+		}
+		api.trustedProxies = trustedProxies
+	}
+
 	// Instantiate the session manager.
-	sm, err := dbsession.NewSessionMgr(api.DBSettings)
+	sm, err := dbsession.NewSessionMgr(api.DBSettings, api.DB)
 	if err != nil {
 		return nil, pkgerrors.Wrap(err, "unable to establish connection to the session database")
 	}
@@ -117,6 +117,18 @@ func TestNewRestAPI(t *testing.T) {
 	api, err = NewRestAPI()
 	require.Error(t, err)
 	require.Nil(t, api)
+
+	// The trusted proxies are parsed.
+	settings = &RestAPISettings{TrustedProxies: []string{"192.0.2.1", "10.0.0.0/8"}}
+	api, err = NewRestAPI(settings, dbs)
+	require.NoError(t, err)
+	require.Len(t, api.trustedProxies, 2)
+
+	// Invalid trusted proxy address.
+	settings = &RestAPISettings{TrustedProxies: []string{"foo"}}
+	api, err = NewRestAPI(settings, dbs)
+	require.Error(t, err)
+	require.Nil(t, api)
 }
 
 // Test that the authentication icons are extracted from callout carriers.
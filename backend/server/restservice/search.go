@@ -22,78 +22,98 @@ func handleSearchError(err error, text string) middleware.Responder {
 }
 
 // Search through different tables in database. Currently supported tables are:
-// machines, apps, subnets, shared networks, hosts, users, groups.
-// If filter text is empty then empty result is returned.
+// machines, apps, subnets, shared networks, hosts, users, groups, zones.
+// If filter text is empty then empty result is returned. The result
+// includes only the records the user is permitted to view.
 func (r *RestAPI) SearchRecords(ctx context.Context, params search.SearchRecordsParams) middleware.Responder {
+	result := &models.SearchResult{
+		Subnets:        &models.Subnets{},
+		SharedNetworks: &models.SharedNetworks{},
+		Hosts:          &models.Hosts{},
+		Machines:       &models.Machines{},
+		Apps:           &models.Apps{},
+		Users:          &models.Users{},
+		Groups:         &models.Groups{},
+		Zones:          &models.Zones{},
+	}
+
 	// if empty text is provided then empty result is returned
 	if params.Text == nil || strings.TrimSpace(*params.Text) == "" {
-		result := &models.SearchResult{
-			Subnets:        &models.Subnets{},
-			SharedNetworks: &models.SharedNetworks{},
-			Hosts:          &models.Hosts{},
-			Machines:       &models.Machines{},
-			Apps:           &models.Apps{},
-			Users:          &models.Users{},
-			Groups:         &models.Groups{},
-		}
 		rsp := search.NewSearchRecordsOK().WithPayload(result)
 		return rsp
 	}
 	text := strings.TrimSpace(*params.Text)
-	filters := &dbmodel.SubnetsByPageFilters{Text: &text}
 
-	// get list of subnets
-	subnets, err := r.getSubnets(0, 5, filters, "", dbmodel.SortDirAny)
+	isPermitted, err := r.getPermissionChecker(ctx)
 	if err != nil {
-		return handleSearchError(err, "Cannot get subnets from the db")
+		return handleSearchError(err, "Cannot get the user permissions from the db")
+	}
+	canView := func(resource string) bool {
+		return isPermitted(resource, dbmodel.PermissionActionRead)
 	}
 
-	// get list of shared networks
-	sharedNetworks, err := r.getSharedNetworks(0, 5, 0, 0, &text, "", dbmodel.SortDirAny)
-	if err != nil {
-		return handleSearchError(err, "Cannot get shared networks from the db")
+	if canView(dbmodel.PermissionResourceSubnets) {
+		// get list of subnets
+		filters := &dbmodel.SubnetsByPageFilters{Text: &text}
+		result.Subnets, err = r.getSubnets(0, 5, filters, "", dbmodel.SortDirAny)
+		if err != nil {
+			return handleSearchError(err, "Cannot get subnets from the db")
+		}
+
+		// get list of shared networks
+		result.SharedNetworks, err = r.getSharedNetworks(0, 5, 0, 0, &text, "", dbmodel.SortDirAny)
+		if err != nil {
+			return handleSearchError(err, "Cannot get shared networks from the db")
+		}
 	}
 
-	// get list of hosts
-	hosts, err := r.getHosts(0, 5, 0, nil, nil, &text, nil, "", dbmodel.SortDirAny)
-	if err != nil {
-		return handleSearchError(err, "Cannot get hosts from the db")
+	if canView(dbmodel.PermissionResourceHosts) {
+		// get list of hosts
+		result.Hosts, err = r.getHosts(0, 5, 0, nil, nil, &text, nil, "", dbmodel.SortDirAny)
+		if err != nil {
+			return handleSearchError(err, "Cannot get hosts from the db")
+		}
 	}
 
-	// get list of machines
-	authorized := true
-	machines, err := r.getMachines(0, 5, &text, &authorized, "", dbmodel.SortDirAny)
-	if err != nil {
-		return handleSearchError(err, "Cannot get machines from the db")
+	if canView(dbmodel.PermissionResourceMachines) {
+		// get list of machines
+		authorized := true
+		result.Machines, err = r.getMachines(0, 5, &text, &authorized, "", dbmodel.SortDirAny)
+		if err != nil {
+			return handleSearchError(err, "Cannot get machines from the db")
+		}
 	}
 
-	// get list of apps
-	apps, err := r.getApps(0, 5, &text, "", "", dbmodel.SortDirAny)
-	if err != nil {
-		return handleSearchError(err, "Cannot get apps from the db")
+	if canView(dbmodel.PermissionResourceApps) {
+		// get list of apps
+		result.Apps, err = r.getApps(0, 5, &text, "", "", dbmodel.SortDirAny)
+		if err != nil {
+			return handleSearchError(err, "Cannot get apps from the db")
+		}
 	}
 
-	// get list of users
-	users, err := r.getUsers(0, 5, &text, "", dbmodel.SortDirAny)
-	if err != nil {
-		return handleSearchError(err, "Cannot get users from the db")
+	if canView(dbmodel.PermissionResourceUsers) {
+		// get list of users
+		result.Users, err = r.getUsers(0, 5, &text, "", dbmodel.SortDirAny)
+		if err != nil {
+			return handleSearchError(err, "Cannot get users from the db")
+		}
 	}
 
-	// get list of groups
-	groups, err := r.getGroups(0, 5, &text, "", dbmodel.SortDirAny)
-	if err != nil {
-		return handleSearchError(err, "Cannot get groups from the db")
+	if canView(dbmodel.PermissionResourceGroups) {
+		// get list of groups
+		result.Groups, err = r.getGroups(0, 5, &text, "", dbmodel.SortDirAny)
+		if err != nil {
+			return handleSearchError(err, "Cannot get groups from the db")
+		}
 	}
 
-	// combine gathered information
-	result := &models.SearchResult{
-		Subnets:        subnets,
-		SharedNetworks: sharedNetworks,
-		Hosts:          hosts,
-		Machines:       machines,
-		Apps:           apps,
-		Users:          users,
-		Groups:         groups,
+	if canView(dbmodel.PermissionResourceZones) {
+		// get list of zones
+		result.Zones, err = r.getZones(0, 5, &dbmodel.Bind9ZonesByPageFilters{Text: &text}, "name", dbmodel.SortDirAsc)
+		if err != nil {
+			return handleSearchError(err, "Cannot get zones from the db")
+		}
 	}
 
 	rsp := search.NewSearchRecordsOK().WithPayload(result)
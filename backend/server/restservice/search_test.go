@@ -1,7 +1,6 @@
 package restservice
 
 import (
-	"context"
 	"testing"
 
 	"github.com/pkg/errors"
@@ -20,7 +19,7 @@ func TestSearchRecords(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(nil, nil)
 	rapi, err := NewRestAPI(dbSettings, db, fa)
 	require.NoError(t, err)
-	ctx := context.Background()
+	ctx, _ := loginTestUser(t, rapi, db, "root", dbmodel.SuperAdminGroupID)
 
 	// search with empty text
 	params := search.SearchRecordsParams{}
@@ -41,6 +40,8 @@ func TestSearchRecords(t *testing.T) {
 	require.Zero(t, okRsp.Payload.Subnets.Total)
 	require.Len(t, okRsp.Payload.Users.Items, 0)
 	require.Zero(t, okRsp.Payload.Users.Total)
+	require.Len(t, okRsp.Payload.Zones.Items, 0)
+	require.Zero(t, okRsp.Payload.Zones.Total)
 
 	// add machine
 	m := &dbmodel.Machine{
@@ -394,6 +395,45 @@ func TestSearchRecords(t *testing.T) {
 	require.Zero(t, okRsp.Payload.Users.Total)
 }
 
+// Check that the search returns only the records the user is permitted
+// to view.
+func TestSearchRecordsPermissions(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	rapi, err := NewRestAPI(dbSettings, db, fa)
+	require.NoError(t, err)
+
+	group := &dbmodel.SystemGroup{
+		Name: "group-viewer",
+		Permissions: []*dbmodel.SystemGroupPermission{
+			{Resource: dbmodel.PermissionResourceGroups, Action: dbmodel.PermissionActionRead},
+			{
+				Resource:  dbmodel.PermissionResourceUsers,
+				Action:    dbmodel.PermissionActionRead,
+				ScopeType: dbmodel.PermissionScopeMachine,
+				ScopeID:   1,
+			},
+		},
+	}
+	_, err = dbmodel.AddGroup(db, group)
+	require.NoError(t, err)
+	ctx, _ := loginTestUser(t, rapi, db, "viewer", group.ID)
+
+	// search for 'admin' - only the groups are expected because the
+	// scoped permission doesn't allow viewing all users.
+	text := "admin"
+	rsp := rapi.SearchRecords(ctx, search.SearchRecordsParams{Text: &text})
+	require.IsType(t, &search.SearchRecordsOK{}, rsp)
+	okRsp := rsp.(*search.SearchRecordsOK)
+	require.Len(t, okRsp.Payload.Groups.Items, 2)
+	require.Empty(t, okRsp.Payload.Users.Items)
+	require.Zero(t, okRsp.Payload.Users.Total)
+	require.Empty(t, okRsp.Payload.Machines.Items)
+	require.Empty(t, okRsp.Payload.Zones.Items)
+}
+
 // Check handing error in search.
 func TestSearchErrorHandling(t *testing.T) {
 	err := errors.New("some error")
@@ -2,19 +2,65 @@ package restservice
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 
 	"github.com/go-openapi/runtime/middleware"
 	log "github.com/sirupsen/logrus"
 
 	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+	"isc.org/stork/server/forecast"
 	"isc.org/stork/server/gen/models"
 	"isc.org/stork/server/gen/restapi/operations/settings"
 )
 
+// Returns the global settings from the database in the REST API format.
+func (r *RestAPI) getSettings() (*models.Settings, error) {
+	dbSettingsMap, err := dbmodel.GetAllSettings(r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &models.Settings{
+		Bind9StatsPullerInterval:        dbSettingsMap["bind9_stats_puller_interval"].(int64),
+		Bind9ZoneDriftCheckerInterval:   dbSettingsMap["bind9_zone_drift_checker_interval"].(int64),
+		Bind9ZoneLagThreshold:           dbSettingsMap["bind9_zone_lag_threshold"].(int64),
+		GrafanaURL:                      dbSettingsMap["grafana_url"].(string),
+		KeaHostsPullerInterval:          dbSettingsMap["kea_hosts_puller_interval"].(int64),
+		KeaStatsPullerInterval:          dbSettingsMap["kea_stats_puller_interval"].(int64),
+		KeaStatusPullerInterval:         dbSettingsMap["kea_status_puller_interval"].(int64),
+		AppsStatePullerInterval:         dbSettingsMap["apps_state_puller_interval"].(int64),
+		PrometheusURL:                   dbSettingsMap["prometheus_url"].(string),
+		MetricsCollectorInterval:        dbSettingsMap["metrics_collector_interval"].(int64),
+		AuditLogRetentionDays:           dbSettingsMap["audit_log_retention_days"].(int64),
+		EventRetentionDays:              dbSettingsMap["event_retention_days"].(int64),
+		EventRetentionMaxInfo:           dbSettingsMap["event_retention_max_info"].(int64),
+		EventRetentionMaxWarning:        dbSettingsMap["event_retention_max_warning"].(int64),
+		EventRetentionMaxError:          dbSettingsMap["event_retention_max_error"].(int64),
+		UtilizationForecastHorizonDays:  dbSettingsMap["utilization_forecast_horizon_days"].(int64),
+		UtilizationForecastMethod:       dbSettingsMap["utilization_forecast_method"].(string),
+		PasswordMinLength:               dbSettingsMap["password_min_length"].(int64),
+		PasswordRequireMixedCase:        dbSettingsMap["password_require_mixed_case"].(bool),
+		PasswordRequireDigit:            dbSettingsMap["password_require_digit"].(bool),
+		PasswordRequireSpecialCharacter: dbSettingsMap["password_require_special_character"].(bool),
+		PasswordHistoryCount:            dbSettingsMap["password_history_count"].(int64),
+		PasswordExpirationDays:          dbSettingsMap["password_expiration_days"].(int64),
+		PasswordChangeOnFirstLogin:      dbSettingsMap["password_change_on_first_login"].(bool),
+		AccountLockoutThreshold:         dbSettingsMap["account_lockout_threshold"].(int64),
+		AccountLockoutDuration:          dbSettingsMap["account_lockout_duration"].(int64),
+		SMTPHost:                        dbSettingsMap["smtp_host"].(string),
+		SMTPPort:                        dbSettingsMap["smtp_port"].(int64),
+		SMTPSecurity:                    dbSettingsMap["smtp_security"].(string),
+		SMTPUsername:                    dbSettingsMap["smtp_username"].(string),
+		SMTPSender:                      dbSettingsMap["smtp_sender"].(string),
+	}
+	return s, nil
+}
+
 // Get global settings.
 func (r *RestAPI) GetSettings(ctx context.Context, params settings.GetSettingsParams) middleware.Responder {
-	dbSettingsMap, err := dbmodel.GetAllSettings(r.DB)
+	s, err := r.getSettings()
 	if err != nil {
 		msg := "Cannot get global settings"
 		log.Error(err)
@@ -24,16 +70,6 @@ func (r *RestAPI) GetSettings(ctx context.Context, params settings.GetSettingsPa
 		return rsp
 	}
 
-	s := &models.Settings{
-		Bind9StatsPullerInterval: dbSettingsMap["bind9_stats_puller_interval"].(int64),
-		GrafanaURL:               dbSettingsMap["grafana_url"].(string),
-		KeaHostsPullerInterval:   dbSettingsMap["kea_hosts_puller_interval"].(int64),
-		KeaStatsPullerInterval:   dbSettingsMap["kea_stats_puller_interval"].(int64),
-		KeaStatusPullerInterval:  dbSettingsMap["kea_status_puller_interval"].(int64),
-		AppsStatePullerInterval:  dbSettingsMap["apps_state_puller_interval"].(int64),
-		PrometheusURL:            dbSettingsMap["prometheus_url"].(string),
-		MetricsCollectorInterval: dbSettingsMap["metrics_collector_interval"].(int64),
-	}
 	rsp := settings.NewGetSettingsOK().WithPayload(s)
 
 	return rsp
@@ -56,7 +92,49 @@ func (r *RestAPI) UpdateSettings(ctx context.Context, params settings.UpdateSett
 		Message: &msg,
 	})
 
-	err := dbmodel.SetSettingInt(r.DB, "bind9_stats_puller_interval", s.Bind9StatsPullerInterval)
+	// The clients unaware of the email notifications don't set the SMTP
+	// security mode. Use the default one in this case.
+	if s.SMTPSecurity == "" {
+		s.SMTPSecurity = eventcenter.SMTPSecuritySTARTTLS
+	}
+	if !eventcenter.IsValidSMTPSecurity(s.SMTPSecurity) {
+		msg := fmt.Sprintf("Invalid SMTP security mode %s", s.SMTPSecurity)
+		log.Error(msg)
+		return settings.NewGetSettingsDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+
+	// The clients unaware of the exhaustion forecasts don't set the
+	// forecasting method. Use the default one in this case.
+	if s.UtilizationForecastMethod == "" {
+		s.UtilizationForecastMethod = string(forecast.MethodLinear)
+	}
+	if !forecast.IsValidMethod(s.UtilizationForecastMethod) {
+		msg := fmt.Sprintf("Invalid utilization forecast method %s", s.UtilizationForecastMethod)
+		log.Error(msg)
+		return settings.NewGetSettingsDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+
+	before, err := r.getSettings()
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+
+	err = dbmodel.SetSettingInt(r.DB, "bind9_stats_puller_interval", s.Bind9StatsPullerInterval)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "bind9_zone_drift_checker_interval", s.Bind9ZoneDriftCheckerInterval)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "bind9_zone_lag_threshold", s.Bind9ZoneLagThreshold)
 	if err != nil {
 		log.Error(err)
 		return errRsp
@@ -96,6 +174,126 @@ func (r *RestAPI) UpdateSettings(ctx context.Context, params settings.UpdateSett
 		log.Error(err)
 		return errRsp
 	}
+	err = dbmodel.SetSettingInt(r.DB, "audit_log_retention_days", s.AuditLogRetentionDays)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_retention_days", s.EventRetentionDays)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_retention_max_info", s.EventRetentionMaxInfo)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_retention_max_warning", s.EventRetentionMaxWarning)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "event_retention_max_error", s.EventRetentionMaxError)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "utilization_forecast_horizon_days", s.UtilizationForecastHorizonDays)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingStr(r.DB, "utilization_forecast_method", s.UtilizationForecastMethod)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "password_min_length", s.PasswordMinLength)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingBool(r.DB, "password_require_mixed_case", s.PasswordRequireMixedCase)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingBool(r.DB, "password_require_digit", s.PasswordRequireDigit)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingBool(r.DB, "password_require_special_character", s.PasswordRequireSpecialCharacter)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "password_history_count", s.PasswordHistoryCount)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "password_expiration_days", s.PasswordExpirationDays)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingBool(r.DB, "password_change_on_first_login", s.PasswordChangeOnFirstLogin)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "account_lockout_threshold", s.AccountLockoutThreshold)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "account_lockout_duration", s.AccountLockoutDuration)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingStr(r.DB, "smtp_host", s.SMTPHost)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "smtp_port", s.SMTPPort)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingStr(r.DB, "smtp_security", s.SMTPSecurity)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingStr(r.DB, "smtp_username", s.SMTPUsername)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	// The password is not returned to the users so the empty password
+	// means that it hasn't been changed.
+	if s.SMTPPassword != "" {
+		err = dbmodel.SetSettingPasswd(r.DB, "smtp_password", s.SMTPPassword)
+		if err != nil {
+			log.Error(err)
+			return errRsp
+		}
+	}
+	err = dbmodel.SetSettingStr(r.DB, "smtp_sender", s.SMTPSender)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+
+	if after, err := r.getSettings(); err == nil {
+		r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectSettings, "", "", before, after)
+	} else {
+		log.Error(err)
+	}
 
 	rsp := settings.NewUpdateSettingsOK()
 	return rsp
@@ -2,6 +2,7 @@ package restservice
 
 import (
 	"context"
+	"net/http"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -37,12 +38,14 @@ func TestSettings(t *testing.T) {
 	require.IsType(t, &settings.GetSettingsOK{}, rsp)
 	okRsp := rsp.(*settings.GetSettingsOK)
 	require.EqualValues(t, 60, okRsp.Payload.Bind9StatsPullerInterval)
+	require.EqualValues(t, 900, okRsp.Payload.Bind9ZoneLagThreshold)
 	require.Empty(t, okRsp.Payload.GrafanaURL)
 
 	// update settings
 	paramsUS := settings.UpdateSettingsParams{
 		Settings: &models.Settings{
 			Bind9StatsPullerInterval: 10,
+			Bind9ZoneLagThreshold:    300,
 			GrafanaURL:               "http://localhost:3000",
 		},
 	}
@@ -55,5 +58,108 @@ func TestSettings(t *testing.T) {
 	require.IsType(t, &settings.GetSettingsOK{}, rsp)
 	okRsp = rsp.(*settings.GetSettingsOK)
 	require.EqualValues(t, 10, okRsp.Payload.Bind9StatsPullerInterval)
+	require.EqualValues(t, 300, okRsp.Payload.Bind9ZoneLagThreshold)
 	require.EqualValues(t, "http://localhost:3000", okRsp.Payload.GrafanaURL)
+	require.Equal(t, "starttls", okRsp.Payload.SMTPSecurity)
+	require.Empty(t, okRsp.Payload.SMTPPassword)
+}
+
+// Test that the SMTP password is updated only when it is specified and
+// it is never returned.
+func TestUpdateSMTPSettings(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rSettings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	fd := &storktest.FakeDispatcher{}
+	rapi, err := NewRestAPI(&rSettings, dbSettings, db, fa, fec, nil, fd, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	err = dbmodel.InitializeSettings(db, 0)
+	require.NoError(t, err)
+
+	rsp := rapi.UpdateSettings(ctx, settings.UpdateSettingsParams{
+		Settings: &models.Settings{
+			SMTPHost:     "smtp.example.org",
+			SMTPPort:     465,
+			SMTPSecurity: "tls",
+			SMTPUsername: "stork",
+			SMTPPassword: "secret",
+			SMTPSender:   "stork@example.org",
+		},
+	})
+	require.IsType(t, &settings.UpdateSettingsOK{}, rsp)
+
+	password, err := dbmodel.GetSettingPasswd(db, "smtp_password")
+	require.NoError(t, err)
+	require.Equal(t, "secret", password)
+
+	rsp = rapi.GetSettings(ctx, settings.GetSettingsParams{})
+	require.IsType(t, &settings.GetSettingsOK{}, rsp)
+	okRsp := rsp.(*settings.GetSettingsOK)
+	require.Equal(t, "smtp.example.org", okRsp.Payload.SMTPHost)
+	require.EqualValues(t, 465, okRsp.Payload.SMTPPort)
+	require.Equal(t, "tls", okRsp.Payload.SMTPSecurity)
+	require.Equal(t, "stork", okRsp.Payload.SMTPUsername)
+	require.Equal(t, "stork@example.org", okRsp.Payload.SMTPSender)
+	require.Empty(t, okRsp.Payload.SMTPPassword)
+
+	// The empty password keeps the current one.
+	okRsp.Payload.SMTPHost = "mail.example.org"
+	rsp = rapi.UpdateSettings(ctx, settings.UpdateSettingsParams{Settings: okRsp.Payload})
+	require.IsType(t, &settings.UpdateSettingsOK{}, rsp)
+	password, err = dbmodel.GetSettingPasswd(db, "smtp_password")
+	require.NoError(t, err)
+	require.Equal(t, "secret", password)
+
+	// Invalid security mode.
+	okRsp.Payload.SMTPSecurity = "ssl"
+	rsp = rapi.UpdateSettings(ctx, settings.UpdateSettingsParams{Settings: okRsp.Payload})
+	require.IsType(t, &settings.GetSettingsDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*settings.GetSettingsDefault)))
+}
+
+// Test that the utilization forecast settings are updated and the
+// forecasting method is validated.
+func TestUpdateUtilizationForecastSettings(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rSettings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	fd := &storktest.FakeDispatcher{}
+	rapi, err := NewRestAPI(&rSettings, dbSettings, db, fa, fec, nil, fd, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	err = dbmodel.InitializeSettings(db, 0)
+	require.NoError(t, err)
+
+	rsp := rapi.GetSettings(ctx, settings.GetSettingsParams{})
+	require.IsType(t, &settings.GetSettingsOK{}, rsp)
+	okRsp := rsp.(*settings.GetSettingsOK)
+	require.EqualValues(t, 30, okRsp.Payload.UtilizationForecastHorizonDays)
+	require.Equal(t, "linear", okRsp.Payload.UtilizationForecastMethod)
+
+	okRsp.Payload.UtilizationForecastHorizonDays = 14
+	okRsp.Payload.UtilizationForecastMethod = "seasonal"
+	rsp = rapi.UpdateSettings(ctx, settings.UpdateSettingsParams{Settings: okRsp.Payload})
+	require.IsType(t, &settings.UpdateSettingsOK{}, rsp)
+
+	horizon, err := dbmodel.GetSettingInt(db, "utilization_forecast_horizon_days")
+	require.NoError(t, err)
+	require.EqualValues(t, 14, horizon)
+	method, err := dbmodel.GetSettingStr(db, "utilization_forecast_method")
+	require.NoError(t, err)
+	require.Equal(t, "seasonal", method)
+
+	// Invalid forecasting method.
+	okRsp.Payload.UtilizationForecastMethod = "quadratic"
+	rsp = rapi.UpdateSettings(ctx, settings.UpdateSettingsParams{Settings: okRsp.Payload})
+	require.IsType(t, &settings.GetSettingsDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*settings.GetSettingsDefault)))
 }
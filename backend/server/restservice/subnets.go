@@ -18,13 +18,15 @@ import (
 // Creates a REST API representation of a subnet from a database model.
 func (r *RestAPI) subnetToRestAPI(sn *dbmodel.Subnet) *models.Subnet {
 	subnet := &models.Subnet{
-		ID:               sn.ID,
-		Subnet:           sn.Prefix,
-		ClientClass:      sn.ClientClass,
-		AddrUtilization:  float64(sn.AddrUtilization) / 10,
-		PdUtilization:    float64(sn.PdUtilization) / 10,
-		Stats:            sn.Stats,
-		StatsCollectedAt: strfmt.DateTime(sn.StatsCollectedAt),
+		ID:                 sn.ID,
+		Subnet:             sn.Prefix,
+		ClientClass:        sn.ClientClass,
+		AddrUtilization:    float64(sn.AddrUtilization) / 10,
+		PdUtilization:      float64(sn.PdUtilization) / 10,
+		Stats:              sn.Stats,
+		StatsCollectedAt:   strfmt.DateTime(sn.StatsCollectedAt),
+		AddrExhaustionDays: getExhaustionDays(sn.AddrExhaustionAt),
+		PdExhaustionDays:   getExhaustionDays(sn.PdExhaustionAt),
 	}
 
 	if sn.SharedNetwork != nil {
@@ -45,6 +47,15 @@ func (r *RestAPI) subnetToRestAPI(sn *dbmodel.Subnet) *models.Subnet {
 		for _, poolDetails := range lsn.AddressPools {
 			pool := poolDetails.LowerBound + "-" + poolDetails.UpperBound
 			localSubnet.Pools = append(localSubnet.Pools, pool)
+			if !poolDetails.StatsCollectedAt.IsZero() {
+				localSubnet.PoolStats = append(localSubnet.PoolStats, &models.PoolStats{
+					Pool:             pool,
+					Utilization:      float64(poolDetails.Utilization) / 10,
+					ExhaustionDays:   getExhaustionDays(poolDetails.ExhaustionAt),
+					Stats:            poolDetails.Stats,
+					StatsCollectedAt: strfmt.DateTime(poolDetails.StatsCollectedAt),
+				})
+			}
 		}
 
 		for _, prefixPoolDetails := range lsn.PrefixPools {
@@ -58,6 +69,16 @@ func (r *RestAPI) subnetToRestAPI(sn *dbmodel.Subnet) *models.Subnet {
 					ExcludedPrefix:  prefixPoolDetails.ExcludedPrefix,
 				},
 			)
+			if !prefixPoolDetails.StatsCollectedAt.IsZero() {
+				localSubnet.PoolStats = append(localSubnet.PoolStats, &models.PoolStats{
+					Pool:             prefix,
+					PrefixDelegation: true,
+					Utilization:      float64(prefixPoolDetails.Utilization) / 10,
+					ExhaustionDays:   getExhaustionDays(prefixPoolDetails.ExhaustionAt),
+					Stats:            prefixPoolDetails.Stats,
+					StatsCollectedAt: strfmt.DateTime(prefixPoolDetails.StatsCollectedAt),
+				})
+			}
 		}
 
 		// Subnet level Kea DHCP parameters.
@@ -399,13 +420,15 @@ func (r *RestAPI) getSharedNetworks(offset, limit, appID, family int64, filterTe
 		}
 		// Create shared network.
 		sharedNetwork := &models.SharedNetwork{
-			ID:               net.ID,
-			Name:             net.Name,
-			Subnets:          subnets,
-			AddrUtilization:  float64(net.AddrUtilization) / 10,
-			PdUtilization:    float64(net.PdUtilization) / 10,
-			Stats:            net.Stats,
-			StatsCollectedAt: strfmt.DateTime(net.StatsCollectedAt),
+			ID:                 net.ID,
+			Name:               net.Name,
+			Subnets:            subnets,
+			AddrUtilization:    float64(net.AddrUtilization) / 10,
+			PdUtilization:      float64(net.PdUtilization) / 10,
+			Stats:              net.Stats,
+			StatsCollectedAt:   strfmt.DateTime(net.StatsCollectedAt),
+			AddrExhaustionDays: getExhaustionDays(net.AddrExhaustionAt),
+			PdExhaustionDays:   getExhaustionDays(net.PdExhaustionAt),
 		}
 		sharedNetworks.Items = append(sharedNetworks.Items, sharedNetwork)
 	}
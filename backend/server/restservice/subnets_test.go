@@ -974,6 +974,60 @@ func TestGetSubnet6MinimalParameters(t *testing.T) {
 	require.NotNil(t, ls.KeaConfigSubnetParameters)
 }
 
+// Test that the pool statistics are returned with the subnet.
+func TestGetSubnetPoolStats(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	fd := &storktest.FakeDispatcher{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil, fd, dbmodel.NewDHCPOptionDefinitionLookup())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	dhcp4, err := dbmodeltest.NewKeaDHCPv4Server(db)
+	require.NoError(t, err)
+	err = dhcp4.Configure(string(testutil.AllKeysDHCPv4JSON))
+	require.NoError(t, err)
+	app, err := dhcp4.GetKea()
+	require.NoError(t, err)
+	err = kea.CommitAppIntoDB(db, app, &storktest.FakeEventCenter{}, nil, dbmodel.NewDHCPOptionDefinitionLookup())
+	require.NoError(t, err)
+
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.0.0/8")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+	require.Len(t, subnets[0].LocalSubnets, 1)
+	require.Len(t, subnets[0].LocalSubnets[0].AddressPools, 2)
+
+	// Only the second pool has the statistics.
+	err = subnets[0].LocalSubnets[0].AddressPools[1].UpdateStats(db, dbmodel.SubnetStats{
+		"total-addresses":    uint64(200),
+		"assigned-addresses": uint64(200),
+	})
+	require.NoError(t, err)
+	err = dbmodel.SetExhaustionForecast(db, dbmodel.UtilizationObjectAddressPool, subnets[0].LocalSubnets[0].AddressPools[1].ID,
+		time.Now().Add(73*time.Hour), time.Time{})
+	require.NoError(t, err)
+
+	rsp := rapi.GetSubnet(ctx, dhcp.GetSubnetParams{
+		ID: subnets[0].ID,
+	})
+	require.IsType(t, &dhcp.GetSubnetOK{}, rsp)
+	subnet := rsp.(*dhcp.GetSubnetOK).Payload
+	require.Len(t, subnet.LocalSubnets, 1)
+	require.Len(t, subnet.LocalSubnets[0].PoolStats, 1)
+	poolStats := subnet.LocalSubnets[0].PoolStats[0]
+	require.Equal(t, "192.3.0.1-192.3.0.200", poolStats.Pool)
+	require.False(t, poolStats.PrefixDelegation)
+	require.EqualValues(t, 100, poolStats.Utilization)
+	require.NotZero(t, poolStats.StatsCollectedAt)
+	require.NotNil(t, poolStats.ExhaustionDays)
+	require.InDelta(t, 3, *poolStats.ExhaustionDays, 0.1)
+}
+
 // Test that the HTTP Not Found status is returned when getting a subnet which
 // doesn't exist.
 func TestGetSubnetNonExisting(t *testing.T) {
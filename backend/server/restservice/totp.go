@@ -0,0 +1,526 @@
+package restservice
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	qrcode "github.com/skip2/go-qrcode"
+
+	"isc.org/stork/server/auth"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/users"
+)
+
+// Issuer of the TOTP secrets displayed by the authenticator apps.
+const totpIssuer = "Stork"
+
+// Size of the QR code image in pixels.
+const totpQRCodeSize = 256
+
+// Checks if the internal user has to provide the second authentication
+// factor to log in. It returns true if the user has enrolled the TOTP or
+// any of the user groups requires it. The enroll value indicates that the
+// user is required to use the second factor but hasn't enrolled yet.
+func (r *RestAPI) isSecondFactorRequired(user *dbmodel.SystemUser) (required, enroll bool, err error) {
+	totp, err := dbmodel.GetUserTOTP(r.DB, user.ID)
+	if err != nil {
+		return false, false, err
+	}
+	if totp != nil && totp.Enabled {
+		return true, false, nil
+	}
+	required, err = dbmodel.IsUserTOTPRequired(r.DB, user.ID)
+	if err != nil {
+		return false, false, err
+	}
+	return required, required, nil
+}
+
+// Generates a new TOTP secret for the user and stores it in the database.
+// It returns the secret with its provisioning URI and QR code.
+func (r *RestAPI) beginTOTPEnrollment(user *dbmodel.SystemUser) (*models.TotpEnrollment, error) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err = dbmodel.SetUserTOTPSecret(r.DB, user.ID, secret); err != nil {
+		return nil, err
+	}
+
+	account := user.Login
+	if account == "" {
+		account = user.Email
+	}
+	uri := auth.GetTOTPProvisioningURI(totpIssuer, account, secret)
+	qrCode, err := qrcode.Encode(uri, qrcode.Medium, totpQRCodeSize)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot generate the TOTP secret QR code")
+	}
+	return &models.TotpEnrollment{
+		Secret:          secret,
+		ProvisioningURI: uri,
+		QrCode:          base64.StdEncoding.EncodeToString(qrCode),
+	}, nil
+}
+
+// Verifies the code confirming the TOTP enrollment. If the code is valid,
+// it enables the second factor and returns the new recovery codes. The
+// returned boolean value is false if the code is invalid.
+func (r *RestAPI) confirmTOTPEnrollment(totp *dbmodel.SystemUserTOTP, code string) ([]string, bool, error) {
+	step, ok, err := auth.ValidateTOTPCode(totp.Secret, code, time.Now(), 0)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	codes, hashes, err := dbmodel.GenerateRecoveryCodes(dbmodel.RecoveryCodesCount)
+	if err != nil {
+		return nil, false, err
+	}
+	if err = dbmodel.EnableUserTOTP(r.DB, totp.UserID, step, hashes); err != nil {
+		return nil, false, err
+	}
+	return codes, true, nil
+}
+
+// Verifies the TOTP or recovery code of the user logging in. Each code can
+// be used only once. The returned boolean value is false if the code is
+// invalid.
+func (r *RestAPI) verifySecondFactor(totp *dbmodel.SystemUserTOTP, verification *models.SecondFactorVerification) (bool, error) {
+	switch {
+	case verification.Code != "":
+		step, ok, err := auth.ValidateTOTPCode(totp.Secret, verification.Code, time.Now(), totp.LastUsedStep)
+		if err != nil || !ok {
+			return false, err
+		}
+		return dbmodel.UpdateUserTOTPLastUsedStep(r.DB, totp.UserID, step)
+	case verification.RecoveryCode != "":
+		ok, err := dbmodel.UseRecoveryCode(r.DB, totp.UserID, verification.RecoveryCode)
+		if ok {
+			log.WithField("userID", totp.UserID).Warn("User logged in with a recovery code")
+		}
+		return ok, err
+	default:
+		return false, nil
+	}
+}
+
+// Begins the TOTP enrollment of the user who has provided a valid password
+// but is required to use the second factor and hasn't enrolled yet.
+func (r *RestAPI) CreateSessionTotpEnrollment(ctx context.Context, params users.CreateSessionTotpEnrollmentParams) middleware.Responder {
+	userID, enroll, ok := r.SessionManager.GetPendingSecondFactor(ctx)
+	if !ok {
+		msg := "Login expired, please log in again"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpEnrollmentDefault(http.StatusUnauthorized).WithPayload(&rspErr)
+	}
+	if !enroll {
+		msg := "User has already enrolled the second factor"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpEnrollmentDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	user, err := dbmodel.GetUserByID(r.DB, userID)
+	if err == nil && user == nil {
+		err = errors.Errorf("user with ID %d does not exist", userID)
+	}
+	var enrollment *models.TotpEnrollment
+	if err == nil {
+		enrollment, err = r.beginTOTPEnrollment(user)
+	}
+	if err != nil {
+		log.WithField("userID", userID).WithError(err).Error("Failed to begin TOTP enrollment")
+
+		msg := "Failed to begin the second factor enrollment"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpEnrollmentDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	return users.NewCreateSessionTotpEnrollmentOK().WithPayload(enrollment)
+}
+
+// Completes the login of the user who has provided a valid password by
+// verifying the TOTP or recovery code. If the user has begun the enrollment
+// while logging in, the code confirms the enrollment and the recovery codes
+// are returned.
+func (r *RestAPI) CreateSessionTotp(ctx context.Context, params users.CreateSessionTotpParams) middleware.Responder {
+	userID, enroll, ok := r.SessionManager.GetPendingSecondFactor(ctx)
+	if !ok {
+		msg := "Login expired, please log in again"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpDefault(http.StatusUnauthorized).WithPayload(&rspErr)
+	}
+
+	// The user may have been locked out by the failed attempts in other
+	// sessions.
+	now := time.Now().UTC()
+	locked, err := r.isLockedOut(userID, now)
+	if err != nil {
+		log.WithField("userID", userID).WithError(err).Error("Failed to get lockout of the user")
+
+		msg := "Failed to verify the second factor"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if locked {
+		r.SessionManager.ClearPendingSecondFactor(ctx)
+		msg := "Account is temporarily locked due to too many failed login attempts"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpDefault(http.StatusUnauthorized).WithPayload(&rspErr)
+	}
+
+	verification := params.Verification
+	if verification == nil {
+		verification = &models.SecondFactorVerification{}
+	}
+
+	totp, err := dbmodel.GetUserTOTP(r.DB, userID)
+	if err != nil {
+		log.WithField("userID", userID).WithError(err).Error("Failed to get TOTP of the user")
+
+		msg := "Failed to verify the second factor"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if totp == nil || totp.Enabled == enroll {
+		msg := "Second factor enrollment has not begun"
+		if !enroll {
+			msg = "User has not enrolled the second factor"
+		}
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	var recoveryCodes []string
+	if enroll {
+		recoveryCodes, ok, err = r.confirmTOTPEnrollment(totp, verification.Code)
+	} else {
+		ok, err = r.verifySecondFactor(totp, verification)
+	}
+	if err != nil {
+		log.WithField("userID", userID).WithError(err).Error("Failed to verify the second factor")
+
+		msg := "Failed to verify the second factor"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if !ok {
+		// The invalid codes count towards the lockout like the invalid
+		// passwords.
+		policy, err := auth.GetPasswordPolicy(r.DB)
+		if err == nil {
+			locked = r.recordFailedLogin(userID, policy, now)
+		} else {
+			log.WithField("userID", userID).WithError(err).Error("Failed to get the password policy")
+		}
+		remaining := r.SessionManager.FailPendingSecondFactor(ctx)
+		log.WithField("userID", userID).Warn("Invalid second factor code")
+
+		msg := fmt.Sprintf("Invalid code, %d attempts left", remaining)
+		switch {
+		case locked:
+			r.SessionManager.ClearPendingSecondFactor(ctx)
+			msg = "Account is temporarily locked due to too many failed login attempts"
+		case remaining == 0:
+			msg = "Invalid code, please log in again"
+		}
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpDefault(http.StatusUnauthorized).WithPayload(&rspErr)
+	}
+
+	var rspUser *models.User
+	user, err := dbmodel.GetUserByID(r.DB, userID)
+	if err == nil && user == nil {
+		err = errors.Errorf("user with ID %d does not exist", userID)
+	}
+	if err == nil {
+		// The complete login resets the failed attempts.
+		err = dbmodel.DeleteUserLockout(r.DB, userID)
+	}
+	if err == nil {
+		r.SessionManager.ClearPendingSecondFactor(ctx)
+		rspUser, err = r.loginUser(ctx, user)
+	}
+	if err != nil {
+		log.WithField("userID", userID).WithError(err).Error("Cannot log in a user")
+
+		msg := "Cannot log in the user"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateSessionTotpDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	if enroll {
+		r.recordAudit(params.HTTPRequest, dbmodel.AuditActionCreate, dbmodel.AuditObjectTOTP, fmt.Sprint(user.ID), user.Identity(),
+			nil, nil)
+	}
+	return users.NewCreateSessionTotpOK().WithPayload(&models.SecondFactorLogin{
+		User:          rspUser,
+		RecoveryCodes: recoveryCodes,
+	})
+}
+
+// Fetches the user whose second factor is managed and checks that it is
+// an internal user. It returns the HTTP status code and the error message
+// if the user cannot be managed.
+func (r *RestAPI) getTOTPUser(id int64) (*dbmodel.SystemUser, int, string) {
+	user, err := dbmodel.GetUserByID(r.DB, int(id))
+	if err != nil {
+		log.WithField("userID", id).WithError(err).Error("Failed to get user from the database")
+		return nil, http.StatusInternalServerError, fmt.Sprintf("Failed to get user with ID %d from the database", id)
+	}
+	if user == nil {
+		return nil, http.StatusNotFound, fmt.Sprintf("Failed to find user with ID %d in the database", id)
+	}
+	if user.AuthenticationMethodID != dbmodel.AuthenticationMethodIDInternal {
+		return nil, http.StatusBadRequest, "Second factor is available only for the internal users"
+	}
+	return user, 0, ""
+}
+
+// Checks if the user with the specified ID is logged in the current session.
+func (r *RestAPI) isLoggedUser(ctx context.Context, id int64) bool {
+	ok, user := r.SessionManager.Logged(ctx)
+	return ok && int64(user.ID) == id
+}
+
+// Returns the TOTP second factor status of the user.
+func (r *RestAPI) GetUserTotp(ctx context.Context, params users.GetUserTotpParams) middleware.Responder {
+	status := &models.TotpStatus{}
+	totp, err := dbmodel.GetUserTOTP(r.DB, int(params.ID))
+	if err == nil {
+		status.Enabled = totp != nil && totp.Enabled
+		status.Required, err = dbmodel.IsUserTOTPRequired(r.DB, int(params.ID))
+	}
+	if err == nil && status.Enabled {
+		status.RecoveryCodesRemaining, err = dbmodel.GetUnusedRecoveryCodesCount(r.DB, int(params.ID))
+	}
+	if err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to get TOTP status of the user")
+
+		msg := fmt.Sprintf("Failed to get second factor status of user with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewGetUserTotpDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	return users.NewGetUserTotpOK().WithPayload(status)
+}
+
+// Begins the TOTP enrollment of the logged user. The users can enroll only
+// their own second factor. The enrolled second factor has to be reset
+// before enrolling again.
+func (r *RestAPI) CreateUserTotp(ctx context.Context, params users.CreateUserTotpParams) middleware.Responder {
+	if !r.isLoggedUser(ctx, params.ID) {
+		msg := "Users can enroll only their own second factor"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserTotpDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+	user, code, msg := r.getTOTPUser(params.ID)
+	if user == nil {
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserTotpDefault(code).WithPayload(&rspErr)
+	}
+
+	totp, err := dbmodel.GetUserTOTP(r.DB, user.ID)
+	if err == nil && totp != nil && totp.Enabled {
+		msg := "Second factor has been already enrolled"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserTotpDefault(http.StatusConflict).WithPayload(&rspErr)
+	}
+	var enrollment *models.TotpEnrollment
+	if err == nil {
+		enrollment, err = r.beginTOTPEnrollment(user)
+	}
+	if err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to begin TOTP enrollment")
+
+		msg := "Failed to begin the second factor enrollment"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserTotpDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	return users.NewCreateUserTotpOK().WithPayload(enrollment)
+}
+
+// Confirms the TOTP enrollment of the logged user with the code generated
+// by the authenticator app. It returns the new recovery codes.
+func (r *RestAPI) UpdateUserTotp(ctx context.Context, params users.UpdateUserTotpParams) middleware.Responder {
+	if !r.isLoggedUser(ctx, params.ID) {
+		msg := "Users can enroll only their own second factor"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserTotpDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+	if params.Code == nil || params.Code.Code == nil {
+		msg := "Missing code"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserTotpDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	totp, err := dbmodel.GetUserTOTP(r.DB, int(params.ID))
+	if err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to get TOTP of the user")
+
+		msg := "Failed to confirm the second factor enrollment"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserTotpDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if totp == nil || totp.Enabled {
+		msg := "Second factor enrollment has not begun"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserTotpDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	codes, ok, err := r.confirmTOTPEnrollment(totp, *params.Code.Code)
+	if err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to confirm TOTP enrollment")
+
+		msg := "Failed to confirm the second factor enrollment"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserTotpDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if !ok {
+		msg := "Invalid code"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserTotpDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	_, user := r.SessionManager.Logged(ctx)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionCreate, dbmodel.AuditObjectTOTP, fmt.Sprint(params.ID), user.Identity(),
+		nil, nil)
+	return users.NewUpdateUserTotpOK().WithPayload(&models.RecoveryCodes{
+		Items: codes,
+	})
+}
+
+// Resets the TOTP second factor of the user. The administrators can reset
+// the second factor of other users, e.g. when they lose their
+// authenticators. The users cannot reset their own second factor if their
+// groups require it.
+func (r *RestAPI) DeleteUserTotp(ctx context.Context, params users.DeleteUserTotpParams) middleware.Responder {
+	user, code, msg := r.getTOTPUser(params.ID)
+	if user == nil {
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteUserTotpDefault(code).WithPayload(&rspErr)
+	}
+
+	if r.isLoggedUser(ctx, params.ID) {
+		required, err := dbmodel.IsUserTOTPRequired(r.DB, int(params.ID))
+		if err != nil {
+			log.WithField("userID", params.ID).WithError(err).Error("Failed to check if TOTP is required")
+
+			msg := fmt.Sprintf("Failed to reset second factor of user with ID %d", params.ID)
+			rspErr := models.APIError{
+				Message: &msg,
+			}
+			return users.NewDeleteUserTotpDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+		}
+		if required {
+			msg := "Second factor is required by the user groups and cannot be disabled"
+			rspErr := models.APIError{
+				Message: &msg,
+			}
+			return users.NewDeleteUserTotpDefault(http.StatusForbidden).WithPayload(&rspErr)
+		}
+	}
+
+	if err := dbmodel.DeleteUserTOTP(r.DB, int(params.ID)); err != nil {
+		log.WithField("userID", params.ID).WithError(err).Error("Failed to reset TOTP of the user")
+
+		msg := fmt.Sprintf("Failed to reset second factor of user with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteUserTotpDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectTOTP, fmt.Sprint(params.ID), user.Identity(),
+		nil, nil)
+	return users.NewDeleteUserTotpOK()
+}
+
+// Sets the flag indicating if the members of the group are required to use
+// the TOTP second factor.
+func (r *RestAPI) UpdateGroupTotpRequirement(ctx context.Context, params users.UpdateGroupTotpRequirementParams) middleware.Responder {
+	if params.Requirement == nil || params.Requirement.Required == nil {
+		msg := "Missing TOTP requirement"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupTotpRequirementDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	before := r.getAuditGroup(int(params.ID))
+	err := dbmodel.SetGroupTOTPRequired(r.DB, int(params.ID), *params.Requirement.Required)
+	var group *dbmodel.SystemGroup
+	if err == nil {
+		group, err = dbmodel.GetGroupByID(r.DB, int(params.ID))
+	}
+	switch {
+	case errors.Is(err, dbmodel.ErrNotExists) || (err == nil && group == nil):
+		msg := fmt.Sprintf("Failed to find group with ID %d in the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupTotpRequirementDefault(http.StatusNotFound).WithPayload(&rspErr)
+	case err != nil:
+		log.WithField("groupID", params.ID).WithError(err).Error("Failed to update TOTP requirement of the group")
+
+		msg := fmt.Sprintf("Failed to update group with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupTotpRequirementDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	restGroup := newRestGroup(*group)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectGroup, fmt.Sprint(group.ID), group.Name,
+		before, restGroup)
+	return users.NewUpdateGroupTotpRequirementOK().WithPayload(restGroup)
+}
@@ -0,0 +1,474 @@
+package restservice
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"isc.org/stork/server/auth"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/users"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Creates an internal user with the password "pass" and the TOTP secret.
+// The TOTP is enabled if the enabled flag is set. It returns the user and
+// the TOTP secret.
+func addTOTPUser(t *testing.T, db *dbops.PgDB, enabled bool, groups ...*dbmodel.SystemGroup) (*dbmodel.SystemUser, string) {
+	user := &dbmodel.SystemUser{
+		Login:    "alice",
+		Lastname: "Smith",
+		Name:     "Alice",
+		Groups:   groups,
+	}
+	_, err := dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	secret, err := auth.GenerateTOTPSecret()
+	require.NoError(t, err)
+	err = dbmodel.SetUserTOTPSecret(db, user.ID, secret)
+	require.NoError(t, err)
+	if enabled {
+		err = dbmodel.EnableUserTOTP(db, user.ID, 0, nil)
+		require.NoError(t, err)
+	}
+	return user, secret
+}
+
+// Returns the TOTP code for the current time.
+func getCurrentTOTPCode(t *testing.T, secret string) string {
+	code, err := auth.ComputeTOTPCode(secret, auth.GetTOTPStep(time.Now()))
+	require.NoError(t, err)
+	return code
+}
+
+// Returns the login parameters of the test user.
+func getTOTPUserCredentials() users.CreateSessionParams {
+	login := "alice"
+	password := "pass"
+	return users.CreateSessionParams{
+		Credentials: &models.SessionCredentials{
+			Identifier: &login,
+			Secret:     &password,
+		},
+	}
+}
+
+// Test that the user with the enrolled TOTP has to provide a valid code
+// to log in.
+func TestCreateSessionWithTOTP(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	user, secret := addTOTPUser(t, db, true)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Act & Assert
+	rsp := rapi.CreateSession(ctx, getTOTPUserCredentials())
+	require.IsType(t, &users.CreateSessionAccepted{}, rsp)
+	require.False(t, rsp.(*users.CreateSessionAccepted).Payload.EnrollmentRequired)
+
+	// The user is not logged in until the code is verified.
+	logged, _ := rapi.SessionManager.Logged(ctx)
+	require.False(t, logged)
+
+	// The user has already enrolled.
+	rsp = rapi.CreateSessionTotpEnrollment(ctx, users.CreateSessionTotpEnrollmentParams{})
+	require.IsType(t, &users.CreateSessionTotpEnrollmentDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*users.CreateSessionTotpEnrollmentDefault)))
+
+	// Invalid code.
+	rsp = rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+		Verification: &models.SecondFactorVerification{Code: "abcdef"},
+	})
+	require.IsType(t, &users.CreateSessionTotpDefault{}, rsp)
+	require.Equal(t, http.StatusUnauthorized, getStatusCode(*rsp.(*users.CreateSessionTotpDefault)))
+	logged, _ = rapi.SessionManager.Logged(ctx)
+	require.False(t, logged)
+
+	// Valid code.
+	code := getCurrentTOTPCode(t, secret)
+	rsp = rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+		Verification: &models.SecondFactorVerification{Code: code},
+	})
+	require.IsType(t, &users.CreateSessionTotpOK{}, rsp)
+	okRsp := rsp.(*users.CreateSessionTotpOK)
+	require.EqualValues(t, user.ID, *okRsp.Payload.User.ID)
+	require.Empty(t, okRsp.Payload.RecoveryCodes)
+
+	logged, loggedUser := rapi.SessionManager.Logged(ctx)
+	require.True(t, logged)
+	require.Equal(t, user.ID, loggedUser.ID)
+
+	// The code cannot be reused.
+	ctx, err = rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	rsp = rapi.CreateSession(ctx, getTOTPUserCredentials())
+	require.IsType(t, &users.CreateSessionAccepted{}, rsp)
+	rsp = rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+		Verification: &models.SecondFactorVerification{Code: code},
+	})
+	require.IsType(t, &users.CreateSessionTotpDefault{}, rsp)
+}
+
+// Test that the user can log in with a recovery code only once.
+func TestCreateSessionWithRecoveryCode(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	user, _ := addTOTPUser(t, db, true)
+	codes, hashes, err := dbmodel.GenerateRecoveryCodes(2)
+	require.NoError(t, err)
+	err = dbmodel.EnableUserTOTP(db, user.ID, 0, hashes)
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		ctx, err := rapi.SessionManager.Load(context.Background(), "")
+		require.NoError(t, err)
+
+		// Act
+		rsp := rapi.CreateSession(ctx, getTOTPUserCredentials())
+		require.IsType(t, &users.CreateSessionAccepted{}, rsp)
+		rsp = rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+			Verification: &models.SecondFactorVerification{RecoveryCode: codes[0]},
+		})
+
+		// Assert
+		if i == 0 {
+			require.IsType(t, &users.CreateSessionTotpOK{}, rsp)
+		} else {
+			require.IsType(t, &users.CreateSessionTotpDefault{}, rsp)
+		}
+	}
+}
+
+// Test that the pending login is rejected after too many invalid codes.
+func TestCreateSessionWithTOTPTooManyAttempts(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	_, secret := addTOTPUser(t, db, true)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	rsp := rapi.CreateSession(ctx, getTOTPUserCredentials())
+	require.IsType(t, &users.CreateSessionAccepted{}, rsp)
+
+	// Act
+	for i := 0; i < 5; i++ {
+		rsp = rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+			Verification: &models.SecondFactorVerification{RecoveryCode: "aaaaa-bbbbb"},
+		})
+		require.IsType(t, &users.CreateSessionTotpDefault{}, rsp)
+	}
+	rsp = rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+		Verification: &models.SecondFactorVerification{Code: getCurrentTOTPCode(t, secret)},
+	})
+
+	// Assert
+	require.IsType(t, &users.CreateSessionTotpDefault{}, rsp)
+	require.Equal(t, http.StatusUnauthorized, getStatusCode(*rsp.(*users.CreateSessionTotpDefault)))
+}
+
+// Test that the invalid second factor codes count towards the lockout and
+// the failed attempts are reset only after the complete login.
+func TestCreateSessionWithTOTPLockout(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	require.NoError(t, dbmodel.SetSettingInt(db, "account_lockout_threshold", 3))
+
+	fec := &storktest.FakeEventCenter{}
+	rapi, _ := NewRestAPI(dbSettings, db, fec)
+	user, secret := addTOTPUser(t, db, true)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Act & Assert
+	for i := 0; i < 2; i++ {
+		rsp := rapi.CreateSession(ctx, getCredentials("alice", "bad pass"))
+		require.IsType(t, &users.CreateSessionBadRequest{}, rsp)
+	}
+
+	// The valid password doesn't reset the failed attempts because the
+	// second factor is still missing.
+	rsp := rapi.CreateSession(ctx, getTOTPUserCredentials())
+	require.IsType(t, &users.CreateSessionAccepted{}, rsp)
+	lockout, err := dbmodel.GetUserLockout(db, user.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, lockout.FailedAttempts)
+
+	// The invalid code locks the user out.
+	rsp = rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+		Verification: &models.SecondFactorVerification{Code: "abcdef"},
+	})
+	require.IsType(t, &users.CreateSessionTotpDefault{}, rsp)
+	defaultRsp := rsp.(*users.CreateSessionTotpDefault)
+	require.Equal(t, http.StatusUnauthorized, getStatusCode(*defaultRsp))
+	require.Contains(t, *defaultRsp.Payload.Message, "locked")
+	require.Len(t, fec.Events, 1)
+	require.Contains(t, fec.Events[0].Text, "has been locked out")
+
+	// The pending login is canceled.
+	rsp = rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+		Verification: &models.SecondFactorVerification{Code: getCurrentTOTPCode(t, secret)},
+	})
+	require.IsType(t, &users.CreateSessionTotpDefault{}, rsp)
+	logged, _ := rapi.SessionManager.Logged(ctx)
+	require.False(t, logged)
+
+	// The valid password is rejected while the user is locked out.
+	rsp = rapi.CreateSession(ctx, getTOTPUserCredentials())
+	require.IsType(t, &users.CreateSessionBadRequest{}, rsp)
+
+	// The complete login after unlocking resets the failed attempts.
+	require.NoError(t, dbmodel.DeleteUserLockout(db, user.ID))
+	rsp = rapi.CreateSession(ctx, getCredentials("alice", "bad pass"))
+	require.IsType(t, &users.CreateSessionBadRequest{}, rsp)
+	rsp = rapi.CreateSession(ctx, getTOTPUserCredentials())
+	require.IsType(t, &users.CreateSessionAccepted{}, rsp)
+	rsp = rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+		Verification: &models.SecondFactorVerification{Code: getCurrentTOTPCode(t, secret)},
+	})
+	require.IsType(t, &users.CreateSessionTotpOK{}, rsp)
+	lockout, err = dbmodel.GetUserLockout(db, user.ID)
+	require.NoError(t, err)
+	require.Nil(t, lockout)
+}
+
+// Test that the user required to use the second factor enrolls while
+// logging in.
+func TestCreateSessionWithTOTPEnrollment(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	err := dbmodel.SetGroupTOTPRequired(db, dbmodel.AdminGroupID, true)
+	require.NoError(t, err)
+	user := &dbmodel.SystemUser{
+		Login:    "alice",
+		Lastname: "Smith",
+		Name:     "Alice",
+		Groups:   []*dbmodel.SystemGroup{{ID: dbmodel.AdminGroupID}},
+	}
+	_, err = dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Act & Assert
+	rsp := rapi.CreateSession(ctx, getTOTPUserCredentials())
+	require.IsType(t, &users.CreateSessionAccepted{}, rsp)
+	require.True(t, rsp.(*users.CreateSessionAccepted).Payload.EnrollmentRequired)
+
+	rsp = rapi.CreateSessionTotpEnrollment(ctx, users.CreateSessionTotpEnrollmentParams{})
+	require.IsType(t, &users.CreateSessionTotpEnrollmentOK{}, rsp)
+	enrollment := rsp.(*users.CreateSessionTotpEnrollmentOK).Payload
+	require.NotEmpty(t, enrollment.Secret)
+	require.Contains(t, enrollment.ProvisioningURI, enrollment.Secret)
+	qrCode, err := base64.StdEncoding.DecodeString(enrollment.QrCode)
+	require.NoError(t, err)
+	require.Equal(t, "\x89PNG", string(qrCode[:4]))
+
+	rsp = rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+		Verification: &models.SecondFactorVerification{Code: getCurrentTOTPCode(t, enrollment.Secret)},
+	})
+	require.IsType(t, &users.CreateSessionTotpOK{}, rsp)
+	require.Len(t, rsp.(*users.CreateSessionTotpOK).Payload.RecoveryCodes, dbmodel.RecoveryCodesCount)
+
+	logged, _ := rapi.SessionManager.Logged(ctx)
+	require.True(t, logged)
+
+	totp, err := dbmodel.GetUserTOTP(db, user.ID)
+	require.NoError(t, err)
+	require.True(t, totp.Enabled)
+}
+
+// Test that the second factor is not verified without the password.
+func TestCreateSessionTotpNotPending(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	_, secret := addTOTPUser(t, db, true)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Act
+	rsp := rapi.CreateSessionTotp(ctx, users.CreateSessionTotpParams{
+		Verification: &models.SecondFactorVerification{Code: getCurrentTOTPCode(t, secret)},
+	})
+
+	// Assert
+	require.IsType(t, &users.CreateSessionTotpDefault{}, rsp)
+	require.Equal(t, http.StatusUnauthorized, getStatusCode(*rsp.(*users.CreateSessionTotpDefault)))
+}
+
+// Test that the logged user can enroll, check the status and disable the
+// second factor.
+func TestUserTotp(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	user := &dbmodel.SystemUser{
+		Login:    "alice",
+		Lastname: "Smith",
+		Name:     "Alice",
+	}
+	_, err := dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	ctx, _ := rapi.SessionManager.Load(context.Background(), "")
+	_ = rapi.SessionManager.LoginHandler(ctx, user)
+	id := int64(user.ID)
+
+	// Act & Assert
+	rsp := rapi.GetUserTotp(ctx, users.GetUserTotpParams{ID: id})
+	require.IsType(t, &users.GetUserTotpOK{}, rsp)
+	require.False(t, rsp.(*users.GetUserTotpOK).Payload.Enabled)
+
+	rsp = rapi.CreateUserTotp(ctx, users.CreateUserTotpParams{ID: id})
+	require.IsType(t, &users.CreateUserTotpOK{}, rsp)
+	secret := rsp.(*users.CreateUserTotpOK).Payload.Secret
+
+	// Invalid code.
+	invalid := "12345"
+	rsp = rapi.UpdateUserTotp(ctx, users.UpdateUserTotpParams{ID: id, Code: &models.TotpCode{Code: &invalid}})
+	require.IsType(t, &users.UpdateUserTotpDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*users.UpdateUserTotpDefault)))
+
+	// Valid code.
+	code := getCurrentTOTPCode(t, secret)
+	rsp = rapi.UpdateUserTotp(ctx, users.UpdateUserTotpParams{ID: id, Code: &models.TotpCode{Code: &code}})
+	require.IsType(t, &users.UpdateUserTotpOK{}, rsp)
+	require.Len(t, rsp.(*users.UpdateUserTotpOK).Payload.Items, dbmodel.RecoveryCodesCount)
+
+	rsp = rapi.GetUserTotp(ctx, users.GetUserTotpParams{ID: id})
+	require.IsType(t, &users.GetUserTotpOK{}, rsp)
+	status := rsp.(*users.GetUserTotpOK).Payload
+	require.True(t, status.Enabled)
+	require.False(t, status.Required)
+	require.EqualValues(t, dbmodel.RecoveryCodesCount, status.RecoveryCodesRemaining)
+
+	// The second factor has to be reset before enrolling again.
+	rsp = rapi.CreateUserTotp(ctx, users.CreateUserTotpParams{ID: id})
+	require.IsType(t, &users.CreateUserTotpDefault{}, rsp)
+	require.Equal(t, http.StatusConflict, getStatusCode(*rsp.(*users.CreateUserTotpDefault)))
+
+	rsp = rapi.DeleteUserTotp(ctx, users.DeleteUserTotpParams{ID: id})
+	require.IsType(t, &users.DeleteUserTotpOK{}, rsp)
+
+	rsp = rapi.GetUserTotp(ctx, users.GetUserTotpParams{ID: id})
+	require.IsType(t, &users.GetUserTotpOK{}, rsp)
+	require.False(t, rsp.(*users.GetUserTotpOK).Payload.Enabled)
+
+	// The enrollment and reset are recorded in the audit log.
+	entries, total, err := dbmodel.GetAuditEntriesByPage(db, 0, 10, nil, "", dbmodel.SortDirAsc)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Equal(t, dbmodel.AuditActionCreate, entries[0].Action)
+	require.Equal(t, dbmodel.AuditObjectTOTP, entries[0].ObjectType)
+	require.Equal(t, dbmodel.AuditActionDelete, entries[1].Action)
+}
+
+// Test that the users cannot enroll the second factor of other users.
+func TestCreateUserTotpOtherUser(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	user, _ := addTOTPUser(t, db, false)
+
+	ctx, _ := rapi.SessionManager.Load(context.Background(), "")
+	_ = rapi.SessionManager.LoginHandler(ctx, &dbmodel.SystemUser{ID: 1})
+
+	// Act
+	rsp := rapi.CreateUserTotp(ctx, users.CreateUserTotpParams{ID: int64(user.ID)})
+
+	// Assert
+	require.IsType(t, &users.CreateUserTotpDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.CreateUserTotpDefault)))
+}
+
+// Test that the users cannot disable the second factor required by their
+// groups but the administrators can reset it.
+func TestDeleteUserTotpRequired(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	err := dbmodel.SetGroupTOTPRequired(db, dbmodel.AdminGroupID, true)
+	require.NoError(t, err)
+	user, _ := addTOTPUser(t, db, true, &dbmodel.SystemGroup{ID: dbmodel.AdminGroupID})
+	id := int64(user.ID)
+
+	// Act & Assert
+	ctx, _ := rapi.SessionManager.Load(context.Background(), "")
+	_ = rapi.SessionManager.LoginHandler(ctx, user)
+	rsp := rapi.DeleteUserTotp(ctx, users.DeleteUserTotpParams{ID: id})
+	require.IsType(t, &users.DeleteUserTotpDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.DeleteUserTotpDefault)))
+
+	// The super-admin resets the second factor.
+	ctx, _ = rapi.SessionManager.Load(context.Background(), "")
+	_ = rapi.SessionManager.LoginHandler(ctx, &dbmodel.SystemUser{ID: 1})
+	rsp = rapi.DeleteUserTotp(ctx, users.DeleteUserTotpParams{ID: id})
+	require.IsType(t, &users.DeleteUserTotpOK{}, rsp)
+
+	totp, err := dbmodel.GetUserTOTP(db, user.ID)
+	require.NoError(t, err)
+	require.Nil(t, totp)
+}
+
+// Test setting the TOTP requirement of the group.
+func TestUpdateGroupTotpRequirement(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	ctx := context.Background()
+	required := true
+
+	// Act
+	rsp := rapi.UpdateGroupTotpRequirement(ctx, users.UpdateGroupTotpRequirementParams{
+		ID:          dbmodel.AdminGroupID,
+		Requirement: &models.GroupTotpRequirement{Required: &required},
+	})
+
+	// Assert
+	require.IsType(t, &users.UpdateGroupTotpRequirementOK{}, rsp)
+	require.True(t, rsp.(*users.UpdateGroupTotpRequirementOK).Payload.TotpRequired)
+
+	rsp = rapi.UpdateGroupTotpRequirement(ctx, users.UpdateGroupTotpRequirementParams{
+		ID:          12345,
+		Requirement: &models.GroupTotpRequirement{Required: &required},
+	})
+	require.IsType(t, &users.UpdateGroupTotpRequirementDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*users.UpdateGroupTotpRequirementDefault)))
+}
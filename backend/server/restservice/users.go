@@ -4,13 +4,16 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strings"
+	"time"
 
 	"github.com/go-openapi/runtime/middleware"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
 	"isc.org/stork/hooks/server/authenticationcallouts"
+	"isc.org/stork/server/auth"
 	dbmodel "isc.org/stork/server/database/model"
 	"isc.org/stork/server/gen/models"
 	"isc.org/stork/server/gen/restapi/operations/users"
@@ -41,21 +44,151 @@ func newRestUser(u dbmodel.SystemUser) *models.User {
 	return r
 }
 
+// User fields recorded in the audit log. The password itself is never
+// recorded. The non-empty value only marks that it has been changed.
+type auditUser struct {
+	*models.User
+	Password string `json:"password,omitempty"`
+	Locked   bool   `json:"locked,omitempty"`
+}
+
+// Returns the current state of the user recorded in the audit log. It
+// returns nil if the user cannot be fetched.
+func (r *RestAPI) getAuditUser(id int) *auditUser {
+	su, err := dbmodel.GetUserByID(r.DB, id)
+	if err != nil || su == nil {
+		log.WithField("userID", id).WithError(err).Warn("Failed to fetch user for the audit log")
+		return nil
+	}
+	return &auditUser{User: newRestUser(*su)}
+}
+
+// Returns the current state of the group recorded in the audit log. It
+// returns nil if the group cannot be fetched.
+func (r *RestAPI) getAuditGroup(id int) *models.Group {
+	group, err := dbmodel.GetGroupByID(r.DB, id)
+	if err != nil || group == nil {
+		log.WithField("groupID", id).WithError(err).Warn("Failed to fetch group for the audit log")
+		return nil
+	}
+	return newRestGroup(*group)
+}
+
 // Create new instance of the group model used by REST API from the
 // group instance returned from the database.
 func newRestGroup(g dbmodel.SystemGroup) *models.Group {
 	id := int64(g.ID)
 	r := &models.Group{
-		ID:          &id,
-		Name:        &g.Name,
-		Description: &g.Description,
+		ID:           &id,
+		Name:         &g.Name,
+		Description:  &g.Description,
+		Permissions:  []*models.GroupPermission{},
+		TotpRequired: g.TOTPRequired,
+	}
+	for _, p := range g.Permissions {
+		r.Permissions = append(r.Permissions, newRestPermission(*p))
 	}
 
 	return r
 }
 
+// Converts the group received over the REST API to the database model.
+// It returns an error if the group lacks the name or any of the
+// permissions is invalid.
+func newDBGroup(g *models.Group) (*dbmodel.SystemGroup, error) {
+	if g == nil || g.Name == nil || len(strings.TrimSpace(*g.Name)) == 0 {
+		return nil, errors.New("missing group name")
+	}
+	group := &dbmodel.SystemGroup{
+		Name: strings.TrimSpace(*g.Name),
+	}
+	if g.Description != nil {
+		group.Description = *g.Description
+	}
+	for _, p := range g.Permissions {
+		permission, err := newDBPermission(p)
+		if err != nil {
+			return nil, err
+		}
+		group.Permissions = append(group.Permissions, permission)
+	}
+	return group, nil
+}
+
+// Creates new instance of the permission model used by REST API from the
+// permission instance returned from the database.
+func newRestPermission(p dbmodel.SystemGroupPermission) *models.GroupPermission {
+	action := string(p.Action)
+	return &models.GroupPermission{
+		Resource:  &p.Resource,
+		Action:    &action,
+		ScopeType: string(p.ScopeType),
+		ScopeID:   p.ScopeID,
+	}
+}
+
+// Converts the permission received over the REST API to the database
+// model. It returns an error if the permission is invalid.
+func newDBPermission(p *models.GroupPermission) (*dbmodel.SystemGroupPermission, error) {
+	if p == nil || p.Resource == nil || p.Action == nil {
+		return nil, errors.New("missing permission resource or action")
+	}
+	permission := &dbmodel.SystemGroupPermission{
+		Resource:  *p.Resource,
+		Action:    dbmodel.PermissionAction(*p.Action),
+		ScopeType: dbmodel.PermissionScopeType(p.ScopeType),
+		ScopeID:   p.ScopeID,
+	}
+	if err := permission.Validate(); err != nil {
+		return nil, err
+	}
+	return permission, nil
+}
+
+// Error returned when the user cannot log in because of too many failed
+// login attempts.
+var errUserLockedOut = errors.New("user is locked out due to too many failed login attempts")
+
+// Records the failed login attempt of the user and locks the user out when
+// the number of the failed attempts reaches the threshold specified in the
+// password policy. The failed attempts include the invalid passwords and
+// the invalid second factor codes. The lockout is reported as an event.
+// It returns true if the user has been locked out.
+func (r *RestAPI) recordFailedLogin(userID int, policy *auth.PasswordPolicy, now time.Time) bool {
+	lockout, err := dbmodel.RecordFailedLogin(r.DB, userID, policy.LockoutThreshold, policy.LockoutDuration, now)
+	if err != nil {
+		log.WithField("userID", userID).WithError(err).Error("Failed to record the failed login attempt")
+		return false
+	}
+	if !lockout.IsLocked(now) {
+		return false
+	}
+	log.WithField("userID", userID).Warnf("User locked out until %s", lockout.LockedUntil.Format(time.RFC3339))
+	user, err := dbmodel.GetUserByID(r.DB, userID)
+	if err != nil || user == nil {
+		log.WithField("userID", userID).WithError(err).Error("Failed to get the locked out user from the database")
+		return true
+	}
+	if r.EventCenter != nil {
+		r.EventCenter.AddWarningEvent(fmt.Sprintf("{user} has been locked out until %s after %d failed login attempts",
+			lockout.LockedUntil.Format(time.RFC3339), policy.LockoutThreshold), user)
+	}
+	return true
+}
+
+// Checks if the user is locked out due to too many failed login attempts.
+func (r *RestAPI) isLockedOut(userID int, now time.Time) (bool, error) {
+	lockout, err := dbmodel.GetUserLockout(r.DB, userID)
+	if err != nil {
+		return false, err
+	}
+	return lockout.IsLocked(now), nil
+}
+
 // The internal authentication flow based on the login and password stored in
-// the database.
+// the database. The user is locked out after too many failed attempts. The
+// failed attempts are reset after the complete login, i.e. after the second
+// factor is verified if the user is required to provide it.
 func (r *RestAPI) internalAuthentication(params users.CreateSessionParams) (*dbmodel.SystemUser, error) {
 	user := &dbmodel.SystemUser{}
 	var identifier, secret string
@@ -74,11 +207,178 @@ func (r *RestAPI) internalAuthentication(params users.CreateSessionParams) (*dbm
 		secret = *params.Credentials.Secret
 	}
 
+	policy, err := auth.GetPasswordPolicy(r.DB)
+	if err != nil {
+		return nil, err
+	}
+
+	// Reject the locked out user without checking the password.
+	now := time.Now().UTC()
+	userID, err := dbmodel.GetPasswordUserID(r.DB, user.Login, user.Email)
+	if err != nil {
+		return nil, err
+	}
+	if userID != 0 {
+		locked, err := r.isLockedOut(userID, now)
+		if err != nil {
+			return nil, err
+		}
+		if locked {
+			return nil, errUserLockedOut
+		}
+	}
+
 	ok, err := dbmodel.Authenticate(r.DB, user, secret)
 	if !ok {
+		if err == nil && userID != 0 {
+			r.recordFailedLogin(userID, policy, now)
+		}
 		return nil, err
 	}
-	return user, err
+	return user, nil
+}
+
+// Checks if the user has to change the password on login because it has
+// been set by the administrator or it has expired.
+func (r *RestAPI) isPasswordChangeRequired(user *dbmodel.SystemUser) (bool, error) {
+	if user.AuthenticationMethodID != dbmodel.AuthenticationMethodIDInternal {
+		return false, nil
+	}
+	info, err := dbmodel.GetUserPasswordInfo(r.DB, user.ID)
+	if err != nil || info == nil {
+		return false, err
+	}
+	if info.ChangeRequired {
+		return true, nil
+	}
+	policy, err := auth.GetPasswordPolicy(r.DB)
+	if err != nil {
+		return false, err
+	}
+	return policy.IsExpired(info.ChangedAt, time.Now().UTC()), nil
+}
+
+// Logs in the authenticated user and returns the user profile. The user
+// who has to change the password is marked in the session and in the
+// returned profile.
+func (r *RestAPI) loginUser(ctx context.Context, user *dbmodel.SystemUser) (*models.User, error) {
+	changeRequired, err := r.isPasswordChangeRequired(user)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.SessionManager.LoginHandler(ctx, user); err != nil {
+		return nil, err
+	}
+	r.SessionManager.SetPasswordChangeRequired(ctx, changeRequired)
+
+	rspUser := newRestUser(*user)
+	rspUser.PasswordChangeRequired = changeRequired
+	return rspUser, nil
+}
+
+// Checks if the user with the specified ID sends the request. It returns
+// false if the request is not available.
+func (r *RestAPI) isRequestingUser(req *http.Request, id int64) bool {
+	return req != nil && r.isLoggedUser(req.Context(), id)
+}
+
+// Checks if the user sending the request belongs to the super-admin group.
+func (r *RestAPI) isSuperAdmin(ctx context.Context) bool {
+	ok, user := r.SessionManager.Logged(ctx)
+	return ok && user.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID})
+}
+
+// Checks if the given permissions cover the permission.
+func isPermissionHeld(held []dbmodel.SystemGroupPermission, permission *dbmodel.SystemGroupPermission) bool {
+	for i := range held {
+		if held[i].Covers(permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if the user sending the request holds all specified permissions.
+// The non-super-admin users may only grant the permissions they hold
+// themselves. Otherwise, they could escalate their privileges by granting
+// new permissions to their own groups. The API token with its own
+// permissions narrows down the permissions of the user, like in the
+// Authorizer. It returns an error if the permissions cannot be fetched.
+func (r *RestAPI) holdsPermissions(ctx context.Context, permissions []*dbmodel.SystemGroupPermission) (bool, error) {
+	ok, user := r.SessionManager.Logged(ctx)
+	if !ok {
+		return false, nil
+	}
+	var held []dbmodel.SystemGroupPermission
+	superAdmin := r.isSuperAdmin(ctx)
+	if !superAdmin {
+		var err error
+		if held, err = r.getUserPermissions(user); err != nil {
+			return false, err
+		}
+	}
+	apiToken := r.SessionManager.APIToken(ctx)
+	for _, permission := range permissions {
+		if permission == nil {
+			continue
+		}
+		if !superAdmin && !isPermissionHeld(held, permission) {
+			return false, nil
+		}
+		if apiToken != nil && apiToken.HasPermissions() && !isPermissionHeld(apiToken.Permissions, permission) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// Checks if the user sending the request may assign the specified groups
+// to the user accounts. Only the super-admin users may assign the
+// super-admin group. Other groups may only be assigned by the users
+// holding all permissions of these groups. It returns an error if the
+// groups cannot be fetched.
+func (r *RestAPI) canAssignGroups(ctx context.Context, groupIDs []int64) (bool, error) {
+	for _, groupID := range groupIDs {
+		if groupID == int64(dbmodel.SuperAdminGroupID) {
+			if !r.isSuperAdmin(ctx) {
+				return false, nil
+			}
+			continue
+		}
+		group, err := dbmodel.GetGroupByID(r.DB, int(groupID))
+		if err != nil {
+			return false, err
+		}
+		if group == nil {
+			continue
+		}
+		if ok, err := r.holdsPermissions(ctx, group.Permissions); err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// Checks if the new password of the user meets the password policy. The
+// zero user ID denotes a new user. It returns the HTTP status code and the
+// error message if the password is rejected.
+func (r *RestAPI) checkPasswordPolicy(policy *auth.PasswordPolicy, userID int, password string) (int, string) {
+	if err := policy.Validate(password); err != nil {
+		return http.StatusBadRequest, fmt.Sprintf("Invalid password: %s", err.Error())
+	}
+	if userID == 0 {
+		return 0, ""
+	}
+	reused, err := dbmodel.IsPasswordInHistory(r.DB, userID, password, policy.HistoryCount)
+	if err != nil {
+		log.WithField("userID", userID).WithError(err).Error("Failed to check the password history")
+		return http.StatusInternalServerError, "Failed to check the password history"
+	}
+	if reused {
+		return http.StatusBadRequest, fmt.Sprintf("Invalid password: it must differ from the last %d passwords",
+			policy.HistoryCount)
+	}
+	return 0, ""
 }
 
 // The internal authentication flow handled by the hooks.
@@ -95,6 +395,13 @@ func (r *RestAPI) externalAuthentication(ctx context.Context, params users.Creat
 		return nil, errors.Errorf("cannot authenticate a user")
 	}
 
+	return r.provisionExternalUser(*params.Credentials.AuthenticationMethodID, calloutUser)
+}
+
+// Creates or updates the internal profile of the user authenticated by the
+// hook. The user is identified by the authentication method and the
+// external ID returned by the hook.
+func (r *RestAPI) provisionExternalUser(authenticationMethodID string, calloutUser *authenticationcallouts.User) (*dbmodel.SystemUser, error) {
 	var groups []*dbmodel.SystemGroup
 	for _, g := range calloutUser.Groups {
 		groups = append(groups, &dbmodel.SystemGroup{
@@ -108,7 +415,7 @@ func (r *RestAPI) externalAuthentication(ctx context.Context, params users.Creat
 		Lastname:               calloutUser.Lastname,
 		Name:                   calloutUser.Name,
 		Groups:                 groups,
-		AuthenticationMethodID: *params.Credentials.AuthenticationMethodID,
+		AuthenticationMethodID: authenticationMethodID,
 		ExternalID:             calloutUser.ID,
 	}
 
@@ -117,10 +424,10 @@ func (r *RestAPI) externalAuthentication(ctx context.Context, params users.Creat
 		var dbUser *dbmodel.SystemUser
 		dbUser, err = dbmodel.GetUserByExternalID(
 			r.DB,
-			*params.Credentials.AuthenticationMethodID,
+			authenticationMethodID,
 			calloutUser.ID,
 		)
-		if err != nil {
+		if err != nil || dbUser == nil {
 			return nil, errors.Errorf("cannot fetch the internal user profile")
 		}
 
@@ -136,6 +443,50 @@ func (r *RestAPI) externalAuthentication(ctx context.Context, params users.Creat
 	return systemUser, err
 }
 
+// Returns the absolute URL of the endpoint completing the redirect-based
+// authentication. The identity provider redirects the user to this URL.
+// The scheme and host are taken from the request, including the headers
+// set by a reverse proxy.
+func getAuthenticationCallbackURL(req *http.Request, authenticationMethodID string) string {
+	callbackURL := &url.URL{
+		Scheme: "http",
+		Host:   req.Host,
+		Path:   "/api/sessions/callback/" + url.PathEscape(authenticationMethodID),
+	}
+	if req.TLS != nil {
+		callbackURL.Scheme = "https"
+	}
+	if proto := req.Header.Get("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		callbackURL.Scheme = proto
+	}
+	if host := req.Header.Get("X-Forwarded-Host"); host != "" {
+		callbackURL.Host = host
+	}
+	return callbackURL.String()
+}
+
+// Checks if the return URL specified by the client points to the Stork UI.
+// Only the absolute paths are accepted to prevent the open redirects.
+func isLocalReturnURL(returnURL string) bool {
+	return strings.HasPrefix(returnURL, "/") &&
+		!strings.HasPrefix(returnURL, "//") &&
+		!strings.Contains(returnURL, "\\")
+}
+
+// Returns the login page URL the user is redirected to after completing the
+// redirect-based authentication. The login page finishes the login in the UI
+// or displays an error.
+func getLoginPageURL(returnURL string, success bool) string {
+	query := url.Values{}
+	if success {
+		query.Set("sso", "ok")
+		query.Set("returnUrl", returnURL)
+	} else {
+		query.Set("sso", "failed")
+	}
+	return "/login?" + query.Encode()
+}
+
 // Attempts to login the user to the system.
 func (r *RestAPI) CreateSession(ctx context.Context, params users.CreateSessionParams) middleware.Responder {
 	var systemUser *dbmodel.SystemUser
@@ -166,10 +517,43 @@ func (r *RestAPI) CreateSession(ctx context.Context, params users.CreateSessionP
 			WithField("method", authenticationMethod).
 			WithField("identifier", *params.Credentials.Identifier).
 			Error("Cannot authenticate a user")
+		if errors.Is(err, errUserLockedOut) {
+			msg := "Account is temporarily locked due to too many failed login attempts"
+			return users.NewCreateSessionBadRequest().WithPayload(&models.APIError{
+				Message: &msg,
+			})
+		}
 		return users.NewCreateSessionBadRequest()
 	}
 
-	err = r.SessionManager.LoginHandler(ctx, systemUser)
+	if authenticationMethod == dbmodel.AuthenticationMethodIDInternal {
+		// The internal users may be required to provide the second factor.
+		required, enroll, err := r.isSecondFactorRequired(systemUser)
+		if err == nil && required {
+			err = r.SessionManager.PutPendingSecondFactor(ctx, systemUser.ID, enroll)
+			if err == nil {
+				return users.NewCreateSessionAccepted().WithPayload(&models.SecondFactorChallenge{
+					EnrollmentRequired: enroll,
+				})
+			}
+		}
+		if err != nil {
+			log.
+				WithError(err).
+				WithField("identifier", *params.Credentials.Identifier).
+				Error("Cannot check the second factor of a user")
+			return users.NewCreateSessionBadRequest()
+		}
+	}
+
+	if authenticationMethod == dbmodel.AuthenticationMethodIDInternal {
+		// Successful login resets the failed attempts.
+		err = dbmodel.DeleteUserLockout(r.DB, systemUser.ID)
+	}
+	var rspUser *models.User
+	if err == nil {
+		rspUser, err = r.loginUser(ctx, systemUser)
+	}
 	if err != nil {
 		log.
 			WithError(err).
@@ -178,7 +562,6 @@ func (r *RestAPI) CreateSession(ctx context.Context, params users.CreateSessionP
 		return users.NewCreateSessionBadRequest()
 	}
 
-	rspUser := newRestUser(*systemUser)
 	return users.NewCreateSessionOK().WithPayload(rspUser)
 }
 
@@ -199,6 +582,126 @@ func (r *RestAPI) DeleteSession(ctx context.Context, params users.DeleteSessionP
 	return users.NewDeleteSessionOK()
 }
 
+// Returns the profile of the user logged in the current session.
+func (r *RestAPI) GetSession(ctx context.Context, params users.GetSessionParams) middleware.Responder {
+	ok, user := r.SessionManager.Logged(ctx)
+	if !ok {
+		msg := "User is not logged in"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewGetSessionDefault(http.StatusUnauthorized).WithPayload(&rspErr)
+	}
+
+	dbUser, err := dbmodel.GetUserByID(r.DB, user.ID)
+	if err != nil {
+		log.WithField("userID", user.ID).WithError(err).Error("Failed to get logged user from the database")
+
+		msg := fmt.Sprintf("Failed to get user with ID %d from the database", user.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewGetSessionDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if dbUser == nil {
+		msg := fmt.Sprintf("Failed to find user with ID %d in the database", user.ID)
+		log.WithField("userID", user.ID).Error(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewGetSessionDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+
+	rspUser := newRestUser(*dbUser)
+	rspUser.PasswordChangeRequired = r.SessionManager.IsPasswordChangeRequired(ctx)
+	return users.NewGetSessionOK().WithPayload(rspUser)
+}
+
+// Begins the redirect-based authentication. It stores the authentication
+// flow state in the session and redirects the user to the identity provider.
+func (r *RestAPI) BeginSessionRedirect(ctx context.Context, params users.BeginSessionRedirectParams) middleware.Responder {
+	authenticationMethodID := params.AuthenticationMethodID
+	callbackURL := getAuthenticationCallbackURL(params.HTTPRequest, authenticationMethodID)
+
+	redirectURL, flowState, err := r.HookManager.BeginAuthentication(
+		ctx,
+		params.HTTPRequest,
+		authenticationMethodID,
+		callbackURL,
+	)
+	if err != nil {
+		log.
+			WithError(err).
+			WithField("method", authenticationMethodID).
+			Error("Cannot begin the redirect-based authentication")
+
+		msg := fmt.Sprintf("Cannot begin the authentication using the %s method", authenticationMethodID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewBeginSessionRedirectDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	returnURL := "/"
+	if params.ReturnURL != nil && isLocalReturnURL(*params.ReturnURL) {
+		returnURL = *params.ReturnURL
+	}
+	r.SessionManager.PutAuthenticationFlow(ctx, authenticationMethodID, flowState, returnURL)
+
+	return users.NewBeginSessionRedirectFound().WithLocation(redirectURL)
+}
+
+// Completes the redirect-based authentication. It is called when the
+// identity provider redirects the user back to Stork. The user is logged in
+// and redirected to the login page which finishes the login in the UI.
+func (r *RestAPI) CompleteSessionRedirect(ctx context.Context, params users.CompleteSessionRedirectParams) middleware.Responder {
+	authenticationMethodID := params.AuthenticationMethodID
+
+	flowState, returnURL, ok := r.SessionManager.PopAuthenticationFlow(ctx, authenticationMethodID)
+	if !ok {
+		log.
+			WithField("method", authenticationMethodID).
+			Error("Cannot complete the redirect-based authentication because it was not started or has expired")
+		return users.NewCompleteSessionRedirectFound().WithLocation(getLoginPageURL("", false))
+	}
+
+	calloutUser, err := r.HookManager.CompleteAuthentication(
+		ctx,
+		params.HTTPRequest,
+		authenticationMethodID,
+		getAuthenticationCallbackURL(params.HTTPRequest, authenticationMethodID),
+		flowState,
+	)
+	if calloutUser == nil || err != nil {
+		log.
+			WithError(err).
+			WithField("method", authenticationMethodID).
+			Error("Cannot authenticate a user")
+		return users.NewCompleteSessionRedirectFound().WithLocation(getLoginPageURL("", false))
+	}
+
+	systemUser, err := r.provisionExternalUser(authenticationMethodID, calloutUser)
+	if systemUser == nil || err != nil {
+		log.
+			WithError(err).
+			WithField("method", authenticationMethodID).
+			WithField("identifier", calloutUser.ID).
+			Error("Cannot create the profile of the authenticated user")
+		return users.NewCompleteSessionRedirectFound().WithLocation(getLoginPageURL("", false))
+	}
+
+	err = r.SessionManager.LoginHandler(ctx, systemUser)
+	if err != nil {
+		log.
+			WithError(err).
+			WithField("identifier", calloutUser.ID).
+			Error("Cannot log in a user")
+		return users.NewCompleteSessionRedirectFound().WithLocation(getLoginPageURL("", false))
+	}
+
+	return users.NewCompleteSessionRedirectFound().WithLocation(getLoginPageURL(returnURL, true))
+}
+
 func (r *RestAPI) getUsers(offset, limit int64, filterText *string, sortField string, sortDir dbmodel.SortDirEnum) (*models.Users, error) {
 	dbUsers, total, err := dbmodel.GetUsersByPage(r.DB, offset, limit, filterText, sortField, sortDir)
 	if err != nil {
@@ -299,6 +802,40 @@ func (r *RestAPI) CreateUser(ctx context.Context, params users.CreateUserParams)
 		return users.NewCreateUserDefault(http.StatusBadRequest).WithPayload(&rspErr)
 	}
 
+	policy, err := auth.GetPasswordPolicy(r.DB)
+	if err != nil {
+		log.WithError(err).Error("Failed to create new user account")
+
+		msg := "Failed to get the password policy"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if code, msg := r.checkPasswordPolicy(policy, 0, string(*p)); code != 0 {
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserDefault(code).WithPayload(&rspErr)
+	}
+
+	if ok, err := r.canAssignGroups(ctx, u.Groups); err != nil {
+		log.WithError(err).Error("Failed to create new user account")
+
+		msg := "Failed to get the groups of the user account"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	} else if !ok {
+		msg := "Only the users holding all permissions of the groups can create the accounts belonging to them"
+		log.WithField("login", *u.Login).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateUserDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+
 	su := &dbmodel.SystemUser{
 		Login:    *u.Login,
 		Email:    *u.Email,
@@ -310,7 +847,13 @@ func (r *RestAPI) CreateUser(ctx context.Context, params users.CreateUserParams)
 		su.Groups = append(su.Groups, &dbmodel.SystemGroup{ID: int(gid)})
 	}
 
-	con, err := dbmodel.CreateUserWithPassword(r.DB, su, string(*p))
+	// The password set by the administrator may have to be changed on
+	// the first login.
+	createUser := dbmodel.CreateUserWithPassword
+	if policy.ChangeOnFirstLogin {
+		createUser = dbmodel.CreateUserWithTemporaryPassword
+	}
+	con, err := createUser(r.DB, su, string(*p))
 	if err != nil {
 		if con {
 			log.
@@ -337,6 +880,8 @@ func (r *RestAPI) CreateUser(ctx context.Context, params users.CreateUserParams)
 	}
 
 	*u.ID = int64(su.ID)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionCreate, dbmodel.AuditObjectUser, fmt.Sprint(su.ID), su.Identity(),
+		nil, r.getAuditUser(su.ID))
 	return users.NewCreateUserOK().WithPayload(u)
 }
 
@@ -364,6 +909,47 @@ func (r *RestAPI) UpdateUser(ctx context.Context, params users.UpdateUserParams)
 		return users.NewUpdateUserDefault(http.StatusBadRequest).WithPayload(&rspErr)
 	}
 
+	// The non-super-admin users must not modify the super-admin accounts
+	// nor assign the groups granting the permissions they don't hold.
+	current, err := dbmodel.GetUserByID(r.DB, int(*u.ID))
+	if err != nil {
+		log.WithField("userID", *u.ID).WithError(err).Error("Failed to update user account")
+
+		msg := fmt.Sprintf("Failed to fetch user with ID %d from the database", *u.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	// Only the groups the user doesn't belong to yet are verified.
+	var addedGroups []int64
+	for _, groupID := range u.Groups {
+		if current == nil || !current.InGroup(&dbmodel.SystemGroup{ID: int(groupID)}) {
+			addedGroups = append(addedGroups, groupID)
+		}
+	}
+	ok, err := r.canAssignGroups(ctx, addedGroups)
+	if err != nil {
+		log.WithField("userID", *u.ID).WithError(err).Error("Failed to update user account")
+
+		msg := "Failed to get the groups of the user account"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if ok && current != nil && current.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
+		ok = r.isSuperAdmin(ctx)
+	}
+	if !ok {
+		msg := "Only super-admin users can modify the super-admin accounts and only the users holding all permissions of the groups can assign them"
+		log.WithField("userID", *u.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+
 	su := &dbmodel.SystemUser{
 		ID:       int(*u.ID),
 		Login:    *u.Login,
@@ -376,6 +962,32 @@ func (r *RestAPI) UpdateUser(ctx context.Context, params users.UpdateUserParams)
 		su.Groups = append(su.Groups, &dbmodel.SystemGroup{ID: int(gid)})
 	}
 
+	password := ""
+	if p != nil {
+		password = string(*p)
+	}
+
+	// Check the new password before updating the user.
+	policy, err := auth.GetPasswordPolicy(r.DB)
+	if err != nil {
+		log.WithField("userID", *u.ID).WithError(err).Errorf("Failed to update user account for user %s", su.Identity())
+
+		msg := "Failed to get the password policy"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if password != "" {
+		if code, msg := r.checkPasswordPolicy(policy, su.ID, password); code != 0 {
+			rspErr := models.APIError{
+				Message: &msg,
+			}
+			return users.NewUpdateUserDefault(code).WithPayload(&rspErr)
+		}
+	}
+
+	before := r.getAuditUser(su.ID)
 	con, err := dbmodel.UpdateUser(r.DB, su)
 	if con {
 		log.WithField("userID", *u.ID).WithError(err).Infof("Failed to update user account for user %s", su.Identity())
@@ -401,12 +1013,21 @@ func (r *RestAPI) UpdateUser(ctx context.Context, params users.UpdateUserParams)
 		return rsp
 	}
 
-	password := ""
-	if p != nil {
-		password = string(*p)
-	}
 	if password != "" {
-		err = dbmodel.SetPassword(r.DB, int(*u.ID), password)
+		// The password set by the administrator for another user may have
+		// to be changed on the next login.
+		resetByAdmin := !r.isRequestingUser(params.HTTPRequest, *u.ID)
+		setPassword := dbmodel.SetPassword
+		if policy.ChangeOnFirstLogin && resetByAdmin {
+			setPassword = dbmodel.SetTemporaryPassword
+		}
+		err = setPassword(r.DB, int(*u.ID), password)
+		if err == nil && resetByAdmin {
+			// The administrator resets the password when the account may
+			// have been compromised. Revoke the API tokens, so they can't
+			// be used to access the account anymore.
+			err = dbmodel.DeleteAPITokensByUserID(r.DB, int(*u.ID))
+		}
 		if err != nil {
 			log.WithFields(log.Fields{
 				"userID": *u.ID,
@@ -423,6 +1044,13 @@ func (r *RestAPI) UpdateUser(ctx context.Context, params users.UpdateUserParams)
 		}
 	}
 
+	after := r.getAuditUser(su.ID)
+	if after != nil && password != "" {
+		after.Password = "changed"
+	}
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectUser, fmt.Sprint(su.ID), su.Identity(),
+		before, after)
+
 	return users.NewUpdateUserOK()
 }
 
@@ -500,6 +1128,8 @@ func (r *RestAPI) DeleteUser(ctx context.Context, params users.DeleteUserParams)
 	}
 
 	u := newRestUser(*su)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectUser, fmt.Sprint(su.ID), su.Identity(),
+		&auditUser{User: u}, nil)
 	return users.NewDeleteUserOK().WithPayload(u)
 }
 
@@ -518,10 +1148,27 @@ func (r *RestAPI) UpdateUserPassword(ctx context.Context, params users.UpdateUse
 		return rsp
 	}
 
+	policy, err := auth.GetPasswordPolicy(r.DB)
+	if err != nil {
+		log.WithField("userID", id).WithError(err).Error("Failed to update password for user")
+
+		msg := "Failed to get the password policy"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserPasswordDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if code, msg := r.checkPasswordPolicy(policy, id, string(*passwords.Newpassword)); code != 0 {
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateUserPasswordDefault(code).WithPayload(&rspErr)
+	}
+
 	// Try to change the password for the given user id. Including old password
 	// for verification and the new password which will only be set if this
 	// verification is successful.
-	auth, err := dbmodel.ChangePassword(r.DB, id, string(*passwords.Oldpassword),
+	authorized, err := dbmodel.ChangePassword(r.DB, id, string(*passwords.Oldpassword),
 		string(*passwords.Newpassword))
 
 	// Error is returned when something went wrong with the database communication
@@ -537,7 +1184,7 @@ func (r *RestAPI) UpdateUserPassword(ctx context.Context, params users.UpdateUse
 		}
 		rsp := users.NewUpdateUserPasswordDefault(http.StatusInternalServerError).WithPayload(&rspErr)
 		return rsp
-	} else if !auth {
+	} else if !authorized {
 		log.Infof("Specified invalid current password while trying to update existing password for user ID %d",
 			id)
 
@@ -549,10 +1196,67 @@ func (r *RestAPI) UpdateUserPassword(ctx context.Context, params users.UpdateUse
 		return rsp
 	}
 
-	// Password successfully changed.
+	// Password successfully changed. The user can access other resources.
+	if r.isRequestingUser(params.HTTPRequest, int64(id)) {
+		r.SessionManager.SetPasswordChangeRequired(params.HTTPRequest.Context(), false)
+	}
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectUser, fmt.Sprint(id), "",
+		&auditUser{}, &auditUser{Password: "changed"})
 	return users.NewUpdateUserPasswordOK()
 }
 
+// Unlocks the user locked out after too many failed login attempts.
+func (r *RestAPI) DeleteUserLockout(ctx context.Context, params users.DeleteUserLockoutParams) middleware.Responder {
+	id := int(params.ID)
+	su, err := dbmodel.GetUserByID(r.DB, id)
+	if err != nil {
+		log.WithField("userID", id).WithError(err).Error("Failed to get user from the database")
+
+		msg := fmt.Sprintf("Failed to get user with ID %d from the database", id)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteUserLockoutDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if su == nil {
+		msg := fmt.Sprintf("Failed to find user with ID %d in the database", id)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteUserLockoutDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+
+	lockout, err := dbmodel.GetUserLockout(r.DB, id)
+	if err == nil {
+		err = dbmodel.DeleteUserLockout(r.DB, id)
+	}
+	if err != nil {
+		log.WithField("userID", id).WithError(err).Error("Failed to unlock the user")
+
+		msg := fmt.Sprintf("Failed to unlock user %s", su.Identity())
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteUserLockoutDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	if lockout.IsLocked(time.Now().UTC()) {
+		unlockedBy := "unknown"
+		if params.HTTPRequest != nil {
+			if ok, user := r.SessionManager.Logged(params.HTTPRequest.Context()); ok {
+				unlockedBy = user.Identity()
+			}
+		}
+		log.WithField("userID", id).Infof("User %s unlocked by %s", su.Identity(), unlockedBy)
+		if r.EventCenter != nil {
+			r.EventCenter.AddInfoEvent(fmt.Sprintf("{user} has been unlocked by %s", unlockedBy), su)
+		}
+		r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectUser, fmt.Sprint(id), su.Identity(),
+			&auditUser{Locked: true}, &auditUser{})
+	}
+	return users.NewDeleteUserLockoutOK()
+}
+
 func (r *RestAPI) getGroups(offset, limit int64, filterText *string, sortField string, sortDir dbmodel.SortDirEnum) (*models.Groups, error) {
 	dbGroups, total, err := dbmodel.GetGroupsByPage(r.DB, offset, limit, filterText, sortField, sortDir)
 	if err != nil {
@@ -596,6 +1300,195 @@ func (r *RestAPI) GetGroups(ctx context.Context, params users.GetGroupsParams) m
 	return rsp
 }
 
+// Creates a new group with permissions.
+func (r *RestAPI) CreateGroup(ctx context.Context, params users.CreateGroupParams) middleware.Responder {
+	group, err := newDBGroup(params.Group)
+	if err != nil {
+		log.WithError(err).Warn("Failed to create new group: invalid data")
+
+		msg := fmt.Sprintf("Failed to create new group: %s", err)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateGroupDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	if ok, err := r.holdsPermissions(ctx, group.Permissions); err != nil {
+		log.WithField("group", group.Name).WithError(err).Error("Failed to create new group")
+
+		msg := "Failed to get the permissions of the user"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateGroupDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	} else if !ok {
+		msg := "Cannot grant the permissions the user doesn't hold"
+		log.WithField("group", group.Name).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateGroupDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+
+	conflict, err := dbmodel.AddGroup(r.DB, group)
+	if err != nil {
+		if conflict {
+			log.WithField("group", group.Name).WithError(err).Info("Failed to create conflicting group")
+
+			msg := fmt.Sprintf("Group %s already exists", group.Name)
+			rspErr := models.APIError{
+				Message: &msg,
+			}
+			return users.NewCreateGroupDefault(http.StatusConflict).WithPayload(&rspErr)
+		}
+		log.WithField("group", group.Name).WithError(err).Error("Failed to create new group")
+
+		msg := fmt.Sprintf("Failed to create new group %s", group.Name)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewCreateGroupDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	restGroup := newRestGroup(*group)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionCreate, dbmodel.AuditObjectGroup, fmt.Sprint(group.ID), group.Name,
+		nil, restGroup)
+	return users.NewCreateGroupOK().WithPayload(restGroup)
+}
+
+// Updates the name, description and permissions of an existing group.
+// The predefined super-admin and admin groups cannot be updated.
+func (r *RestAPI) UpdateGroup(ctx context.Context, params users.UpdateGroupParams) middleware.Responder {
+	group, err := newDBGroup(params.Group)
+	if err != nil {
+		log.WithField("groupID", params.ID).WithError(err).Warn("Failed to update group: invalid data")
+
+		msg := fmt.Sprintf("Failed to update group: %s", err)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+	group.ID = int(params.ID)
+
+	if group.IsPredefined() {
+		msg := fmt.Sprintf("Predefined group with ID %d cannot be modified", params.ID)
+		log.WithField("groupID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+
+	// The non-super-admin users must neither grant the permissions they
+	// don't hold nor modify the groups having such permissions.
+	current, err := dbmodel.GetGroupByID(r.DB, group.ID)
+	if err != nil {
+		log.WithField("groupID", params.ID).WithError(err).Error("Failed to update group")
+
+		msg := fmt.Sprintf("Failed to fetch group with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	permissions := append([]*dbmodel.SystemGroupPermission{}, group.Permissions...)
+	if current != nil {
+		permissions = append(permissions, current.Permissions...)
+	}
+	if ok, err := r.holdsPermissions(ctx, permissions); err != nil {
+		log.WithField("groupID", params.ID).WithError(err).Error("Failed to update group")
+
+		msg := "Failed to get the permissions of the user"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	} else if !ok {
+		msg := "Cannot grant or modify the permissions the user doesn't hold"
+		log.WithField("groupID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+
+	before := r.getAuditGroup(group.ID)
+	conflict, err := dbmodel.UpdateGroup(r.DB, group)
+	switch {
+	case errors.Is(err, dbmodel.ErrNotExists):
+		msg := fmt.Sprintf("Failed to find group with ID %d in the database", params.ID)
+		log.WithField("groupID", params.ID).Error(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupDefault(http.StatusNotFound).WithPayload(&rspErr)
+	case conflict:
+		log.WithField("groupID", params.ID).WithError(err).Info("Failed to update group due to a conflict")
+
+		msg := fmt.Sprintf("Group %s already exists", group.Name)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupDefault(http.StatusConflict).WithPayload(&rspErr)
+	case err != nil:
+		log.WithField("groupID", params.ID).WithError(err).Error("Failed to update group")
+
+		msg := fmt.Sprintf("Failed to update group with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewUpdateGroupDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	restGroup := newRestGroup(*group)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectGroup, fmt.Sprint(group.ID), group.Name,
+		before, restGroup)
+	return users.NewUpdateGroupOK().WithPayload(restGroup)
+}
+
+// Deletes the group. The predefined super-admin and admin groups cannot
+// be deleted.
+func (r *RestAPI) DeleteGroup(ctx context.Context, params users.DeleteGroupParams) middleware.Responder {
+	if (&dbmodel.SystemGroup{ID: int(params.ID)}).IsPredefined() {
+		msg := fmt.Sprintf("Predefined group with ID %d cannot be deleted", params.ID)
+		log.WithField("groupID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteGroupDefault(http.StatusForbidden).WithPayload(&rspErr)
+	}
+
+	before := r.getAuditGroup(int(params.ID))
+	err := dbmodel.DeleteGroup(r.DB, int(params.ID))
+	switch {
+	case errors.Is(err, dbmodel.ErrNotExists):
+		msg := fmt.Sprintf("Failed to find group with ID %d in the database", params.ID)
+		log.WithField("groupID", params.ID).Error(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteGroupDefault(http.StatusNotFound).WithPayload(&rspErr)
+	case err != nil:
+		log.WithField("groupID", params.ID).WithError(err).Error("Failed to delete group")
+
+		msg := fmt.Sprintf("Failed to delete group with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return users.NewDeleteGroupDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	name := ""
+	if before != nil && before.Name != nil {
+		name = *before.Name
+	}
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectGroup, fmt.Sprint(params.ID), name,
+		before, nil)
+
+	return users.NewDeleteGroupOK()
+}
+
 // Get authentication methods supported by server. Endpoint is allowed without log in.
 func (r *RestAPI) GetAuthenticationMethods(ctx context.Context, params users.GetAuthenticationMethodsParams) middleware.Responder {
 	metadata := r.HookManager.GetAuthenticationMetadata()
@@ -620,6 +1513,11 @@ func (r *RestAPI) GetAuthenticationMethods(ctx context.Context, params users.Get
 			method.FormLabelSecret = metaForm.GetSecretFormLabel()
 		}
 
+		if metaRedirect, ok := meta.(authenticationcallouts.AuthenticationMetadataRedirect); ok {
+			method.Redirect = true
+			method.RedirectButtonLabel = metaRedirect.GetRedirectButtonLabel()
+		}
+
 		methods = append(methods, method)
 	}
 
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/go-openapi/runtime/middleware"
 	"github.com/golang/mock/gomock"
 	"github.com/stretchr/testify/require"
 	"isc.org/stork/hooks"
@@ -16,6 +18,7 @@ import (
 	"isc.org/stork/server/gen/models"
 	"isc.org/stork/server/gen/restapi/operations/users"
 	"isc.org/stork/server/hookmanager"
+	storktest "isc.org/stork/server/test/dbmodel"
 	storkutil "isc.org/stork/util"
 )
 
@@ -773,7 +776,415 @@ func TestGetGroups(t *testing.T) {
 
 	groups := rspOK.Payload
 	require.NotNil(t, groups.Items)
-	require.GreaterOrEqual(t, 2, len(groups.Items))
+	require.Len(t, groups.Items, 4)
+	require.Empty(t, groups.Items[0].Permissions)
+	require.Len(t, groups.Items[1].Permissions, 10)
+	require.Equal(t, "read-only", *groups.Items[2].Name)
+}
+
+// Tests that the group can be created, updated and deleted via REST API.
+func TestCreateUpdateDeleteGroup(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	ctx, _ := loginTestUser(t, rapi, db, "root", dbmodel.SuperAdminGroupID)
+
+	name := "zone-viewer"
+	description := "Views zones of one app."
+	resource := "zones"
+	action := "read"
+	createParams := users.CreateGroupParams{
+		Group: &models.Group{
+			Name:        &name,
+			Description: &description,
+			Permissions: []*models.GroupPermission{
+				{
+					Resource:  &resource,
+					Action:    &action,
+					ScopeType: "app",
+					ScopeID:   1,
+				},
+			},
+		},
+	}
+	rsp := rapi.CreateGroup(ctx, createParams)
+	require.IsType(t, &users.CreateGroupOK{}, rsp)
+	group := rsp.(*users.CreateGroupOK).Payload
+	require.NotNil(t, group.ID)
+	require.Len(t, group.Permissions, 1)
+	require.Equal(t, "app", group.Permissions[0].ScopeType)
+
+	// The same name again.
+	rsp = rapi.CreateGroup(ctx, createParams)
+	require.IsType(t, &users.CreateGroupDefault{}, rsp)
+	require.Equal(t, http.StatusConflict, getStatusCode(*rsp.(*users.CreateGroupDefault)))
+
+	// Invalid permission.
+	invalidAction := "delete"
+	rsp = rapi.CreateGroup(ctx, users.CreateGroupParams{
+		Group: &models.Group{
+			Name:        storkutil.Ptr("invalid"),
+			Permissions: []*models.GroupPermission{{Resource: &resource, Action: &invalidAction}},
+		},
+	})
+	require.IsType(t, &users.CreateGroupDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*users.CreateGroupDefault)))
+
+	// Update the group.
+	action = "write"
+	rsp = rapi.UpdateGroup(ctx, users.UpdateGroupParams{
+		ID:    *group.ID,
+		Group: createParams.Group,
+	})
+	require.IsType(t, &users.UpdateGroupOK{}, rsp)
+
+	dbGroup, err := dbmodel.GetGroupByID(db, int(*group.ID))
+	require.NoError(t, err)
+	require.Len(t, dbGroup.Permissions, 1)
+	require.Equal(t, dbmodel.PermissionActionWrite, dbGroup.Permissions[0].Action)
+
+	// Predefined groups can't be modified.
+	rsp = rapi.UpdateGroup(ctx, users.UpdateGroupParams{
+		ID:    int64(dbmodel.AdminGroupID),
+		Group: createParams.Group,
+	})
+	require.IsType(t, &users.UpdateGroupDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.UpdateGroupDefault)))
+
+	// Non-existing group.
+	rsp = rapi.UpdateGroup(ctx, users.UpdateGroupParams{
+		ID:    *group.ID + 100,
+		Group: createParams.Group,
+	})
+	require.IsType(t, &users.UpdateGroupDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*users.UpdateGroupDefault)))
+
+	// Delete the group.
+	rsp = rapi.DeleteGroup(ctx, users.DeleteGroupParams{ID: int64(dbmodel.SuperAdminGroupID)})
+	require.IsType(t, &users.DeleteGroupDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.DeleteGroupDefault)))
+
+	rsp = rapi.DeleteGroup(ctx, users.DeleteGroupParams{ID: *group.ID})
+	require.IsType(t, &users.DeleteGroupOK{}, rsp)
+
+	rsp = rapi.DeleteGroup(ctx, users.DeleteGroupParams{ID: *group.ID})
+	require.IsType(t, &users.DeleteGroupDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*users.DeleteGroupDefault)))
+}
+
+// Creates a user belonging to the specified group and logs them in.
+// It returns the context of the user session.
+func loginTestUser(t *testing.T, rapi *RestAPI, db *dbops.PgDB, login string, groupID int) (context.Context, *dbmodel.SystemUser) {
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	user := &dbmodel.SystemUser{
+		Login:    login,
+		Email:    login + "@example.org",
+		Lastname: "Doe",
+		Name:     login,
+		Groups:   []*dbmodel.SystemGroup{{ID: groupID}},
+	}
+	con, err := dbmodel.CreateUser(db, user)
+	require.False(t, con)
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+	return ctx, user
+}
+
+// Tests that only the super-admin users can create the accounts belonging
+// to the super-admin group.
+func TestCreateUserPrivilegedGroup(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	adminCtx, _ := loginTestUser(t, rapi, db, "admin", dbmodel.AdminGroupID)
+	superAdminCtx, _ := loginTestUser(t, rapi, db, "root", dbmodel.SuperAdminGroupID)
+
+	user := newRestUser(dbmodel.SystemUser{
+		Email:    "jb@example.org",
+		Lastname: "Born",
+		Login:    "jb",
+		Name:     "John",
+		Groups:   []*dbmodel.SystemGroup{{ID: dbmodel.SuperAdminGroupID}},
+	})
+	params := users.CreateUserParams{
+		Account: &models.UserAccount{
+			User:     user,
+			Password: storkutil.Ptr(models.Password("pass")),
+		},
+	}
+
+	// The admin can't create a super-admin account.
+	rsp := rapi.CreateUser(adminCtx, params)
+	require.IsType(t, &users.CreateUserDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.CreateUserDefault)))
+	returned, total, err := dbmodel.GetUsersByPage(db, 0, 10, storkutil.Ptr("jb"), "", dbmodel.SortDirAny)
+	require.NoError(t, err)
+	require.Zero(t, total)
+	require.Empty(t, returned)
+
+	// The super-admin can.
+	rsp = rapi.CreateUser(superAdminCtx, params)
+	require.IsType(t, &users.CreateUserOK{}, rsp)
+	returned, total, err = dbmodel.GetUsersByPage(db, 0, 10, storkutil.Ptr("jb"), "", dbmodel.SortDirAny)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, total)
+	require.True(t, returned[0].InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}))
+}
+
+// Tests that only the super-admin users can add the accounts to the
+// privileged groups and modify the super-admin accounts.
+func TestUpdateUserPrivilegedGroup(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	adminCtx, admin := loginTestUser(t, rapi, db, "admin", dbmodel.AdminGroupID)
+	superAdminCtx, superAdmin := loginTestUser(t, rapi, db, "root", dbmodel.SuperAdminGroupID)
+
+	// Custom group granting all permissions.
+	allGroup := &dbmodel.SystemGroup{
+		Name: "all",
+		Permissions: []*dbmodel.SystemGroupPermission{
+			{Resource: dbmodel.PermissionResourceAll, Action: dbmodel.PermissionActionWrite},
+		},
+	}
+	_, err = dbmodel.AddGroup(db, allGroup)
+	require.NoError(t, err)
+
+	// The admin can't add themselves to the super-admin group nor to the
+	// group granting all permissions.
+	for _, groupID := range []int{dbmodel.SuperAdminGroupID, allGroup.ID} {
+		user := newRestUser(*admin)
+		user.Groups = []int64{int64(dbmodel.AdminGroupID), int64(groupID)}
+		rsp := rapi.UpdateUser(adminCtx, users.UpdateUserParams{
+			Account: &models.UserAccount{User: user},
+		})
+		require.IsType(t, &users.UpdateUserDefault{}, rsp)
+		require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.UpdateUserDefault)))
+	}
+	returned, err := dbmodel.GetUserByID(db, admin.ID)
+	require.NoError(t, err)
+	require.Len(t, returned.Groups, 1)
+	require.EqualValues(t, dbmodel.AdminGroupID, returned.Groups[0].ID)
+
+	// The admin can't modify the super-admin account.
+	user := newRestUser(*superAdmin)
+	user.Email = storkutil.Ptr("hijacked@example.org")
+	rsp := rapi.UpdateUser(adminCtx, users.UpdateUserParams{
+		Account: &models.UserAccount{User: user},
+	})
+	require.IsType(t, &users.UpdateUserDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.UpdateUserDefault)))
+
+	// The super-admin can add the admin to the privileged groups.
+	user = newRestUser(*admin)
+	user.Groups = []int64{int64(dbmodel.SuperAdminGroupID), int64(allGroup.ID)}
+	rsp = rapi.UpdateUser(superAdminCtx, users.UpdateUserParams{
+		Account: &models.UserAccount{User: user},
+	})
+	require.IsType(t, &users.UpdateUserOK{}, rsp)
+	returned, err = dbmodel.GetUserByID(db, admin.ID)
+	require.NoError(t, err)
+	require.True(t, returned.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}))
+	require.True(t, returned.InGroup(allGroup))
+}
+
+// Tests that only the super-admin users can grant the permissions to all
+// resources.
+func TestCreateUpdateGroupAllResources(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	adminCtx, _ := loginTestUser(t, rapi, db, "admin", dbmodel.AdminGroupID)
+	superAdminCtx, _ := loginTestUser(t, rapi, db, "root", dbmodel.SuperAdminGroupID)
+
+	group := &models.Group{
+		Name: storkutil.Ptr("all"),
+		Permissions: []*models.GroupPermission{
+			{
+				Resource: storkutil.Ptr(dbmodel.PermissionResourceAll),
+				Action:   storkutil.Ptr(string(dbmodel.PermissionActionRead)),
+			},
+		},
+	}
+
+	// The admin can't grant the permissions to all resources.
+	rsp := rapi.CreateGroup(adminCtx, users.CreateGroupParams{Group: group})
+	require.IsType(t, &users.CreateGroupDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.CreateGroupDefault)))
+
+	// The super-admin can.
+	rsp = rapi.CreateGroup(superAdminCtx, users.CreateGroupParams{Group: group})
+	require.IsType(t, &users.CreateGroupOK{}, rsp)
+	groupID := *rsp.(*users.CreateGroupOK).Payload.ID
+
+	// The admin can neither modify this group nor grant such permissions
+	// to other groups.
+	hosts := &models.Group{
+		Name: storkutil.Ptr("hosts"),
+		Permissions: []*models.GroupPermission{
+			{
+				Resource: storkutil.Ptr(dbmodel.PermissionResourceHosts),
+				Action:   storkutil.Ptr(string(dbmodel.PermissionActionRead)),
+			},
+		},
+	}
+	rsp = rapi.UpdateGroup(adminCtx, users.UpdateGroupParams{ID: groupID, Group: hosts})
+	require.IsType(t, &users.UpdateGroupDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.UpdateGroupDefault)))
+
+	rsp = rapi.CreateGroup(adminCtx, users.CreateGroupParams{Group: hosts})
+	require.IsType(t, &users.CreateGroupOK{}, rsp)
+	hostsID := *rsp.(*users.CreateGroupOK).Payload.ID
+
+	rsp = rapi.UpdateGroup(adminCtx, users.UpdateGroupParams{ID: hostsID, Group: group})
+	require.IsType(t, &users.UpdateGroupDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.UpdateGroupDefault)))
+
+	// The super-admin can.
+	group.Name = storkutil.Ptr("all-hosts")
+	rsp = rapi.UpdateGroup(superAdminCtx, users.UpdateGroupParams{ID: hostsID, Group: group})
+	require.IsType(t, &users.UpdateGroupOK{}, rsp)
+	hosts.Name = storkutil.Ptr("hosts-only")
+	rsp = rapi.UpdateGroup(superAdminCtx, users.UpdateGroupParams{ID: groupID, Group: hosts})
+	require.IsType(t, &users.UpdateGroupOK{}, rsp)
+}
+
+// Tests that the users allowed to manage the groups and user accounts can
+// only grant the permissions they hold and can't escalate their privileges
+// by extending the permissions of their own groups.
+func TestCreateUpdateGroupSelfEscalation(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	managers := &dbmodel.SystemGroup{
+		Name: "managers",
+		Permissions: []*dbmodel.SystemGroupPermission{
+			{Resource: dbmodel.PermissionResourceGroups, Action: dbmodel.PermissionActionWrite},
+			{Resource: dbmodel.PermissionResourceUsers, Action: dbmodel.PermissionActionWrite},
+			{Resource: dbmodel.PermissionResourceHosts, Action: dbmodel.PermissionActionRead},
+		},
+	}
+	_, err = dbmodel.AddGroup(db, managers)
+	require.NoError(t, err)
+
+	apps := &dbmodel.SystemGroup{
+		Name: "apps",
+		Permissions: []*dbmodel.SystemGroupPermission{
+			{Resource: dbmodel.PermissionResourceApps, Action: dbmodel.PermissionActionWrite},
+		},
+	}
+	_, err = dbmodel.AddGroup(db, apps)
+	require.NoError(t, err)
+
+	ctx, manager := loginTestUser(t, rapi, db, "manager", managers.ID)
+
+	// The manager can't grant new permissions to their own group.
+	escalated := &models.Group{
+		Name: storkutil.Ptr("managers"),
+		Permissions: []*models.GroupPermission{
+			{
+				Resource: storkutil.Ptr(dbmodel.PermissionResourceGroups),
+				Action:   storkutil.Ptr(string(dbmodel.PermissionActionWrite)),
+			},
+			{
+				Resource: storkutil.Ptr(dbmodel.PermissionResourceUsers),
+				Action:   storkutil.Ptr(string(dbmodel.PermissionActionWrite)),
+			},
+			{
+				Resource: storkutil.Ptr(dbmodel.PermissionResourceSettings),
+				Action:   storkutil.Ptr(string(dbmodel.PermissionActionWrite)),
+			},
+		},
+	}
+	rsp := rapi.UpdateGroup(ctx, users.UpdateGroupParams{ID: int64(managers.ID), Group: escalated})
+	require.IsType(t, &users.UpdateGroupDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.UpdateGroupDefault)))
+	returnedGroup, err := dbmodel.GetGroupByID(db, managers.ID)
+	require.NoError(t, err)
+	require.Len(t, returnedGroup.Permissions, 3)
+	for _, permission := range returnedGroup.Permissions {
+		require.NotEqual(t, dbmodel.PermissionResourceSettings, permission.Resource)
+	}
+
+	// The manager can't create a group with the permissions they don't hold
+	// either.
+	rsp = rapi.CreateGroup(ctx, users.CreateGroupParams{Group: &models.Group{
+		Name: storkutil.Ptr("hosts-writers"),
+		Permissions: []*models.GroupPermission{
+			{
+				Resource: storkutil.Ptr(dbmodel.PermissionResourceHosts),
+				Action:   storkutil.Ptr(string(dbmodel.PermissionActionWrite)),
+			},
+		},
+	}})
+	require.IsType(t, &users.CreateGroupDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.CreateGroupDefault)))
+
+	// The manager can't modify the group having the permissions they
+	// don't hold.
+	rsp = rapi.UpdateGroup(ctx, users.UpdateGroupParams{ID: int64(apps.ID), Group: &models.Group{
+		Name: storkutil.Ptr("apps-readers"),
+		Permissions: []*models.GroupPermission{
+			{
+				Resource: storkutil.Ptr(dbmodel.PermissionResourceHosts),
+				Action:   storkutil.Ptr(string(dbmodel.PermissionActionRead)),
+			},
+		},
+	}})
+	require.IsType(t, &users.UpdateGroupDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.UpdateGroupDefault)))
+
+	// The manager can't add themselves to the group having the permissions
+	// they don't hold.
+	user := newRestUser(*manager)
+	user.Groups = []int64{int64(managers.ID), int64(apps.ID)}
+	rsp = rapi.UpdateUser(ctx, users.UpdateUserParams{
+		Account: &models.UserAccount{User: user},
+	})
+	require.IsType(t, &users.UpdateUserDefault{}, rsp)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*rsp.(*users.UpdateUserDefault)))
+	returnedUser, err := dbmodel.GetUserByID(db, manager.ID)
+	require.NoError(t, err)
+	require.Len(t, returnedUser.Groups, 1)
+	require.EqualValues(t, managers.ID, returnedUser.Groups[0].ID)
+
+	// The manager can grant the permissions they hold and assign the
+	// group granting them.
+	rsp = rapi.CreateGroup(ctx, users.CreateGroupParams{Group: &models.Group{
+		Name: storkutil.Ptr("hosts-readers"),
+		Permissions: []*models.GroupPermission{
+			{
+				Resource: storkutil.Ptr(dbmodel.PermissionResourceHosts),
+				Action:   storkutil.Ptr(string(dbmodel.PermissionActionRead)),
+			},
+		},
+	}})
+	require.IsType(t, &users.CreateGroupOK{}, rsp)
+	hostsReadersID := *rsp.(*users.CreateGroupOK).Payload.ID
+
+	user.Groups = []int64{int64(managers.ID), hostsReadersID}
+	rsp = rapi.UpdateUser(ctx, users.UpdateUserParams{
+		Account: &models.UserAccount{User: user},
+	})
+	require.IsType(t, &users.UpdateUserOK{}, rsp)
+	returnedUser, err = dbmodel.GetUserByID(db, manager.ID)
+	require.NoError(t, err)
+	require.Len(t, returnedUser.Groups, 2)
 }
 
 // Tests that user information can be retrieved via REST API.
@@ -1085,6 +1496,243 @@ func TestCreateSessionOfExternalUser(t *testing.T) {
 	require.Greater(t, *okRsp.Payload.ID, int64(0))
 }
 
+// Test that the logged user profile is returned for the current session.
+func TestGetSession(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+
+	user := &dbmodel.SystemUser{
+		Email:    "jan@example.org",
+		Lastname: "Kowalski",
+		Name:     "Jan",
+	}
+	_, err := dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	ctx, _ := rapi.SessionManager.Load(context.Background(), "")
+	_ = rapi.SessionManager.LoginHandler(ctx, user)
+
+	// Act
+	rsp := rapi.GetSession(ctx, users.GetSessionParams{})
+
+	// Assert
+	require.IsType(t, &users.GetSessionOK{}, rsp)
+	okRsp := rsp.(*users.GetSessionOK)
+	require.EqualValues(t, user.ID, *okRsp.Payload.ID)
+	require.Equal(t, "jan@example.org", *okRsp.Payload.Email)
+}
+
+// Test that the session information is not returned when the user is not
+// logged in.
+func TestGetSessionNotLogged(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	ctx, _ := rapi.SessionManager.Load(context.Background(), "")
+
+	// Act
+	rsp := rapi.GetSession(ctx, users.GetSessionParams{})
+
+	// Assert
+	require.IsType(t, &users.GetSessionDefault{}, rsp)
+	require.EqualValues(t, http.StatusUnauthorized, getStatusCode(*rsp.(*users.GetSessionDefault)))
+}
+
+// Test that the callback URL of the redirect-based authentication is
+// constructed from the request.
+func TestGetAuthenticationCallbackURL(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "http://stork.example.org:8080/api/sessions/redirect/oidc", nil)
+	require.Equal(t, "http://stork.example.org:8080/api/sessions/callback/oidc",
+		getAuthenticationCallbackURL(req, "oidc"))
+
+	// The headers set by a reverse proxy take precedence.
+	req.Header.Set("X-Forwarded-Proto", "https")
+	req.Header.Set("X-Forwarded-Host", "stork.example.org")
+	require.Equal(t, "https://stork.example.org/api/sessions/callback/oidc",
+		getAuthenticationCallbackURL(req, "oidc"))
+
+	// Unexpected protocols are ignored.
+	req.Header.Set("X-Forwarded-Proto", "javascript")
+	require.Equal(t, "http://stork.example.org/api/sessions/callback/oidc",
+		getAuthenticationCallbackURL(req, "oidc"))
+}
+
+// Test that only the local return URLs are accepted.
+func TestIsLocalReturnURL(t *testing.T) {
+	require.True(t, isLocalReturnURL("/"))
+	require.True(t, isLocalReturnURL("/machines/all?text=foo"))
+	require.False(t, isLocalReturnURL(""))
+	require.False(t, isLocalReturnURL("machines"))
+	require.False(t, isLocalReturnURL("//evil.example.org"))
+	require.False(t, isLocalReturnURL("/\\evil.example.org"))
+	require.False(t, isLocalReturnURL("https://evil.example.org"))
+}
+
+// Test that the redirect-based authentication is started and its state is
+// stored in the session.
+func TestBeginSessionRedirect(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metadataMock := hookmanager.NewMockAuthenticationMetadata(ctrl)
+	metadataMock.EXPECT().
+		GetID().
+		Return("oidc")
+
+	mock := hookmanager.NewMockAuthenticationRedirectCalloutCarrier(ctrl)
+	mock.EXPECT().
+		BeginAuthentication(gomock.Any(), gomock.Any(), "http://stork.example.org/api/sessions/callback/oidc").
+		Return("https://idp.example.org/authorize?state=foo", "flow-state", nil).
+		Times(1)
+	mock.EXPECT().
+		GetMetadata().
+		Return(metadataMock)
+
+	hookManager := hookmanager.NewHookManager()
+	hookManager.RegisterCalloutCarrier(mock)
+	rapi, _ := NewRestAPI(dbSettings, db, hookManager)
+
+	ctx, _ := rapi.SessionManager.Load(context.Background(), "")
+	req, _ := http.NewRequest(http.MethodGet, "http://stork.example.org/api/sessions/redirect/oidc", nil)
+
+	// Act
+	rsp := rapi.BeginSessionRedirect(ctx, users.BeginSessionRedirectParams{
+		HTTPRequest:            req,
+		AuthenticationMethodID: "oidc",
+		ReturnURL:              storkutil.Ptr("/machines/all"),
+	})
+
+	// Assert
+	require.IsType(t, &users.BeginSessionRedirectFound{}, rsp)
+	require.Equal(t, "https://idp.example.org/authorize?state=foo", rsp.(*users.BeginSessionRedirectFound).Location)
+
+	flowState, returnURL, ok := rapi.SessionManager.PopAuthenticationFlow(ctx, "oidc")
+	require.True(t, ok)
+	require.Equal(t, "flow-state", flowState)
+	require.Equal(t, "/machines/all", returnURL)
+}
+
+// Test that beginning the redirect-based authentication using an unknown
+// method fails.
+func TestBeginSessionRedirectUnknownMethod(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db, hookmanager.NewHookManager())
+	ctx, _ := rapi.SessionManager.Load(context.Background(), "")
+	req, _ := http.NewRequest(http.MethodGet, "http://stork.example.org/api/sessions/redirect/oidc", nil)
+
+	// Act
+	rsp := rapi.BeginSessionRedirect(ctx, users.BeginSessionRedirectParams{
+		HTTPRequest:            req,
+		AuthenticationMethodID: "oidc",
+	})
+
+	// Assert
+	require.IsType(t, &users.BeginSessionRedirectDefault{}, rsp)
+	require.EqualValues(t, http.StatusBadRequest, getStatusCode(*rsp.(*users.BeginSessionRedirectDefault)))
+}
+
+// Test that completing the redirect-based authentication provisions the
+// user and logs them in.
+func TestCompleteSessionRedirect(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	ctrl := gomock.NewController(t)
+	defer ctrl.Finish()
+
+	metadataMock := hookmanager.NewMockAuthenticationMetadata(ctrl)
+	metadataMock.EXPECT().
+		GetID().
+		Return("oidc").
+		AnyTimes()
+
+	mock := hookmanager.NewMockAuthenticationRedirectCalloutCarrier(ctrl)
+	mock.EXPECT().
+		CompleteAuthentication(gomock.Any(), gomock.Any(), "http://stork.example.org/api/sessions/callback/oidc", "flow-state").
+		Return(&authenticationcallouts.User{
+			ID:     "subject",
+			Login:  "foo",
+			Email:  "foo@example.org",
+			Groups: []int{dbmodel.SuperAdminGroupID},
+		}, nil).
+		Times(2)
+	mock.EXPECT().
+		GetMetadata().
+		Return(metadataMock).
+		AnyTimes()
+
+	hookManager := hookmanager.NewHookManager()
+	hookManager.RegisterCalloutCarrier(mock)
+	rapi, _ := NewRestAPI(dbSettings, db, hookManager)
+
+	req, _ := http.NewRequest(http.MethodGet, "http://stork.example.org/api/sessions/callback/oidc?code=foo&state=bar", nil)
+
+	// The user logs in twice. The second login updates the existing profile.
+	for i := 0; i < 2; i++ {
+		ctx, _ := rapi.SessionManager.Load(context.Background(), "")
+		rapi.SessionManager.PutAuthenticationFlow(ctx, "oidc", "flow-state", "/machines/all")
+
+		// Act
+		rsp := rapi.CompleteSessionRedirect(ctx, users.CompleteSessionRedirectParams{
+			HTTPRequest:            req,
+			AuthenticationMethodID: "oidc",
+		})
+
+		// Assert
+		require.IsType(t, &users.CompleteSessionRedirectFound{}, rsp)
+		require.Equal(t, "/login?returnUrl=%2Fmachines%2Fall&sso=ok", rsp.(*users.CompleteSessionRedirectFound).Location)
+
+		ok, user := rapi.SessionManager.Logged(ctx)
+		require.True(t, ok)
+		require.Equal(t, "foo@example.org", user.Email)
+		require.Equal(t, "oidc", user.AuthenticationMethodID)
+	}
+
+	dbUser, err := dbmodel.GetUserByExternalID(db, "oidc", "subject")
+	require.NoError(t, err)
+	require.NotNil(t, dbUser)
+	require.Len(t, dbUser.Groups, 1)
+	require.EqualValues(t, dbmodel.SuperAdminGroupID, dbUser.Groups[0].ID)
+}
+
+// Test that completing the redirect-based authentication fails if it was
+// not started in the same session.
+func TestCompleteSessionRedirectNotStarted(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db, hookmanager.NewHookManager())
+	ctx, _ := rapi.SessionManager.Load(context.Background(), "")
+	req, _ := http.NewRequest(http.MethodGet, "http://stork.example.org/api/sessions/callback/oidc?code=foo&state=bar", nil)
+
+	// Act
+	rsp := rapi.CompleteSessionRedirect(ctx, users.CompleteSessionRedirectParams{
+		HTTPRequest:            req,
+		AuthenticationMethodID: "oidc",
+	})
+
+	// Assert
+	require.IsType(t, &users.CompleteSessionRedirectFound{}, rsp)
+	require.Equal(t, "/login?sso=failed", rsp.(*users.CompleteSessionRedirectFound).Location)
+
+	ok, _ := rapi.SessionManager.Logged(ctx)
+	require.False(t, ok)
+}
+
 // Test that the internal authentication method is always returned.
 func TestGetAuthenticationMethodsInternal(t *testing.T) {
 	// Arrange
@@ -1152,3 +1800,284 @@ func TestGetAuthenticationMethodsFromHooks(t *testing.T) {
 		require.EqualValues(t, fmt.Sprintf("mock-%d", i), method.ID)
 	}
 }
+
+// Returns the login parameters of the user with the specified password.
+func getCredentials(login, password string) users.CreateSessionParams {
+	return users.CreateSessionParams{
+		Credentials: &models.SessionCredentials{
+			Identifier: &login,
+			Secret:     &password,
+		},
+	}
+}
+
+// Test that the user is locked out after too many failed login attempts
+// and can be unlocked by the administrator.
+func TestCreateSessionLockout(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	require.NoError(t, dbmodel.SetSettingInt(db, "account_lockout_threshold", 3))
+
+	fec := &storktest.FakeEventCenter{}
+	rapi, _ := NewRestAPI(dbSettings, db, fec)
+
+	user := &dbmodel.SystemUser{
+		Login:    "jan",
+		Lastname: "Kowalski",
+		Name:     "Jan",
+	}
+	_, err := dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Act & Assert
+	for i := 0; i < 3; i++ {
+		rsp := rapi.CreateSession(ctx, getCredentials("jan", "bad pass"))
+		require.IsType(t, &users.CreateSessionBadRequest{}, rsp)
+		require.Nil(t, rsp.(*users.CreateSessionBadRequest).Payload)
+	}
+	require.Len(t, fec.Events, 1)
+	require.Contains(t, fec.Events[0].Text, "has been locked out")
+	require.EqualValues(t, user.ID, fec.Events[0].Relations.UserID)
+
+	// The valid password is rejected while the user is locked out.
+	rsp := rapi.CreateSession(ctx, getCredentials("jan", "pass"))
+	require.IsType(t, &users.CreateSessionBadRequest{}, rsp)
+	require.NotNil(t, rsp.(*users.CreateSessionBadRequest).Payload)
+	require.Contains(t, *rsp.(*users.CreateSessionBadRequest).Payload.Message, "locked")
+
+	// Unlock the user.
+	unlockRsp := rapi.DeleteUserLockout(ctx, users.DeleteUserLockoutParams{ID: int64(user.ID)})
+	require.IsType(t, &users.DeleteUserLockoutOK{}, unlockRsp)
+	require.Len(t, fec.Events, 2)
+	require.Contains(t, fec.Events[1].Text, "has been unlocked")
+
+	rsp = rapi.CreateSession(ctx, getCredentials("jan", "pass"))
+	require.IsType(t, &users.CreateSessionOK{}, rsp)
+
+	// Unlocking the user that is not locked out doesn't produce the event.
+	unlockRsp = rapi.DeleteUserLockout(ctx, users.DeleteUserLockoutParams{ID: int64(user.ID)})
+	require.IsType(t, &users.DeleteUserLockoutOK{}, unlockRsp)
+	require.Len(t, fec.Events, 2)
+}
+
+// Test that unlocking a non-existing user returns an error.
+func TestDeleteUserLockoutNoUser(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+
+	rsp := rapi.DeleteUserLockout(context.Background(), users.DeleteUserLockoutParams{ID: 123})
+	require.IsType(t, &users.DeleteUserLockoutDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*users.DeleteUserLockoutDefault)))
+}
+
+// Test that the passwords not meeting the password policy are rejected.
+func TestPasswordPolicy(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	require.NoError(t, dbmodel.SetSettingInt(db, "password_min_length", 8))
+	require.NoError(t, dbmodel.SetSettingBool(db, "password_require_digit", true))
+	require.NoError(t, dbmodel.SetSettingInt(db, "password_history_count", 2))
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+	ctx := context.Background()
+
+	newUser := func(password string) users.CreateUserParams {
+		return users.CreateUserParams{
+			Account: &models.UserAccount{
+				User: &models.User{
+					Email:    storkutil.Ptr("jan@example.org"),
+					Lastname: storkutil.Ptr("Kowalski"),
+					Login:    storkutil.Ptr("jan"),
+					Name:     storkutil.Ptr("Jan"),
+					ID:       storkutil.Ptr(int64(0)),
+				},
+				Password: storkutil.Ptr(models.Password(password)),
+			},
+		}
+	}
+
+	// Act & Assert
+	rsp := rapi.CreateUser(ctx, newUser("pass"))
+	require.IsType(t, &users.CreateUserDefault{}, rsp)
+	defaultRsp := rsp.(*users.CreateUserDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+	require.Equal(t, "Invalid password: password must be at least 8 characters long, contain a digit",
+		*defaultRsp.Payload.Message)
+
+	rsp = rapi.CreateUser(ctx, newUser("password1"))
+	require.IsType(t, &users.CreateUserOK{}, rsp)
+	id := *rsp.(*users.CreateUserOK).Payload.ID
+
+	changePassword := func(oldPassword, newPassword string) middleware.Responder {
+		return rapi.UpdateUserPassword(ctx, users.UpdateUserPasswordParams{
+			ID: id,
+			Passwords: &models.PasswordChange{
+				Oldpassword: storkutil.Ptr(models.Password(oldPassword)),
+				Newpassword: storkutil.Ptr(models.Password(newPassword)),
+			},
+		})
+	}
+
+	// Too weak password.
+	pwdRsp := changePassword("password1", "password")
+	require.IsType(t, &users.UpdateUserPasswordDefault{}, pwdRsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*pwdRsp.(*users.UpdateUserPasswordDefault)))
+
+	// Reused password.
+	pwdRsp = changePassword("password1", "password1")
+	require.IsType(t, &users.UpdateUserPasswordDefault{}, pwdRsp)
+	require.Contains(t, *pwdRsp.(*users.UpdateUserPasswordDefault).Payload.Message, "last 2 passwords")
+
+	pwdRsp = changePassword("password1", "password2")
+	require.IsType(t, &users.UpdateUserPasswordOK{}, pwdRsp)
+
+	pwdRsp = changePassword("password2", "password1")
+	require.IsType(t, &users.UpdateUserPasswordDefault{}, pwdRsp)
+
+	pwdRsp = changePassword("password2", "password3")
+	require.IsType(t, &users.UpdateUserPasswordOK{}, pwdRsp)
+
+	// The oldest password is no longer in the checked history.
+	pwdRsp = changePassword("password3", "password1")
+	require.IsType(t, &users.UpdateUserPasswordOK{}, pwdRsp)
+}
+
+// Test that the API tokens of the user are revoked when the administrator
+// resets the password of the user but not when the user changes their own
+// password.
+func TestUpdateUserPasswordRevokesAPITokens(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	superAdminCtx, _ := loginTestUser(t, rapi, db, "root", dbmodel.SuperAdminGroupID)
+
+	user := &dbmodel.SystemUser{
+		Login:    "bot",
+		Email:    "bot@example.org",
+		Lastname: "Bot",
+		Name:     "CI",
+		Groups:   []*dbmodel.SystemGroup{{ID: dbmodel.AdminGroupID}},
+	}
+	_, err = dbmodel.CreateUserWithPassword(db, user, "pass")
+	require.NoError(t, err)
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	_, hash, err := dbmodel.GenerateAPIToken()
+	require.NoError(t, err)
+	_, err = dbmodel.AddAPIToken(db, &dbmodel.APIToken{UserID: user.ID, Name: "ci", TokenHash: hash})
+	require.NoError(t, err)
+
+	update := func(ctx context.Context) {
+		req, _ := http.NewRequestWithContext(ctx, "PUT", "http://example.org/api/users", nil)
+		rsp := rapi.UpdateUser(ctx, users.UpdateUserParams{
+			HTTPRequest: req,
+			Account: &models.UserAccount{
+				User:     newRestUser(*user),
+				Password: storkutil.Ptr(models.Password("new pass")),
+			},
+		})
+		require.IsType(t, &users.UpdateUserOK{}, rsp)
+	}
+
+	// The user changes their own password.
+	update(ctx)
+	tokens, err := dbmodel.GetAPITokensByUserID(db, user.ID)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+
+	// The administrator resets the password.
+	update(superAdminCtx)
+	tokens, err = dbmodel.GetAPITokensByUserID(db, user.ID)
+	require.NoError(t, err)
+	require.Empty(t, tokens)
+}
+
+// Test that the user has to change the password set by the administrator
+// and the expired password on login.
+func TestCreateSessionPasswordChangeRequired(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	require.NoError(t, dbmodel.InitializeSettings(db, 0))
+	require.NoError(t, dbmodel.SetSettingBool(db, "password_change_on_first_login", true))
+
+	rapi, _ := NewRestAPI(dbSettings, db)
+
+	rsp := rapi.CreateUser(context.Background(), users.CreateUserParams{
+		Account: &models.UserAccount{
+			User: &models.User{
+				Email:    storkutil.Ptr("jan@example.org"),
+				Lastname: storkutil.Ptr("Kowalski"),
+				Login:    storkutil.Ptr("jan"),
+				Name:     storkutil.Ptr("Jan"),
+				ID:       storkutil.Ptr(int64(0)),
+			},
+			Password: storkutil.Ptr(models.Password("pass")),
+		},
+	})
+	require.IsType(t, &users.CreateUserOK{}, rsp)
+	id := int(*rsp.(*users.CreateUserOK).Payload.ID)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Act & Assert
+	sessionRsp := rapi.CreateSession(ctx, getCredentials("jan", "pass"))
+	require.IsType(t, &users.CreateSessionOK{}, sessionRsp)
+	require.True(t, sessionRsp.(*users.CreateSessionOK).Payload.PasswordChangeRequired)
+	require.True(t, rapi.SessionManager.IsPasswordChangeRequired(ctx))
+
+	// The user can only change the password.
+	newRequest := func(method, path string) *http.Request {
+		req, _ := http.NewRequestWithContext(ctx, method, "http://example.org/api"+path, nil)
+		return req
+	}
+	require.Error(t, rapi.Authorizer(newRequest("GET", "/machines")))
+	require.NoError(t, rapi.Authorizer(newRequest("PUT", fmt.Sprintf("/users/%d/password", id))))
+	require.NoError(t, rapi.Authorizer(newRequest("DELETE", "/sessions")))
+
+	pwdRsp := rapi.UpdateUserPassword(ctx, users.UpdateUserPasswordParams{
+		HTTPRequest: newRequest("PUT", fmt.Sprintf("/users/%d/password", id)),
+		ID:          int64(id),
+		Passwords: &models.PasswordChange{
+			Oldpassword: storkutil.Ptr(models.Password("pass")),
+			Newpassword: storkutil.Ptr(models.Password("new pass")),
+		},
+	})
+	require.IsType(t, &users.UpdateUserPasswordOK{}, pwdRsp)
+	require.False(t, rapi.SessionManager.IsPasswordChangeRequired(ctx))
+	require.NoError(t, rapi.Authorizer(newRequest("GET", fmt.Sprintf("/users/%d/totp", id))))
+
+	// The next login doesn't require the password change.
+	sessionRsp = rapi.CreateSession(ctx, getCredentials("jan", "new pass"))
+	require.IsType(t, &users.CreateSessionOK{}, sessionRsp)
+	require.False(t, sessionRsp.(*users.CreateSessionOK).Payload.PasswordChangeRequired)
+
+	// The expired password has to be changed.
+	require.NoError(t, dbmodel.SetSettingInt(db, "password_expiration_days", 30))
+	_, err = db.Exec("UPDATE system_user_password SET password_hash = NULL, changed_at = ? WHERE id = ?",
+		time.Now().UTC().AddDate(0, 0, -31), id)
+	require.NoError(t, err)
+
+	sessionRsp = rapi.CreateSession(ctx, getCredentials("jan", "new pass"))
+	require.IsType(t, &users.CreateSessionOK{}, sessionRsp)
+	require.True(t, sessionRsp.(*users.CreateSessionOK).Payload.PasswordChangeRequired)
+}
@@ -0,0 +1,156 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+)
+
+// Returns the utilization history of the subnet, shared network or daemon.
+// The object existence is checked by the caller. If the time range isn't
+// specified, the samples from the last 24 hours are returned. If the
+// resolution isn't specified, the finest resolution still kept for the
+// start of the range is used. It returns the HTTP status code and the
+// error message if the history cannot be returned.
+func (r *RestAPI) getUtilizationHistory(objectType dbmodel.UtilizationObjectType, objectID int64, fromParam, toParam *strfmt.DateTime, resolutionParam *string) (*models.UtilizationHistory, int, string) {
+	now := time.Now().UTC()
+	to := now
+	if toParam != nil {
+		to = time.Time(*toParam).UTC()
+	}
+	from := to.Add(-24 * time.Hour)
+	if fromParam != nil {
+		from = time.Time(*fromParam).UTC()
+	}
+	if !from.Before(to) {
+		return nil, http.StatusBadRequest, "The start of the time range must be before its end"
+	}
+	resolution := dbmodel.GetUtilizationResolution(from, now)
+	if resolutionParam != nil {
+		resolution = dbmodel.UtilizationResolution(*resolutionParam)
+		if !resolution.IsValid() {
+			return nil, http.StatusBadRequest, fmt.Sprintf("Unsupported utilization history resolution %s", *resolutionParam)
+		}
+	}
+
+	dbSamples, err := dbmodel.GetUtilizationSamples(r.DB, objectType, objectID, resolution, from, to)
+	if err != nil {
+		log.WithError(err).Error("Failed to get utilization history from the database")
+		return nil, http.StatusInternalServerError, fmt.Sprintf("Problem fetching utilization history of %s with ID %d from db", objectType, objectID)
+	}
+
+	history := &models.UtilizationHistory{
+		Resolution: string(resolution),
+		Items:      []*models.UtilizationSample{},
+	}
+	for _, sample := range dbSamples {
+		history.Items = append(history.Items, &models.UtilizationSample{
+			SampledAt:          strfmt.DateTime(sample.SampledAt),
+			AddrUtilization:    float64(sample.AddrUtilization) / 10,
+			PdUtilization:      float64(sample.PdUtilization) / 10,
+			AddrUtilizationMax: float64(sample.AddrUtilizationMax) / 10,
+			PdUtilizationMax:   float64(sample.PdUtilizationMax) / 10,
+		})
+	}
+	return history, http.StatusOK, ""
+}
+
+// Returns the utilization history of the subnet.
+func (r *RestAPI) GetSubnetUtilizationHistory(ctx context.Context, params dhcp.GetSubnetUtilizationHistoryParams) middleware.Responder {
+	subnet, err := dbmodel.GetSubnet(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Problem fetching subnet with ID %d from db", params.ID)
+		log.WithError(err).Error(msg)
+		return dhcp.NewGetSubnetUtilizationHistoryDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+	if subnet == nil {
+		msg := fmt.Sprintf("Cannot find subnet with ID %d", params.ID)
+		return dhcp.NewGetSubnetUtilizationHistoryDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+
+	history, status, msg := r.getUtilizationHistory(dbmodel.UtilizationObjectSubnet, params.ID, params.From, params.To, params.Resolution)
+	if history == nil {
+		return dhcp.NewGetSubnetUtilizationHistoryDefault(status).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+	return dhcp.NewGetSubnetUtilizationHistoryOK().WithPayload(history)
+}
+
+// Returns the utilization history of the shared network.
+func (r *RestAPI) GetSharedNetworkUtilizationHistory(ctx context.Context, params dhcp.GetSharedNetworkUtilizationHistoryParams) middleware.Responder {
+	network, err := dbmodel.GetSharedNetwork(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Problem fetching shared network with ID %d from db", params.ID)
+		log.WithError(err).Error(msg)
+		return dhcp.NewGetSharedNetworkUtilizationHistoryDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+	if network == nil {
+		msg := fmt.Sprintf("Cannot find shared network with ID %d", params.ID)
+		return dhcp.NewGetSharedNetworkUtilizationHistoryDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+
+	history, status, msg := r.getUtilizationHistory(dbmodel.UtilizationObjectSharedNetwork, params.ID, params.From, params.To, params.Resolution)
+	if history == nil {
+		return dhcp.NewGetSharedNetworkUtilizationHistoryDefault(status).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+	return dhcp.NewGetSharedNetworkUtilizationHistoryOK().WithPayload(history)
+}
+
+// Returns the utilization history of the DHCP daemon.
+func (r *RestAPI) GetDaemonUtilizationHistory(ctx context.Context, params dhcp.GetDaemonUtilizationHistoryParams) middleware.Responder {
+	daemon, err := dbmodel.GetDaemonByID(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Problem fetching daemon with ID %d from db", params.ID)
+		log.WithError(err).Error(msg)
+		return dhcp.NewGetDaemonUtilizationHistoryDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+	if daemon == nil {
+		msg := fmt.Sprintf("Cannot find daemon with ID %d", params.ID)
+		return dhcp.NewGetDaemonUtilizationHistoryDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+
+	history, status, msg := r.getUtilizationHistory(dbmodel.UtilizationObjectDaemon, params.ID, params.From, params.To, params.Resolution)
+	if history == nil {
+		return dhcp.NewGetDaemonUtilizationHistoryDefault(status).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+	}
+	return dhcp.NewGetDaemonUtilizationHistoryOK().WithPayload(history)
+}
+
+// Returns the number of days until the forecasted exhaustion or nil if
+// the exhaustion is not forecasted.
+func getExhaustionDays(exhaustionAt time.Time) *float64 {
+	if exhaustionAt.IsZero() {
+		return nil
+	}
+	days := time.Until(exhaustionAt).Hours() / 24
+	if days < 0 {
+		days = 0
+	}
+	return &days
+}
@@ -0,0 +1,207 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+	storkutil "isc.org/stork/util"
+)
+
+// Test that the utilization history of the subnet is returned over the
+// REST API.
+func TestGetSubnetUtilizationHistory(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	require.NoError(t, dbmodel.AddSubnet(db, subnet))
+
+	now := time.Now().UTC().Truncate(time.Second)
+	samples := []dbmodel.UtilizationSample{
+		{
+			SubnetID:           subnet.ID,
+			Resolution:         dbmodel.UtilizationResolutionRaw,
+			SampledAt:          now.Add(-2 * time.Hour),
+			AddrUtilization:    125,
+			AddrUtilizationMax: 125,
+		},
+		{
+			SubnetID:           subnet.ID,
+			Resolution:         dbmodel.UtilizationResolutionRaw,
+			SampledAt:          now.Add(-time.Hour),
+			AddrUtilization:    250,
+			AddrUtilizationMax: 250,
+		},
+		{
+			SubnetID:           subnet.ID,
+			Resolution:         dbmodel.UtilizationResolutionHourly,
+			SampledAt:          now.AddDate(0, -2, 0),
+			AddrUtilization:    100,
+			AddrUtilizationMax: 300,
+		},
+	}
+	require.NoError(t, dbmodel.AddUtilizationSamples(db, samples))
+
+	// The raw samples from the last 24 hours are returned by default.
+	rsp := rapi.GetSubnetUtilizationHistory(ctx, dhcp.GetSubnetUtilizationHistoryParams{
+		ID: subnet.ID,
+	})
+	require.IsType(t, &dhcp.GetSubnetUtilizationHistoryOK{}, rsp)
+	history := rsp.(*dhcp.GetSubnetUtilizationHistoryOK).Payload
+	require.Equal(t, "raw", history.Resolution)
+	require.Len(t, history.Items, 2)
+	require.EqualValues(t, 12.5, history.Items[0].AddrUtilization)
+	require.EqualValues(t, 25, history.Items[1].AddrUtilization)
+	require.Equal(t, now.Add(-time.Hour), time.Time(history.Items[1].SampledAt).UTC())
+
+	// The hourly samples are used for the older time ranges.
+	from := strfmt.DateTime(now.AddDate(0, -3, 0))
+	rsp = rapi.GetSubnetUtilizationHistory(ctx, dhcp.GetSubnetUtilizationHistoryParams{
+		ID:   subnet.ID,
+		From: &from,
+	})
+	require.IsType(t, &dhcp.GetSubnetUtilizationHistoryOK{}, rsp)
+	history = rsp.(*dhcp.GetSubnetUtilizationHistoryOK).Payload
+	require.Equal(t, "1h", history.Resolution)
+	require.Len(t, history.Items, 1)
+	require.EqualValues(t, 10, history.Items[0].AddrUtilization)
+	require.EqualValues(t, 30, history.Items[0].AddrUtilizationMax)
+
+	// Explicit resolution.
+	rsp = rapi.GetSubnetUtilizationHistory(ctx, dhcp.GetSubnetUtilizationHistoryParams{
+		ID:         subnet.ID,
+		From:       &from,
+		Resolution: storkutil.Ptr("raw"),
+	})
+	require.IsType(t, &dhcp.GetSubnetUtilizationHistoryOK{}, rsp)
+	require.Len(t, rsp.(*dhcp.GetSubnetUtilizationHistoryOK).Payload.Items, 2)
+
+	rsp = rapi.GetSubnetUtilizationHistory(ctx, dhcp.GetSubnetUtilizationHistoryParams{
+		ID:         subnet.ID,
+		Resolution: storkutil.Ptr("1d"),
+	})
+	require.IsType(t, &dhcp.GetSubnetUtilizationHistoryDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.GetSubnetUtilizationHistoryDefault)))
+
+	// Invalid time range.
+	to := strfmt.DateTime(now.AddDate(0, -4, 0))
+	rsp = rapi.GetSubnetUtilizationHistory(ctx, dhcp.GetSubnetUtilizationHistoryParams{
+		ID:   subnet.ID,
+		From: &from,
+		To:   &to,
+	})
+	require.IsType(t, &dhcp.GetSubnetUtilizationHistoryDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.GetSubnetUtilizationHistoryDefault)))
+
+	// Non-existing subnet.
+	rsp = rapi.GetSubnetUtilizationHistory(ctx, dhcp.GetSubnetUtilizationHistoryParams{
+		ID: subnet.ID + 1,
+	})
+	require.IsType(t, &dhcp.GetSubnetUtilizationHistoryDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*dhcp.GetSubnetUtilizationHistoryDefault)))
+}
+
+// Test that the utilization history of the shared network and the daemon
+// is returned over the REST API.
+func TestGetSharedNetworkAndDaemonUtilizationHistory(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	network := &dbmodel.SharedNetwork{
+		Name:   "frog",
+		Family: 4,
+	}
+	require.NoError(t, dbmodel.AddSharedNetwork(db, network))
+
+	machine := &dbmodel.Machine{
+		Address:   "192.0.2.1",
+		AgentPort: 8080,
+	}
+	require.NoError(t, dbmodel.AddMachine(db, machine))
+	app := &dbmodel.App{
+		MachineID: machine.ID,
+		Type:      dbmodel.AppTypeKea,
+		Daemons: []*dbmodel.Daemon{{
+			Name: "dhcp4",
+		}},
+	}
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+	daemonID := app.Daemons[0].ID
+
+	now := time.Now().UTC()
+	samples := []dbmodel.UtilizationSample{
+		{
+			SharedNetworkID: network.ID,
+			Resolution:      dbmodel.UtilizationResolutionRaw,
+			SampledAt:       now.Add(-time.Hour),
+			PdUtilization:   500,
+		},
+		{
+			DaemonID:        daemonID,
+			Resolution:      dbmodel.UtilizationResolutionRaw,
+			SampledAt:       now.Add(-time.Hour),
+			AddrUtilization: 750,
+		},
+	}
+	require.NoError(t, dbmodel.AddUtilizationSamples(db, samples))
+
+	rsp := rapi.GetSharedNetworkUtilizationHistory(ctx, dhcp.GetSharedNetworkUtilizationHistoryParams{
+		ID: network.ID,
+	})
+	require.IsType(t, &dhcp.GetSharedNetworkUtilizationHistoryOK{}, rsp)
+	history := rsp.(*dhcp.GetSharedNetworkUtilizationHistoryOK).Payload
+	require.Len(t, history.Items, 1)
+	require.EqualValues(t, 50, history.Items[0].PdUtilization)
+
+	rsp = rapi.GetSharedNetworkUtilizationHistory(ctx, dhcp.GetSharedNetworkUtilizationHistoryParams{
+		ID: network.ID + 1,
+	})
+	require.IsType(t, &dhcp.GetSharedNetworkUtilizationHistoryDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*dhcp.GetSharedNetworkUtilizationHistoryDefault)))
+
+	rsp = rapi.GetDaemonUtilizationHistory(ctx, dhcp.GetDaemonUtilizationHistoryParams{
+		ID: daemonID,
+	})
+	require.IsType(t, &dhcp.GetDaemonUtilizationHistoryOK{}, rsp)
+	history = rsp.(*dhcp.GetDaemonUtilizationHistoryOK).Payload
+	require.Len(t, history.Items, 1)
+	require.EqualValues(t, 75, history.Items[0].AddrUtilization)
+
+	rsp = rapi.GetDaemonUtilizationHistory(ctx, dhcp.GetDaemonUtilizationHistoryParams{
+		ID: daemonID + 1,
+	})
+	require.IsType(t, &dhcp.GetDaemonUtilizationHistoryDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*dhcp.GetDaemonUtilizationHistoryDefault)))
+}
+
+// Test that the number of days to the forecasted exhaustion is returned
+// for the REST API.
+func TestGetExhaustionDays(t *testing.T) {
+	require.Nil(t, getExhaustionDays(time.Time{}))
+
+	days := getExhaustionDays(time.Now().Add(36 * time.Hour))
+	require.NotNil(t, days)
+	require.InDelta(t, 1.5, *days, 0.01)
+
+	days = getExhaustionDays(time.Now().Add(-time.Hour))
+	require.NotNil(t, days)
+	require.Zero(t, *days)
+}
@@ -0,0 +1,308 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+)
+
+// Default maximum number of the retries of a failed webhook delivery.
+const defaultWebhookMaxRetries = 3
+
+// Creates new instance of the webhook model used by REST API from the
+// webhook instance returned from the database. The secret is never
+// included.
+func newRestWebhook(w *dbmodel.Webhook) *models.Webhook {
+	maxRetries := int64(w.MaxRetries)
+	webhook := &models.Webhook{
+		ID:          w.ID,
+		Name:        &w.Name,
+		URL:         &w.URL,
+		Enabled:     &w.Enabled,
+		HasSecret:   w.Secret != "",
+		Template:    w.Template,
+		Level:       int64(w.Level),
+		TextPattern: w.TextPattern,
+		MaxRetries:  &maxRetries,
+		CreatedAt:   strfmt.DateTime(w.CreatedAt),
+	}
+	if w.Filters != nil {
+		webhook.MachineID = w.Filters.MachineID
+		webhook.AppID = w.Filters.AppID
+		webhook.DaemonID = w.Filters.DaemonID
+		webhook.SubnetID = w.Filters.SubnetID
+		webhook.UserID = w.Filters.UserID
+	}
+	return webhook
+}
+
+// Converts the webhook received over the REST API to the database model
+// and validates it. The current webhook is used to preserve the secret
+// when the received webhook doesn't specify it. It is nil when the
+// webhook is created.
+func newDBWebhook(w *models.Webhook, current *dbmodel.Webhook) (*dbmodel.Webhook, error) {
+	if w == nil || w.Name == nil || w.URL == nil {
+		return nil, errors.New("missing webhook name or URL")
+	}
+	webhook := &dbmodel.Webhook{
+		Name:        strings.TrimSpace(*w.Name),
+		URL:         strings.TrimSpace(*w.URL),
+		Enabled:     true,
+		Secret:      w.Secret,
+		Template:    w.Template,
+		Level:       dbmodel.EventLevel(w.Level),
+		TextPattern: w.TextPattern,
+		MaxRetries:  defaultWebhookMaxRetries,
+	}
+	if w.Enabled != nil {
+		webhook.Enabled = *w.Enabled
+	}
+	if w.MaxRetries != nil {
+		webhook.MaxRetries = int(*w.MaxRetries)
+	}
+	if current != nil {
+		webhook.ID = current.ID
+		webhook.CreatedAt = current.CreatedAt
+		if webhook.Secret == "" && !w.ClearSecret {
+			webhook.Secret = current.Secret
+		}
+	}
+	filters := &dbmodel.Relations{
+		MachineID: w.MachineID,
+		AppID:     w.AppID,
+		DaemonID:  w.DaemonID,
+		SubnetID:  w.SubnetID,
+		UserID:    w.UserID,
+	}
+	if *filters != (dbmodel.Relations{}) {
+		webhook.Filters = filters
+	}
+	if err := eventcenter.ValidateWebhook(webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Returns all webhooks.
+func (r *RestAPI) GetWebhooks(ctx context.Context, params events.GetWebhooksParams) middleware.Responder {
+	dbWebhooks, err := dbmodel.GetWebhooks(r.DB)
+	if err != nil {
+		log.WithError(err).Error("Failed to get webhooks from the database")
+
+		msg := "Failed to get webhooks from the database"
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetWebhooksDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	webhooks := &models.Webhooks{
+		Items: []*models.Webhook{},
+		Total: int64(len(dbWebhooks)),
+	}
+	for i := range dbWebhooks {
+		webhooks.Items = append(webhooks.Items, newRestWebhook(&dbWebhooks[i]))
+	}
+	return events.NewGetWebhooksOK().WithPayload(webhooks)
+}
+
+// Returns the webhook with the specified ID.
+func (r *RestAPI) GetWebhook(ctx context.Context, params events.GetWebhookParams) middleware.Responder {
+	dbWebhook, err := dbmodel.GetWebhookByID(r.DB, params.ID)
+	if err != nil {
+		log.WithField("webhookID", params.ID).WithError(err).Error("Failed to get webhook from the database")
+
+		msg := fmt.Sprintf("Failed to get webhook with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetWebhookDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if dbWebhook == nil {
+		msg := fmt.Sprintf("Cannot find webhook with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetWebhookDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+	return events.NewGetWebhookOK().WithPayload(newRestWebhook(dbWebhook))
+}
+
+// Creates a new webhook.
+func (r *RestAPI) CreateWebhook(ctx context.Context, params events.CreateWebhookParams) middleware.Responder {
+	dbWebhook, err := newDBWebhook(params.Webhook, nil)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to create webhook: %s", err)
+		log.Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewCreateWebhookDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	conflict, err := dbmodel.AddWebhook(r.DB, dbWebhook)
+	if conflict {
+		msg := fmt.Sprintf("Webhook %s already exists", dbWebhook.Name)
+		log.WithError(err).Info(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewCreateWebhookDefault(http.StatusConflict).WithPayload(&rspErr)
+	}
+	if err != nil {
+		log.WithError(err).Error("Failed to create webhook")
+
+		msg := fmt.Sprintf("Failed to create webhook %s", dbWebhook.Name)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewCreateWebhookDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("webhook", dbWebhook.Name).Info("Created new webhook")
+
+	rspWebhook := newRestWebhook(dbWebhook)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionCreate, dbmodel.AuditObjectWebhook, fmt.Sprint(dbWebhook.ID), dbWebhook.Name,
+		nil, rspWebhook)
+	return events.NewCreateWebhookOK().WithPayload(rspWebhook)
+}
+
+// Updates the webhook. The secret is preserved unless a new secret is
+// specified or the secret is explicitly cleared.
+func (r *RestAPI) UpdateWebhook(ctx context.Context, params events.UpdateWebhookParams) middleware.Responder {
+	current, err := dbmodel.GetWebhookByID(r.DB, params.ID)
+	if err != nil {
+		log.WithField("webhookID", params.ID).WithError(err).Error("Failed to get webhook from the database")
+
+		msg := fmt.Sprintf("Failed to get webhook with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateWebhookDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+	if current == nil {
+		msg := fmt.Sprintf("Cannot find webhook with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateWebhookDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+
+	dbWebhook, err := newDBWebhook(params.Webhook, current)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to update webhook: %s", err)
+		log.WithField("webhookID", params.ID).Warn(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateWebhookDefault(http.StatusBadRequest).WithPayload(&rspErr)
+	}
+
+	conflict, err := dbmodel.UpdateWebhook(r.DB, dbWebhook)
+	if conflict {
+		msg := fmt.Sprintf("Webhook %s already exists", dbWebhook.Name)
+		log.WithError(err).Info(msg)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateWebhookDefault(http.StatusConflict).WithPayload(&rspErr)
+	}
+	if err != nil {
+		log.WithField("webhookID", params.ID).WithError(err).Error("Failed to update webhook")
+
+		msg := fmt.Sprintf("Failed to update webhook with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewUpdateWebhookDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("webhook", dbWebhook.Name).Info("Updated webhook")
+
+	rspWebhook := newRestWebhook(dbWebhook)
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionUpdate, dbmodel.AuditObjectWebhook, fmt.Sprint(dbWebhook.ID), dbWebhook.Name,
+		newRestWebhook(current), rspWebhook)
+	return events.NewUpdateWebhookOK().WithPayload(rspWebhook)
+}
+
+// Deletes the webhook together with its delivery log.
+func (r *RestAPI) DeleteWebhook(ctx context.Context, params events.DeleteWebhookParams) middleware.Responder {
+	current, err := dbmodel.GetWebhookByID(r.DB, params.ID)
+	if err == nil && current != nil {
+		err = dbmodel.DeleteWebhook(r.DB, params.ID)
+	}
+	if (err == nil && current == nil) || errors.Is(err, dbmodel.ErrNotExists) {
+		msg := fmt.Sprintf("Cannot find webhook with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewDeleteWebhookDefault(http.StatusNotFound).WithPayload(&rspErr)
+	}
+	if err != nil {
+		log.WithField("webhookID", params.ID).WithError(err).Error("Failed to delete webhook")
+
+		msg := fmt.Sprintf("Failed to delete webhook with ID %d", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewDeleteWebhookDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	log.WithField("webhook", current.Name).Info("Deleted webhook")
+	r.recordAudit(params.HTTPRequest, dbmodel.AuditActionDelete, dbmodel.AuditObjectWebhook, fmt.Sprint(params.ID), current.Name,
+		newRestWebhook(current), nil)
+	return events.NewDeleteWebhookOK()
+}
+
+// Returns the delivery log of the webhook, starting from the most recent
+// deliveries.
+func (r *RestAPI) GetWebhookDeliveries(ctx context.Context, params events.GetWebhookDeliveriesParams) middleware.Responder {
+	var start int64
+	if params.Start != nil {
+		start = *params.Start
+	}
+
+	var limit int64 = 10
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	dbDeliveries, total, err := dbmodel.GetWebhookDeliveriesByPage(r.DB, params.ID, start, limit)
+	if err != nil {
+		log.WithField("webhookID", params.ID).WithError(err).Error("Failed to get webhook deliveries from the database")
+
+		msg := fmt.Sprintf("Failed to get deliveries of webhook with ID %d from the database", params.ID)
+		rspErr := models.APIError{
+			Message: &msg,
+		}
+		return events.NewGetWebhookDeliveriesDefault(http.StatusInternalServerError).WithPayload(&rspErr)
+	}
+
+	deliveries := &models.WebhookDeliveries{
+		Items: []*models.WebhookDelivery{},
+		Total: total,
+	}
+	for _, d := range dbDeliveries {
+		deliveries.Items = append(deliveries.Items, &models.WebhookDelivery{
+			ID:         d.ID,
+			CreatedAt:  strfmt.DateTime(d.CreatedAt),
+			EventID:    d.EventID,
+			Attempts:   int64(d.Attempts),
+			StatusCode: int64(d.StatusCode),
+			Success:    d.Success,
+			Error:      d.Error,
+		})
+	}
+	return events.NewGetWebhookDeliveriesOK().WithPayload(deliveries)
+}
@@ -0,0 +1,151 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/events"
+	storkutil "isc.org/stork/util"
+)
+
+// Test that the webhooks can be created, fetched, updated and deleted
+// over the REST API and the secrets are not returned.
+func TestWebhooks(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// Create the webhook with the default settings.
+	rsp := rapi.CreateWebhook(ctx, events.CreateWebhookParams{
+		Webhook: &models.Webhook{
+			Name:      storkutil.Ptr("chat"),
+			URL:       storkutil.Ptr("https://chat.example.org/hooks/1"),
+			Secret:    "secret",
+			Level:     int64(dbmodel.EvWarning),
+			MachineID: 1,
+		},
+	})
+	require.IsType(t, &events.CreateWebhookOK{}, rsp)
+	created := rsp.(*events.CreateWebhookOK).Payload
+	require.NotZero(t, created.ID)
+	require.True(t, *created.Enabled)
+	require.True(t, created.HasSecret)
+	require.Empty(t, created.Secret)
+	require.EqualValues(t, 3, *created.MaxRetries)
+	require.EqualValues(t, 1, created.MachineID)
+
+	dbWebhook, err := dbmodel.GetWebhookByID(db, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "secret", dbWebhook.Secret)
+	require.EqualValues(t, 1, dbWebhook.Filters.MachineID)
+
+	// The names are unique.
+	rsp = rapi.CreateWebhook(ctx, events.CreateWebhookParams{
+		Webhook: &models.Webhook{
+			Name: storkutil.Ptr("chat"),
+			URL:  storkutil.Ptr("https://chat.example.org/hooks/2"),
+		},
+	})
+	require.IsType(t, &events.CreateWebhookDefault{}, rsp)
+	require.Equal(t, http.StatusConflict, getStatusCode(*rsp.(*events.CreateWebhookDefault)))
+
+	// Invalid template.
+	rsp = rapi.CreateWebhook(ctx, events.CreateWebhookParams{
+		Webhook: &models.Webhook{
+			Name:     storkutil.Ptr("tickets"),
+			URL:      storkutil.Ptr("https://tickets.example.org"),
+			Template: `{"text": {{.Text}}}`,
+		},
+	})
+	require.IsType(t, &events.CreateWebhookDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*events.CreateWebhookDefault)))
+
+	rsp = rapi.GetWebhooks(ctx, events.GetWebhooksParams{})
+	require.IsType(t, &events.GetWebhooksOK{}, rsp)
+	webhooks := rsp.(*events.GetWebhooksOK).Payload
+	require.EqualValues(t, 1, webhooks.Total)
+	require.Equal(t, "chat", *webhooks.Items[0].Name)
+	require.Empty(t, webhooks.Items[0].Secret)
+
+	// Update the webhook without specifying the secret.
+	rsp = rapi.UpdateWebhook(ctx, events.UpdateWebhookParams{
+		ID: created.ID,
+		Webhook: &models.Webhook{
+			Name:        storkutil.Ptr("chat"),
+			URL:         storkutil.Ptr("https://chat.example.org/hooks/3"),
+			Enabled:     storkutil.Ptr(false),
+			Template:    `{"text": {{json .Text}}}`,
+			TextPattern: "failed",
+			MaxRetries:  storkutil.Ptr(int64(0)),
+		},
+	})
+	require.IsType(t, &events.UpdateWebhookOK{}, rsp)
+	updated := rsp.(*events.UpdateWebhookOK).Payload
+	require.False(t, *updated.Enabled)
+	require.True(t, updated.HasSecret)
+	require.Zero(t, updated.MachineID)
+	require.Zero(t, *updated.MaxRetries)
+
+	dbWebhook, err = dbmodel.GetWebhookByID(db, created.ID)
+	require.NoError(t, err)
+	require.Equal(t, "secret", dbWebhook.Secret)
+	require.Equal(t, "https://chat.example.org/hooks/3", dbWebhook.URL)
+	require.Nil(t, dbWebhook.Filters)
+
+	// Clear the secret.
+	rsp = rapi.UpdateWebhook(ctx, events.UpdateWebhookParams{
+		ID: created.ID,
+		Webhook: &models.Webhook{
+			Name:        storkutil.Ptr("chat"),
+			URL:         storkutil.Ptr("https://chat.example.org/hooks/3"),
+			ClearSecret: true,
+		},
+	})
+	require.IsType(t, &events.UpdateWebhookOK{}, rsp)
+	require.False(t, rsp.(*events.UpdateWebhookOK).Payload.HasSecret)
+
+	rsp = rapi.UpdateWebhook(ctx, events.UpdateWebhookParams{
+		ID: created.ID + 1,
+		Webhook: &models.Webhook{
+			Name: storkutil.Ptr("foo"),
+			URL:  storkutil.Ptr("https://foo.example.org"),
+		},
+	})
+	require.IsType(t, &events.UpdateWebhookDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*events.UpdateWebhookDefault)))
+
+	// Get the delivery log.
+	require.NoError(t, dbmodel.AddWebhookDelivery(db, &dbmodel.WebhookDelivery{
+		WebhookID:  created.ID,
+		Attempts:   2,
+		StatusCode: 502,
+		Error:      "webhook responded with status 502",
+	}))
+	rsp = rapi.GetWebhookDeliveries(ctx, events.GetWebhookDeliveriesParams{ID: created.ID})
+	require.IsType(t, &events.GetWebhookDeliveriesOK{}, rsp)
+	deliveries := rsp.(*events.GetWebhookDeliveriesOK).Payload
+	require.EqualValues(t, 1, deliveries.Total)
+	require.EqualValues(t, 2, deliveries.Items[0].Attempts)
+	require.EqualValues(t, 502, deliveries.Items[0].StatusCode)
+	require.False(t, deliveries.Items[0].Success)
+
+	// Delete the webhook.
+	rsp = rapi.DeleteWebhook(ctx, events.DeleteWebhookParams{ID: created.ID})
+	require.IsType(t, &events.DeleteWebhookOK{}, rsp)
+
+	rsp = rapi.DeleteWebhook(ctx, events.DeleteWebhookParams{ID: created.ID})
+	require.IsType(t, &events.DeleteWebhookDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*events.DeleteWebhookDefault)))
+
+	rsp = rapi.GetWebhook(ctx, events.GetWebhookParams{ID: created.ID})
+	require.IsType(t, &events.GetWebhookDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*events.GetWebhookDefault)))
+}
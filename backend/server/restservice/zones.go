@@ -0,0 +1,165 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/apps/bind9"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+)
+
+// Converts the zone fetched from the database to the REST API format.
+// The drift is specified for the secondary zones only.
+func zoneToRestAPI(dbZone *dbmodel.Bind9Zone, drift *bind9.ZoneDrift) *models.Zone {
+	zone := &models.Zone{
+		ID:        dbZone.ID,
+		Name:      dbZone.Name,
+		View:      dbZone.View,
+		Class:     dbZone.Class,
+		Type:      dbZone.Type,
+		Serial:    dbZone.Serial,
+		Loaded:    dbZone.Loaded,
+		LoadedAt:  strfmt.DateTime(dbZone.LoadedAt),
+		RefreshAt: strfmt.DateTime(dbZone.RefreshAt),
+		ExpiresAt: strfmt.DateTime(dbZone.ExpiresAt),
+		UpdatedAt: strfmt.DateTime(dbZone.UpdatedAt),
+		DaemonID:  dbZone.DaemonID,
+	}
+	if dbZone.Daemon != nil && dbZone.Daemon.App != nil {
+		zone.App = baseAppToRestAPI(dbZone.Daemon.App)
+	}
+	if drift != nil {
+		zone.DriftStatus = drift.Status
+		zone.SerialLag = drift.SerialLag
+		zone.LaggingSince = strfmt.DateTime(drift.LaggingSince)
+		if drift.HasPrimary {
+			primarySerial := drift.PrimarySerial
+			zone.PrimarySerial = &primarySerial
+		}
+	}
+	return zone
+}
+
+// Compares all secondary zones with their primary zones and returns
+// their drift indexed by the zone ID.
+func (r *RestAPI) getZonesDrift() (map[int64]*bind9.ZoneDrift, error) {
+	threshold, err := dbmodel.GetSettingInt(r.DB, bind9.ZoneLagThresholdSettingName)
+	if err != nil {
+		return nil, err
+	}
+	dbZones, err := dbmodel.GetAllBind9Zones(r.DB)
+	if err != nil {
+		return nil, err
+	}
+	return bind9.GetZonesDrift(dbZones, time.Duration(threshold)*time.Second, time.Now().UTC()), nil
+}
+
+func (r *RestAPI) getZones(offset, limit int64, filters *dbmodel.Bind9ZonesByPageFilters, sortField string, sortDir dbmodel.SortDirEnum) (*models.Zones, error) {
+	// get zones from db
+	dbZones, total, err := dbmodel.GetBind9ZonesByPage(r.DB, offset, limit, filters, sortField, sortDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// The secondary zones are compared with the primary zones. Skip it if
+	// there are no secondary zones on the page.
+	var drifts map[int64]*bind9.ZoneDrift
+	for i := range dbZones {
+		if dbZones[i].Type == dbmodel.Bind9ZoneTypeSecondary {
+			drifts, err = r.getZonesDrift()
+			if err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	// prepare response
+	zones := &models.Zones{
+		Total: total,
+	}
+
+	// go through zones from db and change their format to ReST one
+	for i := range dbZones {
+		zones.Items = append(zones.Items, zoneToRestAPI(&dbZones[i], drifts[dbZones[i].ID]))
+	}
+
+	return zones, nil
+}
+
+// Get list of zones served by the BIND 9 servers. The list can be filtered
+// by app ID, zone type and text.
+func (r *RestAPI) GetZones(ctx context.Context, params services.GetZonesParams) middleware.Responder {
+	var start int64
+	if params.Start != nil {
+		start = *params.Start
+	}
+
+	var limit int64 = 10
+	if params.Limit != nil {
+		limit = *params.Limit
+	}
+
+	filters := &dbmodel.Bind9ZonesByPageFilters{
+		AppID:    params.AppID,
+		ZoneType: params.ZoneType,
+		Text:     params.Text,
+	}
+
+	zones, err := r.getZones(start, limit, filters, "name", dbmodel.SortDirAsc)
+	if err != nil {
+		msg := "Cannot get zones from db"
+		log.Error(err)
+		rsp := services.NewGetZonesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := services.NewGetZonesOK().WithPayload(zones)
+	return rsp
+}
+
+// Get the zone by ID.
+func (r *RestAPI) GetZone(ctx context.Context, params services.GetZoneParams) middleware.Responder {
+	dbZone, err := dbmodel.GetBind9ZoneByID(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Cannot get zone with ID %d from db", params.ID)
+		log.Error(err)
+		rsp := services.NewGetZoneDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbZone == nil {
+		msg := fmt.Sprintf("Cannot find zone with ID %d", params.ID)
+		rsp := services.NewGetZoneDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	var drift *bind9.ZoneDrift
+	if dbZone.Type == dbmodel.Bind9ZoneTypeSecondary {
+		drifts, err := r.getZonesDrift()
+		if err != nil {
+			msg := fmt.Sprintf("Cannot compare zone with ID %d with the primary zone", params.ID)
+			log.Error(err)
+			rsp := services.NewGetZoneDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+		drift = drifts[dbZone.ID]
+	}
+
+	rsp := services.NewGetZoneOK().WithPayload(zoneToRestAPI(dbZone, drift))
+	return rsp
+}
@@ -0,0 +1,151 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/restapi/operations/search"
+	"isc.org/stork/server/gen/restapi/operations/services"
+)
+
+// Adds a machine with a BIND 9 app serving the specified zones.
+func addTestBind9AppWithZones(t *testing.T, db *pg.DB, address string, zones []*dbmodel.Bind9Zone) *dbmodel.App {
+	m := &dbmodel.Machine{
+		Address:    address,
+		AgentPort:  8080,
+		Authorized: true,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	var accessPoints []*dbmodel.AccessPoint
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "127.0.0.1", "", 953, false)
+	app := &dbmodel.App{
+		MachineID:    m.ID,
+		Type:         dbmodel.AppTypeBind9,
+		Active:       true,
+		AccessPoints: accessPoints,
+		Daemons: []*dbmodel.Daemon{
+			dbmodel.NewBind9Daemon(true),
+		},
+	}
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+
+	err = dbmodel.CommitBind9Zones(db, app.Daemons[0].ID, zones)
+	require.NoError(t, err)
+	return app
+}
+
+// Test getting the list of zones via the REST API.
+func TestGetZones(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := dbmodel.InitializeSettings(db, 0)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	rapi, err := NewRestAPI(dbSettings, db, fa)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	// No zones.
+	rsp := rapi.GetZones(ctx, services.GetZonesParams{})
+	require.IsType(t, &services.GetZonesOK{}, rsp)
+	okRsp := rsp.(*services.GetZonesOK)
+	require.Empty(t, okRsp.Payload.Items)
+	require.Zero(t, okRsp.Payload.Total)
+
+	app1 := addTestBind9AppWithZones(t, db, "host1", []*dbmodel.Bind9Zone{
+		{View: "_default", Name: "example.org", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary, Serial: 5, Loaded: true},
+		{View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary, Serial: 3, Loaded: true},
+	})
+	app2 := addTestBind9AppWithZones(t, db, "host2", []*dbmodel.Bind9Zone{
+		{View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypeSecondary, Serial: 2, Loaded: true},
+	})
+
+	// All zones.
+	rsp = rapi.GetZones(ctx, services.GetZonesParams{})
+	require.IsType(t, &services.GetZonesOK{}, rsp)
+	okRsp = rsp.(*services.GetZonesOK)
+	require.Len(t, okRsp.Payload.Items, 3)
+	require.EqualValues(t, 3, okRsp.Payload.Total)
+	require.Equal(t, "example.com", okRsp.Payload.Items[0].Name)
+	require.Equal(t, "example.com", okRsp.Payload.Items[1].Name)
+	require.Equal(t, "example.org", okRsp.Payload.Items[2].Name)
+	require.NotNil(t, okRsp.Payload.Items[2].App)
+	require.Equal(t, app1.ID, okRsp.Payload.Items[2].App.ID)
+	require.Equal(t, "host1", okRsp.Payload.Items[2].App.Machine.Address)
+	require.EqualValues(t, 5, okRsp.Payload.Items[2].Serial)
+	require.True(t, okRsp.Payload.Items[2].Loaded)
+
+	// Filter by app.
+	rsp = rapi.GetZones(ctx, services.GetZonesParams{AppID: &app2.ID})
+	require.IsType(t, &services.GetZonesOK{}, rsp)
+	okRsp = rsp.(*services.GetZonesOK)
+	require.Len(t, okRsp.Payload.Items, 1)
+	require.Equal(t, dbmodel.Bind9ZoneTypeSecondary, okRsp.Payload.Items[0].Type)
+
+	// The secondary zone is behind the primary zone.
+	require.Equal(t, "lagging", okRsp.Payload.Items[0].DriftStatus)
+	require.NotNil(t, okRsp.Payload.Items[0].PrimarySerial)
+	require.EqualValues(t, 3, *okRsp.Payload.Items[0].PrimarySerial)
+	require.EqualValues(t, 1, okRsp.Payload.Items[0].SerialLag)
+
+	// Filter by type and text.
+	zoneType := dbmodel.Bind9ZoneTypePrimary
+	text := "com"
+	rsp = rapi.GetZones(ctx, services.GetZonesParams{ZoneType: &zoneType, Text: &text})
+	require.IsType(t, &services.GetZonesOK{}, rsp)
+	okRsp = rsp.(*services.GetZonesOK)
+	require.Len(t, okRsp.Payload.Items, 1)
+	require.Equal(t, app1.ID, okRsp.Payload.Items[0].App.ID)
+
+	// Get zone by ID.
+	zoneID := okRsp.Payload.Items[0].ID
+	rsp = rapi.GetZone(ctx, services.GetZoneParams{ID: zoneID})
+	require.IsType(t, &services.GetZoneOK{}, rsp)
+	zone := rsp.(*services.GetZoneOK).Payload
+	require.Equal(t, "example.com", zone.Name)
+	require.Equal(t, "_default", zone.View)
+	require.EqualValues(t, 3, zone.Serial)
+	require.Empty(t, zone.DriftStatus)
+	require.Nil(t, zone.PrimarySerial)
+
+	// Non-existing zone.
+	rsp = rapi.GetZone(ctx, services.GetZoneParams{ID: zoneID + 100})
+	require.IsType(t, &services.GetZoneDefault{}, rsp)
+	defaultRsp := rsp.(*services.GetZoneDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+}
+
+// Test that the zones are returned in the search results.
+func TestSearchRecordsZones(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	rapi, err := NewRestAPI(dbSettings, db, fa)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	addTestBind9AppWithZones(t, db, "host1", []*dbmodel.Bind9Zone{
+		{View: "_default", Name: "zebra.example.org", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary},
+		{View: "_default", Name: "example.com", Class: "IN", Type: dbmodel.Bind9ZoneTypePrimary},
+	})
+
+	text := "zebra"
+	rsp := rapi.SearchRecords(ctx, search.SearchRecordsParams{Text: &text})
+	require.IsType(t, &search.SearchRecordsOK{}, rsp)
+	okRsp := rsp.(*search.SearchRecordsOK)
+	require.Len(t, okRsp.Payload.Zones.Items, 1)
+	require.EqualValues(t, 1, okRsp.Payload.Zones.Total)
+	require.Equal(t, "zebra.example.org", okRsp.Payload.Zones.Items[0].Name)
+}
@@ -16,12 +16,14 @@ import (
 	"isc.org/stork/server/apps"
 	"isc.org/stork/server/apps/bind9"
 	"isc.org/stork/server/apps/kea"
+	"isc.org/stork/server/auditlog"
 	"isc.org/stork/server/certs"
 	"isc.org/stork/server/config"
 	"isc.org/stork/server/configreview"
 	dbops "isc.org/stork/server/database"
 	dbmodel "isc.org/stork/server/database/model"
 	"isc.org/stork/server/eventcenter"
+	"isc.org/stork/server/forecast"
 	"isc.org/stork/server/hookmanager"
 	"isc.org/stork/server/metrics"
 	"isc.org/stork/server/restservice"
@@ -60,7 +62,15 @@ type StorkServer struct {
 
 	MetricsCollector metrics.Collector
 
-	EventCenter eventcenter.EventCenter
+	AuditLogPruner *auditlog.Pruner
+
+	EventForwarderSettings eventcenter.ForwarderSettings
+	EventCenter            eventcenter.EventCenter
+
+	EventRetentionSettings eventcenter.RetentionSettings
+	EventPruner            *eventcenter.Pruner
+
+	Forecaster *forecast.Forecaster
 
 	ReviewDispatcher configreview.Dispatcher
 	// Configuration manager instance. Note that it inherits some fields
@@ -85,6 +95,7 @@ type Settings struct {
 	EnableMetricsEndpoint bool   `short:"m" long:"metrics" description:"Enable Prometheus /metrics endpoint (no auth)" env:"STORK_SERVER_ENABLE_METRICS"`
 	InitialPullerInterval int64  `long:"initial-puller-interval" description:"Initial interval used by pullers fetching data from Kea; if not provided the recommended values for each puller are used" env:"STORK_SERVER_INITIAL_PULLER_INTERVAL"`
 	HookDirectory         string `long:"hook-directory" description:"The path to the hook directory" env:"STORK_SERVER_HOOK_DIRECTORY" default:"/var/lib/stork-server/hooks"`
+	DisableKeaPoolStats   bool   `long:"disable-kea-pool-stats" description:"Disable pulling the per-pool statistics from Kea 2.3.0 and later" env:"STORK_SERVER_DISABLE_KEA_POOL_STATS"`
 }
 
 // Parse the command line arguments into GO structures.
@@ -146,6 +157,18 @@ STORK_LOG_LEVEL variable. Allowed values are: DEBUG, INFO, WARN, ERROR.`
 		return
 	}
 
+	// Process event forwarding specific args.
+	_, err = parser.AddGroup("Event Forwarding Flags", "", &ss.EventForwarderSettings)
+	if err != nil {
+		return
+	}
+
+	// Process event retention specific args.
+	_, err = parser.AddGroup("Event Retention Flags", "", &ss.EventRetentionSettings)
+	if err != nil {
+		return
+	}
+
 	// Do args parsing.
 	if _, err := parser.Parse(); err != nil {
 		var flagsError *flags.Error
@@ -218,7 +241,10 @@ func (ss *StorkServer) Bootstrap(reload bool) (err error) {
 	}
 
 	// setup event center
-	ss.EventCenter = eventcenter.NewEventCenter(ss.DB)
+	ss.EventCenter, err = eventcenter.NewEventCenter(ss.DB, &ss.EventForwarderSettings)
+	if err != nil {
+		return err
+	}
 
 	// setup connected agents
 	ss.Agents = agentcomm.NewConnectedAgents(&ss.AgentsSettings, ss.EventCenter, caCertPEM, serverCertPEM, serverKeyPEM)
@@ -263,8 +289,14 @@ func (ss *StorkServer) Bootstrap(reload bool) (err error) {
 		return err
 	}
 
+	// setup bind9 zone drift checker
+	ss.Pullers.Bind9ZoneDriftChecker, err = bind9.NewZoneDriftChecker(ss.DB, ss.Agents, ss.EventCenter)
+	if err != nil {
+		return err
+	}
+
 	// setup kea stats puller
-	ss.Pullers.KeaStatsPuller, err = kea.NewStatsPuller(ss.DB, ss.Agents)
+	ss.Pullers.KeaStatsPuller, err = kea.NewStatsPuller(ss.DB, ss.Agents, ss.EventCenter, !ss.GeneralSettings.DisableKeaPoolStats)
 	if err != nil {
 		return err
 	}
@@ -281,8 +313,26 @@ func (ss *StorkServer) Bootstrap(reload bool) (err error) {
 		return err
 	}
 
+	// Setup the deletion of the expired audit log entries.
+	ss.AuditLogPruner, err = auditlog.NewPruner(ss.DB)
+	if err != nil {
+		return err
+	}
+
+	// Setup the deletion of the events exceeding the retention limits.
+	ss.EventPruner, err = eventcenter.NewPruner(ss.DB, &ss.EventRetentionSettings)
+	if err != nil {
+		return err
+	}
+
+	// Setup the forecasting of the subnet and shared network exhaustion.
+	ss.Forecaster, err = forecast.NewForecaster(ss.DB, ss.EventCenter)
+	if err != nil {
+		return err
+	}
+
 	if ss.GeneralSettings.EnableMetricsEndpoint {
-		ss.MetricsCollector, err = metrics.NewCollector(ss.DB)
+		ss.MetricsCollector, err = metrics.NewCollector(ss.DB, ss.Pullers, ss.Agents, ss.ReviewDispatcher)
 		if err != nil {
 			return err
 		}
@@ -308,8 +358,12 @@ func (ss *StorkServer) Bootstrap(reload bool) (err error) {
 		ss.Pullers.HAStatusPuller.Shutdown()
 		ss.Pullers.KeaHostsPuller.Shutdown()
 		ss.Pullers.KeaStatsPuller.Shutdown()
+		ss.Pullers.Bind9ZoneDriftChecker.Shutdown()
 		ss.Pullers.Bind9StatsPuller.Shutdown()
 		ss.Pullers.AppsStatePuller.Shutdown()
+		ss.AuditLogPruner.Shutdown()
+		ss.EventPruner.Shutdown()
+		ss.Forecaster.Shutdown()
 		if ss.MetricsCollector != nil {
 			ss.MetricsCollector.Shutdown()
 		}
@@ -356,8 +410,12 @@ func (ss *StorkServer) Shutdown(reload bool) {
 		ss.Pullers.HAStatusPuller.Shutdown()
 		ss.Pullers.KeaHostsPuller.Shutdown()
 		ss.Pullers.KeaStatsPuller.Shutdown()
+		ss.Pullers.Bind9ZoneDriftChecker.Shutdown()
 		ss.Pullers.Bind9StatsPuller.Shutdown()
 		ss.Pullers.AppsStatePuller.Shutdown()
+		ss.AuditLogPruner.Shutdown()
+		ss.EventPruner.Shutdown()
+		ss.Forecaster.Shutdown()
 		ss.Agents.Shutdown()
 		ss.EventCenter.Shutdown()
 		ss.ReviewDispatcher.Shutdown()
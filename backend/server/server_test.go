@@ -35,7 +35,9 @@ func getExpectedSwitches() []string {
 		"--rest-max-header-size", "--rest-host", "--rest-port", "--rest-listen-limit",
 		"--rest-keep-alive", "--rest-read-timeout", "--rest-write-timeout", "--rest-tls-certificate",
 		"--rest-tls-key", "--rest-tls-ca", "--rest-static-files-dir", "--initial-puller-interval",
-		"--env-file", "--use-env-file", "--db-password",
+		"--env-file", "--use-env-file", "--db-password", "--events-syslog-address",
+		"--events-syslog-protocol", "--events-syslog-facility", "--events-syslog-tls-ca",
+		"--events-json-file", "--events-archive-dir",
 	}
 }
 
@@ -164,8 +166,17 @@ func TestNewStorkServer(t *testing.T) {
 		"--rest-tls-key", "tlskey",
 		"--rest-tls-ca", "tlsca",
 		"--rest-static-files-dir", "staticdir",
+		"--rest-trusted-proxy", "192.0.2.1",
+		"--rest-trusted-proxy", "10.0.0.0/8",
 		"--initial-puller-interval", "54",
 		"--hook-directory", "hookdir",
+		"--disable-kea-pool-stats",
+		"--events-syslog-address", "syslog.example.org:6514",
+		"--events-syslog-protocol", "tls",
+		"--events-syslog-facility", "20",
+		"--events-syslog-tls-ca", "syslogca",
+		"--events-json-file", "events.json",
+		"--events-archive-dir", "archivedir",
 	)
 
 	// Act
@@ -198,8 +209,16 @@ func TestNewStorkServer(t *testing.T) {
 	require.EqualValues(t, "tlskey", ss.RestAPISettings.TLSCertificateKey)
 	require.EqualValues(t, "tlsca", ss.RestAPISettings.TLSCACertificate)
 	require.EqualValues(t, "staticdir", ss.RestAPISettings.StaticFilesDir)
+	require.Equal(t, []string{"192.0.2.1", "10.0.0.0/8"}, ss.RestAPISettings.TrustedProxies)
+	require.EqualValues(t, "syslog.example.org:6514", ss.EventForwarderSettings.SyslogAddress)
+	require.EqualValues(t, "tls", ss.EventForwarderSettings.SyslogProtocol)
+	require.EqualValues(t, 20, ss.EventForwarderSettings.SyslogFacility)
+	require.EqualValues(t, "syslogca", ss.EventForwarderSettings.SyslogTLSCA)
+	require.EqualValues(t, "events.json", ss.EventForwarderSettings.JSONFile)
+	require.EqualValues(t, "archivedir", ss.EventRetentionSettings.ArchiveDirectory)
 	require.EqualValues(t, 54, ss.GeneralSettings.InitialPullerInterval)
 	require.EqualValues(t, "hookdir", ss.GeneralSettings.HookDirectory)
+	require.True(t, ss.GeneralSettings.DisableKeaPoolStats)
 }
 
 // Test that the Stork Server is not constructed if the arguments are wrong.
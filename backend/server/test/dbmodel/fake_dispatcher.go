@@ -119,3 +119,9 @@ func (d *FakeDispatcher) ReviewInProgress(daemonID int64) bool {
 	d.CallLog = append(d.CallLog, FakeDispatcherCall{CallName: "ReviewInProgress", DaemonID: daemonID})
 	return d.InProgress
 }
+
+// Registers the call and returns no statistics.
+func (d *FakeDispatcher) GetCheckerStats() map[string]configreview.CheckerStats {
+	d.CallLog = append(d.CallLog, FakeDispatcherCall{CallName: "GetCheckerStats"})
+	return map[string]configreview.CheckerStats{}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	_ "embed"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+	"isc.org/stork/hooks/server/authenticationcallouts"
+)
+
+//go:embed icon.png
+var icon []byte
+
+// Subset of the LDAP connection methods used by the hook. It is
+// implemented by the ldap.Conn and allows replacing the connection in the
+// unit tests.
+type ldapConnection interface {
+	StartTLS(config *tls.Config) error
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	Close()
+}
+
+// Function establishing the connection to the LDAP server.
+type ldapDialer func(settings *settings) (ldapConnection, error)
+
+// Connects to the LDAP server specified in the settings. The ldaps://
+// connections use the TLS configuration from the settings.
+func dialLDAP(settings *settings) (ldapConnection, error) {
+	parsedURL, err := url.Parse(settings.URL)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid LDAP URL %s", settings.URL)
+	}
+	tlsConfig, err := settings.getTLSConfig(parsedURL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	conn, err := ldap.DialURL(settings.URL, ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot connect to the LDAP server %s", settings.URL)
+	}
+	conn.SetTimeout(settings.Timeout)
+	return conn, nil
+}
+
+// Callout carrier structure.
+type calloutCarrier struct {
+	settings *settings
+	dial     ldapDialer
+}
+
+// Creates the callout carrier using the specified settings.
+func newCalloutCarrier(settings *settings) *calloutCarrier {
+	return &calloutCarrier{
+		settings: settings,
+		dial:     dialLDAP,
+	}
+}
+
+// Closer interface implementation.
+func (c *calloutCarrier) Close() error {
+	// The connections are open only for the authentication time.
+	return nil
+}
+
+// Interface checks.
+var (
+	_ authenticationcallouts.AuthenticationCallouts     = (*calloutCarrier)(nil)
+	_ authenticationcallouts.AuthenticationMetadata     = (*metadata)(nil)
+	_ authenticationcallouts.AuthenticationMetadataForm = (*metadata)(nil)
+)
+
+// Authenticates the user in the LDAP server. It searches for the user entry
+// using the bind DN, and binds as the found user with the specified
+// password. The groups the user belongs to are mapped to the Stork groups.
+func (c *calloutCarrier) Authenticate(ctx context.Context, request *http.Request, identifier, secret *string) (*authenticationcallouts.User, error) {
+	// The empty password would result in an unauthenticated bind which
+	// succeeds in many LDAP servers.
+	if identifier == nil || secret == nil || strings.TrimSpace(*identifier) == "" || *secret == "" {
+		return nil, errors.New("missing LDAP user identifier or password")
+	}
+
+	conn, err := c.dial(c.settings)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if c.settings.StartTLS {
+		parsedURL, err := url.Parse(c.settings.URL)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid LDAP URL %s", c.settings.URL)
+		}
+		tlsConfig, err := c.settings.getTLSConfig(parsedURL.Hostname())
+		if err != nil {
+			return nil, err
+		}
+		if err = conn.StartTLS(tlsConfig); err != nil {
+			return nil, errors.Wrap(err, "cannot start TLS on the LDAP connection")
+		}
+	}
+
+	if c.settings.BindDN != "" {
+		if err = conn.Bind(c.settings.BindDN, c.settings.BindPassword); err != nil {
+			return nil, errors.Wrapf(err, "cannot bind to the LDAP server as %s", c.settings.BindDN)
+		}
+	}
+
+	entry, err := c.findUser(conn, strings.TrimSpace(*identifier))
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify the password.
+	if err = conn.Bind(entry.DN, *secret); err != nil {
+		return nil, errors.Wrapf(err, "invalid credentials of the LDAP user %s", entry.DN)
+	}
+
+	return c.newUser(entry), nil
+}
+
+// Searches for the entry of the user with the specified identifier. It
+// returns an error if there is no such user or the filter matches more than
+// one entry.
+func (c *calloutCarrier) findUser(conn ldapConnection, identifier string) (*ldap.Entry, error) {
+	filter := strings.ReplaceAll(c.settings.UserFilter, "%s", ldap.EscapeFilter(identifier))
+	attributes := []string{
+		c.settings.LoginAttribute,
+		c.settings.EmailAttribute,
+		c.settings.FirstNameAttribute,
+		c.settings.LastNameAttribute,
+		c.settings.GroupAttribute,
+	}
+	if c.settings.IDAttribute != "" {
+		attributes = append(attributes, c.settings.IDAttribute)
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		c.settings.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(c.settings.Timeout.Seconds()), false,
+		filter, attributes, nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, errors.Wrapf(err, "cannot search for the LDAP user %s", identifier)
+	}
+	switch {
+	case result == nil || len(result.Entries) == 0:
+		return nil, errors.Errorf("LDAP user %s not found", identifier)
+	case len(result.Entries) > 1:
+		return nil, errors.Errorf("LDAP user filter matches more than one entry for %s", identifier)
+	}
+	return result.Entries[0], nil
+}
+
+// Creates the Stork user from the LDAP entry. The groups are returned only
+// if the group mapping is configured. Otherwise, the Stork groups of the
+// user are managed in Stork.
+func (c *calloutCarrier) newUser(entry *ldap.Entry) *authenticationcallouts.User {
+	user := &authenticationcallouts.User{
+		ID:       entry.DN,
+		Login:    entry.GetAttributeValue(c.settings.LoginAttribute),
+		Email:    entry.GetAttributeValue(c.settings.EmailAttribute),
+		Name:     entry.GetAttributeValue(c.settings.FirstNameAttribute),
+		Lastname: entry.GetAttributeValue(c.settings.LastNameAttribute),
+	}
+	if c.settings.IDAttribute != "" {
+		user.ID = entry.GetAttributeValue(c.settings.IDAttribute)
+	}
+	if len(c.settings.GroupMap) > 0 {
+		user.Groups = []int{}
+		seen := make(map[int]bool)
+		for _, dn := range entry.GetAttributeValues(c.settings.GroupAttribute) {
+			if groupID, ok := c.settings.GroupMap[normalizeDN(dn)]; ok && !seen[groupID] {
+				seen[groupID] = true
+				user.Groups = append(user.Groups, groupID)
+			}
+		}
+	}
+	return user
+}
+
+// Does nothing because the hook doesn't maintain any sessions.
+func (c *calloutCarrier) Unauthenticate(ctx context.Context) error {
+	return nil
+}
+
+// Returns the metadata of the LDAP authentication method.
+func (c *calloutCarrier) GetMetadata() authenticationcallouts.AuthenticationMetadata {
+	return &metadata{}
+}
+
+// Metadata of the LDAP authentication method.
+type metadata struct{}
+
+// Returns the authentication method ID.
+func (m *metadata) GetID() string {
+	return "ldap"
+}
+
+// Returns the authentication method name.
+func (m *metadata) GetName() string {
+	return "LDAP"
+}
+
+// Returns the authentication method description.
+func (m *metadata) GetDescription() string {
+	return "Authentication using the credentials stored in the LDAP or Active Directory server"
+}
+
+// Returns the authentication method icon.
+func (m *metadata) GetIcon() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(icon)), nil
+}
+
+// Returns the label of the identifier field in the login form.
+func (m *metadata) GetIdentifierFormLabel() string {
+	return "Login"
+}
+
+// Returns the label of the secret field in the login form.
+func (m *metadata) GetSecretFormLabel() string {
+	return "Password"
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// Fake LDAP connection holding the user entries and their passwords.
+type fakeLDAPConnection struct {
+	entries   []*ldap.Entry
+	passwords map[string]string
+	binds     []string
+	filters   []string
+	startTLS  bool
+	closed    bool
+}
+
+// Records the StartTLS call.
+func (c *fakeLDAPConnection) StartTLS(config *tls.Config) error {
+	c.startTLS = true
+	return nil
+}
+
+// Checks the password of the entry.
+func (c *fakeLDAPConnection) Bind(username, password string) error {
+	c.binds = append(c.binds, username)
+	if expected, ok := c.passwords[username]; ok && expected == password {
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+// Returns the entries whose uid matches the filter. It supports only the
+// (uid=%s) filter.
+func (c *fakeLDAPConnection) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	c.filters = append(c.filters, request.Filter)
+	result := &ldap.SearchResult{}
+	for _, entry := range c.entries {
+		if request.Filter == "(uid="+ldap.EscapeFilter(entry.GetAttributeValue("uid"))+")" {
+			result.Entries = append(result.Entries, entry)
+		}
+	}
+	return result, nil
+}
+
+// Records the Close call.
+func (c *fakeLDAPConnection) Close() {
+	c.closed = true
+}
+
+// Creates the carrier using the fake connection with two users: john
+// belonging to the admins and noc groups, and jane belonging to no groups.
+func newTestCarrier() (*calloutCarrier, *fakeLDAPConnection) {
+	conn := &fakeLDAPConnection{
+		entries: []*ldap.Entry{
+			ldap.NewEntry("uid=john,ou=users,dc=example,dc=org", map[string][]string{
+				"uid":       {"john"},
+				"mail":      {"john@example.org"},
+				"givenName": {"John"},
+				"sn":        {"Smith"},
+				"memberOf": {
+					"cn=Admins,ou=groups,dc=example,dc=org",
+					"cn=noc, ou=groups, dc=example, dc=org",
+					"cn=other,ou=groups,dc=example,dc=org",
+				},
+			}),
+			ldap.NewEntry("uid=jane,ou=users,dc=example,dc=org", map[string][]string{
+				"uid":       {"jane"},
+				"givenName": {"Jane"},
+				"sn":        {"Doe"},
+			}),
+		},
+		passwords: map[string]string{
+			"cn=stork,dc=example,dc=org":          "stork-pass",
+			"uid=john,ou=users,dc=example,dc=org": "john-pass",
+			"uid=jane,ou=users,dc=example,dc=org": "jane-pass",
+		},
+	}
+	carrier := newCalloutCarrier(&settings{
+		URL:                "ldap://ldap.example.org",
+		BindDN:             "cn=stork,dc=example,dc=org",
+		BindPassword:       "stork-pass",
+		BaseDN:             "ou=users,dc=example,dc=org",
+		UserFilter:         "(uid=%s)",
+		LoginAttribute:     "uid",
+		EmailAttribute:     "mail",
+		FirstNameAttribute: "givenName",
+		LastNameAttribute:  "sn",
+		GroupAttribute:     "memberOf",
+		GroupMap: map[string]int{
+			"cn=admins,ou=groups,dc=example,dc=org": 2,
+			"cn=noc,ou=groups,dc=example,dc=org":    3,
+		},
+	})
+	carrier.dial = func(*settings) (ldapConnection, error) {
+		return conn, nil
+	}
+	return carrier, conn
+}
+
+// Test that the user is authenticated and the groups are mapped.
+func TestAuthenticate(t *testing.T) {
+	carrier, conn := newTestCarrier()
+
+	identifier := "john"
+	secret := "john-pass"
+	user, err := carrier.Authenticate(context.Background(), nil, &identifier, &secret)
+	require.NoError(t, err)
+	require.NotNil(t, user)
+	require.Equal(t, "uid=john,ou=users,dc=example,dc=org", user.ID)
+	require.Equal(t, "john", user.Login)
+	require.Equal(t, "john@example.org", user.Email)
+	require.Equal(t, "John", user.Name)
+	require.Equal(t, "Smith", user.Lastname)
+	require.Equal(t, []int{2, 3}, user.Groups)
+
+	// The hook binds with the bind DN and then as the user.
+	require.Equal(t, []string{"cn=stork,dc=example,dc=org", "uid=john,ou=users,dc=example,dc=org"}, conn.binds)
+	require.False(t, conn.startTLS)
+	require.True(t, conn.closed)
+}
+
+// Test that the user without mapped groups gets no groups and the groups
+// are not returned when the mapping is not configured.
+func TestAuthenticateGroups(t *testing.T) {
+	carrier, _ := newTestCarrier()
+
+	identifier := "jane"
+	secret := "jane-pass"
+	user, err := carrier.Authenticate(context.Background(), nil, &identifier, &secret)
+	require.NoError(t, err)
+	require.NotNil(t, user.Groups)
+	require.Empty(t, user.Groups)
+
+	carrier.settings.GroupMap = nil
+	user, err = carrier.Authenticate(context.Background(), nil, &identifier, &secret)
+	require.NoError(t, err)
+	require.Nil(t, user.Groups)
+}
+
+// Test that the ID attribute is used instead of the DN.
+func TestAuthenticateIDAttribute(t *testing.T) {
+	carrier, _ := newTestCarrier()
+	carrier.settings.IDAttribute = "uid"
+
+	identifier := "john"
+	secret := "john-pass"
+	user, err := carrier.Authenticate(context.Background(), nil, &identifier, &secret)
+	require.NoError(t, err)
+	require.Equal(t, "john", user.ID)
+}
+
+// Test that the connection is upgraded with StartTLS.
+func TestAuthenticateStartTLS(t *testing.T) {
+	carrier, conn := newTestCarrier()
+	carrier.settings.StartTLS = true
+
+	identifier := "john"
+	secret := "john-pass"
+	_, err := carrier.Authenticate(context.Background(), nil, &identifier, &secret)
+	require.NoError(t, err)
+	require.True(t, conn.startTLS)
+}
+
+// Test the authentication failures.
+func TestAuthenticateFailures(t *testing.T) {
+	carrier, conn := newTestCarrier()
+
+	authenticate := func(identifier, secret string) error {
+		_, err := carrier.Authenticate(context.Background(), nil, &identifier, &secret)
+		return err
+	}
+
+	// Wrong password.
+	require.Error(t, authenticate("john", "jane-pass"))
+	// Empty password must not result in an unauthenticated bind.
+	require.Error(t, authenticate("john", ""))
+	require.Error(t, authenticate(" ", "john-pass"))
+	// Unknown user.
+	require.Error(t, authenticate("joe", "john-pass"))
+	// Missing credentials.
+	_, err := carrier.Authenticate(context.Background(), nil, nil, nil)
+	require.Error(t, err)
+
+	// The identifier is escaped in the filter.
+	require.Error(t, authenticate("*", "john-pass"))
+	require.Contains(t, conn.filters, `(uid=\2a)`)
+
+	// Invalid bind DN password.
+	carrier.settings.BindPassword = "wrong"
+	require.Error(t, authenticate("john", "john-pass"))
+
+	// Connection failure.
+	carrier.dial = func(*settings) (ldapConnection, error) {
+		return nil, errors.New("connection refused")
+	}
+	require.Error(t, authenticate("john", "john-pass"))
+}
+
+// Test that the filter matching many users is rejected.
+func TestAuthenticateAmbiguousUser(t *testing.T) {
+	carrier, conn := newTestCarrier()
+	conn.entries = append(conn.entries, ldap.NewEntry("uid=john,ou=other,dc=example,dc=org", map[string][]string{
+		"uid": {"john"},
+	}))
+
+	identifier := "john"
+	secret := "john-pass"
+	_, err := carrier.Authenticate(context.Background(), nil, &identifier, &secret)
+	require.Error(t, err)
+}
+
+// Test the authentication method metadata.
+func TestGetMetadata(t *testing.T) {
+	carrier, _ := newTestCarrier()
+	metadata := carrier.GetMetadata()
+	require.Equal(t, "ldap", metadata.GetID())
+	require.Equal(t, "LDAP", metadata.GetName())
+	require.NotEmpty(t, metadata.GetDescription())
+
+	reader, err := metadata.GetIcon()
+	require.NoError(t, err)
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, icon, content)
+
+	form, ok := metadata.(interface {
+		GetIdentifierFormLabel() string
+		GetSecretFormLabel() string
+	})
+	require.True(t, ok)
+	require.Equal(t, "Login", form.GetIdentifierFormLabel())
+	require.Equal(t, "Password", form.GetSecretFormLabel())
+
+	require.NoError(t, carrier.Unauthenticate(context.Background()))
+	require.NoError(t, carrier.Close())
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Returns the settings of the local test LDAP server started with the
+// docker-compose.yaml file. The test is skipped if the server URL is not
+// specified in the STORK_HOOK_LDAP_TEST_URL environment variable.
+func getIntegrationTestSettings(t *testing.T) *settings {
+	url := os.Getenv("STORK_HOOK_LDAP_TEST_URL")
+	if url == "" {
+		t.Skip("STORK_HOOK_LDAP_TEST_URL is not set")
+	}
+	return &settings{
+		URL:                 url,
+		SkipTLSVerification: true,
+		BindDN:              "cn=admin,dc=example,dc=org",
+		BindPassword:        "admin",
+		BaseDN:              "ou=users,dc=example,dc=org",
+		UserFilter:          "(&(objectClass=inetOrgPerson)(uid=%s))",
+		LoginAttribute:      "uid",
+		EmailAttribute:      "mail",
+		FirstNameAttribute:  "givenName",
+		LastNameAttribute:   "sn",
+		GroupAttribute:      "memberOf",
+		GroupMap: map[string]int{
+			"cn=stork-admins,ou=groups,dc=example,dc=org":    2,
+			"cn=stork-operators,ou=groups,dc=example,dc=org": 4,
+		},
+		Timeout: 5 * time.Second,
+	}
+}
+
+// Test the authentication against the local LDAP server.
+func TestIntegrationAuthenticate(t *testing.T) {
+	for _, startTLS := range []bool{false, true} {
+		settings := getIntegrationTestSettings(t)
+		settings.StartTLS = startTLS
+		carrier := newCalloutCarrier(settings)
+
+		identifier := "john"
+		secret := "john-pass"
+		user, err := carrier.Authenticate(context.Background(), nil, &identifier, &secret)
+		require.NoError(t, err)
+		require.Equal(t, "uid=john,ou=users,dc=example,dc=org", user.ID)
+		require.Equal(t, "john@example.org", user.Email)
+		require.ElementsMatch(t, []int{2, 4}, user.Groups)
+
+		identifier = "jane"
+		secret = "jane-pass"
+		user, err = carrier.Authenticate(context.Background(), nil, &identifier, &secret)
+		require.NoError(t, err)
+		require.Equal(t, []int{4}, user.Groups)
+
+		secret = "john-pass"
+		_, err = carrier.Authenticate(context.Background(), nil, &identifier, &secret)
+		require.Error(t, err)
+	}
+}
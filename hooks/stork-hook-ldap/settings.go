@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Prefix of the environment variables configuring the hook.
+const envPrefix = "STORK_SERVER_HOOK_LDAP_"
+
+// Hook settings. They are read from the environment variables because the
+// Stork server doesn't pass any configuration to the hooks.
+type settings struct {
+	// LDAP server URL, e.g. ldap://ldap.example.org:389 or
+	// ldaps://ldap.example.org:636.
+	URL string
+	// Upgrades the plain connection to TLS using StartTLS.
+	StartTLS bool
+	// Path to the PEM file with the CA certificates used to verify the LDAP
+	// server certificate. The system CAs are used if it is empty.
+	RootCAFile string
+	// Disables the server certificate verification. It should only be used
+	// for testing.
+	SkipTLSVerification bool
+	// DN and password used to search for the users. The anonymous search is
+	// performed if the DN is empty.
+	BindDN       string
+	BindPassword string
+	// DN of the subtree containing the users.
+	BaseDN string
+	// Filter used to find the user entry. The %s placeholder is replaced
+	// with the escaped identifier specified in the login form.
+	UserFilter string
+	// Names of the user entry attributes. The entry DN is used as the user
+	// ID if the ID attribute is empty.
+	IDAttribute        string
+	LoginAttribute     string
+	EmailAttribute     string
+	FirstNameAttribute string
+	LastNameAttribute  string
+	// Name of the user entry attribute holding the DNs of the groups the
+	// user belongs to.
+	GroupAttribute string
+	// Maps the LDAP group DNs (lowercase) to the Stork group IDs. The Stork
+	// groups are not managed by the hook if the map is empty.
+	GroupMap map[string]int
+	// Timeout of the LDAP operations.
+	Timeout time.Duration
+}
+
+// Returns the value of the environment variable with the hook prefix or
+// the default value if the variable is not set.
+func getEnv(name, defaultValue string) string {
+	if value, ok := os.LookupEnv(envPrefix + name); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// Returns the boolean value of the environment variable with the hook
+// prefix. The variable not set is false.
+func getEnvBool(name string) (bool, error) {
+	value := getEnv(name, "")
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid value of %s%s", envPrefix, name)
+	}
+	return parsed, nil
+}
+
+// Parses the mapping of the LDAP groups to the Stork groups. The mapping
+// is a semicolon-separated list of the entries in the format of
+// GROUP-ID:GROUP-DN, e.g.
+// "1:cn=stork-super-admins,ou=groups,dc=example,dc=org;3:cn=noc,ou=groups,dc=example,dc=org".
+func parseGroupMap(value string) (map[string]int, error) {
+	groupMap := make(map[string]int)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, dn, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, errors.Errorf("invalid group mapping entry %q, expected GROUP-ID:GROUP-DN", entry)
+		}
+		groupID, err := strconv.Atoi(strings.TrimSpace(id))
+		if err != nil || groupID <= 0 {
+			return nil, errors.Errorf("invalid Stork group ID in the group mapping entry %q", entry)
+		}
+		dn = normalizeDN(dn)
+		if dn == "" {
+			return nil, errors.Errorf("empty group DN in the group mapping entry %q", entry)
+		}
+		groupMap[dn] = groupID
+	}
+	return groupMap, nil
+}
+
+// Normalizes the DN for comparisons. The LDAP DNs are case-insensitive and
+// may contain spaces after the RDN separators.
+func normalizeDN(dn string) string {
+	rdns := strings.Split(strings.TrimSpace(dn), ",")
+	for i := range rdns {
+		rdns[i] = strings.TrimSpace(rdns[i])
+	}
+	return strings.ToLower(strings.Join(rdns, ","))
+}
+
+// Reads the hook settings from the environment variables.
+func loadSettingsFromEnv() (*settings, error) {
+	s := &settings{
+		URL:                getEnv("URL", ""),
+		RootCAFile:         getEnv("ROOT_CA", ""),
+		BindDN:             getEnv("BIND_DN", ""),
+		BindPassword:       getEnv("BIND_PASSWORD", ""),
+		BaseDN:             getEnv("BASE_DN", ""),
+		UserFilter:         getEnv("USER_FILTER", "(uid=%s)"),
+		IDAttribute:        getEnv("ATTR_ID", ""),
+		LoginAttribute:     getEnv("ATTR_LOGIN", "uid"),
+		EmailAttribute:     getEnv("ATTR_EMAIL", "mail"),
+		FirstNameAttribute: getEnv("ATTR_FIRST_NAME", "givenName"),
+		LastNameAttribute:  getEnv("ATTR_LAST_NAME", "sn"),
+		GroupAttribute:     getEnv("ATTR_GROUP", "memberOf"),
+		Timeout:            10 * time.Second,
+	}
+
+	var err error
+	if s.StartTLS, err = getEnvBool("START_TLS"); err != nil {
+		return nil, err
+	}
+	if s.SkipTLSVerification, err = getEnvBool("SKIP_TLS_VERIFICATION"); err != nil {
+		return nil, err
+	}
+	if s.GroupMap, err = parseGroupMap(getEnv("GROUP_MAP", "")); err != nil {
+		return nil, err
+	}
+	if timeout := getEnv("TIMEOUT", ""); timeout != "" {
+		if s.Timeout, err = time.ParseDuration(timeout); err != nil {
+			return nil, errors.Wrapf(err, "invalid value of %sTIMEOUT", envPrefix)
+		}
+	}
+
+	if err = s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Checks if the settings are complete and consistent.
+func (s *settings) validate() error {
+	switch {
+	case s.URL == "":
+		return errors.Errorf("%sURL is required", envPrefix)
+	case s.BaseDN == "":
+		return errors.Errorf("%sBASE_DN is required", envPrefix)
+	case !strings.Contains(s.UserFilter, "%s"):
+		return errors.Errorf("%sUSER_FILTER must contain the %%s placeholder", envPrefix)
+	case s.StartTLS && strings.HasPrefix(strings.ToLower(s.URL), "ldaps://"):
+		return errors.New("StartTLS cannot be used with the ldaps:// URL")
+	case s.BindDN != "" && s.BindPassword == "":
+		return errors.Errorf("%sBIND_PASSWORD is required when the bind DN is specified", envPrefix)
+	}
+	return nil
+}
+
+// Returns the TLS configuration used for the ldaps:// connections and
+// StartTLS.
+func (s *settings) getTLSConfig(serverName string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         serverName,
+		InsecureSkipVerify: s.SkipTLSVerification, //nolint:gosec
+	}
+	if s.RootCAFile != "" {
+		pem, err := os.ReadFile(s.RootCAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read the root CA file %s", s.RootCAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no valid certificates found in the root CA file %s", s.RootCAFile)
+		}
+		config.RootCAs = pool
+	}
+	return config, nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the group mapping is parsed and the DNs are normalized.
+func TestParseGroupMap(t *testing.T) {
+	groupMap, err := parseGroupMap("1:cn=Admins, ou=groups,dc=example,dc=org; 3 : cn=noc,ou=groups,dc=example,dc=org;")
+	require.NoError(t, err)
+	require.Len(t, groupMap, 2)
+	require.Equal(t, 1, groupMap["cn=admins,ou=groups,dc=example,dc=org"])
+	require.Equal(t, 3, groupMap["cn=noc,ou=groups,dc=example,dc=org"])
+
+	groupMap, err = parseGroupMap("")
+	require.NoError(t, err)
+	require.Empty(t, groupMap)
+
+	_, err = parseGroupMap("cn=admins,dc=example,dc=org")
+	require.Error(t, err)
+	_, err = parseGroupMap("admin:cn=admins,dc=example,dc=org")
+	require.Error(t, err)
+	_, err = parseGroupMap("0:cn=admins,dc=example,dc=org")
+	require.Error(t, err)
+	_, err = parseGroupMap("1: ")
+	require.Error(t, err)
+}
+
+// Test that the settings are read from the environment variables.
+func TestLoadSettingsFromEnv(t *testing.T) {
+	t.Setenv(envPrefix+"URL", "ldap://ldap.example.org")
+	t.Setenv(envPrefix+"BASE_DN", "ou=users,dc=example,dc=org")
+	t.Setenv(envPrefix+"BIND_DN", "cn=stork,dc=example,dc=org")
+	t.Setenv(envPrefix+"BIND_PASSWORD", "secret")
+	t.Setenv(envPrefix+"START_TLS", "true")
+	t.Setenv(envPrefix+"USER_FILTER", "(&(objectClass=person)(sAMAccountName=%s))")
+	t.Setenv(envPrefix+"ATTR_ID", "objectGUID")
+	t.Setenv(envPrefix+"GROUP_MAP", "2:cn=admins,dc=example,dc=org")
+	t.Setenv(envPrefix+"TIMEOUT", "3s")
+
+	s, err := loadSettingsFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, "ldap://ldap.example.org", s.URL)
+	require.Equal(t, "ou=users,dc=example,dc=org", s.BaseDN)
+	require.Equal(t, "cn=stork,dc=example,dc=org", s.BindDN)
+	require.Equal(t, "secret", s.BindPassword)
+	require.True(t, s.StartTLS)
+	require.False(t, s.SkipTLSVerification)
+	require.Equal(t, "(&(objectClass=person)(sAMAccountName=%s))", s.UserFilter)
+	require.Equal(t, "objectGUID", s.IDAttribute)
+	require.Equal(t, "uid", s.LoginAttribute)
+	require.Equal(t, "memberOf", s.GroupAttribute)
+	require.Equal(t, map[string]int{"cn=admins,dc=example,dc=org": 2}, s.GroupMap)
+	require.Equal(t, 3*time.Second, s.Timeout)
+
+	// Invalid boolean.
+	t.Setenv(envPrefix+"START_TLS", "maybe")
+	_, err = loadSettingsFromEnv()
+	require.Error(t, err)
+}
+
+// Test the settings validation.
+func TestValidateSettings(t *testing.T) {
+	valid := func() *settings {
+		return &settings{
+			URL:        "ldap://ldap.example.org",
+			BaseDN:     "dc=example,dc=org",
+			UserFilter: "(uid=%s)",
+		}
+	}
+	require.NoError(t, valid().validate())
+
+	s := valid()
+	s.URL = ""
+	require.Error(t, s.validate())
+
+	s = valid()
+	s.BaseDN = ""
+	require.Error(t, s.validate())
+
+	s = valid()
+	s.UserFilter = "(uid=admin)"
+	require.Error(t, s.validate())
+
+	s = valid()
+	s.URL = "ldaps://ldap.example.org"
+	s.StartTLS = true
+	require.Error(t, s.validate())
+
+	s = valid()
+	s.BindDN = "cn=stork,dc=example,dc=org"
+	require.Error(t, s.validate())
+}
+
+// Test that the root CA file is loaded into the TLS configuration.
+func TestGetTLSConfig(t *testing.T) {
+	s := &settings{}
+	config, err := s.getTLSConfig("ldap.example.org")
+	require.NoError(t, err)
+	require.Equal(t, "ldap.example.org", config.ServerName)
+	require.Nil(t, config.RootCAs)
+	require.False(t, config.InsecureSkipVerify)
+
+	s.RootCAFile = filepath.Join(t.TempDir(), "ca.pem")
+	_, err = s.getTLSConfig("ldap.example.org")
+	require.Error(t, err)
+
+	err = os.WriteFile(s.RootCAFile, []byte("not a certificate"), 0o600)
+	require.NoError(t, err)
+	_, err = s.getTLSConfig("ldap.example.org")
+	require.Error(t, err)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"isc.org/stork/hooks/server/authenticationcallouts"
+)
+
+//go:embed icon.png
+var icon []byte
+
+// State of the authorization code flow stored in the Stork session between
+// the redirect to the provider and the callback.
+type flowState struct {
+	// Random value binding the callback with the session (CSRF protection).
+	State string `json:"state"`
+	// Random value binding the ID token with the session (replay protection).
+	Nonce string `json:"nonce"`
+	// PKCE code verifier.
+	CodeVerifier string `json:"codeVerifier"`
+}
+
+// Generates a random base64url-encoded value.
+func generateRandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "cannot generate random value")
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Returns the PKCE code challenge for the code verifier using the S256
+// method.
+func getCodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// Callout carrier structure.
+type calloutCarrier struct {
+	settings *settings
+	provider *provider
+	// Returns the current time. It allows replacing the clock in the unit
+	// tests.
+	now func() time.Time
+}
+
+// Creates the callout carrier using the specified settings.
+func newCalloutCarrier(settings *settings) (*calloutCarrier, error) {
+	client, err := settings.getHTTPClient()
+	if err != nil {
+		return nil, err
+	}
+	return &calloutCarrier{
+		settings: settings,
+		provider: newProvider(settings, client),
+		now:      time.Now,
+	}, nil
+}
+
+// Closer interface implementation.
+func (c *calloutCarrier) Close() error {
+	return nil
+}
+
+// Interface checks.
+var (
+	_ authenticationcallouts.AuthenticationCallouts         = (*calloutCarrier)(nil)
+	_ authenticationcallouts.AuthenticationRedirectCallouts = (*calloutCarrier)(nil)
+	_ authenticationcallouts.AuthenticationMetadata         = (*metadata)(nil)
+	_ authenticationcallouts.AuthenticationMetadataRedirect = (*metadata)(nil)
+)
+
+// The form-based authentication is not supported by the OpenID Connect
+// hook. The user must be redirected to the provider.
+func (c *calloutCarrier) Authenticate(ctx context.Context, request *http.Request, identifier, secret *string) (*authenticationcallouts.User, error) {
+	return nil, errors.New("OpenID Connect authentication requires a redirect to the identity provider")
+}
+
+// Begins the authorization code flow. It returns the URL of the provider
+// authorization endpoint with the state, nonce and PKCE code challenge.
+func (c *calloutCarrier) BeginAuthentication(ctx context.Context, request *http.Request, callbackURL string) (string, string, error) {
+	metadata, err := c.provider.getMetadata(ctx)
+	if err != nil {
+		return "", "", err
+	}
+
+	state := &flowState{}
+	for _, value := range []*string{&state.State, &state.Nonce, &state.CodeVerifier} {
+		if *value, err = generateRandomString(); err != nil {
+			return "", "", err
+		}
+	}
+	encodedState, err := json.Marshal(state)
+	if err != nil {
+		return "", "", errors.Wrap(err, "cannot serialize the authentication flow state")
+	}
+
+	authorizationURL, err := url.Parse(metadata.AuthorizationEndpoint)
+	if err != nil {
+		return "", "", errors.Wrapf(err, "invalid authorization endpoint %s", metadata.AuthorizationEndpoint)
+	}
+	query := authorizationURL.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", c.settings.ClientID)
+	query.Set("redirect_uri", callbackURL)
+	query.Set("scope", strings.Join(c.settings.Scopes, " "))
+	query.Set("state", state.State)
+	query.Set("nonce", state.Nonce)
+	query.Set("code_challenge", getCodeChallenge(state.CodeVerifier))
+	query.Set("code_challenge_method", "S256")
+	authorizationURL.RawQuery = query.Encode()
+
+	return authorizationURL.String(), string(encodedState), nil
+}
+
+// Completes the authorization code flow. It validates the callback request,
+// exchanges the code for the ID token and verifies the token.
+func (c *calloutCarrier) CompleteAuthentication(ctx context.Context, request *http.Request, callbackURL, encodedState string) (*authenticationcallouts.User, error) {
+	state := &flowState{}
+	if err := json.Unmarshal([]byte(encodedState), state); err != nil || state.State == "" {
+		return nil, errors.New("invalid authentication flow state")
+	}
+
+	query := request.URL.Query()
+	if providerError := query.Get("error"); providerError != "" {
+		return nil, errors.Errorf("identity provider returned an error: %s %s", providerError, query.Get("error_description"))
+	}
+	if subtle.ConstantTimeCompare([]byte(query.Get("state")), []byte(state.State)) != 1 {
+		return nil, errors.New("state parameter doesn't match the authentication request")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return nil, errors.New("missing authorization code")
+	}
+
+	idToken, err := c.provider.exchangeCode(ctx, code, state.CodeVerifier, callbackURL)
+	if err != nil {
+		return nil, err
+	}
+	claims, err := c.provider.verifyIDToken(ctx, idToken, state.Nonce, c.now())
+	if err != nil {
+		return nil, err
+	}
+	return c.newUser(claims)
+}
+
+// Creates the Stork user from the ID token claims. The groups are returned
+// only if the group mapping is configured. Otherwise, the Stork groups of
+// the user are managed in Stork.
+func (c *calloutCarrier) newUser(claims idTokenClaims) (*authenticationcallouts.User, error) {
+	user := &authenticationcallouts.User{
+		ID:       claims.getString(c.settings.IDClaim),
+		Login:    claims.getString(c.settings.LoginClaim),
+		Email:    claims.getString(c.settings.EmailClaim),
+		Name:     claims.getString(c.settings.FirstNameClaim),
+		Lastname: claims.getString(c.settings.LastNameClaim),
+	}
+	if user.ID == "" {
+		return nil, errors.Errorf("ID token lacks the %s claim", c.settings.IDClaim)
+	}
+	if len(c.settings.GroupMap) > 0 {
+		user.Groups = []int{}
+		seen := make(map[int]bool)
+		for _, group := range claims.getStrings(c.settings.GroupsClaim) {
+			if groupID, ok := c.settings.GroupMap[group]; ok && !seen[groupID] {
+				seen[groupID] = true
+				user.Groups = append(user.Groups, groupID)
+			}
+		}
+	}
+	return user, nil
+}
+
+// Does nothing because the hook doesn't maintain any sessions. The session
+// in the provider is not terminated.
+func (c *calloutCarrier) Unauthenticate(ctx context.Context) error {
+	return nil
+}
+
+// Returns the metadata of the OpenID Connect authentication method.
+func (c *calloutCarrier) GetMetadata() authenticationcallouts.AuthenticationMetadata {
+	return &metadata{buttonLabel: c.settings.ButtonLabel}
+}
+
+// Metadata of the OpenID Connect authentication method.
+type metadata struct {
+	buttonLabel string
+}
+
+// Returns the authentication method ID.
+func (m *metadata) GetID() string {
+	return "oidc"
+}
+
+// Returns the authentication method name.
+func (m *metadata) GetName() string {
+	return "OpenID Connect"
+}
+
+// Returns the authentication method description.
+func (m *metadata) GetDescription() string {
+	return "Single sign-on using the OpenID Connect identity provider"
+}
+
+// Returns the authentication method icon.
+func (m *metadata) GetIcon() (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(icon)), nil
+}
+
+// Returns the label of the button redirecting to the identity provider.
+func (m *metadata) GetRedirectButtonLabel() string {
+	return m.buttonLabel
+}
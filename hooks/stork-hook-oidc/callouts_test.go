@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"github.com/stretchr/testify/require"
+)
+
+// Fake OpenID Connect provider. It serves the discovery document, the
+// signing keys and the token endpoint returning the ID token with the
+// configured claims.
+type fakeProvider struct {
+	server *httptest.Server
+	rsaKey *rsa.PrivateKey
+	ecKey  *ecdsa.PrivateKey
+
+	mutex sync.Mutex
+	// Algorithm used to sign the next ID token.
+	algorithm string
+	// Claims of the next ID token. The nonce is taken from the
+	// authorization request if the claims don't specify it.
+	claims map[string]interface{}
+	// Parameters of the last authorization and token requests.
+	authorizationQuery url.Values
+	tokenForm          url.Values
+	tokenBasicAuth     string
+	// Number of the JWKS requests.
+	jwksRequests int
+}
+
+// Starts the fake provider.
+func newFakeProvider(t *testing.T) *fakeProvider {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	p := &fakeProvider{
+		rsaKey:    rsaKey,
+		ecKey:     ecKey,
+		algorithm: "RS256",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mutex.Lock()
+		p.jwksRequests++
+		p.mutex.Unlock()
+		keys := []interface{}{
+			jose.JSONWebKey{Key: &rsaKey.PublicKey, KeyID: "rsa", Use: "sig"},
+			jose.JSONWebKey{Key: &ecKey.PublicKey, KeyID: "ec"},
+			jose.JSONWebKey{Key: &rsaKey.PublicKey, KeyID: "enc", Use: "enc"},
+			// The keys of unsupported types are skipped.
+			map[string]string{"kty": "unknown", "kid": "unknown"},
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		p.tokenForm = r.PostForm
+		p.tokenBasicAuth = r.Header.Get("Authorization")
+
+		// Verify the PKCE code verifier.
+		challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if p.authorizationQuery == nil ||
+			base64.RawURLEncoding.EncodeToString(challenge[:]) != p.authorizationQuery.Get("code_challenge") ||
+			r.PostForm.Get("code") != "valid-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		claims := map[string]interface{}{}
+		for k, v := range p.claims {
+			claims[k] = v
+		}
+		if _, ok := claims["nonce"]; !ok {
+			claims["nonce"] = p.authorizationQuery.Get("nonce")
+		}
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"id_token":     p.sign(t, claims),
+		})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	p.claims = p.defaultClaims()
+	return p
+}
+
+// Returns the valid claims of the ID token issued for the stork client.
+func (p *fakeProvider) defaultClaims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":                p.server.URL,
+		"sub":                "248289761001",
+		"aud":                "stork",
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"preferred_username": "john",
+		"email":              "john@example.org",
+		"given_name":         "John",
+		"family_name":        "Smith",
+		"groups":             []string{"stork-admins", "noc", "other"},
+	}
+}
+
+// Signs the ID token using the configured algorithm.
+func (p *fakeProvider) sign(t *testing.T, claims map[string]interface{}) string {
+	keyID := "rsa"
+	var key interface{} = p.rsaKey
+	if strings.HasPrefix(p.algorithm, "ES") {
+		keyID = "ec"
+		key = p.ecKey
+	}
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(p.algorithm), Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", keyID),
+	)
+	require.NoError(t, err)
+	payload, _ := json.Marshal(claims)
+	signed, err := signer.Sign(payload)
+	require.NoError(t, err)
+	token, err := signed.CompactSerialize()
+	require.NoError(t, err)
+	return token
+}
+
+// Creates the carrier using the fake provider.
+func newTestCarrier(t *testing.T, p *fakeProvider, groupMap map[string]int) *calloutCarrier {
+	carrier, err := newCalloutCarrier(&settings{
+		Issuer:         p.server.URL,
+		ClientID:       "stork",
+		ClientSecret:   "secret",
+		Scopes:         []string{"openid", "profile", "email"},
+		IDClaim:        "sub",
+		LoginClaim:     "preferred_username",
+		EmailClaim:     "email",
+		FirstNameClaim: "given_name",
+		LastNameClaim:  "family_name",
+		GroupsClaim:    "groups",
+		GroupMap:       groupMap,
+		ButtonLabel:    "Sign in with SSO",
+		Timeout:        5 * time.Second,
+	})
+	require.NoError(t, err)
+	return carrier
+}
+
+// Performs the redirect to the provider and the callback. The provider
+// authorizes the user and redirects back with the specified code and the
+// state from the authorization request (unless overridden).
+func authenticate(t *testing.T, p *fakeProvider, carrier *calloutCarrier, code string, state *string) (*http.Request, string) {
+	callbackURL := "https://stork.example.org/api/sessions/callback/oidc"
+	redirectURL, flowState, err := carrier.BeginAuthentication(context.Background(), nil, callbackURL)
+	require.NoError(t, err)
+
+	parsedURL, err := url.Parse(redirectURL)
+	require.NoError(t, err)
+	p.mutex.Lock()
+	p.authorizationQuery = parsedURL.Query()
+	p.mutex.Unlock()
+
+	callbackQuery := url.Values{"code": {code}, "state": {parsedURL.Query().Get("state")}}
+	if state != nil {
+		callbackQuery.Set("state", *state)
+	}
+	request, err := http.NewRequest(http.MethodGet, callbackURL+"?"+callbackQuery.Encode(), nil)
+	require.NoError(t, err)
+	return request, flowState
+}
+
+// Test that the metadata indicates the redirect-based method.
+func TestGetMetadata(t *testing.T) {
+	carrier := &calloutCarrier{settings: &settings{ButtonLabel: "Sign in with Keycloak"}}
+	meta := carrier.GetMetadata()
+	require.Equal(t, "oidc", meta.GetID())
+	require.Equal(t, "OpenID Connect", meta.GetName())
+	require.Equal(t, "Sign in with Keycloak", meta.(*metadata).GetRedirectButtonLabel())
+
+	icon, err := meta.GetIcon()
+	require.NoError(t, err)
+	require.NoError(t, icon.Close())
+}
+
+// Test that the form-based authentication is rejected.
+func TestAuthenticateNotSupported(t *testing.T) {
+	carrier := &calloutCarrier{}
+	user, err := carrier.Authenticate(context.Background(), nil, nil, nil)
+	require.Error(t, err)
+	require.Nil(t, user)
+}
+
+// Test that the authorization URL contains the parameters of the
+// authorization code flow with PKCE.
+func TestBeginAuthentication(t *testing.T) {
+	p := newFakeProvider(t)
+	carrier := newTestCarrier(t, p, nil)
+
+	redirectURL, encodedState, err := carrier.BeginAuthentication(context.Background(), nil, "https://stork.example.org/api/sessions/callback/oidc")
+	require.NoError(t, err)
+
+	parsedURL, err := url.Parse(redirectURL)
+	require.NoError(t, err)
+	require.Equal(t, p.server.URL+"/authorize", parsedURL.Scheme+"://"+parsedURL.Host+parsedURL.Path)
+
+	state := &flowState{}
+	require.NoError(t, json.Unmarshal([]byte(encodedState), state))
+
+	query := parsedURL.Query()
+	require.Equal(t, "code", query.Get("response_type"))
+	require.Equal(t, "stork", query.Get("client_id"))
+	require.Equal(t, "https://stork.example.org/api/sessions/callback/oidc", query.Get("redirect_uri"))
+	require.Equal(t, "openid profile email", query.Get("scope"))
+	require.Equal(t, state.State, query.Get("state"))
+	require.Equal(t, state.Nonce, query.Get("nonce"))
+	require.Equal(t, getCodeChallenge(state.CodeVerifier), query.Get("code_challenge"))
+	require.Equal(t, "S256", query.Get("code_challenge_method"))
+	require.NotContains(t, redirectURL, state.CodeVerifier)
+
+	// Each flow uses different random values.
+	_, encodedState2, err := carrier.BeginAuthentication(context.Background(), nil, "")
+	require.NoError(t, err)
+	require.NotEqual(t, encodedState, encodedState2)
+}
+
+// Test that the discovery fails if the provider is unavailable.
+func TestBeginAuthenticationDiscoveryError(t *testing.T) {
+	p := newFakeProvider(t)
+	carrier := newTestCarrier(t, p, nil)
+	p.server.Close()
+
+	_, _, err := carrier.BeginAuthentication(context.Background(), nil, "")
+	require.ErrorContains(t, err, "cannot discover")
+}
+
+// Test that the user is authenticated and the claims are mapped to the
+// Stork user.
+func TestCompleteAuthentication(t *testing.T) {
+	p := newFakeProvider(t)
+	carrier := newTestCarrier(t, p, map[string]int{"stork-admins": 2, "noc": 3})
+
+	request, flowState := authenticate(t, p, carrier, "valid-code", nil)
+	user, err := carrier.CompleteAuthentication(context.Background(), request, "https://stork.example.org/api/sessions/callback/oidc", flowState)
+
+	require.NoError(t, err)
+	require.Equal(t, "248289761001", user.ID)
+	require.Equal(t, "john", user.Login)
+	require.Equal(t, "john@example.org", user.Email)
+	require.Equal(t, "John", user.Name)
+	require.Equal(t, "Smith", user.Lastname)
+	require.Equal(t, []int{2, 3}, user.Groups)
+
+	// The confidential client authenticates with the basic auth and sends
+	// the PKCE verifier and the redirect URI.
+	require.NotEmpty(t, p.tokenBasicAuth)
+	require.Equal(t, "authorization_code", p.tokenForm.Get("grant_type"))
+	require.Equal(t, "https://stork.example.org/api/sessions/callback/oidc", p.tokenForm.Get("redirect_uri"))
+	require.Empty(t, p.tokenForm.Get("client_id"))
+}
+
+// Test that the ES256 signed ID tokens are accepted.
+func TestCompleteAuthenticationECDSA(t *testing.T) {
+	p := newFakeProvider(t)
+	p.algorithm = "ES256"
+	carrier := newTestCarrier(t, p, nil)
+
+	request, flowState := authenticate(t, p, carrier, "valid-code", nil)
+	user, err := carrier.CompleteAuthentication(context.Background(), request, "", flowState)
+
+	require.NoError(t, err)
+	require.Equal(t, "248289761001", user.ID)
+	// The groups are not managed by the hook without the mapping.
+	require.Nil(t, user.Groups)
+}
+
+// Test that the public client sends its ID in the token request.
+func TestCompleteAuthenticationPublicClient(t *testing.T) {
+	p := newFakeProvider(t)
+	carrier := newTestCarrier(t, p, nil)
+	carrier.settings.ClientSecret = ""
+
+	request, flowState := authenticate(t, p, carrier, "valid-code", nil)
+	_, err := carrier.CompleteAuthentication(context.Background(), request, "", flowState)
+
+	require.NoError(t, err)
+	require.Empty(t, p.tokenBasicAuth)
+	require.Equal(t, "stork", p.tokenForm.Get("client_id"))
+}
+
+// Test that the callback with a different state is rejected.
+func TestCompleteAuthenticationStateMismatch(t *testing.T) {
+	p := newFakeProvider(t)
+	carrier := newTestCarrier(t, p, nil)
+
+	state := "forged"
+	request, flowState := authenticate(t, p, carrier, "valid-code", &state)
+	user, err := carrier.CompleteAuthentication(context.Background(), request, "", flowState)
+
+	require.ErrorContains(t, err, "state parameter")
+	require.Nil(t, user)
+}
+
+// Test that the error returned by the provider is reported.
+func TestCompleteAuthenticationProviderError(t *testing.T) {
+	p := newFakeProvider(t)
+	carrier := newTestCarrier(t, p, nil)
+
+	_, flowState, err := carrier.BeginAuthentication(context.Background(), nil, "")
+	require.NoError(t, err)
+	request, _ := http.NewRequest(http.MethodGet, "/callback?error=access_denied&error_description=denied", nil)
+
+	user, err := carrier.CompleteAuthentication(context.Background(), request, "", flowState)
+	require.ErrorContains(t, err, "access_denied")
+	require.Nil(t, user)
+}
+
+// Test that the invalid authentication flow state is rejected.
+func TestCompleteAuthenticationInvalidFlowState(t *testing.T) {
+	carrier := &calloutCarrier{}
+	request, _ := http.NewRequest(http.MethodGet, "/callback?code=foo&state=", nil)
+
+	for _, state := range []string{"", "{}", "not-json"} {
+		user, err := carrier.CompleteAuthentication(context.Background(), request, "", state)
+		require.ErrorContains(t, err, "invalid authentication flow state")
+		require.Nil(t, user)
+	}
+}
+
+// Test that the failed code exchange is reported.
+func TestCompleteAuthenticationInvalidCode(t *testing.T) {
+	p := newFakeProvider(t)
+	carrier := newTestCarrier(t, p, nil)
+
+	request, flowState := authenticate(t, p, carrier, "invalid-code", nil)
+	user, err := carrier.CompleteAuthentication(context.Background(), request, "", flowState)
+
+	require.ErrorContains(t, err, "invalid_grant")
+	require.Nil(t, user)
+}
+
+// Test that the ID tokens with invalid claims are rejected.
+func TestCompleteAuthenticationInvalidClaims(t *testing.T) {
+	testCases := map[string]struct {
+		claim string
+		value interface{}
+		err   string
+	}{
+		"issuer":        {"iss", "https://evil.example.org", "unexpected ID token issuer"},
+		"audience":      {"aud", "other", "not issued for the client"},
+		"multiple aud":  {"aud", []string{"stork", "other"}, "authorized for another party"},
+		"expired":       {"exp", time.Now().Add(-time.Hour).Unix(), "expired"},
+		"missing exp":   {"exp", nil, "lacks the expiration time"},
+		"not yet valid": {"nbf", time.Now().Add(time.Hour).Unix(), "not valid yet"},
+		"nonce":         {"nonce", "replayed", "nonce doesn't match"},
+		"subject":       {"sub", nil, "lacks the sub claim"},
+	}
+
+	for name, testCase := range testCases {
+		testCase := testCase
+		t.Run(name, func(t *testing.T) {
+			p := newFakeProvider(t)
+			if testCase.value == nil {
+				delete(p.claims, testCase.claim)
+			} else {
+				p.claims[testCase.claim] = testCase.value
+			}
+			carrier := newTestCarrier(t, p, nil)
+
+			request, flowState := authenticate(t, p, carrier, "valid-code", nil)
+			user, err := carrier.CompleteAuthentication(context.Background(), request, "", flowState)
+
+			require.ErrorContains(t, err, testCase.err)
+			require.Nil(t, user)
+		})
+	}
+}
+
+// Test that the ID token with an invalid signature is rejected.
+func TestVerifyIDTokenInvalidSignature(t *testing.T) {
+	p := newFakeProvider(t)
+	carrier := newTestCarrier(t, p, nil)
+
+	claims := p.defaultClaims()
+	claims["nonce"] = "nonce"
+	token := p.sign(t, claims)
+
+	// Replace the payload keeping the original signature.
+	claims["sub"] = "admin"
+	payload, _ := json.Marshal(claims)
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+
+	_, err := carrier.provider.verifyIDToken(context.Background(), forged, "nonce", time.Now())
+	require.ErrorContains(t, err, "invalid signature")
+
+	// The valid token is accepted.
+	_, err = carrier.provider.verifyIDToken(context.Background(), token, "nonce", time.Now())
+	require.NoError(t, err)
+}
+
+// Test that the unsigned and HMAC signed tokens are rejected.
+func TestVerifyIDTokenUnsupportedAlgorithm(t *testing.T) {
+	p := newFakeProvider(t)
+	carrier := newTestCarrier(t, p, nil)
+
+	payload, _ := json.Marshal(p.defaultClaims())
+	for _, algorithm := range []string{"none", "HS256"} {
+		header, _ := json.Marshal(map[string]string{"alg": algorithm, "kid": "rsa"})
+		token := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+
+		_, err := carrier.provider.verifyIDToken(context.Background(), token, "", time.Now())
+		require.ErrorContains(t, err, "unsupported ID token signature algorithm")
+	}
+
+	_, err := carrier.provider.verifyIDToken(context.Background(), "not-a-token", "", time.Now())
+	require.ErrorContains(t, err, "malformed")
+}
+
+// Test that the keys are fetched once and refreshed for an unknown key
+// not more often than the refresh interval.
+func TestGetKeyCaching(t *testing.T) {
+	p := newFakeProvider(t)
+	carrier := newTestCarrier(t, p, nil)
+
+	key, err := carrier.provider.getKey(context.Background(), "rsa")
+	require.NoError(t, err)
+	require.IsType(t, &rsa.PublicKey{}, key)
+
+	key, err = carrier.provider.getKey(context.Background(), "ec")
+	require.NoError(t, err)
+	require.IsType(t, &ecdsa.PublicKey{}, key)
+	require.Equal(t, 1, p.jwksRequests)
+
+	// The encryption keys are not used to verify the signatures.
+	_, err = carrier.provider.getKey(context.Background(), "enc")
+	require.ErrorContains(t, err, "unknown signing key")
+	require.Equal(t, 1, p.jwksRequests)
+
+	// The keys are fetched again after the refresh interval.
+	carrier.provider.keysFetchedAt = time.Now().Add(-2 * jwksRefreshInterval)
+	_, err = carrier.provider.getKey(context.Background(), "unknown")
+	require.ErrorContains(t, err, "unknown signing key")
+	require.Equal(t, 2, p.jwksRequests)
+}
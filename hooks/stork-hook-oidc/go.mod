@@ -0,0 +1,19 @@
+module stork-hook-oidc
+
+go 1.19
+
+require (
+	github.com/go-jose/go-jose/v3 v3.0.5
+	github.com/pkg/errors v0.9.1
+	github.com/stretchr/testify v1.8.2
+	isc.org/stork v0.0.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/crypto v0.20.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace isc.org/stork v0.0.0 => ../../backend
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"github.com/go-jose/go-jose/v3/jwt"
+	"github.com/pkg/errors"
+)
+
+// Tolerated clock difference between Stork and the provider.
+const clockSkew = time.Minute
+
+// Signature algorithms of the ID tokens supported by the hook.
+var signatureAlgorithms = map[jose.SignatureAlgorithm]bool{
+	jose.RS256: true,
+	jose.RS384: true,
+	jose.RS512: true,
+	jose.ES256: true,
+	jose.ES384: true,
+	jose.ES512: true,
+}
+
+// Verified claims of the ID token.
+type idTokenClaims map[string]interface{}
+
+// Returns the string value of the claim or an empty string if the claim is
+// missing or is not a string.
+func (c idTokenClaims) getString(name string) string {
+	value, _ := c[name].(string)
+	return value
+}
+
+// Returns the string values of the claim. The claim may be a single string
+// or an array of strings. The non-string array elements are ignored.
+func (c idTokenClaims) getStrings(name string) []string {
+	switch value := c[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		var values []string
+		for _, element := range value {
+			if s, ok := element.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+// Verifies the ID token returned by the provider and returns its claims.
+// It checks the signature using the provider keys, the issuer, audience,
+// expiration time and nonce as required by the OpenID Connect Core
+// specification.
+func (p *provider) verifyIDToken(ctx context.Context, token, nonce string, now time.Time) (idTokenClaims, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, errors.Wrap(err, "malformed ID token")
+	}
+	if len(parsed.Headers) != 1 {
+		return nil, errors.New("malformed ID token")
+	}
+	header := parsed.Headers[0]
+	// The "none" algorithm and the HMAC algorithms are rejected here.
+	if !signatureAlgorithms[jose.SignatureAlgorithm(header.Algorithm)] {
+		return nil, errors.Errorf("unsupported ID token signature algorithm %q", header.Algorithm)
+	}
+	key, err := p.getKey(ctx, header.KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	registered := jwt.Claims{}
+	claims := idTokenClaims{}
+	if err = parsed.Claims(key, &registered, &claims); err != nil {
+		return nil, errors.Wrap(err, "invalid signature of the ID token")
+	}
+
+	if registered.Expiry == nil {
+		return nil, errors.New("ID token lacks the expiration time")
+	}
+	err = registered.ValidateWithLeeway(jwt.Expected{
+		Issuer:   p.settings.Issuer,
+		Audience: jwt.Audience{p.settings.ClientID},
+		Time:     now,
+	}, clockSkew)
+	switch {
+	case errors.Is(err, jwt.ErrInvalidIssuer):
+		return nil, errors.Errorf("unexpected ID token issuer %q", registered.Issuer)
+	case errors.Is(err, jwt.ErrInvalidAudience):
+		return nil, errors.Errorf("ID token is not issued for the client %s", p.settings.ClientID)
+	case errors.Is(err, jwt.ErrExpired):
+		return nil, errors.New("ID token has expired")
+	case errors.Is(err, jwt.ErrNotValidYet):
+		return nil, errors.New("ID token is not valid yet")
+	case err != nil:
+		return nil, errors.Wrap(err, "invalid ID token")
+	}
+	if azp := claims.getString("azp"); len(registered.Audience) > 1 && azp != p.settings.ClientID {
+		return nil, errors.Errorf("ID token is authorized for another party %q", azp)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.getString("nonce")), []byte(nonce)) != 1 {
+		return nil, errors.New("ID token nonce doesn't match the authentication request")
+	}
+	return claims, nil
+}
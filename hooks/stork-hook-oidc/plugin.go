@@ -0,0 +1,26 @@
+package main
+
+import (
+	"isc.org/stork/hooks"
+)
+
+// Loads a callout carrier (an object with the callout specification implementations).
+// The hook is configured using the environment variables.
+func Load() (hooks.CalloutCarrier, error) {
+	settings, err := loadSettingsFromEnv()
+	if err != nil {
+		return nil, err
+	}
+	return newCalloutCarrier(settings)
+}
+
+// Returns an application name and expected version.
+func Version() (string, string) {
+	return hooks.HookProgramServer, hooks.StorkVersion
+}
+
+// Type guards.
+var (
+	_ hooks.HookLoadFunction    = Load
+	_ hooks.HookVersionFunction = Version
+)
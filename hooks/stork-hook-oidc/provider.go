@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	jose "github.com/go-jose/go-jose/v3"
+	"github.com/pkg/errors"
+)
+
+// Maximum size of the responses read from the provider.
+const maxResponseSize = 1 << 20
+
+// The JWKS is fetched again when the ID token is signed with an unknown
+// key but not more often than this interval. It protects the provider from
+// being flooded with requests by tokens with random key IDs.
+const jwksRefreshInterval = time.Minute
+
+// Subset of the OpenID Provider Metadata used by the hook.
+type providerMetadata struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Token endpoint response. The access token is not used by the hook.
+type tokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// OpenID Connect provider client. It discovers the provider configuration
+// on first use and caches the signing keys.
+type provider struct {
+	settings *settings
+	client   *http.Client
+
+	mutex         sync.Mutex
+	metadata      *providerMetadata
+	keys          map[string]crypto.PublicKey
+	keysFetchedAt time.Time
+}
+
+// Creates the provider client.
+func newProvider(settings *settings, client *http.Client) *provider {
+	return &provider{
+		settings: settings,
+		client:   client,
+	}
+}
+
+// Sends the GET request and decodes the JSON response.
+func (p *provider) getJSON(ctx context.Context, requestURL string, output interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return errors.Wrapf(err, "cannot create the request to %s", requestURL)
+	}
+	req.Header.Set("Accept", "application/json")
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return errors.Wrapf(err, "cannot send the request to %s", requestURL)
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return errors.Errorf("unexpected status %d received from %s", rsp.StatusCode, requestURL)
+	}
+	err = json.NewDecoder(io.LimitReader(rsp.Body, maxResponseSize)).Decode(output)
+	return errors.Wrapf(err, "cannot parse the response from %s", requestURL)
+}
+
+// Returns the provider metadata. It is fetched from the discovery endpoint
+// on first use. The failed discovery is retried on next use.
+func (p *provider) getMetadata(ctx context.Context) (*providerMetadata, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.metadata != nil {
+		return p.metadata, nil
+	}
+
+	metadata := &providerMetadata{}
+	if err := p.getJSON(ctx, p.settings.Issuer+"/.well-known/openid-configuration", metadata); err != nil {
+		return nil, errors.WithMessage(err, "cannot discover the OpenID Connect provider configuration")
+	}
+	// The issuer must match exactly to prevent the attacks with the
+	// metadata of other providers.
+	if metadata.Issuer != p.settings.Issuer {
+		return nil, errors.Errorf("issuer %s in the provider configuration doesn't match the configured issuer %s", metadata.Issuer, p.settings.Issuer)
+	}
+	if metadata.AuthorizationEndpoint == "" || metadata.TokenEndpoint == "" || metadata.JWKSURI == "" {
+		return nil, errors.New("provider configuration lacks the authorization, token or JWKS endpoint")
+	}
+	p.metadata = metadata
+	return metadata, nil
+}
+
+// Returns the public key with the specified ID used to verify the ID token
+// signature. The keys are fetched again if the key is not known because the
+// provider may have rotated them.
+func (p *provider) getKey(ctx context.Context, keyID string) (crypto.PublicKey, error) {
+	metadata, err := p.getMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if key := lookupKey(p.keys, keyID); key != nil {
+		return key, nil
+	}
+	if p.keys != nil && time.Since(p.keysFetchedAt) < jwksRefreshInterval {
+		return nil, errors.Errorf("unknown signing key %q", keyID)
+	}
+
+	// The keys are parsed one by one, so an invalid key or a key of an
+	// unsupported type doesn't prevent using the other keys.
+	var jwks struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err = p.getJSON(ctx, metadata.JWKSURI, &jwks); err != nil {
+		return nil, errors.WithMessage(err, "cannot fetch the provider signing keys")
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, rawKey := range jwks.Keys {
+		jwk := jose.JSONWebKey{}
+		if err := jwk.UnmarshalJSON(rawKey); err != nil || !jwk.IsPublic() {
+			continue
+		}
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		keys[jwk.KeyID] = jwk.Key
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+
+	key := lookupKey(keys, keyID)
+	if key == nil {
+		return nil, errors.Errorf("unknown signing key %q", keyID)
+	}
+	return key, nil
+}
+
+// Returns the key with the specified ID or nil if there is no such key.
+// The provider with a single key may not specify the key IDs.
+func lookupKey(keys map[string]crypto.PublicKey, keyID string) crypto.PublicKey {
+	if key, ok := keys[keyID]; ok {
+		return key
+	}
+	if keyID == "" && len(keys) == 1 {
+		for _, key := range keys {
+			return key
+		}
+	}
+	return nil
+}
+
+// Exchanges the authorization code for the ID token at the token endpoint.
+// The PKCE code verifier proves that the code was requested by this flow.
+func (p *provider) exchangeCode(ctx context.Context, code, codeVerifier, redirectURI string) (string, error) {
+	metadata, err := p.getMetadata(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {codeVerifier},
+	}
+	if p.settings.ClientSecret == "" {
+		// Public client.
+		form.Set("client_id", p.settings.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Wrap(err, "cannot create the token request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.settings.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.settings.ClientID), url.QueryEscape(p.settings.ClientSecret))
+	}
+
+	rsp, err := p.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot send the token request")
+	}
+	defer rsp.Body.Close()
+
+	token := &tokenResponse{}
+	if err = json.NewDecoder(io.LimitReader(rsp.Body, maxResponseSize)).Decode(token); err != nil {
+		return "", errors.Wrapf(err, "cannot parse the token response with status %d", rsp.StatusCode)
+	}
+	if rsp.StatusCode != http.StatusOK || token.Error != "" {
+		return "", errors.Errorf("token request failed with status %d: %s %s", rsp.StatusCode, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", errors.New("token response lacks the ID token")
+	}
+	return token.IDToken, nil
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Prefix of the environment variables configuring the hook.
+const envPrefix = "STORK_SERVER_HOOK_OIDC_"
+
+// Hook settings. They are read from the environment variables because the
+// Stork server doesn't pass any configuration to the hooks.
+type settings struct {
+	// Issuer URL of the OpenID Connect provider, e.g.
+	// https://accounts.example.org/realms/stork. The provider configuration
+	// is discovered using the /.well-known/openid-configuration document.
+	Issuer string
+	// Client ID and secret registered in the provider. The secret may be
+	// empty for the public clients; the PKCE protects the flow anyway.
+	ClientID     string
+	ClientSecret string
+	// Scopes requested in the authorization request. The openid scope is
+	// always included.
+	Scopes []string
+	// Names of the ID token claims. The user ID claim must hold a unique
+	// and persistent identifier.
+	IDClaim        string
+	LoginClaim     string
+	EmailClaim     string
+	FirstNameClaim string
+	LastNameClaim  string
+	// Name of the claim holding the groups (or roles) of the user.
+	GroupsClaim string
+	// Maps the values of the groups claim to the Stork group IDs. The Stork
+	// groups are not managed by the hook if the map is empty.
+	GroupMap map[string]int
+	// Label of the login button.
+	ButtonLabel string
+	// Path to the PEM file with the CA certificates used to verify the
+	// provider certificate. The system CAs are used if it is empty.
+	RootCAFile string
+	// Disables the provider certificate verification. It should only be
+	// used for testing.
+	SkipTLSVerification bool
+	// Timeout of the requests sent to the provider.
+	Timeout time.Duration
+}
+
+// Returns the value of the environment variable with the hook prefix or
+// the default value if the variable is not set.
+func getEnv(name, defaultValue string) string {
+	if value, ok := os.LookupEnv(envPrefix + name); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// Returns the boolean value of the environment variable with the hook
+// prefix. The variable not set is false.
+func getEnvBool(name string) (bool, error) {
+	value := getEnv(name, "")
+	if value == "" {
+		return false, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, errors.Wrapf(err, "invalid value of %s%s", envPrefix, name)
+	}
+	return parsed, nil
+}
+
+// Parses the mapping of the provider groups to the Stork groups. The
+// mapping is a semicolon-separated list of the entries in the format of
+// GROUP-ID:CLAIM-VALUE, e.g. "1:stork-super-admins;3:noc". The claim values
+// are case-sensitive.
+func parseGroupMap(value string) (map[string]int, error) {
+	groupMap := make(map[string]int)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, group, found := strings.Cut(entry, ":")
+		if !found {
+			return nil, errors.Errorf("invalid group mapping entry %q, expected GROUP-ID:CLAIM-VALUE", entry)
+		}
+		groupID, err := strconv.Atoi(strings.TrimSpace(id))
+		if err != nil || groupID <= 0 {
+			return nil, errors.Errorf("invalid Stork group ID in the group mapping entry %q", entry)
+		}
+		group = strings.TrimSpace(group)
+		if group == "" {
+			return nil, errors.Errorf("empty group name in the group mapping entry %q", entry)
+		}
+		groupMap[group] = groupID
+	}
+	return groupMap, nil
+}
+
+// Parses the space-separated list of scopes. The openid scope is added if
+// missing because it is required by OpenID Connect.
+func parseScopes(value string) []string {
+	scopes := []string{"openid"}
+	for _, scope := range strings.Fields(value) {
+		if scope != "openid" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
+
+// Reads the hook settings from the environment variables.
+func loadSettingsFromEnv() (*settings, error) {
+	s := &settings{
+		Issuer:         strings.TrimSuffix(getEnv("ISSUER", ""), "/"),
+		ClientID:       getEnv("CLIENT_ID", ""),
+		ClientSecret:   getEnv("CLIENT_SECRET", ""),
+		Scopes:         parseScopes(getEnv("SCOPES", "openid profile email")),
+		IDClaim:        getEnv("CLAIM_ID", "sub"),
+		LoginClaim:     getEnv("CLAIM_LOGIN", "preferred_username"),
+		EmailClaim:     getEnv("CLAIM_EMAIL", "email"),
+		FirstNameClaim: getEnv("CLAIM_FIRST_NAME", "given_name"),
+		LastNameClaim:  getEnv("CLAIM_LAST_NAME", "family_name"),
+		GroupsClaim:    getEnv("CLAIM_GROUPS", "groups"),
+		ButtonLabel:    getEnv("BUTTON_LABEL", "Sign in with SSO"),
+		RootCAFile:     getEnv("ROOT_CA", ""),
+		Timeout:        10 * time.Second,
+	}
+
+	var err error
+	if s.SkipTLSVerification, err = getEnvBool("SKIP_TLS_VERIFICATION"); err != nil {
+		return nil, err
+	}
+	if s.GroupMap, err = parseGroupMap(getEnv("GROUP_MAP", "")); err != nil {
+		return nil, err
+	}
+	if timeout := getEnv("TIMEOUT", ""); timeout != "" {
+		if s.Timeout, err = time.ParseDuration(timeout); err != nil {
+			return nil, errors.Wrapf(err, "invalid value of %sTIMEOUT", envPrefix)
+		}
+	}
+
+	if err = s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Checks if the settings are complete and consistent.
+func (s *settings) validate() error {
+	switch {
+	case s.Issuer == "":
+		return errors.Errorf("%sISSUER is required", envPrefix)
+	case s.ClientID == "":
+		return errors.Errorf("%sCLIENT_ID is required", envPrefix)
+	case s.IDClaim == "":
+		return errors.Errorf("%sCLAIM_ID must not be empty", envPrefix)
+	case s.Timeout <= 0:
+		return errors.Errorf("%sTIMEOUT must be positive", envPrefix)
+	}
+	issuerURL, err := url.Parse(s.Issuer)
+	if err != nil || issuerURL.Host == "" {
+		return errors.Errorf("invalid issuer URL %s", s.Issuer)
+	}
+	if issuerURL.Scheme != "https" && issuerURL.Scheme != "http" {
+		return errors.Errorf("unsupported scheme of the issuer URL %s", s.Issuer)
+	}
+	return nil
+}
+
+// Returns the HTTP client used to communicate with the provider.
+func (s *settings) getHTTPClient() (*http.Client, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: s.SkipTLSVerification, //nolint:gosec
+	}
+	if s.RootCAFile != "" {
+		pem, err := os.ReadFile(s.RootCAFile)
+		if err != nil {
+			return nil, errors.Wrapf(err, "cannot read the root CA file %s", s.RootCAFile)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no valid certificates found in the root CA file %s", s.RootCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{
+		Transport: transport,
+		Timeout:   s.Timeout,
+	}, nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the group mapping is parsed.
+func TestParseGroupMap(t *testing.T) {
+	groupMap, err := parseGroupMap("1:stork-super-admins; 3 : noc ;")
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"stork-super-admins": 1, "noc": 3}, groupMap)
+
+	groupMap, err = parseGroupMap("")
+	require.NoError(t, err)
+	require.Empty(t, groupMap)
+
+	_, err = parseGroupMap("admins")
+	require.Error(t, err)
+	_, err = parseGroupMap("admin:admins")
+	require.Error(t, err)
+	_, err = parseGroupMap("0:admins")
+	require.Error(t, err)
+	_, err = parseGroupMap("1: ")
+	require.Error(t, err)
+}
+
+// Test that the openid scope is always requested.
+func TestParseScopes(t *testing.T) {
+	require.Equal(t, []string{"openid", "profile", "email"}, parseScopes("openid profile email"))
+	require.Equal(t, []string{"openid", "groups"}, parseScopes("groups"))
+	require.Equal(t, []string{"openid"}, parseScopes(""))
+}
+
+// Test that the settings are read from the environment variables.
+func TestLoadSettingsFromEnv(t *testing.T) {
+	t.Setenv(envPrefix+"ISSUER", "https://idp.example.org/realms/stork/")
+	t.Setenv(envPrefix+"CLIENT_ID", "stork")
+	t.Setenv(envPrefix+"CLIENT_SECRET", "secret")
+	t.Setenv(envPrefix+"SCOPES", "profile email groups")
+	t.Setenv(envPrefix+"CLAIM_GROUPS", "roles")
+	t.Setenv(envPrefix+"GROUP_MAP", "1:stork-admins")
+	t.Setenv(envPrefix+"BUTTON_LABEL", "Sign in with Keycloak")
+	t.Setenv(envPrefix+"TIMEOUT", "3s")
+
+	s, err := loadSettingsFromEnv()
+	require.NoError(t, err)
+	require.Equal(t, "https://idp.example.org/realms/stork", s.Issuer)
+	require.Equal(t, "stork", s.ClientID)
+	require.Equal(t, "secret", s.ClientSecret)
+	require.Equal(t, []string{"openid", "profile", "email", "groups"}, s.Scopes)
+	require.Equal(t, "sub", s.IDClaim)
+	require.Equal(t, "preferred_username", s.LoginClaim)
+	require.Equal(t, "roles", s.GroupsClaim)
+	require.Equal(t, map[string]int{"stork-admins": 1}, s.GroupMap)
+	require.Equal(t, "Sign in with Keycloak", s.ButtonLabel)
+	require.Equal(t, 3*time.Second, s.Timeout)
+	require.False(t, s.SkipTLSVerification)
+}
+
+// Test that the required settings are validated.
+func TestLoadSettingsFromEnvValidation(t *testing.T) {
+	_, err := loadSettingsFromEnv()
+	require.ErrorContains(t, err, "ISSUER is required")
+
+	t.Setenv(envPrefix+"ISSUER", "https://idp.example.org")
+	_, err = loadSettingsFromEnv()
+	require.ErrorContains(t, err, "CLIENT_ID is required")
+
+	t.Setenv(envPrefix+"CLIENT_ID", "stork")
+	t.Setenv(envPrefix+"TIMEOUT", "soon")
+	_, err = loadSettingsFromEnv()
+	require.ErrorContains(t, err, "TIMEOUT")
+
+	t.Setenv(envPrefix+"TIMEOUT", "1s")
+	t.Setenv(envPrefix+"ISSUER", "ftp://idp.example.org")
+	_, err = loadSettingsFromEnv()
+	require.ErrorContains(t, err, "unsupported scheme")
+
+	t.Setenv(envPrefix+"ISSUER", "https://idp.example.org")
+	_, err = loadSettingsFromEnv()
+	require.NoError(t, err)
+}
+
+// Test that the HTTP client uses the specified root CA file.
+func TestGetHTTPClient(t *testing.T) {
+	s := &settings{Timeout: time.Second}
+	client, err := s.getHTTPClient()
+	require.NoError(t, err)
+	require.Equal(t, time.Second, client.Timeout)
+
+	s.RootCAFile = filepath.Join(t.TempDir(), "ca.pem")
+	_, err = s.getHTTPClient()
+	require.ErrorContains(t, err, "cannot read the root CA file")
+
+	require.NoError(t, os.WriteFile(s.RootCAFile, []byte("not a certificate"), 0o600))
+	_, err = s.getHTTPClient()
+	require.ErrorContains(t, err, "no valid certificates")
+}
@@ -23,6 +23,10 @@ export class AuthInterceptor implements HttpInterceptor {
             // the user to the login page.
             this.auth.destroyLocalSession()
             this.router.navigateByUrl('/login')
+        } else if (err.status === 403 && this.auth.isPasswordChangeRequired()) {
+            // The user has to change the password before accessing other
+            // resources.
+            this.router.navigateByUrl('/profile/password')
         } else if (err.status === 403) {
             // User has no access to the given view. Let's redirect the
             // user to the error page.
@@ -26,6 +26,11 @@ export class AuthGuard implements CanActivate {
             //     return false;
             // }
 
+            // The user who has to change the password can't access other pages.
+            if (currentUser.passwordChangeRequired && state.url !== '/profile/password') {
+                return this.router.parseUrl('/profile/password')
+            }
+
             // authorized so return true
             return true
         }
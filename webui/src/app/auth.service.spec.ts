@@ -95,4 +95,21 @@ describe('AuthService', () => {
         expect(methods.length).toBe(1)
         expect(methods[0].id).toBe('internal')
     })
+
+    it('should store the logged user after the redirect-based login', () => {
+        const usersService: UsersService = TestBed.inject(UsersService)
+        spyOn(usersService, 'getSession').and.returnValue(
+            of({ id: 42, authenticationMethodId: 'oidc' } as User & HttpProgressEvent)
+        )
+        const router = TestBed.inject(Router)
+        router.navigate = jasmine.createSpy('navigate')
+
+        const authService = TestBed.inject(AuthService)
+        authService.finishRedirectLogin('/machines/all')
+
+        expect(authService.currentUserValue.id).toBe(42)
+        expect(authService.isInternalUser()).toBeFalse()
+        expect(router.navigate).toHaveBeenCalledWith(['/machines/all'])
+        localStorage.removeItem('currentUser')
+    })
 })
@@ -8,7 +8,7 @@ import { MessageService } from 'primeng/api'
 import { UsersService } from './backend/api/users.service'
 import { AuthenticationMethod } from './backend/model/authenticationMethod'
 import { SessionCredentials } from './backend/model/sessionCredentials'
-import { User } from './backend'
+import { SecondFactorChallenge, SecondFactorLogin, SecondFactorVerification, TotpEnrollment, User } from './backend'
 import { getErrorMessage } from './utils'
 
 @Injectable({
@@ -18,10 +18,20 @@ export class AuthService {
     private currentUserSubject: BehaviorSubject<User>
     public currentUser: Observable<User>
     private authenticationMethods: Observable<AuthenticationMethod[]>
+    private secondFactorSubject: BehaviorSubject<SecondFactorChallenge>
+
+    /**
+     * Emits the challenge when the user has provided a valid password but
+     * has to provide the second authentication factor to log in. It emits
+     * null when the login is completed or cancelled.
+     */
+    public secondFactor: Observable<SecondFactorChallenge>
 
     constructor(private api: UsersService, private router: Router, private msgSrv: MessageService) {
         this.currentUserSubject = new BehaviorSubject<User>(JSON.parse(localStorage.getItem('currentUser')))
         this.currentUser = this.currentUserSubject.asObservable()
+        this.secondFactorSubject = new BehaviorSubject<SecondFactorChallenge>(null)
+        this.secondFactor = this.secondFactorSubject.asObservable()
         this.authenticationMethods = api.getAuthenticationMethods().pipe(
             // Delay to limit the number of requests sent to the backend on
             // failure. Waits in sequence 1, 2, 4, 8, 16, and max. 32 seconds.
@@ -41,6 +51,10 @@ export class AuthService {
     /**
      * Attempts to create a session for a user.
      *
+     * If the user has to provide the second authentication factor, the
+     * challenge is emitted by the secondFactor observable and the login
+     * has to be completed using the verifySecondFactor function.
+     *
      * @param identifier Specified identifier (e.g., user name).
      * @param secret Specified secret (e.g., password).
      * @param authenticationMethodId Specified authentication method ID.
@@ -49,20 +63,138 @@ export class AuthService {
     login(authenticationMethodId: string, identifier: string, secret: string, returnUrl: string) {
         let user: User
         const credentials: SessionCredentials = { authenticationMethodId, identifier, secret }
-        this.api.createSession(credentials).subscribe(
+        this.api.createSession(credentials, 'response').subscribe(
+            (resp) => {
+                if (resp.status === 202) {
+                    this.secondFactorSubject.next(resp.body as SecondFactorChallenge)
+                    return
+                }
+                const user = resp.body
+                if (user?.id != null) {
+                    this.storeLoggedUser(user, returnUrl)
+                }
+            },
+            (err) => {
+                // The error message is returned when the account is locked.
+                const detail = err.error?.message
+                this.msgSrv.add({ severity: 'error', summary: 'Invalid login or password', detail })
+            }
+        )
+        return user
+    }
+
+    /**
+     * Stores the logged user locally and navigates to the return URL. The
+     * user who has to change the password is navigated to the password
+     * change page instead.
+     *
+     * @param user Logged user.
+     * @param returnUrl URL to return to after successful login.
+     */
+    private storeLoggedUser(user: User, returnUrl: string) {
+        this.currentUserSubject.next(user)
+        localStorage.setItem('currentUser', JSON.stringify(user))
+        if (user.passwordChangeRequired) {
+            this.msgSrv.add({
+                severity: 'warn',
+                summary: 'Password change required',
+                detail: 'Your password has expired or has been set by an administrator. Please change it.',
+                sticky: true,
+            })
+            this.router.navigate(['/profile/password'])
+            return
+        }
+        // ToDo: Unhandled exception from promise
+        this.router.navigate([returnUrl])
+    }
+
+    /**
+     * Marks that the logged user has changed the password and can access
+     * other pages.
+     */
+    passwordChanged() {
+        const user = this.currentUserValue
+        if (user?.passwordChangeRequired) {
+            const updated = { ...user, passwordChangeRequired: false }
+            this.currentUserSubject.next(updated)
+            localStorage.setItem('currentUser', JSON.stringify(updated))
+        }
+    }
+
+    /**
+     * Checks if the logged user has to change the password before accessing
+     * other pages.
+     *
+     * @returns true if the password change is required.
+     */
+    isPasswordChangeRequired(): boolean {
+        return !!this.currentUserValue?.passwordChangeRequired
+    }
+
+    /**
+     * Begins the enrollment of the second authentication factor of the user
+     * who is required to use it but hasn't enrolled yet.
+     *
+     * @returns The TOTP secret to be provisioned in the authenticator app.
+     */
+    beginSecondFactorEnrollment(): Observable<TotpEnrollment> {
+        return this.api.createSessionTotpEnrollment()
+    }
+
+    /**
+     * Verifies the second authentication factor of the user logging in.
+     * The login has to be finished using the finishSecondFactorLogin
+     * function when the verification succeeds.
+     *
+     * @param verification TOTP or recovery code.
+     * @returns The logged user and the recovery codes generated if the user
+     *          has enrolled while logging in.
+     */
+    verifySecondFactor(verification: SecondFactorVerification): Observable<SecondFactorLogin> {
+        return this.api.createSessionTotp(verification)
+    }
+
+    /**
+     * Finishes the login after the successful second factor verification.
+     *
+     * @param user Logged user returned by the verification.
+     * @param returnUrl URL to return to after successful login.
+     */
+    finishSecondFactorLogin(user: User, returnUrl: string) {
+        this.secondFactorSubject.next(null)
+        this.storeLoggedUser(user, returnUrl)
+    }
+
+    /**
+     * Cancels the second factor verification. The user has to provide the
+     * password again.
+     */
+    cancelSecondFactor() {
+        this.secondFactorSubject.next(null)
+    }
+
+    /**
+     * Finishes the redirect-based login (e.g., OpenID Connect). The session
+     * is created by the backend when the identity provider redirects the
+     * user back to Stork. This function fetches the logged user and stores
+     * it locally.
+     *
+     * @param returnUrl URL to return to after successful login.
+     */
+    finishRedirectLogin(returnUrl: string) {
+        this.api.getSession().subscribe(
             (user) => {
                 if (user.id != null) {
                     this.currentUserSubject.next(user)
                     localStorage.setItem('currentUser', JSON.stringify(user))
-                    // ToDo: Unhandled exception from promise
                     this.router.navigate([returnUrl])
                 }
             },
             (err) => {
-                this.msgSrv.add({ severity: 'error', summary: 'Invalid login or password' })
+                const msg = getErrorMessage(err)
+                this.msgSrv.add({ severity: 'error', summary: 'Cannot fetch the logged user', detail: msg })
             }
         )
-        return user
     }
 
     /**
@@ -4,15 +4,18 @@ import { Router, ActivatedRoute } from '@angular/router'
 
 import { GeneralService } from '../backend/api/api'
 import { AuthenticationMethod } from '../backend/model/authenticationMethod'
+import { SecondFactorChallenge, SecondFactorVerification, TotpEnrollment, User } from '../backend'
 import { AuthService } from '../auth.service'
 import { Subscription } from 'rxjs'
+import { MessageService } from 'primeng/api'
+import { getErrorMessage } from '../utils'
 
 @Component({
     selector: 'app-login-screen',
     templateUrl: './login-screen.component.html',
     styleUrls: ['./login-screen.component.sass'],
 })
-export class LoginScreenComponent implements OnInit {
+export class LoginScreenComponent implements OnInit, OnDestroy {
     /**
      * Stork version.
      */
@@ -38,12 +41,53 @@ export class LoginScreenComponent implements OnInit {
      */
     authenticationMethod: AuthenticationMethod
 
+    /**
+     * Challenge returned when the user has to provide the second
+     * authentication factor. It is null if the user hasn't provided the
+     * password yet.
+     */
+    secondFactor: SecondFactorChallenge = null
+
+    /**
+     * The TOTP secret generated when the user has to enroll the second
+     * factor while logging in.
+     */
+    enrollment: TotpEnrollment = null
+
+    /**
+     * Object representing the second factor form.
+     */
+    secondFactorForm: UntypedFormGroup
+
+    /**
+     * Indicates if the user provides the recovery code instead of the TOTP
+     * code.
+     */
+    useRecoveryCode = false
+
+    /**
+     * Recovery codes generated when the user has enrolled while logging in.
+     * They are displayed before finishing the login.
+     */
+    recoveryCodes: string[] = null
+
+    /**
+     * The user logged in after the enrollment.
+     */
+    private enrolledUser: User = null
+
+    /**
+     * Subscriptions released when the component is destroyed.
+     */
+    private subscriptions = new Subscription()
+
     constructor(
         protected api: GeneralService,
         private auth: AuthService,
         private route: ActivatedRoute,
         private router: Router,
-        private formBuilder: UntypedFormBuilder
+        private formBuilder: UntypedFormBuilder,
+        private msgSrv: MessageService
     ) {}
 
     /**
@@ -60,12 +104,41 @@ export class LoginScreenComponent implements OnInit {
         // Set the return URL.
         this.returnUrl = this.route.snapshot.queryParams.returnUrl || '/'
 
+        // Handle the result of the redirect-based authentication.
+        const sso = this.route.snapshot.queryParams.sso
+        if (sso === 'ok') {
+            this.auth.finishRedirectLogin(this.returnUrl)
+        } else if (sso === 'failed') {
+            this.msgSrv.add({
+                severity: 'error',
+                summary: 'Single sign-on failed',
+                detail: 'The identity provider did not authenticate the user.',
+            })
+        }
+
         // Initialize the login form controls.
         this.loginForm = this.formBuilder.group({
             authenticationMethodId: ['', Validators.required],
             identifier: ['', Validators.required],
             secret: ['', Validators.required],
         })
+        this.secondFactorForm = this.formBuilder.group({
+            code: ['', Validators.required],
+        })
+
+        // Show the second factor form when the password is valid but the
+        // user has to provide the second factor.
+        this.subscriptions.add(
+            this.auth.secondFactor.subscribe((challenge) => {
+                this.secondFactor = challenge
+                this.enrollment = null
+                this.useRecoveryCode = false
+                this.secondFactorForm.reset()
+                if (challenge?.enrollmentRequired) {
+                    this.beginEnrollment()
+                }
+            })
+        )
 
         // Fetch version.
         this.api
@@ -88,6 +161,13 @@ export class LoginScreenComponent implements OnInit {
             })
     }
 
+    /**
+     * Unsubscribes from the second factor challenges.
+     */
+    ngOnDestroy() {
+        this.subscriptions.unsubscribe()
+    }
+
     /**
      * Callback called when the authentication method icon is missing.
      * It hides the icon to prevent displaying an ugly placeholder.
@@ -111,10 +191,101 @@ export class LoginScreenComponent implements OnInit {
      * Performs a login operation.
      */
     signIn() {
+        if (this.authenticationMethod.redirect) {
+            this.redirectToIdentityProvider()
+            return
+        }
         this.auth.login(this.authenticationMethod.id, this.f.identifier.value, this.f.secret.value, this.returnUrl)
         this.router.navigate([this.returnUrl])
     }
 
+    /**
+     * Generates the TOTP secret for the user who has to enroll the second
+     * factor while logging in.
+     */
+    beginEnrollment() {
+        this.auth
+            .beginSecondFactorEnrollment()
+            .toPromise()
+            .then((enrollment) => {
+                this.enrollment = enrollment
+            })
+            .catch((err) => {
+                this.msgSrv.add({
+                    severity: 'error',
+                    summary: 'Cannot begin the two-factor authentication enrollment',
+                    detail: getErrorMessage(err),
+                })
+                this.auth.cancelSecondFactor()
+            })
+    }
+
+    /**
+     * Verifies the TOTP or recovery code and finishes the login. If the
+     * user has enrolled while logging in, the recovery codes are displayed
+     * first.
+     */
+    verifySecondFactor() {
+        const code = this.secondFactorForm.controls.code.value
+        const verification: SecondFactorVerification = this.useRecoveryCode ? { recoveryCode: code } : { code }
+        this.auth
+            .verifySecondFactor(verification)
+            .toPromise()
+            .then((login) => {
+                if (login.recoveryCodes?.length > 0) {
+                    this.recoveryCodes = login.recoveryCodes
+                    this.enrolledUser = login.user
+                    return
+                }
+                this.auth.finishSecondFactorLogin(login.user, this.returnUrl)
+            })
+            .catch((err) => {
+                this.msgSrv.add({
+                    severity: 'error',
+                    summary: 'Two-factor authentication failed',
+                    detail: getErrorMessage(err),
+                })
+                this.secondFactorForm.reset()
+            })
+    }
+
+    /**
+     * Finishes the login after the user has saved the recovery codes.
+     */
+    finishEnrollment() {
+        this.recoveryCodes = null
+        this.auth.finishSecondFactorLogin(this.enrolledUser, this.returnUrl)
+    }
+
+    /**
+     * Cancels the second factor verification and returns to the password
+     * form.
+     */
+    cancelSecondFactor() {
+        this.auth.cancelSecondFactor()
+    }
+
+    /**
+     * Callback called on the key pressing in the second factor form.
+     * It triggers the verification if the Enter key is pressed.
+     * @param event
+     */
+    secondFactorKeyUp(event) {
+        if (event.key === 'Enter' && this.secondFactorForm.valid) {
+            this.verifySecondFactor()
+        }
+    }
+
+    /**
+     * Begins the redirect-based authentication. The backend redirects the
+     * user to the identity provider.
+     */
+    redirectToIdentityProvider() {
+        const id = encodeURIComponent(this.authenticationMethod.id)
+        const returnUrl = encodeURIComponent(this.returnUrl)
+        window.location.href = `/api/sessions/redirect/${id}?returnUrl=${returnUrl}`
+    }
+
     /**
      * Performs a log out operation.
      * It redirect a user to the login form.
@@ -18,6 +18,8 @@ import { MenuModule } from 'primeng/menu'
 import { RouterTestingModule } from '@angular/router/testing'
 import { PasswordModule } from 'primeng/password'
 import { MessageModule } from 'primeng/message'
+import { of } from 'rxjs'
+import { AuthService } from '../auth.service'
 
 describe('PasswordChangePageComponent', () => {
     let component: PasswordChangePageComponent
@@ -69,4 +71,28 @@ describe('PasswordChangePageComponent', () => {
         expect(breadcrumbsComponent.items[0].label).toEqual('User Profile')
         expect(breadcrumbsComponent.items[1].label).toEqual('Password Change')
     })
+
+    it('should navigate to the dashboard after the required password change', () => {
+        const auth = fixture.debugElement.injector.get(AuthService)
+        const router = fixture.debugElement.injector.get(Router)
+        const usersApi = fixture.debugElement.injector.get(UsersService)
+        spyOnProperty(auth, 'currentUserValue').and.returnValue({ id: 1, passwordChangeRequired: true } as any)
+        spyOn(auth, 'passwordChanged')
+        spyOn(router, 'navigate')
+        spyOn(usersApi, 'updateUserPassword').and.returnValue(of({} as any))
+
+        component.passwordChangeForm.setValue({
+            oldPassword: 'old-password',
+            newPassword: 'new-password',
+            confirmPassword: 'new-password',
+        })
+        component.passwordChangeFormSubmit()
+
+        expect(usersApi.updateUserPassword).toHaveBeenCalledWith(1, {
+            oldpassword: 'old-password',
+            newpassword: 'new-password',
+        })
+        expect(auth.passwordChanged).toHaveBeenCalled()
+        expect(router.navigate).toHaveBeenCalledWith(['/'])
+    })
 })
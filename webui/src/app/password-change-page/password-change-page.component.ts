@@ -1,5 +1,6 @@
 import { Component, OnInit } from '@angular/core'
 import { UntypedFormBuilder, UntypedFormGroup, Validators } from '@angular/forms'
+import { Router } from '@angular/router'
 
 import { MessageService } from 'primeng/api'
 
@@ -24,7 +25,8 @@ export class PasswordChangePageComponent implements OnInit {
         private formBuilder: UntypedFormBuilder,
         private usersApi: UsersService,
         private msgSrv: MessageService,
-        private auth: AuthService
+        private auth: AuthService,
+        private router: Router
     ) {}
 
     ngOnInit() {
@@ -75,6 +77,10 @@ export class PasswordChangePageComponent implements OnInit {
                     severity: 'success',
                     summary: 'User password updated',
                 })
+                if (this.auth.isPasswordChangeRequired()) {
+                    this.auth.passwordChanged()
+                    this.router.navigate(['/'])
+                }
             },
             (err) => {
                 const msg = getErrorMessage(err)
@@ -18,6 +18,9 @@ import { MenuModule } from 'primeng/menu'
 import { OverlayPanelModule } from 'primeng/overlaypanel'
 import { RouterTestingModule } from '@angular/router/testing'
 import { PlaceholderPipe } from '../pipes/placeholder.pipe'
+import { FormsModule } from '@angular/forms'
+import { ButtonModule } from 'primeng/button'
+import { InputTextModule } from 'primeng/inputtext'
 
 describe('ProfilePageComponent', () => {
     let component: ProfilePageComponent
@@ -51,6 +54,9 @@ describe('ProfilePageComponent', () => {
                 OverlayPanelModule,
                 NoopAnimationsModule,
                 RouterTestingModule,
+                FormsModule,
+                ButtonModule,
+                InputTextModule,
             ],
         }).compileComponents()
     }))
@@ -94,6 +100,26 @@ describe('ProfilePageComponent', () => {
         expect(externalIdValue.textContent).toContain('(not specified)')
     })
 
+    it('should display the recovery codes after enabling two-factor authentication', async () => {
+        const usersApi = fixture.debugElement.injector.get(UsersService)
+        spyOn(usersApi, 'updateUserTotp').and.returnValue(of({ items: ['aaaaa-bbbbb', 'ccccc-ddddd'] } as any))
+        spyOn(usersApi, 'getUserTotp').and.returnValue(
+            of({ enabled: true, required: false, recoveryCodesRemaining: 2 } as any)
+        )
+        component.totpEnrollment = { secret: 'JBSWY3DPEHPK3PXP', provisioningUri: 'otpauth://', qrCode: '' }
+        component.totpCode = '123456'
+
+        component.confirmTotpEnrollment()
+        await fixture.whenStable()
+        fixture.detectChanges()
+
+        expect(usersApi.updateUserTotp).toHaveBeenCalledWith(1, { code: '123456' })
+        expect(component.totpEnrollment).toBeNull()
+        expect(component.recoveryCodes).toEqual(['aaaaa-bbbbb', 'ccccc-ddddd'])
+        expect(component.totpStatus.enabled).toBeTrue()
+        expect(fixture.nativeElement.textContent).toContain('ccccc-ddddd')
+    })
+
     it('should display the value if the external ID is not empty', () => {
         component.currentUser.externalId = 'foobar'
         fixture.detectChanges()
@@ -1,8 +1,10 @@
 import { Component, OnDestroy, OnInit } from '@angular/core'
 import { AuthService } from '../auth.service'
 import { ServerDataService } from '../server-data.service'
-import { User } from '../backend'
+import { TotpEnrollment, TotpStatus, User, UsersService } from '../backend'
 import { Subscription } from 'rxjs'
+import { MessageService } from 'primeng/api'
+import { getErrorMessage } from '../utils'
 
 /**
  * This component is for displaying information about the user's account.
@@ -19,12 +21,38 @@ export class ProfilePageComponent implements OnInit, OnDestroy {
     private groups: any[]
     public groupName: string
 
+    /**
+     * Status of the TOTP second factor of the internal user.
+     */
+    totpStatus: TotpStatus = null
+
+    /**
+     * The TOTP secret generated when the user begins the enrollment.
+     */
+    totpEnrollment: TotpEnrollment = null
+
+    /**
+     * Code confirming the TOTP enrollment.
+     */
+    totpCode = ''
+
+    /**
+     * Recovery codes returned when the enrollment is confirmed. They are
+     * displayed only once.
+     */
+    recoveryCodes: string[] = null
+
     /**
      * List of subscriptions created by the component.
      */
     private subscriptions = new Subscription()
 
-    constructor(private auth: AuthService, private serverData: ServerDataService) {
+    constructor(
+        private auth: AuthService,
+        private serverData: ServerDataService,
+        private usersApi: UsersService,
+        private msgSrv: MessageService
+    ) {
         this.subscriptions.add(
             this.auth.currentUser.subscribe((user) => {
                 this.currentUser = user
@@ -45,6 +73,100 @@ export class ProfilePageComponent implements OnInit, OnDestroy {
                 }
             }
         })
+        if (this.currentUser?.authenticationMethodId === 'internal') {
+            this.loadTotpStatus()
+        }
+    }
+
+    /**
+     * Fetches the status of the TOTP second factor of the user.
+     */
+    loadTotpStatus() {
+        this.usersApi
+            .getUserTotp(this.currentUser.id)
+            .toPromise()
+            .then((status) => {
+                this.totpStatus = status
+            })
+            .catch((err) => {
+                this.msgSrv.add({
+                    severity: 'error',
+                    summary: 'Cannot get two-factor authentication status',
+                    detail: getErrorMessage(err),
+                })
+            })
+    }
+
+    /**
+     * Begins the TOTP enrollment. It displays the secret to be provisioned
+     * in the authenticator app.
+     */
+    beginTotpEnrollment() {
+        this.recoveryCodes = null
+        this.usersApi
+            .createUserTotp(this.currentUser.id)
+            .toPromise()
+            .then((enrollment) => {
+                this.totpEnrollment = enrollment
+                this.totpCode = ''
+            })
+            .catch((err) => {
+                this.msgSrv.add({
+                    severity: 'error',
+                    summary: 'Cannot begin two-factor authentication enrollment',
+                    detail: getErrorMessage(err),
+                })
+            })
+    }
+
+    /**
+     * Confirms the TOTP enrollment with the code generated by the
+     * authenticator app and displays the recovery codes.
+     */
+    confirmTotpEnrollment() {
+        this.usersApi
+            .updateUserTotp(this.currentUser.id, { code: this.totpCode })
+            .toPromise()
+            .then((codes) => {
+                this.totpEnrollment = null
+                this.recoveryCodes = codes.items
+                this.msgSrv.add({
+                    severity: 'success',
+                    summary: 'Two-factor authentication enabled',
+                })
+                this.loadTotpStatus()
+            })
+            .catch((err) => {
+                this.msgSrv.add({
+                    severity: 'error',
+                    summary: 'Cannot enable two-factor authentication',
+                    detail: getErrorMessage(err),
+                })
+            })
+    }
+
+    /**
+     * Disables the TOTP second factor of the user.
+     */
+    disableTotp() {
+        this.usersApi
+            .deleteUserTotp(this.currentUser.id)
+            .toPromise()
+            .then(() => {
+                this.recoveryCodes = null
+                this.msgSrv.add({
+                    severity: 'success',
+                    summary: 'Two-factor authentication disabled',
+                })
+                this.loadTotpStatus()
+            })
+            .catch((err) => {
+                this.msgSrv.add({
+                    severity: 'error',
+                    summary: 'Cannot disable two-factor authentication',
+                    detail: getErrorMessage(err),
+                })
+            })
     }
 
     /**
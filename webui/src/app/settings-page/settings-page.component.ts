@@ -19,11 +19,35 @@ export class SettingsPageComponent implements OnInit {
     constructor(private fb: UntypedFormBuilder, private settingsApi: SettingsService, private msgSrv: MessageService) {
         this.settingsForm = this.fb.group({
             bind9_stats_puller_interval: ['', [Validators.required, Validators.min(0)]],
+            bind9_zone_drift_checker_interval: ['', [Validators.required, Validators.min(0)]],
+            bind9_zone_lag_threshold: ['', [Validators.required, Validators.min(0)]],
             grafana_url: [''],
             kea_hosts_puller_interval: ['', [Validators.required, Validators.min(0)]],
             kea_stats_puller_interval: ['', [Validators.required, Validators.min(0)]],
             kea_status_puller_interval: ['', [Validators.required, Validators.min(0)]],
             prometheus_url: [''],
+            audit_log_retention_days: ['', [Validators.required, Validators.min(0)]],
+            event_retention_days: ['', [Validators.required, Validators.min(0)]],
+            event_retention_max_info: ['', [Validators.required, Validators.min(0)]],
+            event_retention_max_warning: ['', [Validators.required, Validators.min(0)]],
+            event_retention_max_error: ['', [Validators.required, Validators.min(0)]],
+            utilization_forecast_horizon_days: ['', [Validators.required, Validators.min(0)]],
+            utilization_forecast_method: ['linear'],
+            password_min_length: ['', [Validators.required, Validators.min(0)]],
+            password_require_mixed_case: [false],
+            password_require_digit: [false],
+            password_require_special_character: [false],
+            password_history_count: ['', [Validators.required, Validators.min(0)]],
+            password_expiration_days: ['', [Validators.required, Validators.min(0)]],
+            password_change_on_first_login: [false],
+            account_lockout_threshold: ['', [Validators.required, Validators.min(0)]],
+            account_lockout_duration: ['', [Validators.required, Validators.min(0)]],
+            smtp_host: [''],
+            smtp_port: ['', [Validators.required, Validators.min(1), Validators.max(65535)]],
+            smtp_security: ['starttls'],
+            smtp_username: [''],
+            smtp_password: [''],
+            smtp_sender: [''],
         })
     }
 
@@ -32,11 +56,40 @@ export class SettingsPageComponent implements OnInit {
             (data) => {
                 const numericSettings = [
                     'bind9_stats_puller_interval',
+                    'bind9_zone_drift_checker_interval',
+                    'bind9_zone_lag_threshold',
                     'kea_hosts_puller_interval',
                     'kea_stats_puller_interval',
                     'kea_status_puller_interval',
+                    'audit_log_retention_days',
+                    'event_retention_days',
+                    'event_retention_max_info',
+                    'event_retention_max_warning',
+                    'event_retention_max_error',
+                    'utilization_forecast_horizon_days',
+                    'password_min_length',
+                    'password_history_count',
+                    'password_expiration_days',
+                    'account_lockout_threshold',
+                    'account_lockout_duration',
+                    'smtp_port',
+                ]
+                const stringSettings = [
+                    'grafana_url',
+                    'prometheus_url',
+                    'utilization_forecast_method',
+                    'smtp_host',
+                    'smtp_security',
+                    'smtp_username',
+                    'smtp_password',
+                    'smtp_sender',
+                ]
+                const booleanSettings = [
+                    'password_require_mixed_case',
+                    'password_require_digit',
+                    'password_require_special_character',
+                    'password_change_on_first_login',
                 ]
-                const stringSettings = ['grafana_url', 'prometheus_url']
 
                 for (const s of numericSettings) {
                     if (data[s] === undefined) {
@@ -48,6 +101,11 @@ export class SettingsPageComponent implements OnInit {
                         data[s] = ''
                     }
                 }
+                for (const s of booleanSettings) {
+                    if (data[s] === undefined) {
+                        data[s] = false
+                    }
+                }
 
                 this.settingsForm.patchValue(data)
             },
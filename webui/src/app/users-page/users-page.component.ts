@@ -560,6 +560,74 @@ export class UsersPageComponent implements OnInit, OnDestroy {
             })
     }
 
+    /**
+     * Displays a dialog to confirm resetting the second authentication
+     * factor of the user.
+     */
+    confirmResetUserTotp() {
+        this.confirmService.confirm({
+            message:
+                'Are you sure that you want to reset the two-factor authentication of this user? ' +
+                'The user will have to set it up again if it is required by the user group.',
+            header: 'Reset Two-Factor Authentication',
+            icon: 'pi pi-exclamation-triangle',
+            accept: () => {
+                this.resetUserTotp()
+            },
+        })
+    }
+
+    /**
+     * Resets the second authentication factor of the user, e.g. when the
+     * user has lost the authenticator app and the recovery codes.
+     */
+    resetUserTotp() {
+        this.usersApi
+            .deleteUserTotp(this.userTab.user.id)
+            .toPromise()
+            .then(() => {
+                this.msgSrv.add({
+                    severity: 'success',
+                    summary: 'Two-factor authentication reset',
+                    detail: 'Resetting two-factor authentication of the user succeeded.',
+                })
+            })
+            .catch((err) => {
+                const msg = getErrorMessage(err)
+                this.msgSrv.add({
+                    severity: 'error',
+                    summary: 'Failed to reset two-factor authentication',
+                    detail: 'Resetting two-factor authentication of the user failed: ' + msg,
+                    sticky: true,
+                })
+            })
+    }
+
+    /**
+     * Unlocks the user locked out after too many failed login attempts.
+     */
+    unlockUser() {
+        this.usersApi
+            .deleteUserLockout(this.userTab.user.id)
+            .toPromise()
+            .then(() => {
+                this.msgSrv.add({
+                    severity: 'success',
+                    summary: 'User account unlocked',
+                    detail: 'The user can log in again.',
+                })
+            })
+            .catch((err) => {
+                const msg = getErrorMessage(err)
+                this.msgSrv.add({
+                    severity: 'error',
+                    summary: 'Failed to unlock user account',
+                    detail: 'Unlocking the user account failed: ' + msg,
+                    sticky: true,
+                })
+            })
+    }
+
     /**
      * Action invoked when a user form is saved
      *